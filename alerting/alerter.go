@@ -0,0 +1,156 @@
+package alerting
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+	"compliance-agent/logging"
+)
+
+// logger is the alerters' log.
+func logger() *slog.Logger {
+	return logging.Component("alerting")
+}
+
+// Alerter is a notification destination. Each backend (Slack today;
+// email, Teams, PagerDuty, ... later) implements it and registers a
+// factory, so main never needs to know which backends exist.
+type Alerter interface {
+	// Name identifies the backend in logs and config ("slack").
+	Name() string
+	// SendReport posts the scan summary.
+	SendReport(report ComplianceReport) error
+	// SendViolations posts an urgent alert for the given findings.
+	SendViolations(hostname string, violations []analyzer.Violation) error
+	// Test checks the destination is configured and reachable.
+	Test() error
+}
+
+// TestSender is implemented by alerters whose Test only checks
+// configuration but that can also deliver a harmless test message, as
+// `notify test` does. Alerters without it are tested with Test.
+type TestSender interface {
+	SendTest(hostname string) error
+}
+
+// SendTest delivers a test message through a, or tests it with Test
+// when it can't send one.
+func SendTest(a Alerter, hostname string) error {
+	if t, ok := a.(TestSender); ok {
+		return t.SendTest(hostname)
+	}
+	return a.Test()
+}
+
+// Factory builds an alerter from the alerting config. Returning an error
+// means the backend was enabled but is misconfigured.
+type Factory func(cfg config.AlertConfig) (Alerter, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a backend available under name. It is called from the
+// backend's init so adding a backend is one new file.
+func Register(name string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic("alerting: duplicate alerter " + name)
+	}
+	registry[name] = f
+}
+
+// Registered lists the known backend names, sorted.
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return registeredLocked()
+}
+
+func registeredLocked() []string {
+	names := make([]string, 0, len(registry))
+	for n := range registry {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Build instantiates every alerter listed in cfg.Enabled, in order: a
+// backend by its registered name, or one of cfg.Destinations by its
+// name. Those with a detail config are wrapped to limit what they are
+// told, and with a dedup window each is wrapped to suppress repeat
+// alerts.
+func Build(cfg config.AlertConfig) ([]Alerter, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var dedup *dedupState
+	if cfg.Dedup.Window > 0 {
+		var err error
+		if dedup, err = newDedupState(cfg.Dedup); err != nil {
+			return nil, fmt.Errorf("alert dedup state: %w", err)
+		}
+	}
+	for _, d := range cfg.Destinations {
+		if _, taken := registry[d.Name]; taken || d.Name == "" {
+			return nil, fmt.Errorf("destination name %q: must be set and not a backend's name", d.Name)
+		}
+	}
+	var out []Alerter
+	for _, name := range cfg.Enabled {
+		f, bcfg, err := factoryFor(cfg, name)
+		if err != nil {
+			return nil, err
+		}
+		a, err := f(bcfg)
+		if err != nil {
+			return nil, fmt.Errorf("alerter %s: %w", name, err)
+		}
+		if detail, ok := cfg.Detail[name]; ok || a.Name() != name {
+			if a, err = newDetailAlerter(a, name, detail); err != nil {
+				return nil, fmt.Errorf("alerter %s: %w", name, err)
+			}
+		}
+		if dedup != nil {
+			a = &dedupAlerter{Alerter: a, state: dedup}
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+// factoryFor finds what builds the alerter called name, and the config
+// to build it from: a destination's backend sees its own section in
+// place of the top-level one. The caller holds registryMu.
+func factoryFor(cfg config.AlertConfig, name string) (Factory, config.AlertConfig, error) {
+	if f, ok := registry[name]; ok {
+		return f, cfg, nil
+	}
+	for _, d := range cfg.Destinations {
+		if d.Name != name {
+			continue
+		}
+		f, ok := registry[d.Type]
+		if !ok {
+			return nil, cfg, fmt.Errorf("destination %s: unknown type %q (known: %v)", name, d.Type, registeredLocked())
+		}
+		switch d.Type {
+		case "slack":
+			cfg.Slack = d.Slack
+		case "pagerduty":
+			cfg.PagerDuty = d.PagerDuty
+		case "webhook":
+			cfg.Webhook = d.Webhook
+		case "syslog":
+			cfg.Syslog = d.Syslog
+		}
+		return f, cfg, nil
+	}
+	return nil, cfg, fmt.Errorf("unknown alerter %q (known: %v, or a name from destinations)", name, registeredLocked())
+}
@@ -0,0 +1,48 @@
+package alerting
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuild_Slack(t *testing.T) {
+	var got SlackMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer srv.Close()
+
+	alerters, err := Build(config.AlertConfig{
+		Enabled: []string{"slack"},
+		Slack:   config.SlackAlertConfig{WebhookURL: srv.URL, Channel: "#sec"},
+	})
+	require.NoError(t, err)
+	require.Len(t, alerters, 1)
+	assert.Equal(t, "slack", alerters[0].Name())
+
+	require.NoError(t, alerters[0].SendViolations("web-1", []analyzer.Violation{
+		{Category: "user", Severity: analyzer.SeverityCritical, Message: "unexpected user present: eve"},
+	}))
+	assert.Equal(t, "#sec", got.Channel)
+	require.Len(t, got.Attachments, 1)
+	assert.Equal(t, "#8B0000", got.Attachments[0].Color)
+}
+
+func TestBuild_UnknownAlerter(t *testing.T) {
+	_, err := Build(config.AlertConfig{Enabled: []string{"carrier-pigeon"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "carrier-pigeon")
+}
+
+func TestBuild_NoneEnabled(t *testing.T) {
+	alerters, err := Build(config.AlertConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, alerters)
+}
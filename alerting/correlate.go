@@ -0,0 +1,161 @@
+package alerting
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+)
+
+// IncidentCategory is the category of the violation an incident is
+// alerted as.
+const IncidentCategory = "incident"
+
+// DefaultCorrelationRules apply when the config lists none. Each pairs
+// findings that are routine alone but together look like an intrusion.
+var DefaultCorrelationRules = []config.CorrelationRule{
+	{
+		Name: "possible compromise",
+		Signals: map[string][]string{
+			"new account":  {"user"},
+			"new SSH key":  {"authorized_keys"},
+			"new listener": {"port", "root_listener", "listeners"},
+		},
+		MinSignals: 2,
+	},
+	{
+		Name: "possible traffic interception",
+		Signals: map[string][]string{
+			"name resolution": {"hosts_override"},
+			"proxy":           {"proxy"},
+			"gateway":         {"gateway_mac_change", "arp_duplicate"},
+			"interface":       {"promiscuous_interface"},
+		},
+		MinSignals: 2,
+	},
+}
+
+// Incident is a group of related violations from one scan.
+type Incident struct {
+	Rule     string            `json:"rule"`
+	Severity analyzer.Severity `json:"severity"`
+	// Signals are the rule's signals that matched, sorted.
+	Signals    []string             `json:"signals"`
+	Violations []analyzer.Violation `json:"violations"`
+}
+
+// Violation is the incident as one violation, its message carrying the
+// combined evidence, so every alerter can send it. Its risk is its
+// riskiest finding's.
+func (i Incident) Violation() analyzer.Violation {
+	evidence := make([]string, 0, len(i.Violations))
+	var risk float64
+	for _, v := range i.Violations {
+		evidence = append(evidence, v.Message)
+		risk = max(risk, v.Risk)
+	}
+	return analyzer.Violation{
+		Category: IncidentCategory,
+		Severity: i.Severity,
+		Risk:     risk,
+		Message: fmt.Sprintf("%s: %d related findings (%s): %s",
+			i.Rule, len(i.Violations), strings.Join(i.Signals, ", "), strings.Join(evidence, "; ")),
+	}
+}
+
+// CorrelationRules returns the rules cfg asks for: none when disabled,
+// else its own or the defaults.
+func CorrelationRules(cfg config.CorrelationConfig) ([]config.CorrelationRule, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if len(cfg.Rules) == 0 {
+		return DefaultCorrelationRules, nil
+	}
+	return cfg.Rules, validateCorrelationRules(cfg.Rules)
+}
+
+func validateCorrelationRules(rules []config.CorrelationRule) error {
+	for i, r := range rules {
+		if r.Name == "" {
+			return fmt.Errorf("correlation.rules[%d]: name is required", i)
+		}
+		if len(r.Signals) < 2 {
+			return fmt.Errorf("correlation rule %q: needs at least two signals", r.Name)
+		}
+		if r.MinSignals < 0 || r.MinSignals > len(r.Signals) {
+			return fmt.Errorf("correlation rule %q: min_signals %d out of range 1-%d", r.Name, r.MinSignals, len(r.Signals))
+		}
+		if r.Severity != "" {
+			if _, err := analyzer.ParseSeverity(r.Severity); err != nil {
+				return fmt.Errorf("correlation rule %q: %w", r.Name, err)
+			}
+		}
+		seen := map[string]string{}
+		for signal, categories := range r.Signals {
+			if len(categories) == 0 {
+				return fmt.Errorf("correlation rule %q: signal %q lists no categories", r.Name, signal)
+			}
+			for _, c := range categories {
+				if other, dup := seen[c]; dup {
+					return fmt.Errorf("correlation rule %q: category %q is in signals %q and %q", r.Name, c, other, signal)
+				}
+				seen[c] = signal
+			}
+		}
+	}
+	return nil
+}
+
+// Correlate applies rules, in order, to one scan's violations. Each
+// violation joins at most one incident; rest are the violations no
+// incident took, in their input order. Rules are assumed valid.
+func Correlate(violations []analyzer.Violation, rules []config.CorrelationRule) (incidents []Incident, rest []analyzer.Violation) {
+	taken := make([]bool, len(violations))
+	for _, r := range rules {
+		matched := map[string][]int{} // signal -> violation indexes
+		for i, v := range violations {
+			if taken[i] {
+				continue
+			}
+			for signal, categories := range r.Signals {
+				if slices.Contains(categories, v.Category) {
+					matched[signal] = append(matched[signal], i)
+					break
+				}
+			}
+		}
+		need := r.MinSignals
+		if need == 0 {
+			need = len(r.Signals)
+		}
+		if len(matched) < need {
+			continue
+		}
+		inc := Incident{Rule: r.Name, Severity: analyzer.SeverityCritical}
+		if r.Severity != "" {
+			inc.Severity, _ = analyzer.ParseSeverity(r.Severity)
+		}
+		var idx []int
+		for signal, is := range matched {
+			inc.Signals = append(inc.Signals, signal)
+			idx = append(idx, is...)
+		}
+		sort.Strings(inc.Signals)
+		sort.Ints(idx)
+		for _, i := range idx {
+			inc.Violations = append(inc.Violations, violations[i])
+			taken[i] = true
+		}
+		incidents = append(incidents, inc)
+	}
+	for i, v := range violations {
+		if !taken[i] {
+			rest = append(rest, v)
+		}
+	}
+	return incidents, rest
+}
@@ -0,0 +1,92 @@
+package alerting
+
+import (
+	"testing"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrelate_PossibleCompromise(t *testing.T) {
+	violations := []analyzer.Violation{
+		{Category: "user", Severity: analyzer.SeverityHigh, Message: `unexpected user present: eve`},
+		{Category: "firewall", Severity: analyzer.SeverityHigh, Message: "firewall disabled"},
+		{Category: "authorized_keys", Severity: analyzer.SeverityHigh, User: "eve", Message: "user eve has 1 SSH authorized key(s) but is not permitted any"},
+		{Category: "port", Message: "unexpected open port: 4444"},
+	}
+	rules, err := CorrelationRules(config.CorrelationConfig{Enabled: true})
+	require.NoError(t, err)
+	incidents, rest := Correlate(violations, rules)
+
+	require.Len(t, incidents, 1)
+	inc := incidents[0]
+	assert.Equal(t, "possible compromise", inc.Rule)
+	assert.Equal(t, analyzer.SeverityCritical, inc.Severity)
+	assert.Equal(t, []string{"new SSH key", "new account", "new listener"}, inc.Signals)
+	assert.Equal(t, []analyzer.Violation{violations[0], violations[2], violations[3]}, inc.Violations, "input order")
+	assert.Equal(t, violations[1:2], rest)
+
+	v := inc.Violation()
+	assert.Equal(t, IncidentCategory, v.Category)
+	assert.Equal(t, analyzer.SeverityCritical, v.Severity)
+	assert.Equal(t, "possible compromise: 3 related findings (new SSH key, new account, new listener): "+
+		"unexpected user present: eve; user eve has 1 SSH authorized key(s) but is not permitted any; unexpected open port: 4444", v.Message)
+}
+
+func TestCorrelate_BelowThreshold(t *testing.T) {
+	violations := []analyzer.Violation{
+		{Category: "port", Message: "unexpected open port: 8080"},
+		{Category: "port", Message: "unexpected open port: 8081"},
+	}
+	incidents, rest := Correlate(violations, DefaultCorrelationRules)
+	assert.Empty(t, incidents, "two findings of one signal aren't correlated")
+	assert.Equal(t, violations, rest)
+
+	incidents, rest = Correlate(violations, nil)
+	assert.Empty(t, incidents)
+	assert.Equal(t, violations, rest)
+}
+
+func TestCorrelate_CustomRulesAllSignals(t *testing.T) {
+	rules, err := CorrelationRules(config.CorrelationConfig{Enabled: true, Rules: []config.CorrelationRule{{
+		Name:     "unprotected laptop",
+		Severity: "high",
+		Signals: map[string][]string{
+			"disk":     {"disk_encryption"},
+			"firewall": {"firewall"},
+		},
+	}}})
+	require.NoError(t, err)
+	disk := analyzer.Violation{Category: "disk_encryption", Message: "/ not encrypted"}
+	fw := analyzer.Violation{Category: "firewall", Message: "firewall disabled"}
+
+	incidents, _ := Correlate([]analyzer.Violation{disk}, rules)
+	assert.Empty(t, incidents, "min_signals defaults to all")
+	incidents, rest := Correlate([]analyzer.Violation{fw, disk}, rules)
+	require.Len(t, incidents, 1)
+	assert.Equal(t, analyzer.SeverityHigh, incidents[0].Severity)
+	assert.Empty(t, rest)
+}
+
+func TestCorrelationRules_Config(t *testing.T) {
+	rules, err := CorrelationRules(config.CorrelationConfig{Rules: DefaultCorrelationRules})
+	require.NoError(t, err)
+	assert.Nil(t, rules, "disabled")
+
+	for _, tc := range []struct {
+		rule config.CorrelationRule
+		want string
+	}{
+		{config.CorrelationRule{Signals: map[string][]string{"a": {"x"}, "b": {"y"}}}, "name is required"},
+		{config.CorrelationRule{Name: "r", Signals: map[string][]string{"a": {"x"}}}, "at least two signals"},
+		{config.CorrelationRule{Name: "r", MinSignals: 3, Signals: map[string][]string{"a": {"x"}, "b": {"y"}}}, "min_signals 3"},
+		{config.CorrelationRule{Name: "r", Severity: "urgent", Signals: map[string][]string{"a": {"x"}, "b": {"y"}}}, "unknown severity"},
+		{config.CorrelationRule{Name: "r", Signals: map[string][]string{"a": {"x"}, "b": {}}}, `signal "b" lists no categories`},
+		{config.CorrelationRule{Name: "r", Signals: map[string][]string{"a": {"x"}, "b": {"x"}}}, `category "x" is in signals`},
+	} {
+		_, err := CorrelationRules(config.CorrelationConfig{Enabled: true, Rules: []config.CorrelationRule{tc.rule}})
+		assert.ErrorContains(t, err, tc.want)
+	}
+}
@@ -0,0 +1,195 @@
+package alerting
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+)
+
+// Fingerprint identifies a finding on a host across scans; see
+// analyzer.Fingerprint.
+func Fingerprint(hostname string, v analyzer.Violation) string {
+	return analyzer.Fingerprint(hostname, v)
+}
+
+// dedupState records when each alert was last sent, keyed by alerter
+// name and fingerprint. Every wrapped alerter shares one.
+type dedupState struct {
+	mu     sync.Mutex
+	path   string
+	window time.Duration
+	now    func() time.Time
+	sent   map[string]time.Time
+}
+
+func newDedupState(cfg config.DedupConfig) (*dedupState, error) {
+	d := &dedupState{path: cfg.StatePath, window: cfg.Window, now: time.Now, sent: map[string]time.Time{}}
+	if d.path == "" {
+		return d, nil
+	}
+	b, err := os.ReadFile(d.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &d.sent); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// fresh reports whether key hasn't been sent within the window.
+func (d *dedupState) fresh(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	last, ok := d.sent[key]
+	return !ok || d.now().Sub(last) >= d.window
+}
+
+// mark records keys as sent now and saves the state file, dropping
+// entries old enough not to matter. A save failure is logged: the alert
+// itself went out.
+func (d *dedupState) mark(keys ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	for _, k := range keys {
+		d.sent[k] = now
+	}
+	if d.path == "" {
+		return
+	}
+	for k, t := range d.sent {
+		if now.Sub(t) >= d.window {
+			delete(d.sent, k)
+		}
+	}
+	if err := d.save(); err != nil {
+		logger().Warn("alert dedup state not saved", "path", d.path, "err", err)
+	}
+}
+
+// forget drops keys so they alert again at once.
+func (d *dedupState) forget(keys ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, k := range keys {
+		delete(d.sent, k)
+	}
+	if d.path == "" || len(keys) == 0 {
+		return
+	}
+	if err := d.save(); err != nil {
+		logger().Warn("alert dedup state not saved", "path", d.path, "err", err)
+	}
+}
+
+func (d *dedupState) save() error {
+	if err := os.MkdirAll(filepath.Dir(d.path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(d.sent, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(d.path, b, 0o600)
+}
+
+// dedupAlerter suppresses what its alerter already sent within the
+// window: unchanged violations, reports whose violations are all
+// unchanged, and repeat connection tests (a Slack webhook test posts a
+// message).
+type dedupAlerter struct {
+	Alerter
+	state *dedupState
+}
+
+// Test implements Alerter, re-testing once per window after a success.
+func (a *dedupAlerter) Test() error {
+	key := a.Name() + "/test"
+	if !a.state.fresh(key) {
+		return nil
+	}
+	if err := a.Alerter.Test(); err != nil {
+		return err
+	}
+	a.state.mark(key)
+	return nil
+}
+
+// SendTest implements TestSender. Tests always go out.
+func (a *dedupAlerter) SendTest(hostname string) error {
+	return SendTest(a.Alerter, hostname)
+}
+
+// SendReport implements Alerter. A report is keyed by its host and the
+// set of violations in it, so one with a new or resolved violation goes
+// out.
+func (a *dedupAlerter) SendReport(report ComplianceReport) error {
+	fps := make([]string, 0, len(report.Violations))
+	for _, v := range report.Violations {
+		fps = append(fps, Fingerprint(report.Hostname, v))
+	}
+	sort.Strings(fps)
+	sum := sha256.New()
+	for _, fp := range fps {
+		sum.Write([]byte(fp))
+	}
+	key := a.Name() + "/report/" + report.Hostname + "/" + hex.EncodeToString(sum.Sum(nil)[:16])
+	if !a.state.fresh(key) {
+		return nil
+	}
+	if err := a.Alerter.SendReport(report); err != nil {
+		return err
+	}
+	a.state.mark(key)
+	return nil
+}
+
+// SendViolations implements Alerter, passing on only the violations not
+// sent within the window.
+func (a *dedupAlerter) SendViolations(hostname string, violations []analyzer.Violation) error {
+	var vs []analyzer.Violation
+	var keys []string
+	for _, v := range violations {
+		key := a.Name() + "/" + Fingerprint(hostname, v)
+		if a.state.fresh(key) {
+			vs = append(vs, v)
+			keys = append(keys, key)
+		}
+	}
+	if len(vs) == 0 {
+		return nil
+	}
+	if err := a.Alerter.SendViolations(hostname, vs); err != nil {
+		return err
+	}
+	a.state.mark(keys...)
+	return nil
+}
+
+// SendResolved implements Resolver. Resolved violations are forgotten,
+// so one that comes back alerts straight away; the wrapped alerter is
+// told if it is a Resolver.
+func (a *dedupAlerter) SendResolved(hostname string, resolved []analyzer.Violation) error {
+	keys := make([]string, 0, len(resolved))
+	for _, v := range resolved {
+		keys = append(keys, a.Name()+"/"+Fingerprint(hostname, v))
+	}
+	a.state.forget(keys...)
+	if r, ok := a.Alerter.(Resolver); ok {
+		return r.SendResolved(hostname, resolved)
+	}
+	return nil
+}
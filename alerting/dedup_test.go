@@ -0,0 +1,87 @@
+package alerting
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingAlerter counts what reaches the real backend.
+type recordingAlerter struct {
+	tests      int
+	reports    int
+	violations [][]analyzer.Violation
+}
+
+func (r *recordingAlerter) Name() string                      { return "rec" }
+func (r *recordingAlerter) Test() error                       { r.tests++; return nil }
+func (r *recordingAlerter) SendReport(ComplianceReport) error { r.reports++; return nil }
+func (r *recordingAlerter) SendViolations(_ string, vs []analyzer.Violation) error {
+	r.violations = append(r.violations, vs)
+	return nil
+}
+
+func TestDedup_SuppressesWithinWindow(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "dedup.json")
+	now := time.Date(2026, 4, 1, 12, 0, 0, 0, time.UTC)
+	newWrapped := func(rec *recordingAlerter) *dedupAlerter {
+		st, err := newDedupState(config.DedupConfig{Window: time.Hour, StatePath: state})
+		require.NoError(t, err)
+		st.now = func() time.Time { return now }
+		return &dedupAlerter{Alerter: rec, state: st}
+	}
+
+	eve := analyzer.Violation{Category: "user", Severity: analyzer.SeverityCritical, Message: "unexpected user present: eve"}
+	telnet := analyzer.Violation{Category: "port", Severity: analyzer.SeverityHigh, Message: "port 23 open"}
+
+	rec := &recordingAlerter{}
+	a := newWrapped(rec)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, a.Test())
+		require.NoError(t, a.SendReport(ComplianceReport{Hostname: "web-1", Violations: []analyzer.Violation{eve}}))
+		require.NoError(t, a.SendViolations("web-1", []analyzer.Violation{eve}))
+	}
+	assert.Equal(t, 1, rec.tests)
+	assert.Equal(t, 1, rec.reports)
+	assert.Len(t, rec.violations, 1)
+
+	// State survives a restart; only the new finding goes out, and the
+	// changed report does too.
+	rec = &recordingAlerter{}
+	a = newWrapped(rec)
+	require.NoError(t, a.SendReport(ComplianceReport{Hostname: "web-1", Violations: []analyzer.Violation{eve, telnet}}))
+	require.NoError(t, a.SendViolations("web-1", []analyzer.Violation{eve, telnet}))
+	assert.Equal(t, 1, rec.reports)
+	assert.Equal(t, [][]analyzer.Violation{{telnet}}, rec.violations)
+
+	// Another host with the same finding is its own alert.
+	require.NoError(t, a.SendViolations("web-2", []analyzer.Violation{eve}))
+	assert.Len(t, rec.violations, 2)
+
+	// Once the window passes, unchanged findings alert again.
+	now = now.Add(time.Hour)
+	require.NoError(t, a.SendViolations("web-1", []analyzer.Violation{eve, telnet}))
+	assert.Equal(t, []analyzer.Violation{eve, telnet}, rec.violations[2])
+}
+
+func TestDedup_Build(t *testing.T) {
+	alerters, err := Build(config.AlertConfig{
+		Enabled: []string{"pagerduty"},
+		Dedup:   config.DedupConfig{Window: time.Hour},
+	})
+	require.NoError(t, err)
+	assert.IsType(t, &dedupAlerter{}, alerters[0])
+	assert.Equal(t, "pagerduty", alerters[0].Name())
+
+	alerters, err = Build(config.AlertConfig{Enabled: []string{"pagerduty"}})
+	require.NoError(t, err)
+	assert.IsType(t, &PagerDutyClient{}, alerters[0])
+
+	assert.NotEqual(t, Fingerprint("web-1", analyzer.Violation{Category: "port", Message: "port 23"}),
+		Fingerprint("web-2", analyzer.Violation{Category: "port", Message: "port 23"}))
+}
@@ -0,0 +1,135 @@
+package alerting
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"compliance-agent/analyzer"
+)
+
+// Resolver is implemented by alerters that can announce violations that
+// have disappeared since the previous run, in delta alerting mode.
+// Alerters without it are only told about new violations.
+type Resolver interface {
+	SendResolved(hostname string, resolved []analyzer.Violation) error
+}
+
+// Delta compares a host's violations with the previous run's by
+// Fingerprint. Added are in cur but not prev; resolved are in prev but
+// not cur. Both keep their input order.
+func Delta(hostname string, prev, cur []analyzer.Violation) (added, resolved []analyzer.Violation) {
+	before := make(map[string]bool, len(prev))
+	for _, v := range prev {
+		before[Fingerprint(hostname, v)] = true
+	}
+	now := make(map[string]bool, len(cur))
+	for _, v := range cur {
+		fp := Fingerprint(hostname, v)
+		now[fp] = true
+		if !before[fp] {
+			added = append(added, v)
+		}
+	}
+	for _, v := range prev {
+		if fp := Fingerprint(hostname, v); !now[fp] {
+			resolved = append(resolved, v)
+			now[fp] = true // once, even if prev repeats it
+		}
+	}
+	return added, resolved
+}
+
+// DeltaState is, for each alerter and host, the violations the alerter
+// has been told about in delta mode. Each alerter's baseline only moves
+// past a violation once the alerter has acknowledged it, so one whose
+// send fails is offered again next run instead of being lost. Path keeps
+// the state across restarts; without it a daemon remembers in memory.
+type DeltaState struct {
+	mu    sync.Mutex
+	path  string
+	known map[string]map[string][]analyzer.Violation
+}
+
+// NewDeltaState loads the state saved at path, if there is one.
+func NewDeltaState(path string) (*DeltaState, error) {
+	d := &DeltaState{path: path, known: map[string]map[string][]analyzer.Violation{}}
+	if path == "" {
+		return d, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &d.known); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Baseline returns the violations alerter has been told about on
+// hostname. It reports false when there are none recorded yet, so the
+// caller can fall back to the previous report.
+func (d *DeltaState) Baseline(alerter, hostname string) ([]analyzer.Violation, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	vs, ok := d.known[alerter][hostname]
+	return vs, ok
+}
+
+// Advance records what alerter now knows about hostname after a run with
+// violations cur. Added violations it wasn't sent (addedOK false) stay
+// out of the baseline so they are added again next run; resolved ones
+// it wasn't told about (resolvedOK false) stay in it so they resolve
+// again. A save failure is logged: delivery itself is done.
+func (d *DeltaState) Advance(alerter, hostname string, cur, added, resolved []analyzer.Violation, addedOK, resolvedOK bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.known[alerter] == nil {
+		d.known[alerter] = map[string][]analyzer.Violation{}
+	}
+	d.known[alerter][hostname] = nextBaseline(hostname, cur, added, resolved, addedOK, resolvedOK)
+	if d.path == "" {
+		return
+	}
+	if err := d.save(); err != nil {
+		logger().Warn("delta alert state not saved", "path", d.path, "err", err)
+	}
+}
+
+func (d *DeltaState) save() error {
+	if err := os.MkdirAll(filepath.Dir(d.path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(d.known, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(d.path, b, 0o600)
+}
+
+// nextBaseline is cur without the added violations that weren't
+// delivered, plus the resolved ones that weren't.
+func nextBaseline(hostname string, cur, added, resolved []analyzer.Violation, addedOK, resolvedOK bool) []analyzer.Violation {
+	next := make([]analyzer.Violation, 0, len(cur)+len(resolved))
+	undelivered := map[string]bool{}
+	if !addedOK {
+		for _, v := range added {
+			undelivered[Fingerprint(hostname, v)] = true
+		}
+	}
+	for _, v := range cur {
+		if !undelivered[Fingerprint(hostname, v)] {
+			next = append(next, v)
+		}
+	}
+	if !resolvedOK {
+		next = append(next, resolved...)
+	}
+	return next
+}
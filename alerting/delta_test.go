@@ -0,0 +1,108 @@
+package alerting
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelta(t *testing.T) {
+	eve := analyzer.Violation{Category: "user", Severity: analyzer.SeverityCritical, Message: "unexpected user present: eve"}
+	telnet := analyzer.Violation{Category: "port", Severity: analyzer.SeverityHigh, Message: "port 23 open"}
+	ftp := analyzer.Violation{Category: "port", Severity: analyzer.SeverityHigh, Message: "port 21 open"}
+
+	added, resolved := Delta("web-1", []analyzer.Violation{eve, telnet, telnet}, []analyzer.Violation{telnet, ftp})
+	assert.Equal(t, []analyzer.Violation{ftp}, added)
+	assert.Equal(t, []analyzer.Violation{eve}, resolved)
+
+	reRated := telnet
+	reRated.Severity = analyzer.SeverityCritical
+	added, resolved = Delta("web-1", []analyzer.Violation{telnet}, []analyzer.Violation{reRated})
+	assert.Empty(t, added, "a severity change isn't a new finding")
+	assert.Empty(t, resolved)
+}
+
+func TestDelta_ResolvedThroughDedup(t *testing.T) {
+	var events []WebhookEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev WebhookEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+		events = append(events, ev)
+	}))
+	defer srv.Close()
+
+	t.Setenv("WEBHOOK_SECRET", "")
+	alerters, err := Build(config.AlertConfig{
+		Enabled: []string{"webhook"},
+		Webhook: config.WebhookAlertConfig{URL: srv.URL, AllowHTTP: true, Events: []string{"violations", "resolved"}},
+		Dedup:   config.DedupConfig{Window: time.Hour},
+	})
+	require.NoError(t, err)
+	a := alerters[0]
+	r, ok := a.(Resolver)
+	require.True(t, ok)
+
+	eve := []analyzer.Violation{{Category: "user", Severity: analyzer.SeverityCritical, Message: "unexpected user present: eve"}}
+	require.NoError(t, a.SendViolations("web-1", eve))
+	require.NoError(t, r.SendResolved("web-1", eve))
+	// Resolved findings are forgotten by dedup, so a recurrence alerts.
+	require.NoError(t, a.SendViolations("web-1", eve))
+
+	require.Len(t, events, 3)
+	assert.Equal(t, []string{"violations", "resolved", "violations"}, []string{events[0].Event, events[1].Event, events[2].Event})
+	assert.Equal(t, eve[0].Message, events[1].Violations[0].Message)
+}
+
+func TestNextBaseline(t *testing.T) {
+	eve := analyzer.Violation{Category: "user", Message: "unexpected user present: eve"}
+	telnet := analyzer.Violation{Category: "port", Message: "port 23 open"}
+	ftp := analyzer.Violation{Category: "port", Message: "port 21 open"}
+	cur := []analyzer.Violation{telnet, ftp}
+	added, resolved := []analyzer.Violation{ftp}, []analyzer.Violation{eve}
+
+	assert.Equal(t, cur, nextBaseline("web-1", cur, added, resolved, true, true))
+	assert.Equal(t, []analyzer.Violation{telnet}, nextBaseline("web-1", cur, added, resolved, false, true),
+		"an undelivered violation stays new")
+	assert.Equal(t, []analyzer.Violation{telnet, ftp, eve}, nextBaseline("web-1", cur, added, resolved, true, false),
+		"an undelivered resolution stays pending")
+}
+
+func TestDeltaState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "delta.json")
+	telnet := analyzer.Violation{Category: "port", Message: "port 23 open"}
+	ftp := analyzer.Violation{Category: "port", Message: "port 21 open"}
+
+	d, err := NewDeltaState(path)
+	require.NoError(t, err)
+	_, ok := d.Baseline("slack", "web-1")
+	assert.False(t, ok)
+
+	// Slack's send failed, so ftp is still new to it after a restart.
+	cur := []analyzer.Violation{telnet, ftp}
+	d.Advance("slack", "web-1", cur, []analyzer.Violation{ftp}, nil, false, true)
+	d.Advance("syslog", "web-1", cur, []analyzer.Violation{ftp}, nil, true, true)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	d, err = NewDeltaState(path)
+	require.NoError(t, err)
+	base, ok := d.Baseline("slack", "web-1")
+	require.True(t, ok)
+	added, _ := Delta("web-1", base, cur)
+	assert.Equal(t, []analyzer.Violation{ftp}, added)
+	base, ok = d.Baseline("syslog", "web-1")
+	require.True(t, ok)
+	added, _ = Delta("web-1", base, cur)
+	assert.Empty(t, added)
+}
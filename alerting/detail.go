@@ -0,0 +1,110 @@
+package alerting
+
+import (
+	"fmt"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+)
+
+// Detail levels, from least to most said.
+const (
+	DetailSummary    = "summary"
+	DetailViolations = "violations"
+	DetailFull       = "full"
+)
+
+// detailAlerter limits what its alerter is told, per its destination's
+// detail config, and names it after the destination.
+type detailAlerter struct {
+	Alerter
+	name  string
+	level string
+	min   analyzer.Severity // "" for every severity
+}
+
+func newDetailAlerter(a Alerter, name string, cfg config.DetailConfig) (*detailAlerter, error) {
+	d := &detailAlerter{Alerter: a, name: name, level: cfg.Level}
+	switch d.level {
+	case "":
+		d.level = DetailFull
+	case DetailSummary, DetailViolations, DetailFull:
+	default:
+		return nil, fmt.Errorf("detail level %q (want summary, violations or full)", cfg.Level)
+	}
+	if cfg.MinSeverity != "" {
+		sev, err := analyzer.ParseSeverity(cfg.MinSeverity)
+		if err != nil {
+			return nil, fmt.Errorf("detail min_severity: %w", err)
+		}
+		d.min = sev
+	}
+	return d, nil
+}
+
+// Name implements Alerter with the destination's name.
+func (d *detailAlerter) Name() string { return d.name }
+
+// filter keeps the violations at or above the minimum severity.
+func (d *detailAlerter) filter(violations []analyzer.Violation) []analyzer.Violation {
+	if d.min == "" {
+		return violations
+	}
+	var out []analyzer.Violation
+	for _, v := range violations {
+		if severityOf(v).Rank() >= d.min.Rank() {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// SendReport implements Alerter. A summary keeps each violation's
+// category, severity and control, enough to count them, and drops the
+// message, user and metadata that would identify what was found. The
+// users, processes, ports and packages are replaced by their counts.
+func (d *detailAlerter) SendReport(report ComplianceReport) error {
+	report.Violations = d.filter(report.Violations)
+	if d.level == DetailSummary {
+		counted := make([]analyzer.Violation, len(report.Violations))
+		for i, v := range report.Violations {
+			counted[i] = analyzer.Violation{Category: v.Category, Severity: severityOf(v), Control: v.Control, Risk: v.Risk}
+		}
+		report.Violations = counted
+		report.ExtraMetadata = nil
+		counts := report.inventory()
+		report.Counts = &counts
+		report.Users, report.Processes, report.OpenPorts, report.Packages = nil, nil, nil, nil
+	}
+	return d.Alerter.SendReport(report)
+}
+
+// SendViolations implements Alerter. Summaries get no per-violation
+// alerts.
+func (d *detailAlerter) SendViolations(hostname string, violations []analyzer.Violation) error {
+	if d.level == DetailSummary {
+		return nil
+	}
+	if violations = d.filter(violations); len(violations) == 0 {
+		return nil
+	}
+	return d.Alerter.SendViolations(hostname, violations)
+}
+
+// SendResolved implements Resolver, passing on what SendViolations
+// would have.
+func (d *detailAlerter) SendResolved(hostname string, resolved []analyzer.Violation) error {
+	r, ok := d.Alerter.(Resolver)
+	if !ok || d.level == DetailSummary {
+		return nil
+	}
+	if resolved = d.filter(resolved); len(resolved) == 0 {
+		return nil
+	}
+	return r.SendResolved(hostname, resolved)
+}
+
+// SendTest implements TestSender.
+func (d *detailAlerter) SendTest(hostname string) error {
+	return SendTest(d.Alerter, hostname)
+}
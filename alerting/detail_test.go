@@ -0,0 +1,138 @@
+package alerting
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/collector"
+	"compliance-agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookReceiver collects the events posted to it.
+func webhookReceiver(t *testing.T) (*[]WebhookEvent, string) {
+	var events []WebhookEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev WebhookEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+		events = append(events, ev)
+	}))
+	t.Cleanup(srv.Close)
+	return &events, srv.URL
+}
+
+func TestBuild_DestinationsWithDetail(t *testing.T) {
+	public, publicURL := webhookReceiver(t)
+	security, securityURL := webhookReceiver(t)
+	alerters, err := Build(config.AlertConfig{
+		Enabled: []string{"webhook", "security"},
+		Webhook: config.WebhookAlertConfig{URL: publicURL, AllowHTTP: true},
+		Destinations: []config.DestinationConfig{{
+			Name:    "security",
+			Type:    "webhook",
+			Webhook: config.WebhookAlertConfig{URL: securityURL, AllowHTTP: true},
+		}},
+		Detail: map[string]config.DetailConfig{
+			"webhook":  {Level: "summary"},
+			"security": {Level: "violations", MinSeverity: "high"},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, alerters, 2)
+	assert.Equal(t, "webhook", alerters[0].Name())
+	assert.Equal(t, "security", alerters[1].Name())
+
+	violations := []analyzer.Violation{
+		{Category: "user", Severity: analyzer.SeverityCritical, User: "eve", Message: `user "eve" present`},
+		{Category: "cis", Control: "5.2.7", Message: "sshd: PermitRootLogin yes"},
+		{Category: "port", Severity: analyzer.SeverityLow, Message: "port 8080"},
+	}
+	rep := ComplianceReport{Hostname: "web-1", Violations: violations, ExtraMetadata: map[string]any{"ueba_score": 0.9}}
+	for _, a := range alerters {
+		require.NoError(t, a.SendReport(rep))
+		require.NoError(t, a.SendViolations("web-1", violations))
+		require.NoError(t, a.(Resolver).SendResolved("web-1", violations))
+	}
+
+	require.Len(t, *public, 1, "summaries get the report only")
+	assert.Equal(t, []analyzer.Violation{
+		{Category: "user", Severity: analyzer.SeverityCritical},
+		{Category: "cis", Severity: analyzer.SeverityMedium, Control: "5.2.7"},
+		{Category: "port", Severity: analyzer.SeverityLow},
+	}, (*public)[0].Violations)
+	assert.Nil(t, (*public)[0].Report.ExtraMetadata)
+
+	require.Len(t, *security, 3)
+	assert.Equal(t, []string{"report", "violations", "resolved"}, []string{(*security)[0].Event, (*security)[1].Event, (*security)[2].Event})
+	for _, ev := range *security {
+		assert.Equal(t, violations[:1], ev.Violations, "only high and above, with evidence")
+	}
+	assert.Equal(t, 0.9, (*security)[0].Report.ExtraMetadata["ueba_score"])
+}
+
+func TestDetail_SummaryLeavesOutInventory(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		body = string(b)
+	}))
+	defer srv.Close()
+	alerters, err := Build(config.AlertConfig{
+		Enabled: []string{"webhook"},
+		Webhook: config.WebhookAlertConfig{URL: srv.URL, AllowHTTP: true},
+		Detail:  map[string]config.DetailConfig{"webhook": {Level: "summary"}},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, alerters[0].SendReport(ComplianceReport{
+		Hostname:  "web-1",
+		Users:     []collector.User{{Username: "eve", Shell: "/bin/bash"}},
+		Processes: []collector.Process{{PID: 42, Name: "backup", Cmdline: "backup --password hunter2"}},
+		OpenPorts: []int{22, 8080},
+		Packages:  []collector.Package{{Name: "openssl", Version: "1.1.1"}},
+		Violations: []analyzer.Violation{
+			{Category: "user", Severity: analyzer.SeverityCritical, User: "eve", Message: `user "eve" present`},
+		},
+	}))
+	for _, leak := range []string{`"eve"`, "backup", "hunter2", "openssl", "8080"} {
+		assert.NotContains(t, body, leak)
+	}
+	var ev WebhookEvent
+	require.NoError(t, json.Unmarshal([]byte(body), &ev))
+	assert.Equal(t, &InventoryCounts{Users: 1, Processes: 1, OpenPorts: 2, Packages: 1}, ev.Report.Counts)
+	assert.Empty(t, ev.Report.Users)
+	assert.Empty(t, ev.Report.Processes)
+	assert.Empty(t, ev.Report.Packages)
+	assert.Empty(t, ev.Report.OpenPorts)
+}
+
+func TestBuild_DestinationErrors(t *testing.T) {
+	_, err := Build(config.AlertConfig{
+		Enabled:      []string{"ops"},
+		Destinations: []config.DestinationConfig{{Name: "ops", Type: "fax"}},
+	})
+	assert.ErrorContains(t, err, `destination ops: unknown type "fax"`)
+
+	_, err = Build(config.AlertConfig{
+		Destinations: []config.DestinationConfig{{Name: "slack", Type: "webhook"}},
+	})
+	assert.ErrorContains(t, err, `destination name "slack"`)
+
+	_, err = Build(config.AlertConfig{
+		Enabled: []string{"webhook"},
+		Detail:  map[string]config.DetailConfig{"webhook": {Level: "verbose"}},
+	})
+	assert.ErrorContains(t, err, `detail level "verbose"`)
+
+	_, err = Build(config.AlertConfig{
+		Enabled: []string{"webhook"},
+		Detail:  map[string]config.DetailConfig{"webhook": {MinSeverity: "urgent"}},
+	})
+	assert.ErrorContains(t, err, "min_severity")
+}
@@ -0,0 +1,160 @@
+package alerting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+)
+
+// Default link templates, to the fleet server's API.
+const (
+	DefaultHostLink    = "{{.Server}}/api/v1/hosts/{{.AgentID}}"
+	DefaultFindingLink = "{{.Server}}/api/v1/hosts/{{.AgentID}}/findings/{{.Fingerprint}}"
+)
+
+// Links renders the links alerts carry back to the fleet server. A nil
+// *Links renders none.
+type Links struct {
+	server    string
+	host      *template.Template
+	finding   *template.Template
+	ack       *template.Template
+	credsPath string
+
+	// mu guards agentID, read from credsPath once the agent has
+	// enrolled.
+	mu      sync.Mutex
+	agentID string
+}
+
+// FindingLinks are the links for one violation.
+type FindingLinks struct {
+	Fingerprint string `json:"fingerprint"`
+	Finding     string `json:"finding,omitempty"`
+	Ack         string `json:"ack,omitempty"`
+}
+
+// NewLinks parses cfg's templates. It returns nil when there is neither
+// a base URL nor a template to render.
+func NewLinks(cfg config.LinksConfig) (*Links, error) {
+	if cfg.BaseURL == "" && cfg.Host == "" && cfg.Finding == "" && cfg.Ack == "" {
+		return nil, nil
+	}
+	l := &Links{server: strings.TrimSuffix(cfg.BaseURL, "/"), credsPath: cfg.CredentialsPath}
+	for _, t := range []struct {
+		name, src, def string
+		dst            **template.Template
+	}{
+		{"host", cfg.Host, DefaultHostLink, &l.host},
+		{"finding", cfg.Finding, DefaultFindingLink, &l.finding},
+		{"ack", cfg.Ack, "", &l.ack},
+	} {
+		src := t.src
+		if src == "" && l.server != "" {
+			src = t.def
+		}
+		if src == "" {
+			continue
+		}
+		tmpl, err := template.New(t.name).Funcs(template.FuncMap{"query": url.QueryEscape}).Option("missingkey=error").Parse(src)
+		if err != nil {
+			return nil, fmt.Errorf("links.%s: %w", t.name, err)
+		}
+		*t.dst = tmpl
+	}
+	return l, nil
+}
+
+// Host is the link to hostname's page, or "".
+func (l *Links) Host(hostname string) string {
+	if l == nil {
+		return ""
+	}
+	return l.render(l.host, l.data(hostname))
+}
+
+// Finding returns the links for v on hostname.
+func (l *Links) Finding(hostname string, v analyzer.Violation) FindingLinks {
+	out := FindingLinks{Fingerprint: Fingerprint(hostname, v)}
+	if l == nil {
+		return out
+	}
+	data := l.data(hostname)
+	data["Fingerprint"] = out.Fingerprint
+	data["Category"] = v.Category
+	data["Control"] = v.Control
+	data["Severity"] = string(severityOf(v))
+	out.Finding = l.render(l.finding, data)
+	out.Ack = l.render(l.ack, data)
+	return out
+}
+
+// Findings returns the links for each of violations, in order, or nil
+// when there are no links to render.
+func (l *Links) Findings(hostname string, violations []analyzer.Violation) []FindingLinks {
+	if l == nil || (l.finding == nil && l.ack == nil) {
+		return nil
+	}
+	out := make([]FindingLinks, len(violations))
+	for i, v := range violations {
+		out[i] = l.Finding(hostname, v)
+	}
+	return out
+}
+
+// data is the template data every link shares. Empty values are left
+// out, so a template using one fails and its link is dropped.
+func (l *Links) data(hostname string) map[string]string {
+	data := map[string]string{}
+	for k, v := range map[string]string{"Server": l.server, "AgentID": l.agent(), "Hostname": hostname} {
+		if v != "" {
+			data[k] = v
+		}
+	}
+	return data
+}
+
+func (l *Links) render(t *template.Template, data map[string]string) string {
+	if t == nil {
+		return ""
+	}
+	for k, v := range data {
+		if v == "" {
+			delete(data, k)
+		}
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// agent returns the agent's fleet server ID from its credentials, once
+// it has enrolled.
+func (l *Links) agent() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.agentID != "" || l.credsPath == "" {
+		return l.agentID
+	}
+	b, err := os.ReadFile(l.credsPath)
+	if err != nil {
+		return ""
+	}
+	var creds struct {
+		AgentID string `json:"agent_id"`
+	}
+	if json.Unmarshal(b, &creds) == nil {
+		l.agentID = creds.AgentID
+	}
+	return l.agentID
+}
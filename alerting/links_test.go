@@ -0,0 +1,153 @@
+package alerting
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var linkViolation = analyzer.Violation{Category: "port", Severity: analyzer.SeverityHigh, Message: "unexpected open port: 23"}
+
+func writeCreds(t *testing.T, agentID string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "agent_credentials.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"agent_id":"`+agentID+`","token":"t"}`), 0o600))
+	return path
+}
+
+func TestLinks(t *testing.T) {
+	creds := filepath.Join(t.TempDir(), "agent_credentials.json")
+	l, err := NewLinks(config.LinksConfig{BaseURL: "https://fleet.example.com/", CredentialsPath: creds})
+	require.NoError(t, err)
+	fp := Fingerprint("web-1", linkViolation)
+
+	assert.Empty(t, l.Host("web-1"), "not enrolled yet: no agent ID to link to")
+	assert.Equal(t, FindingLinks{Fingerprint: fp}, l.Finding("web-1", linkViolation))
+
+	require.NoError(t, os.WriteFile(creds, []byte(`{"agent_id":"a1b2"}`), 0o600))
+	assert.Equal(t, "https://fleet.example.com/api/v1/hosts/a1b2", l.Host("web-1"))
+	assert.Equal(t, FindingLinks{
+		Fingerprint: fp,
+		Finding:     "https://fleet.example.com/api/v1/hosts/a1b2/findings/" + fp,
+	}, l.Finding("web-1", linkViolation))
+
+	l, err = NewLinks(config.LinksConfig{
+		Host: "https://grafana.example.com/d/compliance?var-host={{query .Hostname}}",
+		Ack:  "https://soar.example.com/ack?fp={{.Fingerprint}}&control={{.Control}}",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://grafana.example.com/d/compliance?var-host=web+1", l.Host("web 1"))
+	got := l.Finding("web-1", linkViolation)
+	assert.Empty(t, got.Finding, "no base URL, so no default")
+	assert.Empty(t, got.Ack, "the violation has no control")
+	got = l.Finding("web-1", analyzer.Violation{Category: "cis", Control: "5.2.7", Message: "x"})
+	assert.Contains(t, got.Ack, "&control=5.2.7")
+
+	l, err = NewLinks(config.LinksConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, l)
+	assert.Empty(t, l.Host("web-1"))
+	assert.Nil(t, l.Findings("web-1", []analyzer.Violation{linkViolation}))
+
+	_, err = NewLinks(config.LinksConfig{Ack: "{{.Fingerprint"})
+	assert.ErrorContains(t, err, "links.ack")
+}
+
+func TestLinks_Slack(t *testing.T) {
+	api := &fakeSlackAPI{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	alerters, err := Build(config.AlertConfig{
+		Enabled: []string{"slack"},
+		Slack:   config.SlackAlertConfig{BotToken: "xoxb-test", APIURL: srv.URL},
+		Links:   config.LinksConfig{BaseURL: "https://fleet.example.com", Ack: "https://soar.example.com/ack/{{.Fingerprint}}", CredentialsPath: writeCreds(t, "a1b2")},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, alerters[0].SendReport(ComplianceReport{Hostname: "web-1", Violations: []analyzer.Violation{linkViolation}}))
+	att := api.posts[0].Attachments[0]
+	require.Len(t, att.Actions, 1)
+	assert.Equal(t, "https://fleet.example.com/api/v1/hosts/a1b2", att.Actions[0].URL)
+
+	require.NoError(t, alerters[0].SendViolations("web-1", []analyzer.Violation{linkViolation}))
+	fp := Fingerprint("web-1", linkViolation)
+	att = api.posts[1].Attachments[0]
+	assert.Equal(t, "https://fleet.example.com/api/v1/hosts/a1b2", att.TitleLink)
+	assert.Contains(t, att.Fields[0].Value, "unexpected open port: 23 · <https://fleet.example.com/api/v1/hosts/a1b2/findings/"+fp+
+		"|finding> · <https://soar.example.com/ack/"+fp+"|acknowledge>")
+
+	// Without a fleet server there is nothing to link to.
+	plain, err := Build(config.AlertConfig{
+		Enabled: []string{"slack"},
+		Slack:   config.SlackAlertConfig{BotToken: "xoxb-test", APIURL: srv.URL},
+	})
+	require.NoError(t, err)
+	require.NoError(t, plain[0].SendReport(ComplianceReport{Hostname: "web-2"}))
+	assert.Empty(t, api.posts[2].Attachments[0].Actions)
+}
+
+func TestLinks_PagerDutyAndWebhook(t *testing.T) {
+	var pd pagerDutyEvent
+	var hook WebhookEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/pd" {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&pd))
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&hook))
+	}))
+	defer srv.Close()
+	t.Setenv("WEBHOOK_SECRET", "")
+	alerters, err := Build(config.AlertConfig{
+		Enabled:   []string{"pagerduty", "webhook"},
+		PagerDuty: config.PagerDutyAlertConfig{RoutingKey: "key123", EventsURL: srv.URL + "/pd", MinSeverity: "high"},
+		Webhook:   config.WebhookAlertConfig{URL: srv.URL + "/hook", AllowHTTP: true},
+		Links:     config.LinksConfig{BaseURL: "https://fleet.example.com", CredentialsPath: writeCreds(t, "a1b2")},
+	})
+	require.NoError(t, err)
+	fp := Fingerprint("web-1", linkViolation)
+	for _, a := range alerters {
+		require.NoError(t, a.SendViolations("web-1", []analyzer.Violation{linkViolation}))
+	}
+
+	assert.Equal(t, []pagerDutyLink{
+		{Href: "https://fleet.example.com/api/v1/hosts/a1b2", Text: "Host web-1"},
+		{Href: "https://fleet.example.com/api/v1/hosts/a1b2/findings/" + fp, Text: "unexpected open port: 23"},
+	}, pd.Links)
+
+	require.NotNil(t, hook.Links)
+	assert.Equal(t, "https://fleet.example.com/api/v1/hosts/a1b2", hook.Links.Host)
+	assert.Equal(t, []FindingLinks{{Fingerprint: fp, Finding: "https://fleet.example.com/api/v1/hosts/a1b2/findings/" + fp}}, hook.Links.Findings)
+}
+
+func TestLinks_Syslog(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+	alerters, err := Build(config.AlertConfig{
+		Enabled: []string{"syslog"},
+		Syslog:  config.SyslogAlertConfig{Network: "udp", Address: pc.LocalAddr().String()},
+		Links:   config.LinksConfig{Ack: "https://soar.example.com/ack/{{.Fingerprint}}"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, alerters[0].SendViolations("web-1", []analyzer.Violation{linkViolation}))
+
+	buf := make([]byte, 4096)
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	require.NoError(t, err)
+	msg := string(buf[:n])
+	assert.Contains(t, msg, `ack_url="https://soar.example.com/ack/`+Fingerprint("web-1", linkViolation)+`"`)
+	assert.NotContains(t, msg, "finding_url", "no base URL")
+}
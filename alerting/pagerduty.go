@@ -0,0 +1,274 @@
+package alerting
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+)
+
+// DefaultPagerDutyEventsURL is the Events API v2 enqueue endpoint.
+const DefaultPagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+func init() {
+	Register("pagerduty", func(cfg config.AlertConfig) (Alerter, error) {
+		c, err := NewPagerDutyClient(cfg.PagerDuty)
+		if err != nil {
+			return nil, err
+		}
+		if c.links, err = NewLinks(cfg.Links); err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+}
+
+// PagerDutyClient triggers PagerDuty incidents for severe violations. It
+// only pages: scan summaries go to the other alerters.
+type PagerDutyClient struct {
+	routingKey  string
+	eventsURL   string
+	minSeverity analyzer.Severity
+	client      *http.Client
+	links       *Links
+}
+
+// NewPagerDutyClient builds a client from config, falling back to the
+// PAGERDUTY_ROUTING_KEY environment variable.
+func NewPagerDutyClient(cfg config.PagerDutyAlertConfig) (*PagerDutyClient, error) {
+	c := &PagerDutyClient{
+		routingKey:  cfg.RoutingKey,
+		eventsURL:   cfg.EventsURL,
+		minSeverity: analyzer.SeverityCritical,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+	if c.routingKey == "" {
+		c.routingKey = os.Getenv("PAGERDUTY_ROUTING_KEY")
+	}
+	if c.eventsURL == "" {
+		c.eventsURL = DefaultPagerDutyEventsURL
+	}
+	if cfg.MinSeverity != "" {
+		sev, err := analyzer.ParseSeverity(cfg.MinSeverity)
+		if err != nil {
+			return nil, fmt.Errorf("min_severity: %w", err)
+		}
+		c.minSeverity = sev
+	}
+	return c, nil
+}
+
+// Name implements Alerter.
+func (p *PagerDutyClient) Name() string { return "pagerduty" }
+
+// SendReport implements Alerter. Summaries don't page anyone.
+func (p *PagerDutyClient) SendReport(ComplianceReport) error { return nil }
+
+// Test implements Alerter. The Events API has no dry-run endpoint and a
+// test event would page someone, so this only checks configuration;
+// SendTest sends a change event instead.
+func (p *PagerDutyClient) Test() error {
+	if p.routingKey == "" {
+		return errors.New("PAGERDUTY_ROUTING_KEY not configured")
+	}
+	return nil
+}
+
+// SendTest implements TestSender with a change event, which shows on
+// the service's timeline without opening an incident or paging anyone.
+func (p *PagerDutyClient) SendTest(hostname string) error {
+	if err := p.Test(); err != nil {
+		return err
+	}
+	body, err := json.Marshal(pagerDutyChangeEvent{
+		RoutingKey: p.routingKey,
+		Payload: pagerDutyChangePayload{
+			Summary:   "compliance-agent test event from " + hostname,
+			Source:    hostname,
+			Timestamp: time.Now().UTC(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return p.post(changeEventsURL(p.eventsURL), body)
+}
+
+// changeEventsURL is the change events endpoint next to eventsURL
+// (/v2/enqueue becomes /v2/change/enqueue).
+func changeEventsURL(eventsURL string) string {
+	if i := strings.LastIndex(eventsURL, "/enqueue"); i >= 0 {
+		return eventsURL[:i] + "/change/enqueue" + eventsURL[i+len("/enqueue"):]
+	}
+	return strings.TrimSuffix(eventsURL, "/") + "/change/enqueue"
+}
+
+// pagerDutyChangeEvent is an Events API v2 change event body.
+type pagerDutyChangeEvent struct {
+	RoutingKey string                 `json:"routing_key"`
+	Payload    pagerDutyChangePayload `json:"payload"`
+}
+
+type pagerDutyChangePayload struct {
+	Summary   string    `json:"summary"`
+	Source    string    `json:"source"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// pagerDutyEvent is an Events API v2 request body.
+type pagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key"`
+	Payload     pagerDutyPayload `json:"payload"`
+	Links       []pagerDutyLink  `json:"links,omitempty"`
+}
+
+// pagerDutyLink is a link shown on the incident.
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+// pagerDutyMaxFindings caps the violations an event links to.
+const pagerDutyMaxFindings = 5
+
+type pagerDutyPayload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"`
+	Component     string         `json:"component,omitempty"`
+	Class         string         `json:"class,omitempty"`
+	CustomDetails map[string]any `json:"custom_details,omitempty"`
+}
+
+// SendViolations implements Alerter. It triggers one event per rule at or
+// above the minimum severity. The dedup key is hostname+rule, so later
+// runs that find the same problem update the open incident instead of
+// paging again.
+func (p *PagerDutyClient) SendViolations(hostname string, violations []analyzer.Violation) error {
+	if p.routingKey == "" {
+		return errors.New("PAGERDUTY_ROUTING_KEY not configured")
+	}
+	byRule := map[string][]analyzer.Violation{}
+	for _, v := range violations {
+		if v.Severity.Rank() >= p.minSeverity.Rank() {
+			byRule[v.Category] = append(byRule[v.Category], v)
+		}
+	}
+	rules := make([]string, 0, len(byRule))
+	for r := range byRule {
+		rules = append(rules, r)
+	}
+	sort.Strings(rules)
+
+	var errs []error
+	for _, rule := range rules {
+		ev := pagerDutyEventFor(p.routingKey, hostname, rule, byRule[rule])
+		ev.Links = p.eventLinks(hostname, byRule[rule])
+		if err := p.send(ev); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", rule, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// eventLinks links an incident to the host and to the first few of its
+// violations, each with its acknowledge link when configured.
+func (p *PagerDutyClient) eventLinks(hostname string, vs []analyzer.Violation) []pagerDutyLink {
+	var out []pagerDutyLink
+	if link := p.links.Host(hostname); link != "" {
+		out = append(out, pagerDutyLink{Href: link, Text: "Host " + hostname})
+	}
+	for i, l := range p.links.Findings(hostname, vs[:min(len(vs), pagerDutyMaxFindings)]) {
+		text := vs[i].Message
+		if len(text) > 80 {
+			text = text[:77] + "..."
+		}
+		if l.Finding != "" {
+			out = append(out, pagerDutyLink{Href: l.Finding, Text: text})
+		}
+		if l.Ack != "" {
+			out = append(out, pagerDutyLink{Href: l.Ack, Text: "Acknowledge: " + text})
+		}
+	}
+	return out
+}
+
+func pagerDutyEventFor(routingKey, hostname, rule string, vs []analyzer.Violation) pagerDutyEvent {
+	worst := vs[0].Severity
+	messages := make([]string, 0, len(vs))
+	for _, v := range vs {
+		if v.Severity.Rank() > worst.Rank() {
+			worst = v.Severity
+		}
+		messages = append(messages, v.Message)
+	}
+	summary := fmt.Sprintf("%s: %s", hostname, messages[0])
+	if len(vs) > 1 {
+		summary = fmt.Sprintf("%s: %d %s violations, e.g. %s", hostname, len(vs), rule, messages[0])
+	}
+	// PagerDuty caps summaries at 1024 characters.
+	if len(summary) > 1024 {
+		summary = summary[:1021] + "..."
+	}
+	return pagerDutyEvent{
+		RoutingKey:  routingKey,
+		EventAction: "trigger",
+		DedupKey:    "compliance-agent/" + hostname + "/" + rule,
+		Payload: pagerDutyPayload{
+			Summary:   summary,
+			Source:    hostname,
+			Severity:  pagerDutySeverity(worst),
+			Component: rule,
+			Class:     "compliance",
+			CustomDetails: map[string]any{
+				"violations": messages,
+				"severity":   string(worst),
+			},
+		},
+	}
+}
+
+// pagerDutySeverity maps onto PagerDuty's critical/error/warning/info.
+func pagerDutySeverity(s analyzer.Severity) string {
+	switch s {
+	case analyzer.SeverityCritical:
+		return "critical"
+	case analyzer.SeverityHigh:
+		return "error"
+	case analyzer.SeverityLow, analyzer.SeverityInfo:
+		return "info"
+	}
+	return "warning"
+}
+
+func (p *PagerDutyClient) send(ev pagerDutyEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return p.post(p.eventsURL, body)
+}
+
+func (p *PagerDutyClient) post(url string, body []byte) error {
+	resp, err := p.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		var msg bytes.Buffer
+		_, _ = msg.ReadFrom(resp.Body)
+		return fmt.Errorf("events API returned %s: %s", resp.Status, strings.TrimSpace(msg.String()))
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package alerting
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPagerDuty_SendViolations(t *testing.T) {
+	var events []pagerDutyEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev pagerDutyEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+		events = append(events, ev)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	alerters, err := Build(config.AlertConfig{
+		Enabled:   []string{"pagerduty"},
+		PagerDuty: config.PagerDutyAlertConfig{RoutingKey: "key123", EventsURL: srv.URL},
+	})
+	require.NoError(t, err)
+	require.NoError(t, alerters[0].Test())
+
+	require.NoError(t, alerters[0].SendViolations("web-1", []analyzer.Violation{
+		{Category: "user", Severity: analyzer.SeverityCritical, Message: "unexpected user present: eve"},
+		{Category: "user", Severity: analyzer.SeverityCritical, Message: "unexpected user present: mallory"},
+		{Category: "port", Severity: analyzer.SeverityHigh, Message: "unexpected open port: 23"},
+		{Category: "agent", Severity: analyzer.SeverityCritical, Message: "osquery socket is world-writable"},
+	}))
+	require.Len(t, events, 2, "one event per rule; high doesn't page by default")
+	assert.Equal(t, "compliance-agent/web-1/agent", events[0].DedupKey)
+	assert.Equal(t, "compliance-agent/web-1/user", events[1].DedupKey)
+	assert.Equal(t, "key123", events[1].RoutingKey)
+	assert.Equal(t, "trigger", events[1].EventAction)
+	assert.Equal(t, "critical", events[1].Payload.Severity)
+	assert.Equal(t, "web-1: 2 user violations, e.g. unexpected user present: eve", events[1].Payload.Summary)
+}
+
+func TestPagerDuty_Config(t *testing.T) {
+	t.Setenv("PAGERDUTY_ROUTING_KEY", "")
+	_, err := NewPagerDutyClient(config.PagerDutyAlertConfig{MinSeverity: "urgent"})
+	assert.Error(t, err)
+
+	c, err := NewPagerDutyClient(config.PagerDutyAlertConfig{MinSeverity: "high"})
+	require.NoError(t, err)
+	assert.Error(t, c.Test(), "no routing key")
+	assert.Equal(t, "error", pagerDutySeverity(analyzer.SeverityHigh))
+}
+
+func TestPagerDuty_SendTestIsAChangeEvent(t *testing.T) {
+	var paths []string
+	var ev pagerDutyChangeEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	c, err := NewPagerDutyClient(config.PagerDutyAlertConfig{RoutingKey: "key123", EventsURL: srv.URL + "/v2/enqueue"})
+	require.NoError(t, err)
+	require.NoError(t, SendTest(c, "web-1"))
+	assert.Equal(t, []string{"/v2/change/enqueue"}, paths, "change events don't page")
+	assert.Equal(t, "key123", ev.RoutingKey)
+	assert.Equal(t, "web-1", ev.Payload.Source)
+
+	assert.Equal(t, "https://events.pagerduty.com/v2/change/enqueue", changeEventsURL(DefaultPagerDutyEventsURL))
+	assert.Equal(t, "http://pd.local/change/enqueue", changeEventsURL("http://pd.local/"))
+}
@@ -6,21 +6,68 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sync"
 	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/collector"
+	"compliance-agent/config"
 )
 
-// SlackConfig holds configuration for Slack webhook integration
+func init() {
+	Register("slack", func(cfg config.AlertConfig) (Alerter, error) {
+		c := NewSlackClient()
+		if cfg.Slack.WebhookURL != "" {
+			c.config.WebhookURL = cfg.Slack.WebhookURL
+		}
+		if cfg.Slack.Channel != "" {
+			c.config.Channel = cfg.Slack.Channel
+		}
+		if cfg.Slack.BotToken != "" {
+			c.config.BotToken = cfg.Slack.BotToken
+		}
+		if cfg.Slack.APIURL != "" {
+			c.config.APIURL = cfg.Slack.APIURL
+		}
+		c.config.ThreadState = cfg.Slack.ThreadState
+		c.config.MaxViolations = cfg.ChatLimit
+		links, err := NewLinks(cfg.Links)
+		if err != nil {
+			return nil, err
+		}
+		c.links = links
+		if err := c.loadThreads(); err != nil {
+			return nil, fmt.Errorf("thread_state: %w", err)
+		}
+		return c, nil
+	})
+}
+
+// SlackConfig holds configuration for Slack integration. With a
+// BotToken, messages go through the Web API and follow-ups are threaded;
+// otherwise they go to the incoming webhook.
 type SlackConfig struct {
-	WebhookURL string
-	Channel    string
-	Username   string
-	IconEmoji  string
+	WebhookURL  string
+	Channel     string
+	Username    string
+	IconEmoji   string
+	BotToken    string
+	APIURL      string
+	ThreadState string
+	// MaxViolations caps how many violations, riskiest first, a message
+	// lists; zero lists them all.
+	MaxViolations int
 }
 
 // SlackClient handles sending alerts to Slack
 type SlackClient struct {
 	config SlackConfig
 	client *http.Client
+	links  *Links
+
+	// mu guards threads, each host's last Web API report thread.
+	mu      sync.Mutex
+	threads map[string]*slackThread
 }
 
 // NewSlackClient creates a new Slack client
@@ -30,6 +77,8 @@ func NewSlackClient() *SlackClient {
 		Channel:    os.Getenv("SLACK_CHANNEL"),
 		Username:   "Compliance Agent",
 		IconEmoji:  ":shield:",
+		BotToken:   os.Getenv("SLACK_BOT_TOKEN"),
+		APIURL:     DefaultSlackAPIURL,
 	}
 
 	// Set defaults if not provided
@@ -38,14 +87,33 @@ func NewSlackClient() *SlackClient {
 	}
 
 	return &SlackClient{
-		config: config,
-		client: &http.Client{Timeout: 10 * time.Second},
+		config:  config,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		threads: map[string]*slackThread{},
 	}
 }
 
+// Name implements Alerter.
+func (s *SlackClient) Name() string { return "slack" }
+
+// SendReport implements Alerter.
+func (s *SlackClient) SendReport(report ComplianceReport) error {
+	return s.SendComplianceReport(report)
+}
+
+// SendViolations implements Alerter.
+func (s *SlackClient) SendViolations(hostname string, violations []analyzer.Violation) error {
+	return s.SendViolationAlert(hostname, violations)
+}
+
+// Test implements Alerter.
+func (s *SlackClient) Test() error { return s.TestConnection() }
+
 // SlackMessage represents a Slack message payload
 type SlackMessage struct {
-	Channel     string       `json:"channel,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	// ThreadTS replies under an earlier message (Web API only).
+	ThreadTS    string       `json:"thread_ts,omitempty"`
 	Username    string       `json:"username,omitempty"`
 	IconEmoji   string       `json:"icon_emoji,omitempty"`
 	Text        string       `json:"text,omitempty"`
@@ -56,6 +124,7 @@ type SlackMessage struct {
 type Attachment struct {
 	Color     string   `json:"color,omitempty"`
 	Title     string   `json:"title,omitempty"`
+	TitleLink string   `json:"title_link,omitempty"`
 	Text      string   `json:"text,omitempty"`
 	Fields    []Field  `json:"fields,omitempty"`
 	Actions   []Action `json:"actions,omitempty"`
@@ -73,28 +142,45 @@ type Field struct {
 type ComplianceReport struct {
 	GeneratedAt   time.Time              `json:"generated_at"`
 	Hostname      string                 `json:"hostname"`
-	Users         []map[string]string    `json:"users"`
-	Processes     []map[string]string    `json:"processes"`
+	Users         []collector.User       `json:"users"`
+	Processes     []collector.Process    `json:"processes"`
 	OpenPorts     []int                  `json:"open_ports"`
-	Packages      []map[string]string    `json:"packages"`
-	Violations    []map[string]string    `json:"violations"`
+	Packages      []collector.Package    `json:"packages"`
+	Violations    []analyzer.Violation   `json:"violations"`
 	ExtraMetadata map[string]interface{} `json:"meta,omitempty"`
+	// Counts stands in for the inventory when a summary leaves it out.
+	Counts *InventoryCounts `json:"counts,omitempty"`
+}
+
+// InventoryCounts is how many users, processes, open ports and packages
+// a report found.
+type InventoryCounts struct {
+	Users     int `json:"users"`
+	Processes int `json:"processes"`
+	OpenPorts int `json:"open_ports"`
+	Packages  int `json:"packages"`
+}
+
+// inventory counts the report's inventory, or returns Counts when it has
+// been left out.
+func (r ComplianceReport) inventory() InventoryCounts {
+	if r.Counts != nil {
+		return *r.Counts
+	}
+	return InventoryCounts{Users: len(r.Users), Processes: len(r.Processes), OpenPorts: len(r.OpenPorts), Packages: len(r.Packages)}
 }
 
 // SendComplianceReport sends a compliance report to Slack
 func (s *SlackClient) SendComplianceReport(report ComplianceReport) error {
-	if s.config.WebhookURL == "" {
-		return fmt.Errorf("SLACK_WEBHOOK_URL not configured")
+	if err := s.checkConfigured(); err != nil {
+		return err
 	}
 
-	// Determine message color based on violations
+	// Color by the worst severity present so one critical finding isn't
+	// drowned out by a pile of informational ones.
 	color := "good" // green
 	if len(report.Violations) > 0 {
-		if len(report.Violations) > 10 {
-			color = "danger" // red
-		} else {
-			color = "warning" // yellow
-		}
+		color = severityColor(highestSeverity(report.Violations))
 	}
 
 	// Create summary text
@@ -106,6 +192,7 @@ func (s *SlackClient) SendComplianceReport(report ComplianceReport) error {
 	}
 
 	// Create fields for the attachment
+	counts := report.inventory()
 	fields := []Field{
 		{
 			Title: "🕐 Generated At",
@@ -119,22 +206,22 @@ func (s *SlackClient) SendComplianceReport(report ComplianceReport) error {
 		},
 		{
 			Title: "👥 Users",
-			Value: fmt.Sprintf("%d", len(report.Users)),
+			Value: fmt.Sprintf("%d", counts.Users),
 			Short: true,
 		},
 		{
 			Title: "⚙️ Processes",
-			Value: fmt.Sprintf("%d", len(report.Processes)),
+			Value: fmt.Sprintf("%d", counts.Processes),
 			Short: true,
 		},
 		{
 			Title: "🔌 Open Ports",
-			Value: fmt.Sprintf("%d", len(report.OpenPorts)),
+			Value: fmt.Sprintf("%d", counts.OpenPorts),
 			Short: true,
 		},
 		{
 			Title: "📦 Packages",
-			Value: fmt.Sprintf("%d", len(report.Packages)),
+			Value: fmt.Sprintf("%d", counts.Packages),
 			Short: true,
 		},
 	}
@@ -146,6 +233,14 @@ func (s *SlackClient) SendComplianceReport(report ComplianceReport) error {
 			Title: "⚠️ Violations Summary",
 			Value: violationSummary,
 			Short: false,
+		}, Field{
+			Title: "🎯 By Severity",
+			Value: severitySummary(report.Violations),
+			Short: false,
+		}, Field{
+			Title: "🔥 Top Risks",
+			Value: s.topRisks(report.Violations),
+			Short: false,
 		})
 	}
 
@@ -153,19 +248,21 @@ func (s *SlackClient) SendComplianceReport(report ComplianceReport) error {
 	attachment := Attachment{
 		Color:     color,
 		Title:     "Compliance Report Details",
-		Text:      "Click 'View Report' to see full details",
 		Fields:    fields,
 		Timestamp: report.GeneratedAt.Unix(),
 	}
 
-	// Add action buttons
-	attachment.Actions = []Action{
-		{
-			Type: "button",
-			Text: "View Full Report",
-			URL:  "file://compliance_report.json",
-			Style: "primary",
-		},
+	// Link to the host on the fleet server, when there is one.
+	if link := s.links.Host(report.Hostname); link != "" {
+		attachment.Text = "Click 'View Host' to see full details"
+		attachment.Actions = []Action{
+			{
+				Type:  "button",
+				Text:  "View Host",
+				URL:   link,
+				Style: "primary",
+			},
+		}
 	}
 
 	// Create message
@@ -177,6 +274,9 @@ func (s *SlackClient) SendComplianceReport(report ComplianceReport) error {
 		Attachments: []Attachment{attachment},
 	}
 
+	if s.config.BotToken != "" {
+		return s.postReport(report.Hostname, message, report.Violations)
+	}
 	return s.sendMessage(message)
 }
 
@@ -188,40 +288,85 @@ type Action struct {
 	Style string `json:"style,omitempty"`
 }
 
-// createViolationSummary creates a summary of violations by category
-func (s *SlackClient) createViolationSummary(violations []map[string]string) string {
-	categoryCount := make(map[string]int)
-	for _, violation := range violations {
-		category := violation["category"]
-		if category == "" {
-			category = "unknown"
-		}
-		categoryCount[category]++
+// createViolationSummary counts violations by category, riskiest
+// category first.
+func (s *SlackClient) createViolationSummary(violations []analyzer.Violation) string {
+	summary := ""
+	for _, g := range analyzer.GroupByRisk(violations) {
+		summary += fmt.Sprintf("%s %s: %d\n", categoryEmoji(g.Category), g.Category, len(g.Violations))
 	}
+	return summary
+}
 
-	summary := ""
-	for category, count := range categoryCount {
-		emoji := "⚠️"
-		switch category {
-		case "user":
-			emoji = "👤"
-		case "port":
-			emoji = "🔌"
-		case "package":
-			emoji = "📦"
-		case "process":
-			emoji = "⚙️"
-		}
-		summary += fmt.Sprintf("%s %s: %d\n", emoji, category, count)
+// topRisks lists the riskiest violations, up to MaxViolations.
+func (s *SlackClient) topRisks(violations []analyzer.Violation) string {
+	shown, more := s.capped(violations)
+	text := ""
+	for _, v := range shown {
+		text += "• " + violationLine(v) + "\n"
+	}
+	if more > 0 {
+		text += fmt.Sprintf("…and %d more lower-risk violation(s)\n", more)
+	}
+	return text
+}
+
+// capped returns violations riskiest first, cut to MaxViolations, and how
+// many were left out.
+func (s *SlackClient) capped(violations []analyzer.Violation) (shown []analyzer.Violation, more int) {
+	shown = append([]analyzer.Violation(nil), violations...)
+	analyzer.SortByRisk(shown)
+	if limit := s.config.MaxViolations; limit > 0 && len(shown) > limit {
+		return shown[:limit], len(shown) - limit
 	}
+	return shown, 0
+}
 
-	return summary
+// findingLinks is the " · <link|finding> · <link|acknowledge>" suffix of
+// a violation's line, empty without links.
+func (s *SlackClient) findingLinks(hostname string, v analyzer.Violation) string {
+	l := s.links.Finding(hostname, v)
+	out := ""
+	if l.Finding != "" {
+		out += " · <" + l.Finding + "|finding>"
+	}
+	if l.Ack != "" {
+		out += " · <" + l.Ack + "|acknowledge>"
+	}
+	return out
+}
+
+// violationLine is one violation in a message: severity, risk when
+// scored, and the message.
+func violationLine(v analyzer.Violation) string {
+	if v.Risk > 0 {
+		return fmt.Sprintf("[%s · risk %g] %s", severityOf(v), v.Risk, v.Message)
+	}
+	return fmt.Sprintf("[%s] %s", severityOf(v), v.Message)
+}
+
+func categoryEmoji(category string) string {
+	switch category {
+	case "user":
+		return "👤"
+	case "port":
+		return "🔌"
+	case "package":
+		return "📦"
+	case "process":
+		return "⚙️"
+	case IncidentCategory:
+		return "🔗"
+	}
+	return "⚠️"
 }
 
-// SendViolationAlert sends an immediate alert for critical violations
-func (s *SlackClient) SendViolationAlert(hostname string, violations []map[string]string) error {
-	if s.config.WebhookURL == "" {
-		return fmt.Errorf("SLACK_WEBHOOK_URL not configured")
+// SendViolationAlert sends an immediate alert for critical violations.
+// Through the Web API it replies under the host's report with every
+// violation listed, rather than the first few per category.
+func (s *SlackClient) SendViolationAlert(hostname string, violations []analyzer.Violation) error {
+	if err := s.checkConfigured(); err != nil {
+		return err
 	}
 
 	if len(violations) == 0 {
@@ -231,62 +376,39 @@ func (s *SlackClient) SendViolationAlert(hostname string, violations []map[strin
 	// Create urgent alert message
 	text := fmt.Sprintf("🚨 *CRITICAL COMPLIANCE VIOLATIONS* detected on `%s`", hostname)
 
-	// Group violations by category
-	categoryViolations := make(map[string][]map[string]string)
-	for _, violation := range violations {
-		category := violation["category"]
-		if category == "" {
-			category = "unknown"
-		}
-		categoryViolations[category] = append(categoryViolations[category], violation)
-	}
-
-	// Create fields for each category
+	// The riskiest violations, up to the cap, grouped by category with
+	// the riskiest group first.
+	shown, more := s.capped(violations)
 	fields := []Field{}
-	for category, vios := range categoryViolations {
-		emoji := "⚠️"
-		switch category {
-		case "user":
-			emoji = "👤"
-		case "port":
-			emoji = "🔌"
-		case "package":
-			emoji = "📦"
-		case "process":
-			emoji = "⚙️"
-		}
-
-		// Show first few violations for each category
-		violationText := ""
-		maxShow := 3
-		if len(vios) > maxShow {
-			violationText = fmt.Sprintf("%d violations (showing first %d):\n", len(vios), maxShow)
-		} else {
-			violationText = fmt.Sprintf("%d violations:\n", len(vios))
-		}
-
-		for i, vio := range vios {
-			if i >= maxShow {
-				break
-			}
-			violationText += fmt.Sprintf("• %s\n", vio["message"])
+	for _, g := range analyzer.GroupByRisk(shown) {
+		violationText := fmt.Sprintf("%d violations:\n", len(g.Violations))
+		for _, vio := range g.Violations {
+			violationText += "• " + violationLine(vio) + s.findingLinks(hostname, vio) + "\n"
 		}
-
 		fields = append(fields, Field{
-			Title: fmt.Sprintf("%s %s", emoji, category),
+			Title: fmt.Sprintf("%s %s", categoryEmoji(g.Category), g.Category),
 			Value: violationText,
 			Short: false,
 		})
 	}
+	if more > 0 {
+		fields = append(fields, Field{
+			Value: fmt.Sprintf("…and %d more lower-risk violation(s), %d in all; see the full report", more, len(violations)),
+			Short: false,
+		})
+	}
 
 	// Create attachment
 	attachment := Attachment{
-		Color:     "danger",
+		Color:     severityColor(highestSeverity(violations)),
 		Title:     "Immediate Action Required",
 		Text:      "Review the violations below and take appropriate action",
 		Fields:    fields,
 		Timestamp: time.Now().Unix(),
 	}
+	if link := s.links.Host(hostname); link != "" {
+		attachment.TitleLink = link
+	}
 
 	// Create message
 	message := SlackMessage{
@@ -297,9 +419,99 @@ func (s *SlackClient) SendViolationAlert(hostname string, violations []map[strin
 		Attachments: []Attachment{attachment},
 	}
 
+	if s.config.BotToken != "" {
+		if t := s.thread(hostname); t != nil {
+			message.Channel, message.ThreadTS = t.Channel, t.TS
+		}
+		_, err := s.postMessage(message)
+		return err
+	}
 	return s.sendMessage(message)
 }
 
+// SendResolved implements Resolver. Through the Web API, resolutions
+// are already threaded under the previous report by SendReport.
+func (s *SlackClient) SendResolved(hostname string, resolved []analyzer.Violation) error {
+	if err := s.checkConfigured(); err != nil {
+		return err
+	}
+	if len(resolved) == 0 || s.config.BotToken != "" {
+		return nil
+	}
+	text := fmt.Sprintf("✅ *%d violation(s) resolved* on `%s`", len(resolved), hostname)
+	shown, more := s.capped(resolved)
+	for _, v := range shown {
+		text += "\n• " + violationLine(v)
+	}
+	if more > 0 {
+		text += fmt.Sprintf("\n…and %d more", more)
+	}
+	return s.sendMessage(SlackMessage{
+		Channel:   s.config.Channel,
+		Username:  s.config.Username,
+		IconEmoji: s.config.IconEmoji,
+		Text:      text,
+	})
+}
+
+// severityOrder lists severities from worst to least severe.
+var severityOrder = []analyzer.Severity{
+	analyzer.SeverityCritical,
+	analyzer.SeverityHigh,
+	analyzer.SeverityMedium,
+	analyzer.SeverityLow,
+	analyzer.SeverityInfo,
+}
+
+// severityOf returns a violation's severity, defaulting to medium for
+// violations produced before severities existed.
+func severityOf(v analyzer.Violation) analyzer.Severity {
+	if v.Severity != "" {
+		return v.Severity
+	}
+	return analyzer.SeverityMedium
+}
+
+func highestSeverity(violations []analyzer.Violation) analyzer.Severity {
+	var best analyzer.Severity
+	for _, v := range violations {
+		if sev := severityOf(v); best == "" || sev.Rank() > best.Rank() {
+			best = sev
+		}
+	}
+	return best
+}
+
+// severityColor maps a severity onto a Slack attachment color.
+func severityColor(severity analyzer.Severity) string {
+	switch severity {
+	case analyzer.SeverityCritical:
+		return "#8B0000" // dark red
+	case analyzer.SeverityHigh:
+		return "danger"
+	case analyzer.SeverityMedium:
+		return "warning"
+	case analyzer.SeverityLow:
+		return "#439FE0" // blue
+	default:
+		return "good"
+	}
+}
+
+func severitySummary(violations []analyzer.Violation) string {
+	counts := map[analyzer.Severity]int{}
+	for _, v := range violations {
+		counts[severityOf(v)]++
+	}
+	summary := ""
+	for _, sev := range severityOrder {
+		if counts[sev] > 0 {
+			summary += fmt.Sprintf("%s: %d\n", sev, counts[sev])
+		}
+	}
+	return summary
+}
+
 // sendMessage sends a message to Slack
 func (s *SlackClient) sendMessage(message SlackMessage) error {
 	jsonData, err := json.Marshal(message)
@@ -320,10 +532,23 @@ func (s *SlackClient) sendMessage(message SlackMessage) error {
 	return nil
 }
 
-// TestConnection tests the Slack webhook connection
+// checkConfigured returns an error when neither transport is set up.
+func (s *SlackClient) checkConfigured() error {
+	if s.config.WebhookURL == "" && s.config.BotToken == "" {
+		return fmt.Errorf("SLACK_WEBHOOK_URL or SLACK_BOT_TOKEN not configured")
+	}
+	return nil
+}
+
+// TestConnection tests the Slack connection. With a bot token it checks
+// the token with auth.test, which posts nothing.
 func (s *SlackClient) TestConnection() error {
-	if s.config.WebhookURL == "" {
-		return fmt.Errorf("SLACK_WEBHOOK_URL not configured")
+	if err := s.checkConfigured(); err != nil {
+		return err
+	}
+	if s.config.BotToken != "" {
+		_, err := s.callAPI("auth.test", struct{}{})
+		return err
 	}
 
 	testMessage := SlackMessage{
@@ -0,0 +1,156 @@
+package alerting
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"compliance-agent/analyzer"
+)
+
+// DefaultSlackAPIURL is the Slack Web API base URL.
+const DefaultSlackAPIURL = "https://slack.com/api"
+
+// slackThread is the last report posted for a host through the Web API
+// and the violations it listed, so later messages can thread under it.
+type slackThread struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+	// Open maps each violation's fingerprint (analyzer.Fingerprint) to
+	// its message, for resolution updates.
+	Open map[string]string `json:"open"`
+}
+
+// slackAPIResponse is the envelope every Web API method returns. Errors
+// come back as HTTP 200 with ok=false.
+type slackAPIResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// callAPI POSTs body as JSON to a Web API method with the bot token.
+func (s *SlackClient) callAPI(method string, body any) (slackAPIResponse, error) {
+	var out slackAPIResponse
+	b, err := json.Marshal(body)
+	if err != nil {
+		return out, fmt.Errorf("failed to marshal message: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(s.config.APIURL, "/")+"/"+method, bytes.NewReader(b))
+	if err != nil {
+		return out, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+s.config.BotToken)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return out, fmt.Errorf("failed to send message: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return out, fmt.Errorf("slack API %s returned status %d", method, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return out, fmt.Errorf("slack API %s: %w", method, err)
+	}
+	if !out.OK {
+		return out, fmt.Errorf("slack API %s: %s", method, out.Error)
+	}
+	return out, nil
+}
+
+// postMessage sends message with chat.postMessage and returns where it
+// landed. The bot posts under its own name and icon.
+func (s *SlackClient) postMessage(message SlackMessage) (slackAPIResponse, error) {
+	message.Username, message.IconEmoji = "", ""
+	return s.callAPI("chat.postMessage", message)
+}
+
+// postReport posts the report at the top of the channel, first replying
+// under the host's previous report with any violations it listed that
+// are now gone.
+func (s *SlackClient) postReport(hostname string, message SlackMessage, violations []analyzer.Violation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	open := make(map[string]string, len(violations))
+	for _, v := range violations {
+		open[analyzer.Fingerprint(hostname, v)] = v.Message
+	}
+	if prev := s.threads[hostname]; prev != nil {
+		var resolved []string
+		for k, msg := range prev.Open {
+			if _, still := open[k]; !still {
+				resolved = append(resolved, msg)
+			}
+		}
+		if len(resolved) > 0 {
+			if _, err := s.postMessage(resolutionMessage(prev, resolved)); err != nil {
+				return err
+			}
+		}
+	}
+
+	resp, err := s.postMessage(message)
+	if err != nil {
+		return err
+	}
+	s.threads[hostname] = &slackThread{Channel: resp.Channel, TS: resp.TS, Open: open}
+	return s.saveThreads()
+}
+
+// resolutionMessage is the thread reply listing resolved violations.
+func resolutionMessage(t *slackThread, resolved []string) SlackMessage {
+	sort.Strings(resolved)
+	text := fmt.Sprintf("✅ *%d violation(s) resolved* since this report:", len(resolved))
+	for _, msg := range resolved {
+		text += "\n• " + msg
+	}
+	return SlackMessage{Channel: t.Channel, ThreadTS: t.TS, Text: text}
+}
+
+// thread returns the host's current report thread, or nil.
+func (s *SlackClient) thread(hostname string) *slackThread {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.threads[hostname]
+}
+
+// loadThreads reads the thread state file, if one is configured. A
+// missing file is a first run.
+func (s *SlackClient) loadThreads() error {
+	if s.config.ThreadState == "" {
+		return nil
+	}
+	b, err := os.ReadFile(s.config.ThreadState)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &s.threads)
+}
+
+// saveThreads writes the thread state file, if one is configured. The
+// caller holds s.mu.
+func (s *SlackClient) saveThreads() error {
+	if s.config.ThreadState == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.config.ThreadState), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(s.threads, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.config.ThreadState, b, 0o600)
+}
@@ -0,0 +1,136 @@
+package alerting
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSlackAPI records chat.postMessage calls and answers each with a new
+// message ts in channel C123.
+type fakeSlackAPI struct {
+	posts   []SlackMessage
+	methods []string
+}
+
+func (f *fakeSlackAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer xoxb-test" {
+		_ = json.NewEncoder(w).Encode(slackAPIResponse{Error: "invalid_auth"})
+		return
+	}
+	f.methods = append(f.methods, r.URL.Path)
+	if r.URL.Path == "/chat.postMessage" {
+		var m SlackMessage
+		_ = json.NewDecoder(r.Body).Decode(&m)
+		f.posts = append(f.posts, m)
+	}
+	_ = json.NewEncoder(w).Encode(slackAPIResponse{OK: true, Channel: "C123", TS: fmt.Sprintf("1700000000.%06d", len(f.posts))})
+}
+
+func newBotAlerter(t *testing.T, url, state string) Alerter {
+	t.Helper()
+	alerters, err := Build(config.AlertConfig{
+		Enabled: []string{"slack"},
+		Slack:   config.SlackAlertConfig{BotToken: "xoxb-test", APIURL: url, Channel: "#sec", ThreadState: state},
+	})
+	require.NoError(t, err)
+	return alerters[0]
+}
+
+func TestSlackAPI_ThreadsViolationsAndResolutions(t *testing.T) {
+	api := &fakeSlackAPI{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	state := filepath.Join(t.TempDir(), "slack-threads.json")
+
+	eve := analyzer.Violation{Category: "user", Severity: analyzer.SeverityCritical, Message: "unexpected user present: eve"}
+	telnet := analyzer.Violation{Category: "port", Severity: analyzer.SeverityHigh, Message: "port 23 open"}
+
+	s := newBotAlerter(t, srv.URL, state)
+	require.NoError(t, s.Test())
+	require.NoError(t, s.SendReport(ComplianceReport{Hostname: "web-1", Violations: []analyzer.Violation{eve, telnet}}))
+	require.NoError(t, s.SendViolations("web-1", []analyzer.Violation{eve, telnet}))
+	assert.Equal(t, []string{"/auth.test", "/chat.postMessage", "/chat.postMessage"}, api.methods)
+	require.Len(t, api.posts, 2)
+	assert.Equal(t, "#sec", api.posts[0].Channel)
+	assert.Empty(t, api.posts[0].ThreadTS)
+	assert.Empty(t, api.posts[0].Username, "bots post under their own name")
+	assert.Equal(t, "C123", api.posts[1].Channel)
+	assert.Equal(t, "1700000000.000001", api.posts[1].ThreadTS)
+
+	// A new process (a one-shot run) picks the thread up from the state
+	// file and reports eve's account as resolved under the old report.
+	s = newBotAlerter(t, srv.URL, state)
+	require.NoError(t, s.SendReport(ComplianceReport{Hostname: "web-1", Violations: []analyzer.Violation{telnet}}))
+	require.Len(t, api.posts, 4)
+	assert.Equal(t, "1700000000.000001", api.posts[2].ThreadTS)
+	assert.Contains(t, api.posts[2].Text, "1 violation(s) resolved")
+	assert.Contains(t, api.posts[2].Text, eve.Message)
+	assert.NotContains(t, api.posts[2].Text, telnet.Message)
+	assert.Empty(t, api.posts[3].ThreadTS, "the new report starts a new thread")
+
+	// Nothing resolved: no reply.
+	require.NoError(t, s.SendReport(ComplianceReport{Hostname: "web-1", Violations: []analyzer.Violation{telnet}}))
+	assert.Len(t, api.posts, 5)
+}
+
+func TestSlackAPI_Errors(t *testing.T) {
+	api := &fakeSlackAPI{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	alerters, err := Build(config.AlertConfig{
+		Enabled: []string{"slack"},
+		Slack:   config.SlackAlertConfig{BotToken: "xoxb-wrong", APIURL: srv.URL},
+	})
+	require.NoError(t, err)
+	assert.ErrorContains(t, alerters[0].Test(), "invalid_auth")
+
+	t.Setenv("SLACK_WEBHOOK_URL", "")
+	t.Setenv("SLACK_BOT_TOKEN", "")
+	c := NewSlackClient()
+	assert.ErrorContains(t, c.Test(), "not configured")
+}
+
+func TestSlack_RiskOrderAndChatLimit(t *testing.T) {
+	api := &fakeSlackAPI{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	alerters, err := Build(config.AlertConfig{
+		Enabled:   []string{"slack"},
+		Slack:     config.SlackAlertConfig{BotToken: "xoxb-test", APIURL: srv.URL},
+		ChatLimit: 2,
+	})
+	require.NoError(t, err)
+
+	violations := []analyzer.Violation{
+		{Category: "package", Severity: analyzer.SeverityLow, Message: "telnet installed", Risk: 2},
+		{Category: "user", Severity: analyzer.SeverityHigh, Message: "unexpected user present: eve", Risk: 7},
+		{Category: "port", Severity: analyzer.SeverityHigh, Message: "port 23 open", Risk: 10.5},
+	}
+	require.NoError(t, alerters[0].SendViolations("web-1", violations))
+	require.Len(t, api.posts, 1)
+	fields := api.posts[0].Attachments[0].Fields
+	require.Len(t, fields, 3)
+	assert.Equal(t, "🔌 port", fields[0].Title, "riskiest category first")
+	assert.Contains(t, fields[0].Value, "[high · risk 10.5] port 23 open")
+	assert.Equal(t, "👤 user", fields[1].Title)
+	assert.Contains(t, fields[2].Value, "1 more lower-risk violation(s), 3 in all")
+
+	require.NoError(t, alerters[0].SendReport(ComplianceReport{Hostname: "web-1", Violations: violations}))
+	var top string
+	for _, f := range api.posts[1].Attachments[0].Fields {
+		if f.Title == "🔥 Top Risks" {
+			top = f.Value
+		}
+	}
+	assert.Equal(t, "• [high · risk 10.5] port 23 open\n• [high · risk 7] unexpected user present: eve\n…and 1 more lower-risk violation(s)\n", top)
+}
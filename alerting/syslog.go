@@ -0,0 +1,430 @@
+package alerting
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+)
+
+// syslogSDID is the structured data ID of violation parameters. 32473 is
+// the example enterprise number (RFC 5612), as no number is registered
+// for the agent.
+const syslogSDID = "compliance@32473"
+
+// journaldSocket is where journald accepts native protocol datagrams.
+var journaldSocket = "/run/systemd/journal/socket"
+
+// localSyslogSockets are the local syslog daemon's sockets on Linux,
+// macOS and the BSDs, tried in order.
+var localSyslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+func init() {
+	Register("syslog", func(cfg config.AlertConfig) (Alerter, error) {
+		c, err := NewSyslogClient(cfg.Syslog)
+		if err != nil {
+			return nil, err
+		}
+		if c.links, err = NewLinks(cfg.Links); err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+}
+
+// syslogFacilities are the RFC 5424 facility codes by name.
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// SyslogClient writes violations to syslog, so log pipelines that
+// already collect it pick compliance events up with no new integration.
+// Each violation is one message, with its category, severity, control,
+// user, fingerprint and links as structured data (journal fields in
+// journald).
+type SyslogClient struct {
+	network     string
+	address     string
+	facility    int
+	appName     string
+	events      []string
+	minSeverity analyzer.Severity
+	tlsConfig   *tls.Config
+	timeout     time.Duration
+	links       *Links
+}
+
+// NewSyslogClient builds a client from config, falling back to the
+// SYSLOG_ADDRESS environment variable.
+func NewSyslogClient(cfg config.SyslogAlertConfig) (*SyslogClient, error) {
+	c := &SyslogClient{
+		network: cfg.Network,
+		address: cfg.Address,
+		appName: cfg.AppName,
+		events:  cfg.Events,
+		timeout: cfg.Timeout,
+	}
+	if c.network == "" {
+		c.network = "local"
+	}
+	if c.address == "" {
+		c.address = os.Getenv("SYSLOG_ADDRESS")
+	}
+	if c.appName == "" {
+		c.appName = "compliance-agent"
+	}
+	if c.timeout <= 0 {
+		c.timeout = 10 * time.Second
+	}
+	facility := cfg.Facility
+	if facility == "" {
+		facility = "local0"
+	}
+	f, ok := syslogFacilities[strings.ToLower(facility)]
+	if !ok {
+		return nil, fmt.Errorf("facility: unknown facility %q", facility)
+	}
+	c.facility = f
+	if len(c.events) == 0 {
+		c.events = []string{"report", "violations", "resolved"}
+	}
+	for _, e := range c.events {
+		if e != "report" && e != "violations" && e != "resolved" {
+			return nil, fmt.Errorf("events: unknown event %q (want report, violations or resolved)", e)
+		}
+	}
+	if cfg.MinSeverity != "" {
+		sev, err := analyzer.ParseSeverity(cfg.MinSeverity)
+		if err != nil {
+			return nil, fmt.Errorf("min_severity: %w", err)
+		}
+		c.minSeverity = sev
+	}
+	switch c.network {
+	case "local", "journald":
+	case "udp", "tcp":
+		if c.address == "" {
+			return nil, fmt.Errorf("network %s needs an address (or SYSLOG_ADDRESS)", c.network)
+		}
+	case "tls":
+		if c.address == "" {
+			return nil, errors.New("network tls needs an address (or SYSLOG_ADDRESS)")
+		}
+		tc, err := syslogTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		c.tlsConfig = tc
+	default:
+		return nil, fmt.Errorf("network: unknown network %q (want local, journald, udp, tcp or tls)", c.network)
+	}
+	return c, nil
+}
+
+func syslogTLSConfig(cfg config.SyslogAlertConfig) (*tls.Config, error) {
+	tc := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("ca_file: %w", err)
+		}
+		tc.RootCAs = x509.NewCertPool()
+		if !tc.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_file: no certificates in %s", cfg.CAFile)
+		}
+	}
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, errors.New("set both cert_file and key_file, or neither")
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
+
+// Name implements Alerter.
+func (s *SyslogClient) Name() string { return "syslog" }
+
+// Test implements Alerter by connecting, without writing a message. Over
+// UDP that only checks the address resolves.
+func (s *SyslogClient) Test() error {
+	conn, _, err := s.dial()
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// syslogMessage is one event before it is encoded for the transport.
+type syslogMessage struct {
+	hostname string
+	msgID    string // "report" | "violation" | "resolved"
+	severity int    // RFC 5424 severity, 0 (emergency) to 7 (debug)
+	text     string
+	params   [][2]string // structured data, in order
+}
+
+// SendTest implements TestSender with one informational message.
+func (s *SyslogClient) SendTest(hostname string) error {
+	return s.send([]syslogMessage{{hostname: hostname, msgID: "test", severity: 6, text: "compliance-agent test message"}})
+}
+
+// SendReport implements Alerter with one summary message per scan,
+// which also shows a collector that the agent is running.
+func (s *SyslogClient) SendReport(report ComplianceReport) error {
+	if !slices.Contains(s.events, "report") {
+		return nil
+	}
+	counts := map[analyzer.Severity]int{}
+	for _, v := range report.Violations {
+		counts[severityOf(v)]++
+	}
+	params := [][2]string{{"violations", strconv.Itoa(len(report.Violations))}, {"host_url", s.links.Host(report.Hostname)}}
+	var parts []string
+	for _, sev := range []analyzer.Severity{analyzer.SeverityCritical, analyzer.SeverityHigh, analyzer.SeverityMedium, analyzer.SeverityLow, analyzer.SeverityInfo} {
+		if counts[sev] > 0 {
+			params = append(params, [2]string{string(sev), strconv.Itoa(counts[sev])})
+			parts = append(parts, fmt.Sprintf("%d %s", counts[sev], sev))
+		}
+	}
+	text := fmt.Sprintf("compliance scan of %s: no violations", report.Hostname)
+	severity := 6 // informational
+	if len(report.Violations) > 0 {
+		text = fmt.Sprintf("compliance scan of %s: %d violations (%s)", report.Hostname, len(report.Violations), strings.Join(parts, ", "))
+		severity = 5 // notice
+	}
+	return s.send([]syslogMessage{{hostname: report.Hostname, msgID: "report", severity: severity, text: text, params: params}})
+}
+
+// SendViolations implements Alerter, one message per violation at or
+// above min_severity.
+func (s *SyslogClient) SendViolations(hostname string, violations []analyzer.Violation) error {
+	return s.sendViolations("violations", hostname, violations)
+}
+
+// SendResolved implements Resolver with a notice per violation that is
+// gone, carrying the same fingerprint as the message that raised it.
+func (s *SyslogClient) SendResolved(hostname string, resolved []analyzer.Violation) error {
+	return s.sendViolations("resolved", hostname, resolved)
+}
+
+func (s *SyslogClient) sendViolations(event, hostname string, violations []analyzer.Violation) error {
+	if !slices.Contains(s.events, event) {
+		return nil
+	}
+	var msgs []syslogMessage
+	for _, v := range violations {
+		sev := severityOf(v)
+		if s.minSeverity != "" && sev.Rank() < s.minSeverity.Rank() {
+			continue
+		}
+		links := s.links.Finding(hostname, v)
+		m := syslogMessage{
+			hostname: hostname,
+			msgID:    "violation",
+			severity: syslogSeverity(sev),
+			text:     v.Message,
+			params: [][2]string{
+				{"category", v.Category},
+				{"severity", string(sev)},
+				{"control", v.Control},
+				{"user", v.User},
+				{"fingerprint", links.Fingerprint},
+				{"finding_url", links.Finding},
+				{"ack_url", links.Ack},
+			},
+		}
+		if event == "resolved" {
+			m.msgID, m.severity, m.text = "resolved", 5, "resolved: "+v.Message
+		}
+		msgs = append(msgs, m)
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return s.send(msgs)
+}
+
+// syslogSeverity maps onto RFC 5424 severities: critical is crit, high
+// err, medium warning, low notice and info informational.
+func syslogSeverity(s analyzer.Severity) int {
+	switch s {
+	case analyzer.SeverityCritical:
+		return 2
+	case analyzer.SeverityHigh:
+		return 3
+	case analyzer.SeverityLow:
+		return 5
+	case analyzer.SeverityInfo:
+		return 6
+	}
+	return 4
+}
+
+// transport says how dial's connection frames messages.
+type transport int
+
+const (
+	datagram transport = iota // one RFC 5424 message per datagram
+	stream                    // octet-counted RFC 5424 (RFC 6587, 5425)
+	journal                   // journald native protocol
+)
+
+// dial connects to the configured destination. "local" prefers journald
+// when its socket exists, as on systemd Linux hosts.
+func (s *SyslogClient) dial() (net.Conn, transport, error) {
+	switch s.network {
+	case "udp":
+		conn, err := net.DialTimeout("udp", s.address, s.timeout)
+		return conn, datagram, err
+	case "tcp":
+		conn, err := net.DialTimeout("tcp", s.address, s.timeout)
+		return conn, stream, err
+	case "tls":
+		d := &tls.Dialer{NetDialer: &net.Dialer{Timeout: s.timeout}, Config: s.tlsConfig}
+		conn, err := d.Dial("tcp", s.address)
+		return conn, stream, err
+	case "journald":
+		conn, err := net.DialTimeout("unixgram", journaldSocket, s.timeout)
+		if err != nil {
+			return nil, journal, fmt.Errorf("journald: %w", err)
+		}
+		return conn, journal, nil
+	}
+	if _, err := os.Stat(journaldSocket); err == nil {
+		if conn, err := net.DialTimeout("unixgram", journaldSocket, s.timeout); err == nil {
+			return conn, journal, nil
+		}
+	}
+	var errs []error
+	for _, path := range localSyslogSockets {
+		conn, err := net.DialTimeout("unixgram", path, s.timeout)
+		if err == nil {
+			return conn, datagram, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, datagram, fmt.Errorf("no local syslog socket: %w", errors.Join(errs...))
+}
+
+// send writes msgs over one connection.
+func (s *SyslogClient) send(msgs []syslogMessage) error {
+	conn, t, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_ = conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	now := time.Now()
+	for _, m := range msgs {
+		var b []byte
+		switch t {
+		case journal:
+			b = s.journalEntry(m)
+		case stream:
+			line := s.format(m, now)
+			b = append([]byte(strconv.Itoa(len(line))+" "), line...)
+		default:
+			b = s.format(m, now)
+		}
+		if _, err := conn.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// format renders an RFC 5424 message:
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID k="v" ...] MSG
+func (s *SyslogClient) format(m syslogMessage, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d %s ", s.facility*8+m.severity,
+		now.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		syslogHeaderField(m.hostname, 255), syslogHeaderField(s.appName, 48), os.Getpid(), syslogHeaderField(m.msgID, 32))
+	sd := false
+	for _, p := range m.params {
+		if p[1] == "" {
+			continue
+		}
+		if !sd {
+			b.WriteString("[" + syslogSDID)
+			sd = true
+		}
+		fmt.Fprintf(&b, ` %s="%s"`, p[0], sdEscaper.Replace(p[1]))
+	}
+	if sd {
+		b.WriteString("]")
+	} else {
+		b.WriteString("-")
+	}
+	b.WriteString(" " + m.text)
+	return b.Bytes()
+}
+
+// sdEscaper escapes a structured data value (RFC 5424 section 6.3.3).
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// syslogHeaderField makes s a valid header field: printable ASCII with
+// no spaces, at most max long, "-" when empty.
+func syslogHeaderField(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	if len(s) > max {
+		s = s[:max]
+	}
+	return s
+}
+
+// journalEntry encodes m in journald's native protocol: KEY=value lines,
+// with a length-prefixed value where it contains a newline. Structured
+// data become COMPLIANCE_* fields.
+func (s *SyslogClient) journalEntry(m syslogMessage) []byte {
+	var b bytes.Buffer
+	field := func(k, v string) {
+		if !strings.Contains(v, "\n") {
+			b.WriteString(k + "=" + v + "\n")
+			return
+		}
+		b.WriteString(k + "\n")
+		_ = binary.Write(&b, binary.LittleEndian, uint64(len(v)))
+		b.WriteString(v + "\n")
+	}
+	field("MESSAGE", m.text)
+	field("PRIORITY", strconv.Itoa(m.severity))
+	field("SYSLOG_FACILITY", strconv.Itoa(s.facility))
+	field("SYSLOG_IDENTIFIER", s.appName)
+	field("COMPLIANCE_EVENT", m.msgID)
+	field("COMPLIANCE_HOSTNAME", m.hostname)
+	for _, p := range m.params {
+		if p[1] != "" {
+			field("COMPLIANCE_"+strings.ToUpper(p[0]), p[1])
+		}
+	}
+	return b.Bytes()
+}
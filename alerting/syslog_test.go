@@ -0,0 +1,159 @@
+package alerting
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var syslogViolations = []analyzer.Violation{
+	{Category: "user", Severity: analyzer.SeverityCritical, Message: "unexpected user present: eve"},
+	{Category: "cis", Severity: analyzer.SeverityLow, Control: "5.2.7", Message: `sshd: "PermitRootLogin" is [yes]`},
+}
+
+func TestSyslog_UDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	alerters, err := Build(config.AlertConfig{
+		Enabled: []string{"syslog"},
+		Syslog:  config.SyslogAlertConfig{Network: "udp", Address: pc.LocalAddr().String(), Facility: "auth"},
+	})
+	require.NoError(t, err)
+	s := alerters[0]
+	require.NoError(t, s.Test())
+	require.NoError(t, s.SendViolations("web-1", syslogViolations))
+
+	read := func() string {
+		buf := make([]byte, 4096)
+		_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+	// auth (4) * 8 + crit (2)
+	first := read()
+	assert.True(t, strings.HasPrefix(first, "<34>1 "), first)
+	fields := strings.SplitN(first, " ", 7)
+	assert.Equal(t, []string{"web-1", "compliance-agent", strconv.Itoa(os.Getpid()), "violation"}, fields[2:6])
+	assert.Equal(t, `[compliance@32473 category="user" severity="critical" fingerprint="`+Fingerprint("web-1", syslogViolations[0])+`"] unexpected user present: eve`, fields[6])
+
+	second := read()
+	assert.True(t, strings.HasPrefix(second, "<37>1 "), "low is notice")
+	assert.Contains(t, second, `control="5.2.7"`)
+	assert.Contains(t, second, `] sshd: "PermitRootLogin" is [yes]`)
+
+	require.NoError(t, SendTest(s, "web-1"))
+	test := read()
+	assert.True(t, strings.HasPrefix(test, "<38>1 "), "informational")
+	assert.Contains(t, test, " test - compliance-agent test message")
+}
+
+func TestSyslog_TCPFraming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	got := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var msgs []string
+		for {
+			n, err := r.ReadString(' ')
+			if err != nil {
+				break
+			}
+			size, _ := strconv.Atoi(strings.TrimSpace(n))
+			buf := make([]byte, size)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				break
+			}
+			msgs = append(msgs, string(buf))
+		}
+		got <- msgs
+	}()
+
+	c, err := NewSyslogClient(config.SyslogAlertConfig{Network: "tcp", Address: ln.Addr().String(), Events: []string{"report", "resolved"}, MinSeverity: "high"})
+	require.NoError(t, err)
+	require.NoError(t, c.SendViolations("web-1", syslogViolations), "violations event is off")
+	require.NoError(t, c.SendResolved("web-1", syslogViolations))
+
+	msgs := <-got
+	require.Len(t, msgs, 1, "low is below min_severity")
+	assert.True(t, strings.HasPrefix(msgs[0], "<133>1 "), "local0 notice: %s", msgs[0])
+	assert.Contains(t, msgs[0], " resolved [compliance@32473 ")
+	assert.True(t, strings.HasSuffix(msgs[0], "] resolved: unexpected user present: eve"))
+}
+
+func TestSyslog_Journald(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no unix datagram sockets")
+	}
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	pc, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	defer pc.Close()
+	old := journaldSocket
+	journaldSocket = path
+	defer func() { journaldSocket = old }()
+
+	c, err := NewSyslogClient(config.SyslogAlertConfig{})
+	require.NoError(t, err)
+	require.NoError(t, c.SendReport(ComplianceReport{Hostname: "web-1", Violations: syslogViolations}))
+
+	buf := make([]byte, 4096)
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := pc.Read(buf)
+	require.NoError(t, err)
+	entry := string(buf[:n])
+	assert.Contains(t, entry, "MESSAGE=compliance scan of web-1: 2 violations (1 critical, 1 low)\n")
+	assert.Contains(t, entry, "PRIORITY=5\n")
+	assert.Contains(t, entry, "SYSLOG_FACILITY=16\n")
+	assert.Contains(t, entry, "SYSLOG_IDENTIFIER=compliance-agent\n")
+	assert.Contains(t, entry, "COMPLIANCE_EVENT=report\n")
+	assert.Contains(t, entry, "COMPLIANCE_CRITICAL=1\n")
+
+	// A value with a newline is length-prefixed.
+	b := c.journalEntry(syslogMessage{msgID: "violation", text: "a\nb"})
+	assert.True(t, strings.HasPrefix(string(b), "MESSAGE\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\n"))
+}
+
+func TestSyslog_Config(t *testing.T) {
+	t.Setenv("SYSLOG_ADDRESS", "")
+	for _, cfg := range []config.SyslogAlertConfig{
+		{Network: "udp"},
+		{Network: "carrier-pigeon"},
+		{Facility: "local9"},
+		{Events: []string{"scan"}},
+		{MinSeverity: "urgent"},
+		{Network: "tls", Address: "logs:6514", CertFile: "client.pem"},
+	} {
+		_, err := NewSyslogClient(cfg)
+		assert.Error(t, err, "%+v", cfg)
+	}
+	t.Setenv("SYSLOG_ADDRESS", "logs.example.com:6514")
+	c, err := NewSyslogClient(config.SyslogAlertConfig{Network: "tls"})
+	require.NoError(t, err)
+	assert.Equal(t, "logs.example.com:6514", c.address)
+
+	assert.Equal(t, "-", syslogHeaderField("", 10))
+	assert.Equal(t, "web_1", syslogHeaderField("web 1", 10))
+	assert.Equal(t, `a\"b\]\\`, sdEscaper.Replace(`a"b]\`))
+}
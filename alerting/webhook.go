@@ -0,0 +1,312 @@
+package alerting
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+)
+
+// DefaultWebhookSignatureHeader carries the body's HMAC-SHA256 when a
+// secret is configured.
+const DefaultWebhookSignatureHeader = "X-Compliance-Signature"
+
+func init() {
+	Register("webhook", func(cfg config.AlertConfig) (Alerter, error) {
+		c, err := NewWebhookClient(cfg.Webhook)
+		if err != nil {
+			return nil, err
+		}
+		if c.links, err = NewLinks(cfg.Links); err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+}
+
+// WebhookClient POSTs reports and violations to an arbitrary endpoint,
+// for in-house systems that have no backend of their own.
+type WebhookClient struct {
+	url         string
+	headers     map[string]string
+	tmpl        *template.Template
+	secret      []byte
+	sigHeader   string
+	events      []string
+	minSeverity analyzer.Severity
+	allowHTTP   bool
+	client      *http.Client
+	links       *Links
+}
+
+// WebhookEvent is the data a body template renders. Report is set for
+// "report" events only.
+type WebhookEvent struct {
+	Event       string               `json:"event"` // "report" | "violations" | "resolved" | "test"
+	Hostname    string               `json:"hostname"`
+	GeneratedAt time.Time            `json:"generated_at"`
+	Severity    analyzer.Severity    `json:"severity,omitempty"` // worst present
+	Violations  []analyzer.Violation `json:"violations"`
+	Report      *ComplianceReport    `json:"report,omitempty"`
+	// Links lead back to the fleet server when alerting.links (or
+	// central.url) is set.
+	Links *WebhookLinks `json:"links,omitempty"`
+}
+
+// WebhookLinks are an event's links: the host's page, and Findings with
+// one entry per violation, in the same order as Violations.
+type WebhookLinks struct {
+	Host     string         `json:"host,omitempty"`
+	Findings []FindingLinks `json:"findings,omitempty"`
+}
+
+// webhookFuncs are available in body templates. json is the one to reach
+// for: it quotes strings, so a message can't break the body.
+var webhookFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join":  strings.Join,
+	"count": func(sev string, vs []analyzer.Violation) int {
+		n := 0
+		for _, v := range vs {
+			if string(severityOf(v)) == sev {
+				n++
+			}
+		}
+		return n
+	},
+}
+
+// NewWebhookClient builds a client from config, falling back to the
+// WEBHOOK_URL and WEBHOOK_SECRET environment variables. The template is
+// parsed here so a mistake fails at startup, not at the first alert.
+func NewWebhookClient(cfg config.WebhookAlertConfig) (*WebhookClient, error) {
+	c := &WebhookClient{
+		url:       cfg.URL,
+		headers:   cfg.Headers,
+		secret:    []byte(cfg.Secret),
+		sigHeader: cfg.SignatureHeader,
+		events:    cfg.Events,
+		allowHTTP: cfg.AllowHTTP,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+	if c.url == "" {
+		c.url = os.Getenv("WEBHOOK_URL")
+	}
+	if len(c.secret) == 0 {
+		c.secret = []byte(os.Getenv("WEBHOOK_SECRET"))
+	}
+	if c.sigHeader == "" {
+		c.sigHeader = DefaultWebhookSignatureHeader
+	}
+	if cfg.Timeout > 0 {
+		c.client.Timeout = cfg.Timeout
+	}
+	if len(c.events) == 0 {
+		c.events = []string{"report", "violations", "resolved"}
+	}
+	for _, e := range c.events {
+		if e != "report" && e != "violations" && e != "resolved" {
+			return nil, fmt.Errorf("events: unknown event %q (want report, violations or resolved)", e)
+		}
+	}
+	if cfg.MinSeverity != "" {
+		sev, err := analyzer.ParseSeverity(cfg.MinSeverity)
+		if err != nil {
+			return nil, fmt.Errorf("min_severity: %w", err)
+		}
+		c.minSeverity = sev
+	}
+
+	src := cfg.Template
+	if cfg.TemplateFile != "" {
+		if src != "" {
+			return nil, errors.New("set template or template_file, not both")
+		}
+		b, err := os.ReadFile(cfg.TemplateFile)
+		if err != nil {
+			return nil, fmt.Errorf("template_file: %w", err)
+		}
+		src = string(b)
+	}
+	if src != "" {
+		t, err := template.New("webhook").Funcs(webhookFuncs).Option("missingkey=error").Parse(src)
+		if err != nil {
+			return nil, fmt.Errorf("template: %w", err)
+		}
+		c.tmpl = t
+	}
+	return c, nil
+}
+
+// Name implements Alerter.
+func (w *WebhookClient) Name() string { return "webhook" }
+
+// Test implements Alerter. An arbitrary endpoint may not tolerate a test
+// event, so this only checks configuration.
+func (w *WebhookClient) Test() error {
+	if w.url == "" {
+		return errors.New("WEBHOOK_URL not configured")
+	}
+	u, err := url.Parse(w.url)
+	if err != nil {
+		return fmt.Errorf("webhook url: %w", err)
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && w.allowHTTP) {
+		return fmt.Errorf("webhook url %s: must be https (set allow_http for a local receiver)", u.Redacted())
+	}
+	return nil
+}
+
+// SendTest implements TestSender with a "test" event and no
+// violations, whatever events are enabled.
+func (w *WebhookClient) SendTest(hostname string) error {
+	return w.post(WebhookEvent{
+		Event:       "test",
+		Hostname:    hostname,
+		GeneratedAt: time.Now().UTC(),
+		Violations:  []analyzer.Violation{},
+	})
+}
+
+// SendReport implements Alerter.
+func (w *WebhookClient) SendReport(report ComplianceReport) error {
+	if !slices.Contains(w.events, "report") {
+		return nil
+	}
+	return w.post(WebhookEvent{
+		Event:       "report",
+		Hostname:    report.Hostname,
+		GeneratedAt: report.GeneratedAt,
+		Severity:    highestSeverity(report.Violations),
+		Violations:  nonNil(report.Violations),
+		Report:      &report,
+		Links:       w.eventLinks(report.Hostname, report.Violations),
+	})
+}
+
+// SendViolations implements Alerter. Violations below min_severity are
+// dropped; nothing is sent when none are left.
+func (w *WebhookClient) SendViolations(hostname string, violations []analyzer.Violation) error {
+	return w.sendViolationEvent("violations", hostname, violations)
+}
+
+// SendResolved implements Resolver with a "resolved" event listing the
+// violations that are gone, filtered by min_severity like violations.
+func (w *WebhookClient) SendResolved(hostname string, resolved []analyzer.Violation) error {
+	return w.sendViolationEvent("resolved", hostname, resolved)
+}
+
+func (w *WebhookClient) sendViolationEvent(event, hostname string, violations []analyzer.Violation) error {
+	if !slices.Contains(w.events, event) {
+		return nil
+	}
+	var vs []analyzer.Violation
+	for _, v := range violations {
+		if w.minSeverity == "" || severityOf(v).Rank() >= w.minSeverity.Rank() {
+			vs = append(vs, v)
+		}
+	}
+	if len(vs) == 0 {
+		return nil
+	}
+	return w.post(WebhookEvent{
+		Event:       event,
+		Hostname:    hostname,
+		GeneratedAt: time.Now().UTC(),
+		Severity:    highestSeverity(vs),
+		Violations:  vs,
+		Links:       w.eventLinks(hostname, vs),
+	})
+}
+
+// eventLinks returns the links for an event, nil without any.
+func (w *WebhookClient) eventLinks(hostname string, vs []analyzer.Violation) *WebhookLinks {
+	l := &WebhookLinks{Host: w.links.Host(hostname), Findings: w.links.Findings(hostname, vs)}
+	if l.Host == "" && l.Findings == nil {
+		return nil
+	}
+	return l
+}
+
+// render produces the request body: the template's output, which must be
+// valid JSON, or the event itself.
+func (w *WebhookClient) render(ev WebhookEvent) ([]byte, error) {
+	if w.tmpl == nil {
+		return json.Marshal(ev)
+	}
+	var buf bytes.Buffer
+	if err := w.tmpl.Execute(&buf, ev); err != nil {
+		return nil, fmt.Errorf("template: %w", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, errors.New("template did not render valid JSON (use the json function to quote values)")
+	}
+	return buf.Bytes(), nil
+}
+
+// sign returns the signature header value for body: "sha256=" and the
+// hex HMAC-SHA256, as GitHub signs its webhooks.
+func (w *WebhookClient) sign(body []byte) string {
+	mac := hmac.New(sha256.New, w.secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *WebhookClient) post(ev WebhookEvent) error {
+	if err := w.Test(); err != nil {
+		return err
+	}
+	body, err := w.render(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "compliance-agent")
+	req.Header.Set("X-Compliance-Event", ev.Event)
+	for k, v := range w.headers {
+		req.Header.Set(k, os.ExpandEnv(v))
+	}
+	if len(w.secret) > 0 {
+		req.Header.Set(w.sigHeader, w.sign(body))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var msg bytes.Buffer
+		_, _ = msg.ReadFrom(resp.Body)
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(msg.String()))
+	}
+	return nil
+}
+
+func nonNil(vs []analyzer.Violation) []analyzer.Violation {
+	if vs == nil {
+		return []analyzer.Violation{}
+	}
+	return vs
+}
@@ -0,0 +1,108 @@
+package alerting
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook_TemplateHeadersAndSignature(t *testing.T) {
+	t.Setenv("TICKET_TOKEN", "tok")
+	var got []*http.Request
+	var bodies [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = append(got, r)
+		bodies = append(bodies, b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	alerters, err := Build(config.AlertConfig{
+		Enabled: []string{"webhook"},
+		Webhook: config.WebhookAlertConfig{
+			URL:         srv.URL,
+			AllowHTTP:   true,
+			Events:      []string{"violations"},
+			Headers:     map[string]string{"Authorization": "Bearer ${TICKET_TOKEN}"},
+			Secret:      "s3cret",
+			MinSeverity: "high",
+			Template: `{"host": {{json .Hostname}}, "worst": {{json .Severity}}, "critical": {{count "critical" .Violations}},
+"items": [{{range $i, $v := .Violations}}{{if $i}},{{end}}{{json $v.Message}}{{end}}]}`,
+		},
+	})
+	require.NoError(t, err)
+	w := alerters[0]
+	require.NoError(t, w.Test())
+
+	require.NoError(t, w.SendReport(ComplianceReport{Hostname: "web-1"}), "report events not enabled")
+	require.NoError(t, w.SendViolations("web-1", []analyzer.Violation{{Category: "port", Severity: analyzer.SeverityLow, Message: "port 8080"}}))
+	assert.Empty(t, got, "nothing at or above min_severity")
+
+	require.NoError(t, w.SendViolations("web-1", []analyzer.Violation{
+		{Category: "user", Severity: analyzer.SeverityCritical, Message: `user "eve" present`},
+		{Category: "port", Severity: analyzer.SeverityHigh, Message: "port 23"},
+		{Category: "port", Severity: analyzer.SeverityLow, Message: "port 8080"},
+	}))
+	require.Len(t, got, 1)
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(bodies[0], &body))
+	assert.Equal(t, map[string]any{
+		"host": "web-1", "worst": "critical", "critical": float64(1),
+		"items": []any{`user "eve" present`, "port 23"},
+	}, body)
+	assert.Equal(t, "Bearer tok", got[0].Header.Get("Authorization"))
+	assert.Equal(t, "violations", got[0].Header.Get("X-Compliance-Event"))
+	assert.Equal(t, w.(*WebhookClient).sign(bodies[0]), got[0].Header.Get(DefaultWebhookSignatureHeader))
+}
+
+func TestWebhook_DefaultBody(t *testing.T) {
+	var ev WebhookEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get(DefaultWebhookSignatureHeader))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+	}))
+	defer srv.Close()
+
+	t.Setenv("WEBHOOK_SECRET", "")
+	w, err := NewWebhookClient(config.WebhookAlertConfig{URL: srv.URL, AllowHTTP: true})
+	require.NoError(t, err)
+	require.NoError(t, w.SendReport(ComplianceReport{Hostname: "web-1", OpenPorts: []int{22}}))
+	assert.Equal(t, "report", ev.Event)
+	assert.Equal(t, []int{22}, ev.Report.OpenPorts)
+	assert.NotNil(t, ev.Violations)
+
+	ev = WebhookEvent{}
+	require.NoError(t, SendTest(w, "web-1"))
+	assert.Equal(t, "test", ev.Event)
+	assert.Equal(t, "web-1", ev.Hostname)
+	assert.Nil(t, ev.Report)
+	assert.Empty(t, ev.Violations)
+}
+
+func TestWebhook_Config(t *testing.T) {
+	t.Setenv("WEBHOOK_URL", "")
+	_, err := NewWebhookClient(config.WebhookAlertConfig{Template: "{{.Nope"})
+	assert.ErrorContains(t, err, "template")
+	_, err = NewWebhookClient(config.WebhookAlertConfig{Events: []string{"everything"}})
+	assert.Error(t, err)
+
+	w, err := NewWebhookClient(config.WebhookAlertConfig{})
+	require.NoError(t, err)
+	assert.Error(t, w.Test(), "no URL")
+	w, err = NewWebhookClient(config.WebhookAlertConfig{URL: "http://example.com/hook"})
+	require.NoError(t, err)
+	assert.ErrorContains(t, w.Test(), "https")
+
+	w, err = NewWebhookClient(config.WebhookAlertConfig{URL: "https://example.com/hook", Template: `{"host": {{.Hostname}}}`})
+	require.NoError(t, err)
+	_, err = w.render(WebhookEvent{Hostname: "web 1"})
+	assert.ErrorContains(t, err, "valid JSON")
+}
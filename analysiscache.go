@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"compliance-agent/analyzer"
+)
+
+// analysisCache keeps each analyzer's last result keyed by a hash of its
+// input and the policy, so a daemon scanning a stable host every few
+// minutes doesn't re-evaluate rules over identical data. It lives as long
+// as the scanner; nothing is persisted.
+type analysisCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedAnalysis
+}
+
+type cachedAnalysis struct {
+	hash       [sha256.Size]byte
+	violations []analyzer.Violation
+	// since is when the result was computed: the first run that saw
+	// this input.
+	since time.Time
+}
+
+// analysisCacheMaxAge bounds how long a result is reused, so checks that
+// read the clock (Rego's time.now_ns, a script's times module) are still
+// re-evaluated on a host that never changes.
+const analysisCacheMaxAge = time.Hour
+
+func newAnalysisCache() *analysisCache {
+	return &analysisCache{entries: map[string]cachedAnalysis{}}
+}
+
+// inputHash hashes an analyzer's input together with the policy. ok is
+// false when the input can't be encoded, which disables caching for it.
+func inputHash(policy []byte, input any) (sum [sha256.Size]byte, ok bool) {
+	h := sha256.New()
+	h.Write(policy)
+	if err := json.NewEncoder(h).Encode(input); err != nil {
+		return sum, false
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, true
+}
+
+// sourcesHash hashes the files and directories Rego or scripts are
+// loaded from, so editing a module in place misses the cache even though
+// the policy naming it is unchanged. ok is false when one can't be read,
+// which disables caching for that analyzer.
+func sourcesHash(paths ...string) (sum [sha256.Size]byte, ok bool) {
+	h := sha256.New()
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%s\x00%d\x00", path, len(b))
+			h.Write(b)
+			return nil
+		})
+		if err != nil {
+			return sum, false
+		}
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, true
+}
+
+// lookup returns the cached result for subsystem if its input hash is
+// unchanged and it was computed less than analysisCacheMaxAge before now.
+func (c *analysisCache) lookup(subsystem string, hash [sha256.Size]byte, now time.Time) (cachedAnalysis, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[subsystem]
+	return e, ok && e.hash == hash && now.Sub(e.since) < analysisCacheMaxAge
+}
+
+func (c *analysisCache) store(subsystem string, hash [sha256.Size]byte, v []analyzer.Violation, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[subsystem] = cachedAnalysis{hash: hash, violations: append([]analyzer.Violation(nil), v...), since: at}
+}
@@ -0,0 +1,67 @@
+//go:build !no_scripts && !slim
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/collector"
+	"compliance-agent/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scanAt is a report of the same host as collected at a later scan:
+// identical datasets, but its own time, health, errors and metadata.
+func scanAt(at time.Time) report.ComplianceReport {
+	return report.ComplianceReport{
+		GeneratedAt: at,
+		Hostname:    "web-1",
+		Platform:    "linux",
+		Users:       []collector.User{{Username: "root", UID: 0}},
+		Health:      &report.AgentHealth{StartedAt: at.Add(-time.Hour), UptimeSeconds: int64(at.Second()) + 3600},
+		Errors:      []report.RunError{{Stage: "notify", Subsystem: "slack", Message: at.String()}},
+		ExtraMetadata: map[string]interface{}{
+			"ml": map[string]any{"score": at.Second()},
+		},
+	}
+}
+
+func unchanged(rep report.ComplianceReport) []string {
+	var names []string
+	for _, u := range rep.Unchanged {
+		names = append(names, u.Subsystem)
+	}
+	return names
+}
+
+func TestAnalyze_ScriptCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.tengo")
+	require.NoError(t, os.WriteFile(path, []byte(`violations = ["first"]`), 0o644))
+	policies := analyzer.Policies{Scripts: []analyzer.Script{{Name: "users", Path: path}}}
+	cache := newAnalysisCache()
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	first := scanAt(t0)
+	analyze(&first, policies, analyzer.RiskModel{}, cache)
+	assert.NotContains(t, unchanged(first), "scripts")
+
+	second := scanAt(t0.Add(5 * time.Minute))
+	analyze(&second, policies, analyzer.RiskModel{}, cache)
+	assert.Contains(t, unchanged(second), "scripts", "only the scan's time, health, errors and metadata changed")
+	assert.Equal(t, first.Violations, second.Violations)
+
+	require.NoError(t, os.WriteFile(path, []byte(`violations = ["second"]`), 0o644))
+	third := scanAt(t0.Add(10 * time.Minute))
+	analyze(&third, policies, analyzer.RiskModel{}, cache)
+	assert.NotContains(t, unchanged(third), "scripts", "the script file was edited")
+	var messages []string
+	for _, v := range third.Violations {
+		messages = append(messages, v.Message)
+	}
+	assert.Contains(t, messages, "second")
+}
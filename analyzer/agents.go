@@ -0,0 +1,116 @@
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// RequiredAgent is a third-party agent (backup client, EDR, MDM) the host
+// must have installed, optionally at a given version and with known
+// config files.
+type RequiredAgent struct {
+	Name string `yaml:"name"`
+	// Binaries and Packages identify the install; at least one must be
+	// set. The version comes from the first installed package.
+	Binaries []string `yaml:"binaries"`
+	Packages []string `yaml:"packages"`
+	// Processes, when set, must be running (substring match).
+	Processes []string `yaml:"processes"`
+	// Version pins an exact version; MinVersion sets a floor.
+	Version    string `yaml:"version"`
+	MinVersion string `yaml:"min_version"`
+	// Configs are files whose SHA-256 must match.
+	Configs []AgentConfig `yaml:"configs"`
+}
+
+// AgentConfig is an expected config file fingerprint. With no SHA256 the
+// file only has to exist and be readable.
+type AgentConfig struct {
+	Path   string `yaml:"path"`
+	SHA256 string `yaml:"sha256"`
+}
+
+var sha256RE = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// AgentSpecs turns the policy's required agents into what the collector
+// looks for.
+func AgentSpecs(agents []RequiredAgent) []collector.AgentSpec {
+	var out []collector.AgentSpec
+	for _, a := range agents {
+		spec := collector.AgentSpec{Name: a.Name, Binaries: a.Binaries, Packages: a.Packages, Processes: a.Processes}
+		for _, c := range a.Configs {
+			spec.ConfigFiles = append(spec.ConfigFiles, c.Path)
+		}
+		out = append(out, spec)
+	}
+	return out
+}
+
+// AnalyzeRequiredAgents reports drift per required agent: not installed,
+// not running, the wrong version, or config files that don't match their
+// expected hashes. An agent that isn't installed is only reported as
+// missing.
+func AnalyzeRequiredAgents(states []collector.AgentState, policies Policies) []Violation {
+	found := map[string]collector.AgentState{}
+	for _, st := range states {
+		found[st.Name] = st
+	}
+	var v []Violation
+	add := func(category, msg string) {
+		v = append(v, Violation{
+			Category: category,
+			Severity: policies.severityFor(category),
+			Message:  msg,
+		})
+	}
+	for _, want := range policies.RequiredAgents {
+		st, ok := found[want.Name]
+		if !ok {
+			// Not collected (e.g. a saved report from before the
+			// agent was added to the policy).
+			continue
+		}
+		if st.Binary == "" && st.Package == "" {
+			add("component_missing", fmt.Sprintf("required agent %s is not installed", want.Name))
+			continue
+		}
+		if st.Running != nil && !*st.Running {
+			add("component_not_running", fmt.Sprintf("required agent %s is not running (%s)", want.Name, strings.Join(want.Processes, ", ")))
+		}
+		if want.Version != "" || want.MinVersion != "" {
+			switch {
+			case st.Version == "":
+				add("component_version", fmt.Sprintf("required agent %s version is unknown (no installed package among %s)", want.Name, strings.Join(want.Packages, ", ")))
+			case want.Version != "" && collector.CompareVersions(st.Version, want.Version) != 0:
+				add("component_version", fmt.Sprintf("required agent %s is version %s, expected %s", want.Name, st.Version, want.Version))
+			case want.MinVersion != "" && collector.CompareVersions(st.Version, want.MinVersion) < 0:
+				add("component_version", fmt.Sprintf("required agent %s is version %s, older than %s", want.Name, st.Version, want.MinVersion))
+			}
+		}
+		hashes := map[string]collector.ConfigHash{}
+		for _, c := range st.Configs {
+			hashes[c.Path] = c
+		}
+		for _, c := range want.Configs {
+			got, ok := hashes[c.Path]
+			switch {
+			case !ok:
+			case got.Error != "":
+				add("component_config", fmt.Sprintf("required agent %s config %s: %s", want.Name, c.Path, got.Error))
+			case c.SHA256 != "" && !strings.EqualFold(got.SHA256, c.SHA256):
+				add("component_config", fmt.Sprintf("required agent %s config %s has drifted (sha256 %s, expected %s)", want.Name, c.Path, shortHash(got.SHA256), shortHash(c.SHA256)))
+			}
+		}
+	}
+	return v
+}
+
+func shortHash(h string) string {
+	if len(h) > 12 {
+		return h[:12]
+	}
+	return h
+}
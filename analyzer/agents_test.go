@@ -0,0 +1,77 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeRequiredAgents(t *testing.T) {
+	p, err := ParsePolicies([]byte(`
+required_agents:
+  - name: falcon
+    binaries: [/opt/CrowdStrike/falcond]
+    packages: [falcon-sensor]
+    processes: [falcond]
+    min_version: "7.11"
+    configs:
+      - path: /etc/falcon.conf
+        sha256: ` + strings.Repeat("a", 64) + `
+      - path: /etc/falcon-extra.conf
+  - name: backup
+    packages: [restic]
+    version: "0.16.4"
+  - name: mdm
+    binaries: [/usr/local/bin/mdmclient]
+`))
+	require.NoError(t, err)
+	running := false
+	states := []collector.AgentState{
+		{
+			Name: "falcon", Binary: "/opt/CrowdStrike/falcond", Package: "falcon-sensor", Version: "7.10.17706", Running: &running,
+			Configs: []collector.ConfigHash{
+				{Path: "/etc/falcon.conf", SHA256: strings.Repeat("b", 64)},
+				{Path: "/etc/falcon-extra.conf", Error: "open /etc/falcon-extra.conf: no such file or directory"},
+			},
+		},
+		{Name: "backup", Package: "restic", Version: "0.16.4"},
+		{Name: "mdm"},
+	}
+	v := AnalyzeRequiredAgents(states, p)
+	var got []string
+	for _, x := range v {
+		got = append(got, x.Category+": "+x.Message)
+	}
+	assert.Equal(t, []string{
+		"component_not_running: required agent falcon is not running (falcond)",
+		"component_version: required agent falcon is version 7.10.17706, older than 7.11",
+		"component_config: required agent falcon config /etc/falcon.conf has drifted (sha256 bbbbbbbbbbbb, expected aaaaaaaaaaaa)",
+		"component_config: required agent falcon config /etc/falcon-extra.conf: open /etc/falcon-extra.conf: no such file or directory",
+		"component_missing: required agent mdm is not installed",
+	}, got)
+
+	specs := AgentSpecs(p.RequiredAgents)
+	assert.Equal(t, []string{"/etc/falcon.conf", "/etc/falcon-extra.conf"}, specs[0].ConfigFiles)
+}
+
+func TestRequiredAgentsValidation(t *testing.T) {
+	_, err := ParsePolicies([]byte(`
+required_agents:
+  - name: a
+  - name: a
+    packages: [x]
+    configs: [{path: rel.conf, sha256: xyz}]
+`))
+	require.Error(t, err)
+	for _, want := range []string{
+		"required_agents[0]: set binaries or packages",
+		`required_agents[1]: duplicate name "a"`,
+		`required_agents[1].configs[0]: "rel.conf" is not an absolute path`,
+		"required_agents[1].configs[0]: sha256 must be 64 hex digits",
+	} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
@@ -0,0 +1,154 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// AppliesTo limits a rule or script to the hosts it was written for, so
+// one policy bundle can serve a mixed fleet. Elsewhere the check doesn't
+// run and is listed as not applicable instead of passing or failing.
+//
+//	applies_to:
+//	  platforms: [ubuntu, debian]
+//	  versions: ">= 22.04"
+type AppliesTo struct {
+	// Platforms are matched against the OS's distribution ID, name or
+	// platform (linux, darwin, windows), as os_version.min_versions keys
+	// are. Empty means every OS.
+	Platforms []string `yaml:"platforms"`
+	// Versions are constraints on the OS release, e.g. ">= 13, < 15".
+	// A host whose release wasn't collected doesn't match.
+	Versions string `yaml:"versions"`
+}
+
+// Enabled reports whether the check is limited at all.
+func (a AppliesTo) Enabled() bool {
+	return len(a.Platforms) > 0 || a.Versions != ""
+}
+
+func (a AppliesTo) validate() []string {
+	var problems []string
+	problems = append(problems, checkNames("applies_to.platforms", a.Platforms)...)
+	if a.Versions != "" {
+		if _, err := parseVersionConstraints(a.Versions); err != nil {
+			problems = append(problems, fmt.Sprintf("applies_to.versions: %v", err))
+		}
+	}
+	return problems
+}
+
+// reason says why the check doesn't apply to a host on platform with
+// OS release v (nil when not collected); empty when it applies.
+func (a AppliesTo) reason(platform string, v *collector.OSVersion) string {
+	if len(a.Platforms) > 0 {
+		keys := []string{platform}
+		if v != nil {
+			keys = append(keys, v.ID, v.Name, v.Platform)
+		}
+		if !platformMatches(a.Platforms, keys) {
+			host := platform
+			if v != nil && v.ID != "" {
+				host = v.ID
+			}
+			if host == "" {
+				host = "unknown OS"
+			}
+			return fmt.Sprintf("%s is not one of %s", host, strings.Join(a.Platforms, ", "))
+		}
+	}
+	if a.Versions != "" {
+		if v == nil || v.Version == "" {
+			return "OS version unknown, wanted " + a.Versions
+		}
+		cs, err := parseVersionConstraints(a.Versions)
+		if err != nil {
+			return "invalid applies_to.versions" // rejected by Validate
+		}
+		for _, c := range cs {
+			if !c.allows(v.Version) {
+				return fmt.Sprintf("OS version %s does not meet %s", v.Version, a.Versions)
+			}
+		}
+	}
+	return ""
+}
+
+func platformMatches(names, keys []string) bool {
+	for _, n := range names {
+		for _, k := range keys {
+			if k != "" && strings.EqualFold(n, k) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NotApplicable records a check that didn't run because the host isn't
+// one it applies to. Reports list these as "N/A", so a check that was
+// never evaluated isn't mistaken for one that passed.
+type NotApplicable struct {
+	// Kind is "rule" or "script", or "profile" for a benchmark profile
+	// left out of an image scan.
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Applicable returns the policy with only the rules and scripts that
+// apply to a host on platform with OS release v, and the ones left out.
+// A rule that depends on one left out is left out too, rather than
+// skipped as if its dependency had failed.
+func (p Policies) Applicable(platform string, v *collector.OSVersion) (Policies, []NotApplicable) {
+	var na []NotApplicable
+	out := p
+	out.Rules = nil
+	out.Scripts = nil
+	dropped := map[string]bool{}
+	order, _ := ruleOrder(p.Rules)
+	for _, r := range order {
+		why := r.AppliesTo.reason(platform, v)
+		for _, d := range r.DependsOn {
+			if why == "" && dropped[d] {
+				why = fmt.Sprintf("depends on %s, which is not applicable", d)
+			}
+		}
+		if why != "" {
+			dropped[r.Name] = true
+			na = append(na, NotApplicable{Kind: "rule", Name: r.Name, Reason: why})
+		}
+	}
+	// Keep policy order for the rules that remain.
+	for _, r := range p.Rules {
+		if !dropped[r.Name] {
+			out.Rules = append(out.Rules, r)
+		}
+	}
+	for _, s := range p.Scripts {
+		if why := s.AppliesTo.reason(platform, v); why != "" {
+			na = append(na, NotApplicable{Kind: "script", Name: s.Name, Reason: why})
+			continue
+		}
+		out.Scripts = append(out.Scripts, s)
+	}
+	return out, na
+}
+
+// NeedsOSVersion reports whether any rule or script is limited, so the
+// OS release must be collected to decide.
+func (p Policies) NeedsOSVersion() bool {
+	for _, r := range p.Rules {
+		if r.AppliesTo.Enabled() {
+			return true
+		}
+	}
+	for _, s := range p.Scripts {
+		if s.AppliesTo.Enabled() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicies_Applicable(t *testing.T) {
+	p, err := ParsePolicies([]byte(`
+rules:
+  - name: auditd-missing
+    target: host
+    expr: '!host.processes.exists(p, p.name == "auditd")'
+    applies_to: {platforms: [linux]}
+  - name: ufw-missing
+    target: host
+    expr: "true"
+    applies_to: {platforms: [ubuntu], versions: ">= 22.04"}
+  - name: ufw-old
+    target: package
+    expr: pkg.name == "ufw"
+    depends_on: [ufw-missing]
+  - name: everywhere
+    target: host
+    expr: "true"
+`))
+	require.NoError(t, err)
+	// Scripts may be compiled out, so this one isn't validated.
+	p.Scripts = []Script{{Name: "gatekeeper", Source: "violations = []", AppliesTo: AppliesTo{Platforms: []string{"darwin"}, Versions: ">= 13, < 15"}}}
+	assert.True(t, p.NeedsOSVersion())
+
+	names := func(p Policies) []string {
+		var n []string
+		for _, r := range p.Rules {
+			n = append(n, r.Name)
+		}
+		for _, s := range p.Scripts {
+			n = append(n, s.Name)
+		}
+		return n
+	}
+
+	ubuntu := &collector.OSVersion{Platform: "linux", ID: "ubuntu", Name: "Ubuntu", Version: "22.04"}
+	got, na := p.Applicable("linux", ubuntu)
+	assert.Equal(t, []string{"auditd-missing", "ufw-missing", "ufw-old", "everywhere"}, names(got))
+	assert.Equal(t, []NotApplicable{{Kind: "script", Name: "gatekeeper", Reason: "ubuntu is not one of darwin"}}, na)
+
+	ubuntu.Version = "20.04.6"
+	got, na = p.Applicable("linux", ubuntu)
+	assert.Equal(t, []string{"auditd-missing", "everywhere"}, names(got))
+	assert.Equal(t, []NotApplicable{
+		{Kind: "rule", Name: "ufw-missing", Reason: "OS version 20.04.6 does not meet >= 22.04"},
+		{Kind: "rule", Name: "ufw-old", Reason: "depends on ufw-missing, which is not applicable"},
+		{Kind: "script", Name: "gatekeeper", Reason: "ubuntu is not one of darwin"},
+	}, na)
+
+	got, na = p.Applicable("darwin", &collector.OSVersion{Platform: "darwin", ID: "darwin", Version: "14.4.1"})
+	assert.Equal(t, []string{"everywhere", "gatekeeper"}, names(got))
+	assert.Len(t, na, 3)
+
+	// Without the release, version-limited checks don't apply rather
+	// than guess.
+	_, na = p.Applicable("darwin", nil)
+	assert.Contains(t, na, NotApplicable{Kind: "script", Name: "gatekeeper", Reason: "OS version unknown, wanted >= 13, < 15"})
+
+	// The caller's policy is left as it was.
+	assert.Len(t, p.Rules, 4)
+	assert.Len(t, p.Scripts, 1)
+}
+
+func TestValidate_AppliesTo(t *testing.T) {
+	p := Policies{
+		Rules: []Rule{
+			{Name: "a", Target: "host", Expr: "true", AppliesTo: AppliesTo{Platforms: []string{"linux", ""}}},
+			{Name: "b", Target: "host", Expr: "true", AppliesTo: AppliesTo{Versions: "22.04"}},
+		},
+		Scripts: []Script{{Name: "c", Path: "/etc/c.tengo", AppliesTo: AppliesTo{Versions: ">= x"}}},
+	}
+	err := p.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rules[0].applies_to.platforms[1]: empty entry")
+	assert.Contains(t, err.Error(), `rules[1].applies_to.versions: constraint "22.04" needs an operator`)
+	assert.Contains(t, err.Error(), `scripts[0].applies_to.versions: "x" is not a version`)
+	assert.False(t, Policies{}.NeedsOSVersion())
+}
@@ -0,0 +1,101 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// ARPPolicy turns the neighbor table into a basic man-in-the-middle
+// indicator for laptops on untrusted networks.
+type ARPPolicy struct {
+	// GatewayChange flags a default gateway answering from a MAC not
+	// seen for that address in earlier scans. Networks the host has
+	// been on before (up to eight MACs per gateway address) are
+	// remembered in the baseline, so moving between home and office
+	// doesn't trip it.
+	GatewayChange bool `yaml:"gateway_change"`
+	// Duplicates flags one MAC claiming several IPs (ARP spoofing shows
+	// up as the gateway's IP sharing the attacker's MAC) and one IP
+	// resolving to several MACs.
+	Duplicates bool `yaml:"duplicates"`
+}
+
+// Enabled reports whether any ARP rule is set, which is what triggers
+// ARP collection.
+func (a ARPPolicy) Enabled() bool {
+	return a.GatewayChange || a.Duplicates
+}
+
+// AnalyzeARP applies the ARP policy.
+func AnalyzeARP(t collector.ARPTable, policies Policies) []Violation {
+	p := policies.ARP
+	var v []Violation
+	add := func(category, msg string) {
+		v = append(v, Violation{
+			Category: category,
+			Severity: policies.severityFor(category),
+			Message:  msg,
+		})
+	}
+	gw := t.Gateway
+	if p.GatewayChange && gw != nil && gw.MAC != "" && len(t.KnownGatewayMACs) > 0 {
+		known := false
+		for _, m := range t.KnownGatewayMACs {
+			known = known || m == gw.MAC
+		}
+		if !known {
+			add("gateway_mac_change", fmt.Sprintf("default gateway %s now answers from %s, previously %s", gw.IP, gw.MAC, strings.Join(t.KnownGatewayMACs, ", ")))
+		}
+	}
+	if !p.Duplicates {
+		return v
+	}
+	// Entries are per interface; the same address on two interfaces is
+	// two different networks.
+	ipsByMAC := map[string][]string{}
+	macsByIP := map[string][]string{}
+	for _, e := range t.Entries {
+		ipsByMAC[e.Interface+"|"+e.MAC] = appendUnique(ipsByMAC[e.Interface+"|"+e.MAC], e.IP)
+		macsByIP[e.Interface+"|"+e.IP] = appendUnique(macsByIP[e.Interface+"|"+e.IP], e.MAC)
+	}
+	for _, key := range sortedKeys(ipsByMAC) {
+		ips := ipsByMAC[key]
+		if len(ips) < 2 {
+			continue
+		}
+		_, mac, _ := strings.Cut(key, "|")
+		msg := fmt.Sprintf("MAC %s answers for %s", mac, strings.Join(ips, ", "))
+		if gw != nil && gw.MAC == mac {
+			msg += fmt.Sprintf(", including the default gateway %s", gw.IP)
+		}
+		add("arp_duplicate", msg)
+	}
+	for _, key := range sortedKeys(macsByIP) {
+		if macs := macsByIP[key]; len(macs) > 1 {
+			_, ip, _ := strings.Cut(key, "|")
+			add("arp_duplicate", fmt.Sprintf("IP %s resolves to several MACs: %s", ip, strings.Join(macs, ", ")))
+		}
+	}
+	return v
+}
+
+func appendUnique(xs []string, x string) []string {
+	for _, e := range xs {
+		if e == x {
+			return xs
+		}
+	}
+	return append(xs, x)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
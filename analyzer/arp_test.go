@@ -0,0 +1,56 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeARP(t *testing.T) {
+	gw := &collector.Neighbor{IP: "192.168.1.1", MAC: "aa:bb:cc:00:00:66", Interface: "wlan0"}
+	table := collector.ARPTable{
+		Entries: []collector.Neighbor{
+			{IP: "192.168.1.1", MAC: "aa:bb:cc:00:00:66", Interface: "wlan0"},
+			{IP: "192.168.1.66", MAC: "aa:bb:cc:00:00:66", Interface: "wlan0"},
+			{IP: "192.168.1.20", MAC: "aa:bb:cc:00:00:20", Interface: "wlan0"},
+			// Same address on another network isn't a duplicate.
+			{IP: "192.168.1.20", MAC: "aa:bb:cc:00:00:21", Interface: "eth0"},
+		},
+		Gateway:          gw,
+		KnownGatewayMACs: []string{"aa:bb:cc:00:00:01"},
+	}
+	p := Policies{ARP: ARPPolicy{GatewayChange: true, Duplicates: true}}
+	v := AnalyzeARP(table, p)
+	if assert.Len(t, v, 2) {
+		assert.Equal(t, "gateway_mac_change", v[0].Category)
+		assert.Equal(t, SeverityHigh, v[0].Severity)
+		assert.Equal(t, "default gateway 192.168.1.1 now answers from aa:bb:cc:00:00:66, previously aa:bb:cc:00:00:01", v[0].Message)
+		assert.Equal(t, "arp_duplicate", v[1].Category)
+		assert.Equal(t, "MAC aa:bb:cc:00:00:66 answers for 192.168.1.1, 192.168.1.66, including the default gateway 192.168.1.1", v[1].Message)
+	}
+
+	// A gateway MAC seen before, or a first scan with nothing known,
+	// is not a change.
+	table.Entries = nil
+	table.KnownGatewayMACs = []string{"aa:bb:cc:00:00:01", "aa:bb:cc:00:00:66"}
+	assert.Empty(t, AnalyzeARP(table, p))
+	table.KnownGatewayMACs = nil
+	assert.Empty(t, AnalyzeARP(table, p))
+
+	assert.Empty(t, AnalyzeARP(collector.ARPTable{Entries: []collector.Neighbor{
+		{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01"},
+		{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:02"},
+	}}, Policies{}))
+}
+
+func TestAnalyzeARPDuplicateIP(t *testing.T) {
+	v := AnalyzeARP(collector.ARPTable{Entries: []collector.Neighbor{
+		{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01"},
+		{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:02"},
+	}}, Policies{ARP: ARPPolicy{Duplicates: true}})
+	if assert.Len(t, v, 1) {
+		assert.Equal(t, "IP 10.0.0.1 resolves to several MACs: aa:bb:cc:00:00:01, aa:bb:cc:00:00:02", v[0].Message)
+		assert.Equal(t, SeverityMedium, v[0].Severity)
+	}
+}
@@ -0,0 +1,54 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// BluetoothPolicy restricts Bluetooth radios and paired peripherals.
+type BluetoothPolicy struct {
+	// RequireOff flags a powered-on controller (e.g. on servers).
+	RequireOff bool `yaml:"require_off"`
+	// RequireNotDiscoverable flags a controller in discoverable mode.
+	RequireNotDiscoverable bool `yaml:"require_not_discoverable"`
+	// DeniedDeviceTypes flags paired devices of these types: keyboard,
+	// mouse, audio, phone, other.
+	DeniedDeviceTypes []string `yaml:"denied_device_types"`
+}
+
+// Enabled reports whether any Bluetooth rule is set, which is what
+// triggers Bluetooth collection.
+func (b BluetoothPolicy) Enabled() bool {
+	return b.RequireOff || b.RequireNotDiscoverable || len(b.DeniedDeviceTypes) > 0
+}
+
+// AnalyzeBluetooth applies the Bluetooth policy.
+func AnalyzeBluetooth(st collector.BluetoothState, policies Policies) []Violation {
+	b := policies.Bluetooth
+	denied := map[string]struct{}{}
+	for _, t := range b.DeniedDeviceTypes {
+		denied[strings.ToLower(t)] = struct{}{}
+	}
+	var v []Violation
+	add := func(category, msg string) {
+		v = append(v, Violation{
+			Category: category,
+			Severity: policies.severityFor(category),
+			Message:  msg,
+		})
+	}
+	if b.RequireOff && st.Powered != nil && *st.Powered {
+		add("bluetooth", "bluetooth is powered on")
+	}
+	if b.RequireNotDiscoverable && st.Discoverable != nil && *st.Discoverable {
+		add("bluetooth", "bluetooth is discoverable")
+	}
+	for _, d := range st.PairedDevices {
+		if _, ok := denied[d.Type]; ok {
+			add("bluetooth_device", fmt.Sprintf("paired bluetooth %s not permitted: %s (%s)", d.Type, d.Name, d.Address))
+		}
+	}
+	return v
+}
@@ -0,0 +1,30 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeBluetooth(t *testing.T) {
+	on := true
+	st := collector.BluetoothState{
+		Powered:      &on,
+		Discoverable: &on,
+		PairedDevices: []collector.BluetoothDevice{
+			{Name: "Magic Keyboard", Address: "AA:BB:CC:DD:EE:01", Type: "keyboard"},
+			{Name: "AirPods", Address: "AA:BB:CC:DD:EE:02", Type: "audio"},
+		},
+	}
+	p := Policies{Bluetooth: BluetoothPolicy{
+		RequireNotDiscoverable: true,
+		DeniedDeviceTypes:      []string{"Keyboard", "mouse"},
+	}}
+	v := AnalyzeBluetooth(st, p)
+	if assert.Len(t, v, 2) {
+		assert.Equal(t, "bluetooth is discoverable", v[0].Message)
+		assert.Equal(t, "bluetooth_device", v[1].Category)
+		assert.Contains(t, v[1].Message, "Magic Keyboard")
+	}
+}
@@ -0,0 +1,90 @@
+package analyzer
+
+import "slices"
+
+// Check is one thing a policy asks of a host, at the granularity CI test
+// reports list them: a policy section, a custom rule or script, Rego, or
+// a benchmark control. A host passes a check when none of its violations
+// match it.
+type Check struct {
+	// Name is the policy key: "sshd", "rules/telnet-running",
+	// "scripts/no-world-writable", "profiles/cis-ubuntu-22.04/5.2.7".
+	Name  string
+	Title string
+	// Categories are the violation categories that fail the check and
+	// Control, for a benchmark control, the control ID they must carry.
+	Categories []string
+	Control    string
+	// Dataset is the report dataset the check reads (see package
+	// schema), if it reads a single one.
+	Dataset string
+}
+
+// Matches reports whether v is a failure of the check.
+func (c Check) Matches(v Violation) bool {
+	return slices.Contains(c.Categories, v.Category) && (c.Control == "" || v.Control == c.Control)
+}
+
+// Checks lists the checks the policy enables, in policy order. Users and
+// open ports are always checked against their allowlists.
+func (p Policies) Checks() []Check {
+	checks := []Check{
+		{Name: "allowed_users", Title: "only allowed users exist", Categories: []string{"user"}, Dataset: "users"},
+		{Name: "allowed_ports", Title: "only allowed ports listen", Categories: []string{"port"}, Dataset: "port_bindings"},
+	}
+	add := func(on bool, name, dataset, title string, categories ...string) {
+		if on {
+			checks = append(checks, Check{Name: name, Title: title, Categories: categories, Dataset: dataset})
+		}
+	}
+	add(len(p.Processes.Blocked) > 0 || p.Processes.Strict, "processes", "processes", "no blocked or unexpected processes run",
+		"process", "process_blocked")
+	add(p.ChecksPackages(), "packages", "packages", "denied packages absent, required packages present",
+		"package", "package_missing", "package_version", "package_not_allowed")
+	add(p.RequireDiskEncryption, "require_disk_encryption", "disk_encryption", "boot volume encrypted", "disk_encryption")
+	add(p.RequireFirewallEnabled, "require_firewall_enabled", "firewall", "host firewall enabled", "firewall")
+	add(len(p.RequiredAgents) > 0, "required_agents", "required_agents", "required agents installed, running and configured",
+		"component_missing", "component_not_running", "component_version", "component_config")
+	add(p.Workstation.Enabled(), "workstation", "accounts", "workstation accounts configured safely",
+		"screen_lock", "browser_extension", "authorized_keys", "login_item")
+	add(p.Laptop.Enabled(), "laptop", "power", "sleep and hibernation secured",
+		"sleep_on_lid_close", "password_after_sleep", "hibernation_encryption")
+	add(p.Sharing.Prohibited, "sharing", "sharing", "no unapproved sharing services", "sharing")
+	add(p.OSVersion.Enabled(), "os_version", "os_version", "OS release and kernel at or above the minimum", "os_version", "kernel_version")
+	add(p.SSHD.Enabled(), "sshd", "sshd", "SSH server configured as required",
+		"ssh_protocol", "ssh_root_login", "ssh_password_auth", "ssh_max_auth_tries", "ssh_config")
+	add(p.Bluetooth.Enabled(), "bluetooth", "bluetooth", "Bluetooth off or restricted", "bluetooth", "bluetooth_device")
+	add(p.VPN.Enabled(), "vpn", "vpn", "approved VPN installed and connected", "vpn", "vpn_tunnel")
+	add(p.HostsFile.CheckOverrides, "hosts_file", "hosts", "no hosts file overrides of protected domains", "hosts_override")
+	add(p.Proxy.RequireEnabled, "proxy", "proxy", "required proxy in use", "proxy")
+	add(p.Connections.Enabled(), "connections", "connections", "no connections to denied countries or networks",
+		"connection_country", "connection_asn")
+	add(p.DNS.Enabled(), "dns", "dns_queries", "no lookups of denied or generated domains", "network_threat")
+	add(p.ARP.Enabled(), "arp", "arp", "gateway stable and no duplicate addresses", "gateway_mac_change", "arp_duplicate")
+	add(p.Interfaces.Enabled(), "interfaces", "interfaces", "network interfaces as allowed",
+		"promiscuous_interface", "virtual_interface", "interface_address")
+	add(p.TLS.Enabled(), "tls", "tls_services", "local TLS services use allowed versions and ciphers", "tls_protocol", "tls_cipher")
+	add(p.Web.Enabled(), "web", "web_endpoints", "web consoles secured", "web_hsts", "web_https_redirect", "web_default_credentials")
+	add(p.Secrets.Enabled(), "secrets", "secrets", "no exposed secrets or expiring certificates", "secret_exposure", "certificate_expiry")
+	add(p.Vulnerabilities.Enabled(), "vulnerabilities", "vulnerabilities", "no known vulnerable packages", "vulnerability")
+	for _, r := range p.Rules {
+		checks = append(checks, Check{Name: "rules/" + r.Name, Title: r.Description, Categories: []string{r.Name}})
+	}
+	for _, s := range p.Scripts {
+		checks = append(checks, Check{Name: "scripts/" + s.Name, Categories: []string{s.Name}})
+	}
+	add(p.Rego.Enabled(), "rego", "", "Rego policies pass", "rego")
+	for _, name := range p.Profiles {
+		prof, ok := LookupProfile(name)
+		if !ok {
+			continue
+		}
+		for _, c := range prof.Controls {
+			checks = append(checks, Check{
+				Name: "profiles/" + name + "/" + c.ID, Title: c.Title, Categories: []string{"cis"}, Control: c.ID,
+				Dataset: "benchmark",
+			})
+		}
+	}
+	return checks
+}
@@ -0,0 +1,44 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicies_Checks(t *testing.T) {
+	names := func(checks []Check) []string {
+		var out []string
+		for _, c := range checks {
+			out = append(out, c.Name)
+		}
+		return out
+	}
+	assert.Equal(t, []string{"allowed_users", "allowed_ports"}, names(Policies{}.Checks()), "always checked")
+
+	p := Policies{
+		RequireFirewallEnabled: true,
+		SSHD:                   SSHDPolicy{MaxAuthTries: 4},
+		Rules:                  []Rule{{Name: "telnet-running", Description: "no telnet"}},
+		Scripts:                []Script{{Name: "gatekeeper"}},
+		Profiles:               []string{"cis-ubuntu-22.04"},
+	}
+	checks := p.Checks()
+	assert.Equal(t, []string{"allowed_users", "allowed_ports", "require_firewall_enabled", "sshd",
+		"rules/telnet-running", "scripts/gatekeeper"}, names(checks)[:6])
+	prof, ok := LookupProfile("cis-ubuntu-22.04")
+	require.True(t, ok)
+	assert.Len(t, checks, 6+len(prof.Controls), "one per benchmark control")
+
+	sshd := checks[3]
+	assert.True(t, sshd.Matches(Violation{Category: "ssh_max_auth_tries"}))
+	assert.False(t, sshd.Matches(Violation{Category: "firewall"}))
+	assert.Equal(t, "no telnet", checks[4].Title)
+	assert.True(t, checks[4].Matches(Violation{Category: "telnet-running"}))
+
+	control := checks[6]
+	assert.Equal(t, "profiles/cis-ubuntu-22.04/"+prof.Controls[0].ID, control.Name)
+	assert.True(t, control.Matches(Violation{Category: "cis", Control: prof.Controls[0].ID}))
+	assert.False(t, control.Matches(Violation{Category: "cis", Control: "9.9.9"}), "another control's finding")
+}
@@ -1,18 +1,126 @@
 package analyzer
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"sort"
+	"strings"
+
+	"compliance-agent/collector"
 )
 
+// Policies is the rule set the analyzers enforce. It is normally loaded
+// from a YAML policy file (see LoadPolicies).
 type Policies struct {
-	AllowedUsers []string
-	AllowedPorts []int
+	AllowedUsers []string `yaml:"allowed_users"`
+	AllowedPorts []int    `yaml:"allowed_ports"`
+	// AllowedPackages, when set, is an allowlist: an installed package
+	// none of its entries matches is reported. Entries take the syntax
+	// of PackagePolicy's.
+	AllowedPackages  []string `yaml:"allowed_packages"`
+	AllowedProcesses []string `yaml:"allowed_processes"`
+	// Processes blocks processes by name or path, and can make
+	// AllowedProcesses a strict allowlist.
+	Processes ProcessPolicy `yaml:"processes"`
+	// Packages denies and requires installed packages, with optional
+	// version constraints.
+	Packages PackagePolicy `yaml:"packages"`
+	// RequireDiskEncryption flags an unencrypted boot volume (FileVault,
+	// LUKS or BitLocker).
+	RequireDiskEncryption bool `yaml:"require_disk_encryption"`
+	// RequireFirewallEnabled flags a host firewall that isn't filtering
+	// inbound traffic.
+	RequireFirewallEnabled bool `yaml:"require_firewall_enabled"`
+	// RequiredAgents are third-party agents (backup, EDR, MDM) checked
+	// for drift from their expected version and config.
+	RequiredAgents []RequiredAgent `yaml:"required_agents"`
+	// Severities overrides the severity per rule, keyed by violation
+	// category (e.g. "user: critical").
+	Severities map[string]string `yaml:"severities"`
+	// Workstation holds per-account rules for laptops and desktops.
+	Workstation WorkstationPolicy `yaml:"workstation"`
+	// Laptop holds power management rules for battery-powered hosts.
+	Laptop LaptopPolicy `yaml:"laptop"`
+	// Sharing prohibits file/printer sharing services on this host.
+	Sharing SharingPolicy `yaml:"sharing"`
+	// OSVersion sets minimum OS release and kernel versions.
+	OSVersion OSVersionPolicy `yaml:"os_version"`
+	// SSHD enforces SSH server hardening (root login, password auth,
+	// MaxAuthTries and other sshd_config options).
+	SSHD SSHDPolicy `yaml:"sshd"`
+	// Bluetooth restricts the radio and paired peripherals.
+	Bluetooth BluetoothPolicy `yaml:"bluetooth"`
+	// VPN requires an approved VPN client and/or an active tunnel.
+	VPN VPNPolicy `yaml:"vpn"`
+	// HostsFile flags hosts file overrides of protected domains.
+	HostsFile HostsFilePolicy `yaml:"hosts_file"`
+	// Proxy requires the filtering proxy to be configured.
+	Proxy ProxyPolicy `yaml:"proxy"`
+	// Connections flags established connections by remote country/ASN.
+	Connections ConnectionPolicy `yaml:"connections"`
+	// DNS flags sampled lookups of denied or generated-looking domains.
+	DNS DNSPolicy `yaml:"dns"`
+	// ARP flags gateway MAC changes and duplicate neighbor entries.
+	ARP ARPPolicy `yaml:"arp"`
+	// Interfaces flags promiscuous, unexpected virtual and
+	// multi-addressed network interfaces.
+	Interfaces InterfacePolicy `yaml:"interfaces"`
+	// TLS probes local TLS listeners for old protocols and insecure
+	// suites.
+	TLS TLSPolicy `yaml:"tls"`
+	// Web checks security headers, HTTPS redirects and default-login
+	// pages on local web consoles.
+	Web WebPolicy `yaml:"web"`
+	// Secrets flags world-readable keys, keystores and dotenv files, and
+	// expiring certificates.
+	Secrets SecretsPolicy `yaml:"secrets"`
+	// Vulnerabilities checks packages against the OSV database.
+	Vulnerabilities VulnerabilityPolicy `yaml:"vulnerabilities"`
+	// Profiles names built-in benchmark packs to check, e.g.
+	// cis-ubuntu-22.04 (see ProfileNames).
+	Profiles []string `yaml:"profiles"`
+	// Rules are operator-written CEL checks (see Rule).
+	Rules []Rule `yaml:"rules"`
+	// Rego evaluates OPA policies against the report (see RegoPolicy).
+	Rego RegoPolicy `yaml:"rego"`
+	// Scripts are operator-written Tengo checks run against the report
+	// (see Script).
+	Scripts []Script `yaml:"scripts"`
+
+	// programs are the compiled Rules, shared by copies of the policy
+	// parsed with it (see ParsePolicies).
+	programs *ruleCache
 }
 
 type Violation struct {
-	Category string `json:"category"`
-	Message  string `json:"message"`
+	Category string   `json:"category"`
+	Severity Severity `json:"severity"`
+	// User is the account a per-user finding belongs to; empty for
+	// host-level findings.
+	User string `json:"user,omitempty"`
+	// Control is the benchmark control ID (e.g. CIS "5.2.7") for
+	// findings from a profile.
+	Control string `json:"control,omitempty"`
+	Message string `json:"message"`
+	// Risk is severity × host criticality × exposure (see RiskModel);
+	// zero until the report is scored.
+	Risk float64 `json:"risk,omitempty"`
+	// Fingerprint is set, with Message and User emptied, when the
+	// violation was anonymized (see report.ComplianceReport.Anonymize).
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// Fingerprint identifies a finding on a host across scans: hostname,
+// category and message. Severity and control are left out so re-rating
+// a rule doesn't re-alert. An anonymized violation keeps the fingerprint
+// it had, so it still matches its later occurrences.
+func Fingerprint(hostname string, v Violation) string {
+	if v.Fingerprint != "" {
+		return v.Fingerprint
+	}
+	sum := sha256.Sum256([]byte(hostname + "\x00" + v.Category + "\x00" + v.Message))
+	return hex.EncodeToString(sum[:16])
 }
 
 type AnalysisResult struct {
@@ -20,20 +128,21 @@ type AnalysisResult struct {
 }
 
 // AnalyzeUsers checks if collected users are a subset of allowed users.
-func AnalyzeUsers(collectedUsers []map[string]string, policies Policies) []Violation {
+func AnalyzeUsers(collectedUsers []collector.User, policies Policies) []Violation {
 	allowed := make(map[string]struct{})
 	for _, u := range policies.AllowedUsers {
 		allowed[u] = struct{}{}
 	}
 	var v []Violation
-	for _, row := range collectedUsers {
-		username := row["username"]
+	for _, u := range collectedUsers {
+		username := u.Username
 		if username == "" {
 			continue
 		}
 		if _, ok := allowed[username]; !ok {
 			v = append(v, Violation{
 				Category: "user",
+				Severity: policies.severityFor("user"),
 				Message:  fmt.Sprintf("unexpected user present: %s", username),
 			})
 		}
@@ -42,21 +151,60 @@ func AnalyzeUsers(collectedUsers []map[string]string, policies Policies) []Viola
 }
 
 // AnalyzePorts checks if open/listening ports are in the allowed set.
-// Pass a slice of port numbers. Collection added in later phases.
-func AnalyzePorts(openPorts []int, policies Policies) []Violation {
+// Each disallowed port is reported once, naming the protocol, address
+// and owning process of every socket bound to it.
+func AnalyzePorts(bindings []collector.PortBinding, policies Policies) []Violation {
 	allowed := make(map[int]struct{})
 	for _, p := range policies.AllowedPorts {
 		allowed[p] = struct{}{}
 	}
-	sort.Ints(openPorts)
-	var v []Violation
-	for _, p := range openPorts {
-		if _, ok := allowed[p]; !ok {
-			v = append(v, Violation{
-				Category: "port",
-				Message:  fmt.Sprintf("unexpected open port: %d", p),
-			})
+	listeners := map[int][]string{}
+	for _, b := range bindings {
+		if _, ok := allowed[b.Port]; !ok {
+			listeners[b.Port] = appendUnique(listeners[b.Port], describeListener(b))
 		}
 	}
+	ports := make([]int, 0, len(listeners))
+	for p := range listeners {
+		ports = append(ports, p)
+	}
+	sort.Ints(ports)
+	var v []Violation
+	for _, p := range ports {
+		v = append(v, Violation{
+			Category: "port",
+			Severity: policies.severityFor("port"),
+			Message:  fmt.Sprintf("unexpected open port: %d (%s)", p, strings.Join(listeners[p], "; ")),
+		})
+	}
 	return v
 }
+
+// describeListener renders a binding as "tcp on 0.0.0.0 by sshd (pid
+// 812)", leaving out whatever the collector couldn't see.
+func describeListener(b collector.PortBinding) string {
+	s := b.Protocol
+	if b.Address != "" {
+		s += " on " + b.Address
+	}
+	switch {
+	case b.Process != "" && b.PID > 0:
+		s += fmt.Sprintf(" by %s (pid %d)", b.Process, b.PID)
+	case b.Process != "":
+		s += " by " + b.Process
+	case b.PID > 0:
+		s += fmt.Sprintf(" by pid %d", b.PID)
+	}
+	return s
+}
+
+// AgentViolation turns a problem with the agent's own trust chain (such as
+// an unsafe osquery socket) into a finding, so it shows up in the report
+// and alerts rather than only in logs.
+func AgentViolation(message string, policies Policies) Violation {
+	return Violation{
+		Category: "agent",
+		Severity: policies.severityFor("agent"),
+		Message:  message,
+	}
+}
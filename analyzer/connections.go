@@ -0,0 +1,72 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// ConnectionPolicy flags established connections by where the remote end
+// is. It needs GeoIP databases configured in the agent config; without
+// them connections carry no country or ASN and nothing matches.
+type ConnectionPolicy struct {
+	// DeniedCountries are ISO 3166-1 alpha-2 codes, e.g. embargoed
+	// countries.
+	DeniedCountries []string `yaml:"denied_countries"`
+	// DeniedASNs are autonomous system numbers of known-bad networks.
+	DeniedASNs []uint `yaml:"denied_asns"`
+}
+
+// Enabled reports whether any connection rule is set, which is what
+// triggers connection collection.
+func (c ConnectionPolicy) Enabled() bool {
+	return len(c.DeniedCountries)+len(c.DeniedASNs) > 0
+}
+
+// AnalyzeConnections applies the connection policy. A process talking to
+// the same remote address over several sockets is reported once.
+func AnalyzeConnections(conns []collector.Connection, policies Policies) []Violation {
+	p := policies.Connections
+	countries := map[string]bool{}
+	for _, c := range p.DeniedCountries {
+		countries[strings.ToUpper(c)] = true
+	}
+	asns := map[uint]bool{}
+	for _, a := range p.DeniedASNs {
+		asns[a] = true
+	}
+	var v []Violation
+	seen := map[string]bool{}
+	add := func(category, msg string) {
+		if seen[category+msg] {
+			return
+		}
+		seen[category+msg] = true
+		v = append(v, Violation{
+			Category: category,
+			Severity: policies.severityFor(category),
+			Message:  msg,
+		})
+	}
+	for _, c := range conns {
+		who := connectionOwner(c)
+		if c.Country != "" && countries[strings.ToUpper(c.Country)] {
+			add("connection_country", fmt.Sprintf("%s connected to %s in denied country %s", who, c.RemoteAddress, c.Country))
+		}
+		if c.ASN != 0 && asns[c.ASN] {
+			add("connection_asn", fmt.Sprintf("%s connected to %s in denied AS%d (%s)", who, c.RemoteAddress, c.ASN, c.ASOrg))
+		}
+	}
+	return v
+}
+
+func connectionOwner(c collector.Connection) string {
+	switch {
+	case c.Process != "":
+		return fmt.Sprintf("process %s (pid %d)", c.Process, c.PID)
+	case c.PID > 0:
+		return fmt.Sprintf("pid %d", c.PID)
+	}
+	return "a process"
+}
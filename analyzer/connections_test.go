@@ -0,0 +1,26 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeConnections(t *testing.T) {
+	conns := []collector.Connection{
+		{PID: 42, Process: "curl", RemoteAddress: "203.0.113.7", RemotePort: 443, Country: "KP", ASN: 64500, ASOrg: "Example Transit"},
+		{PID: 42, Process: "curl", RemoteAddress: "203.0.113.7", RemotePort: 8443, Country: "KP"},
+		{PID: -1, RemoteAddress: "198.51.100.1", Country: "NL", ASN: 64501},
+		{PID: -1, RemoteAddress: "10.0.0.1"},
+	}
+	p := Policies{Connections: ConnectionPolicy{DeniedCountries: []string{"kp", "IR"}, DeniedASNs: []uint{64500}}}
+	v := AnalyzeConnections(conns, p)
+	if assert.Len(t, v, 2) {
+		assert.Equal(t, "process curl (pid 42) connected to 203.0.113.7 in denied country KP", v[0].Message)
+		assert.Equal(t, SeverityHigh, v[0].Severity)
+		assert.Equal(t, "connection_asn", v[1].Category)
+		assert.Equal(t, "process curl (pid 42) connected to 203.0.113.7 in denied AS64500 (Example Transit)", v[1].Message)
+	}
+	assert.Empty(t, AnalyzeConnections(conns, Policies{}))
+}
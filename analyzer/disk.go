@@ -0,0 +1,32 @@
+package analyzer
+
+import (
+	"fmt"
+
+	"compliance-agent/collector"
+)
+
+// AnalyzeDiskEncryption flags an unencrypted boot volume when the policy
+// sets require_disk_encryption. Volumes whose state couldn't be read are
+// absent from the list and produce no violation; the collector records
+// that as an error instead.
+func AnalyzeDiskEncryption(volumes []collector.DiskVolume, policies Policies) []Violation {
+	if !policies.RequireDiskEncryption {
+		return nil
+	}
+	var v []Violation
+	for _, vol := range volumes {
+		if vol.Boot && !vol.Encrypted {
+			name := vol.Mount
+			if name == "" {
+				name = vol.Name
+			}
+			v = append(v, Violation{
+				Category: "disk_encryption",
+				Severity: policies.severityFor("disk_encryption"),
+				Message:  fmt.Sprintf("boot volume %s is not encrypted", name),
+			})
+		}
+	}
+	return v
+}
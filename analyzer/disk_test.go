@@ -0,0 +1,26 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeDiskEncryption(t *testing.T) {
+	vols := []collector.DiskVolume{
+		{Name: "/dev/sda2", Mount: "/", Boot: true},
+		{Name: "/dev/sdb1", Mount: "/data"},
+	}
+	assert.Empty(t, AnalyzeDiskEncryption(vols, Policies{}), "off unless required")
+
+	v := AnalyzeDiskEncryption(vols, Policies{RequireDiskEncryption: true})
+	require.Len(t, v, 1)
+	assert.Equal(t, "disk_encryption", v[0].Category)
+	assert.Equal(t, SeverityHigh, v[0].Severity)
+	assert.Equal(t, "boot volume / is not encrypted", v[0].Message)
+
+	vols[0].Encrypted = true
+	assert.Empty(t, AnalyzeDiskEncryption(vols, Policies{RequireDiskEncryption: true}))
+}
@@ -0,0 +1,131 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// DNSPolicy flags sampled DNS lookups of known-bad or machine-generated
+// domains. Both feed the network_threat category.
+type DNSPolicy struct {
+	// DeniedDomains match the domain and its subdomains.
+	DeniedDomains []string `yaml:"denied_domains"`
+	// DetectDGA flags names that look algorithmically generated, the
+	// way malware finds its command-and-control servers.
+	DetectDGA bool `yaml:"detect_dga"`
+	// AllowedDomains are never flagged, e.g. internal names that happen
+	// to look random.
+	AllowedDomains []string `yaml:"allowed_domains"`
+}
+
+// Enabled reports whether any DNS rule is set, which is what triggers
+// DNS sampling.
+func (d DNSPolicy) Enabled() bool {
+	return len(d.DeniedDomains) > 0 || d.DetectDGA
+}
+
+// AnalyzeDNS applies the DNS policy to the sampled lookups.
+func AnalyzeDNS(queries []collector.DNSQuery, policies Policies) []Violation {
+	p := policies.DNS
+	var v []Violation
+	add := func(msg string) {
+		v = append(v, Violation{
+			Category: "network_threat",
+			Severity: policies.severityFor("network_threat"),
+			Message:  msg,
+		})
+	}
+	for _, q := range queries {
+		if protectedDomain(q.Name, p.AllowedDomains) != "" {
+			continue
+		}
+		if d := protectedDomain(q.Name, p.DeniedDomains); d != "" {
+			add(fmt.Sprintf("DNS lookup of %s matches denied domain %s%s", q.Name, d, dnsQuerySuffix(q)))
+			continue
+		}
+		if p.DetectDGA {
+			if label, ok := looksGenerated(q.Name); ok {
+				add(fmt.Sprintf("DNS lookup of %s looks algorithmically generated (%q)%s", q.Name, label, dnsQuerySuffix(q)))
+			}
+		}
+	}
+	return v
+}
+
+func dnsQuerySuffix(q collector.DNSQuery) string {
+	var parts []string
+	if q.Client != "" {
+		parts = append(parts, "from "+q.Client)
+	}
+	if q.Count > 1 {
+		parts = append(parts, fmt.Sprintf("%d lookups", q.Count))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// secondLevelLabels are public second-level labels under country TLDs
+// (example.co.uk), so the label before them is the registered name.
+var secondLevelLabels = map[string]bool{
+	"co": true, "com": true, "net": true, "org": true, "gov": true, "ac": true, "edu": true,
+}
+
+// looksGenerated applies a DGA heuristic to the registered label of a
+// domain (the "example" of www.example.co.uk). Subdomains are ignored
+// since CDNs and cloud services randomize those legitimately. A label is
+// suspicious when it is long, has high character entropy, and reads like
+// noise: several digits, a long consonant run, or few vowels.
+func looksGenerated(name string) (string, bool) {
+	labels := strings.Split(strings.TrimSuffix(strings.ToLower(name), "."), ".")
+	if len(labels) < 2 {
+		return "", false
+	}
+	i := len(labels) - 2
+	if i > 0 && len(labels[len(labels)-1]) == 2 && secondLevelLabels[labels[i]] {
+		i--
+	}
+	label := labels[i]
+	if len(label) < 12 || strings.HasPrefix(label, "xn--") {
+		return "", false
+	}
+	if labelEntropy(label) < 3.5 {
+		return "", false
+	}
+	digits, vowels, run, maxRun := 0, 0, 0, 0
+	for _, r := range label {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+			run = 0
+		case strings.ContainsRune("aeiouy", r):
+			vowels++
+			run = 0
+		case r >= 'a' && r <= 'z':
+			run++
+			maxRun = max(maxRun, run)
+		default:
+			run = 0
+		}
+	}
+	return label, digits >= 3 || maxRun >= 5 || float64(vowels)/float64(len(label)) < 0.2
+}
+
+// labelEntropy is the Shannon entropy of s in bits per character.
+func labelEntropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+	var h float64
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
@@ -0,0 +1,53 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLooksGenerated(t *testing.T) {
+	for _, name := range []string{
+		"kq3v9z7x1pj2m.com",
+		"www.xjwqpzrtkvbnmd.net",
+		"a1b2c3d4e5f6g7.co.uk",
+	} {
+		_, ok := looksGenerated(name)
+		assert.True(t, ok, name)
+	}
+	for _, name := range []string{
+		"google.com",
+		"stackoverflow.com",
+		"login.microsoftonline.com",
+		"d3ag4hukkh62yn.cloudfront.net",
+		"googleusercontent.com",
+		"xn--80ak6aa92e.com",
+		"localhost",
+	} {
+		_, ok := looksGenerated(name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestAnalyzeDNS(t *testing.T) {
+	queries := []collector.DNSQuery{
+		{Name: "c2.evil.example", Count: 3, Client: "10.0.0.5"},
+		{Name: "kq3v9z7x1pj2m.com", Count: 1},
+		{Name: "build.zq8x7w6v5u4t3.com", Count: 1},
+		{Name: "www.example.org", Count: 9},
+	}
+	p := Policies{DNS: DNSPolicy{
+		DeniedDomains:  []string{"evil.example"},
+		DetectDGA:      true,
+		AllowedDomains: []string{"zq8x7w6v5u4t3.com"},
+	}}
+	v := AnalyzeDNS(queries, p)
+	if assert.Len(t, v, 2) {
+		assert.Equal(t, "network_threat", v[0].Category)
+		assert.Equal(t, SeverityHigh, v[0].Severity)
+		assert.Equal(t, "DNS lookup of c2.evil.example matches denied domain evil.example (from 10.0.0.5, 3 lookups)", v[0].Message)
+		assert.Equal(t, `DNS lookup of kq3v9z7x1pj2m.com looks algorithmically generated ("kq3v9z7x1pj2m")`, v[1].Message)
+	}
+	assert.Empty(t, AnalyzeDNS(queries, Policies{}))
+}
@@ -0,0 +1,88 @@
+package analyzer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"compliance-agent/errcode"
+)
+
+// DefaultExitCodes exits 2 when a critical violation is found and 1 for
+// any other violation.
+const DefaultExitCodes = "critical=2,any=1"
+
+// ExitCodes maps violation severities to process exit codes, so CI
+// pipelines and MDM scripts can gate on a scan's result.
+type ExitCodes []exitThreshold
+
+type exitThreshold struct {
+	// rank is the least severe violation the threshold matches; -1
+	// matches any.
+	rank int
+	code int
+}
+
+// ParseExitCodes reads a comma-separated list of severity=code pairs, e.g.
+// "critical=2,high=1". Each severity matches violations at that level or
+// worse, and "any" matches every violation. The most severe threshold
+// reached sets the exit code. "none" or an empty spec always exits 0.
+// The exit statuses errcode gives errors are refused, so a violation
+// can't be mistaken for a failed scan.
+func ParseExitCodes(spec string) (ExitCodes, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || strings.EqualFold(spec, "none") {
+		return nil, nil
+	}
+	var codes ExitCodes
+	seen := map[int]bool{}
+	for _, part := range strings.Split(spec, ",") {
+		level, num, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not severity=code", part)
+		}
+		t := exitThreshold{rank: -1}
+		if level = strings.TrimSpace(level); !strings.EqualFold(level, "any") {
+			sev, err := ParseSeverity(level)
+			if err != nil {
+				return nil, err
+			}
+			t.rank = sev.Rank()
+		}
+		code, err := strconv.Atoi(strings.TrimSpace(num))
+		if err != nil || code < 1 || code > 125 {
+			return nil, fmt.Errorf("%s=%s: code must be 1-125", level, num)
+		}
+		if errcode.Reserved(code) {
+			return nil, fmt.Errorf("%s=%s: %d is the exit status of an error code", level, num, code)
+		}
+		if seen[t.rank] {
+			return nil, fmt.Errorf("%s is set twice", level)
+		}
+		seen[t.rank] = true
+		t.code = code
+		codes = append(codes, t)
+	}
+	return codes, nil
+}
+
+// Code returns the exit code for the most severe threshold the
+// violations reach, or 0 when none is reached.
+func (e ExitCodes) Code(violations []Violation) int {
+	if len(violations) == 0 {
+		return 0
+	}
+	worst := -1
+	for _, v := range violations {
+		if r := v.Severity.Rank(); r > worst {
+			worst = r
+		}
+	}
+	code, best := 0, -2
+	for _, t := range e {
+		if t.rank <= worst && t.rank > best {
+			code, best = t.code, t.rank
+		}
+	}
+	return code
+}
@@ -0,0 +1,46 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExitCodes(t *testing.T) {
+	codes, err := ParseExitCodes(DefaultExitCodes)
+	require.NoError(t, err)
+	assert.Equal(t, 0, codes.Code(nil))
+	assert.Equal(t, 1, codes.Code([]Violation{{Severity: SeverityInfo}}))
+	// The zero severity ranks as medium.
+	assert.Equal(t, 1, codes.Code([]Violation{{}}))
+	assert.Equal(t, 2, codes.Code([]Violation{{Severity: SeverityLow}, {Severity: SeverityCritical}}))
+
+	codes, err = ParseExitCodes("high=3, critical=4")
+	require.NoError(t, err)
+	assert.Equal(t, 0, codes.Code([]Violation{{Severity: SeverityMedium}}))
+	assert.Equal(t, 3, codes.Code([]Violation{{Severity: SeverityHigh}}))
+	assert.Equal(t, 4, codes.Code([]Violation{{Severity: SeverityCritical}}))
+
+	codes, err = ParseExitCodes("none")
+	require.NoError(t, err)
+	assert.Equal(t, 0, codes.Code([]Violation{{Severity: SeverityCritical}}))
+
+	for spec, want := range map[string]string{
+		"critical":          "not severity=code",
+		"severe=2":          "unknown severity",
+		"any=0":             "code must be 1-125",
+		"high=x":            "code must be 1-125",
+		"high=1,HIGH=2":     "HIGH is set twice",
+		"critical=77":       "77 is the exit status of an error code",
+		"any=64":            "64 is the exit status of an error code",
+		"any=1,critical=99": "",
+	} {
+		_, err := ParseExitCodes(spec)
+		if want == "" {
+			assert.NoError(t, err, spec)
+		} else {
+			assert.ErrorContains(t, err, want, spec)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// DefaultProtectedDomains are security-vendor and update domains users
+// redirect or blackhole in the hosts file to silence endpoint tooling.
+var DefaultProtectedDomains = []string{
+	"crowdstrike.com", "cloudsink.net",
+	"sentinelone.net",
+	"paloaltonetworks.com",
+	"zscaler.net", "zscaler.com",
+	"sophos.com",
+	"eset.com",
+	"kaspersky.com",
+	"mcafee.com", "trellix.com",
+	"trendmicro.com",
+	"carbonblack.io",
+	"okta.com", "duosecurity.com",
+	"windowsupdate.com", "update.microsoft.com", "wdcp.microsoft.com", "smartscreen.microsoft.com",
+	"swscan.apple.com", "mesu.apple.com",
+}
+
+// HostsFilePolicy flags hosts file entries that override protected
+// domains.
+type HostsFilePolicy struct {
+	CheckOverrides bool `yaml:"check_overrides"`
+	// ProtectedDomains adds corporate domains to DefaultProtectedDomains.
+	// A domain protects its subdomains too.
+	ProtectedDomains []string `yaml:"protected_domains"`
+}
+
+// ProxyPolicy requires the system proxy that enforces web filtering.
+type ProxyPolicy struct {
+	RequireEnabled bool `yaml:"require_enabled"`
+	// Server, when set, must appear in the configured proxy server or PAC
+	// URL (e.g. "proxy.corp.example.com").
+	Server string `yaml:"server"`
+}
+
+// AnalyzeHostsFile applies the hosts file policy.
+func AnalyzeHostsFile(entries []collector.HostsEntry, policies Policies) []Violation {
+	if !policies.HostsFile.CheckOverrides {
+		return nil
+	}
+	protected := append(append([]string(nil), DefaultProtectedDomains...), policies.HostsFile.ProtectedDomains...)
+	var v []Violation
+	for _, e := range entries {
+		for _, h := range e.Hostnames {
+			if d := protectedDomain(h, protected); d != "" {
+				v = append(v, Violation{
+					Category: "hosts_override",
+					Severity: policies.severityFor("hosts_override"),
+					Message:  fmt.Sprintf("hosts file line %d maps %s (protected domain %s) to %s", e.Line, h, d, e.IP),
+				})
+			}
+		}
+	}
+	return v
+}
+
+// protectedDomain returns the entry in domains that host is, or is a
+// subdomain of.
+func protectedDomain(host string, domains []string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, d := range domains {
+		d = strings.TrimPrefix(strings.ToLower(d), ".")
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return d
+		}
+	}
+	return ""
+}
+
+// AnalyzeProxy applies the proxy policy. Unreadable settings are not a
+// violation.
+func AnalyzeProxy(ps collector.ProxySettings, policies Policies) []Violation {
+	p := policies.Proxy
+	if !p.RequireEnabled || ps.Enabled == nil {
+		return nil
+	}
+	var v []Violation
+	add := func(msg string) {
+		v = append(v, Violation{
+			Category: "proxy",
+			Severity: policies.severityFor("proxy"),
+			Message:  msg,
+		})
+	}
+	if !*ps.Enabled {
+		add("system proxy is disabled")
+		return v
+	}
+	if p.Server != "" {
+		want := strings.ToLower(p.Server)
+		if !strings.Contains(strings.ToLower(ps.Server), want) && !strings.Contains(strings.ToLower(ps.PACURL), want) {
+			add(fmt.Sprintf("system proxy points at %s, not %s", firstNonEmpty(ps.Server, ps.PACURL), p.Server))
+		}
+	}
+	for _, b := range ps.Bypass {
+		if b = strings.TrimSpace(b); b == "*" || b == "*.*" || b == "0.0.0.0/0" {
+			add(fmt.Sprintf("proxy exceptions bypass all hosts (%q)", b))
+			break
+		}
+	}
+	return v
+}
+
+func firstNonEmpty(s ...string) string {
+	for _, x := range s {
+		if x != "" {
+			return x
+		}
+	}
+	return ""
+}
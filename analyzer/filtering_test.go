@@ -0,0 +1,44 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeHostsFile(t *testing.T) {
+	entries := []collector.HostsEntry{
+		{IP: "127.0.0.1", Hostnames: []string{"localhost"}, Line: 1},
+		{IP: "0.0.0.0", Hostnames: []string{"ts01-b.cloudsink.net"}, Line: 2},
+		{IP: "203.0.113.9", Hostnames: []string{"sso.corp.example.com", "notcorp.example.com"}, Line: 3},
+	}
+	p := Policies{HostsFile: HostsFilePolicy{CheckOverrides: true, ProtectedDomains: []string{"corp.example.com"}}}
+	v := AnalyzeHostsFile(entries, p)
+	if assert.Len(t, v, 2) {
+		assert.Equal(t, "hosts file line 2 maps ts01-b.cloudsink.net (protected domain cloudsink.net) to 0.0.0.0", v[0].Message)
+		assert.Equal(t, SeverityHigh, v[0].Severity)
+		assert.Contains(t, v[1].Message, "sso.corp.example.com")
+	}
+
+	assert.Empty(t, AnalyzeHostsFile(entries, Policies{}))
+}
+
+func TestAnalyzeProxy(t *testing.T) {
+	on, off := true, false
+	p := Policies{Proxy: ProxyPolicy{RequireEnabled: true, Server: "proxy.corp.example.com"}}
+
+	v := AnalyzeProxy(collector.ProxySettings{Enabled: &off}, p)
+	if assert.Len(t, v, 1) {
+		assert.Equal(t, "system proxy is disabled", v[0].Message)
+	}
+
+	v = AnalyzeProxy(collector.ProxySettings{Enabled: &on, Server: "127.0.0.1:8888", Bypass: []string{"*.local", "*"}}, p)
+	if assert.Len(t, v, 2) {
+		assert.Equal(t, "system proxy points at 127.0.0.1:8888, not proxy.corp.example.com", v[0].Message)
+		assert.Contains(t, v[1].Message, "bypass all hosts")
+	}
+
+	assert.Empty(t, AnalyzeProxy(collector.ProxySettings{Enabled: &on, PACURL: "http://proxy.corp.example.com/proxy.pac"}, p))
+	assert.Empty(t, AnalyzeProxy(collector.ProxySettings{}, p), "unknown settings aren't a violation")
+}
@@ -0,0 +1,32 @@
+package analyzer
+
+import (
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// AnalyzeFirewall flags a host firewall that isn't filtering inbound
+// traffic when the policy sets require_firewall_enabled. An open-port
+// allowlist says nothing about whether anything stops a new listener.
+// Unknown state (typically an unprivileged scan) produces no violation.
+func AnalyzeFirewall(st collector.FirewallStatus, policies Policies) []Violation {
+	if !policies.RequireFirewallEnabled || st.Enabled == nil || *st.Enabled {
+		return nil
+	}
+	var off []string
+	for _, b := range st.Backends {
+		if !b.Enabled {
+			s := b.Name
+			if b.Detail != "" {
+				s += ": " + b.Detail
+			}
+			off = append(off, s)
+		}
+	}
+	return []Violation{{
+		Category: "firewall",
+		Severity: policies.severityFor("firewall"),
+		Message:  "host firewall is not enabled (" + strings.Join(off, "; ") + ")",
+	}}
+}
@@ -0,0 +1,26 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeFirewall(t *testing.T) {
+	off, on := false, true
+	p := Policies{RequireFirewallEnabled: true}
+	st := collector.FirewallStatus{Enabled: &off, Backends: []collector.FirewallBackend{
+		{Name: "ufw", Detail: "Status: inactive"},
+		{Name: "iptables", Detail: "policy ACCEPT"},
+	}}
+	v := AnalyzeFirewall(st, p)
+	require.Len(t, v, 1)
+	assert.Equal(t, SeverityHigh, v[0].Severity)
+	assert.Equal(t, "host firewall is not enabled (ufw: Status: inactive; iptables: policy ACCEPT)", v[0].Message)
+
+	assert.Empty(t, AnalyzeFirewall(st, Policies{}), "off unless required")
+	assert.Empty(t, AnalyzeFirewall(collector.FirewallStatus{}, p), "unknown state")
+	assert.Empty(t, AnalyzeFirewall(collector.FirewallStatus{Enabled: &on}, p))
+}
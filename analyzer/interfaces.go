@@ -0,0 +1,129 @@
+package analyzer
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// DefaultAllowedVirtual are software interfaces endpoints have in normal
+// use: VPN tunnels, container networking, and the macOS Thunderbolt
+// bridge.
+var DefaultAllowedVirtual = []string{
+	"utun*", "tun*", "wg*", "tailscale*", "ppp*", "ipsec*",
+	"docker0", "br-*", "veth*",
+	"bridge0",
+}
+
+// InterfacePolicy flags network interfaces that suggest sniffing or rogue
+// virtualization.
+type InterfacePolicy struct {
+	// Promiscuous flags interfaces in promiscuous mode. Bridge ports are
+	// exempt, since bridging needs it.
+	Promiscuous bool `yaml:"promiscuous"`
+	// Virtual flags bridge, tap, tun, veth and other software
+	// interfaces that are up and whose name or description matches none
+	// of AllowedVirtual.
+	Virtual bool `yaml:"virtual"`
+	// AllowedVirtual are glob patterns; empty means
+	// DefaultAllowedVirtual.
+	AllowedVirtual []string `yaml:"allowed_virtual"`
+	// SecondaryAddresses flags an interface holding more than one IPv4
+	// address.
+	SecondaryAddresses bool `yaml:"secondary_addresses"`
+	// AllowedNetworks are CIDRs every non-loopback, non-link-local
+	// address must fall in. Empty allows any.
+	AllowedNetworks []string `yaml:"allowed_networks"`
+}
+
+// Enabled reports whether any interface rule is set, which is what
+// triggers interface collection.
+func (p InterfacePolicy) Enabled() bool {
+	return p.Promiscuous || p.Virtual || p.SecondaryAddresses || len(p.AllowedNetworks) > 0
+}
+
+// virtualKinds are interface kinds the Virtual rule looks at.
+var virtualKinds = map[string]bool{
+	"bridge": true, "tap": true, "tun": true, "veth": true, "wireguard": true, "virtual": true,
+}
+
+// AnalyzeInterfaces applies the interface policy.
+func AnalyzeInterfaces(ifaces []collector.NetInterface, policies Policies) []Violation {
+	p := policies.Interfaces
+	allowed := p.AllowedVirtual
+	if len(allowed) == 0 {
+		allowed = DefaultAllowedVirtual
+	}
+	var networks []*net.IPNet
+	for _, cidr := range p.AllowedNetworks {
+		if _, n, err := net.ParseCIDR(cidr); err == nil {
+			networks = append(networks, n)
+		}
+	}
+	var v []Violation
+	add := func(category, msg string) {
+		v = append(v, Violation{
+			Category: category,
+			Severity: policies.severityFor(category),
+			Message:  msg,
+		})
+	}
+	for _, ifc := range ifaces {
+		if ifc.Kind == "loopback" {
+			continue
+		}
+		label := ifc.Name
+		if ifc.Description != "" {
+			label += " (" + ifc.Description + ")"
+		}
+		if p.Promiscuous && ifc.Promiscuous != nil && *ifc.Promiscuous && ifc.Master == "" {
+			add("promiscuous_interface", fmt.Sprintf("interface %s is in promiscuous mode", label))
+		}
+		if p.Virtual && ifc.Up && virtualKinds[ifc.Kind] && !matchesAny(allowed, ifc.Name, ifc.Description) {
+			add("virtual_interface", fmt.Sprintf("unexpected %s interface %s", ifc.Kind, label))
+		}
+		var v4 []string
+		for _, a := range ifc.Addresses {
+			ip, _, err := net.ParseCIDR(a)
+			if err != nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+				continue
+			}
+			if ip.To4() != nil {
+				v4 = append(v4, a)
+			}
+			if len(networks) > 0 && !inNetworks(ip, networks) {
+				add("interface_address", fmt.Sprintf("interface %s has address %s outside the allowed networks", label, a))
+			}
+		}
+		if p.SecondaryAddresses && len(v4) > 1 {
+			add("interface_address", fmt.Sprintf("interface %s has secondary IPv4 addresses: %s", label, strings.Join(v4, ", ")))
+		}
+	}
+	return v
+}
+
+func matchesAny(patterns []string, names ...string) bool {
+	for _, pat := range patterns {
+		for _, n := range names {
+			if n == "" {
+				continue
+			}
+			if ok, _ := path.Match(strings.ToLower(pat), strings.ToLower(n)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func inNetworks(ip net.IP, networks []*net.IPNet) bool {
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeInterfaces(t *testing.T) {
+	yes, no := true, false
+	ifaces := []collector.NetInterface{
+		{Name: "lo", Kind: "loopback", Promiscuous: &yes, Addresses: []string{"127.0.0.1/8"}},
+		{Name: "eth0", Kind: "physical", Promiscuous: &yes, Addresses: []string{"10.1.2.3/24", "10.1.2.99/24", "fe80::1/64"}},
+		{Name: "veth1a2b", Kind: "veth", Up: true, Promiscuous: &yes, Master: "docker0"},
+		{Name: "docker0", Kind: "bridge", Up: true, Promiscuous: &no, Addresses: []string{"172.17.0.1/16"}},
+		{Name: "virbr0", Kind: "bridge", Up: true, Promiscuous: &no},
+		{Name: "tap0", Kind: "tap", Up: true},
+		{Name: "Ethernet 3", Description: "VirtualBox Host-Only Ethernet Adapter", Kind: "virtual", Up: true},
+		// Down interfaces carry no traffic.
+		{Name: "ifb0", Kind: "virtual"},
+	}
+	p := Policies{Interfaces: InterfacePolicy{
+		Promiscuous:        true,
+		Virtual:            true,
+		SecondaryAddresses: true,
+		AllowedNetworks:    []string{"10.0.0.0/8"},
+	}}
+	var got []string
+	for _, x := range AnalyzeInterfaces(ifaces, p) {
+		got = append(got, x.Category+": "+x.Message)
+	}
+	assert.Equal(t, []string{
+		"promiscuous_interface: interface eth0 is in promiscuous mode",
+		"interface_address: interface eth0 has secondary IPv4 addresses: 10.1.2.3/24, 10.1.2.99/24",
+		"interface_address: interface docker0 has address 172.17.0.1/16 outside the allowed networks",
+		"virtual_interface: unexpected bridge interface virbr0",
+		"virtual_interface: unexpected tap interface tap0",
+		"virtual_interface: unexpected virtual interface Ethernet 3 (VirtualBox Host-Only Ethernet Adapter)",
+	}, got)
+
+	// A custom list replaces the defaults, and patterns match the
+	// Windows adapter description too.
+	p.Interfaces = InterfacePolicy{Virtual: true, AllowedVirtual: []string{"docker0", "veth*", "virbr*", "tap*", "*VirtualBox*"}}
+	assert.Empty(t, AnalyzeInterfaces(ifaces, p))
+	assert.Empty(t, AnalyzeInterfaces(ifaces, Policies{}))
+}
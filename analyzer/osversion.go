@@ -0,0 +1,74 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// OSVersionPolicy sets the oldest acceptable OS releases and kernel, so
+// hosts that have fallen behind on updates are reported.
+type OSVersionPolicy struct {
+	// MinVersions maps an OS to its oldest acceptable version, e.g.
+	// macOS: "14.4" or ubuntu: "22.04.4". The OS is matched by its
+	// distribution ID, name or platform (linux, darwin, windows), in
+	// that order.
+	MinVersions map[string]string `yaml:"min_versions"`
+	// MinKernel is the oldest acceptable Linux kernel, e.g. "5.15".
+	MinKernel string `yaml:"min_kernel"`
+}
+
+// Enabled reports whether any version floor is set.
+func (p OSVersionPolicy) Enabled() bool {
+	return len(p.MinVersions) > 0 || p.MinKernel != ""
+}
+
+// minVersion finds the floor for v.
+func (p OSVersionPolicy) minVersion(v *collector.OSVersion) (string, bool) {
+	for _, key := range []string{v.ID, v.Name, v.Platform} {
+		for name, min := range p.MinVersions {
+			if key != "" && strings.EqualFold(name, key) {
+				return min, true
+			}
+		}
+	}
+	return "", false
+}
+
+// AnalyzeOSVersion reports an OS release or Linux kernel older than the
+// policy's floor, with the expected and actual versions.
+func AnalyzeOSVersion(v *collector.OSVersion, policies Policies) []Violation {
+	p := policies.OSVersion
+	if v == nil || !p.Enabled() {
+		return nil
+	}
+	var out []Violation
+	add := func(category, msg string) {
+		out = append(out, Violation{
+			Category: category,
+			Severity: policies.severityFor(category),
+			Message:  msg,
+		})
+	}
+	if min, ok := p.minVersion(v); ok {
+		name := v.Name
+		if name == "" {
+			name = v.ID
+		}
+		switch {
+		case v.Version == "":
+			add("os_version", fmt.Sprintf("%s version is unknown, required %s or later", name, min))
+		case collector.CompareVersions(v.Version, min) < 0:
+			build := ""
+			if v.Build != "" {
+				build = " (build " + v.Build + ")"
+			}
+			add("os_version", fmt.Sprintf("%s %s%s is older than the required %s", name, v.Version, build, min))
+		}
+	}
+	if p.MinKernel != "" && v.Platform == "linux" && v.Kernel != "" && collector.CompareVersions(v.Kernel, p.MinKernel) < 0 {
+		add("kernel_version", fmt.Sprintf("Linux kernel %s is older than the required %s", v.Kernel, p.MinKernel))
+	}
+	return out
+}
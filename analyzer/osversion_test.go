@@ -0,0 +1,38 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeOSVersion(t *testing.T) {
+	p := Policies{OSVersion: OSVersionPolicy{
+		MinVersions: map[string]string{"macOS": "14.4", "ubuntu": "22.04.4", "linux": "1.0", "windows": "10.0.22631.3447"},
+		MinKernel:   "5.15",
+	}}
+
+	mac := &collector.OSVersion{Platform: "darwin", ID: "darwin", Name: "macOS", Version: "14.3.1", Build: "23D60", Kernel: "23.3.0"}
+	assert.Equal(t, []Violation{{Category: "os_version", Severity: SeverityHigh, Message: "macOS 14.3.1 (build 23D60) is older than the required 14.4"}}, AnalyzeOSVersion(mac, p))
+
+	// The distribution's own floor wins over the platform's, and the
+	// kernel is compared without its suffix.
+	ubuntu := &collector.OSVersion{Platform: "linux", ID: "ubuntu", Name: "Ubuntu", Version: "22.04.3", Kernel: "5.4.0-150-generic"}
+	v := AnalyzeOSVersion(ubuntu, p)
+	if assert.Len(t, v, 2) {
+		assert.Equal(t, "Ubuntu 22.04.3 is older than the required 22.04.4", v[0].Message)
+		assert.Equal(t, Violation{Category: "kernel_version", Severity: SeverityHigh, Message: "Linux kernel 5.4.0-150-generic is older than the required 5.15"}, v[1])
+	}
+	ubuntu.Version, ubuntu.Kernel = "24.04", "6.8.0-31-generic"
+	assert.Empty(t, AnalyzeOSVersion(ubuntu, p))
+
+	win := &collector.OSVersion{Platform: "windows", ID: "windows", Name: "Microsoft Windows 11 Pro", Version: "10.0.22631.3447", Kernel: "10.0.22631"}
+	assert.Empty(t, AnalyzeOSVersion(win, p), "kernel floor is Linux only")
+
+	arch := &collector.OSVersion{Platform: "linux", ID: "arch", Name: "Arch Linux", Kernel: "6.8.9"}
+	assert.Equal(t, "Arch Linux version is unknown, required 1.0 or later", AnalyzeOSVersion(arch, p)[0].Message)
+
+	assert.Empty(t, AnalyzeOSVersion(nil, p))
+	assert.Empty(t, AnalyzeOSVersion(mac, Policies{}))
+}
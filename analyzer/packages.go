@@ -0,0 +1,334 @@
+package analyzer
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// PackagePolicy denies and requires installed packages. Each entry is a
+// name, which may be a glob ("vnc*"), optionally followed by version
+// constraints: "openssl < 3.0.7" or "falcon-sensor >= 7.10, < 8".
+// Versions compare as dpkg and rpm compare them: epoch, then upstream
+// version, letters included, then revision; see comparePackageVersions.
+type PackagePolicy struct {
+	// Denied packages mustn't be installed. With constraints, only a
+	// version inside them is reported, so "openssl < 3.0.7" flags old
+	// OpenSSL but allows current releases.
+	Denied []string `yaml:"denied"`
+	// Required packages must be installed, at a version meeting the
+	// constraints when given.
+	Required []string `yaml:"required"`
+}
+
+// Enabled reports whether any package rule is configured.
+func (p PackagePolicy) Enabled() bool {
+	return len(p.Denied) > 0 || len(p.Required) > 0
+}
+
+// ChecksPackages reports whether the policy checks installed packages,
+// with package rules or an allowed_packages list.
+func (p Policies) ChecksPackages() bool {
+	return p.Packages.Enabled() || len(p.AllowedPackages) > 0
+}
+
+// versionConstraint is one comparison, e.g. ">= 7.10".
+type versionConstraint struct {
+	op, version string
+}
+
+func (c versionConstraint) String() string { return c.op + " " + c.version }
+
+// allows reports whether version v meets the constraint.
+func (c versionConstraint) allows(v string) bool {
+	n := comparePackageVersions(v, c.version)
+	switch c.op {
+	case "<":
+		return n < 0
+	case "<=":
+		return n <= 0
+	case ">":
+		return n > 0
+	case ">=":
+		return n >= 0
+	case "!=":
+		return n != 0
+	default:
+		return n == 0
+	}
+}
+
+// comparePackageVersions compares installed version v with a
+// constraint's version as dpkg does ("[epoch:]upstream[-revision]"): by
+// epoch, a missing one being 0, then upstream version, then revision.
+// rpm's epoch, version and release order the same way. A constraint
+// without a revision matches any, so "= 3.0.7" holds for 3.0.7-1ubuntu1.
+func comparePackageVersions(v, want string) int {
+	ve, vu, vr := splitPackageVersion(v)
+	we, wu, wr := splitPackageVersion(want)
+	if c := cmp.Compare(ve, we); c != 0 {
+		return c
+	}
+	if c := compareVersionPart(vu, wu); c != 0 || wr == "" {
+		return c
+	}
+	return compareVersionPart(vr, wr)
+}
+
+// splitPackageVersion splits "[epoch:]upstream[-revision]".
+func splitPackageVersion(v string) (epoch int, upstream, revision string) {
+	v = strings.TrimSpace(v)
+	if e, rest, ok := strings.Cut(v, ":"); ok {
+		if n, err := strconv.Atoi(e); err == nil {
+			epoch, v = n, rest
+		}
+	}
+	if i := strings.LastIndex(v, "-"); i >= 0 {
+		return epoch, v[:i], v[i+1:]
+	}
+	return epoch, v, ""
+}
+
+// compareVersionPart is dpkg's verrevcmp: alternating runs of non-digits,
+// compared character by character with letters before other symbols and
+// '~' before everything (even the end), and digits, compared as numbers.
+func compareVersionPart(a, b string) int {
+	for a != "" || b != "" {
+		for (a != "" && !isDigit(a[0])) || (b != "" && !isDigit(b[0])) {
+			ac, bc := versionCharOrder(a), versionCharOrder(b)
+			if ac != bc {
+				return cmp.Compare(ac, bc)
+			}
+			a, b = a[1:], b[1:]
+		}
+		a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+		first := 0
+		for a != "" && isDigit(a[0]) && b != "" && isDigit(b[0]) {
+			if first == 0 {
+				first = cmp.Compare(a[0], b[0])
+			}
+			a, b = a[1:], b[1:]
+		}
+		switch {
+		case a != "" && isDigit(a[0]):
+			return 1
+		case b != "" && isDigit(b[0]):
+			return -1
+		case first != 0:
+			return first
+		}
+	}
+	return 0
+}
+
+// versionCharOrder ranks the first character of s for compareVersionPart;
+// a digit or the end ranks 0.
+func versionCharOrder(s string) int {
+	switch {
+	case s == "" || isDigit(s[0]):
+		return 0
+	case s[0] == '~':
+		return -1
+	case ('a' <= s[0] && s[0] <= 'z') || ('A' <= s[0] && s[0] <= 'Z'):
+		return int(s[0])
+	}
+	return int(s[0]) + 256
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// packageRule is a parsed Denied or Required entry.
+type packageRule struct {
+	name        string
+	constraints []versionConstraint
+}
+
+// constrained reports whether the rule has version constraints.
+func (r packageRule) constrained() bool { return len(r.constraints) > 0 }
+
+// allows reports whether version v meets every constraint.
+func (r packageRule) allows(v string) bool {
+	for _, c := range r.constraints {
+		if !c.allows(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r packageRule) describe() string {
+	parts := make([]string, len(r.constraints))
+	for i, c := range r.constraints {
+		parts[i] = c.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// matches reports whether pkg's name matches the rule's name or glob.
+func (r packageRule) matches(pkg collector.Package) bool {
+	ok, _ := path.Match(strings.ToLower(r.name), strings.ToLower(pkg.Name))
+	return ok
+}
+
+// versionOps are tried longest first so ">=" isn't read as ">".
+var versionOps = []string{">=", "<=", "!=", "==", ">", "<", "="}
+
+// parsePackageRule reads "name [op version[, op version...]]".
+func parsePackageRule(s string) (packageRule, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexAny(s, " \t<>=!")
+	if i < 0 {
+		i = len(s)
+	}
+	r := packageRule{name: s[:i]}
+	if r.name == "" {
+		return r, fmt.Errorf("%q has no package name", s)
+	}
+	if _, err := path.Match(r.name, ""); err != nil {
+		return r, fmt.Errorf("bad pattern %q", r.name)
+	}
+	rest := strings.TrimSpace(s[i:])
+	if rest == "" {
+		return r, nil
+	}
+	cs, err := parseVersionConstraints(rest)
+	if err != nil {
+		return r, fmt.Errorf("%q: %w", s, err)
+	}
+	r.constraints = cs
+	return r, nil
+}
+
+// parseVersionConstraints reads "op version[, op version...]".
+func parseVersionConstraints(s string) ([]versionConstraint, error) {
+	var cs []versionConstraint
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		c := versionConstraint{}
+		for _, op := range versionOps {
+			if strings.HasPrefix(part, op) {
+				c.op, c.version = op, strings.TrimSpace(part[len(op):])
+				if op == "==" {
+					c.op = "="
+				}
+				break
+			}
+		}
+		if c.op == "" {
+			return nil, fmt.Errorf("constraint %q needs an operator (<, <=, >, >=, =, !=)", part)
+		}
+		if !isVersion(c.version) {
+			return nil, fmt.Errorf("%q is not a version", c.version)
+		}
+		cs = append(cs, c)
+	}
+	return cs, nil
+}
+
+// AnalyzePackages reports installed packages the policy denies or, with
+// allowed_packages, doesn't allow, and required packages that are
+// missing or at a version outside their constraints. Each denied package
+// is reported once, listing its installed versions. collected is false
+// when the packages collector failed, timed out or found no package
+// manager, and the run recorded that error; required packages are then
+// not checked, since a failed collection isn't a compliance failure. An
+// empty pkgs that was collected reports every required package missing.
+func AnalyzePackages(pkgs []collector.Package, collected bool, policies Policies) []Violation {
+	p := policies.Packages
+	if !policies.ChecksPackages() {
+		return nil
+	}
+	var v []Violation
+	add := func(category, msg string) {
+		v = append(v, Violation{
+			Category: category,
+			Severity: policies.severityFor(category),
+			Message:  msg,
+		})
+	}
+	for _, entry := range p.Denied {
+		rule, err := parsePackageRule(entry)
+		if err != nil {
+			continue // rejected by Validate
+		}
+		found := map[string][]string{}
+		for _, pkg := range pkgs {
+			if !rule.matches(pkg) || (rule.constrained() && (pkg.Version == "" || !rule.allows(pkg.Version))) {
+				continue
+			}
+			found[pkg.Name] = appendUnique(found[pkg.Name], pkg.Version)
+		}
+		for _, name := range sortedKeys(found) {
+			msg := fmt.Sprintf("denied package installed: %s %s", name, strings.Join(found[name], ", "))
+			if rule.constrained() {
+				msg += " (denied " + rule.describe() + ")"
+			}
+			add("package", strings.TrimSpace(msg))
+		}
+	}
+	if len(policies.AllowedPackages) > 0 {
+		var allowed []packageRule
+		for _, entry := range policies.AllowedPackages {
+			if rule, err := parsePackageRule(entry); err == nil {
+				allowed = append(allowed, rule)
+			}
+		}
+		found := map[string][]string{}
+		for _, pkg := range pkgs {
+			if !packageAllowed(allowed, pkg) {
+				found[pkg.Name] = appendUnique(found[pkg.Name], pkg.Version)
+			}
+		}
+		for _, name := range sortedKeys(found) {
+			add("package_not_allowed", strings.TrimSpace(fmt.Sprintf("package not in allowed_packages installed: %s %s", name, strings.Join(found[name], ", "))))
+		}
+	}
+	if !collected {
+		return v
+	}
+	for _, entry := range p.Required {
+		rule, err := parsePackageRule(entry)
+		if err != nil {
+			continue
+		}
+		var versions []string
+		installed, ok := false, false
+		for _, pkg := range pkgs {
+			if !rule.matches(pkg) {
+				continue
+			}
+			installed = true
+			if !rule.constrained() || (pkg.Version != "" && rule.allows(pkg.Version)) {
+				ok = true
+				break
+			}
+			ver := pkg.Version
+			if ver == "" {
+				ver = "unknown"
+			}
+			versions = appendUnique(versions, ver)
+		}
+		switch {
+		case !installed:
+			add("package_missing", fmt.Sprintf("required package %s is not installed", rule.name))
+		case !ok:
+			add("package_version", fmt.Sprintf("required package %s is version %s, required %s", rule.name, strings.Join(versions, ", "), rule.describe()))
+		}
+	}
+	return v
+}
+
+// packageAllowed reports whether one of the allowed rules matches pkg,
+// at a version inside its constraints when it has any.
+func packageAllowed(allowed []packageRule, pkg collector.Package) bool {
+	for _, rule := range allowed {
+		if rule.matches(pkg) && (!rule.constrained() || (pkg.Version != "" && rule.allows(pkg.Version))) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzePackages(t *testing.T) {
+	pkgs := []collector.Package{
+		{Name: "telnet", Version: "0.17+2.4-2", Source: "deb"},
+		{Name: "openssl", Version: "3.0.2-0ubuntu1.15", Source: "deb"},
+		{Name: "tigervnc-viewer", Version: "1.12.0", Source: "deb"},
+		{Name: "vnc4server", Version: "4.1.1", Source: "deb"},
+		{Name: "falcon-sensor", Version: "7.05.0-16004", Source: "deb"},
+		{Name: "curl", Version: "1:7.81.0-1ubuntu1.16", Source: "deb"},
+	}
+	p := Policies{Packages: PackagePolicy{
+		Denied:   []string{"telnet", "vnc*", "openssl < 3.0.7", "curl >= 1:8.0"},
+		Required: []string{"falcon-sensor >= 7.10, < 8", "auditd", "curl>=1:7.81"},
+	}}
+	require.NoError(t, p.Validate())
+
+	var msgs []string
+	for _, v := range AnalyzePackages(pkgs, true, p) {
+		msgs = append(msgs, string(v.Severity)+" "+v.Category+": "+v.Message)
+	}
+	assert.Equal(t, []string{
+		"high package: denied package installed: telnet 0.17+2.4-2",
+		"high package: denied package installed: vnc4server 4.1.1",
+		"high package: denied package installed: openssl 3.0.2-0ubuntu1.15 (denied < 3.0.7)",
+		"medium package_version: required package falcon-sensor is version 7.05.0-16004, required >= 7.10, < 8",
+		"high package_missing: required package auditd is not installed",
+	}, msgs)
+
+	assert.Empty(t, AnalyzePackages(pkgs, true, Policies{}))
+
+	// The collector failed: nothing is missing. Collected and none found
+	// (nil, as parsers return for no rows): everything required is.
+	assert.Empty(t, AnalyzePackages(nil, false, p))
+	assert.Len(t, AnalyzePackages(nil, true, p), 3)
+}
+
+func TestAnalyzePackages_Allowed(t *testing.T) {
+	pkgs := []collector.Package{
+		{Name: "bash", Version: "5.1-6"},
+		{Name: "openssl", Version: "3.0.2"},
+		{Name: "openssl", Version: "1.1.1"},
+		{Name: "libssl3", Version: "3.0.2"},
+		{Name: "nmap", Version: "7.80"},
+	}
+	p := Policies{AllowedPackages: []string{"bash", "lib*", "openssl >= 3.0"}}
+	require.NoError(t, p.Validate())
+	require.True(t, p.ChecksPackages())
+
+	var msgs []string
+	for _, v := range AnalyzePackages(pkgs, true, p) {
+		msgs = append(msgs, string(v.Severity)+" "+v.Category+": "+v.Message)
+	}
+	assert.Equal(t, []string{
+		"medium package_not_allowed: package not in allowed_packages installed: nmap 7.80",
+		"medium package_not_allowed: package not in allowed_packages installed: openssl 1.1.1",
+	}, msgs)
+
+	assert.ErrorContains(t, Policies{AllowedPackages: []string{"openssl >="}}.Validate(), "allowed_packages[0]")
+}
+
+func TestComparePackageVersions(t *testing.T) {
+	for _, tc := range []struct {
+		v, want string
+		cmp     int
+	}{
+		{"3.0.2-0ubuntu1.15", "3.0.7", -1},
+		{"3.0.7-1ubuntu1", "3.0.7", 0}, // no revision in the constraint
+		{"3.0.7-1ubuntu1", "3.0.7-2", -1},
+		{"1:7.81.0-1ubuntu1.16", "8.0", 1}, // the epoch wins
+		{"1:7.81.0", "1:8.0", -1},
+		{"1.1.1k", "1.1.1f", 1},
+		{"1.1.1", "1.1.1a", -1},
+		{"2.0~rc1", "2.0", -1},
+		{"1.10", "1.9", 1},
+		{"1.010", "1.10", 0},
+		{"7.05.0-16004", "7.10", -1},
+	} {
+		assert.Equal(t, tc.cmp, comparePackageVersions(tc.v, tc.want), "%s vs %s", tc.v, tc.want)
+	}
+}
+
+func TestParsePackageRule(t *testing.T) {
+	r, err := parsePackageRule("openssl==3.0.13")
+	require.NoError(t, err)
+	assert.Equal(t, "openssl", r.name)
+	assert.Equal(t, "= 3.0.13", r.describe())
+	assert.True(t, r.allows("3.0.13-1"))
+	assert.False(t, r.allows("3.0.14"))
+
+	r, err = parsePackageRule("kernel != 5.4.0, >= 5")
+	require.NoError(t, err)
+	assert.True(t, r.allows("6.8.0"))
+	assert.False(t, r.allows("5.4.0"))
+
+	_, err = parsePackageRule("openssl 3.0")
+	assert.ErrorContains(t, err, "needs an operator")
+	_, err = parsePackageRule("openssl < new")
+	assert.ErrorContains(t, err, "not a version")
+	_, err = parsePackageRule("[vnc")
+	assert.ErrorContains(t, err, "bad pattern")
+}
+
+func TestValidatePackageRules(t *testing.T) {
+	p := Policies{Packages: PackagePolicy{Denied: []string{"ok", ">= 1"}, Required: []string{"x <"}}}
+	err := p.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "packages.denied[1]")
+	assert.Contains(t, err.Error(), "packages.required[0]")
+}
@@ -0,0 +1,298 @@
+package analyzer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"compliance-agent/errcode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPolicies is what the agent enforces when no policy file is given.
+// It mirrors the historical hard-coded policy so upgrading doesn't change
+// results for existing deployments.
+func DefaultPolicies() Policies {
+	return Policies{
+		AllowedUsers: []string{"root", "admin"},
+		AllowedPorts: []int{22, 80, 443},
+	}
+}
+
+// LoadPolicies reads and validates a YAML policy file. Unknown keys are
+// rejected so a typo like `allowed_user:` fails loudly instead of silently
+// allowing everything.
+func LoadPolicies(path string) (Policies, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Policies{}, fmt.Errorf("read policy %s: %w", path, err)
+	}
+	p, err := ParsePolicies(b)
+	if err != nil {
+		return Policies{}, fmt.Errorf("policy %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// ParsePolicies decodes and validates policy YAML.
+func ParsePolicies(b []byte) (Policies, error) {
+	p := Policies{programs: &ruleCache{}}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil {
+		if errors.Is(err, io.EOF) {
+			return Policies{}, errcode.Errorf(errcode.ParseError, "policy file is empty")
+		}
+		return Policies{}, errcode.Errorf(errcode.ParseError, "parse: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return Policies{}, errcode.New(errcode.ParseError, err)
+	}
+	return p, nil
+}
+
+// Validate reports every problem in the policy at once, so operators can
+// fix a file in one pass.
+func (p Policies) Validate() error {
+	var problems []string
+	problems = append(problems, checkNames("allowed_users", p.AllowedUsers)...)
+	problems = append(problems, checkNames("allowed_packages", p.AllowedPackages)...)
+	problems = append(problems, checkNames("allowed_processes", p.AllowedProcesses)...)
+	for i, pat := range p.AllowedProcesses {
+		if _, err := regexp.Compile(pat); err != nil {
+			problems = append(problems, fmt.Sprintf("allowed_processes[%d]: %v", i, err))
+		}
+	}
+	for i, pat := range p.Processes.Blocked {
+		if _, err := regexp.Compile(pat); err != nil {
+			problems = append(problems, fmt.Sprintf("processes.blocked[%d]: %v", i, err))
+		}
+	}
+	if p.Processes.Strict && len(p.AllowedProcesses) == 0 {
+		problems = append(problems, "processes.strict: allowed_processes is empty, so every process would be reported")
+	}
+	for i, entry := range p.AllowedPackages {
+		if _, err := parsePackageRule(entry); err != nil {
+			problems = append(problems, fmt.Sprintf("allowed_packages[%d]: %v", i, err))
+		}
+	}
+	for i, entry := range p.Packages.Denied {
+		if _, err := parsePackageRule(entry); err != nil {
+			problems = append(problems, fmt.Sprintf("packages.denied[%d]: %v", i, err))
+		}
+	}
+	for i, entry := range p.Packages.Required {
+		if _, err := parsePackageRule(entry); err != nil {
+			problems = append(problems, fmt.Sprintf("packages.required[%d]: %v", i, err))
+		}
+	}
+	seen := map[int]bool{}
+	for i, port := range p.AllowedPorts {
+		if port < 1 || port > 65535 {
+			problems = append(problems, fmt.Sprintf("allowed_ports[%d]: %d is not a valid port (1-65535)", i, port))
+		}
+		if seen[port] {
+			problems = append(problems, fmt.Sprintf("allowed_ports[%d]: duplicate port %d", i, port))
+		}
+		seen[port] = true
+	}
+	for i, sig := range p.VPN.Signatures {
+		if strings.TrimSpace(sig.Name) == "" {
+			problems = append(problems, fmt.Sprintf("vpn.signatures[%d]: missing name", i))
+		}
+		if len(sig.Processes)+len(sig.Packages)+len(sig.Services) == 0 {
+			problems = append(problems, fmt.Sprintf("vpn.signatures[%d]: needs at least one process, package or service", i))
+		}
+	}
+	for i, c := range p.Connections.DeniedCountries {
+		if len(c) != 2 {
+			problems = append(problems, fmt.Sprintf("connections.denied_countries[%d]: %q is not an ISO 3166-1 alpha-2 code", i, c))
+		}
+	}
+	for i, pat := range p.Interfaces.AllowedVirtual {
+		if _, err := path.Match(pat, ""); err != nil {
+			problems = append(problems, fmt.Sprintf("interfaces.allowed_virtual[%d]: bad pattern %q", i, pat))
+		}
+	}
+	for i, cidr := range p.Interfaces.AllowedNetworks {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			problems = append(problems, fmt.Sprintf("interfaces.allowed_networks[%d]: %v", i, err))
+		}
+	}
+	for i, port := range p.TLS.Ports {
+		if port < 1 || port > 65535 {
+			problems = append(problems, fmt.Sprintf("tls.ports[%d]: %d is not a valid port (1-65535)", i, port))
+		}
+	}
+	if p.TLS.MinVersion != "" && tlsVersionRank(p.TLS.MinVersion) < 0 {
+		problems = append(problems, fmt.Sprintf("tls.min_version: unknown version %q (want one of %s)", p.TLS.MinVersion, strings.Join(tlsVersions, ", ")))
+	}
+	for i, raw := range p.Web.Endpoints {
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("web.endpoints[%d]: %q is not an http(s) URL", i, raw))
+		}
+	}
+	for i, pat := range p.Web.CredentialPatterns {
+		if _, err := regexp.Compile(pat); err != nil {
+			problems = append(problems, fmt.Sprintf("web.credential_patterns[%d]: %v", i, err))
+		}
+	}
+	agentNames := map[string]bool{}
+	for i, a := range p.RequiredAgents {
+		field := fmt.Sprintf("required_agents[%d]", i)
+		if a.Name == "" {
+			problems = append(problems, field+": name is required")
+		} else if agentNames[a.Name] {
+			problems = append(problems, fmt.Sprintf("%s: duplicate name %q", field, a.Name))
+		}
+		agentNames[a.Name] = true
+		if len(a.Binaries) == 0 && len(a.Packages) == 0 {
+			problems = append(problems, field+": set binaries or packages")
+		}
+		for j, c := range a.Configs {
+			if !filepath.IsAbs(c.Path) {
+				problems = append(problems, fmt.Sprintf("%s.configs[%d]: %q is not an absolute path", field, j, c.Path))
+			}
+			if c.SHA256 != "" && !sha256RE.MatchString(c.SHA256) {
+				problems = append(problems, fmt.Sprintf("%s.configs[%d]: sha256 must be 64 hex digits", field, j))
+			}
+		}
+	}
+	for i, dir := range p.Secrets.Paths {
+		if !filepath.IsAbs(dir) {
+			problems = append(problems, fmt.Sprintf("secrets.paths[%d]: %q is not an absolute path", i, dir))
+		}
+	}
+	for name, min := range p.OSVersion.MinVersions {
+		if !isVersion(min) {
+			problems = append(problems, fmt.Sprintf("os_version.min_versions.%s: %q is not a version", name, min))
+		}
+	}
+	if p.OSVersion.MinKernel != "" && !isVersion(p.OSVersion.MinKernel) {
+		problems = append(problems, fmt.Sprintf("os_version.min_kernel: %q is not a version", p.OSVersion.MinKernel))
+	}
+	for i, v := range p.SSHD.PermitRootLogin {
+		if !allowedRootLogin(permitRootLoginValues, v) {
+			problems = append(problems, fmt.Sprintf("sshd.permit_root_login[%d]: unknown value %q (want %s)", i, v, strings.Join(permitRootLoginValues, ", ")))
+		}
+	}
+	if p.SSHD.MaxAuthTries < 0 {
+		problems = append(problems, fmt.Sprintf("sshd.max_auth_tries: %d is negative", p.SSHD.MaxAuthTries))
+	}
+	if p.SSHD.ConfigPath != "" && !filepath.IsAbs(p.SSHD.ConfigPath) {
+		problems = append(problems, fmt.Sprintf("sshd.config_path: %q is not an absolute path", p.SSHD.ConfigPath))
+	}
+	for opt, want := range p.SSHD.Require {
+		if want == "" {
+			problems = append(problems, fmt.Sprintf("sshd.require.%s: value is empty", opt))
+		}
+	}
+	if p.Secrets.ExpiryWarningDays < 0 {
+		problems = append(problems, fmt.Sprintf("secrets.expiry_warning_days: %d is negative", p.Secrets.ExpiryWarningDays))
+	}
+	if p.Vulnerabilities.MinSeverity != "" {
+		if _, err := ParseSeverity(p.Vulnerabilities.MinSeverity); err != nil {
+			problems = append(problems, fmt.Sprintf("vulnerabilities.min_severity: %v", err))
+		}
+	}
+	problems = append(problems, checkNames("dns.denied_domains", p.DNS.DeniedDomains)...)
+	problems = append(problems, checkNames("dns.allowed_domains", p.DNS.AllowedDomains)...)
+	for i, name := range p.Profiles {
+		if _, ok := LookupProfile(name); !ok {
+			problems = append(problems, fmt.Sprintf("profiles[%d]: unknown profile %q (known: %s)", i, name, strings.Join(ProfileNames(), ", ")))
+		}
+	}
+	ruleNames := map[string]bool{}
+	for i, r := range p.Rules {
+		if strings.TrimSpace(r.Name) == "" {
+			problems = append(problems, fmt.Sprintf("rules[%d]: missing name", i))
+		} else if ruleNames[r.Name] {
+			problems = append(problems, fmt.Sprintf("rules[%d]: duplicate name %q", i, r.Name))
+		}
+		ruleNames[r.Name] = true
+		if r.Severity != "" {
+			if _, err := ParseSeverity(r.Severity); err != nil {
+				problems = append(problems, fmt.Sprintf("rules[%d]: %v", i, err))
+			}
+		}
+		c := r.compiled(p.programs)
+		if c.progErr != nil {
+			problems = append(problems, fmt.Sprintf("rules[%d]: %v", i, c.progErr))
+		}
+		if c.whenErr != nil {
+			problems = append(problems, fmt.Sprintf("rules[%d]: %v", i, c.whenErr))
+		}
+		for _, pr := range r.AppliesTo.validate() {
+			problems = append(problems, fmt.Sprintf("rules[%d].%s", i, pr))
+		}
+	}
+	_, orderProblems := ruleOrder(p.Rules)
+	problems = append(problems, orderProblems...)
+	problems = append(problems, p.Rego.validate()...)
+	scriptNames := map[string]bool{}
+	for i, sc := range p.Scripts {
+		if sc.Name == "" {
+			problems = append(problems, fmt.Sprintf("scripts[%d]: name is required", i))
+		} else if scriptNames[sc.Name] {
+			problems = append(problems, fmt.Sprintf("scripts[%d]: duplicate name %q", i, sc.Name))
+		}
+		scriptNames[sc.Name] = true
+		for _, pr := range sc.AppliesTo.validate() {
+			problems = append(problems, fmt.Sprintf("scripts[%d].%s", i, pr))
+		}
+		if (sc.Path == "") == (sc.Source == "") {
+			problems = append(problems, fmt.Sprintf("scripts[%d]: exactly one of path and source is required", i))
+			continue
+		}
+		if sc.Severity != "" {
+			if _, err := ParseSeverity(sc.Severity); err != nil {
+				problems = append(problems, fmt.Sprintf("scripts[%d]: %v", i, err))
+			}
+		}
+		if sc.Timeout < 0 || sc.MaxAllocs < 0 {
+			problems = append(problems, fmt.Sprintf("scripts[%d]: timeout and max_allocs must not be negative", i))
+		}
+		if err := sc.validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("scripts[%d]: %v", i, err))
+		}
+	}
+	for rule, sev := range p.Severities {
+		if _, err := ParseSeverity(sev); err != nil {
+			problems = append(problems, fmt.Sprintf("severities.%s: %v", rule, err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid policy:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func checkNames(field string, names []string) []string {
+	var problems []string
+	seen := map[string]bool{}
+	for i, n := range names {
+		if strings.TrimSpace(n) == "" {
+			problems = append(problems, fmt.Sprintf("%s[%d]: empty entry", field, i))
+			continue
+		}
+		if seen[n] {
+			problems = append(problems, fmt.Sprintf("%s[%d]: duplicate entry %q", field, i, n))
+		}
+		seen[n] = true
+	}
+	return problems
+}
+
+// isVersion reports whether s starts like a dotted version number.
+func isVersion(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
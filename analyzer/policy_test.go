@@ -0,0 +1,83 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"compliance-agent/collector"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPolicies_ParsesAllSections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+allowed_users: [root, deploy]
+allowed_ports: [22, 443]
+allowed_packages: [openssh-server]
+allowed_processes: [sshd, nginx]
+`), 0o644))
+
+	p, err := LoadPolicies(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"root", "deploy"}, p.AllowedUsers)
+	assert.Equal(t, []int{22, 443}, p.AllowedPorts)
+	assert.Equal(t, []string{"openssh-server"}, p.AllowedPackages)
+	assert.Equal(t, []string{"sshd", "nginx"}, p.AllowedProcesses)
+}
+
+func TestParsePolicies_RejectsUnknownKeys(t *testing.T) {
+	_, err := ParsePolicies([]byte("allowed_user: [root]\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "allowed_user")
+}
+
+func TestParsePolicies_ReportsAllProblems(t *testing.T) {
+	_, err := ParsePolicies([]byte(`
+allowed_users: [root, ""]
+allowed_ports: [22, 22, 70000]
+`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "allowed_users[1]: empty entry")
+	assert.Contains(t, err.Error(), "allowed_ports[1]: duplicate port 22")
+	assert.Contains(t, err.Error(), "allowed_ports[2]: 70000 is not a valid port")
+}
+
+func TestParsePolicies_Empty(t *testing.T) {
+	_, err := ParsePolicies(nil)
+	require.Error(t, err)
+}
+
+func TestParsePolicies_Severities(t *testing.T) {
+	p, err := ParsePolicies([]byte(`
+allowed_users: [root]
+severities:
+  user: Critical
+`))
+	require.NoError(t, err)
+	v := AnalyzeUsers([]collector.User{{Username: "mallory"}}, p)
+	require.Len(t, v, 1)
+	assert.Equal(t, SeverityCritical, v[0].Severity)
+
+	pv := AnalyzePorts([]collector.PortBinding{{Port: 8080, Protocol: "tcp"}}, p)
+	require.Len(t, pv, 1)
+	assert.Equal(t, SeverityMedium, pv[0].Severity, "built-in default")
+
+	_, err = ParsePolicies([]byte("severities:\n  port: urgent\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "severities.port")
+}
+
+func TestAnalyzePorts_NamesListeners(t *testing.T) {
+	v := AnalyzePorts([]collector.PortBinding{
+		{Port: 22, Protocol: "tcp", Address: "0.0.0.0", PID: 812, Process: "sshd"},
+		{Port: 8080, Protocol: "tcp", Address: "0.0.0.0", PID: 4242, Process: "java"},
+		{Port: 8080, Protocol: "tcp", Address: "::", PID: 4242, Process: "java"},
+		{Port: 5353, Protocol: "udp", Address: "0.0.0.0", PID: -1},
+	}, Policies{AllowedPorts: []int{22}})
+	require.Len(t, v, 2)
+	assert.Equal(t, "unexpected open port: 5353 (udp on 0.0.0.0)", v[0].Message)
+	assert.Equal(t, "unexpected open port: 8080 (tcp on 0.0.0.0 by java (pid 4242); tcp on :: by java (pid 4242))", v[1].Message)
+}
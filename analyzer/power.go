@@ -0,0 +1,45 @@
+package analyzer
+
+import "compliance-agent/collector"
+
+// LaptopPolicy holds power management rules that only apply to hosts with
+// an internal battery: a laptop left asleep in a bag should not be one
+// lid-open away from an unlocked session.
+type LaptopPolicy struct {
+	RequireSleepOnLidClose      bool `yaml:"require_sleep_on_lid_close"`
+	RequirePasswordAfterSleep   bool `yaml:"require_password_after_sleep"`
+	RequireEncryptedHibernation bool `yaml:"require_encrypted_hibernation"`
+}
+
+// Enabled reports whether any laptop rule is set, which is what triggers
+// power settings collection.
+func (l LaptopPolicy) Enabled() bool {
+	return l.RequireSleepOnLidClose || l.RequirePasswordAfterSleep || l.RequireEncryptedHibernation
+}
+
+// AnalyzePower applies the laptop policy. Desktops and servers, and
+// settings that couldn't be read, produce no violations.
+func AnalyzePower(ps collector.PowerSettings, policies Policies) []Violation {
+	l := policies.Laptop
+	if !ps.Laptop {
+		return nil
+	}
+	var v []Violation
+	add := func(category, msg string) {
+		v = append(v, Violation{
+			Category: category,
+			Severity: policies.severityFor(category),
+			Message:  msg,
+		})
+	}
+	if l.RequireSleepOnLidClose && ps.SleepOnLidClose != nil && !*ps.SleepOnLidClose {
+		add("sleep_on_lid_close", "laptop does not sleep when the lid is closed")
+	}
+	if l.RequirePasswordAfterSleep && ps.PasswordAfterSleep != nil && !*ps.PasswordAfterSleep {
+		add("password_after_sleep", "no password required after waking from sleep")
+	}
+	if l.RequireEncryptedHibernation && ps.HibernationEncrypted != nil && !*ps.HibernationEncrypted {
+		add("hibernation_encryption", "hibernation image is written to unencrypted storage")
+	}
+	return v
+}
@@ -0,0 +1,32 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzePower(t *testing.T) {
+	off, on := false, true
+	p := Policies{Laptop: LaptopPolicy{
+		RequireSleepOnLidClose:      true,
+		RequirePasswordAfterSleep:   true,
+		RequireEncryptedHibernation: true,
+	}}
+	ps := collector.PowerSettings{
+		Laptop:               true,
+		SleepOnLidClose:      &on,
+		PasswordAfterSleep:   &off,
+		HibernationEncrypted: nil, // unknown: not a finding
+	}
+
+	v := AnalyzePower(ps, p)
+	if assert.Len(t, v, 1) {
+		assert.Equal(t, "password_after_sleep", v[0].Category)
+		assert.Equal(t, SeverityHigh, v[0].Severity)
+	}
+
+	ps.Laptop = false
+	assert.Empty(t, AnalyzePower(ps, p), "desktops are out of scope")
+}
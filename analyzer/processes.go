@@ -0,0 +1,116 @@
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// ProcessPolicy flags processes that mustn't run, and optionally anything
+// not in allowed_processes.
+type ProcessPolicy struct {
+	// Blocked are regular expressions searched for in each process's name
+	// and path, e.g. ^nc$ or ^/tmp/.
+	Blocked []string `yaml:"blocked"`
+	// Strict reports every process that allowed_processes doesn't match.
+	// Allowed entries are regular expressions matched against the whole
+	// name or path, so a plain name allows exactly that name. Kernel
+	// threads are skipped.
+	Strict bool `yaml:"strict"`
+}
+
+// compileProcessPatterns compiles the patterns, anchored when whole is
+// set.
+func compileProcessPatterns(patterns []string, whole bool) ([]*regexp.Regexp, error) {
+	out := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		if whole {
+			p = "^(?:" + p + ")$"
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+// matchProcess returns the first pattern matching p's name or path.
+func matchProcess(res []*regexp.Regexp, p collector.Process) *regexp.Regexp {
+	for _, re := range res {
+		if re.MatchString(p.Name) || (p.Path != "" && re.MatchString(p.Path)) {
+			return re
+		}
+	}
+	return nil
+}
+
+// kernelThread reports processes with no executable behind them: Linux
+// kernel threads ("[kworker/0:1]" from ps, or no path and command line
+// from osquery) and the Windows System and Idle processes.
+func kernelThread(p collector.Process) bool {
+	return strings.HasPrefix(p.Name, "[") && strings.HasSuffix(p.Name, "]") || p.Path == "" && p.Cmdline == ""
+}
+
+// AnalyzeProcesses flags running processes that match a blocked pattern
+// and, in strict mode, those not in allowed_processes. Each process name
+// is reported once, listing its PIDs.
+func AnalyzeProcesses(procs []collector.Process, policies Policies) []Violation {
+	p := policies.Processes
+	blocked, err := compileProcessPatterns(p.Blocked, false)
+	if err != nil {
+		return nil // rejected by Validate
+	}
+	var allowed []*regexp.Regexp
+	if p.Strict {
+		if allowed, err = compileProcessPatterns(policies.AllowedProcesses, true); err != nil {
+			return nil
+		}
+	}
+	type finding struct {
+		name, why string
+		pids      []string
+	}
+	// Blocked processes are reported first.
+	byCategory := map[string]map[string]*finding{"process_blocked": {}, "process": {}}
+	for _, proc := range procs {
+		category, why := "", ""
+		if re := matchProcess(blocked, proc); re != nil {
+			category, why = "process_blocked", "matches blocked pattern "+re.String()
+		} else if p.Strict && !kernelThread(proc) && matchProcess(allowed, proc) == nil {
+			category, why = "process", "not in allowed_processes"
+		} else {
+			continue
+		}
+		f := byCategory[category][proc.Name]
+		if f == nil {
+			f = &finding{name: proc.Name, why: why}
+			byCategory[category][proc.Name] = f
+		}
+		f.pids = append(f.pids, fmt.Sprint(proc.PID))
+	}
+	var v []Violation
+	for _, category := range []string{"process_blocked", "process"} {
+		found := byCategory[category]
+		for _, name := range sortedKeys(found) {
+			f := found[name]
+			pids := "pid " + f.pids[0]
+			if len(f.pids) > 1 {
+				pids = "pids " + strings.Join(f.pids, ", ")
+			}
+			what := "unexpected"
+			if category == "process_blocked" {
+				what = "blocked"
+			}
+			v = append(v, Violation{
+				Category: category,
+				Severity: policies.severityFor(category),
+				Message:  fmt.Sprintf("%s process running: %s (%s; %s)", what, f.name, pids, f.why),
+			})
+		}
+	}
+	return v
+}
@@ -0,0 +1,50 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeProcesses(t *testing.T) {
+	procs := []collector.Process{
+		{PID: 1, Name: "systemd", Path: "/usr/lib/systemd/systemd", Cmdline: "/sbin/init"},
+		{PID: 2, Name: "[kthreadd]", Path: "[kthreadd]", Cmdline: "[kthreadd]"},
+		{PID: 3, Name: "kworker/0:1"},
+		{PID: 812, Name: "sshd", Path: "/usr/sbin/sshd", Cmdline: "sshd: /usr/sbin/sshd -D"},
+		{PID: 900, Name: "sshd-session", Path: "/usr/lib/openssh/sshd-session", Cmdline: "sshd-session"},
+		{PID: 4242, Name: "nc", Path: "/usr/bin/nc", Cmdline: "nc -l 4444"},
+		{PID: 4243, Name: "nc", Path: "/usr/bin/nc", Cmdline: "nc -l 4445"},
+		{PID: 5000, Name: "miner", Path: "/tmp/.x/miner", Cmdline: "/tmp/.x/miner"},
+	}
+	p := Policies{
+		AllowedProcesses: []string{"systemd", "sshd", "/usr/bin/.*"},
+		Processes:        ProcessPolicy{Blocked: []string{"^nc$", "^/tmp/"}, Strict: true},
+	}
+	require.NoError(t, p.Validate())
+
+	var msgs []string
+	for _, v := range AnalyzeProcesses(procs, p) {
+		msgs = append(msgs, string(v.Severity)+" "+v.Category+": "+v.Message)
+	}
+	assert.Equal(t, []string{
+		"high process_blocked: blocked process running: miner (pid 5000; matches blocked pattern ^/tmp/)",
+		"high process_blocked: blocked process running: nc (pids 4242, 4243; matches blocked pattern ^nc$)",
+		// "sshd" allows exactly sshd; kernel threads are skipped.
+		"medium process: unexpected process running: sshd-session (pid 900; not in allowed_processes)",
+	}, msgs)
+
+	p.Processes.Strict = false
+	assert.Len(t, AnalyzeProcesses(procs, p), 2, "blocklist only")
+	assert.Empty(t, AnalyzeProcesses(procs, Policies{}))
+}
+
+func TestValidate_Processes(t *testing.T) {
+	p := Policies{Processes: ProcessPolicy{Blocked: []string{"("}, Strict: true}}
+	err := p.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "processes.blocked[0]: error parsing regexp")
+	assert.Contains(t, err.Error(), "processes.strict: allowed_processes is empty")
+}
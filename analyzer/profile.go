@@ -0,0 +1,260 @@
+package analyzer
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"compliance-agent/collector"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profiles are built-in benchmark rule packs (CIS today), selected by name
+// with `--profile` or the policy's `profiles:` list. Each control reads
+// one fact through a collector probe and compares it with an expectation,
+// so a saved collection can be checked offline like everything else.
+
+//go:embed profiles/*.yaml
+var profileFiles embed.FS
+
+// Profile is one benchmark rule pack.
+type Profile struct {
+	Name  string `yaml:"name"`
+	Title string `yaml:"title"`
+	// Platform is the runtime.GOOS the pack applies to.
+	Platform string    `yaml:"platform"`
+	Controls []Control `yaml:"controls"`
+}
+
+// Control is one benchmark recommendation.
+type Control struct {
+	ID       string          `yaml:"id"`
+	Title    string          `yaml:"title"`
+	Severity Severity        `yaml:"severity"`
+	Probe    collector.Probe `yaml:"probe"`
+	// Default is the effective value when the probe finds nothing, e.g.
+	// sshd's built-in default for an unset option.
+	Default string      `yaml:"default"`
+	Expect  Expectation `yaml:"expect"`
+}
+
+// Expectation is what a compliant value looks like. Every set field must
+// hold.
+type Expectation struct {
+	// Equals and OneOf compare case-insensitively.
+	Equals     string   `yaml:"equals"`
+	OneOf      []string `yaml:"one_of"`
+	Matches    string   `yaml:"matches"`
+	NotMatches string   `yaml:"not_matches"`
+	Min        *float64 `yaml:"min"`
+	Max        *float64 `yaml:"max"`
+	// MaxMode is an octal permission mask; the file may not have any
+	// permission bit outside it.
+	MaxMode string `yaml:"max_mode"`
+
+	matches, notMatches *regexp.Regexp
+}
+
+var (
+	profilesOnce sync.Once
+	profiles     map[string]Profile
+)
+
+// builtinProfiles parses the embedded packs once. A broken pack is a
+// build defect, so it panics (and the tests catch it).
+func builtinProfiles() map[string]Profile {
+	profilesOnce.Do(func() {
+		profiles = map[string]Profile{}
+		entries, err := profileFiles.ReadDir("profiles")
+		if err != nil {
+			panic(err)
+		}
+		for _, e := range entries {
+			b, err := profileFiles.ReadFile(path.Join("profiles", e.Name()))
+			if err != nil {
+				panic(err)
+			}
+			p, err := parseProfile(b)
+			if err != nil {
+				panic(fmt.Sprintf("profile %s: %v", e.Name(), err))
+			}
+			profiles[p.Name] = p
+		}
+	})
+	return profiles
+}
+
+func parseProfile(b []byte) (Profile, error) {
+	var p Profile
+	if err := yaml.Unmarshal(b, &p); err != nil {
+		return Profile{}, err
+	}
+	for i := range p.Controls {
+		c := &p.Controls[i]
+		c.Probe.ID = p.Name + "/" + c.ID
+		var err error
+		if c.Expect.Matches != "" {
+			if c.Expect.matches, err = regexp.Compile(c.Expect.Matches); err != nil {
+				return Profile{}, fmt.Errorf("control %s: %w", c.ID, err)
+			}
+		}
+		if c.Expect.NotMatches != "" {
+			if c.Expect.notMatches, err = regexp.Compile(c.Expect.NotMatches); err != nil {
+				return Profile{}, fmt.Errorf("control %s: %w", c.ID, err)
+			}
+		}
+		if c.Expect.MaxMode != "" {
+			if _, err := strconv.ParseUint(c.Expect.MaxMode, 8, 32); err != nil {
+				return Profile{}, fmt.Errorf("control %s: max_mode: %w", c.ID, err)
+			}
+		}
+		if _, err := ParseSeverity(string(c.Severity)); err != nil {
+			return Profile{}, fmt.Errorf("control %s: %w", c.ID, err)
+		}
+	}
+	return p, nil
+}
+
+// ProfileNames lists the built-in profiles, sorted.
+func ProfileNames() []string {
+	var names []string
+	for n := range builtinProfiles() {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupProfile returns a built-in profile by name.
+func LookupProfile(name string) (Profile, bool) {
+	p, ok := builtinProfiles()[name]
+	return p, ok
+}
+
+// Probes returns the collector probes the profile's controls need.
+func (p Profile) Probes() []collector.Probe {
+	out := make([]collector.Probe, 0, len(p.Controls))
+	for _, c := range p.Controls {
+		out = append(out, c.Probe)
+	}
+	return out
+}
+
+// check compares a value against the expectation and returns why it
+// fails, or "" when it complies.
+func (e Expectation) check(value string) string {
+	v := strings.TrimSpace(value)
+	if e.Equals != "" && !strings.EqualFold(v, e.Equals) {
+		return fmt.Sprintf("is %q, want %q", v, e.Equals)
+	}
+	if len(e.OneOf) > 0 {
+		ok := false
+		for _, o := range e.OneOf {
+			ok = ok || strings.EqualFold(v, o)
+		}
+		if !ok {
+			return fmt.Sprintf("is %q, want one of %s", v, strings.Join(e.OneOf, ", "))
+		}
+	}
+	if e.matches != nil && !e.matches.MatchString(v) {
+		return fmt.Sprintf("is %q, want a match for %s", v, e.Matches)
+	}
+	if e.notMatches != nil && e.notMatches.MatchString(v) {
+		return fmt.Sprintf("is %q, must not match %s", v, e.NotMatches)
+	}
+	if e.Min != nil || e.Max != nil {
+		n, err := strconv.ParseFloat(v, 64)
+		switch {
+		case err != nil:
+			return fmt.Sprintf("is %q, want a number", v)
+		case e.Min != nil && n < *e.Min:
+			return fmt.Sprintf("is %s, want at least %s", v, formatNum(*e.Min))
+		case e.Max != nil && n > *e.Max:
+			return fmt.Sprintf("is %s, want at most %s", v, formatNum(*e.Max))
+		}
+	}
+	if e.MaxMode != "" {
+		mode, err := strconv.ParseUint(v, 8, 32)
+		if err != nil {
+			return fmt.Sprintf("mode %q is not octal", v)
+		}
+		mask, _ := strconv.ParseUint(e.MaxMode, 8, 32)
+		if mode&^mask != 0 {
+			return fmt.Sprintf("mode is %s, want %s or more restrictive", v, e.MaxMode)
+		}
+	}
+	return ""
+}
+
+func formatNum(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// AnalyzeProfiles checks the collected probe results against each profile
+// in the policy. Controls without a result (not collected, or not
+// applicable on this host) are skipped. Violations carry the benchmark
+// control ID.
+func AnalyzeProfiles(results []collector.ProbeResult, policies Policies) []Violation {
+	byID := map[string]collector.ProbeResult{}
+	for _, r := range results {
+		byID[r.ID] = r
+	}
+	var v []Violation
+	for _, name := range policies.Profiles {
+		p, ok := LookupProfile(name)
+		if !ok {
+			continue // rejected by Validate
+		}
+		for _, c := range p.Controls {
+			r, ok := byID[c.Probe.ID]
+			if !ok || r.NotApplicable {
+				continue
+			}
+			value := r.Value
+			if !r.Found {
+				if c.Default == "" {
+					v = append(v, profileViolation(c, policies, fmt.Sprintf("%s not found", probeSubject(c, r))))
+					continue
+				}
+				value = c.Default
+			}
+			if why := c.Expect.check(value); why != "" {
+				v = append(v, profileViolation(c, policies, probeSubject(c, r)+" "+why))
+			}
+		}
+	}
+	return v
+}
+
+func probeSubject(c Control, r collector.ProbeResult) string {
+	switch {
+	case c.Probe.Key != "":
+		return c.Probe.Key
+	case r.Source != "":
+		return r.Source
+	case len(c.Probe.Paths) > 0:
+		return c.Probe.Paths[0]
+	}
+	return strings.Join(c.Probe.Command, " ")
+}
+
+func profileViolation(c Control, policies Policies, detail string) Violation {
+	sev := c.Severity
+	if s, ok := policies.Severities["cis"]; ok {
+		if parsed, err := ParseSeverity(s); err == nil {
+			sev = parsed
+		}
+	}
+	return Violation{
+		Category: "cis",
+		Severity: sev,
+		Control:  c.ID,
+		Message:  fmt.Sprintf("CIS %s %s: %s", c.ID, c.Title, detail),
+	}
+}
@@ -0,0 +1,89 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinProfilesLoad(t *testing.T) {
+	names := ProfileNames()
+	assert.Contains(t, names, "cis-ubuntu-22.04")
+	assert.Contains(t, names, "cis-macos-14")
+	for _, name := range names {
+		p, ok := LookupProfile(name)
+		require.True(t, ok)
+		assert.NotEmpty(t, p.Platform, name)
+		seen := map[string]bool{}
+		for _, c := range p.Controls {
+			assert.False(t, seen[c.ID], "%s: duplicate control %s", name, c.ID)
+			seen[c.ID] = true
+			assert.Equal(t, name+"/"+c.ID, c.Probe.ID)
+			assert.Contains(t, []string{"file_setting", "file_mode", "command"}, c.Probe.Kind, "%s/%s", name, c.ID)
+		}
+	}
+}
+
+func TestAnalyzeProfiles(t *testing.T) {
+	p := Policies{Profiles: []string{"cis-ubuntu-22.04"}}
+	results := []collector.ProbeResult{
+		{ID: "cis-ubuntu-22.04/5.2.7", Value: "yes", Found: true, Source: "/etc/ssh/sshd_config"},
+		{ID: "cis-ubuntu-22.04/5.2.18", Value: "6", Found: true},
+		{ID: "cis-ubuntu-22.04/6.1.1", Value: "644", Found: true},
+		{ID: "cis-ubuntu-22.04/6.1.3", Value: "666", Found: true},
+		// Not set, so sshd's default applies.
+		{ID: "cis-ubuntu-22.04/5.2.22", Found: false},
+		{ID: "cis-ubuntu-22.04/5.2.5", NotApplicable: true},
+	}
+	v := AnalyzeProfiles(results, p)
+	byControl := map[string]Violation{}
+	for _, x := range v {
+		assert.Equal(t, "cis", x.Category)
+		byControl[x.Control] = x
+	}
+	assert.Len(t, byControl, len(v))
+	if assert.Contains(t, byControl, "5.2.7") {
+		assert.Equal(t, `CIS 5.2.7 Ensure SSH root login is disabled: PermitRootLogin is "yes", want "no"`, byControl["5.2.7"].Message)
+		assert.Equal(t, SeverityHigh, byControl["5.2.7"].Severity)
+	}
+	assert.Contains(t, byControl, "5.2.18")
+	assert.NotContains(t, byControl, "6.1.1")
+	assert.Contains(t, byControl, "6.1.3")
+	assert.Contains(t, byControl, "5.2.22")
+	assert.NotContains(t, byControl, "5.2.5")
+
+	// Without the profile selected, probe results are ignored.
+	assert.Empty(t, AnalyzeProfiles(results, Policies{}))
+}
+
+func TestExpectationCheck(t *testing.T) {
+	lo, hi := 1.0, 4.0
+	cases := []struct {
+		e     Expectation
+		value string
+		ok    bool
+	}{
+		{Expectation{Equals: "no"}, "No", true},
+		{Expectation{Equals: "no"}, "yes", false},
+		{Expectation{OneOf: []string{"INFO", "VERBOSE"}}, "verbose", true},
+		{Expectation{OneOf: []string{"INFO", "VERBOSE"}}, "QUIET", false},
+		{Expectation{Min: &lo, Max: &hi}, "4", true},
+		{Expectation{Min: &lo, Max: &hi}, "5", false},
+		{Expectation{Max: &hi}, "lots", false},
+		{Expectation{MaxMode: "640"}, "600", true},
+		{Expectation{MaxMode: "640"}, "644", false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.ok, c.e.check(c.value) == "", "%+v %q", c.e, c.value)
+	}
+}
+
+func TestValidateProfiles(t *testing.T) {
+	p := DefaultPolicies()
+	p.Profiles = []string{"cis-ubuntu-22.04", "cis-windows-11"}
+	err := p.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `profiles[1]: unknown profile "cis-windows-11"`)
+}
@@ -0,0 +1,72 @@
+package analyzer
+
+import "fmt"
+
+// DefaultRegoQuery is evaluated when the policy doesn't name one.
+const DefaultRegoQuery = "data.endpoint.violations"
+
+// RegoPolicy evaluates OPA policies against the collected report, for
+// teams that already keep their rules in Rego. The report is the input
+// document, in its JSON shape.
+//
+// The query must produce a set or array whose elements are either message
+// strings or objects with "message" and optional "category", "severity"
+// and "user" keys.
+type RegoPolicy struct {
+	// Paths are .rego (and data .json/.yaml) files or directories.
+	Paths []string `yaml:"paths"`
+	// Bundles are OPA bundle directories or .tar.gz files.
+	Bundles []string `yaml:"bundles"`
+	Query   string   `yaml:"query"`
+}
+
+// Enabled reports whether any Rego source is configured.
+func (r RegoPolicy) Enabled() bool {
+	return len(r.Paths)+len(r.Bundles) > 0
+}
+
+func (r RegoPolicy) query() string {
+	if r.Query != "" {
+		return r.Query
+	}
+	return DefaultRegoQuery
+}
+
+// violationFrom converts a violation reported by a Rego query or a
+// script: a message string, or an object with "message" and optional
+// "category", "severity" and "user". category and severity apply when the
+// object doesn't set its own; an empty severity falls back to the
+// category's.
+func violationFrom(item any, category, severity string, policies Policies) (Violation, error) {
+	viol := Violation{Category: category}
+	switch it := item.(type) {
+	case string:
+		viol.Message = it
+	case map[string]any:
+		str := func(k string) string {
+			s, _ := it[k].(string)
+			return s
+		}
+		viol.User, viol.Message = str("user"), str("message")
+		if viol.Message == "" {
+			return Violation{}, fmt.Errorf("violation %v has no message", it)
+		}
+		if c := str("category"); c != "" {
+			viol.Category = c
+		}
+		if s := str("severity"); s != "" {
+			severity = s
+		}
+	default:
+		return Violation{}, fmt.Errorf("unsupported violation %v (%T)", item, item)
+	}
+	viol.Severity = policies.severityFor(viol.Category)
+	if severity != "" {
+		sev, err := ParseSeverity(severity)
+		if err != nil {
+			return Violation{}, err
+		}
+		viol.Severity = sev
+	}
+	return viol, nil
+}
@@ -0,0 +1,26 @@
+//go:build no_rego || slim
+
+package analyzer
+
+import (
+	"context"
+	"errors"
+)
+
+var errNoRego = errors.New("rego: not compiled into this agent (built with no_rego)")
+
+// AnalyzeRego fails when the policy uses Rego, which this build left out.
+func AnalyzeRego(_ context.Context, _ any, policies Policies) ([]Violation, error) {
+	if !policies.Rego.Enabled() {
+		return nil, nil
+	}
+	return nil, errNoRego
+}
+
+// validate rejects a Rego policy up front rather than at the first scan.
+func (r RegoPolicy) validate() []string {
+	if !r.Enabled() {
+		return nil
+	}
+	return []string{errNoRego.Error()}
+}
@@ -0,0 +1,73 @@
+//go:build !no_rego && !slim
+
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"compliance-agent/buildinfo"
+
+	"github.com/open-policy-agent/opa/rego"
+)
+
+func init() { buildinfo.Register("rego") }
+
+// prepare loads and compiles the modules.
+func (r RegoPolicy) prepare(ctx context.Context) (rego.PreparedEvalQuery, error) {
+	opts := []func(*rego.Rego){rego.Query(r.query())}
+	if len(r.Paths) > 0 {
+		opts = append(opts, rego.Load(r.Paths, nil))
+	}
+	for _, b := range r.Bundles {
+		opts = append(opts, rego.LoadBundle(b))
+	}
+	return rego.New(opts...).PrepareForEval(ctx)
+}
+
+// AnalyzeRego evaluates the Rego policy with input as the input document.
+// Unlike the built-in analyzers it can fail at run time (a bundle that
+// went missing, a rule that errors), so it returns an error.
+func AnalyzeRego(ctx context.Context, input any, policies Policies) ([]Violation, error) {
+	if !policies.Rego.Enabled() {
+		return nil, nil
+	}
+	pq, err := policies.Rego.prepare(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("rego: %w", err)
+	}
+	rs, err := pq.Eval(ctx, rego.EvalInput(input))
+	if err != nil {
+		return nil, fmt.Errorf("rego: %w", err)
+	}
+	var v []Violation
+	for _, r := range rs {
+		for _, e := range r.Expressions {
+			items, ok := e.Value.([]any)
+			if !ok {
+				return nil, fmt.Errorf("rego: %s returned %T, want a set or array", e.Text, e.Value)
+			}
+			for _, item := range items {
+				viol, err := violationFrom(item, "rego", "", policies)
+				if err != nil {
+					return nil, fmt.Errorf("rego: %s: %w", e.Text, err)
+				}
+				v = append(v, viol)
+			}
+		}
+	}
+	return v, nil
+}
+
+// validate compiles the configured modules so syntax errors surface when
+// the policy loads.
+func (r RegoPolicy) validate() []string {
+	if !r.Enabled() {
+		return nil
+	}
+	if _, err := r.prepare(context.Background()); err != nil {
+		return []string{fmt.Sprintf("rego: %s", strings.TrimSpace(err.Error()))}
+	}
+	return nil
+}
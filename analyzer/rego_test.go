@@ -0,0 +1,55 @@
+//go:build !no_rego && !slim
+
+package analyzer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRego = `package endpoint
+
+violations[v] {
+	u := input.users[_]
+	u.uid == 0
+	u.username != "root"
+	v := {"category": "uid0", "severity": "critical", "message": sprintf("%s has uid 0", [u.username])}
+}
+
+violations[msg] {
+	input.open_ports[_] == 23
+	msg := "telnet port open"
+}
+`
+
+func TestAnalyzeRego(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "endpoint.rego"), []byte(testRego), 0o644))
+	p := Policies{Rego: RegoPolicy{Paths: []string{dir}}}
+	require.Empty(t, p.Rego.validate())
+
+	input := map[string]any{
+		"users":      []any{map[string]any{"username": "root", "uid": 0}, map[string]any{"username": "toor", "uid": 0}},
+		"open_ports": []any{22, 23},
+	}
+	v, err := AnalyzeRego(context.Background(), input, p)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Violation{
+		{Category: "uid0", Severity: SeverityCritical, Message: "toor has uid 0"},
+		{Category: "rego", Severity: SeverityMedium, Message: "telnet port open"},
+	}, v)
+}
+
+func TestRegoPolicy_ValidateSyntaxError(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bad.rego"), []byte("package endpoint\nviolations[x] {"), 0o644))
+	problems := RegoPolicy{Paths: []string{dir}}.validate()
+	if assert.Len(t, problems, 1) {
+		assert.Contains(t, problems[0], "rego:")
+	}
+}
@@ -0,0 +1,158 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// severityWeights are the base of a violation's risk score.
+var severityWeights = map[Severity]float64{
+	SeverityCritical: 10,
+	SeverityHigh:     7,
+	SeverityMedium:   4,
+	SeverityLow:      2,
+	SeverityInfo:     1,
+}
+
+// criticalityFactors scale every finding on a host by how much the host
+// matters.
+var criticalityFactors = map[string]float64{
+	"low":      0.5,
+	"medium":   1,
+	"high":     1.5,
+	"critical": 2,
+}
+
+// NetworkExposure is the default exposure factor of findings an attacker
+// can reach over the network; local-only findings get 1.
+const NetworkExposure = 1.5
+
+// networkCategories are the violation categories exposed to the network
+// by default.
+var networkCategories = map[string]bool{
+	"port":                    true,
+	"root_listener":           true,
+	"listeners":               true,
+	"sharing":                 true,
+	"firewall":                true,
+	"ssh_protocol":            true,
+	"ssh_root_login":          true,
+	"ssh_password_auth":       true,
+	"ssh_max_auth_tries":      true,
+	"ssh_config":              true,
+	"tls_protocol":            true,
+	"tls_cipher":              true,
+	"web_hsts":                true,
+	"web_https_redirect":      true,
+	"web_default_credentials": true,
+	"network_threat":          true,
+	"vulnerability":           true,
+}
+
+// RiskModel scores violations as severity weight × host criticality ×
+// exposure, so a high finding on an exposed, critical server outranks a
+// critical one on a lab machine.
+type RiskModel struct {
+	// Criticality is the host's factor (see ParseCriticality); zero is
+	// treated as 1.
+	Criticality float64
+	// Exposure overrides the exposure factor of categories; the rest get
+	// NetworkExposure if network-exposed and 1 otherwise.
+	Exposure map[string]float64
+}
+
+// ParseCriticality maps a host criticality name (low, medium, high,
+// critical; empty is medium) to its factor.
+func ParseCriticality(name string) (float64, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return 1, nil
+	}
+	f, ok := criticalityFactors[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown criticality %q (want low, medium, high or critical)", name)
+	}
+	return f, nil
+}
+
+// Score is v's risk, rounded to one decimal place.
+func (m RiskModel) Score(v Violation) float64 {
+	sev := v.Severity
+	if sev == "" {
+		sev = SeverityMedium
+	}
+	crit := m.Criticality
+	if crit == 0 {
+		crit = 1
+	}
+	exposure, ok := m.Exposure[v.Category]
+	if !ok {
+		exposure = 1
+		if networkCategories[v.Category] {
+			exposure = NetworkExposure
+		}
+	}
+	return math.Round(severityWeights[sev]*crit*exposure*10) / 10
+}
+
+// ScoreRisk sets each violation's Risk and sorts them by it (see
+// SortByRisk).
+func ScoreRisk(violations []Violation, m RiskModel) {
+	for i := range violations {
+		violations[i].Risk = m.Score(violations[i])
+	}
+	SortByRisk(violations)
+}
+
+// SortByRisk orders violations riskiest first; ties go by severity, then
+// category and message, so the order is stable between runs.
+func SortByRisk(violations []Violation) {
+	sort.SliceStable(violations, func(i, j int) bool {
+		a, b := violations[i], violations[j]
+		if a.Risk != b.Risk {
+			return a.Risk > b.Risk
+		}
+		if ra, rb := a.Severity.Rank(), b.Severity.Rank(); ra != rb {
+			return ra > rb
+		}
+		if a.Category != b.Category {
+			return a.Category < b.Category
+		}
+		return a.Message < b.Message
+	})
+}
+
+// RiskGroup is the violations of one category.
+type RiskGroup struct {
+	Category   string
+	Risk       float64 // the highest in the group
+	Violations []Violation
+}
+
+// GroupByRisk groups violations by category, riskiest group first, each
+// group's violations riskiest first. Uncategorized violations group as
+// "unknown".
+func GroupByRisk(violations []Violation) []RiskGroup {
+	sorted := append([]Violation(nil), violations...)
+	SortByRisk(sorted)
+	index := map[string]int{}
+	var groups []RiskGroup
+	for _, v := range sorted {
+		cat := v.Category
+		if cat == "" {
+			cat = "unknown"
+		}
+		i, ok := index[cat]
+		if !ok {
+			// The first of a category seen is its riskiest, so groups
+			// come out in order.
+			i = len(groups)
+			index[cat] = i
+			groups = append(groups, RiskGroup{Category: cat, Risk: v.Risk})
+		}
+		groups[i].Violations = append(groups[i].Violations, v)
+	}
+	return groups
+}
@@ -0,0 +1,64 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiskModel_Score(t *testing.T) {
+	m := RiskModel{Criticality: 1.5}
+	assert.Equal(t, 15.0, m.Score(Violation{Category: "user", Severity: SeverityCritical}))
+	assert.Equal(t, 15.8, m.Score(Violation{Category: "port", Severity: SeverityHigh}), "network exposed, 7 × 1.5 × 1.5")
+	assert.Equal(t, 6.0, m.Score(Violation{Category: "user"}), "no severity counts as medium")
+
+	m = RiskModel{Exposure: map[string]float64{"port": 1, "user": 3}}
+	assert.Equal(t, 7.0, m.Score(Violation{Category: "port", Severity: SeverityHigh}))
+	assert.Equal(t, 21.0, m.Score(Violation{Category: "user", Severity: SeverityHigh}))
+}
+
+func TestScoreRisk_Order(t *testing.T) {
+	violations := []Violation{
+		{Category: "user", Severity: SeverityHigh, Message: "eve"},
+		{Category: "package", Severity: SeverityInfo, Message: "pkg"},
+		{Category: "port", Severity: SeverityHigh, Message: "8080"},
+		{Category: "user", Severity: SeverityHigh, Message: "bob"},
+		{Category: "disk_encryption", Severity: SeverityCritical, Message: "/"},
+	}
+	ScoreRisk(violations, RiskModel{})
+	var got []string
+	for _, v := range violations {
+		got = append(got, v.Message)
+	}
+	assert.Equal(t, []string{"8080", "/", "bob", "eve", "pkg"}, got)
+	assert.Equal(t, 10.5, violations[0].Risk)
+}
+
+func TestGroupByRisk(t *testing.T) {
+	violations := []Violation{
+		{Category: "user", Risk: 7, Message: "eve"},
+		{Category: "port", Risk: 6, Message: "8080"},
+		{Category: "port", Risk: 10.5, Message: "22"},
+		{Risk: 1, Message: "?"},
+	}
+	groups := GroupByRisk(violations)
+	require.Len(t, groups, 3)
+	assert.Equal(t, "port", groups[0].Category)
+	assert.Equal(t, 10.5, groups[0].Risk)
+	assert.Equal(t, "22", groups[0].Violations[0].Message)
+	assert.Equal(t, "user", groups[1].Category)
+	assert.Equal(t, "unknown", groups[2].Category)
+	assert.Equal(t, "eve", violations[0].Message, "input left alone")
+}
+
+func TestParseCriticality(t *testing.T) {
+	f, err := ParseCriticality("")
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, f)
+	f, err = ParseCriticality("Critical")
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, f)
+	_, err = ParseCriticality("vital")
+	assert.ErrorContains(t, err, "unknown criticality")
+}
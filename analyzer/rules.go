@@ -0,0 +1,474 @@
+package analyzer
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+
+	"compliance-agent/collector"
+
+	"github.com/google/cel-go/cel"
+	"golang.org/x/sync/errgroup"
+)
+
+// Rule is an operator-written check: a CEL expression evaluated against
+// each item of Target. An expression that returns true is a violation.
+//
+//	rules:
+//	  - name: telnet-running
+//	    target: process
+//	    expr: process.name == "telnetd"
+//	    severity: critical
+//	  - name: rdp-exposed
+//	    when: host.platform == "windows"
+//	    target: port
+//	    expr: port.port == 3389 && port.address == "0.0.0.0"
+type Rule struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// Target is user, process, package, port, connection or host. The
+	// expression sees the item as a variable of the same name, except
+	// packages are "pkg" (package is a reserved word in CEL).
+	Target   string `yaml:"target"`
+	Expr     string `yaml:"expr"`
+	Severity string `yaml:"severity"`
+	// When is a CEL condition on `host` (as for target host); the rule
+	// only runs where it holds, so checks for software or a platform the
+	// host doesn't have aren't evaluated at all.
+	When string `yaml:"when"`
+	// DependsOn names rules that must run and pass first. A rule is
+	// skipped when a dependency was skipped or found violations, so one
+	// failed precondition doesn't cascade into findings that follow from
+	// it.
+	DependsOn []string `yaml:"depends_on"`
+	// AppliesTo limits the rule to some platforms and OS releases.
+	// Unlike When, a rule that doesn't apply is listed in the report as
+	// not applicable.
+	AppliesTo AppliesTo `yaml:"applies_to"`
+}
+
+// Inventory is the collected data rules are evaluated against.
+type Inventory struct {
+	Hostname    string
+	Users       []collector.User
+	Processes   []collector.Process
+	Packages    []collector.Package
+	Ports       []collector.PortBinding
+	Connections []collector.Connection
+	// Platform is the OS the data was collected on (runtime.GOOS).
+	Platform string
+}
+
+// ruleVars maps a target onto the CEL variable it binds.
+var ruleVars = map[string]string{
+	"user":       "user",
+	"process":    "process",
+	"package":    "pkg",
+	"port":       "port",
+	"connection": "connection",
+	"host":       "host",
+}
+
+// RuleTargets lists the rule targets in the order they are documented.
+var RuleTargets = []string{"user", "process", "package", "port", "connection", "host"}
+
+// RuleVariable returns the CEL variable a rule target binds and a sample
+// of its fields, each holding its type's zero value. The host sample's
+// lists hold one sample item. ok is false for an unknown target.
+func RuleVariable(target string) (name string, sample map[string]any, ok bool) {
+	name, ok = ruleVars[target]
+	if !ok {
+		return "", nil, false
+	}
+	inv := Inventory{
+		Users:       []collector.User{{}},
+		Processes:   []collector.Process{{}},
+		Packages:    []collector.Package{{}},
+		Ports:       []collector.PortBinding{{}},
+		Connections: []collector.Connection{{}},
+	}
+	return name, ruleItems(target, inv)[0].vars, true
+}
+
+// compile type-checks the expression and prepares it for evaluation.
+func (r Rule) compile() (cel.Program, error) {
+	v, ok := ruleVars[r.Target]
+	if !ok {
+		return nil, fmt.Errorf("unknown target %q (want user, process, package, port, connection or host)", r.Target)
+	}
+	env, err := cel.NewEnv(cel.Variable(v, cel.DynType))
+	if err != nil {
+		return nil, err
+	}
+	ast, iss := env.Compile(r.Expr)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+	if t := ast.OutputType(); t != cel.BoolType && t != cel.DynType {
+		return nil, fmt.Errorf("expression returns %s, want bool", t)
+	}
+	return env.Program(ast)
+}
+
+// compileWhen prepares the rule's condition; nil when it has none.
+func (r Rule) compileWhen() (cel.Program, error) {
+	if r.When == "" {
+		return nil, nil
+	}
+	env, err := cel.NewEnv(cel.Variable("host", cel.DynType))
+	if err != nil {
+		return nil, err
+	}
+	ast, iss := env.Compile(r.When)
+	if iss.Err() != nil {
+		return nil, fmt.Errorf("when: %w", iss.Err())
+	}
+	if t := ast.OutputType(); t != cel.BoolType && t != cel.DynType {
+		return nil, fmt.Errorf("when: expression returns %s, want bool", t)
+	}
+	return env.Program(ast)
+}
+
+// compiledRule is a rule's expression and When condition, ready to run.
+// cel.Programs are safe for concurrent use.
+type compiledRule struct {
+	prog, when       cel.Program // when is nil for a rule without one
+	progErr, whenErr error
+}
+
+type ruleKey struct{ target, expr, when string }
+
+// ruleCache holds a policy's compiled rules by target and expressions.
+// CEL compilation is the expensive part of a rule, so it happens when the
+// policy is validated and is then shared by every scan of that policy,
+// rather than repeated each daemon interval. A reloaded policy gets a new
+// cache, so rules no longer in force are dropped with the old one.
+type ruleCache struct {
+	m sync.Map // ruleKey -> *compiledRule
+}
+
+// compiled returns the rule compiled, compiling it on first use. A nil
+// cache, for a policy that wasn't parsed, compiles it every time.
+func (r Rule) compiled(cache *ruleCache) *compiledRule {
+	k := ruleKey{r.Target, r.Expr, r.When}
+	if cache != nil {
+		if c, ok := cache.m.Load(k); ok {
+			return c.(*compiledRule)
+		}
+	}
+	c := &compiledRule{}
+	c.prog, c.progErr = r.compile()
+	c.when, c.whenErr = r.compileWhen()
+	if cache == nil {
+		return c
+	}
+	actual, _ := cache.m.LoadOrStore(k, c)
+	return actual.(*compiledRule)
+}
+
+// ruleOrder sorts rules so that each comes after the rules it depends on,
+// otherwise keeping policy order. problems lists unknown dependencies and
+// cycles; rules caught in either are still ordered, and are then skipped
+// because a dependency never passes.
+func ruleOrder(rules []Rule) (order []Rule, problems []string) {
+	byName := map[string]Rule{}
+	for _, r := range rules {
+		byName[r.Name] = r
+	}
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var visit func(r Rule, path []string)
+	visit = func(r Rule, path []string) {
+		switch state[r.Name] {
+		case done:
+			return
+		case visiting:
+			for i, name := range path {
+				if name == r.Name {
+					path = path[i:]
+					break
+				}
+			}
+			problems = append(problems, fmt.Sprintf("rules: dependency cycle %s", strings.Join(append(path, r.Name), " -> ")))
+			return
+		}
+		state[r.Name] = visiting
+		for _, d := range r.DependsOn {
+			dep, ok := byName[d]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("rules: %q depends on unknown rule %q", r.Name, d))
+				continue
+			}
+			visit(dep, append(path, r.Name))
+		}
+		state[r.Name] = done
+		order = append(order, r)
+	}
+	for _, r := range rules {
+		visit(r, nil)
+	}
+	return order, problems
+}
+
+// ruleWorkers bounds how many rules are evaluated at once.
+var ruleWorkers = runtime.GOMAXPROCS(0)
+
+// AnalyzeRules evaluates the policy rules in dependency order. A rule
+// whose When condition doesn't hold, or whose dependencies didn't all
+// pass, is skipped. A rule that fails to evaluate on an item (e.g. a
+// missing field) is skipped for that item rather than failing the run.
+//
+// Rules run in waves: each wave holds the rules whose dependencies are
+// all in earlier waves, evaluated concurrently by up to ruleWorkers
+// goroutines. Violations are joined in dependency order, so the result
+// doesn't depend on scheduling.
+func AnalyzeRules(inv Inventory, policies Policies) []Violation {
+	order, _ := ruleOrder(policies.Rules)
+	level := map[string]int{}
+	var waves [][]int
+	for i, r := range order {
+		// A dependency not yet levelled is unknown or in a cycle; the
+		// rule is skipped either way.
+		l := 0
+		for _, d := range r.DependsOn {
+			if dl, ok := level[d]; ok && dl+1 > l {
+				l = dl + 1
+			}
+		}
+		level[r.Name] = l
+		for len(waves) <= l {
+			waves = append(waves, nil)
+		}
+		waves[l] = append(waves[l], i)
+	}
+
+	// Each target's items are built once and shared read-only by every
+	// rule on it.
+	items := map[string][]ruleItem{}
+	var host map[string]any
+	for _, r := range order {
+		if _, ok := items[r.Target]; !ok {
+			items[r.Target] = ruleItems(r.Target, inv)
+		}
+		if r.When != "" && host == nil {
+			host = hostVars(inv)
+		}
+	}
+
+	compiled := make([]*compiledRule, len(order))
+	for i, r := range order {
+		compiled[i] = r.compiled(policies.programs)
+	}
+
+	results := make([][]Violation, len(order))
+	ran := make([]bool, len(order))
+	passed := map[string]bool{}
+	for _, wave := range waves {
+		var g errgroup.Group
+		g.SetLimit(ruleWorkers)
+		var mu sync.Mutex
+		var panicked any
+		for _, i := range wave {
+			r := order[i]
+			if !dependenciesPassed(r, passed) {
+				continue
+			}
+			g.Go(func() error {
+				// Re-raised below, on the caller's goroutine, where the
+				// analyzer's panic guard can contain it.
+				defer func() {
+					if p := recover(); p != nil {
+						mu.Lock()
+						panicked = p
+						mu.Unlock()
+					}
+				}()
+				results[i], ran[i] = evalRule(r, compiled[i], items[r.Target], host, policies)
+				return nil
+			})
+		}
+		_ = g.Wait()
+		if panicked != nil {
+			panic(panicked)
+		}
+		for _, i := range wave {
+			if ran[i] {
+				passed[order[i].Name] = len(results[i]) == 0
+			}
+		}
+	}
+	var v []Violation
+	for _, r := range results {
+		v = append(v, r...)
+	}
+	return v
+}
+
+func dependenciesPassed(r Rule, passed map[string]bool) bool {
+	for _, d := range r.DependsOn {
+		if !passed[d] {
+			return false
+		}
+	}
+	return true
+}
+
+// evalRule runs one compiled rule over its target's items. ran is false
+// when the rule doesn't compile or its When condition doesn't hold.
+func evalRule(r Rule, c *compiledRule, items []ruleItem, host map[string]any, policies Policies) (v []Violation, ran bool) {
+	if c.progErr != nil || c.whenErr != nil {
+		return nil, false // rejected by Validate; only reachable for unvalidated policies
+	}
+	if c.when != nil {
+		out, _, err := c.when.Eval(map[string]any{"host": host})
+		if err != nil {
+			return nil, false
+		}
+		if ok, _ := out.Value().(bool); !ok {
+			return nil, false
+		}
+	}
+	sev := policies.severityFor(r.Name)
+	if s, err := ParseSeverity(r.Severity); err == nil {
+		sev = s
+	}
+	desc := r.Description
+	if desc == "" {
+		desc = r.Expr
+	}
+	for _, item := range items {
+		out, _, err := c.prog.Eval(map[string]any{ruleVars[r.Target]: item.vars})
+		if err != nil {
+			continue
+		}
+		if hit, ok := out.Value().(bool); ok && hit {
+			v = append(v, Violation{
+				Category: r.Name,
+				Severity: sev,
+				Message:  fmt.Sprintf("%s: %s", item.subject, desc),
+			})
+		}
+	}
+	return v, true
+}
+
+type ruleItem struct {
+	subject string
+	vars    map[string]any
+}
+
+func ruleItems(target string, inv Inventory) []ruleItem {
+	var items []ruleItem
+	switch target {
+	case "user":
+		for _, u := range inv.Users {
+			items = append(items, ruleItem{"user " + u.Username, userVars(u)})
+		}
+	case "process":
+		for _, p := range inv.Processes {
+			items = append(items, ruleItem{fmt.Sprintf("process %s (pid %d)", p.Name, p.PID), processVars(p)})
+		}
+	case "package":
+		for _, p := range inv.Packages {
+			items = append(items, ruleItem{strings.TrimSpace("package " + p.Name + " " + p.Version), packageVars(p)})
+		}
+	case "port":
+		for _, b := range inv.Ports {
+			items = append(items, ruleItem{fmt.Sprintf("port %d/%s", b.Port, b.Protocol), portVars(b)})
+		}
+	case "connection":
+		for _, c := range inv.Connections {
+			items = append(items, ruleItem{fmt.Sprintf("connection %s to %s:%d", connectionOwner(c), c.RemoteAddress, c.RemotePort), connectionVars(c)})
+		}
+	case "host":
+		items = append(items, ruleItem{"host " + inv.Hostname, hostVars(inv)})
+	}
+	return items
+}
+
+func userVars(u collector.User) map[string]any {
+	return map[string]any{
+		"username":    u.Username,
+		"uid":         u.UID,
+		"gid":         u.GID,
+		"description": u.Description,
+		"directory":   u.Directory,
+		"shell":       u.Shell,
+		"sid":         u.SID,
+	}
+}
+
+func processVars(p collector.Process) map[string]any {
+	return map[string]any{
+		"pid":     p.PID,
+		"name":    p.Name,
+		"path":    p.Path,
+		"cmdline": p.Cmdline,
+		"uid":     p.UID,
+		"user":    p.User,
+	}
+}
+
+func packageVars(p collector.Package) map[string]any {
+	return map[string]any{
+		"name":    p.Name,
+		"version": p.Version,
+		"source":  p.Source,
+		"arch":    p.Arch,
+	}
+}
+
+func portVars(b collector.PortBinding) map[string]any {
+	return map[string]any{
+		"port":     b.Port,
+		"protocol": b.Protocol,
+		"address":  b.Address,
+	}
+}
+
+func connectionVars(c collector.Connection) map[string]any {
+	return map[string]any{
+		"pid":            c.PID,
+		"process":        c.Process,
+		"protocol":       c.Protocol,
+		"local_address":  c.LocalAddress,
+		"local_port":     c.LocalPort,
+		"remote_address": c.RemoteAddress,
+		"remote_port":    c.RemotePort,
+		"country":        c.Country,
+		"asn":            int(c.ASN),
+		"as_org":         c.ASOrg,
+	}
+}
+
+func hostVars(inv Inventory) map[string]any {
+	var users, procs, pkgs, ports, conns []any
+	for _, u := range inv.Users {
+		users = append(users, userVars(u))
+	}
+	for _, p := range inv.Processes {
+		procs = append(procs, processVars(p))
+	}
+	for _, p := range inv.Packages {
+		pkgs = append(pkgs, packageVars(p))
+	}
+	for _, b := range inv.Ports {
+		ports = append(ports, portVars(b))
+	}
+	for _, c := range inv.Connections {
+		conns = append(conns, connectionVars(c))
+	}
+	return map[string]any{
+		"hostname":    inv.Hostname,
+		"platform":    inv.Platform,
+		"users":       users,
+		"processes":   procs,
+		"packages":    pkgs,
+		"ports":       ports,
+		"connections": conns,
+	}
+}
@@ -0,0 +1,169 @@
+package analyzer
+
+import (
+	"fmt"
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeRules(t *testing.T) {
+	inv := Inventory{
+		Hostname: "web-1",
+		Users: []collector.User{
+			{Username: "root", UID: 0},
+			{Username: "toor", UID: 0},
+			{Username: "alice", UID: 1000},
+		},
+		Processes:   []collector.Process{{PID: 42, Name: "telnetd"}, {PID: 1, Name: "init"}},
+		Ports:       []collector.PortBinding{{Port: 23, Protocol: "tcp"}},
+		Connections: []collector.Connection{{PID: 42, Process: "telnetd", RemoteAddress: "203.0.113.7", RemotePort: 4444, ASN: 64500}},
+	}
+	p := Policies{Rules: []Rule{
+		{Name: "uid0-alias", Target: "user", Expr: `user.uid == 0 && user.username != "root"`, Severity: "critical", Description: "non-root account with UID 0"},
+		{Name: "telnetd", Target: "process", Expr: `process.name == "telnetd"`},
+		{Name: "too-few-users", Target: "host", Expr: `size(host.users) < 2`},
+		{Name: "telnet-port", Target: "port", Expr: `port.port == 23`},
+		{Name: "bad-asn", Target: "connection", Expr: `connection.asn == 64500 && connection.remote_port > 1024`},
+	}}
+	require.NoError(t, p.Validate())
+
+	v := AnalyzeRules(inv, p)
+	require.Len(t, v, 4)
+	assert.Equal(t, Violation{Category: "uid0-alias", Severity: SeverityCritical, Message: "user toor: non-root account with UID 0"}, v[0])
+	assert.Equal(t, Violation{Category: "telnetd", Severity: SeverityMedium, Message: `process telnetd (pid 42): process.name == "telnetd"`}, v[1])
+	assert.Equal(t, "port 23/tcp: port.port == 23", v[2].Message)
+	assert.Equal(t, "bad-asn", v[3].Category)
+	assert.Contains(t, v[3].Message, "connection process telnetd (pid 42) to 203.0.113.7:4444")
+}
+
+func TestValidate_Rules(t *testing.T) {
+	p := Policies{Rules: []Rule{
+		{Name: "bad-target", Target: "kernel", Expr: "true"},
+		{Name: "syntax", Target: "user", Expr: "user.uid ==="},
+		{Name: "not-bool", Target: "user", Expr: `"x"`},
+		{Target: "user", Expr: "true"},
+	}}
+	err := p.Validate()
+	require.Error(t, err)
+	for _, want := range []string{"rules[0]", "unknown target", "rules[1]", "rules[2]", "want bool", "rules[3]: missing name"} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestRule_CompiledOnce(t *testing.T) {
+	cache := &ruleCache{}
+	r := Rule{Name: "telnet", Target: "process", Expr: `process.name == "telnetd"`}
+	c := r.compiled(cache)
+	require.NoError(t, c.progErr)
+	assert.Same(t, c, r.compiled(cache))
+	r.Name, r.Severity = "renamed", "low"
+	assert.Same(t, c, r.compiled(cache), "keyed by what is compiled")
+	r.When = `host.platform == "linux"`
+	assert.NotSame(t, c, r.compiled(cache))
+	assert.NotSame(t, r.compiled(nil), r.compiled(nil), "no cache compiles each time")
+
+	bad := Rule{Name: "bad", Target: "process", Expr: "process.name ==", When: "host.("}
+	assert.Error(t, bad.compiled(cache).progErr)
+	assert.Error(t, bad.compiled(cache).whenErr)
+}
+
+func TestParsePolicies_RuleCache(t *testing.T) {
+	y := []byte("rules:\n  - name: telnet\n    target: process\n    expr: process.name == \"telnetd\"\n")
+	p, err := ParsePolicies(y)
+	require.NoError(t, err)
+	c := p.Rules[0].compiled(p.programs)
+	assert.Same(t, c, p.Rules[0].compiled(p.programs), "compiled by Validate and reused")
+
+	reloaded, err := ParsePolicies(y)
+	require.NoError(t, err)
+	assert.NotSame(t, c, reloaded.Rules[0].compiled(reloaded.programs), "a reloaded policy has its own cache")
+}
+
+func TestAnalyzeRules_Conditions(t *testing.T) {
+	inv := Inventory{
+		Platform:  "linux",
+		Processes: []collector.Process{{PID: 7, Name: "dockerd"}, {PID: 8, Name: "nc"}},
+		Ports:     []collector.PortBinding{{Port: 2375, Protocol: "tcp", Address: "0.0.0.0"}},
+	}
+	p := Policies{Rules: []Rule{
+		// Listed before its dependency; evaluated after it.
+		{Name: "docker-api-exposed", Target: "port", Expr: `port.port == 2375`, DependsOn: []string{"docker-installed"}},
+		{Name: "docker-installed", Target: "host", Expr: `false`, When: `host.processes.exists(p, p.name == "dockerd")`},
+		{Name: "rdp", Target: "port", Expr: `port.port == 3389`, When: `host.platform == "windows"`},
+		{Name: "netcat", Target: "process", Expr: `process.name == "nc"`},
+		{Name: "after-netcat", Target: "host", Expr: `true`, DependsOn: []string{"netcat"}},
+		{Name: "after-rdp", Target: "host", Expr: `true`, DependsOn: []string{"rdp"}},
+	}}
+	require.NoError(t, p.Validate())
+
+	var got []string
+	for _, v := range AnalyzeRules(inv, p) {
+		got = append(got, v.Category)
+	}
+	// after-netcat: its dependency found a violation. after-rdp: its
+	// dependency didn't apply.
+	assert.Equal(t, []string{"docker-api-exposed", "netcat"}, got)
+
+	inv.Processes = nil
+	got = nil
+	for _, v := range AnalyzeRules(inv, p) {
+		got = append(got, v.Category)
+	}
+	assert.Equal(t, []string{"after-netcat"}, got, "docker checks skipped without dockerd")
+}
+
+func TestValidate_RuleDependencies(t *testing.T) {
+	p := Policies{Rules: []Rule{
+		{Name: "a", Target: "host", Expr: "true", DependsOn: []string{"b"}},
+		{Name: "b", Target: "host", Expr: "true", DependsOn: []string{"c"}},
+		{Name: "c", Target: "host", Expr: "true", DependsOn: []string{"a"}},
+		{Name: "d", Target: "host", Expr: "true", DependsOn: []string{"missing"}, When: `host.platform ==`},
+	}}
+	err := p.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rules: dependency cycle a -> b -> c -> a")
+	assert.Contains(t, err.Error(), `rules: "d" depends on unknown rule "missing"`)
+	assert.Contains(t, err.Error(), "rules[3]: when:")
+	assert.Empty(t, AnalyzeRules(Inventory{}, p))
+}
+
+func TestAnalyzeRules_Parallel(t *testing.T) {
+	var inv Inventory
+	for i := 0; i < 2000; i++ {
+		inv.Packages = append(inv.Packages, collector.Package{Name: fmt.Sprintf("pkg%d", i), Version: "1.0"})
+	}
+	var p Policies
+	for i := 0; i < 200; i++ {
+		r := Rule{Name: fmt.Sprintf("r%d", i), Target: "package", Expr: fmt.Sprintf(`pkg.name == "pkg%d"`, i*7)}
+		if i%3 == 0 && i > 0 {
+			r.DependsOn = []string{fmt.Sprintf("r%d", i-1)}
+		}
+		p.Rules = append(p.Rules, r)
+	}
+	// Never matches, so rules depending on it run.
+	p.Rules[199].Expr = `pkg.name == "none"`
+	p.Rules[0].DependsOn = []string{"r199"}
+	require.NoError(t, p.Validate())
+
+	defer func(n int) { ruleWorkers = n }(ruleWorkers)
+	ruleWorkers = 1
+	want := AnalyzeRules(inv, p)
+	ruleWorkers = 8
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, want, AnalyzeRules(inv, p))
+	}
+	// r0 is ordered right after r199, which passed. r3 depends on r2,
+	// which found a violation, so it's skipped.
+	assert.Equal(t, []string{"r0", "r1", "r2", "r4", "r5"}, categories(want)[:5])
+}
+
+func categories(vs []Violation) []string {
+	var out []string
+	for _, v := range vs {
+		out = append(out, v.Category)
+	}
+	return out
+}
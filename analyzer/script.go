@@ -0,0 +1,118 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Script is an operator-written check in Tengo, for logic a CEL rule
+// can't express: loops, or joins across datasets. The script sees the
+// report as `input`, in its JSON shape, and appends to `violations`
+// message strings or maps with "message" and optional "category",
+// "severity" and "user" keys (as a Rego query returns them).
+//
+//	scripts:
+//	  - name: root-listeners
+//	    path: /etc/compliance-agent/checks/root_listeners.tengo
+//
+// Scripts can't touch the file system or run commands: the os module
+// isn't importable and neither are other files. Each run is bounded by
+// Timeout and MaxAllocs.
+type Script struct {
+	Name string `yaml:"name"`
+	// Path is a .tengo file; Source is the script inline. Exactly one
+	// must be set.
+	Path   string `yaml:"path"`
+	Source string `yaml:"source"`
+	// Severity applies to violations that don't set their own. The
+	// category defaults to the script's name.
+	Severity string `yaml:"severity"`
+	// Timeout defaults to 5s; MaxAllocs, the number of objects the
+	// script may allocate, to 1,000,000.
+	Timeout   time.Duration `yaml:"timeout"`
+	MaxAllocs int64         `yaml:"max_allocs"`
+	// AppliesTo limits the script to some platforms and OS releases.
+	AppliesTo AppliesTo `yaml:"applies_to"`
+}
+
+const (
+	defaultScriptTimeout   = 5 * time.Second
+	defaultScriptMaxAllocs = 1_000_000
+)
+
+func (s Script) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return defaultScriptTimeout
+}
+
+func (s Script) maxAllocs() int64 {
+	if s.MaxAllocs > 0 {
+		return s.MaxAllocs
+	}
+	return defaultScriptMaxAllocs
+}
+
+// AnalyzeScripts runs every policy script with input, the report, as
+// `input`. A script that fails (a run-time error, the time or allocation
+// limit) contributes no violations; the others still run, and the
+// failures are returned together.
+func AnalyzeScripts(ctx context.Context, input any, policies Policies) ([]Violation, error) {
+	if len(policies.Scripts) == 0 {
+		return nil, nil
+	}
+	// Round-trip through JSON so scripts see the field names and shapes
+	// of compliance_report.json.
+	b, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	doc = scriptNumbers(doc)
+	var v []Violation
+	var errs []error
+	for _, s := range policies.Scripts {
+		// Set converts doc afresh for each script, so one that modifies
+		// its input can't affect the next.
+		sv, err := s.run(ctx, doc, policies)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("script %s: %w", s.Name, err))
+			continue
+		}
+		v = append(v, sv...)
+	}
+	return v, errors.Join(errs...)
+}
+
+// scriptNumbers turns JSON numbers into int64 where they are whole, so
+// `u.uid == 0` holds in a script: Tengo never treats a float as equal to
+// an int.
+func scriptNumbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for k, e := range v {
+			v[k] = scriptNumbers(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = scriptNumbers(e)
+		}
+	}
+	return v
+}
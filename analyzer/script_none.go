@@ -0,0 +1,19 @@
+//go:build no_scripts || slim
+
+package analyzer
+
+import (
+	"context"
+	"errors"
+)
+
+var errNoScripts = errors.New("scripting is not compiled into this agent (built with no_scripts)")
+
+func (s Script) run(context.Context, any, Policies) ([]Violation, error) {
+	return nil, errNoScripts
+}
+
+// validate rejects scripts up front rather than at the first scan.
+func (s Script) validate() error {
+	return errNoScripts
+}
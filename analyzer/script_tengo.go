@@ -0,0 +1,99 @@
+//go:build !no_scripts && !slim
+
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"compliance-agent/buildinfo"
+
+	"github.com/d5/tengo/v2"
+	"github.com/d5/tengo/v2/stdlib"
+)
+
+// scriptMaxStringLen caps any one string or bytes value. Tengo's limit is
+// process-wide, and nothing else here uses Tengo.
+const scriptMaxStringLen = 16 << 20
+
+func init() {
+	buildinfo.Register("scripts")
+	tengo.MaxStringLen = scriptMaxStringLen
+	tengo.MaxBytesLen = scriptMaxStringLen
+}
+
+// scriptModules is the standard library without os, which would give
+// scripts files, environment and processes.
+var scriptModules = func() *tengo.ModuleMap {
+	var names []string
+	for _, n := range stdlib.AllModuleNames() {
+		if n != "os" {
+			names = append(names, n)
+		}
+	}
+	return stdlib.GetModuleMap(names...)
+}()
+
+// compile reads and compiles the script with input and violations
+// declared.
+func (s Script) compile() (*tengo.Compiled, error) {
+	src := []byte(s.Source)
+	if s.Path != "" {
+		var err error
+		if src, err = os.ReadFile(s.Path); err != nil {
+			return nil, err
+		}
+	}
+	sc := tengo.NewScript(src)
+	sc.SetImports(scriptModules)
+	sc.SetMaxAllocs(s.maxAllocs())
+	if err := sc.Add("input", nil); err != nil {
+		return nil, err
+	}
+	if err := sc.Add("violations", []any{}); err != nil {
+		return nil, err
+	}
+	return sc.Compile()
+}
+
+// run evaluates the script against input, which must already be in its
+// JSON shape.
+func (s Script) run(ctx context.Context, input any, policies Policies) ([]Violation, error) {
+	c, err := s.compile()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Set("input", input); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	if err := c.RunContext(ctx); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("timed out after %s", s.timeout())
+		}
+		return nil, err
+	}
+	items, ok := c.Get("violations").Value().([]any)
+	if !ok {
+		return nil, fmt.Errorf("violations is %s, want an array", c.Get("violations").ValueType())
+	}
+	var v []Violation
+	for _, item := range items {
+		viol, err := violationFrom(item, s.Name, s.Severity, policies)
+		if err != nil {
+			return nil, err
+		}
+		v = append(v, viol)
+	}
+	return v, nil
+}
+
+// validate compiles the script so syntax errors surface when the policy
+// loads.
+func (s Script) validate() error {
+	_, err := s.compile()
+	return err
+}
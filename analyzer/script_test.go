@@ -0,0 +1,76 @@
+//go:build !no_scripts && !slim
+
+package analyzer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testScript = `
+root := {}
+for u in input.users {
+	if u.uid == 0 { root[u.username] = true }
+}
+for b in input.port_bindings {
+	if root[b.user] && b.address == "0.0.0.0" {
+		violations = append(violations, {
+			category: "root_listener",
+			severity: "high",
+			message: b.process + " listens on " + string(b.port) + " as " + b.user
+		})
+	}
+}
+if len(input.port_bindings) > 1 {
+	violations = append(violations, "more than one listener")
+}
+`
+
+func TestAnalyzeScripts(t *testing.T) {
+	p := Policies{Scripts: []Script{{Name: "listeners", Source: testScript, Severity: "low"}}}
+	require.NoError(t, p.Validate())
+
+	input := map[string]any{
+		"users": []any{map[string]any{"username": "root", "uid": 0}, map[string]any{"username": "web", "uid": 33}},
+		"port_bindings": []any{
+			map[string]any{"port": 22, "address": "0.0.0.0", "process": "sshd", "user": "root"},
+			map[string]any{"port": 8080, "address": "0.0.0.0", "process": "java", "user": "web"},
+		},
+	}
+	v, err := AnalyzeScripts(context.Background(), input, p)
+	require.NoError(t, err)
+	assert.Equal(t, []Violation{
+		{Category: "root_listener", Severity: SeverityHigh, Message: "sshd listens on 22 as root"},
+		{Category: "listeners", Severity: SeverityLow, Message: "more than one listener"},
+	}, v)
+}
+
+func TestAnalyzeScripts_Limits(t *testing.T) {
+	p := Policies{Scripts: []Script{
+		{Name: "spin", Source: `for {}`, Timeout: 50 * time.Millisecond},
+		{Name: "hog", Source: `a := []; for { a = append(a, [1]) }`, MaxAllocs: 1000},
+		{Name: "ok", Source: `violations = ["fine"]`},
+	}}
+	v, err := AnalyzeScripts(context.Background(), map[string]any{}, p)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "script spin: timed out after 50ms")
+	assert.Contains(t, err.Error(), "script hog: Runtime Error: object allocation limit exceeded")
+	assert.Equal(t, []Violation{{Category: "ok", Severity: SeverityMedium, Message: "fine"}}, v)
+}
+
+func TestScript_ValidateSandbox(t *testing.T) {
+	p := Policies{Scripts: []Script{
+		{Name: "os", Source: `os := import("os"); violations = [os.getenv("HOME")]`},
+		{Name: "file", Source: `x := import("./other")`},
+		{Name: "both", Source: `violations = []`, Path: "/tmp/x.tengo"},
+	}}
+	err := p.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `scripts[0]: Compile Error: module 'os' not found`)
+	assert.Contains(t, err.Error(), `scripts[1]: Compile Error: module './other' not found`)
+	assert.Contains(t, err.Error(), "scripts[2]: exactly one of path and source is required")
+}
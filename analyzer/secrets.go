@@ -0,0 +1,71 @@
+package analyzer
+
+import (
+	"fmt"
+	"time"
+
+	"compliance-agent/collector"
+)
+
+// SecretsPolicy looks for private keys, keystores and dotenv files other
+// local users can read, and for server certificates about to expire.
+type SecretsPolicy struct {
+	Scan bool `yaml:"scan"`
+	// Paths are the directories to walk; empty uses the platform
+	// defaults (/etc/ssl, /etc/pki, web server and /opt, /srv app
+	// directories on Linux).
+	Paths []string `yaml:"paths"`
+	// ExpiryWarningDays flags certificates expiring within this many
+	// days (default 30).
+	ExpiryWarningDays int `yaml:"expiry_warning_days"`
+}
+
+// Enabled reports whether scanning is on.
+func (p SecretsPolicy) Enabled() bool {
+	return p.Scan
+}
+
+// now is replaced in tests.
+var now = time.Now
+
+var secretKindNames = map[string]string{
+	"private_key": "private key",
+	"keystore":    "keystore",
+	"env":         "environment file",
+}
+
+// AnalyzeSecrets flags world-readable secret material and certificates
+// that have expired or expire within the warning window.
+func AnalyzeSecrets(files []collector.SecretFile, policies Policies) []Violation {
+	days := policies.Secrets.ExpiryWarningDays
+	if days <= 0 {
+		days = 30
+	}
+	horizon := now().Add(time.Duration(days) * 24 * time.Hour)
+	var v []Violation
+	add := func(category, msg string) {
+		v = append(v, Violation{
+			Category: category,
+			Severity: policies.severityFor(category),
+			Message:  msg,
+		})
+	}
+	for _, f := range files {
+		if name, ok := secretKindNames[f.Kind]; ok && f.WorldReadable {
+			add("secret_exposure", fmt.Sprintf("%s %s is world-readable (mode %s)", name, f.Path, f.Mode))
+		}
+		if f.NotAfter == nil || f.NotAfter.After(horizon) {
+			continue
+		}
+		subject := ""
+		if f.Subject != "" {
+			subject = " (" + f.Subject + ")"
+		}
+		verb := "expires on"
+		if f.NotAfter.Before(now()) {
+			verb = "expired on"
+		}
+		add("certificate_expiry", fmt.Sprintf("certificate %s%s %s %s", f.Path, subject, verb, f.NotAfter.UTC().Format(time.DateOnly)))
+	}
+	return v
+}
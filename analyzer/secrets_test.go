@@ -0,0 +1,42 @@
+package analyzer
+
+import (
+	"testing"
+	"time"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeSecrets(t *testing.T) {
+	defer func(orig func() time.Time) { now = orig }(now)
+	now = func() time.Time { return time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC) }
+	at := func(y int, m time.Month, d int) *time.Time {
+		tm := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		return &tm
+	}
+	files := []collector.SecretFile{
+		{Path: "/etc/ssl/private/site.key", Kind: "private_key", Mode: "0644", WorldReadable: true},
+		{Path: "/srv/app/.env", Kind: "env", Mode: "0600"},
+		{Path: "/etc/ssl/site.crt", Kind: "certificate", Mode: "0644", WorldReadable: true, Subject: "CN=www.example.com", NotAfter: at(2026, 11, 1)},
+		{Path: "/etc/ssl/old.crt", Kind: "certificate", Mode: "0644", NotAfter: at(2026, 9, 1)},
+		{Path: "/etc/ssl/fresh.crt", Kind: "certificate", Mode: "0644", NotAfter: at(2027, 9, 1)},
+	}
+	v := AnalyzeSecrets(files, Policies{})
+	require.Len(t, v, 3)
+	assert.Equal(t, Violation{Category: "secret_exposure", Severity: SeverityHigh, Message: "private key /etc/ssl/private/site.key is world-readable (mode 0644)"}, v[0])
+	assert.Equal(t, "certificate /etc/ssl/site.crt (CN=www.example.com) expires on 2026-11-01", v[1].Message)
+	assert.Equal(t, SeverityMedium, v[1].Severity)
+	assert.Equal(t, "certificate /etc/ssl/old.crt expired on 2026-09-01", v[2].Message)
+
+	v = AnalyzeSecrets(files, Policies{Secrets: SecretsPolicy{ExpiryWarningDays: 7}})
+	assert.Len(t, v, 2, "site.crt is outside a 7-day window")
+}
+
+func TestSecretsPolicyValidation(t *testing.T) {
+	_, err := ParsePolicies([]byte("secrets:\n  scan: true\n  paths: [etc/ssl]\n  expiry_warning_days: -1\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "secrets.paths[0]")
+	assert.Contains(t, err.Error(), "secrets.expiry_warning_days")
+}
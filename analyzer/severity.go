@@ -0,0 +1,129 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity ranks how urgent a violation is. The zero value is treated as
+// SeverityMedium so violations built before severities existed keep a
+// sensible rank.
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityHigh     Severity = "high"
+	SeverityMedium   Severity = "medium"
+	SeverityLow      Severity = "low"
+	SeverityInfo     Severity = "info"
+)
+
+// defaultSeverities apply when a policy doesn't override a rule.
+var defaultSeverities = map[string]Severity{
+	"user":  SeverityHigh,
+	"port":  SeverityMedium,
+	"agent": SeverityHigh,
+
+	"process":         SeverityMedium,
+	"process_blocked": SeverityHigh,
+
+	"package":         SeverityHigh,
+	"package_missing": SeverityHigh,
+	"package_version": SeverityMedium,
+
+	"package_not_allowed": SeverityMedium,
+
+	"screen_lock":       SeverityMedium,
+	"browser_extension": SeverityHigh,
+	"authorized_keys":   SeverityHigh,
+	"login_item":        SeverityMedium,
+
+	"sleep_on_lid_close":     SeverityMedium,
+	"password_after_sleep":   SeverityHigh,
+	"hibernation_encryption": SeverityHigh,
+
+	"sharing":            SeverityHigh,
+	"bluetooth":          SeverityMedium,
+	"bluetooth_device":   SeverityMedium,
+	"vpn":                SeverityHigh,
+	"vpn_tunnel":         SeverityMedium,
+	"hosts_override":     SeverityHigh,
+	"proxy":              SeverityHigh,
+	"connection_country": SeverityHigh,
+	"connection_asn":     SeverityHigh,
+	"network_threat":     SeverityHigh,
+	"gateway_mac_change": SeverityHigh,
+	"arp_duplicate":      SeverityMedium,
+	"vulnerability":      SeverityMedium,
+
+	"promiscuous_interface": SeverityHigh,
+	"virtual_interface":     SeverityMedium,
+	"interface_address":     SeverityMedium,
+
+	"tls_protocol": SeverityHigh,
+	"tls_cipher":   SeverityHigh,
+
+	"web_hsts":                SeverityMedium,
+	"web_https_redirect":      SeverityMedium,
+	"web_default_credentials": SeverityHigh,
+
+	"disk_encryption": SeverityHigh,
+
+	"secret_exposure":    SeverityHigh,
+	"certificate_expiry": SeverityMedium,
+
+	"component_missing":     SeverityHigh,
+	"component_not_running": SeverityHigh,
+	"component_version":     SeverityMedium,
+	"component_config":      SeverityHigh,
+
+	"firewall": SeverityHigh,
+
+	"ssh_protocol":       SeverityCritical,
+	"ssh_root_login":     SeverityHigh,
+	"ssh_password_auth":  SeverityMedium,
+	"ssh_max_auth_tries": SeverityMedium,
+	"ssh_config":         SeverityMedium,
+
+	"os_version":     SeverityHigh,
+	"kernel_version": SeverityHigh,
+}
+
+// ParseSeverity accepts a severity name case-insensitively.
+func ParseSeverity(s string) (Severity, error) {
+	switch sev := Severity(strings.ToLower(strings.TrimSpace(s))); sev {
+	case SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo:
+		return sev, nil
+	}
+	return "", fmt.Errorf("unknown severity %q (want critical, high, medium, low or info)", s)
+}
+
+// Rank orders severities for comparison and sorting; higher is worse.
+func (s Severity) Rank() int {
+	switch s {
+	case SeverityCritical:
+		return 4
+	case SeverityHigh:
+		return 3
+	case SeverityLow:
+		return 1
+	case SeverityInfo:
+		return 0
+	default:
+		return 2
+	}
+}
+
+// severityFor returns the severity a policy assigns to a rule, falling
+// back to the built-in default and then to medium.
+func (p Policies) severityFor(rule string) Severity {
+	if s, ok := p.Severities[rule]; ok {
+		if sev, err := ParseSeverity(s); err == nil {
+			return sev
+		}
+	}
+	if sev, ok := defaultSeverities[rule]; ok {
+		return sev
+	}
+	return SeverityMedium
+}
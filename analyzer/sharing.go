@@ -0,0 +1,43 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// SharingPolicy prohibits file, printer and peer-to-peer sharing services
+// on hosts where they aren't needed.
+type SharingPolicy struct {
+	Prohibited bool `yaml:"prohibited"`
+	// Allowed lists services exempt from the prohibition (e.g. "cups" on
+	// a print server), by collector.SharingService.Service name.
+	Allowed []string `yaml:"allowed"`
+}
+
+// AnalyzeSharing flags every enabled sharing service not explicitly
+// allowed, when sharing is prohibited. One whose state is unknown isn't
+// flagged.
+func AnalyzeSharing(services []collector.SharingService, policies Policies) []Violation {
+	if !policies.Sharing.Prohibited {
+		return nil
+	}
+	allowed := map[string]struct{}{}
+	for _, s := range policies.Sharing.Allowed {
+		allowed[strings.ToLower(s)] = struct{}{}
+	}
+	var v []Violation
+	for _, s := range services {
+		if _, ok := allowed[s.Service]; ok || s.Unknown {
+			continue
+		}
+		msg := fmt.Sprintf("sharing service %s enabled: %s", s.Service, s.Evidence)
+		v = append(v, Violation{
+			Category: "sharing",
+			Severity: policies.severityFor("sharing"),
+			Message:  msg,
+		})
+	}
+	return v
+}
@@ -0,0 +1,24 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeSharing(t *testing.T) {
+	services := []collector.SharingService{
+		{Service: "smb", Port: 445, Protocol: "tcp", Evidence: "listening on 0.0.0.0:445/tcp"},
+		{Service: "cups", Port: 631, Protocol: "tcp", Evidence: "cupsctl: _share_printers=1"},
+		{Service: "airdrop", Unknown: true, Evidence: "no managed DisableAirDrop or console user DiscoverableMode to read"},
+	}
+	assert.Empty(t, AnalyzeSharing(services, Policies{}), "not prohibited")
+
+	v := AnalyzeSharing(services, Policies{Sharing: SharingPolicy{Prohibited: true, Allowed: []string{"CUPS"}}})
+	if assert.Len(t, v, 1) {
+		assert.Equal(t, "sharing", v[0].Category)
+		assert.Equal(t, SeverityHigh, v[0].Severity)
+		assert.Equal(t, "sharing service smb enabled: listening on 0.0.0.0:445/tcp", v[0].Message)
+	}
+}
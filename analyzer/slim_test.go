@@ -0,0 +1,21 @@
+//go:build slim
+
+package analyzer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlim_RejectsRegoAndScripts(t *testing.T) {
+	_, err := ParsePolicies([]byte("rego:\n  paths: [policy.rego]\nscripts:\n  - name: s\n    source: x := 1\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no_rego")
+	assert.Contains(t, err.Error(), "no_scripts")
+
+	_, err = AnalyzeRego(context.Background(), nil, Policies{Rego: RegoPolicy{Paths: []string{"x"}}})
+	assert.Error(t, err)
+}
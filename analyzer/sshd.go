@@ -0,0 +1,145 @@
+package analyzer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// SSHDPolicy enforces SSH server hardening. Options the config file
+// leaves unset are checked at sshd's built-in default. Hosts without an
+// SSH server pass.
+type SSHDPolicy struct {
+	// ConfigPath overrides the platform's sshd_config location.
+	ConfigPath string `yaml:"config_path"`
+	// PermitRootLogin lists the acceptable values, e.g. [no] or
+	// [no, prohibit-password].
+	PermitRootLogin []string `yaml:"permit_root_login"`
+	// PasswordAuthentication, when set, is the required state.
+	PasswordAuthentication *bool `yaml:"password_authentication"`
+	// MaxAuthTries is the highest allowed value; 0 leaves it unchecked.
+	MaxAuthTries int `yaml:"max_auth_tries"`
+	// Require maps other options to their required value, e.g.
+	// X11Forwarding: "no". Names and values are case-insensitive.
+	Require map[string]string `yaml:"require"`
+}
+
+// Enabled reports whether any SSH rule is configured.
+func (p SSHDPolicy) Enabled() bool {
+	return len(p.PermitRootLogin) > 0 || p.PasswordAuthentication != nil || p.MaxAuthTries > 0 || len(p.Require) > 0
+}
+
+// sshdDefaults are OpenSSH's built-in values for options commonly
+// audited, used when a parsed config file doesn't set them.
+var sshdDefaults = map[string]string{
+	"permitrootlogin":                 "prohibit-password",
+	"passwordauthentication":          "yes",
+	"maxauthtries":                    "6",
+	"protocol":                        "2",
+	"permitemptypasswords":            "no",
+	"pubkeyauthentication":            "yes",
+	"hostbasedauthentication":         "no",
+	"ignorerhosts":                    "yes",
+	"kbdinteractiveauthentication":    "yes",
+	"challengeresponseauthentication": "yes",
+	"permituserenvironment":           "no",
+	"x11forwarding":                   "no",
+	"allowtcpforwarding":              "yes",
+	"allowagentforwarding":            "yes",
+	"loglevel":                        "INFO",
+	"usepam":                          "no",
+	"clientaliveinterval":             "0",
+	"clientalivecountmax":             "3",
+	"logingracetime":                  "120",
+	"maxsessions":                     "10",
+}
+
+var permitRootLoginValues = []string{"yes", "no", "prohibit-password", "without-password", "forced-commands-only"}
+
+// AnalyzeSSHD checks the SSH server configuration against the policy.
+// Protocol 1 is always flagged once any SSH rule is set.
+func AnalyzeSSHD(cfg *collector.SSHDConfig, policies Policies) []Violation {
+	p := policies.SSHD
+	if cfg == nil || !p.Enabled() {
+		return nil
+	}
+	var v []Violation
+	add := func(category, msg string) {
+		v = append(v, Violation{
+			Category: category,
+			Severity: policies.severityFor(category),
+			Message:  msg + " (" + cfg.Source + ")",
+		})
+	}
+	value := func(key string) (string, string) {
+		if s, ok := cfg.Settings[key]; ok {
+			return s, s
+		}
+		return sshdDefaults[key], sshdDefaults[key] + " by default"
+	}
+
+	if s, shown := value("protocol"); strings.Contains(s, "1") {
+		add("ssh_protocol", fmt.Sprintf("sshd allows SSH protocol 1 (Protocol %s)", shown))
+	}
+	if len(p.PermitRootLogin) > 0 {
+		s, shown := value("permitrootlogin")
+		if !allowedRootLogin(p.PermitRootLogin, s) {
+			add("ssh_root_login", fmt.Sprintf("sshd PermitRootLogin is %s, allowed: %s", shown, strings.Join(p.PermitRootLogin, ", ")))
+		}
+	}
+	if want := p.PasswordAuthentication; want != nil {
+		s, shown := value("passwordauthentication")
+		if strings.EqualFold(s, "yes") != *want {
+			add("ssh_password_auth", fmt.Sprintf("sshd PasswordAuthentication is %s, want %s", shown, yesNo(*want)))
+		}
+	}
+	if p.MaxAuthTries > 0 {
+		s, shown := value("maxauthtries")
+		if n, err := strconv.Atoi(s); err != nil || n > p.MaxAuthTries {
+			add("ssh_max_auth_tries", fmt.Sprintf("sshd MaxAuthTries is %s, want at most %d", shown, p.MaxAuthTries))
+		}
+	}
+	for _, opt := range sortedKeys(p.Require) {
+		want := p.Require[opt]
+		s, ok := cfg.Settings[strings.ToLower(opt)]
+		shown := s
+		if !ok {
+			s, ok = sshdDefaults[strings.ToLower(opt)]
+			shown = s + " by default"
+		}
+		switch {
+		case !ok:
+			add("ssh_config", fmt.Sprintf("sshd %s is not set, want %s", opt, want))
+		case !strings.EqualFold(s, want):
+			add("ssh_config", fmt.Sprintf("sshd %s is %s, want %s", opt, shown, want))
+		}
+	}
+	return v
+}
+
+// canonicalRootLogin maps the deprecated without-password spelling, which
+// older sshd -T still prints, onto prohibit-password.
+func canonicalRootLogin(s string) string {
+	if strings.EqualFold(s, "without-password") {
+		return "prohibit-password"
+	}
+	return s
+}
+
+func allowedRootLogin(allowed []string, s string) bool {
+	for _, e := range allowed {
+		if strings.EqualFold(canonicalRootLogin(e), canonicalRootLogin(s)) {
+			return true
+		}
+	}
+	return false
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,58 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeSSHD(t *testing.T) {
+	no := false
+	p := Policies{SSHD: SSHDPolicy{
+		PermitRootLogin:        []string{"no"},
+		PasswordAuthentication: &no,
+		MaxAuthTries:           4,
+		Require:                map[string]string{"X11Forwarding": "no", "PermitEmptyPasswords": "no", "Banner": "/etc/issue.net"},
+	}}
+	require.NoError(t, p.Validate())
+
+	// A parsed file: unset options are at sshd's defaults.
+	cfg := &collector.SSHDConfig{Source: "/etc/ssh/sshd_config", Settings: map[string]string{
+		"protocol":      "2,1",
+		"maxauthtries":  "3",
+		"x11forwarding": "yes",
+	}}
+	var msgs []string
+	for _, v := range AnalyzeSSHD(cfg, p) {
+		msgs = append(msgs, string(v.Severity)+" "+v.Category+": "+v.Message)
+	}
+	assert.Equal(t, []string{
+		"critical ssh_protocol: sshd allows SSH protocol 1 (Protocol 2,1) (/etc/ssh/sshd_config)",
+		"high ssh_root_login: sshd PermitRootLogin is prohibit-password by default, allowed: no (/etc/ssh/sshd_config)",
+		"medium ssh_password_auth: sshd PasswordAuthentication is yes by default, want no (/etc/ssh/sshd_config)",
+		"medium ssh_config: sshd Banner is not set, want /etc/issue.net (/etc/ssh/sshd_config)",
+		"medium ssh_config: sshd X11Forwarding is yes, want no (/etc/ssh/sshd_config)",
+	}, msgs)
+
+	hardened := &collector.SSHDConfig{Source: "sshd -T", Settings: map[string]string{
+		"permitrootlogin":        "no",
+		"passwordauthentication": "no",
+		"maxauthtries":           "4",
+		"x11forwarding":          "no",
+		"permitemptypasswords":   "no",
+		"banner":                 "/etc/issue.net",
+	}}
+	assert.Empty(t, AnalyzeSSHD(hardened, p))
+	assert.Empty(t, AnalyzeSSHD(nil, p), "no SSH server")
+}
+
+func TestAnalyzeSSHD_RootLoginAlias(t *testing.T) {
+	p := Policies{SSHD: SSHDPolicy{PermitRootLogin: []string{"no", "prohibit-password"}}}
+	cfg := &collector.SSHDConfig{Source: "sshd -T", Settings: map[string]string{"permitrootlogin": "without-password"}}
+	assert.Empty(t, AnalyzeSSHD(cfg, p))
+
+	p.SSHD.PermitRootLogin = []string{"never"}
+	assert.ErrorContains(t, p.Validate(), `sshd.permit_root_login[0]: unknown value "never"`)
+}
@@ -0,0 +1,87 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// tlsVersions orders protocol names as collector.TLSService reports them.
+var tlsVersions = []string{"SSLv3", "TLS1.0", "TLS1.1", "TLS1.2", "TLS1.3"}
+
+// TLSPolicy probes local TLS listeners and checks how they are
+// configured, not just that the port is open. Probing opens many short
+// connections to each listener, so it is opt-in.
+type TLSPolicy struct {
+	Probe bool `yaml:"probe"`
+	// Ports limits probing to these ports; empty probes every listening
+	// TCP port.
+	Ports []int `yaml:"ports"`
+	// MinVersion is the oldest acceptable protocol (SSLv3, TLS1.0 ...
+	// TLS1.3); default TLS1.1, which flags SSLv3 and TLS 1.0.
+	MinVersion string `yaml:"min_version"`
+}
+
+// Enabled reports whether probing is on.
+func (p TLSPolicy) Enabled() bool {
+	return p.Probe
+}
+
+func (p TLSPolicy) minVersion() int {
+	if i := tlsVersionRank(p.MinVersion); i >= 0 {
+		return i
+	}
+	return tlsVersionRank("TLS1.1")
+}
+
+func tlsVersionRank(v string) int {
+	for i, name := range tlsVersions {
+		if strings.EqualFold(v, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// AnalyzeTLS flags services accepting protocols older than the policy
+// minimum, and suites with no authentication (anonymous), no encryption
+// (NULL) or export-grade keys.
+func AnalyzeTLS(services []collector.TLSService, policies Policies) []Violation {
+	floor := policies.TLS.minVersion()
+	var v []Violation
+	add := func(category, msg string) {
+		v = append(v, Violation{
+			Category: category,
+			Severity: policies.severityFor(category),
+			Message:  msg,
+		})
+	}
+	for _, svc := range services {
+		var old []string
+		for _, ver := range svc.Versions {
+			if r := tlsVersionRank(ver); r >= 0 && r < floor {
+				old = append(old, ver)
+			}
+		}
+		if len(old) > 0 {
+			add("tls_protocol", fmt.Sprintf("TLS service on port %d accepts %s", svc.Port, strings.Join(old, ", ")))
+		}
+		var weak []string
+		for _, ver := range tlsVersions {
+			for _, cs := range svc.CipherSuites[ver] {
+				if insecureSuite(cs) {
+					weak = appendUnique(weak, cs)
+				}
+			}
+		}
+		if len(weak) > 0 {
+			add("tls_cipher", fmt.Sprintf("TLS service on port %d accepts insecure cipher suites: %s", svc.Port, strings.Join(weak, ", ")))
+		}
+	}
+	return v
+}
+
+func insecureSuite(name string) bool {
+	return strings.Contains(name, "_anon_") || strings.Contains(name, "_WITH_NULL_") || strings.Contains(name, "_EXPORT_")
+}
@@ -0,0 +1,33 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeTLS(t *testing.T) {
+	services := []collector.TLSService{
+		{Port: 443, Versions: []string{"TLS1.2", "TLS1.3"}, CipherSuites: map[string][]string{
+			"TLS1.2": {"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+			"TLS1.3": {"TLS_AES_128_GCM_SHA256"},
+		}},
+		{Port: 8443, Versions: []string{"SSLv3", "TLS1.0", "TLS1.1", "TLS1.2"}, CipherSuites: map[string][]string{
+			"SSLv3":  {"TLS_RSA_WITH_RC4_128_SHA", "TLS_RSA_EXPORT_WITH_RC4_40_MD5"},
+			"TLS1.0": {"TLS_DH_anon_WITH_AES_128_CBC_SHA", "TLS_RSA_WITH_NULL_SHA"},
+			"TLS1.2": {"TLS_DH_anon_WITH_AES_128_CBC_SHA"},
+		}},
+	}
+	var got []string
+	for _, v := range AnalyzeTLS(services, Policies{TLS: TLSPolicy{Probe: true}}) {
+		got = append(got, string(v.Severity)+" "+v.Category+": "+v.Message)
+	}
+	assert.Equal(t, []string{
+		"high tls_protocol: TLS service on port 8443 accepts SSLv3, TLS1.0",
+		"high tls_cipher: TLS service on port 8443 accepts insecure cipher suites: TLS_RSA_EXPORT_WITH_RC4_40_MD5, TLS_DH_anon_WITH_AES_128_CBC_SHA, TLS_RSA_WITH_NULL_SHA",
+	}, got)
+
+	strict := AnalyzeTLS(services[1:], Policies{TLS: TLSPolicy{Probe: true, MinVersion: "tls1.2"}})
+	assert.Equal(t, "TLS service on port 8443 accepts SSLv3, TLS1.0, TLS1.1", strict[0].Message)
+}
@@ -0,0 +1,87 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// VPNPolicy requires an approved VPN client (and optionally a live
+// tunnel) on remote-work hosts.
+type VPNPolicy struct {
+	// ApprovedClients names the acceptable clients, by signature name. An
+	// empty list accepts any known client. When set, other detected VPN
+	// clients are violations too.
+	ApprovedClients  []string `yaml:"approved_clients"`
+	RequireInstalled bool     `yaml:"require_installed"`
+	RequireConnected bool     `yaml:"require_connected"`
+	// Signatures adds or overrides client signatures, by name.
+	Signatures []collector.VPNSignature `yaml:"signatures"`
+}
+
+// Enabled reports whether any VPN rule is set, which is what triggers VPN
+// detection.
+func (v VPNPolicy) Enabled() bool {
+	return v.RequireInstalled || v.RequireConnected || len(v.ApprovedClients) > 0
+}
+
+// AllSignatures returns the built-in signatures with the policy's own
+// merged in; a policy signature replaces a built-in one of the same name.
+func (v VPNPolicy) AllSignatures() []collector.VPNSignature {
+	custom := map[string]collector.VPNSignature{}
+	for _, s := range v.Signatures {
+		custom[s.Name] = s
+	}
+	var out []collector.VPNSignature
+	for _, s := range collector.DefaultVPNSignatures {
+		if c, ok := custom[s.Name]; ok {
+			s = c
+			delete(custom, s.Name)
+		}
+		out = append(out, s)
+	}
+	for _, s := range v.Signatures {
+		if _, ok := custom[s.Name]; ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// AnalyzeVPN applies the VPN policy.
+func AnalyzeVPN(st collector.VPNStatus, policies Policies) []Violation {
+	p := policies.VPN
+	approved := map[string]struct{}{}
+	for _, c := range p.ApprovedClients {
+		approved[strings.ToLower(c)] = struct{}{}
+	}
+	var v []Violation
+	add := func(category, msg string) {
+		v = append(v, Violation{
+			Category: category,
+			Severity: policies.severityFor(category),
+			Message:  msg,
+		})
+	}
+
+	haveApproved := false
+	for _, c := range st.Clients {
+		if _, ok := approved[strings.ToLower(c.Name)]; ok || len(approved) == 0 {
+			haveApproved = true
+			continue
+		}
+		add("vpn", fmt.Sprintf("unapproved VPN client %s installed (%s)", c.Name, strings.Join(c.Evidence, ", ")))
+	}
+	if p.RequireInstalled && !haveApproved {
+		if len(approved) > 0 {
+			add("vpn", fmt.Sprintf("no approved VPN client installed (approved: %s)", strings.Join(p.ApprovedClients, ", ")))
+		} else {
+			add("vpn", "no VPN client installed")
+		}
+	}
+	if p.RequireConnected && st.TunnelActive != nil && !*st.TunnelActive {
+		add("vpn_tunnel", "no active VPN tunnel")
+	}
+	return v
+}
@@ -0,0 +1,46 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeVPN(t *testing.T) {
+	down := false
+	st := collector.VPNStatus{
+		Clients:      []collector.VPNClient{{Name: "tailscale", Evidence: []string{"process tailscaled"}, Running: true}},
+		TunnelActive: &down,
+	}
+	p := Policies{VPN: VPNPolicy{
+		ApprovedClients:  []string{"globalprotect"},
+		RequireInstalled: true,
+		RequireConnected: true,
+	}}
+	v := AnalyzeVPN(st, p)
+	if assert.Len(t, v, 3) {
+		assert.Equal(t, "unapproved VPN client tailscale installed (process tailscaled)", v[0].Message)
+		assert.Equal(t, "no approved VPN client installed (approved: globalprotect)", v[1].Message)
+		assert.Equal(t, "vpn_tunnel", v[2].Category)
+	}
+
+	st.Clients = []collector.VPNClient{{Name: "globalprotect"}}
+	p.VPN.RequireConnected = false
+	assert.Empty(t, AnalyzeVPN(st, p))
+}
+
+func TestVPNPolicy_AllSignatures(t *testing.T) {
+	p := VPNPolicy{Signatures: []collector.VPNSignature{
+		{Name: "openvpn", Processes: []string{"openvpn3"}},
+		{Name: "corp-vpn", Processes: []string{"corpvpnd"}},
+	}}
+	sigs := p.AllSignatures()
+	assert.Len(t, sigs, len(collector.DefaultVPNSignatures)+1)
+	for _, s := range sigs {
+		if s.Name == "openvpn" {
+			assert.Equal(t, []string{"openvpn3"}, s.Processes)
+		}
+	}
+	assert.Equal(t, "corp-vpn", sigs[len(sigs)-1].Name)
+}
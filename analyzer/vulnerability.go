@@ -0,0 +1,83 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"compliance-agent/osv"
+)
+
+// VulnerabilityPolicy checks installed packages against the OSV
+// vulnerability database. Scanning sends package names and versions to
+// the OSV API (or the URL in the agent config), so it is opt-in.
+type VulnerabilityPolicy struct {
+	Scan bool `yaml:"scan"`
+	// MinSeverity drops findings rated below it. Unrated findings are
+	// always kept.
+	MinSeverity string `yaml:"min_severity"`
+	// Ignore lists vulnerability IDs or aliases that were assessed as not
+	// applicable.
+	Ignore []string `yaml:"ignore"`
+}
+
+// Enabled reports whether scanning is on.
+func (v VulnerabilityPolicy) Enabled() bool {
+	return v.Scan
+}
+
+// AnalyzeVulnerabilities reports each vulnerable package. A finding takes
+// its severity from the vulnerability record; unrated ones fall back to
+// the "vulnerability" category severity.
+func AnalyzeVulnerabilities(findings []osv.Finding, policies Policies) []Violation {
+	p := policies.Vulnerabilities
+	floor, err := ParseSeverity(p.MinSeverity)
+	if err != nil {
+		floor = SeverityInfo
+	}
+	ignore := map[string]bool{}
+	for _, id := range p.Ignore {
+		ignore[strings.ToUpper(id)] = true
+	}
+	var v []Violation
+	for _, f := range findings {
+		if vulnIgnored(f, ignore) {
+			continue
+		}
+		sev := policies.severityFor("vulnerability")
+		if s, err := ParseSeverity(f.Severity); err == nil {
+			sev = s
+			if sev.Rank() < floor.Rank() {
+				continue
+			}
+		}
+		ids := f.ID
+		if len(f.Aliases) > 0 {
+			ids += " (" + strings.Join(f.Aliases, ", ") + ")"
+		}
+		msg := fmt.Sprintf("package %s %s is affected by %s", f.Package, f.Version, ids)
+		if f.Summary != "" {
+			msg += ": " + strings.TrimSuffix(f.Summary, ".")
+		}
+		if f.Fixed != "" {
+			msg += " (fixed in " + f.Fixed + ")"
+		}
+		v = append(v, Violation{
+			Category: "vulnerability",
+			Severity: sev,
+			Message:  msg,
+		})
+	}
+	return v
+}
+
+func vulnIgnored(f osv.Finding, ignore map[string]bool) bool {
+	if ignore[strings.ToUpper(f.ID)] {
+		return true
+	}
+	for _, a := range f.Aliases {
+		if ignore[strings.ToUpper(a)] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/osv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeVulnerabilities(t *testing.T) {
+	findings := []osv.Finding{
+		{Package: "openssl", Version: "3.0.2-0ubuntu1.9", ID: "USN-6119-1", Aliases: []string{"CVE-2023-2650"}, Summary: "OpenSSL could be made to crash.", Severity: "high", Fixed: "3.0.2-0ubuntu1.10"},
+		{Package: "bash", Version: "5.1-6ubuntu1", ID: "UBUNTU-CVE-2022-3715", Severity: "low"},
+		{Package: "curl", Version: "7.81.0-1ubuntu1.10", ID: "DSA-0000-1"},
+		{Package: "zlib1g", Version: "1:1.2.11", ID: "GHSA-aaaa-bbbb-cccc", Aliases: []string{"CVE-2022-37434"}, Severity: "critical"},
+	}
+	p := Policies{Vulnerabilities: VulnerabilityPolicy{Scan: true, MinSeverity: "medium", Ignore: []string{"cve-2022-37434"}}}
+	v := AnalyzeVulnerabilities(findings, p)
+	if assert.Len(t, v, 2) {
+		assert.Equal(t, "vulnerability", v[0].Category)
+		assert.Equal(t, SeverityHigh, v[0].Severity)
+		assert.Equal(t, "package openssl 3.0.2-0ubuntu1.9 is affected by USN-6119-1 (CVE-2023-2650): OpenSSL could be made to crash (fixed in 3.0.2-0ubuntu1.10)", v[0].Message)
+		// Unrated findings get the category severity and aren't filtered.
+		assert.Equal(t, SeverityMedium, v[1].Severity)
+		assert.Contains(t, v[1].Message, "DSA-0000-1")
+	}
+}
@@ -0,0 +1,102 @@
+package analyzer
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// WebPolicy checks the web consoles an appliance-style host serves
+// locally. Each endpoint is fetched once, unauthenticated.
+type WebPolicy struct {
+	// Endpoints are URLs on this host, e.g. https://127.0.0.1:8443/ or
+	// http://localhost:8080/. Other hosts are never contacted.
+	Endpoints []string `yaml:"endpoints"`
+	// RequireHSTS flags https endpoints without a Strict-Transport-Security
+	// header, or with a max-age under MinHSTSAge days (default 180).
+	RequireHSTS bool `yaml:"require_hsts"`
+	MinHSTSAge  int  `yaml:"min_hsts_age_days"`
+	// RequireHTTPSRedirect flags http endpoints that serve content
+	// instead of redirecting to https.
+	RequireHTTPSRedirect bool `yaml:"require_https_redirect"`
+	// DefaultCredentials flags pages advertising default logins or an
+	// unfinished setup wizard. CredentialPatterns adds regular
+	// expressions to the built-in signatures.
+	DefaultCredentials bool     `yaml:"default_credentials"`
+	CredentialPatterns []string `yaml:"credential_patterns"`
+}
+
+// Enabled reports whether any endpoints are configured.
+func (p WebPolicy) Enabled() bool {
+	return len(p.Endpoints) > 0
+}
+
+var hstsMaxAgeRE = regexp.MustCompile(`(?i)max-age\s*=\s*"?(\d+)`)
+
+// AnalyzeWeb applies the web policy to the fetched endpoints. Endpoints
+// that couldn't be fetched are left to the collection errors.
+func AnalyzeWeb(endpoints []collector.WebEndpoint, policies Policies) []Violation {
+	p := policies.Web
+	minAge := p.MinHSTSAge
+	if minAge <= 0 {
+		minAge = 180
+	}
+	var v []Violation
+	add := func(category, msg string) {
+		v = append(v, Violation{
+			Category: category,
+			Severity: policies.severityFor(category),
+			Message:  msg,
+		})
+	}
+	for _, ep := range endpoints {
+		if ep.Error != "" {
+			continue
+		}
+		u, err := url.Parse(ep.URL)
+		if err != nil {
+			continue
+		}
+		switch u.Scheme {
+		case "https":
+			if !p.RequireHSTS {
+				break
+			}
+			hsts := ep.Headers["Strict-Transport-Security"]
+			m := hstsMaxAgeRE.FindStringSubmatch(hsts)
+			switch {
+			case hsts == "":
+				add("web_hsts", fmt.Sprintf("%s sends no Strict-Transport-Security header", ep.URL))
+			case m == nil:
+				add("web_hsts", fmt.Sprintf("%s sends Strict-Transport-Security without max-age", ep.URL))
+			default:
+				if age, _ := strconv.Atoi(m[1]); age < minAge*86400 {
+					add("web_hsts", fmt.Sprintf("%s sets HSTS max-age %s, under %d days", ep.URL, m[1], minAge))
+				}
+			}
+		case "http":
+			if p.RequireHTTPSRedirect && !redirectsToHTTPS(ep) {
+				add("web_https_redirect", fmt.Sprintf("%s answers %d over plain HTTP instead of redirecting to HTTPS", ep.URL, ep.Status))
+			}
+		}
+		if p.DefaultCredentials && len(ep.DefaultCredentials) > 0 {
+			page := ep.URL
+			if ep.Title != "" {
+				page += " (" + ep.Title + ")"
+			}
+			add("web_default_credentials", fmt.Sprintf("%s looks like a default-credential or unfinished setup page: %s", page, strings.Join(ep.DefaultCredentials, ", ")))
+		}
+	}
+	return v
+}
+
+func redirectsToHTTPS(ep collector.WebEndpoint) bool {
+	if ep.Status < 300 || ep.Status >= 400 {
+		return false
+	}
+	return strings.HasPrefix(strings.ToLower(ep.Location), "https://")
+}
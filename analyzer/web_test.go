@@ -0,0 +1,30 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeWeb(t *testing.T) {
+	endpoints := []collector.WebEndpoint{
+		{URL: "http://127.0.0.1:8080/", Status: 301, Location: "https://127.0.0.1:8443/"},
+		{URL: "http://127.0.0.1:9000/", Status: 200, Title: "Setup", DefaultCredentials: []string{"admin/admin hint"}},
+		{URL: "https://127.0.0.1:8443/", Status: 200, Headers: map[string]string{"Strict-Transport-Security": "max-age=31536000; includeSubDomains"}},
+		{URL: "https://127.0.0.1:9443/", Status: 200, Headers: map[string]string{"Strict-Transport-Security": "max-age=3600"}},
+		{URL: "https://127.0.0.1:10443/", Status: 200},
+		{URL: "https://127.0.0.1:11443/", Error: "connection refused"},
+	}
+	p := Policies{Web: WebPolicy{RequireHSTS: true, RequireHTTPSRedirect: true, DefaultCredentials: true}}
+	var got []string
+	for _, v := range AnalyzeWeb(endpoints, p) {
+		got = append(got, string(v.Severity)+" "+v.Category+": "+v.Message)
+	}
+	assert.Equal(t, []string{
+		"medium web_https_redirect: http://127.0.0.1:9000/ answers 200 over plain HTTP instead of redirecting to HTTPS",
+		"high web_default_credentials: http://127.0.0.1:9000/ (Setup) looks like a default-credential or unfinished setup page: admin/admin hint",
+		"medium web_hsts: https://127.0.0.1:9443/ sets HSTS max-age 3600, under 180 days",
+		"medium web_hsts: https://127.0.0.1:10443/ sends no Strict-Transport-Security header",
+	}, got)
+}
@@ -0,0 +1,82 @@
+package analyzer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// WorkstationPolicy holds per-account rules for laptops and desktops.
+// Each rule is evaluated for every interactive account, and violations
+// name the account they belong to.
+type WorkstationPolicy struct {
+	RequireScreenLock bool `yaml:"require_screen_lock"`
+	// DeniedBrowserExtensions matches extension IDs or display names.
+	DeniedBrowserExtensions []string `yaml:"denied_browser_extensions"`
+	// RestrictAuthorizedKeys flags authorized_keys for every account not
+	// listed in AuthorizedKeysUsers.
+	RestrictAuthorizedKeys bool     `yaml:"restrict_authorized_keys"`
+	AuthorizedKeysUsers    []string `yaml:"authorized_keys_users"`
+	// DeniedLoginItems matches launch agents / autostart entries by
+	// case-insensitive substring of the file name.
+	DeniedLoginItems []string `yaml:"denied_login_items"`
+}
+
+// Enabled reports whether any workstation rule is set, which is what
+// triggers per-account collection on system scans.
+func (w WorkstationPolicy) Enabled() bool {
+	return w.RequireScreenLock || len(w.DeniedBrowserExtensions) > 0 ||
+		w.RestrictAuthorizedKeys || len(w.DeniedLoginItems) > 0
+}
+
+// AnalyzeAccounts applies the workstation policy to each account.
+func AnalyzeAccounts(accounts []collector.UserScope, policies Policies) []Violation {
+	w := policies.Workstation
+	deniedExt := map[string]struct{}{}
+	for _, e := range w.DeniedBrowserExtensions {
+		deniedExt[strings.ToLower(e)] = struct{}{}
+	}
+	keyUsers := map[string]struct{}{}
+	for _, u := range w.AuthorizedKeysUsers {
+		keyUsers[u] = struct{}{}
+	}
+
+	var v []Violation
+	add := func(category, user, msg string) {
+		v = append(v, Violation{
+			Category: category,
+			Severity: policies.severityFor(category),
+			User:     user,
+			Message:  msg,
+		})
+	}
+	for _, a := range accounts {
+		if w.RequireScreenLock && a.ScreenLock.Enabled != nil && !*a.ScreenLock.Enabled {
+			add("screen_lock", a.Username, fmt.Sprintf("screen lock disabled for user %s", a.Username))
+		}
+		for _, e := range a.BrowserExtensions {
+			_, byID := deniedExt[strings.ToLower(e.ID)]
+			_, byName := deniedExt[strings.ToLower(e.Name)]
+			if byID || (e.Name != "" && byName) {
+				add("browser_extension", a.Username, fmt.Sprintf("denied %s extension %s (%s) installed for user %s", e.Browser, e.ID, e.Name, a.Username))
+			}
+		}
+		if w.RestrictAuthorizedKeys && len(a.AuthorizedKeys) > 0 {
+			if _, ok := keyUsers[a.Username]; !ok {
+				add("authorized_keys", a.Username, fmt.Sprintf("user %s has %d SSH authorized key(s) but is not permitted any", a.Username, len(a.AuthorizedKeys)))
+			}
+		}
+		for _, item := range a.LaunchAgents {
+			name := strings.ToLower(filepath.Base(item))
+			for _, d := range w.DeniedLoginItems {
+				if strings.Contains(name, strings.ToLower(d)) {
+					add("login_item", a.Username, fmt.Sprintf("denied login item %s for user %s", item, a.Username))
+					break
+				}
+			}
+		}
+	}
+	return v
+}
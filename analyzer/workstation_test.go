@@ -0,0 +1,51 @@
+package analyzer
+
+import (
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeAccounts_AttributesToUser(t *testing.T) {
+	off := false
+	accounts := []collector.UserScope{
+		{
+			Username:          "alice",
+			ScreenLock:        collector.ScreenLock{Enabled: &off},
+			BrowserExtensions: []collector.BrowserExtension{{Browser: "chrome", ID: "bad-ext", Name: "Coupon Finder"}},
+			AuthorizedKeys:    []collector.AuthorizedKey{{Type: "ssh-ed25519"}},
+		},
+		{
+			Username:       "ops",
+			AuthorizedKeys: []collector.AuthorizedKey{{Type: "ssh-ed25519"}},
+			LaunchAgents:   []string{"/Users/ops/Library/LaunchAgents/com.teamviewer.plist"},
+		},
+	}
+	p := Policies{Workstation: WorkstationPolicy{
+		RequireScreenLock:       true,
+		DeniedBrowserExtensions: []string{"coupon finder"},
+		RestrictAuthorizedKeys:  true,
+		AuthorizedKeysUsers:     []string{"ops"},
+		DeniedLoginItems:        []string{"TeamViewer"},
+	}}
+
+	v := AnalyzeAccounts(accounts, p)
+	require.Len(t, v, 4)
+	byCat := map[string]Violation{}
+	for _, x := range v {
+		byCat[x.Category] = x
+	}
+	assert.Equal(t, "alice", byCat["screen_lock"].User)
+	assert.Equal(t, "alice", byCat["browser_extension"].User)
+	assert.Equal(t, "alice", byCat["authorized_keys"].User)
+	assert.Equal(t, "ops", byCat["login_item"].User)
+	assert.Equal(t, SeverityHigh, byCat["authorized_keys"].Severity)
+}
+
+func TestAnalyzeAccounts_UnknownScreenLockIsNotAViolation(t *testing.T) {
+	p := Policies{Workstation: WorkstationPolicy{RequireScreenLock: true}}
+	v := AnalyzeAccounts([]collector.UserScope{{Username: "bob"}}, p)
+	assert.Empty(t, v)
+}
@@ -1,20 +1,24 @@
 package baseline
 
-import "time"
+import (
+	"time"
 
-// SnapshotFromCollected builds a Snapshot from the raw maps the collectors return.
+	"compliance-agent/collector"
+)
+
+// SnapshotFromCollected builds a Snapshot from what the collectors return.
 // Kept in this package so callers don't need to know how processes are
 // aggregated (by name → count).
 func SnapshotFromCollected(
 	hostname string,
-	processes []map[string]string,
+	processes []collector.Process,
 	openPorts []int,
-	users []map[string]string,
-	packages []map[string]string,
+	users []collector.User,
+	packages []collector.Package,
 ) Snapshot {
 	procCounts := map[string]int{}
 	for _, p := range processes {
-		name := p["name"]
+		name := p.Name
 		if name == "" {
 			continue
 		}
@@ -37,6 +37,9 @@ type Baseline struct {
 	UserCountSeries []int          `json:"user_count_series"`
 	PkgCountSeries  []int          `json:"pkg_count_series"`
 	MaxSeriesLen     int            `json:"max_series_len"`
+
+	// GatewayMACs are the MACs seen per default gateway address.
+	GatewayMACs map[string][]string `json:"gateway_macs,omitempty"`
 }
 
 // Store wraps a Baseline with thread-safe access and JSON persistence.
@@ -114,6 +117,32 @@ func (s *Store) Update(snap Snapshot) {
 	s.data.PkgCountSeries = trim(append(s.data.PkgCountSeries, snap.PkgCount), s.data.MaxSeriesLen)
 }
 
+// maxGatewayMACs bounds the MACs remembered per gateway address, so a
+// laptop keeps recognizing the few networks it moves between.
+const maxGatewayMACs = 8
+
+// ObserveGateway records mac for the gateway ip and returns the MACs seen
+// for it before this call.
+func (s *Store) ObserveGateway(ip, mac string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.GatewayMACs == nil {
+		s.data.GatewayMACs = map[string][]string{}
+	}
+	known := s.data.GatewayMACs[ip]
+	for _, m := range known {
+		if m == mac {
+			return append([]string(nil), known...)
+		}
+	}
+	next := append(append([]string(nil), known...), mac)
+	if len(next) > maxGatewayMACs {
+		next = next[len(next)-maxGatewayMACs:]
+	}
+	s.data.GatewayMACs[ip] = next
+	return append([]string(nil), known...)
+}
+
 func trim(xs []int, maxLen int) []int {
 	if len(xs) <= maxLen {
 		return xs
@@ -1,6 +1,7 @@
 package baseline
 
 import (
+	"fmt"
 	"path/filepath"
 	"testing"
 	"time"
@@ -59,3 +60,23 @@ func TestStore_SeriesTrimsToMaxLen(t *testing.T) {
 	d := s.Data()
 	assert.LessOrEqual(t, len(d.UserCountSeries), d.MaxSeriesLen)
 }
+
+func TestStore_ObserveGateway(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "b.json")
+	s := NewStore(path)
+	require.NoError(t, s.Load())
+
+	assert.Empty(t, s.ObserveGateway("192.168.1.1", "aa:bb:cc:00:00:01"))
+	assert.Equal(t, []string{"aa:bb:cc:00:00:01"}, s.ObserveGateway("192.168.1.1", "aa:bb:cc:00:00:01"))
+	assert.Equal(t, []string{"aa:bb:cc:00:00:01"}, s.ObserveGateway("192.168.1.1", "aa:bb:cc:00:00:02"))
+	require.NoError(t, s.Save())
+
+	s2 := NewStore(path)
+	require.NoError(t, s2.Load())
+	assert.Equal(t, []string{"aa:bb:cc:00:00:01", "aa:bb:cc:00:00:02"}, s2.Data().GatewayMACs["192.168.1.1"])
+	for i := 0; i < maxGatewayMACs; i++ {
+		s2.ObserveGateway("192.168.1.1", fmt.Sprintf("aa:bb:cc:00:01:%02x", i))
+	}
+	assert.Len(t, s2.Data().GatewayMACs["192.168.1.1"], maxGatewayMACs)
+}
@@ -0,0 +1,96 @@
+// Package buildinfo describes this agent binary: its version and which
+// optional subsystems were compiled in. Constrained endpoints can build a
+// slim agent that leaves the heavy ones out:
+//
+//	go build -tags slim            # every optional subsystem left out
+//	go build -tags no_rego,no_history
+//
+// Each optional subsystem registers itself from a file behind its build
+// tag, so the list always matches what was linked.
+package buildinfo
+
+import (
+	"runtime/debug"
+	"slices"
+	"sync"
+)
+
+// Version is the release version, set at link time with
+// -ldflags "-X compliance-agent/buildinfo.Version=v1.2.3". Unset, it
+// falls back to the VCS revision Go recorded, then to "dev".
+var Version = ""
+
+// ReleaseKey is the public key release binaries are signed with, set at
+// link time by packaging/build.sh as the base64 of the raw Ed25519 key
+// (several separated by commas, while a key is rotated). Unset, the
+// binary doesn't check its own signature; see package release.
+var ReleaseKey = ""
+
+// Optional lists every subsystem a build can leave out, with the tag
+// that does it. slim implies all of them.
+var Optional = map[string]string{
+	"rego":    "no_rego",
+	"scripts": "no_scripts",
+	"history": "no_history",
+	"server":  "no_server",
+	"sinks":   "no_sinks",
+	"pdf":     "no_pdf",
+}
+
+var (
+	mu       sync.Mutex
+	features []string
+)
+
+// Register records an optional subsystem as compiled in. It is called
+// from init functions.
+func Register(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Contains(features, name) {
+		features = append(features, name)
+	}
+}
+
+// Features returns the optional subsystems compiled in, sorted.
+func Features() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	out := append([]string{}, features...)
+	slices.Sort(out)
+	return out
+}
+
+// Has reports whether the named subsystem is compiled in.
+func Has(name string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return slices.Contains(features, name)
+}
+
+// Omitted returns the optional subsystems this build left out, sorted.
+func Omitted() []string {
+	var out []string
+	for name := range Optional {
+		if !Has(name) {
+			out = append(out, name)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// AgentVersion returns Version, or the VCS revision when it isn't set.
+func AgentVersion() string {
+	if Version != "" {
+		return Version
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			if s.Key == "vcs.revision" && len(s.Value) >= 12 {
+				return "dev-" + s.Value[:12]
+			}
+		}
+	}
+	return "dev"
+}
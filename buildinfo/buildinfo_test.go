@@ -0,0 +1,27 @@
+package buildinfo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	defer func(saved []string) { features = saved }(features)
+	features = nil
+
+	Register("scripts")
+	Register("rego")
+	Register("rego")
+	assert.Equal(t, []string{"rego", "scripts"}, Features())
+	assert.True(t, Has("rego"))
+	assert.Equal(t, []string{"history", "pdf", "server", "sinks"}, Omitted())
+}
+
+func TestAgentVersion(t *testing.T) {
+	defer func(saved string) { Version = saved }(Version)
+	Version = "v1.2.3"
+	assert.Equal(t, "v1.2.3", AgentVersion())
+	Version = ""
+	assert.NotEmpty(t, AgentVersion())
+}
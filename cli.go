@@ -0,0 +1,686 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"compliance-agent/alerting"
+	"compliance-agent/analyzer"
+	"compliance-agent/buildinfo"
+	"compliance-agent/config"
+	"compliance-agent/errcode"
+	"compliance-agent/guard"
+	"compliance-agent/logging"
+	"compliance-agent/metrics"
+	"compliance-agent/report"
+	"compliance-agent/reportcrypt"
+	"compliance-agent/schema"
+)
+
+// command is one CLI subcommand. Each parses its own flags.
+type command struct {
+	summary string
+	run     func(args []string)
+}
+
+var commands = map[string]command{
+	"run":             {"collect, analyze, save the report and alert (default)", cmdRun},
+	"collect":         {"collect host inventory and write it as JSON for later analysis", cmdCollect},
+	"analyze":         {"evaluate a saved collection against a policy", cmdAnalyze},
+	"report":          {"write a report (json, html, junit, markdown or pdf), from a saved file or a fresh scan", cmdReport},
+	"alert":           {"send a saved report to the enabled alerters", cmdAlert},
+	"daemon":          {"run the full scan repeatedly on an interval", cmdDaemon},
+	"history":         {"list past runs, or show the report in force at a time (history ls|show), locally or with -fleet", cmdHistory},
+	"summary":         {"write the executive summary of the last period for this host or, with -fleet, the fleet", cmdSummary},
+	"privacy":         {"export or purge what the fleet database holds on a host or user, with a record of each purge (privacy export|purge|log)", cmdPrivacy},
+	"policy":          {"make policy signing keys, sign or verify a policy, and preview a policy change (policy keygen|sign|verify|preview)", cmdPolicy},
+	"sbom":            {"write the package inventory as a CycloneDX or SPDX SBOM, optionally uploading it to Dependency-Track", cmdSBOM},
+	"scan-image":      {"scan a container image (reference, docker save/OCI archive or unpacked root) against the policy", cmdScanImage},
+	"server":          {"run the fleet server agents enroll with, upload reports to and fetch policy from", cmdServer},
+	"validate-image":  {"scan a machine image build and fail on regressions from a golden image's report", cmdValidateImage},
+	"verify-log":      {"check the hash chain of the evidence log", cmdVerifyLog},
+	"test-slack":      {"same as notify test slack", cmdTestSlack},
+	"notify":          {"send a test message to each configured alerter and sink (notify test [destination|all])", cmdNotify},
+	"version":         {"print the agent version and the optional features built in", cmdVersion},
+	"schema":          {"dump the datasets and fields this build collects, per platform, or the report's JSON Schema (schema dump|json-schema)", cmdSchema},
+	"assets":          {"write the Grafana dashboard and Prometheus alerting rules for the fleet server's metrics (assets grafana)", cmdAssets},
+	"validate-report": {"check report files against the report's JSON Schema", cmdValidateReport},
+	"rule-stats":      {"measure the fleet policy's checks: noisy, never failing and most suppressed, from the fleet database", cmdRuleStats},
+	"encrypt":         {"make a report encryption key pair, or encrypt a file to public keys (encrypt keygen)", cmdEncrypt},
+	"package":         {"make release signing keys, sign a release binary, and check this binary's signature (package keygen|sign|verify)", cmdPackage},
+	"decrypt":         {"decrypt an encrypted report with a private key", cmdDecrypt},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for n := range commands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", n, commands[n].summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for a command's flags.\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Every command also takes --log-level (debug, info, warn, error) and --log-format (text, json).\n")
+}
+
+// commonFlags are shared by every command that scans the host.
+type commonFlags struct {
+	config   *string
+	policy   *string
+	userMode *bool
+	evidence *string
+	profile  *string
+	root     *string
+}
+
+func addCommonFlags(fs *flag.FlagSet) commonFlags {
+	return commonFlags{
+		config:   fs.String("config", "", "Path to YAML config (optional)"),
+		policy:   fs.String("policy", "", "Path to YAML compliance policy (optional)"),
+		userMode: fs.Bool("user-mode", false, "Unprivileged workstation scan of the current user only (no root, no osqueryd launch)"),
+		evidence: addEvidenceFlag(fs),
+		profile:  addProfileFlag(fs),
+		root:     fs.String("root", "", "Scan the filesystem mounted here instead of the running system (e.g. /mnt/target)"),
+	}
+}
+
+func addProfileFlag(fs *flag.FlagSet) *string {
+	return fs.String("profile", "", "Also check these built-in benchmark profiles, comma-separated (e.g. cis-ubuntu-22.04)")
+}
+
+// withProfiles adds the --profile list to the policy's profiles.
+func withProfiles(p analyzer.Policies, list string) analyzer.Policies {
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || slices.Contains(p.Profiles, name) {
+			continue
+		}
+		if _, ok := analyzer.LookupProfile(name); !ok {
+			usageError("unknown --profile %q (known: %s)", name, strings.Join(analyzer.ProfileNames(), ", "))
+		}
+		p.Profiles = append(p.Profiles, name)
+	}
+	return p
+}
+
+func addExitCodesFlag(fs *flag.FlagSet) *string {
+	return fs.String("exit-codes", analyzer.DefaultExitCodes, "Exit status by worst violation severity, e.g. critical=2,high=1 or any=1 (none: always 0)")
+}
+
+func parseExitCodes(spec string) analyzer.ExitCodes {
+	codes, err := analyzer.ParseExitCodes(spec)
+	if err != nil {
+		fatal(errcode.New(errcode.Usage, err), "-exit-codes")
+	}
+	return codes
+}
+
+func addEvidenceFlag(fs *flag.FlagSet) *string {
+	return fs.String("evidence-manifest", "", "Also write an evidence manifest (SHA-256 of each artifact) to this path (overrides config)")
+}
+
+// load reads the config and policy and validates the scan scope. Policies
+// are loaded before collection so a malformed file fails fast.
+func (f commonFlags) load() (config.Config, analyzer.Policies) {
+	cfg := loadConfig(*f.config)
+	if *f.userMode {
+		cfg.Scope = "user"
+	}
+	if *f.evidence != "" {
+		cfg.Evidence.Manifest = *f.evidence
+	}
+	if *f.root != "" {
+		cfg.Root = *f.root
+	}
+	if cfg.Root != "" {
+		checkRoot(&cfg.Root)
+		if cfg.Scope == "user" {
+			usageError("--root scans a whole system; drop --user-mode (or scope: user)")
+		}
+	}
+	if cfg.Scope != "system" && cfg.Scope != "user" {
+		fatal(errcode.Errorf(errcode.ParseError, "unknown scope %q (want system or user)", cfg.Scope), "config")
+	}
+	if cfg.Scope == "user" && os.Geteuid() == 0 {
+		usageError("user mode must run unprivileged; drop --user-mode (or scope: user) for a system scan")
+	}
+	return cfg, withProfiles(loadPolicies(*f.policy), *f.profile)
+}
+
+// checkRoot makes a --root directory absolute, as the report records it.
+func checkRoot(root *string) {
+	abs, err := filepath.Abs(*root)
+	if fi, serr := os.Stat(abs); err != nil || serr != nil || !fi.IsDir() {
+		usageError("--root %s: not a directory", *root)
+	}
+	*root = abs
+}
+
+// fatal logs err with its error code and exits with the code's status
+// (see package errcode), so scripts can tell why a command stopped.
+func fatal(err error, format string, args ...any) {
+	slog.Error(fmt.Sprintf(format, args...), "err", errcode.Format(err))
+	exit(errcode.Of(err).ExitCode())
+}
+
+// exitHooks run, newest first, before exit ends the process: os.Exit
+// skips deferred calls, and an osqueryd the agent started must not
+// outlive it.
+var exitHooks []func()
+
+// onExit registers f to run when fatal or exit ends the process.
+func onExit(f func()) {
+	exitHooks = append(exitHooks, f)
+}
+
+// exit runs the exit hooks and exits with code.
+func exit(code int) {
+	for i := len(exitHooks) - 1; i >= 0; i-- {
+		exitHooks[i]()
+	}
+	os.Exit(code)
+}
+
+// usageError stops a command run with bad or conflicting flags (see
+// errcode.Usage).
+func usageError(format string, args ...any) {
+	fatal(errcode.Errorf(errcode.Usage, format, args...), "invalid usage")
+}
+
+// parseFlags parses args into fs, a flag.ContinueOnError set. A bad flag
+// exits with the usage status rather than the flag package's 2, which the
+// default -exit-codes give to violations; -h exits 0.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		usageError("%s: %v", fs.Name(), err)
+	}
+}
+
+func loadConfig(path string) config.Config {
+	cfg, err := config.Load(path)
+	if err != nil {
+		fatal(err, "config load")
+	}
+	setupLogging(cfg.Log)
+	return cfg
+}
+
+func loadPolicies(path string) analyzer.Policies {
+	if path == "" {
+		return analyzer.DefaultPolicies()
+	}
+	p, err := analyzer.LoadPolicies(path)
+	if err != nil {
+		fatal(err, "policy load")
+	}
+	return p
+}
+
+func checkFormat(format string) {
+	switch format {
+	case "json", "html", "junit", "markdown":
+	case "pdf":
+		if !buildinfo.Has("pdf") {
+			usageError("--output-format pdf: not compiled into this agent (built with no_pdf)")
+		}
+	default:
+		usageError("unknown --output-format %q (want json, html, junit, markdown or pdf)", format)
+	}
+}
+
+// reportFile is the default file name for a report in format.
+func reportFile(format string) string {
+	switch format {
+	case "junit":
+		return "compliance_report.xml"
+	case "markdown":
+		return "compliance_report.md"
+	}
+	return "compliance_report." + format
+}
+
+// startScanner picks a collector and builds a scanner. The returned func
+// releases both; fatal and exit run it too if it has not run yet.
+func startScanner(cfg config.Config, policies analyzer.Policies) (*scanner, func()) {
+	logging.Component("scan").Info("starting", "version", buildinfo.AgentVersion())
+	c, closeCollector, setupErr := newCollector(cfg)
+	s, err := newScanner(cfg, c, policies)
+	if err != nil {
+		fatal(err, "scanner setup")
+	}
+	s.setupErr = setupErr
+	closeScanner := sync.OnceFunc(func() {
+		s.close()
+		closeCollector()
+	})
+	onExit(closeScanner)
+	return s, closeScanner
+}
+
+func readReport(path string) report.ComplianceReport {
+	var rep report.ComplianceReport
+	b, err := os.ReadFile(path)
+	if err != nil {
+		fatal(err, "read report")
+	}
+	if reportcrypt.IsEncrypted(b) {
+		fatal(errcode.New(errcode.ParseError, fmt.Errorf("%s is encrypted; decrypt it first with `decrypt -key`", path)), "read report")
+	}
+	if err := json.Unmarshal(b, &rep); err != nil {
+		fatal(errcode.New(errcode.ParseError, err), "parse %s", path)
+	}
+	return rep
+}
+
+func cmdRun(args []string) {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	outputFormat := fs.String("output-format", "json", "Report format: json, html, junit, markdown or pdf")
+	outputFile := fs.String("output-file", "", "Report file (default compliance_report.<format>, .xml for junit, .md for markdown)")
+	exitCodes := addExitCodesFlag(fs)
+	// Flags from before subcommands existed, kept so existing cron jobs
+	// and unit files keep working.
+	testSlack := fs.Bool("test-slack", false, "Same as the test-slack command")
+	streaming := fs.Bool("streaming", false, "Same as daemon -streaming")
+	daemon := fs.Bool("daemon", false, "Same as the daemon command")
+	fs.Duration("interval", 0, "Scan interval for -daemon/-streaming (overrides config)")
+	parseFlags(fs, args)
+
+	if *testSlack {
+		cmdTestSlack([]string{"-config", *common.config})
+		return
+	}
+	if *daemon || *streaming {
+		cmdDaemon(args)
+		return
+	}
+
+	cfg, policies := common.load()
+	checkFormat(*outputFormat)
+	if cfg.Mode == "daemon" || cfg.Mode == "streaming" {
+		cmdDaemon(args)
+		return
+	}
+	checkReleaseSignature(cfg.Release)
+	codes := parseExitCodes(*exitCodes)
+
+	ctx, cancel := signalContext()
+	defer cancel()
+	s, closeScanner := startScanner(cfg, policies)
+	defer closeScanner()
+	s.outputFormat = *outputFormat
+	s.outputFile = *outputFile
+	s.policyPath = *common.policy
+	s.profiles = *common.profile
+	s.verbose = true
+	rep, err := s.scan(ctx)
+	if err != nil {
+		fatal(err, "scan")
+	}
+	if code := codes.Code(rep.Violations); code != 0 {
+		exit(code)
+	}
+}
+
+func cmdCollect(args []string) {
+	fs := flag.NewFlagSet("collect", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	all := fs.Bool("all", false, "Run every optional collector, not just those the policy needs")
+	out := fs.String("o", "collection.json", "Output file (- for stdout)")
+	parseFlags(fs, args)
+
+	cfg, policies := common.load()
+	// A collection isn't a finished report.
+	cfg.History.Path = ""
+	cfg.Evidence.Log = ""
+	opts := optionsFor(policies)
+	if *all {
+		opts = allOptions(policies)
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+	s, closeScanner := startScanner(cfg, policies)
+	defer closeScanner()
+	rep, err := s.collect(ctx, opts)
+	if err != nil {
+		fatal(err, "collect")
+	}
+	if err := writeReport(&rep, "json", *out); err != nil {
+		fatal(err, "write collection")
+	}
+	if *out != "-" {
+		fmt.Fprintf(os.Stderr, "Saved collection to %s\n", *out)
+	}
+	if cfg.Evidence.Manifest != "" {
+		if err := writeEvidence(cfg.Evidence.Manifest, rep, evidenceFile{"collection", *out}); err != nil {
+			fatal(err, "evidence manifest")
+		}
+	}
+}
+
+func cmdAnalyze(args []string) {
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to YAML config (optional; for risk scoring)")
+	policyPath := fs.String("policy", "", "Path to YAML compliance policy (optional)")
+	in := fs.String("i", "collection.json", "Collection (or report) to analyze")
+	out := fs.String("o", "compliance_report.json", "Output file (- for stdout)")
+	outputFormat := fs.String("output-format", "json", "Report format: json, html, junit, markdown or pdf")
+	evidenceManifest := addEvidenceFlag(fs)
+	profile := addProfileFlag(fs)
+	exitCodes := addExitCodesFlag(fs)
+	parseFlags(fs, args)
+	checkFormat(*outputFormat)
+	codes := parseExitCodes(*exitCodes)
+
+	risk, err := riskModel(loadConfig(*configPath).Risk)
+	if err != nil {
+		fatal(errcode.New(errcode.ParseError, err), "config")
+	}
+	policies := withProfiles(loadPolicies(*policyPath), *profile)
+	rep := readReport(*in)
+	analyze(&rep, policies, risk, nil)
+	if err := writeReport(&rep, *outputFormat, *out); err != nil {
+		fatal(err, "write report")
+	}
+	if *out != "-" {
+		fmt.Fprintf(os.Stderr, "%d violation(s); saved report to %s\n", len(rep.Violations), *out)
+	}
+	if *evidenceManifest != "" {
+		if err := writeEvidence(*evidenceManifest, rep,
+			evidenceFile{"collection", *in},
+			evidenceFile{"report", *out},
+			evidenceFile{"policy", *policyPath},
+		); err != nil {
+			fatal(err, "evidence manifest")
+		}
+	}
+	if code := codes.Code(rep.Violations); code != 0 {
+		os.Exit(code)
+	}
+}
+
+func cmdReport(args []string) {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	in := fs.String("i", "", "Saved report to render (default: scan the host now)")
+	outputFormat := fs.String("output-format", "json", "Report format: json, html, junit, markdown or pdf")
+	out := fs.String("o", "", "Output file (default compliance_report.<format>, .xml for junit, .md for markdown; - for stdout)")
+	exitCodes := addExitCodesFlag(fs)
+	parseFlags(fs, args)
+	checkFormat(*outputFormat)
+	codes := parseExitCodes(*exitCodes)
+	defaultOut := *out == ""
+	if defaultOut {
+		*out = reportFile(*outputFormat)
+	}
+
+	var rep report.ComplianceReport
+	var manifest string
+	write := writeReport
+	if *in != "" {
+		rep = readReport(*in)
+		manifest = loadConfig(*common.config).Evidence.Manifest
+		if *common.evidence != "" {
+			manifest = *common.evidence
+		}
+	} else {
+		// A fresh scan without the side effects of `run`: no history or
+		// evidence log entry and no alerts.
+		cfg, policies := common.load()
+		cfg.History.Path = ""
+		cfg.Evidence.Log = ""
+		manifest = cfg.Evidence.Manifest
+		ctx, cancel := signalContext()
+		defer cancel()
+		s, closeScanner := startScanner(cfg, policies)
+		var err error
+		rep, err = s.collect(ctx, optionsFor(policies))
+		closeScanner()
+		if err != nil {
+			fatal(err, "collect")
+		}
+		analyze(&rep, policies, s.risk, nil)
+		write = s.writeReport
+		if defaultOut {
+			*out = s.reportFile(*outputFormat)
+		}
+	}
+	if err := write(&rep, *outputFormat, *out); err != nil {
+		fatal(err, "write report")
+	}
+	if *out != "-" {
+		fmt.Fprintf(os.Stderr, "Saved report to %s\n", *out)
+	}
+	if manifest != "" {
+		files := []evidenceFile{{"report", *out}}
+		if *in != "" {
+			files = append(files, evidenceFile{"source", *in})
+		} else {
+			files = append(files, evidenceFile{"policy", *common.policy})
+		}
+		if err := writeEvidence(manifest, rep, files...); err != nil {
+			fatal(err, "evidence manifest")
+		}
+	}
+	if code := codes.Code(rep.Violations); code != 0 {
+		os.Exit(code)
+	}
+}
+
+func cmdAlert(args []string) {
+	fs := flag.NewFlagSet("alert", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to YAML config (optional)")
+	in := fs.String("i", "compliance_report.json", "Report to send")
+	force := fs.Bool("force", false, "Send even what alert dedup would suppress")
+	parseFlags(fs, args)
+
+	cfg := loadConfig(*configPath)
+	if *force {
+		cfg.Alerting.Dedup.Window = 0
+	}
+	alerters, err := alerting.Build(alertingConfig(cfg))
+	if err != nil {
+		fatal(err, "alerting setup")
+	}
+	correlation, err := alerting.CorrelationRules(cfg.Alerting.Correlation)
+	if err != nil {
+		fatal(errcode.New(errcode.ParseError, err), "alerting.correlation")
+	}
+	rep := readReport(*in)
+	var rec guard.Recorder
+	sendAlerts(&rec, nil, alerters, correlation, rep, nil, nil)
+	if errs := rec.Errors(); len(errs) > 0 {
+		slog.Error("alerts not delivered", "alerts", len(errs), "code", errcode.NotifierFailure)
+		os.Exit(errcode.NotifierFailure.ExitCode())
+	}
+}
+
+func cmdDaemon(args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	outputFormat := fs.String("output-format", "json", "Report format: json, html, junit, markdown or pdf")
+	outputFile := fs.String("output-file", "", "Report file, rewritten each scan (default compliance_report.<format>)")
+	interval := fs.Duration("interval", 0, "Scan interval (overrides config)")
+	streaming := fs.Bool("streaming", false, "Run the lightweight streaming loop (snapshots and ML scores only)")
+	// Accept the legacy run flags when forwarded from cmdRun.
+	fs.Bool("daemon", false, "")
+	fs.Bool("test-slack", false, "")
+	fs.String("exit-codes", "", "")
+	parseFlags(fs, args)
+
+	cfg, policies := common.load()
+	checkFormat(*outputFormat)
+	checkReleaseSignature(cfg.Release)
+	if *interval > 0 {
+		cfg.Interval = *interval
+	}
+	if *streaming || cfg.Mode == "streaming" {
+		runStreaming(cfg)
+		return
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+	s, closeScanner := startScanner(cfg, policies)
+	defer closeScanner()
+	s.outputFormat = *outputFormat
+	s.outputFile = *outputFile
+	s.policyPath = *common.policy
+	s.profiles = *common.profile
+	runDaemon(ctx, s, cfg.Interval)
+}
+
+// cmdTestSlack is the command `notify test slack` grew out of, kept for
+// existing scripts.
+func cmdTestSlack(args []string) {
+	fs := flag.NewFlagSet("test-slack", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to YAML config (optional)")
+	parseFlags(fs, args)
+	cmdNotify([]string{"test", "-config", *configPath, "slack"})
+}
+
+func cmdVersion(args []string) {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Print as JSON")
+	parseFlags(fs, args)
+
+	info := report.AgentInfo{Version: buildinfo.AgentVersion(), Features: buildinfo.Features()}
+	if *asJSON {
+		dumpJSON(info)
+		return
+	}
+	fmt.Printf("compliance-agent %s (%s, %s/%s)\n", info.Version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	features := strings.Join(info.Features, ", ")
+	if features == "" {
+		features = "none"
+	}
+	fmt.Printf("features: %s\n", features)
+	if omitted := buildinfo.Omitted(); len(omitted) > 0 {
+		fmt.Printf("omitted:  %s\n", strings.Join(omitted, ", "))
+	}
+}
+
+// cmdSchema prints machine-readable documentation of what policies can be
+// written against, `schema dump [-platform linux]`, or of the report
+// format, `schema json-schema`.
+func cmdSchema(args []string) {
+	if len(args) > 0 && args[0] == "json-schema" {
+		b, err := schema.ReportSchemaJSON()
+		if err != nil {
+			fatal(errcode.New(errcode.Internal, err), "schema")
+		}
+		os.Stdout.Write(b)
+		return
+	}
+	if len(args) == 0 || args[0] != "dump" {
+		fmt.Fprintf(os.Stderr, "Usage: %s schema dump [-platform name]\n       %s schema json-schema\n", os.Args[0], os.Args[0])
+		usageError("schema: want dump or json-schema")
+	}
+	fs := flag.NewFlagSet("schema dump", flag.ContinueOnError)
+	platform := fs.String("platform", "", "Only list datasets collected on this platform (e.g. linux, darwin, windows, freebsd)")
+	parseFlags(fs, args[1:])
+	// Unescaped, so types read array<object> rather than array\u003cobject\u003e.
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(schema.Dump(*platform)); err != nil {
+		fatal(err, "schema")
+	}
+}
+
+// cmdValidateReport checks report files against the report's JSON Schema:
+// `validate-report [-strict] report.json...`. Each problem is printed as
+// file: path: message; any makes it exit with the parse-error status.
+func cmdValidateReport(args []string) {
+	fs := flag.NewFlagSet("validate-report", flag.ContinueOnError)
+	strict := fs.Bool("strict", false, "Also reject fields the schema doesn't list, e.g. from a newer agent")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s validate-report [-strict] report.json... (- for stdin)\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		usageError("validate-report: no report given")
+	}
+	s := schema.ReportSchema()
+	invalid := false
+	for _, path := range fs.Args() {
+		var b []byte
+		var err error
+		if path == "-" {
+			b, err = io.ReadAll(os.Stdin)
+		} else {
+			b, err = os.ReadFile(path)
+		}
+		if err != nil {
+			fatal(err, "read report")
+		}
+		problems, err := s.Validate(b, *strict)
+		if err != nil {
+			problems = []schema.Problem{{Message: "not JSON: " + err.Error()}}
+		}
+		for _, p := range problems {
+			fmt.Printf("%s: %s\n", path, p)
+		}
+		if len(problems) > 0 {
+			invalid = true
+			continue
+		}
+		fmt.Printf("%s: ok\n", path)
+	}
+	if invalid {
+		os.Exit(errcode.ParseError.ExitCode())
+	}
+}
+
+// cmdAssets writes monitoring setup for the fleet server's /metrics:
+// `assets grafana [-o dir]` writes the Grafana dashboard and the
+// Prometheus alerting rules.
+func cmdAssets(args []string) {
+	if len(args) == 0 || args[0] != "grafana" {
+		fmt.Fprintf(os.Stderr, "Usage: %s assets grafana [-o dir]\n", os.Args[0])
+		usageError("assets: want grafana")
+	}
+	fs := flag.NewFlagSet("assets grafana", flag.ContinueOnError)
+	dir := fs.String("o", ".", "Directory to write "+metrics.DashboardFile+" and "+metrics.AlertRulesFile+" to")
+	parseFlags(fs, args[1:])
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		fatal(err, "assets")
+	}
+	for _, asset := range []struct {
+		name string
+		gen  func() ([]byte, error)
+	}{
+		{metrics.DashboardFile, metrics.Dashboard},
+		{metrics.AlertRulesFile, metrics.AlertRules},
+	} {
+		b, err := asset.gen()
+		if err != nil {
+			fatal(errcode.New(errcode.Internal, err), "assets: "+asset.name)
+		}
+		path := filepath.Join(*dir, asset.name)
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			fatal(err, "assets")
+		}
+		fmt.Println(path)
+	}
+}
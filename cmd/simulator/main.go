@@ -0,0 +1,52 @@
+// Command simulator load-tests a report aggregation server by running a
+// fleet of fake agents against it:
+//
+//	go run ./cmd/simulator -url http://localhost:8080/api/v1/reports -agents 5000 -interval 1m -duration 10m
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"compliance-agent/simulator"
+)
+
+func main() {
+	url := flag.String("url", "http://localhost:8080/api/v1/reports", "Endpoint that receives reports")
+	token := flag.String("token", os.Getenv("SIMULATOR_TOKEN"), "Bearer token for the server (default $SIMULATOR_TOKEN)")
+	agents := flag.Int("agents", 100, "Number of simulated agents")
+	interval := flag.Duration("interval", time.Minute, "How often each agent reports")
+	duration := flag.Duration("duration", 5*time.Minute, "How long to run (0: until interrupted)")
+	seed := flag.Int64("seed", 1, "Random seed; the same seed generates the same fleet")
+	rate := flag.Float64("violation-rate", 0.15, "Chance of each kind of finding on a host, 0..1")
+	timeout := flag.Duration("timeout", 30*time.Second, "Per-request timeout")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	fmt.Fprintf(os.Stderr, "Simulating %d agents reporting every %s to %s\n", *agents, *interval, *url)
+	stats, err := simulator.Run(ctx, simulator.Options{
+		URL:           *url,
+		Token:         *token,
+		Agents:        *agents,
+		Interval:      *interval,
+		Duration:      *duration,
+		Seed:          *seed,
+		ViolationRate: *rate,
+		Timeout:       *timeout,
+	})
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	fmt.Println(stats)
+	if stats.Sent == 0 && stats.Failed > 0 {
+		os.Exit(1)
+	}
+}
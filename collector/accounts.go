@@ -0,0 +1,109 @@
+package collector
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AuthorizedKey identifies one entry of ~/.ssh/authorized_keys by its
+// SHA256 fingerprint; the key material itself is not kept.
+type AuthorizedKey struct {
+	Type        string `json:"type"`
+	Fingerprint string `json:"fingerprint"`
+	Comment     string `json:"comment,omitempty"`
+}
+
+// ScreenLock is an account's idle screen-lock setting. Enabled is nil
+// when it couldn't be determined (no desktop session, unsupported OS).
+type ScreenLock struct {
+	Enabled *bool  `json:"enabled"`
+	Source  string `json:"source,omitempty"`
+}
+
+// CollectAccounts gathers UserScope data for every interactive local
+// account in users (as returned by CollectUsers), so workstation checks
+// can be attributed to the specific person rather than the host.
+func CollectAccounts(users []User) []UserScope {
+	var out []UserScope
+	for _, u := range users {
+		if !interactiveAccount(u) {
+			continue
+		}
+		s, _ := CollectUserScope(u.Username, u.Directory)
+		out = append(out, s)
+	}
+	return out
+}
+
+// interactiveAccount filters out system/service accounts: low UIDs,
+// nologin shells, and accounts without a real home directory.
+func interactiveAccount(u User) bool {
+	name, home, shell := u.Username, u.Directory, u.Shell
+	if name == "" || home == "" || home == "/" || home == "/var/empty" {
+		return false
+	}
+	if strings.HasSuffix(shell, "nologin") || strings.HasSuffix(shell, "/false") {
+		return false
+	}
+	// A negative UID means the source couldn't report one.
+	if u.UID >= 0 && u.UID < minHumanUID {
+		return false
+	}
+	if fi, err := os.Stat(home); err != nil || !fi.IsDir() {
+		return false
+	}
+	return true
+}
+
+// readAuthorizedKeys fingerprints the keys in ~/.ssh/authorized_keys,
+// skipping comments and any leading options field.
+func readAuthorizedKeys(home string) []AuthorizedKey {
+	f, err := os.Open(filepath.Join(home, ".ssh", "authorized_keys"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var keys []AuthorizedKey
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if k, ok := parseAuthorizedKey(sc.Text()); ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func parseAuthorizedKey(line string) (AuthorizedKey, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return AuthorizedKey{}, false
+	}
+	fields := strings.Fields(line)
+	// Find the key type; anything before it is an options field.
+	for i := 0; i+1 < len(fields); i++ {
+		if !isKeyType(fields[i]) {
+			continue
+		}
+		blob, err := base64.StdEncoding.DecodeString(fields[i+1])
+		if err != nil {
+			return AuthorizedKey{}, false
+		}
+		sum := sha256.Sum256(blob)
+		return AuthorizedKey{
+			Type:        fields[i],
+			Fingerprint: "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]),
+			Comment:     strings.Join(fields[i+2:], " "),
+		}, true
+	}
+	return AuthorizedKey{}, false
+}
+
+func isKeyType(s string) bool {
+	return strings.HasPrefix(s, "ssh-") || strings.HasPrefix(s, "ecdsa-") ||
+		strings.HasPrefix(s, "sk-ssh-") || strings.HasPrefix(s, "sk-ecdsa-")
+}
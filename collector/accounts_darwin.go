@@ -0,0 +1,22 @@
+package collector
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// minHumanUID is where macOS starts numbering people's accounts.
+const minHumanUID = 500
+
+// readScreenLock reads an account's lock-on-idle preference from its
+// per-user screensaver plist, by path.
+func readScreenLock(_, home string) ScreenLock {
+	plist := filepath.Join(home, "Library", "Preferences", "com.apple.screensaver")
+	out, err := exec.Command("defaults", "read", plist, "askForPassword").Output()
+	if err != nil {
+		return ScreenLock{}
+	}
+	on := strings.TrimSpace(string(out)) == "1"
+	return ScreenLock{Enabled: &on, Source: "com.apple.screensaver askForPassword"}
+}
@@ -0,0 +1,33 @@
+package collector
+
+import (
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+)
+
+// minHumanUID is where Linux distributions start numbering people's
+// accounts (UID_MIN in login.defs).
+const minHumanUID = 1000
+
+// readScreenLock reads an account's lock-on-idle preference: GNOME's
+// setting, through gsettings as that user (dconf reads don't need a
+// session).
+func readScreenLock(username, home string) ScreenLock {
+	args := []string{"get", "org.gnome.desktop.screensaver", "lock-enabled"}
+	cmd := exec.Command("gsettings", args...)
+	if cur, err := user.Current(); err == nil && cur.Username != username {
+		if os.Geteuid() != 0 {
+			return ScreenLock{}
+		}
+		cmd = exec.Command("runuser", append([]string{"-u", username, "--", "gsettings"}, args...)...)
+	}
+	cmd.Env = append(os.Environ(), "HOME="+home)
+	out, err := cmd.Output()
+	if err != nil {
+		return ScreenLock{}
+	}
+	on := strings.TrimSpace(string(out)) == "true"
+	return ScreenLock{Enabled: &on, Source: "gsettings org.gnome.desktop.screensaver lock-enabled"}
+}
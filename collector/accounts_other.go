@@ -0,0 +1,10 @@
+//go:build !darwin && !linux && !windows
+
+package collector
+
+// minHumanUID follows Linux on the other Unixes; FreeBSD numbers
+// people's accounts from 1000 too.
+const minHumanUID = 1000
+
+// readScreenLock is not implemented on this platform.
+func readScreenLock(_, _ string) ScreenLock { return ScreenLock{} }
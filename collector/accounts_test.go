@@ -0,0 +1,30 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAuthorizedKey(t *testing.T) {
+	// Blob is base64("hello"); only the fingerprint shape matters here.
+	k, ok := parseAuthorizedKey(`from="10.0.0.0/8",no-pty ssh-ed25519 aGVsbG8= alice@laptop`)
+	require.True(t, ok)
+	assert.Equal(t, "ssh-ed25519", k.Type)
+	assert.Equal(t, "alice@laptop", k.Comment)
+	assert.Equal(t, "SHA256:LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ", k.Fingerprint)
+
+	_, ok = parseAuthorizedKey("# comment")
+	assert.False(t, ok)
+	_, ok = parseAuthorizedKey("ssh-rsa !!notbase64!!")
+	assert.False(t, ok)
+}
+
+func TestInteractiveAccount(t *testing.T) {
+	home := t.TempDir()
+	assert.True(t, interactiveAccount(User{Username: "alice", UID: 1001, Directory: home, Shell: "/bin/zsh"}))
+	assert.False(t, interactiveAccount(User{Username: "daemon", UID: 1, Directory: home, Shell: "/bin/sh"}))
+	assert.False(t, interactiveAccount(User{Username: "svc", UID: 1002, Directory: home, Shell: "/usr/sbin/nologin"}))
+	assert.False(t, interactiveAccount(User{Username: "gone", UID: 1003, Directory: home + "/missing", Shell: "/bin/bash"}))
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package collector
+
+// minHumanUID is 0: the UID a Windows account reports is its RID, and
+// the built-in Administrator's 500 is still a person's account.
+const minHumanUID = 0
+
+// readScreenLock is not implemented on Windows.
+func readScreenLock(_, _ string) ScreenLock { return ScreenLock{} }
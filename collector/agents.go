@@ -0,0 +1,104 @@
+package collector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+)
+
+// AgentSpec identifies a third-party agent (backup client, EDR, MDM) the
+// host must run, and the config files to fingerprint.
+type AgentSpec struct {
+	Name string
+	// Binaries are candidate install paths; the first that exists is
+	// reported.
+	Binaries []string
+	// Packages are package names, matched exactly (case-insensitively);
+	// the first installed one supplies the version.
+	Packages []string
+	// Processes are matched as case-insensitive substrings of running
+	// process names and paths.
+	Processes   []string
+	ConfigFiles []string
+}
+
+// AgentState is what the agent found of one AgentSpec.
+type AgentState struct {
+	Name    string `json:"name"`
+	Binary  string `json:"binary,omitempty"`
+	Package string `json:"package,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Running is nil when the spec lists no processes or the process
+	// list couldn't be collected.
+	Running *bool        `json:"running"`
+	Configs []ConfigHash `json:"configs,omitempty"`
+}
+
+// ConfigHash is the SHA-256 of a config file, or why it couldn't be read.
+type ConfigHash struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// CheckAgents looks for each spec's binaries, packages and processes and
+// hashes its config files. A nil procs or pkgs means that inventory
+// wasn't available, so running state or version is left unknown rather
+// than reported missing.
+func CheckAgents(specs []AgentSpec, procs []Process, pkgs []Package) []AgentState {
+	out := make([]AgentState, 0, len(specs))
+	for _, spec := range specs {
+		st := AgentState{Name: spec.Name}
+		for _, b := range spec.Binaries {
+			if fi, err := os.Stat(b); err == nil && !fi.IsDir() {
+				st.Binary = b
+				break
+			}
+		}
+		if pkgs != nil {
+		findPackage:
+			for _, name := range spec.Packages {
+				for _, p := range pkgs {
+					if strings.EqualFold(p.Name, name) {
+						st.Package, st.Version = p.Name, p.Version
+						break findPackage
+					}
+				}
+			}
+		}
+		if len(spec.Processes) > 0 && procs != nil {
+			running := false
+			for _, p := range procs {
+				if anyContains(p.Name, spec.Processes) || anyContains(p.Path, spec.Processes) {
+					running = true
+					break
+				}
+			}
+			st.Running = &running
+		}
+		for _, path := range spec.ConfigFiles {
+			st.Configs = append(st.Configs, hashConfig(path))
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+func hashConfig(path string) ConfigHash {
+	c := ConfigHash{Path: path}
+	f, err := os.Open(path)
+	if err != nil {
+		c.Error = err.Error()
+		return c
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		c.Error = err.Error()
+		return c
+	}
+	c.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return c
+}
@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAgents(t *testing.T) {
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "falcond")
+	conf := filepath.Join(dir, "falcon.conf")
+	require.NoError(t, os.WriteFile(bin, []byte("#!/bin/sh\n"), 0o755))
+	require.NoError(t, os.WriteFile(conf, []byte("cid=abc\n"), 0o600))
+
+	specs := []AgentSpec{
+		{
+			Name:        "falcon",
+			Binaries:    []string{filepath.Join(dir, "missing"), bin},
+			Packages:    []string{"falcon-sensor"},
+			Processes:   []string{"falcond"},
+			ConfigFiles: []string{conf, filepath.Join(dir, "absent.conf")},
+		},
+		{Name: "backup", Packages: []string{"restic"}, Processes: []string{"restic"}},
+	}
+	procs := []Process{{Name: "falcond", Path: "/opt/CrowdStrike/falcond"}}
+	pkgs := []Package{{Name: "Falcon-Sensor", Version: "7.10.17706"}}
+
+	got := CheckAgents(specs, procs, pkgs)
+	require.Len(t, got, 2)
+	f := got[0]
+	assert.Equal(t, bin, f.Binary)
+	assert.Equal(t, "Falcon-Sensor", f.Package)
+	assert.Equal(t, "7.10.17706", f.Version)
+	require.NotNil(t, f.Running)
+	assert.True(t, *f.Running)
+	require.Len(t, f.Configs, 2)
+	assert.Equal(t, "608927dc6c9dc3a200d5b44d1742ec424914b48d5082664b658c9d7a62964479", f.Configs[0].SHA256)
+	assert.NotEmpty(t, f.Configs[1].Error)
+
+	b := got[1]
+	assert.Empty(t, b.Package)
+	require.NotNil(t, b.Running)
+	assert.False(t, *b.Running)
+
+	unknown := CheckAgents(specs[1:], nil, nil)
+	assert.Nil(t, unknown[0].Running, "no process list, no verdict")
+}
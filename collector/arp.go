@@ -0,0 +1,175 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"strings"
+)
+
+// ARPTable is the IPv4 neighbor table and the default gateway. IPv6 is
+// left out: one host answers for several v6 addresses with the same MAC,
+// which would read as spoofing.
+type ARPTable struct {
+	Entries []Neighbor `json:"entries"`
+	Gateway *Neighbor  `json:"gateway,omitempty"`
+	// KnownGatewayMACs are the MACs seen for this gateway address in
+	// earlier scans, from the baseline. Filled in by the scanner.
+	KnownGatewayMACs []string `json:"known_gateway_macs,omitempty"`
+}
+
+// Neighbor is one resolved IPv4 neighbor.
+type Neighbor struct {
+	IP        string `json:"ip"`
+	MAC       string `json:"mac"`
+	Interface string `json:"interface,omitempty"`
+}
+
+// CollectARPTable reads the neighbor table and resolves the default
+// gateway's MAC from it. A missing gateway is not an error (offline).
+func CollectARPTable() (ARPTable, error) {
+	entries, gwIP, gwIfc, err := readNeighbors()
+	if err != nil {
+		return ARPTable{}, err
+	}
+	t := ARPTable{Entries: entries}
+	if gwIP != "" {
+		gw := Neighbor{IP: gwIP, Interface: gwIfc}
+		for _, e := range t.Entries {
+			if e.IP == gwIP && (gwIfc == "" || e.Interface == "" || e.Interface == gwIfc) {
+				gw.MAC = e.MAC
+				break
+			}
+		}
+		t.Gateway = &gw
+	}
+	return t, nil
+}
+
+// parseIPNeigh reads `ip -4 neigh show`:
+//
+//	192.168.1.1 dev wlan0 lladdr aa:bb:cc:dd:ee:ff REACHABLE
+func parseIPNeigh(out string) []Neighbor {
+	var res []Neighbor
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 {
+			continue
+		}
+		n := Neighbor{IP: f[0]}
+		for i := 1; i+1 < len(f); i++ {
+			switch f[i] {
+			case "dev":
+				n.Interface = f[i+1]
+			case "lladdr":
+				n.MAC = normalizeMAC(f[i+1])
+			}
+		}
+		if n.MAC != "" && strings.Contains(n.IP, ".") {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+// parseProcARP reads /proc/net/arp: IP, HW type, flags, HW address, mask,
+// device, under a header line.
+func parseProcARP(s string) []Neighbor {
+	var res []Neighbor
+	lines := strings.Split(s, "\n")
+	for _, line := range lines[min(1, len(lines)):] {
+		f := strings.Fields(line)
+		// Flags 0x0 is an incomplete entry.
+		if len(f) < 6 || f[2] == "0x0" {
+			continue
+		}
+		if mac := normalizeMAC(f[3]); mac != "" {
+			res = append(res, Neighbor{IP: f[0], MAC: mac, Interface: f[5]})
+		}
+	}
+	return res
+}
+
+// parseProcRoute finds the default route in /proc/net/route, whose
+// addresses are little-endian hex.
+func parseProcRoute(s string) (ip, ifc string) {
+	lines := strings.Split(s, "\n")
+	for _, line := range lines[min(1, len(lines)):] {
+		f := strings.Fields(line)
+		if len(f) < 3 || f[1] != "00000000" {
+			continue
+		}
+		b, err := hex.DecodeString(f[2])
+		if err != nil || len(b) != 4 {
+			continue
+		}
+		return net.IPv4(b[3], b[2], b[1], b[0]).String(), f[0]
+	}
+	return "", ""
+}
+
+// parseArpAN reads macOS `arp -an`:
+//
+//	? (192.168.1.1) at a:b:c:d:e:f on en0 ifscope [ethernet]
+func parseArpAN(out string) []Neighbor {
+	var res []Neighbor
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) < 4 || f[2] != "at" {
+			continue
+		}
+		n := Neighbor{IP: strings.Trim(f[1], "()"), MAC: normalizeMAC(f[3])}
+		if len(f) >= 6 && f[4] == "on" {
+			n.Interface = f[5]
+		}
+		if n.MAC != "" {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+// parseRouteGet reads the gateway and interface from macOS
+// `route -n get default`.
+func parseRouteGet(out string) (ip, ifc string) {
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		k, v, ok := strings.Cut(strings.TrimSpace(sc.Text()), ":")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "gateway":
+			ip = strings.TrimSpace(v)
+		case "interface":
+			ifc = strings.TrimSpace(v)
+		}
+	}
+	return ip, ifc
+}
+
+// normalizeMAC returns a lowercase colon-separated MAC with zero-padded
+// octets (macOS prints "0:1b:..." and Windows "AA-BB-..."), or "" for
+// incomplete, broadcast, all-zero and multicast addresses.
+func normalizeMAC(s string) string {
+	hw, err := net.ParseMAC(padMAC(strings.ReplaceAll(s, "-", ":")))
+	if err != nil || len(hw) != 6 {
+		return ""
+	}
+	if hw[0]&1 == 1 || hw.String() == "00:00:00:00:00:00" {
+		return ""
+	}
+	return hw.String()
+}
+
+func padMAC(s string) string {
+	parts := strings.Split(s, ":")
+	for i, p := range parts {
+		if len(p) == 1 {
+			parts[i] = "0" + p
+		}
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,20 @@
+package collector
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// readNeighbors reads the neighbor table from `arp -an` and the default
+// gateway from `route -n get default`.
+func readNeighbors() (entries []Neighbor, gwIP, gwIfc string, err error) {
+	out, err := exec.Command("arp", "-an").Output()
+	if err != nil {
+		return nil, "", "", fmt.Errorf("arp -an: %w", err)
+	}
+	entries = parseArpAN(string(out))
+	if out, err := exec.Command("route", "-n", "get", "default").Output(); err == nil {
+		gwIP, gwIfc = parseRouteGet(string(out))
+	}
+	return entries, gwIP, gwIfc, nil
+}
@@ -0,0 +1,29 @@
+package collector
+
+import (
+	"os"
+	"os/exec"
+)
+
+// readNeighbors reads the neighbor table with ip, else /proc/net/arp,
+// and the default gateway from /proc/net/route.
+func readNeighbors() (entries []Neighbor, gwIP, gwIfc string, err error) {
+	if entries, err = neighborsLinux(); err != nil {
+		return nil, "", "", err
+	}
+	if b, err := os.ReadFile("/proc/net/route"); err == nil {
+		gwIP, gwIfc = parseProcRoute(string(b))
+	}
+	return entries, gwIP, gwIfc, nil
+}
+
+func neighborsLinux() ([]Neighbor, error) {
+	if out, err := exec.Command("ip", "-4", "neigh", "show").Output(); err == nil {
+		return parseIPNeigh(string(out)), nil
+	}
+	b, err := os.ReadFile("/proc/net/arp")
+	if err != nil {
+		return nil, err
+	}
+	return parseProcARP(string(b)), nil
+}
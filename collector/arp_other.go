@@ -0,0 +1,6 @@
+//go:build !linux && !darwin && !windows
+
+package collector
+
+// readNeighbors is not implemented on this platform.
+func readNeighbors() ([]Neighbor, string, string, error) { return nil, "", "", nil }
@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIPNeigh(t *testing.T) {
+	out := "192.168.1.1 dev wlan0 lladdr AA:BB:CC:DD:EE:01 REACHABLE\n" +
+		"192.168.1.7 dev wlan0  FAILED\n" +
+		"192.168.1.9 dev wlan0 lladdr 01:00:5e:00:00:fb STALE\n" +
+		"192.168.1.20 dev wlan0 lladdr aa:bb:cc:dd:ee:14 STALE\n"
+	assert.Equal(t, []Neighbor{
+		{IP: "192.168.1.1", MAC: "aa:bb:cc:dd:ee:01", Interface: "wlan0"},
+		{IP: "192.168.1.20", MAC: "aa:bb:cc:dd:ee:14", Interface: "wlan0"},
+	}, parseIPNeigh(out))
+}
+
+func TestParseProcARPAndRoute(t *testing.T) {
+	arp := "IP address       HW type     Flags       HW address            Mask     Device\n" +
+		"10.0.2.2         0x1         0x2         52:54:00:12:35:02     *        eth0\n" +
+		"10.0.2.9         0x1         0x0         00:00:00:00:00:00     *        eth0\n"
+	assert.Equal(t, []Neighbor{{IP: "10.0.2.2", MAC: "52:54:00:12:35:02", Interface: "eth0"}}, parseProcARP(arp))
+
+	route := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\n" +
+		"eth0\t0002000A\t00000000\t0001\t0\t0\t0\t00FFFFFF\n" +
+		"eth0\t00000000\t0202000A\t0003\t0\t0\t0\t00000000\n"
+	ip, ifc := parseProcRoute(route)
+	assert.Equal(t, "10.0.2.2", ip)
+	assert.Equal(t, "eth0", ifc)
+}
+
+func TestParseArpANAndRouteGet(t *testing.T) {
+	out := "? (192.168.1.1) at 0:1b:c:d:e:f on en0 ifscope [ethernet]\n" +
+		"? (192.168.1.5) at (incomplete) on en0 ifscope [ethernet]\n" +
+		"? (192.168.1.255) at ff:ff:ff:ff:ff:ff on en0 ifscope [ethernet]\n"
+	assert.Equal(t, []Neighbor{{IP: "192.168.1.1", MAC: "00:1b:0c:0d:0e:0f", Interface: "en0"}}, parseArpAN(out))
+
+	ip, ifc := parseRouteGet("   route to: default\ndestination: default\n       mask: default\n    gateway: 192.168.1.1\n  interface: en0\n")
+	assert.Equal(t, "192.168.1.1", ip)
+	assert.Equal(t, "en0", ifc)
+}
+
+func TestNormalizeMAC(t *testing.T) {
+	assert.Equal(t, "aa:bb:cc:dd:ee:ff", normalizeMAC("AA-BB-CC-DD-EE-FF"))
+	assert.Equal(t, "", normalizeMAC("00-00-00-00-00-00"))
+	assert.Equal(t, "", normalizeMAC("(incomplete)"))
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package collector
+
+// readNeighbors reads the neighbor table and the lowest-metric default
+// route from the NetTCPIP cmdlets.
+func readNeighbors() (entries []Neighbor, gwIP, gwIfc string, err error) {
+	rows, err := runPowerShellJSON("Get-NetNeighbor -AddressFamily IPv4 | Select-Object IPAddress,LinkLayerAddress,InterfaceAlias | ConvertTo-Json -Compress")
+	if err != nil {
+		return nil, "", "", err
+	}
+	for _, r := range rows {
+		if mac := normalizeMAC(r["LinkLayerAddress"]); mac != "" {
+			entries = append(entries, Neighbor{IP: r["IPAddress"], MAC: mac, Interface: r["InterfaceAlias"]})
+		}
+	}
+	if rows, err := runPowerShellJSON("Get-NetRoute -DestinationPrefix 0.0.0.0/0 | Sort-Object RouteMetric | Select-Object -First 1 NextHop,InterfaceAlias | ConvertTo-Json -Compress"); err == nil && len(rows) == 1 {
+		gwIP, gwIfc = rows[0]["NextHop"], rows[0]["InterfaceAlias"]
+	}
+	return entries, gwIP, gwIfc, nil
+}
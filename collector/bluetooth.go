@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// BluetoothState is the host's Bluetooth controller state and paired
+// devices. Powered and Discoverable are nil when unknown (no controller,
+// or the platform doesn't expose it).
+type BluetoothState struct {
+	Powered       *bool             `json:"powered"`
+	Discoverable  *bool             `json:"discoverable"`
+	PairedDevices []BluetoothDevice `json:"paired_devices,omitempty"`
+	Source        string            `json:"source,omitempty"`
+}
+
+// BluetoothDevice is one paired peripheral.
+type BluetoothDevice struct {
+	Name    string `json:"name"`
+	Address string `json:"address,omitempty"`
+	// Type is a coarse class: keyboard, mouse, audio, phone, or other.
+	Type      string `json:"type"`
+	Connected bool   `json:"connected"`
+}
+
+// CollectBluetooth reads Bluetooth state for the current platform. It
+// never fails; a host without Bluetooth reports an empty state.
+func CollectBluetooth() BluetoothState {
+	return collectBluetooth()
+}
+
+// bluetoothType maps a platform's device class or icon name onto the
+// coarse types policies match against.
+func bluetoothType(class string) string {
+	c := strings.ToLower(class)
+	switch {
+	case strings.Contains(c, "keyboard"):
+		return "keyboard"
+	case strings.Contains(c, "mouse"), strings.Contains(c, "trackpad"), strings.Contains(c, "pointing"):
+		return "mouse"
+	case strings.Contains(c, "audio"), strings.Contains(c, "headset"), strings.Contains(c, "headphone"), strings.Contains(c, "speaker"):
+		return "audio"
+	case strings.Contains(c, "phone"):
+		return "phone"
+	}
+	return "other"
+}
+
+// parseSystemProfilerBluetooth reads `system_profiler SPBluetoothDataType
+// -json`. Devices are keyed by name under device_connected and
+// device_not_connected.
+func parseSystemProfilerBluetooth(b []byte) BluetoothState {
+	var doc struct {
+		SP []struct {
+			Controller struct {
+				State        string `json:"controller_state"`
+				Discoverable string `json:"controller_discoverable"`
+			} `json:"controller_properties"`
+			Connected    []map[string]spBluetoothDevice `json:"device_connected"`
+			NotConnected []map[string]spBluetoothDevice `json:"device_not_connected"`
+		} `json:"SPBluetoothDataType"`
+	}
+	st := BluetoothState{Source: "system_profiler"}
+	if err := json.Unmarshal(b, &doc); err != nil || len(doc.SP) == 0 {
+		return st
+	}
+	sp := doc.SP[0]
+	if s := sp.Controller.State; s != "" {
+		st.Powered = boolPtr(s == "attrib_on")
+	}
+	if s := sp.Controller.Discoverable; s != "" {
+		st.Discoverable = boolPtr(s == "attrib_on")
+	}
+	add := func(list []map[string]spBluetoothDevice, connected bool) {
+		for _, m := range list {
+			for name, d := range m {
+				st.PairedDevices = append(st.PairedDevices, BluetoothDevice{
+					Name:      name,
+					Address:   d.Address,
+					Type:      bluetoothType(d.MinorType),
+					Connected: connected,
+				})
+			}
+		}
+	}
+	add(sp.Connected, true)
+	add(sp.NotConnected, false)
+	return st
+}
+
+type spBluetoothDevice struct {
+	Address   string `json:"device_address"`
+	MinorType string `json:"device_minorType"`
+}
+
+// parseBluetoothctlProps reads the indented "Key: value" lines of
+// `bluetoothctl show` / `info`.
+func parseBluetoothctlProps(out string) map[string]string {
+	m := map[string]string{}
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		if k, v, ok := strings.Cut(strings.TrimSpace(sc.Text()), ":"); ok {
+			if _, dup := m[k]; !dup {
+				m[k] = strings.TrimSpace(v)
+			}
+		}
+	}
+	return m
+}
+
+var bluetoothctlDevice = regexp.MustCompile(`^Device ([0-9A-Fa-f:]{17}) (.*)$`)
+
+func parseBluetoothctlDevices(out string) []BluetoothDevice {
+	var devs []BluetoothDevice
+	for _, line := range strings.Split(out, "\n") {
+		if m := bluetoothctlDevice.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			devs = append(devs, BluetoothDevice{Name: m[2], Address: m[1], Type: "other"})
+		}
+	}
+	return devs
+}
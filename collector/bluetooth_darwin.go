@@ -0,0 +1,12 @@
+package collector
+
+import "os/exec"
+
+// collectBluetooth reads system_profiler's Bluetooth report.
+func collectBluetooth() BluetoothState {
+	out, err := exec.Command("system_profiler", "SPBluetoothDataType", "-json").Output()
+	if err != nil {
+		return BluetoothState{}
+	}
+	return parseSystemProfilerBluetooth(out)
+}
@@ -0,0 +1,33 @@
+package collector
+
+import "os/exec"
+
+// collectBluetooth asks BlueZ through bluetoothctl.
+func collectBluetooth() BluetoothState {
+	st := BluetoothState{Source: "bluetoothctl"}
+	out, err := exec.Command("bluetoothctl", "show").Output()
+	if err != nil {
+		return st
+	}
+	props := parseBluetoothctlProps(string(out))
+	if v, ok := props["Powered"]; ok {
+		st.Powered = boolPtr(v == "yes")
+	}
+	if v, ok := props["Discoverable"]; ok {
+		st.Discoverable = boolPtr(v == "yes")
+	}
+	// `devices Paired` replaced `paired-devices` in BlueZ 5.65.
+	out, err = exec.Command("bluetoothctl", "devices", "Paired").Output()
+	if err != nil || len(out) == 0 {
+		out, _ = exec.Command("bluetoothctl", "paired-devices").Output()
+	}
+	for _, d := range parseBluetoothctlDevices(string(out)) {
+		if info, err := exec.Command("bluetoothctl", "info", d.Address).Output(); err == nil {
+			p := parseBluetoothctlProps(string(info))
+			d.Type = bluetoothType(p["Icon"])
+			d.Connected = p["Connected"] == "yes"
+		}
+		st.PairedDevices = append(st.PairedDevices, d)
+	}
+	return st
+}
@@ -0,0 +1,6 @@
+//go:build !linux && !darwin && !windows
+
+package collector
+
+// collectBluetooth is not implemented on this platform.
+func collectBluetooth() BluetoothState { return BluetoothState{} }
@@ -0,0 +1,36 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSystemProfilerBluetooth(t *testing.T) {
+	out := []byte(`{"SPBluetoothDataType":[{
+		"controller_properties":{"controller_state":"attrib_on","controller_discoverable":"attrib_off"},
+		"device_connected":[{"Magic Keyboard":{"device_address":"AA:BB:CC:DD:EE:01","device_minorType":"Keyboard"}}],
+		"device_not_connected":[{"AirPods":{"device_address":"AA:BB:CC:DD:EE:02","device_minorType":"Headphones"}}]
+	}]}`)
+	st := parseSystemProfilerBluetooth(out)
+	require.NotNil(t, st.Powered)
+	require.NotNil(t, st.Discoverable)
+	assert.True(t, *st.Powered)
+	assert.False(t, *st.Discoverable)
+	assert.Equal(t, []BluetoothDevice{
+		{Name: "Magic Keyboard", Address: "AA:BB:CC:DD:EE:01", Type: "keyboard", Connected: true},
+		{Name: "AirPods", Address: "AA:BB:CC:DD:EE:02", Type: "audio"},
+	}, st.PairedDevices)
+}
+
+func TestParseBluetoothctl(t *testing.T) {
+	show := "Controller 00:1A:7D:DA:71:13 (public)\n\tName: host\n\tPowered: yes\n\tDiscoverable: no\n"
+	props := parseBluetoothctlProps(show)
+	assert.Equal(t, "yes", props["Powered"])
+	assert.Equal(t, "no", props["Discoverable"])
+
+	devs := parseBluetoothctlDevices("Device 11:22:33:44:55:66 MX Master 3\nnoise\n")
+	assert.Equal(t, []BluetoothDevice{{Name: "MX Master 3", Address: "11:22:33:44:55:66", Type: "other"}}, devs)
+	assert.Equal(t, "mouse", bluetoothType("input-mouse"))
+}
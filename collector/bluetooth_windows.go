@@ -0,0 +1,30 @@
+//go:build windows
+
+package collector
+
+import "strings"
+
+// collectBluetooth reads the Bluetooth PnP devices.
+func collectBluetooth() BluetoothState {
+	st := BluetoothState{Source: "Get-PnpDevice"}
+	// Paired devices show up as Bluetooth-enumerated PnP devices; the
+	// radio itself is the one with a "Radio" friendly name. Windows has
+	// no discoverable flag outside the Settings UI, so it stays unknown.
+	rows, err := runPowerShellJSON(`Get-PnpDevice -Class Bluetooth,Keyboard,Mouse,AudioEndpoint -ErrorAction SilentlyContinue | Where-Object { $_.InstanceId -like 'BTH*' } | Select-Object FriendlyName,Class,Status,InstanceId | ConvertTo-Json -Compress`)
+	if err != nil {
+		return st
+	}
+	for _, r := range rows {
+		name := r["FriendlyName"]
+		if strings.Contains(name, "Radio") || strings.Contains(name, "Enumerator") {
+			st.Powered = boolPtr(r["Status"] == "OK")
+			continue
+		}
+		st.PairedDevices = append(st.PairedDevices, BluetoothDevice{
+			Name:      name,
+			Type:      bluetoothType(r["Class"] + " " + name),
+			Connected: r["Status"] == "OK",
+		})
+	}
+	return st
+}
@@ -0,0 +1,181 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// minSupportedOSQuery is the oldest osquery the compatibility table has
+// been checked against. Older daemons still work but are flagged.
+const minSupportedOSQuery = "4.0.0"
+
+// OSQueryInfo is the subset of the osquery_info table the agent uses to
+// pick compatible SQL and to record in the report.
+type OSQueryInfo struct {
+	Version       string `json:"version"`
+	BuildPlatform string `json:"build_platform"`
+	BuildDistro   string `json:"build_distro,omitempty"`
+	Supported     bool   `json:"supported"`
+}
+
+// compatQuery is one SQL variant for a logical query. The first entry in
+// a list whose constraints match the running daemon wins, so newer and
+// more specific variants go first.
+type compatQuery struct {
+	MinVersion string   // inclusive; "" matches any version
+	Platforms  []string // osquery build_platform values; nil matches any
+	SQL        string   // may contain one %d for a row limit, so a literal % is %%
+}
+
+// compatQueries maps a logical query name onto per-version/per-platform
+// SQL. There is no generic "packages" table in osquery, so package
+// collection is inherently platform-specific.
+var compatQueries = map[string][]compatQuery{
+	"users": {
+		{SQL: "SELECT username, uid, gid, description, directory, shell FROM users;"},
+	},
+	"processes": {
+		{SQL: "SELECT pid, name, path, cmdline, uid FROM processes LIMIT %d;"},
+	},
+	"listening_ports": {
+		{SQL: "SELECT l.port, l.protocol, l.address, l.pid, p.name " +
+			"FROM listening_ports l LEFT JOIN processes p USING (pid) " +
+			"WHERE l.address != '::' AND l.port > 0;"},
+	},
+	"connections": {
+		{SQL: "SELECT s.pid, p.name, s.protocol, s.local_address, s.local_port, s.remote_address, s.remote_port " +
+			"FROM process_open_sockets s LEFT JOIN processes p USING (pid) " +
+			"WHERE s.state = 'ESTABLISHED' AND s.remote_port > 0 LIMIT %d;"},
+	},
+	// Linux has no osquery variant: disk_encryption names dm devices
+	// (/dev/dm-1) while mounts names their mapper aliases, so the two
+	// can't be joined to find the root volume.
+	"disk_encryption": {
+		{Platforms: []string{"darwin"}, SQL: "SELECT d.name, d.encrypted, d.type, m.path AS mount " +
+			"FROM disk_encryption d JOIN mounts m ON m.device = d.name;"},
+		{Platforms: []string{"windows"}, SQL: "SELECT device_id AS name, drive_letter AS mount, " +
+			"protection_status = 1 AS encrypted, encryption_method AS type FROM bitlocker_info;"},
+	},
+	// The update build revision, which moves with each Windows
+	// cumulative update, is only in the registry.
+	"os_version": {
+		{Platforms: []string{"windows"}, SQL: "SELECT o.name, o.version, o.build, o.platform, k.version AS kernel, " +
+			"(SELECT data FROM registry WHERE path = 'HKEY_LOCAL_MACHINE\\SOFTWARE\\Microsoft\\Windows NT\\CurrentVersion\\UBR') AS ubr " +
+			"FROM os_version o, kernel_info k;"},
+		{SQL: "SELECT o.name, o.version, o.build, o.platform, k.version AS kernel FROM os_version o, kernel_info k;"},
+	},
+	// osquery has no table for the Linux machine-id; the Windows one is
+	// in the registry.
+	"system_info": {
+		{Platforms: []string{"windows"}, SQL: "SELECT uuid, hardware_serial, hardware_vendor, hardware_model, " +
+			"(SELECT data FROM registry WHERE path = 'HKEY_LOCAL_MACHINE\\SOFTWARE\\Microsoft\\Cryptography\\MachineGuid') AS machine_id " +
+			"FROM system_info;"},
+		{SQL: "SELECT uuid, hardware_serial, hardware_vendor, hardware_model FROM system_info;"},
+	},
+	// RPM versions are version-release, as `rpm -qa` prints them, so
+	// package constraints compare revisions the same way on both paths.
+	// osquery 4.x has neither rpm_packages.epoch nor deb_packages.status;
+	// 5.x has both, and lists Debian packages removed with their config
+	// files left behind, which the status filter drops.
+	"packages": {
+		{Platforms: []string{"darwin"}, SQL: "SELECT name, version, 'homebrew' AS source, '' AS arch FROM homebrew_packages LIMIT %d;"},
+		{MinVersion: "5.0.0", Platforms: []string{"linux"}, SQL: "SELECT name, version, source, arch, sha1 FROM (" +
+			"SELECT name, version, 'deb' AS source, arch, '' AS sha1 FROM deb_packages WHERE status LIKE '%% installed' " +
+			"UNION ALL SELECT name, (CASE WHEN epoch > 0 THEN epoch || ':' ELSE '' END) || version || '-' || release AS version, " +
+			"'rpm' AS source, arch, sha1 FROM rpm_packages) LIMIT %d;"},
+		{Platforms: []string{"linux"}, SQL: "SELECT name, version, source, arch, sha1 FROM (" +
+			"SELECT name, version, 'deb' AS source, arch, '' AS sha1 FROM deb_packages " +
+			"UNION ALL SELECT name, version || '-' || release AS version, 'rpm' AS source, arch, sha1 FROM rpm_packages) LIMIT %d;"},
+		{Platforms: []string{"windows"}, SQL: "SELECT name, version, 'programs' AS source, '' AS arch FROM programs LIMIT %d;"},
+		{SQL: "SELECT name, version, source, arch FROM packages LIMIT %d;"},
+	},
+}
+
+// compatSQL picks the SQL for a logical query given the daemon info. An
+// empty info (version not yet known) matches only unconstrained entries.
+func compatSQL(name string, info OSQueryInfo) (string, error) {
+	for _, q := range compatQueries[name] {
+		if q.MinVersion != "" && (info.Version == "" || CompareVersions(info.Version, q.MinVersion) < 0) {
+			continue
+		}
+		if len(q.Platforms) > 0 && !contains(q.Platforms, info.BuildPlatform) {
+			continue
+		}
+		return q.SQL, nil
+	}
+	return "", fmt.Errorf("no %s query compatible with osquery %s on %s", name, info.Version, info.BuildPlatform)
+}
+
+// runCompat picks the SQL for a logical query and runs it through query,
+// so every osquery transport (local socket, Fleet) shares one table.
+func runCompat(ctx context.Context, query func(context.Context, string) ([]map[string]string, error), info OSQueryInfo, name string, limit int) ([]map[string]string, error) {
+	q, err := compatSQL(name, info)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(q, "%d") {
+		q = fmt.Sprintf(q, limit)
+	}
+	return query(ctx, q)
+}
+
+// CompareVersions compares dotted numeric versions, ignoring any
+// pre-release or build suffix ("5.10.2-3-gabcdef" compares as 5.10.2) and
+// a Debian epoch ("1:2.3.4ubuntu1" compares as 2.3.4).
+func CompareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(v string) []int {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.Index(v, ":"); i >= 0 {
+		v = v[i+1:]
+	}
+	if i := strings.IndexAny(v, "-+ ~"); i >= 0 {
+		v = v[:i]
+	}
+	var parts []int
+	for _, p := range strings.Split(v, ".") {
+		// A part with a trailing qualifier (4ubuntu1) ends the version.
+		digits := p
+		if i := strings.IndexFunc(p, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+			digits = p[:i]
+		}
+		n, err := strconv.Atoi(digits)
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+		if digits != p {
+			break
+		}
+	}
+	return parts
+}
+
+func contains(xs []string, s string) bool {
+	for _, x := range xs {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, 0, CompareVersions("5.10.2", "5.10.2"))
+	assert.Equal(t, 1, CompareVersions("5.10.2", "5.9"))
+	assert.Equal(t, -1, CompareVersions("4.9.0", "5.0.0"))
+	assert.Equal(t, 0, CompareVersions("5.10.2-3-gabcdef", "5.10.2"))
+	assert.Equal(t, 0, CompareVersions("1:2.3.4ubuntu1", "2.3.4"))
+	assert.Equal(t, -1, CompareVersions("7.10.17706.0", "7.11"))
+}
+
+func TestCompatSQL_PicksPlatformVariant(t *testing.T) {
+	sql, err := compatSQL("packages", OSQueryInfo{Version: "5.12.1", BuildPlatform: "linux"})
+	require.NoError(t, err)
+	assert.Contains(t, sql, "deb_packages")
+
+	sql, err = compatSQL("packages", OSQueryInfo{Version: "5.12.1", BuildPlatform: "darwin"})
+	require.NoError(t, err)
+	assert.Contains(t, sql, "homebrew_packages")
+}
+
+func TestCompatSQL_PicksPackagesByVersion(t *testing.T) {
+	var got string
+	query := func(_ context.Context, sql string) ([]map[string]string, error) {
+		got = sql
+		return nil, nil
+	}
+	_, err := runCompat(context.Background(), query, OSQueryInfo{Version: "5.12.1", BuildPlatform: "linux"}, "packages", 100)
+	require.NoError(t, err)
+	assert.Contains(t, got, "WHERE status LIKE '% installed'")
+	assert.Contains(t, got, "epoch || ':'")
+	assert.Contains(t, got, "LIMIT 100;")
+
+	_, err = runCompat(context.Background(), query, OSQueryInfo{Version: "4.9.0", BuildPlatform: "linux"}, "packages", 100)
+	require.NoError(t, err)
+	assert.NotContains(t, got, "status")
+	assert.NotContains(t, got, "epoch")
+	assert.Contains(t, got, "version || '-' || release")
+}
+
+func TestCompatSQL_RespectsMinVersion(t *testing.T) {
+	compatQueries["test"] = []compatQuery{
+		{MinVersion: "5.0.0", SQL: "new"},
+		{SQL: "old"},
+	}
+	defer delete(compatQueries, "test")
+
+	sql, _ := compatSQL("test", OSQueryInfo{Version: "5.2.0"})
+	assert.Equal(t, "new", sql)
+	sql, _ = compatSQL("test", OSQueryInfo{Version: "4.9.0"})
+	assert.Equal(t, "old", sql)
+	sql, _ = compatSQL("test", OSQueryInfo{})
+	assert.Equal(t, "old", sql, "unknown version only matches unconstrained entries")
+
+	_, err := compatSQL("missing", OSQueryInfo{})
+	assert.Error(t, err)
+}
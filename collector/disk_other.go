@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package collector
+
+import "context"
+
+// CollectDiskEncryption is not implemented on this platform.
+func CollectDiskEncryption(context.Context) ([]DiskVolume, error) { return nil, nil }
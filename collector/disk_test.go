@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLsblkVolumes(t *testing.T) {
+	out := []byte(`{"blockdevices": [
+	  {"name":"nvme0n1", "type":"disk", "fstype":null, "mountpoint":null, "children": [
+	    {"name":"nvme0n1p1", "type":"part", "fstype":"vfat", "mountpoint":"/boot/efi"},
+	    {"name":"nvme0n1p3", "type":"part", "fstype":"crypto_LUKS", "mountpoint":null, "children": [
+	      {"name":"dm_crypt-0", "type":"crypt", "fstype":"LVM2_member", "mountpoint":null, "children": [
+	        {"name":"ubuntu--vg-root", "type":"lvm", "fstype":"ext4", "mountpoint":"/"},
+	        {"name":"ubuntu--vg-swap", "type":"lvm", "fstype":"swap", "mountpoint":"[SWAP]"}
+	      ]}
+	    ]}
+	  ]},
+	  {"name":"sdb", "type":"disk", "fstype":"ext4", "mountpoint":"/data"}
+	]}`)
+	vols, err := parseLsblkVolumes(out)
+	require.NoError(t, err)
+	assert.Equal(t, []DiskVolume{
+		{Name: "/dev/nvme0n1p1", Mount: "/boot/efi"},
+		{Name: "/dev/ubuntu--vg-root", Mount: "/", Boot: true, Encrypted: true, Type: "LUKS"},
+		{Name: "/dev/sdb", Mount: "/data"},
+	}, vols)
+	assert.NoError(t, checkBootVolume(vols))
+	assert.ErrorIs(t, checkBootVolume(vols[2:]), errNoBootVolume)
+}
+
+func TestVolumesFromBitLocker(t *testing.T) {
+	rows, err := parsePowerShellJSON([]byte(`[{"MountPoint":"C:","VolumeType":0,"ProtectionStatus":1,"EncryptionMethod":7},{"MountPoint":"D:","VolumeType":1,"ProtectionStatus":0,"EncryptionMethod":0}]`))
+	require.NoError(t, err)
+	assert.Equal(t, []DiskVolume{
+		{Name: "C:", Mount: "C:", Boot: true, Encrypted: true, Type: "XtsAes256"},
+		{Name: "D:", Mount: "D:"},
+	}, volumesFromBitLocker(rows))
+}
+
+func TestVolumesFromRows_MacDataVolume(t *testing.T) {
+	vols := volumesFromRows([]map[string]string{
+		{"name": "/dev/disk3s1s1", "mount": "/", "encrypted": "0"},
+		{"name": "/dev/disk3s5", "mount": "/System/Volumes/Data", "encrypted": "1", "type": "APFS Encryption"},
+	})
+	require.Len(t, vols, 2)
+	assert.False(t, vols[0].Boot, "sealed system snapshot")
+	assert.Equal(t, DiskVolume{Name: "/dev/disk3s5", Mount: "/System/Volumes/Data", Boot: true, Encrypted: true, Type: "APFS Encryption"}, vols[1])
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package collector
+
+import "context"
+
+// CollectDiskEncryption reports BitLocker protection of each volume.
+func CollectDiskEncryption(ctx context.Context) ([]DiskVolume, error) {
+	rows, err := runPowerShellJSONContext(ctx, "Get-BitLockerVolume | Select-Object MountPoint,VolumeType,ProtectionStatus,EncryptionMethod | ConvertTo-Json -Compress")
+	if err != nil {
+		return nil, err
+	}
+	vols := volumesFromBitLocker(rows)
+	return vols, checkBootVolume(vols)
+}
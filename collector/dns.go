@@ -0,0 +1,198 @@
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DNSQuery is one recently resolved name. Samples come from the
+// resolver's cache or query log, so they show what was looked up, not
+// every lookup.
+type DNSQuery struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+	// Client is the querying address when the log records it.
+	Client string `json:"client,omitempty"`
+	// Count is how many times the name appeared in the sample.
+	Count  int    `json:"count"`
+	Source string `json:"source"`
+}
+
+// dnsLogTail is how much of the end of each resolver log is read.
+const dnsLogTail = 4 << 20
+
+// CollectDNSQueries samples recent lookups from the platform resolver
+// cache (Windows DNS client cache, systemd-resolved) and from the given
+// resolver query logs (dnsmasq, Unbound, BIND). Names are deduplicated
+// and at most limit are returned, most frequent first. An error is
+// returned only when no source could be read.
+func CollectDNSQueries(logPaths []string, limit int) ([]DNSQuery, error) {
+	seen := map[string]*DNSQuery{}
+	var order []string
+	add := func(name, qtype, client, source string) {
+		name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+		if name == "" || !strings.Contains(name, ".") {
+			return
+		}
+		if q, ok := seen[name]; ok {
+			q.Count++
+			return
+		}
+		seen[name] = &DNSQuery{Name: name, Type: qtype, Client: client, Count: 1, Source: source}
+		order = append(order, name)
+	}
+
+	var errs []error
+	read := 0
+	if cached, source, err := resolverCache(); err != nil {
+		errs = append(errs, err)
+	} else if source != "" {
+		read++
+		for _, q := range cached {
+			add(q.Name, q.Type, "", source)
+		}
+	}
+	for _, path := range logPaths {
+		lines, err := tailLines(path, dnsLogTail)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		read++
+		for _, line := range lines {
+			if name, qtype, client, ok := parseResolverLogLine(line); ok {
+				add(name, qtype, client, path)
+			}
+		}
+	}
+	if read == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	out := make([]DNSQuery, 0, len(order))
+	for _, name := range order {
+		out = append(out, *seen[name])
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Count > out[j].Count })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// parseResolvectlCache reads `resolvectl show-cache`: per-scope headers
+// followed by "name IN TYPE data" records.
+func parseResolvectlCache(out string) []DNSQuery {
+	var res []DNSQuery
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) >= 3 && f[1] == "IN" {
+			res = append(res, DNSQuery{Name: f[0], Type: f[2]})
+		}
+	}
+	return res
+}
+
+// parseResolverLogLine recognizes query lines from the common local
+// resolvers:
+//
+//	dnsmasq: ... dnsmasq[812]: query[A] example.com from 127.0.0.1
+//	Unbound: ... unbound[77:0] info: 127.0.0.1 example.com. A IN
+//	BIND:    ... client @0x7f 10.0.0.5#53124 (example.com): query: example.com IN A +E(0)
+func parseResolverLogLine(line string) (name, qtype, client string, ok bool) {
+	if i := strings.Index(line, " query["); i >= 0 {
+		rest := line[i+len(" query["):]
+		t, rest, found := strings.Cut(rest, "] ")
+		if !found {
+			return "", "", "", false
+		}
+		f := strings.Fields(rest)
+		if len(f) == 0 {
+			return "", "", "", false
+		}
+		if len(f) >= 3 && f[1] == "from" {
+			client = f[2]
+		}
+		return f[0], t, client, true
+	}
+	if i := strings.Index(line, " query: "); i >= 0 {
+		f := strings.Fields(line[i+len(" query: "):])
+		if len(f) < 3 {
+			return "", "", "", false
+		}
+		if j := strings.Index(line, "client "); j >= 0 {
+			for _, c := range strings.Fields(line[j+len("client "):]) {
+				if !strings.HasPrefix(c, "@") {
+					client, _, _ = strings.Cut(c, "#")
+					break
+				}
+			}
+		}
+		return f[0], f[2], client, true
+	}
+	if i := strings.Index(line, " info: "); i >= 0 {
+		f := strings.Fields(line[i+len(" info: "):])
+		if len(f) == 4 && f[3] == "IN" && strings.HasSuffix(f[1], ".") {
+			return f[1], f[2], f[0], true
+		}
+	}
+	return "", "", "", false
+}
+
+// dnsTypeName maps the numeric record types Get-DnsClientCache reports.
+func dnsTypeName(t string) string {
+	switch t {
+	case "1":
+		return "A"
+	case "5":
+		return "CNAME"
+	case "28":
+		return "AAAA"
+	case "12":
+		return "PTR"
+	case "15":
+		return "MX"
+	case "16":
+		return "TXT"
+	case "33":
+		return "SRV"
+	}
+	return t
+}
+
+// tailLines reads up to max bytes from the end of a file, dropping the
+// first partial line.
+func tailLines(path string, max int64) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	partial := false
+	if fi.Size() > max {
+		if _, err := f.Seek(-max, io.SeekEnd); err != nil {
+			return nil, err
+		}
+		partial = true
+	}
+	var lines []string
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if partial {
+			partial = false
+			continue
+		}
+		lines = append(lines, sc.Text())
+	}
+	return lines, sc.Err()
+}
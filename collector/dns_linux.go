@@ -0,0 +1,17 @@
+package collector
+
+import "os/exec"
+
+// resolverCache reads systemd-resolved's cache. source is "" when there
+// is none to read: no resolvectl, or one older than systemd 254, whose
+// show-cache just fails.
+func resolverCache() ([]DNSQuery, string, error) {
+	if _, err := exec.LookPath("resolvectl"); err != nil {
+		return nil, "", nil
+	}
+	out, err := exec.Command("resolvectl", "show-cache").Output()
+	if err != nil {
+		return nil, "", nil
+	}
+	return parseResolvectlCache(string(out)), "systemd-resolved cache", nil
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !windows
+
+package collector
+
+// resolverCache is not implemented on this platform; only resolver logs
+// are read.
+func resolverCache() ([]DNSQuery, string, error) { return nil, "", nil }
@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResolverLogLine(t *testing.T) {
+	cases := []struct {
+		line                string
+		name, qtype, client string
+	}{
+		{"Oct 17 04:10:01 host dnsmasq[812]: query[AAAA] Example.com from 127.0.0.1", "Example.com", "AAAA", "127.0.0.1"},
+		{"[1697515801] unbound[77:0] info: 10.0.0.5 kq3v9z7x1pj2m.net. A IN", "kq3v9z7x1pj2m.net.", "A", "10.0.0.5"},
+		{"17-Oct-2026 04:10:01.123 client @0x7f8a 10.0.0.5#53124 (example.org): query: example.org IN A +E(0)K (10.0.0.1)", "example.org", "A", "10.0.0.5"},
+	}
+	for _, c := range cases {
+		name, qtype, client, ok := parseResolverLogLine(c.line)
+		require.True(t, ok, c.line)
+		assert.Equal(t, []string{c.name, c.qtype, c.client}, []string{name, qtype, client})
+	}
+	for _, line := range []string{
+		"Oct 17 04:10:01 host dnsmasq[812]: reply example.com is 93.184.216.34",
+		"[1697515801] unbound[77:0] info: start of service",
+	} {
+		_, _, _, ok := parseResolverLogLine(line)
+		assert.False(t, ok, line)
+	}
+}
+
+func TestParseResolvectlCache(t *testing.T) {
+	out := "Scope protocol=dns interface=eth0:\nexample.com IN A 93.184.216.34\nexample.com IN AAAA 2606:2800::1\n\nScope protocol=mdns interface=eth0:\n"
+	assert.Equal(t, []DNSQuery{{Name: "example.com", Type: "A"}, {Name: "example.com", Type: "AAAA"}}, parseResolvectlCache(out))
+}
+
+func TestCollectDNSQueriesFromLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dnsmasq.log")
+	log := "dnsmasq[1]: query[A] a.example.com from 127.0.0.1\n" +
+		"dnsmasq[1]: query[A] b.example.com from 127.0.0.1\n" +
+		"dnsmasq[1]: query[AAAA] B.example.com. from 127.0.0.1\n" +
+		"dnsmasq[1]: query[A] localhost from 127.0.0.1\n"
+	require.NoError(t, os.WriteFile(path, []byte(log), 0o600))
+
+	got, err := CollectDNSQueries([]string{path}, 0)
+	require.NoError(t, err)
+	var fromLog []DNSQuery
+	for _, q := range got {
+		if q.Source == path {
+			fromLog = append(fromLog, q)
+		}
+	}
+	assert.Equal(t, []DNSQuery{
+		{Name: "b.example.com", Type: "A", Client: "127.0.0.1", Count: 2, Source: path},
+		{Name: "a.example.com", Type: "A", Client: "127.0.0.1", Count: 1, Source: path},
+	}, fromLog)
+}
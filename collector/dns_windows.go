@@ -0,0 +1,16 @@
+//go:build windows
+
+package collector
+
+// resolverCache reads the DNS client cache.
+func resolverCache() ([]DNSQuery, string, error) {
+	rows, err := runPowerShellJSON("Get-DnsClientCache | Select-Object Entry,Type | ConvertTo-Json -Compress")
+	if err != nil {
+		return nil, "", err
+	}
+	qs := make([]DNSQuery, 0, len(rows))
+	for _, r := range rows {
+		qs = append(qs, DNSQuery{Name: r["Entry"], Type: dnsTypeName(r["Type"])})
+	}
+	return qs, "dns client cache", nil
+}
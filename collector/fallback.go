@@ -1,13 +1,23 @@
 package collector
 
 import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
 	"os/exec"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
+
+	"compliance-agent/errcode"
 )
 
-// FallbackCollector provides basic system data collection without osquery
+// FallbackCollector provides basic system data collection without osquery.
+// Its Collect methods are implemented per platform in fallback_<os>.go;
+// the parsers they share live here so every platform's are tested on any
+// build host.
 type FallbackCollector struct{}
 
 // NewFallbackCollector creates a new fallback collector
@@ -15,185 +25,584 @@ func NewFallbackCollector() *FallbackCollector {
 	return &FallbackCollector{}
 }
 
-// CollectUsers returns basic user information using system commands
-func (f *FallbackCollector) CollectUsers() ([]map[string]string, error) {
-	var users []map[string]string
+// MinimalTier is the SupportTier of legacy UNIX hosts: Solaris, illumos
+// and AIX, where only users, processes, network sockets and packages
+// are collected and osquery doesn't run, so reports there are
+// reduced-fidelity.
+const MinimalTier = "minimal"
+
+// errNoPackageManager is a CollectPackages that found none of its
+// platform's package managers, so it can't say what is installed.
+var errNoPackageManager = errcode.Errorf(errcode.CollectorUnavailable, "no package manager found")
+
+// packagesResult is what a CollectPackages returns: the packages its
+// package manager queries found, and err if a query failed. A scan
+// cancelled or timed out meanwhile returns its own error instead, since
+// the queries fail then too. Either way the packages count as not
+// collected, so a failed query doesn't pass for a host that has none.
+func packagesResult(ctx context.Context, packages []Package, err error) ([]Package, error) {
+	if ctx.Err() != nil {
+		return packages, ctx.Err()
+	}
+	return packages, err
+}
+
+// packageQuery runs a package manager and returns its output, with the
+// command in the error.
+func packageQuery(ctx context.Context, name string, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return nil, errcode.Errorf(errcode.CollectorUnavailable, "%s: %w", strings.Join(append([]string{name}, args...), " "), err)
+	}
+	return out, nil
+}
 
-	switch runtime.GOOS {
-	case "darwin", "linux":
-		// Use getent or dscl on macOS
-		cmd := exec.Command("getent", "passwd")
-		if runtime.GOOS == "darwin" {
-			cmd = exec.Command("dscl", ".", "list", "/Users")
+// parsePasswd parses passwd(5) lines, as in /etc/passwd or from
+// `getent passwd`: username:x:uid:gid:description:home:shell.
+func parsePasswd(output string) []User {
+	var users []User
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
 		}
-		
-		output, err := cmd.Output()
-		if err != nil {
-			return users, err
+		parts := strings.Split(line, ":")
+		if len(parts) >= 7 {
+			users = append(users, User{
+				Username:    parts[0],
+				UID:         atoiOr(parts[2], -1),
+				GID:         atoiOr(parts[3], -1),
+				Description: parts[4],
+				Directory:   parts[5],
+				Shell:       parts[6],
+			})
 		}
+	}
+	return users
+}
 
-		lines := strings.Split(string(output), "\n")
-		for _, line := range lines {
-			if line == "" {
-				continue
-			}
-			
-			if runtime.GOOS == "darwin" {
-				// macOS dscl output
-				users = append(users, map[string]string{
-					"username": line,
-					"uid":      "0", // Placeholder
-					"gid":      "0", // Placeholder
-					"description": "User",
-					"directory": "/Users/" + line,
-					"shell":    "/bin/bash",
-				})
-			} else {
-				// Linux getent output: username:x:uid:gid:description:home:shell
-				parts := strings.Split(line, ":")
-				if len(parts) >= 7 {
-					users = append(users, map[string]string{
-						"username":    parts[0],
-						"uid":         parts[2],
-						"gid":         parts[3],
-						"description": parts[4],
-						"directory":   parts[5],
-						"shell":       parts[6],
-					})
-				}
-			}
+// parseMasterPasswd parses the BSD /etc/master.passwd, which has class,
+// change and expire fields between the GID and the description:
+// name:pw:uid:gid:class:change:expire:gecos:home:shell.
+func parseMasterPasswd(content string) []User {
+	var users []User
+	for _, line := range strings.Split(content, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Split(line, ":")
+		if len(parts) >= 10 {
+			users = append(users, User{
+				Username:    parts[0],
+				UID:         atoiOr(parts[2], -1),
+				GID:         atoiOr(parts[3], -1),
+				Description: parts[7],
+				Directory:   parts[8],
+				Shell:       parts[9],
+			})
+		}
+	}
+	return users
+}
+
+// parsePsAux parses BSD-style `ps aux`, which reports the owner by name,
+// not UID. BusyBox ps ignores the arguments and prints four columns, so
+// it parses to nothing.
+func parsePsAux(output string, limit int) []Process {
+	var processes []Process
+	for i, line := range strings.Split(output, "\n") {
+		if i == 0 || line == "" || len(processes) >= limit {
+			continue // Skip header
+		}
+		fields := strings.Fields(line)
+		if len(fields) >= 11 {
+			processes = append(processes, Process{
+				PID:     atoiOr(fields[1], -1),
+				Name:    fields[10],
+				Path:    fields[10],
+				Cmdline: strings.Join(fields[10:], " "),
+				UID:     -1,
+				User:    fields[0],
+			})
 		}
 	}
+	return processes
+}
+
+// bsdPsColumns and posixPsColumns ask ps for the columns parsePsColumns
+// reads: PID, UID, user, command name, then the full command line. The
+// trailing = on each drops the header line.
+const (
+	bsdPsColumns   = "pid=,uid=,user=,ucomm=,command="
+	posixPsColumns = "pid=,uid=,user=,comm=,args="
+)
 
-	return users, nil
+// parsePsColumns parses ps -o output in bsdPsColumns or posixPsColumns
+// order. ps has no executable path, so Path is the command's first word.
+func parsePsColumns(output string, limit int) []Process {
+	var processes []Process
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || len(processes) >= limit {
+			continue
+		}
+		processes = append(processes, Process{
+			PID:     atoiOr(fields[0], -1),
+			UID:     atoiOr(fields[1], -1),
+			User:    fields[2],
+			Name:    fields[3],
+			Path:    fields[4],
+			Cmdline: strings.Join(fields[4:], " "),
+		})
+	}
+	return processes
 }
 
-// CollectProcesses returns basic process information
-func (f *FallbackCollector) CollectProcesses(limit int) ([]map[string]string, error) {
-	var processes []map[string]string
+// parseDpkgList parses `dpkg -l`, keeping installed ("ii") packages.
+func parseDpkgList(output, arch string, limit int) []Package {
+	var packages []Package
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, "ii") || len(packages) >= limit {
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) >= 3 {
+			packages = append(packages, Package{Name: fields[1], Version: fields[2], Source: "dpkg", Arch: arch})
+		}
+	}
+	return packages
+}
+
+// rpmQueryFormat makes `rpm -qa` print tab-separated name, version, arch
+// and header digest for parseRPMList. SHA1HEADER is in every rpm still
+// in use; SHA256HEADER isn't in RHEL 7's, where the query would fail.
+const rpmQueryFormat = "%{NAME}\t%{VERSION}-%{RELEASE}\t%{ARCH}\t%{SHA1HEADER}\n"
 
-	switch runtime.GOOS {
-	case "darwin", "linux":
-		cmd := exec.Command("ps", "aux")
-		output, err := cmd.Output()
-		if err != nil {
-			return processes, err
+func parseRPMList(output string, limit int) []Package {
+	var packages []Package
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.Split(line, "\t")
+		if (len(parts) != 3 && len(parts) != 4) || len(packages) >= limit {
+			continue
+		}
+		p := Package{Name: parts[0], Version: parts[1], Source: "rpm", Arch: parts[2]}
+		if len(parts) == 4 && parts[3] != "(none)" {
+			p.SHA1 = parts[3]
 		}
+		packages = append(packages, p)
+	}
+	return packages
+}
 
-		lines := strings.Split(string(output), "\n")
-		count := 0
-		for i, line := range lines {
-			if i == 0 || line == "" || count >= limit {
-				continue // Skip header
+// apkInstalledDB is Alpine's installed-package database.
+const apkInstalledDB = "/lib/apk/db/installed"
+
+// parseApkInstalled parses Alpine's package database
+// (/lib/apk/db/installed): one stanza per package, with P:, V: and A:
+// lines for name, version and arch, and a C: checksum ("Q1" and the
+// base64 SHA-1 of the package's control data). Reading it directly works without
+// the apk tool, e.g. in a distroless agent container with the host's
+// root mounted.
+func parseApkInstalled(db string, limit int) []Package {
+	var packages []Package
+	var cur Package
+	flush := func() {
+		if cur.Name != "" && len(packages) < limit {
+			cur.Source = "apk"
+			packages = append(packages, cur)
+		}
+		cur = Package{}
+	}
+	for _, line := range strings.Split(db, "\n") {
+		if line == "" {
+			flush()
+			continue
+		}
+		if len(line) < 2 || line[1] != ':' {
+			continue
+		}
+		switch line[0] {
+		case 'P':
+			cur.Name = line[2:]
+		case 'V':
+			cur.Version = line[2:]
+		case 'A':
+			cur.Arch = line[2:]
+		case 'C':
+			if b64, ok := strings.CutPrefix(line[2:], "Q1"); ok {
+				if sum, err := base64.StdEncoding.DecodeString(b64); err == nil && len(sum) == sha1.Size {
+					cur.SHA1 = hex.EncodeToString(sum)
+				}
 			}
+		}
+	}
+	flush()
+	return packages
+}
 
-			fields := strings.Fields(line)
-			if len(fields) >= 11 {
-				processes = append(processes, map[string]string{
-					"pid":     fields[1],
-					"name":    fields[10],
-					"path":    fields[10],
-					"cmdline": strings.Join(fields[10:], " "),
-					"uid":     fields[0],
-				})
-				count++
+// parsePkgInfo parses `pkg info` (FreeBSD, source "pkg") or `pkg_info`
+// (OpenBSD, source "pkg_info"): a name-version package string, then its
+// comment. FreeBSD versions never contain a dash, so the last one splits;
+// OpenBSD appends flavors after the version (vim-9.0.1677-no_x11), so the
+// version starts at the first dash followed by a digit.
+func parsePkgInfo(output, source string, limit int) []Package {
+	var packages []Package
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || len(packages) >= limit {
+			continue
+		}
+		pkg := fields[0]
+		i := strings.LastIndex(pkg, "-")
+		if source == "pkg_info" {
+			i = -1
+			for j := 0; j < len(pkg)-1; j++ {
+				if pkg[j] == '-' && pkg[j+1] >= '0' && pkg[j+1] <= '9' {
+					i = j
+					break
+				}
 			}
 		}
+		if i <= 0 {
+			continue
+		}
+		packages = append(packages, Package{Name: pkg[:i], Version: pkg[i+1:], Source: source, Arch: runtime.GOARCH})
 	}
+	return packages
+}
+
+// parseIPSList parses Solaris/illumos IPS `pkg list -H`: FMRI stem,
+// version and IFO flags.
+func parseIPSList(output string, limit int) []Package {
+	var packages []Package
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(packages) >= limit {
+			continue
+		}
+		packages = append(packages, Package{Name: fields[0], Version: fields[1], Source: "ips", Arch: runtime.GOARCH})
+	}
+	return packages
+}
 
-	return processes, nil
+// parseLslpp parses AIX `lslpp -Lc`: colon-separated package, fileset
+// and level first, after a # header.
+func parseLslpp(output string, limit int) []Package {
+	var packages []Package
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.Split(line, ":")
+		if len(parts) < 3 || strings.HasPrefix(line, "#") || len(packages) >= limit {
+			continue
+		}
+		packages = append(packages, Package{Name: parts[1], Version: parts[2], Source: "lslpp", Arch: runtime.GOARCH})
+	}
+	return packages
 }
 
-// CollectOpenPorts returns listening ports using netstat
-func (f *FallbackCollector) CollectOpenPorts() ([]int, error) {
-	var ports []int
+var ssUsersRE = regexp.MustCompile(`users:\(\("((?:[^"\\]|\\.)*)",pid=(\d+)`)
 
-	switch runtime.GOOS {
-	case "darwin", "linux":
-		cmd := exec.Command("netstat", "-tuln")
-		output, err := cmd.Output()
-		if err != nil {
-			return ports, err
+// parseSSListeners parses `ss -tulpnH`: netid, state, queues, local and
+// peer address, then users:(("name",pid=N,fd=N),...) when the owner is
+// visible.
+func parseSSListeners(output string) []PortBinding {
+	var ports []PortBinding
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || (fields[0] != "tcp" && fields[0] != "udp") {
+			continue
+		}
+		addr, port := splitHostPort(fields[4], ":")
+		if port <= 0 {
+			continue
 		}
+		b := PortBinding{Port: port, Protocol: fields[0], Address: cleanListenAddress(addr), PID: -1}
+		if m := ssUsersRE.FindStringSubmatch(line); m != nil {
+			b.Process = m[1]
+			b.PID = atoiOr(m[2], -1)
+		}
+		ports = append(ports, b)
+	}
+	return ports
+}
 
-		lines := strings.Split(string(output), "\n")
-		for _, line := range lines {
-			if strings.Contains(line, "LISTEN") || strings.Contains(line, "tcp") {
-				fields := strings.Fields(line)
-				if len(fields) >= 4 {
-					// Extract port from address:port format
-					addr := fields[3]
-					if strings.Contains(addr, ":") {
-						parts := strings.Split(addr, ":")
-						if len(parts) > 1 {
-							portStr := parts[len(parts)-1]
-							if port, err := strconv.Atoi(portStr); err == nil && port > 0 {
-								ports = append(ports, port)
-							}
-						}
+// parseLsofListeners parses `lsof -nP -iTCP -sTCP:LISTEN -iUDP`. A socket
+// shared by several descriptors of one process is reported once, and
+// connected UDP sockets are skipped.
+func parseLsofListeners(output string) []PortBinding {
+	type key struct {
+		proto, addr string
+		port, pid   int
+	}
+	seen := map[key]bool{}
+	var ports []PortBinding
+	for _, line := range strings.Split(output, "\n") {
+		// COMMAND PID USER FD TYPE DEVICE SIZE/OFF NODE NAME [(STATE)]
+		fields := strings.Fields(line)
+		if len(fields) < 9 || fields[0] == "COMMAND" || strings.Contains(fields[8], "->") {
+			continue
+		}
+		proto := strings.ToLower(fields[7])
+		if proto != "tcp" && proto != "udp" {
+			continue
+		}
+		addr, port := splitHostPort(fields[8], ":")
+		if port <= 0 {
+			continue
+		}
+		b := PortBinding{
+			Port:     port,
+			Protocol: proto,
+			Address:  cleanListenAddress(addr),
+			PID:      atoiOr(fields[1], -1),
+			Process:  strings.ReplaceAll(fields[0], `\x20`, " "),
+		}
+		k := key{b.Protocol, b.Address, b.Port, b.PID}
+		if !seen[k] {
+			seen[k] = true
+			ports = append(ports, b)
+		}
+	}
+	return ports
+}
+
+// parseNetstatListeners parses `netstat -tuln`, which has no owner
+// column.
+func parseNetstatListeners(output string) []PortBinding {
+	var ports []PortBinding
+	for _, line := range strings.Split(output, "\n") {
+		if strings.Contains(line, "LISTEN") || strings.Contains(line, "tcp") {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 {
+				// Extract port from address:port format
+				addr := fields[3]
+				if i := strings.LastIndex(addr, ":"); i >= 0 {
+					if port, err := strconv.Atoi(addr[i+1:]); err == nil && port > 0 {
+						ports = append(ports, PortBinding{
+							Port:     port,
+							Protocol: strings.TrimSuffix(fields[0], "6"),
+							Address:  addr[:i],
+							PID:      -1,
+						})
 					}
 				}
 			}
 		}
 	}
+	return ports
+}
 
-	return ports, nil
+// sockstatSocket is one row of FreeBSD sockstat: USER COMMAND PID FD
+// PROTO LOCAL FOREIGN. Kernel-owned sockets show ? for the owner.
+type sockstatSocket struct {
+	proto, process string
+	pid            int
+	local, foreign string
 }
 
-// CollectPackages returns basic package information
-func (f *FallbackCollector) CollectPackages(limit int) ([]map[string]string, error) {
-	var packages []map[string]string
+func parseSockstat(output string) []sockstatSocket {
+	var socks []sockstatSocket
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 7 || fields[0] == "USER" {
+			continue
+		}
+		// tcp4, tcp6 and tcp46 (a dual-stack socket) are all tcp.
+		proto := strings.TrimRight(fields[4], "46")
+		if proto != "tcp" && proto != "udp" {
+			continue
+		}
+		s := sockstatSocket{proto: proto, pid: atoiOr(fields[2], -1), local: fields[5], foreign: fields[6]}
+		if fields[1] != "?" {
+			s.process = fields[1]
+		}
+		socks = append(socks, s)
+	}
+	return socks
+}
 
-	switch runtime.GOOS {
-	case "darwin":
-		// Try Homebrew
-		if _, err := exec.LookPath("brew"); err == nil {
-			cmd := exec.Command("brew", "list", "--formula")
-			output, err := cmd.Output()
-			if err == nil {
-				lines := strings.Split(string(output), "\n")
-				count := 0
-				for _, line := range lines {
-					if line == "" || count >= limit {
-						continue
-					}
-					packages = append(packages, map[string]string{
-						"name":    line,
-						"version": "unknown",
-						"source":  "homebrew",
-						"arch":    runtime.GOARCH,
-					})
-					count++
-				}
-			}
+// parseSockstatListeners parses `sockstat -46l`. A socket shared by
+// several descriptors of one process is reported once.
+func parseSockstatListeners(output string) []PortBinding {
+	type key struct {
+		proto, addr string
+		port, pid   int
+	}
+	seen := map[key]bool{}
+	var ports []PortBinding
+	for _, s := range parseSockstat(output) {
+		addr, port := splitHostPort(s.local, ":")
+		if port <= 0 {
+			continue
 		}
-	case "linux":
-		// Try dpkg (Debian/Ubuntu)
-		if _, err := exec.LookPath("dpkg"); err == nil {
-			cmd := exec.Command("dpkg", "-l")
-			output, err := cmd.Output()
-			if err == nil {
-				lines := strings.Split(string(output), "\n")
-				count := 0
-				for _, line := range lines {
-					if strings.HasPrefix(line, "ii") && count < limit {
-						fields := strings.Fields(line)
-						if len(fields) >= 3 {
-							packages = append(packages, map[string]string{
-								"name":    fields[1],
-								"version": fields[2],
-								"source":  "dpkg",
-								"arch":    runtime.GOARCH,
-							})
-							count++
-						}
-					}
-				}
+		b := PortBinding{Port: port, Protocol: s.proto, Address: cleanListenAddress(addr), PID: s.pid, Process: s.process}
+		k := key{b.Protocol, b.Address, b.Port, b.PID}
+		if !seen[k] {
+			seen[k] = true
+			ports = append(ports, b)
+		}
+	}
+	return ports
+}
+
+// parseSockstatConnections parses `sockstat -46c -P tcp`, which lists
+// connected sockets with their owners.
+func parseSockstatConnections(output string) []Connection {
+	var conns []Connection
+	for _, s := range parseSockstat(output) {
+		local, lport := splitHostPort(s.local, ":")
+		remote, rport := splitHostPort(s.foreign, ":")
+		c := Connection{
+			PID:           s.pid,
+			Process:       s.process,
+			Protocol:      s.proto,
+			LocalAddress:  cleanListenAddress(local),
+			LocalPort:     lport,
+			RemoteAddress: cleanListenAddress(remote),
+			RemotePort:    rport,
+		}
+		if c.Protocol == "tcp" && remoteConnection(c) {
+			conns = append(conns, c)
+		}
+	}
+	return conns
+}
+
+// parseBSDNetstatListeners parses `netstat -an` on the BSDs: listening
+// TCP sockets, and UDP sockets with no foreign address. Addresses end in
+// .port, and unix-domain rows are skipped.
+func parseBSDNetstatListeners(output string) []PortBinding {
+	var ports []PortBinding
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		proto := strings.TrimRight(fields[0], "46")
+		switch {
+		case proto == "tcp" && len(fields) >= 6 && fields[5] == "LISTEN":
+		case proto == "udp" && fields[4] == "*.*":
+		default:
+			continue
+		}
+		addr, port := splitHostPort(fields[3], ".")
+		if port <= 0 {
+			continue
+		}
+		ports = append(ports, PortBinding{Port: port, Protocol: proto, Address: cleanListenAddress(addr), PID: -1})
+	}
+	return ports
+}
+
+// parseSolarisNetstat parses Solaris/illumos `netstat -an`, which splits
+// sockets into "TCP: IPv4", "UDP: IPv6", ... sections with no protocol
+// column. TCP rows are local, remote, four queue columns, then state;
+// a bound UDP socket is local then Idle. Addresses end in .port.
+func parseSolarisNetstat(output string) ([]PortBinding, []Connection) {
+	var ports []PortBinding
+	var conns []Connection
+	proto := ""
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.HasSuffix(fields[0], ":") && strings.HasPrefix(fields[1], "IPv") {
+			proto = strings.ToLower(strings.TrimSuffix(fields[0], ":"))
+			continue
+		}
+		if strings.HasPrefix(line, "Active") {
+			proto = "" // UNIX domain sockets
+			continue
+		}
+		if len(fields) < 2 {
+			continue
+		}
+		addr, port := splitHostPort(fields[0], ".")
+		if port <= 0 {
+			continue
+		}
+		switch {
+		case proto == "tcp" && len(fields) >= 7 && fields[6] == "LISTEN",
+			proto == "udp" && fields[1] == "Idle":
+			ports = append(ports, PortBinding{Port: port, Protocol: proto, Address: cleanListenAddress(addr), PID: -1})
+		case proto == "tcp" && len(fields) >= 7 && fields[6] == "ESTABLISHED":
+			remote, rport := splitHostPort(fields[1], ".")
+			c := Connection{PID: -1, Protocol: "tcp", LocalAddress: addr, LocalPort: port, RemoteAddress: remote, RemotePort: rport}
+			if remoteConnection(c) {
+				conns = append(conns, c)
 			}
 		}
 	}
+	return ports, conns
+}
+
+// cleanListenAddress normalizes the address forms ss and lsof print:
+// brackets around IPv6, a %interface scope, and * for any address.
+func cleanListenAddress(addr string) string {
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	if i := strings.Index(addr, "%"); i >= 0 {
+		addr = addr[:i]
+	}
+	if addr == "*" {
+		return "0.0.0.0"
+	}
+	return addr
+}
+
+// limitConnections caps conns at limit, 1000 when limit isn't positive.
+func limitConnections(conns []Connection, limit int) []Connection {
+	if limit <= 0 {
+		limit = 1000
+	}
+	if len(conns) > limit {
+		conns = conns[:limit]
+	}
+	return conns
+}
+
+func parseNetstatConnections(output, sep string) []Connection {
+	var conns []Connection
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 || fields[5] != "ESTABLISHED" || !strings.HasPrefix(fields[0], "tcp") {
+			continue
+		}
+		local, lport := splitHostPort(fields[3], sep)
+		remote, rport := splitHostPort(fields[4], sep)
+		c := Connection{
+			PID:           -1,
+			Protocol:      "tcp",
+			LocalAddress:  local,
+			LocalPort:     lport,
+			RemoteAddress: remote,
+			RemotePort:    rport,
+		}
+		if remoteConnection(c) {
+			conns = append(conns, c)
+		}
+	}
+	return conns
+}
+
+func splitHostPort(addr, sep string) (string, int) {
+	i := strings.LastIndex(addr, sep)
+	if i < 0 {
+		return addr, 0
+	}
+	return addr[:i], atoiOr(addr[i+1:], 0)
+}
+
+// CollectDiskEncryption reports volume encryption with the platform's
+// own tools (see CollectDiskEncryption).
+func (f *FallbackCollector) CollectDiskEncryption(ctx context.Context) ([]DiskVolume, error) {
+	return CollectDiskEncryption(ctx)
+}
+
+// CollectOSVersion reads the OS release with the platform's own tools
+// (see CollectOSVersion).
+func (f *FallbackCollector) CollectOSVersion(ctx context.Context) (*OSVersion, error) {
+	return CollectOSVersion(ctx)
+}
 
-	return packages, nil
+// CollectHardware reads the machine's identifiers with the platform's own
+// tools (see CollectHardware).
+func (f *FallbackCollector) CollectHardware(ctx context.Context) (*HardwareInfo, error) {
+	return CollectHardware(ctx)
 }
 
 // HealthCheck always returns nil for fallback collector
@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"context"
+	"os/exec"
+)
+
+// CollectOpenPorts returns listening ports from netstat, whose AIX output
+// matches the BSDs'. It doesn't attribute them to processes.
+func (f *FallbackCollector) CollectOpenPorts(ctx context.Context) ([]PortBinding, error) {
+	output, err := exec.CommandContext(ctx, "netstat", "-an").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseBSDNetstatListeners(string(output)), nil
+}
+
+// CollectConnections returns established TCP connections from netstat.
+func (f *FallbackCollector) CollectConnections(ctx context.Context, limit int) ([]Connection, error) {
+	output, err := exec.CommandContext(ctx, "netstat", "-an", "-f", "inet").Output()
+	if err != nil {
+		return nil, err
+	}
+	return limitConnections(parseNetstatConnections(string(output), "."), limit), nil
+}
+
+// CollectPackages returns installp filesets from lslpp, plus RPMs from
+// the AIX Toolbox when rpm is installed.
+func (f *FallbackCollector) CollectPackages(ctx context.Context, limit int) ([]Package, error) {
+	var packages []Package
+	output, err := packageQuery(ctx, "lslpp", "-Lc")
+	if err == nil {
+		packages = parseLslpp(string(output), limit)
+	}
+	if _, lerr := exec.LookPath("rpm"); lerr == nil && err == nil && len(packages) < limit {
+		// The Toolbox is optional: a failing rpm leaves lslpp's filesets.
+		if output, rerr := packageQuery(ctx, "rpm", "-qa", "--qf", rpmQueryFormat); rerr == nil {
+			packages = append(packages, parseRPMList(string(output), limit-len(packages))...)
+		}
+	}
+	return packagesResult(ctx, packages, err)
+}
@@ -0,0 +1,95 @@
+//go:build freebsd || openbsd
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+)
+
+// BSD fallback collection uses the base system's tools: sockstat on
+// FreeBSD, netstat where it's missing (OpenBSD), and pkg or pkg_info for
+// packages.
+
+// CollectUsers returns accounts from /etc/master.passwd, which only root
+// can read, or the world-readable /etc/passwd generated from it.
+func (f *FallbackCollector) CollectUsers(ctx context.Context) ([]User, error) {
+	if b, err := os.ReadFile("/etc/master.passwd"); err == nil {
+		return parseMasterPasswd(string(b)), nil
+	} else if !errors.Is(err, fs.ErrPermission) && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	b, err := os.ReadFile("/etc/passwd")
+	if err != nil {
+		return nil, err
+	}
+	return parsePasswd(string(b)), nil
+}
+
+// CollectProcesses returns processes from ps, asking for explicit
+// columns so UIDs are reported too.
+func (f *FallbackCollector) CollectProcesses(ctx context.Context, limit int) ([]Process, error) {
+	output, err := exec.CommandContext(ctx, "ps", "-axww", "-o", bsdPsColumns).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parsePsColumns(string(output), limit), nil
+}
+
+// CollectOpenPorts returns listening ports from sockstat, which
+// attributes them to processes, or netstat without it. Without root,
+// sockstat only sees the agent user's own processes.
+func (f *FallbackCollector) CollectOpenPorts(ctx context.Context) ([]PortBinding, error) {
+	if _, err := exec.LookPath("sockstat"); err == nil {
+		output, err := exec.CommandContext(ctx, "sockstat", "-46l").Output()
+		if err != nil {
+			return nil, err
+		}
+		return parseSockstatListeners(string(output)), nil
+	}
+	output, err := exec.CommandContext(ctx, "netstat", "-an").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseBSDNetstatListeners(string(output)), nil
+}
+
+// CollectConnections returns established TCP connections from sockstat,
+// or netstat (no process attribution) without it.
+func (f *FallbackCollector) CollectConnections(ctx context.Context, limit int) ([]Connection, error) {
+	if _, err := exec.LookPath("sockstat"); err == nil {
+		output, err := exec.CommandContext(ctx, "sockstat", "-46c", "-P", "tcp").Output()
+		if err != nil {
+			return nil, err
+		}
+		return limitConnections(parseSockstatConnections(string(output)), limit), nil
+	}
+	output, err := exec.CommandContext(ctx, "netstat", "-an", "-p", "tcp").Output()
+	if err != nil {
+		return nil, err
+	}
+	// BSD netstat separates the port with a dot: 10.0.0.5.51234.
+	return limitConnections(parseNetstatConnections(string(output), "."), limit), nil
+}
+
+// CollectPackages returns packages from pkg (FreeBSD) or pkg_info
+// (OpenBSD). A missing or failing package tool leaves the list empty.
+func (f *FallbackCollector) CollectPackages(ctx context.Context, limit int) ([]Package, error) {
+	var packages []Package
+	err := errNoPackageManager
+	if _, lerr := exec.LookPath("pkg"); lerr == nil {
+		var output []byte
+		if output, err = packageQuery(ctx, "pkg", "info"); err == nil {
+			packages = parsePkgInfo(string(output), "pkg", limit)
+		}
+	} else if _, lerr := exec.LookPath("pkg_info"); lerr == nil {
+		var output []byte
+		if output, err = packageQuery(ctx, "pkg_info"); err == nil {
+			packages = parsePkgInfo(string(output), "pkg_info", limit)
+		}
+	}
+	return packagesResult(ctx, packages, err)
+}
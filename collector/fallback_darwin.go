@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// CollectUsers lists local accounts with dscl, which doesn't report IDs;
+// -1 marks them unknown.
+func (f *FallbackCollector) CollectUsers(ctx context.Context) ([]User, error) {
+	output, err := exec.CommandContext(ctx, "dscl", ".", "list", "/Users").Output()
+	if err != nil {
+		return nil, err
+	}
+	var users []User
+	for _, line := range strings.Split(string(output), "\n") {
+		if line == "" {
+			continue
+		}
+		users = append(users, User{
+			Username:    line,
+			UID:         -1,
+			GID:         -1,
+			Description: "User",
+			Directory:   "/Users/" + line,
+			Shell:       "/bin/bash",
+		})
+	}
+	return users, nil
+}
+
+// CollectProcesses returns processes from ps aux.
+func (f *FallbackCollector) CollectProcesses(ctx context.Context, limit int) ([]Process, error) {
+	output, err := exec.CommandContext(ctx, "ps", "aux").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parsePsAux(string(output), limit), nil
+}
+
+// CollectOpenPorts returns listening ports and their owning processes
+// from lsof, or netstat (no process attribution) without it. Without
+// root, lsof only sees the agent user's own processes.
+func (f *FallbackCollector) CollectOpenPorts(ctx context.Context) ([]PortBinding, error) {
+	if _, err := exec.LookPath("lsof"); err == nil {
+		// lsof exits 1 when nothing matched, with valid empty output.
+		output, err := exec.CommandContext(ctx, "lsof", "-nP", "-iTCP", "-sTCP:LISTEN", "-iUDP").Output()
+		var exitErr *exec.ExitError
+		if err != nil && !(errors.As(err, &exitErr) && len(output) == 0) {
+			return nil, err
+		}
+		return parseLsofListeners(string(output)), nil
+	}
+	output, err := exec.CommandContext(ctx, "netstat", "-tuln").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseNetstatListeners(string(output)), nil
+}
+
+// CollectConnections returns established TCP connections using netstat,
+// which doesn't report the owning process.
+func (f *FallbackCollector) CollectConnections(ctx context.Context, limit int) ([]Connection, error) {
+	output, err := exec.CommandContext(ctx, "netstat", "-tn").Output()
+	if err != nil {
+		return nil, err
+	}
+	// macOS separates the port with a dot: 10.0.0.5.51234.
+	return limitConnections(parseNetstatConnections(string(output), "."), limit), nil
+}
+
+// CollectPackages returns Homebrew formulae. A missing or failing brew
+// leaves the list empty.
+func (f *FallbackCollector) CollectPackages(ctx context.Context, limit int) ([]Package, error) {
+	var packages []Package
+	err := errNoPackageManager
+	if _, lerr := exec.LookPath("brew"); lerr == nil {
+		var output []byte
+		if output, err = packageQuery(ctx, "brew", "list", "--formula"); err == nil {
+			for _, line := range strings.Split(string(output), "\n") {
+				if line == "" || len(packages) >= limit {
+					continue
+				}
+				packages = append(packages, Package{
+					Name:    line,
+					Version: "unknown",
+					Source:  "homebrew",
+					Arch:    runtime.GOARCH,
+				})
+			}
+		}
+	}
+	return packagesResult(ctx, packages, err)
+}
@@ -0,0 +1,34 @@
+//go:build solaris || aix
+
+package collector
+
+import (
+	"context"
+	"os"
+	"os/exec"
+)
+
+// Solaris, illumos and AIX get a minimal tier (see SupportTier): users,
+// processes, listening ports, connections and packages from base-system
+// tools. Everything else is left empty.
+
+// SupportTier returns MinimalTier.
+func SupportTier() string { return MinimalTier }
+
+// CollectUsers returns accounts from /etc/passwd.
+func (f *FallbackCollector) CollectUsers(ctx context.Context) ([]User, error) {
+	b, err := os.ReadFile("/etc/passwd")
+	if err != nil {
+		return nil, err
+	}
+	return parsePasswd(string(b)), nil
+}
+
+// CollectProcesses returns processes from POSIX ps -eo.
+func (f *FallbackCollector) CollectProcesses(ctx context.Context, limit int) ([]Process, error) {
+	output, err := exec.CommandContext(ctx, "ps", "-eo", posixPsColumns).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parsePsColumns(string(output), limit), nil
+}
@@ -0,0 +1,169 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"compliance-agent/errcode"
+)
+
+// Linux fallback collection prefers the usual tools and falls back to
+// reading /proc and /etc directly, so BusyBox/musl hosts (Alpine,
+// OpenWrt, Raspberry Pi minimal images) and distroless containers still
+// get data.
+
+// CollectUsers returns accounts from `getent passwd`, which includes
+// directory (LDAP, SSSD) users, or /etc/passwd where getent is missing,
+// as on musl.
+func (f *FallbackCollector) CollectUsers(ctx context.Context) ([]User, error) {
+	if _, err := exec.LookPath("getent"); err == nil {
+		output, err := exec.CommandContext(ctx, "getent", "passwd").Output()
+		if err != nil {
+			return nil, err
+		}
+		return parsePasswd(string(output)), nil
+	}
+	b, err := os.ReadFile("/etc/passwd")
+	if err != nil {
+		return nil, err
+	}
+	return parsePasswd(string(b)), nil
+}
+
+// CollectProcesses returns processes from `ps aux`, or from /proc when ps
+// is missing or is BusyBox's, whose output has no owner or command line
+// columns.
+func (f *FallbackCollector) CollectProcesses(ctx context.Context, limit int) ([]Process, error) {
+	if output, err := exec.CommandContext(ctx, "ps", "aux").Output(); err == nil {
+		if procs := parsePsAux(string(output), limit); len(procs) > 0 {
+			return procs, nil
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return readProcProcesses(limit)
+}
+
+// readProcProcesses walks /proc. The executable path needs root for
+// other users' processes; without it Path is left empty.
+func readProcProcesses(limit int) ([]Process, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var procs []Process
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || len(procs) >= limit {
+			continue
+		}
+		dir := filepath.Join("/proc", e.Name())
+		comm, err := os.ReadFile(filepath.Join(dir, "comm"))
+		if err != nil {
+			continue // exited
+		}
+		p := Process{PID: pid, Name: strings.TrimSpace(string(comm)), UID: -1}
+		if status, err := os.ReadFile(filepath.Join(dir, "status")); err == nil {
+			p.UID = parseProcStatusUID(string(status))
+		}
+		if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+			p.Cmdline = strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+		}
+		p.Path, _ = os.Readlink(filepath.Join(dir, "exe"))
+		procs = append(procs, p)
+	}
+	return procs, nil
+}
+
+// CollectOpenPorts returns listening ports and their owning processes
+// from ss, else netstat, else /proc/net. Only ss attributes ports to
+// processes; without root it only sees the agent user's own, and other
+// listeners get PID -1.
+func (f *FallbackCollector) CollectOpenPorts(ctx context.Context) ([]PortBinding, error) {
+	if _, err := exec.LookPath("ss"); err == nil {
+		output, err := exec.CommandContext(ctx, "ss", "-tulpnH").Output()
+		if err != nil {
+			return nil, err
+		}
+		return parseSSListeners(string(output)), nil
+	}
+	if _, err := exec.LookPath("netstat"); err == nil {
+		output, err := exec.CommandContext(ctx, "netstat", "-tuln").Output()
+		if err != nil {
+			return nil, err
+		}
+		return parseNetstatListeners(string(output)), nil
+	}
+	var ports []PortBinding
+	for _, proto := range []string{"tcp", "udp"} {
+		socks, err := readProcNet(proto)
+		if err != nil {
+			return nil, err
+		}
+		ports = append(ports, procNetListeners(socks, proto)...)
+	}
+	return ports, nil
+}
+
+// CollectConnections returns established TCP connections from netstat,
+// or /proc/net/tcp where netstat is missing. Neither reports the owning
+// process.
+func (f *FallbackCollector) CollectConnections(ctx context.Context, limit int) ([]Connection, error) {
+	if _, err := exec.LookPath("netstat"); err == nil {
+		output, err := exec.CommandContext(ctx, "netstat", "-tn").Output()
+		if err != nil {
+			return nil, err
+		}
+		return limitConnections(parseNetstatConnections(string(output), ":"), limit), nil
+	}
+	socks, err := readProcNet("tcp")
+	if err != nil {
+		return nil, err
+	}
+	return limitConnections(procNetConnections(socks), limit), nil
+}
+
+// readProcNet reads the IPv4 and IPv6 tables for proto. A missing IPv6
+// table (IPv6 disabled) is not an error.
+func readProcNet(proto string) ([]procNetSocket, error) {
+	b, err := os.ReadFile("/proc/net/" + proto)
+	if err != nil {
+		return nil, err
+	}
+	socks := parseProcNet(string(b))
+	if b, err := os.ReadFile("/proc/net/" + proto + "6"); err == nil {
+		socks = append(socks, parseProcNet(string(b))...)
+	}
+	return socks, nil
+}
+
+// CollectPackages returns packages from dpkg, rpm or Alpine's apk
+// database, whichever the host has. A failing package manager leaves the
+// list empty.
+func (f *FallbackCollector) CollectPackages(ctx context.Context, limit int) ([]Package, error) {
+	var packages []Package
+	err := errNoPackageManager
+	if _, lerr := exec.LookPath("dpkg"); lerr == nil {
+		var output []byte
+		if output, err = packageQuery(ctx, "dpkg", "-l"); err == nil {
+			packages = parseDpkgList(string(output), runtime.GOARCH, limit)
+		}
+	} else if _, lerr := exec.LookPath("rpm"); lerr == nil {
+		var output []byte
+		if output, err = packageQuery(ctx, "rpm", "-qa", "--qf", rpmQueryFormat); err == nil {
+			packages = parseRPMList(string(output), limit)
+		}
+	} else if b, rerr := os.ReadFile(apkInstalledDB); rerr == nil {
+		packages, err = parseApkInstalled(string(b), limit), nil
+	} else if !errors.Is(rerr, os.ErrNotExist) {
+		err = errcode.New(errcode.CollectorUnavailable, rerr)
+	}
+	return packagesResult(ctx, packages, err)
+}
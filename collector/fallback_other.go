@@ -0,0 +1,32 @@
+//go:build !linux && !darwin && !windows && !freebsd && !openbsd && !solaris && !aix
+
+package collector
+
+import "context"
+
+// Platforms without a native fallback report nothing rather than fail:
+// osquery, where it runs, still covers them.
+
+// CollectUsers is not implemented on this platform.
+func (f *FallbackCollector) CollectUsers(context.Context) ([]User, error) { return nil, nil }
+
+// CollectProcesses is not implemented on this platform.
+func (f *FallbackCollector) CollectProcesses(context.Context, int) ([]Process, error) {
+	return nil, nil
+}
+
+// CollectOpenPorts is not implemented on this platform.
+func (f *FallbackCollector) CollectOpenPorts(context.Context) ([]PortBinding, error) {
+	return nil, nil
+}
+
+// CollectConnections is not implemented on this platform.
+func (f *FallbackCollector) CollectConnections(context.Context, int) ([]Connection, error) {
+	return nil, nil
+}
+
+// CollectPackages is not implemented on this platform. It fails rather
+// than report nothing, so required packages aren't all reported missing.
+func (f *FallbackCollector) CollectPackages(context.Context, int) ([]Package, error) {
+	return nil, errNoPackageManager
+}
@@ -0,0 +1,187 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Windows fallback collection via PowerShell cmdlets and WMI/CIM. Every
+// script ends in ConvertTo-Json so we parse structured output rather than
+// scraping console tables.
+
+// runPowerShellJSON runs a script and decodes its ConvertTo-Json output.
+func runPowerShellJSON(script string) ([]map[string]string, error) {
+	return runPowerShellJSONContext(context.Background(), script)
+}
+
+// runPowerShellJSONContext is runPowerShellJSON, killing PowerShell when
+// ctx is done.
+func runPowerShellJSONContext(ctx context.Context, script string) ([]map[string]string, error) {
+	cmd := exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-Command", script)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("powershell: %w", err)
+	}
+	return parsePowerShellJSON(out)
+}
+
+// parsePowerShellJSON flattens ConvertTo-Json output into string maps.
+// PowerShell emits a bare object (not an array) when there's exactly one
+// result, and nothing at all when there are none.
+func parsePowerShellJSON(b []byte) ([]map[string]string, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil, nil
+	}
+	var raw []map[string]any
+	if b[0] == '{' {
+		var one map[string]any
+		if err := json.Unmarshal(b, &one); err != nil {
+			return nil, fmt.Errorf("parse powershell json: %w", err)
+		}
+		raw = []map[string]any{one}
+	} else if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("parse powershell json: %w", err)
+	}
+	rows := make([]map[string]string, 0, len(raw))
+	for _, r := range raw {
+		row := make(map[string]string, len(r))
+		for k, v := range r {
+			row[k] = psString(v)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func psString(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	default:
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+}
+
+func collectUsersWindows(ctx context.Context) ([]User, error) {
+	rows, err := runPowerShellJSONContext(ctx, "Get-LocalUser | Select-Object Name,@{n='SID';e={$_.SID.Value}},Description,Enabled | ConvertTo-Json -Compress")
+	if err != nil {
+		return nil, err
+	}
+	users := make([]User, 0, len(rows))
+	for _, r := range rows {
+		// The RID (last SID component) plays the role of a UID.
+		sid := r["SID"]
+		users = append(users, User{
+			Username:    r["Name"],
+			UID:         atoiOr(sid[strings.LastIndex(sid, "-")+1:], -1),
+			GID:         -1,
+			Description: r["Description"],
+			Directory:   `C:\Users\` + r["Name"],
+			SID:         sid,
+		})
+	}
+	return users, nil
+}
+
+func collectProcessesWindows(ctx context.Context, limit int) ([]Process, error) {
+	// Get-Process lacks command lines; Win32_Process has them.
+	rows, err := runPowerShellJSONContext(ctx, fmt.Sprintf(
+		"Get-CimInstance Win32_Process | Select-Object -First %d ProcessId,Name,ExecutablePath,CommandLine | ConvertTo-Json -Compress", limit))
+	if err != nil {
+		return nil, err
+	}
+	procs := make([]Process, 0, len(rows))
+	for _, r := range rows {
+		procs = append(procs, Process{
+			PID:     atoiOr(r["ProcessId"], -1),
+			Name:    r["Name"],
+			Path:    r["ExecutablePath"],
+			Cmdline: r["CommandLine"],
+			UID:     -1,
+		})
+	}
+	return procs, nil
+}
+
+// collectOpenPortsWindows names each listener's owner by joining
+// OwningProcess against Get-Process in the same script.
+func collectOpenPortsWindows(ctx context.Context) ([]PortBinding, error) {
+	rows, err := runPowerShellJSONContext(ctx, "$names = @{}; Get-Process | ForEach-Object { $names[[int]$_.Id] = $_.ProcessName }; "+
+		"Get-NetTCPConnection -State Listen | Select-Object LocalAddress,LocalPort,OwningProcess,"+
+		"@{n='ProcessName';e={$names[[int]$_.OwningProcess]}} | ConvertTo-Json -Compress")
+	if err != nil {
+		return nil, err
+	}
+	return portsFromPowerShell(rows), nil
+}
+
+func portsFromPowerShell(rows []map[string]string) []PortBinding {
+	var ports []PortBinding
+	for _, r := range rows {
+		p, err := strconv.Atoi(r["LocalPort"])
+		if err != nil || p <= 0 {
+			continue
+		}
+		ports = append(ports, PortBinding{
+			Port:     p,
+			Protocol: "tcp",
+			Address:  r["LocalAddress"],
+			PID:      atoiOr(r["OwningProcess"], -1),
+			Process:  r["ProcessName"],
+		})
+	}
+	return ports
+}
+
+func collectConnectionsWindows(ctx context.Context, limit int) ([]Connection, error) {
+	rows, err := runPowerShellJSONContext(ctx, fmt.Sprintf(
+		"Get-NetTCPConnection -State Established | Select-Object -First %d LocalAddress,LocalPort,RemoteAddress,RemotePort,OwningProcess | ConvertTo-Json -Compress", limit))
+	if err != nil {
+		return nil, err
+	}
+	var conns []Connection
+	for _, r := range rows {
+		c := Connection{
+			PID:           atoiOr(r["OwningProcess"], -1),
+			Protocol:      "tcp",
+			LocalAddress:  r["LocalAddress"],
+			LocalPort:     atoiOr(r["LocalPort"], 0),
+			RemoteAddress: r["RemoteAddress"],
+			RemotePort:    atoiOr(r["RemotePort"], 0),
+		}
+		if remoteConnection(c) {
+			conns = append(conns, c)
+		}
+	}
+	return conns, nil
+}
+
+func collectPackagesWindows(ctx context.Context, limit int) ([]Package, error) {
+	rows, err := runPowerShellJSONContext(ctx, fmt.Sprintf(
+		"Get-Package | Select-Object -First %d Name,Version,ProviderName | ConvertTo-Json -Compress", limit))
+	if err != nil {
+		return nil, err
+	}
+	pkgs := make([]Package, 0, len(rows))
+	for _, r := range rows {
+		pkgs = append(pkgs, Package{
+			Name:    r["Name"],
+			Version: r["Version"],
+			Source:  r["ProviderName"],
+		})
+	}
+	return pkgs, nil
+}
@@ -0,0 +1,36 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePowerShellJSON(t *testing.T) {
+	rows, err := parsePowerShellJSON([]byte(`[{"Name":"Administrator","Enabled":false,"LocalPort":3389},{"Name":"dev","Description":null}]`))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "false", rows[0]["Enabled"])
+	assert.Equal(t, "3389", rows[0]["LocalPort"])
+	assert.Equal(t, "", rows[1]["Description"])
+
+	// A single result comes back as a bare object.
+	rows, err = parsePowerShellJSON([]byte(`{"Name":"only"}` + "\r\n"))
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "only", rows[0]["Name"])
+
+	rows, err = parsePowerShellJSON(nil)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+}
+
+func TestPortsFromPowerShell(t *testing.T) {
+	rows, err := parsePowerShellJSON([]byte(`[{"LocalAddress":"0.0.0.0","LocalPort":3389,"OwningProcess":1044,"ProcessName":"svchost"},{"LocalAddress":"::","LocalPort":445,"OwningProcess":4,"ProcessName":null}]`))
+	require.NoError(t, err)
+	assert.Equal(t, []PortBinding{
+		{Port: 3389, Protocol: "tcp", Address: "0.0.0.0", PID: 1044, Process: "svchost"},
+		{Port: 445, Protocol: "tcp", Address: "::", PID: 4},
+	}, portsFromPowerShell(rows))
+}
@@ -0,0 +1,39 @@
+package collector
+
+import (
+	"context"
+	"os/exec"
+)
+
+// CollectOpenPorts returns listening ports from netstat, which doesn't
+// attribute them to processes.
+func (f *FallbackCollector) CollectOpenPorts(ctx context.Context) ([]PortBinding, error) {
+	output, err := exec.CommandContext(ctx, "netstat", "-an").Output()
+	if err != nil {
+		return nil, err
+	}
+	ports, _ := parseSolarisNetstat(string(output))
+	return ports, nil
+}
+
+// CollectConnections returns established TCP connections from netstat.
+func (f *FallbackCollector) CollectConnections(ctx context.Context, limit int) ([]Connection, error) {
+	output, err := exec.CommandContext(ctx, "netstat", "-an", "-P", "tcp").Output()
+	if err != nil {
+		return nil, err
+	}
+	_, conns := parseSolarisNetstat(string(output))
+	return limitConnections(conns, limit), nil
+}
+
+// CollectPackages returns IPS packages (Solaris 11, OmniOS,
+// OpenIndiana). Hosts without IPS, such as Solaris 10 or SmartOS, report
+// none.
+func (f *FallbackCollector) CollectPackages(ctx context.Context, limit int) ([]Package, error) {
+	var packages []Package
+	output, err := packageQuery(ctx, "pkg", "list", "-H")
+	if err == nil {
+		packages = parseIPSList(string(output), limit)
+	}
+	return packagesResult(ctx, packages, err)
+}
@@ -0,0 +1,216 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"compliance-agent/errcode"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePasswd(t *testing.T) {
+	users := parsePasswd("# comment\nroot:x:0:0:root:/root:/bin/ash\nnobody:x:65534:65534:nobody:/:/sbin/nologin\nbroken:x\n")
+	assert.Equal(t, []User{
+		{Username: "root", UID: 0, GID: 0, Description: "root", Directory: "/root", Shell: "/bin/ash"},
+		{Username: "nobody", UID: 65534, GID: 65534, Description: "nobody", Directory: "/", Shell: "/sbin/nologin"},
+	}, users)
+}
+
+func TestParsePsAux(t *testing.T) {
+	out := `USER         PID %CPU %MEM    VSZ   RSS TTY      STAT START   TIME COMMAND
+root           1  0.0  0.1 167744 11756 ?        Ss   Apr01   0:09 /sbin/init splash
+alice       4242  1.2  2.0 912340 80000 pts/0    Sl+  10:00   0:30 /usr/bin/python3 app.py
+`
+	assert.Equal(t, []Process{
+		{PID: 1, Name: "/sbin/init", Path: "/sbin/init", Cmdline: "/sbin/init splash", UID: -1, User: "root"},
+	}, parsePsAux(out, 1))
+
+	busybox := "PID   USER     TIME  COMMAND\n    1 root      0:01 /sbin/init\n"
+	assert.Empty(t, parsePsAux(busybox, 100), "BusyBox ps has too few columns")
+}
+
+func TestParsePackageLists(t *testing.T) {
+	dpkg := `Desired=Unknown/Install/Remove/Purge/Hold
+||/ Name           Version         Architecture Description
++++-==============-===============-============-=================
+ii  openssl        3.0.2-0ubuntu1  arm64        Secure Sockets Layer toolkit
+rc  oldpkg         1.0             arm64        removed, config left
+`
+	assert.Equal(t, []Package{{Name: "openssl", Version: "3.0.2-0ubuntu1", Source: "dpkg", Arch: "arm64"}}, parseDpkgList(dpkg, "arm64", 10))
+
+	rpm := "bash\t5.1.8-9.el9\taarch64\t8c1f4b3f7bd0e0f0d9a5b1f6a2c3d4e5f6a7b8c9\nopenssl\t3.0.7-27.el9\taarch64\t(none)\nold\t1.0-1\tnoarch\n"
+	assert.Equal(t, []Package{
+		{Name: "bash", Version: "5.1.8-9.el9", Source: "rpm", Arch: "aarch64", SHA1: "8c1f4b3f7bd0e0f0d9a5b1f6a2c3d4e5f6a7b8c9"},
+		{Name: "openssl", Version: "3.0.7-27.el9", Source: "rpm", Arch: "aarch64"},
+		{Name: "old", Version: "1.0-1", Source: "rpm", Arch: "noarch"},
+	}, parseRPMList(rpm, 10), "no digest, or an rpm that printed none")
+	assert.Len(t, parseRPMList(rpm, 1), 1)
+
+	apk := `C:Q1abc=
+P:musl
+V:1.2.4-r2
+A:aarch64
+T:the musl c library
+
+C:Q1zH0uQvLj6PSiqB7t1eFvmeSEFr8=
+P:busybox
+V:1.36.1-r5
+A:aarch64
+`
+	assert.Equal(t, []Package{
+		{Name: "musl", Version: "1.2.4-r2", Source: "apk", Arch: "aarch64"},
+		{Name: "busybox", Version: "1.36.1-r5", Source: "apk", Arch: "aarch64", SHA1: "cc7d2e42f2e3e8f4a2a81eedd5e16f99e48416bf"},
+	}, parseApkInstalled(apk, 10), "musl's checksum is malformed")
+	assert.Len(t, parseApkInstalled(apk, 1), 1)
+}
+
+func TestPackagesResult(t *testing.T) {
+	pkgs := []Package{{Name: "bash"}}
+	got, err := packagesResult(context.Background(), pkgs, nil)
+	require.NoError(t, err)
+	assert.Equal(t, pkgs, got)
+
+	_, err = packagesResult(context.Background(), nil, errNoPackageManager)
+	assert.Equal(t, errcode.CollectorUnavailable, errcode.Of(err))
+	_, err = packageQuery(context.Background(), "compliance-agent-no-such-package-manager")
+	assert.ErrorContains(t, err, "compliance-agent-no-such-package-manager")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = packagesResult(ctx, nil, errors.New("dpkg -l: signal: killed"))
+	assert.ErrorIs(t, err, context.Canceled, "the scan's error, not the query's")
+}
+
+func TestParseSystemFiles(t *testing.T) {
+	l1, l5, l15 := parseUptimeLoadAvg(" 10:00:00 up 3 days,  2 users,  load average: 0.52, 0.58, 0.59\n")
+	assert.Equal(t, []float64{0.52, 0.58, 0.59}, []float64{l1, l5, l15})
+
+	total, free := parseMeminfo("MemTotal:        8000000 kB\nMemFree:          100000 kB\nMemAvailable:    4000000 kB\n")
+	assert.Equal(t, int64(8000000), total)
+	assert.Equal(t, int64(4000000), free)
+
+	stats := parseProcNetDev(`Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+  eth0: 1000 10 0 0 0 0 0 0 2000 20 0 0 0 0 0 0
+`)
+	assert.Equal(t, []NetworkInterface{{Name: "eth0", RXBytes: 1000, RXPkts: 10, TXBytes: 2000, TXPkts: 20}}, stats.Interfaces)
+}
+
+func TestParseBSD(t *testing.T) {
+	master := "# $FreeBSD$\nroot:$6$salt:0:0::0:0:Charlie &:/root:/bin/sh\ntoor:*:0:0::0:0:Bourne-again Superuser:/root:\nshort:x:1:1\n"
+	assert.Equal(t, []User{
+		{Username: "root", UID: 0, GID: 0, Description: "Charlie &", Directory: "/root", Shell: "/bin/sh"},
+		{Username: "toor", UID: 0, GID: 0, Description: "Bourne-again Superuser", Directory: "/root", Shell: ""},
+	}, parseMasterPasswd(master))
+
+	ps := "    1     0 root  init     /sbin/init\n  812     0 root  sshd     sshd: /usr/sbin/sshd [listener] 0 of 10-100 startups\n"
+	assert.Equal(t, []Process{
+		{PID: 1, UID: 0, User: "root", Name: "init", Path: "/sbin/init", Cmdline: "/sbin/init"},
+	}, parsePsColumns(ps, 1))
+	assert.Len(t, parsePsColumns(ps, 10), 2)
+
+	pkg := "curl-8.4.0                     Command line tool and library for transferring data with URLs\nxorg-fonts-100dpi-7.7_3        X.Org 100dpi bitmap fonts\n"
+	pkgs := parsePkgInfo(pkg, "pkg", 10)
+	assert.Equal(t, []string{"curl", "8.4.0", "xorg-fonts-100dpi", "7.7_3"},
+		[]string{pkgs[0].Name, pkgs[0].Version, pkgs[1].Name, pkgs[1].Version})
+	pkgs = parsePkgInfo("vim-9.0.1677-no_x11 vi clone, many additional features\nquirks-6.160 exceptions to pkg_add rules\n", "pkg_info", 10)
+	assert.Equal(t, []string{"vim", "9.0.1677-no_x11", "quirks", "6.160"},
+		[]string{pkgs[0].Name, pkgs[0].Version, pkgs[1].Name, pkgs[1].Version})
+	assert.Equal(t, "pkg_info", pkgs[0].Source)
+}
+
+func TestParseSockstat(t *testing.T) {
+	listen := `USER     COMMAND    PID   FD  PROTO  LOCAL ADDRESS         FOREIGN ADDRESS
+root     sshd       812   4   tcp6   *:22                  *:*
+root     sshd       812   5   tcp4   *:22                  *:*
+www      nginx      901   6   tcp4   *:443                 *:*
+www      nginx      901   7   tcp4   *:443                 *:*
+root     syslogd    600   6   udp4   127.0.0.1:514         *:*
+?        ?          ?     ?   udp46  *:68                  *:*
+`
+	assert.Equal(t, []PortBinding{
+		{Port: 22, Protocol: "tcp", Address: "0.0.0.0", PID: 812, Process: "sshd"},
+		{Port: 443, Protocol: "tcp", Address: "0.0.0.0", PID: 901, Process: "nginx"},
+		{Port: 514, Protocol: "udp", Address: "127.0.0.1", PID: 600, Process: "syslogd"},
+		{Port: 68, Protocol: "udp", Address: "0.0.0.0", PID: -1},
+	}, parseSockstatListeners(listen))
+
+	conns := `USER     COMMAND    PID   FD  PROTO  LOCAL ADDRESS         FOREIGN ADDRESS
+root     sshd       1200  4   tcp4   10.0.0.5:22           203.0.113.9:51234
+root     postgres   700   9   tcp4   127.0.0.1:5432        127.0.0.1:40000
+`
+	assert.Equal(t, []Connection{{
+		PID: 1200, Process: "sshd", Protocol: "tcp",
+		LocalAddress: "10.0.0.5", LocalPort: 22, RemoteAddress: "203.0.113.9", RemotePort: 51234,
+	}}, parseSockstatConnections(conns))
+
+	netstat := `Active Internet connections (including servers)
+Proto   Recv-Q Send-Q  Local Address          Foreign Address        (state)
+tcp          0      0  *.22                   *.*                    LISTEN
+tcp          0      0  10.0.0.5.22            203.0.113.9.51234      ESTABLISHED
+tcp6         0      0  ::1.25                 *.*                    LISTEN
+udp          0      0  *.514                  *.*
+udp          0      0  10.0.0.5.40000         10.0.0.1.53
+Active UNIX domain sockets
+Address          Type   Recv-Q Send-Q    Inode     Conn     Refs  Nextref Addr
+0xffff800000a1b2 stream      0      0        0 0xffff8000     0        0 /var/run/sock
+`
+	assert.Equal(t, []PortBinding{
+		{Port: 22, Protocol: "tcp", Address: "0.0.0.0", PID: -1},
+		{Port: 25, Protocol: "tcp", Address: "::1", PID: -1},
+		{Port: 514, Protocol: "udp", Address: "0.0.0.0", PID: -1},
+	}, parseBSDNetstatListeners(netstat))
+	assert.Len(t, parseNetstatConnections(netstat, "."), 1)
+}
+
+func TestParseLegacyUnix(t *testing.T) {
+	ps := "  1     0 root     /sbin/init /sbin/init\n 612    25 smmsp    /usr/lib/sendmail /usr/lib/sendmail -Ac -q15m\n"
+	assert.Equal(t, Process{PID: 612, UID: 25, User: "smmsp", Name: "/usr/lib/sendmail", Path: "/usr/lib/sendmail", Cmdline: "/usr/lib/sendmail -Ac -q15m"},
+		parsePsColumns(ps, 10)[1])
+
+	netstat := `
+UDP: IPv4
+   Local Address        Remote Address      State
+-------------------- -------------------- ----------
+      *.514                                 Idle
+10.0.0.5.40000       10.0.0.1.53          Connected
+
+TCP: IPv4
+   Local Address        Remote Address    Swind Send-Q Rwind Recv-Q    State
+-------------------- -------------------- ----- ------ ----- ------ -----------
+      *.22                 *.*                0      0 128000      0 LISTEN
+127.0.0.1.25               *.*                0      0 128000      0 LISTEN
+10.0.0.5.22          203.0.113.9.51234    64128      0 128872      0 ESTABLISHED
+
+TCP: IPv6
+   Local Address                     Remote Address                 Swind Send-Q Rwind Recv-Q   State      If
+--------------------------------- --------------------------------- ----- ------ ----- ------ ----------- -----
+      *.22                              *.*                             0      0 128000      0 LISTEN
+
+Active UNIX domain sockets
+Address          Type       Vnode            Conn             Local Addr      Remote Addr
+ffffff0d0a1b2c38 stream-ord ffffff0d0a1b2e00 0000000000000000 /var/run/.inetd.uds.1
+`
+	ports, conns := parseSolarisNetstat(netstat)
+	assert.Equal(t, []PortBinding{
+		{Port: 514, Protocol: "udp", Address: "0.0.0.0", PID: -1},
+		{Port: 22, Protocol: "tcp", Address: "0.0.0.0", PID: -1},
+		{Port: 25, Protocol: "tcp", Address: "127.0.0.1", PID: -1},
+		{Port: 22, Protocol: "tcp", Address: "0.0.0.0", PID: -1},
+	}, ports)
+	assert.Equal(t, []Connection{{
+		PID: -1, Protocol: "tcp", LocalAddress: "10.0.0.5", LocalPort: 22, RemoteAddress: "203.0.113.9", RemotePort: 51234,
+	}}, conns)
+
+	ips := parseIPSList("compress/bzip2                1.0.8-11.4.0.0.1.14.0      i--\nnetwork/openssh               9.6.1-11.4.66.0.1.159.0    i--\n", 1)
+	assert.Equal(t, []string{"compress/bzip2", "1.0.8-11.4.0.0.1.14.0", "ips"}, []string{ips[0].Name, ips[0].Version, ips[0].Source})
+	assert.Len(t, ips, 1)
+
+	lslpp := "#Package Name:Fileset:Level:State:PTF Id:Fix State:Type:Description\nbos:bos.rte:7.2.5.0: : :C: :Base Operating System Runtime\nopenssh.base:openssh.base.server:9.2.112.2400: : :C:F:Open Secure Shell Server\n"
+	pkgs := parseLslpp(lslpp, 10)
+	require.Len(t, pkgs, 2)
+	assert.Equal(t, []string{"openssh.base.server", "9.2.112.2400", "lslpp"}, []string{pkgs[1].Name, pkgs[1].Version, pkgs[1].Source})
+}
@@ -0,0 +1,7 @@
+//go:build !solaris && !aix
+
+package collector
+
+// SupportTier returns "" on fully supported platforms, MinimalTier on
+// Solaris, illumos and AIX.
+func SupportTier() string { return "" }
@@ -0,0 +1,35 @@
+package collector
+
+import "context"
+
+// Windows fallback collection goes through PowerShell and WMI/CIM; see
+// fallback_powershell.go.
+
+// CollectUsers returns local accounts from Get-LocalUser.
+func (f *FallbackCollector) CollectUsers(ctx context.Context) ([]User, error) {
+	return collectUsersWindows(ctx)
+}
+
+// CollectProcesses returns running processes from Win32_Process.
+func (f *FallbackCollector) CollectProcesses(ctx context.Context, limit int) ([]Process, error) {
+	return collectProcessesWindows(ctx, limit)
+}
+
+// CollectOpenPorts returns listening TCP endpoints with their owning
+// process.
+func (f *FallbackCollector) CollectOpenPorts(ctx context.Context) ([]PortBinding, error) {
+	return collectOpenPortsWindows(ctx)
+}
+
+// CollectConnections returns established TCP connections.
+func (f *FallbackCollector) CollectConnections(ctx context.Context, limit int) ([]Connection, error) {
+	if limit <= 0 {
+		limit = 1000
+	}
+	return collectConnectionsWindows(ctx, limit)
+}
+
+// CollectPackages returns installed software from Get-Package.
+func (f *FallbackCollector) CollectPackages(ctx context.Context, limit int) ([]Package, error) {
+	return collectPackagesWindows(ctx, limit)
+}
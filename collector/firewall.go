@@ -0,0 +1,137 @@
+package collector
+
+import (
+	"context"
+	"strings"
+)
+
+// FirewallStatus is whether the host firewall is filtering inbound
+// traffic, with what each backend reported.
+type FirewallStatus struct {
+	// Enabled is nil when no backend could be queried, which on Linux
+	// usually means the agent isn't root.
+	Enabled  *bool             `json:"enabled"`
+	Backends []FirewallBackend `json:"backends,omitempty"`
+}
+
+// FirewallBackend is one firewall the agent queried: alf on macOS; ufw,
+// firewalld, nftables or iptables on Linux; one entry per Windows
+// Firewall profile (windows:Domain, windows:Private, windows:Public).
+type FirewallBackend struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// CollectFirewall reads the host firewall state. On Linux any backend
+// that filters inbound traffic counts; on Windows every profile has to
+// be on, since the active profile changes with the network.
+func CollectFirewall(ctx context.Context) (FirewallStatus, error) {
+	backends, all, err := firewallBackends(ctx)
+	st := FirewallStatus{Backends: backends}
+	if len(backends) == 0 {
+		return st, err
+	}
+	st.Enabled = boolPtr(firewallEnabled(backends, all))
+	return st, nil
+}
+
+// firewallEnabled requires every backend to be on when all is set, and
+// any one otherwise.
+func firewallEnabled(backends []FirewallBackend, all bool) bool {
+	for _, b := range backends {
+		if all && !b.Enabled {
+			return false
+		}
+		if !all && b.Enabled {
+			return true
+		}
+	}
+	return all
+}
+
+func parseSocketfilterfw(out string) FirewallBackend {
+	// "Firewall is enabled. (State = 1)"; State 2 is "block all".
+	return FirewallBackend{
+		Name:    "alf",
+		Enabled: strings.Contains(out, "enabled") || strings.Contains(out, "blocking all"),
+		Detail:  strings.TrimSpace(out),
+	}
+}
+
+func parseUFWStatus(out string) FirewallBackend {
+	line, _, _ := strings.Cut(strings.TrimSpace(out), "\n")
+	return FirewallBackend{Name: "ufw", Enabled: strings.TrimSpace(line) == "Status: active", Detail: strings.TrimSpace(line)}
+}
+
+func parseFirewalldState(out string) FirewallBackend {
+	state := strings.TrimSpace(out)
+	return FirewallBackend{Name: "firewalld", Enabled: state == "running", Detail: state}
+}
+
+// parseNftRuleset looks for a chain on the input hook that drops by
+// default or has a drop or reject rule.
+func parseNftRuleset(out string) FirewallBackend {
+	b := FirewallBackend{Name: "nftables", Detail: "no filtering input chain"}
+	var chain string
+	input, filtering := false, false
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "chain "):
+			chain = strings.TrimSuffix(strings.TrimPrefix(line, "chain "), " {")
+			input, filtering = false, false
+		case chain == "":
+		case line == "}":
+			if input && filtering {
+				b.Enabled, b.Detail = true, "input chain "+chain+" filters"
+				return b
+			}
+			chain = ""
+		case strings.Contains(line, "hook input"):
+			input = true
+			filtering = filtering || strings.Contains(line, "policy drop")
+		case strings.HasSuffix(line, " drop") || line == "drop" || strings.Contains(line, "reject"):
+			filtering = true
+		}
+	}
+	return b
+}
+
+// parseIptablesInput reads `iptables -S INPUT`: the chain filters when
+// its policy isn't ACCEPT or a rule drops, rejects or hands off to
+// another chain (as ufw and firewalld do).
+func parseIptablesInput(out string) FirewallBackend {
+	b := FirewallBackend{Name: "iptables"}
+	rules := 0
+	for _, line := range strings.Split(out, "\n") {
+		f := strings.Fields(line)
+		switch {
+		case len(f) == 3 && f[0] == "-P":
+			b.Detail = "policy " + f[2]
+			b.Enabled = b.Enabled || f[2] != "ACCEPT"
+		case len(f) > 2 && f[0] == "-A":
+			rules++
+			for i := 0; i+1 < len(f); i++ {
+				if f[i] == "-j" && f[i+1] != "ACCEPT" && f[i+1] != "RETURN" && f[i+1] != "LOG" {
+					b.Enabled = true
+				}
+			}
+		}
+	}
+	if rules > 0 {
+		b.Detail = strings.TrimSpace(b.Detail + " with rules")
+	}
+	return b
+}
+
+// firewallProfilesWindows reads Get-NetFirewallProfile, where Enabled is
+// a GpoBoolean that ConvertTo-Json writes as 1/0.
+func firewallProfilesWindows(rows []map[string]string) []FirewallBackend {
+	var out []FirewallBackend
+	for _, r := range rows {
+		on := r["Enabled"] == "1" || strings.EqualFold(r["Enabled"], "true")
+		out = append(out, FirewallBackend{Name: "windows:" + r["Name"], Enabled: on})
+	}
+	return out
+}
@@ -0,0 +1,15 @@
+package collector
+
+import (
+	"context"
+	"os/exec"
+)
+
+// firewallBackends reads the Application Firewall's global state.
+func firewallBackends(ctx context.Context) ([]FirewallBackend, bool, error) {
+	out, err := exec.CommandContext(ctx, "/usr/libexec/ApplicationFirewall/socketfilterfw", "--getglobalstate").Output()
+	if err != nil {
+		return nil, false, err
+	}
+	return []FirewallBackend{parseSocketfilterfw(string(out))}, false, nil
+}
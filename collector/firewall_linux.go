@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+var linuxFirewalls = []struct {
+	name  string
+	cmd   []string
+	parse func(string) FirewallBackend
+}{
+	{"ufw", []string{"ufw", "status"}, parseUFWStatus},
+	{"firewalld", []string{"firewall-cmd", "--state"}, parseFirewalldState},
+	{"nftables", []string{"nft", "list", "ruleset"}, parseNftRuleset},
+	{"iptables", []string{"iptables", "-S", "INPUT"}, parseIptablesInput},
+}
+
+// firewallBackends asks each installed backend; any one filtering
+// inbound traffic counts. err is set only when none could be read.
+func firewallBackends(ctx context.Context) (backends []FirewallBackend, all bool, err error) {
+	var errs []error
+	for _, q := range linuxFirewalls {
+		if _, err := exec.LookPath(q.cmd[0]); err != nil {
+			continue
+		}
+		// firewall-cmd --state exits non-zero when not running,
+		// with the answer still on stdout.
+		out, err := exec.CommandContext(ctx, q.cmd[0], q.cmd[1:]...).Output()
+		var exitErr *exec.ExitError
+		if err != nil && !(q.name == "firewalld" && errors.As(err, &exitErr) && len(out) > 0) {
+			errs = append(errs, err)
+			continue
+		}
+		backends = append(backends, q.parse(string(out)))
+	}
+	if len(backends) == 0 && len(errs) == 0 {
+		errs = append(errs, errors.New("no firewall found"))
+	}
+	return backends, false, errors.Join(errs...)
+}
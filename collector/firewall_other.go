@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package collector
+
+import "context"
+
+// firewallBackends is not implemented on this platform.
+func firewallBackends(context.Context) ([]FirewallBackend, bool, error) { return nil, false, nil }
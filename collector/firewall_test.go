@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUFWStatus(t *testing.T) {
+	assert.True(t, parseUFWStatus("Status: active\n\nTo Action From\n22/tcp ALLOW Anywhere\n").Enabled)
+	b := parseUFWStatus("Status: inactive\n")
+	assert.False(t, b.Enabled)
+	assert.Equal(t, "Status: inactive", b.Detail)
+}
+
+func TestParseNftRuleset(t *testing.T) {
+	filtering := `table inet filter {
+	chain input {
+		type filter hook input priority filter; policy accept;
+		ct state established,related accept
+		tcp dport 22 accept
+		counter drop
+	}
+	chain forward {
+		type filter hook forward priority filter; policy drop;
+	}
+}
+`
+	b := parseNftRuleset(filtering)
+	assert.True(t, b.Enabled)
+	assert.Equal(t, "input chain input filters", b.Detail)
+
+	open := `table inet filter {
+	chain input {
+		type filter hook input priority filter; policy accept;
+	}
+	chain forward {
+		type filter hook forward priority filter; policy drop;
+	}
+}
+`
+	assert.False(t, parseNftRuleset(open).Enabled, "only forward drops")
+	assert.True(t, parseNftRuleset("table ip t {\n\tchain in {\n\t\ttype filter hook input priority 0; policy drop;\n\t}\n}\n").Enabled)
+}
+
+func TestParseIptablesInput(t *testing.T) {
+	b := parseIptablesInput("-P INPUT ACCEPT\n")
+	assert.False(t, b.Enabled)
+	assert.Equal(t, "policy ACCEPT", b.Detail)
+
+	assert.True(t, parseIptablesInput("-P INPUT DROP\n").Enabled)
+	assert.True(t, parseIptablesInput("-P INPUT ACCEPT\n-A INPUT -j ufw-before-input\n").Enabled)
+	b = parseIptablesInput("-P INPUT ACCEPT\n-A INPUT -i lo -j ACCEPT\n")
+	assert.False(t, b.Enabled)
+	assert.Equal(t, "policy ACCEPT with rules", b.Detail)
+}
+
+func TestFirewallProfilesWindows(t *testing.T) {
+	rows, err := parsePowerShellJSON([]byte(`[{"Name":"Domain","Enabled":1},{"Name":"Private","Enabled":1},{"Name":"Public","Enabled":0}]`))
+	require.NoError(t, err)
+	backends := firewallProfilesWindows(rows)
+	assert.Equal(t, []FirewallBackend{
+		{Name: "windows:Domain", Enabled: true},
+		{Name: "windows:Private", Enabled: true},
+		{Name: "windows:Public"},
+	}, backends)
+	assert.False(t, firewallEnabled(backends, true), "every profile must be on")
+	assert.True(t, firewallEnabled(backends, false))
+	assert.True(t, parseSocketfilterfw("Firewall is enabled. (State = 1)\n").Enabled)
+	assert.False(t, parseSocketfilterfw("Firewall is disabled. (State = 0)\n").Enabled)
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package collector
+
+import "context"
+
+// firewallBackends reads every firewall profile. All of them have to be
+// on, since the active profile changes with the network.
+func firewallBackends(ctx context.Context) ([]FirewallBackend, bool, error) {
+	rows, err := runPowerShellJSONContext(ctx, "Get-NetFirewallProfile | Select-Object Name,Enabled | ConvertTo-Json -Compress")
+	if err != nil {
+		return nil, true, err
+	}
+	return firewallProfilesWindows(rows), true, nil
+}
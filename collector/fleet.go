@@ -0,0 +1,211 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FleetCollector runs the agent's queries remotely through a Fleet server's
+// live-query API instead of the local extension socket. It is meant for
+// Fleet-managed hosts where the socket is locked down: the osqueryd on the
+// host still answers, but over its TLS connection to Fleet.
+type FleetCollector struct {
+	URL            string // Fleet base URL, e.g. https://fleet.example.com
+	Token          string // Fleet API token
+	HostIdentifier string // hostname, UUID or node key Fleet knows the host by
+	client         *http.Client
+
+	mu   sync.Mutex
+	info *OSQueryInfo
+}
+
+// NewFleetCollector targets the current host on a Fleet server. An empty
+// identifier defaults to the local hostname.
+func NewFleetCollector(baseURL, token, hostIdentifier string, timeout time.Duration) *FleetCollector {
+	if hostIdentifier == "" {
+		hostIdentifier, _ = os.Hostname()
+	}
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &FleetCollector{
+		URL:            strings.TrimRight(baseURL, "/"),
+		Token:          token,
+		HostIdentifier: hostIdentifier,
+		client:         &http.Client{Timeout: timeout},
+	}
+}
+
+type fleetQueryResp struct {
+	Status string              `json:"status"`
+	Error  *string             `json:"error"`
+	Rows   []map[string]string `json:"rows"`
+}
+
+func (f *FleetCollector) query(ctx context.Context, sql string) ([]map[string]string, error) {
+	body, err := json.Marshal(map[string]string{"query": sql})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf("%s/api/v1/fleet/hosts/identifier/%s/query", f.URL, url.PathEscape(f.HostIdentifier))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+f.Token)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fleet query failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("fleet API returned %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	var out fleetQueryResp
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode fleet response: %w", err)
+	}
+	if out.Error != nil && *out.Error != "" {
+		return nil, fmt.Errorf("fleet query error (host %s): %s", out.Status, *out.Error)
+	}
+	return out.Rows, nil
+}
+
+// Info returns the remote daemon's osquery version and platform.
+func (f *FleetCollector) Info() (OSQueryInfo, error) {
+	return f.loadInfo(context.Background())
+}
+
+func (f *FleetCollector) loadInfo(ctx context.Context) (OSQueryInfo, error) {
+	f.mu.Lock()
+	cached := f.info
+	f.mu.Unlock()
+	if cached != nil {
+		return *cached, nil
+	}
+	rows, err := f.query(ctx, "SELECT version, build_platform, build_distro FROM osquery_info;")
+	if err != nil {
+		return OSQueryInfo{}, fmt.Errorf("read osquery_info: %w", err)
+	}
+	if len(rows) == 0 {
+		return OSQueryInfo{}, fmt.Errorf("osquery_info returned no rows")
+	}
+	info := OSQueryInfo{
+		Version:       rows[0]["version"],
+		BuildPlatform: rows[0]["build_platform"],
+		BuildDistro:   rows[0]["build_distro"],
+	}
+	info.Supported = CompareVersions(info.Version, minSupportedOSQuery) >= 0
+	f.mu.Lock()
+	f.info = &info
+	f.mu.Unlock()
+	return info, nil
+}
+
+func (f *FleetCollector) compatQuery(ctx context.Context, name string, limit int) ([]map[string]string, error) {
+	info, _ := f.loadInfo(ctx)
+	return runCompat(ctx, f.query, info, name, limit)
+}
+
+// HealthCheck confirms Fleet accepts our token and the host is online.
+func (f *FleetCollector) HealthCheck() error {
+	if _, err := f.query(context.Background(), "SELECT 1 AS ok;"); err != nil {
+		return fmt.Errorf("fleet health check failed: %w", err)
+	}
+	return nil
+}
+
+// CollectUsers returns local users from the remote host.
+func (f *FleetCollector) CollectUsers(ctx context.Context) ([]User, error) {
+	rows, err := f.compatQuery(ctx, "users", 0)
+	if err != nil {
+		return nil, err
+	}
+	return usersFromRows(rows), nil
+}
+
+// CollectProcesses returns a subset of the remote host's processes.
+func (f *FleetCollector) CollectProcesses(ctx context.Context, limit int) ([]Process, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := f.compatQuery(ctx, "processes", limit)
+	if err != nil {
+		return nil, err
+	}
+	return processesFromRows(rows), nil
+}
+
+// CollectOpenPorts returns the remote host's listening ports.
+func (f *FleetCollector) CollectOpenPorts(ctx context.Context) ([]PortBinding, error) {
+	rows, err := f.compatQuery(ctx, "listening_ports", 0)
+	if err != nil {
+		return nil, err
+	}
+	return portsFromRows(rows), nil
+}
+
+// CollectConnections returns the remote host's established connections.
+func (f *FleetCollector) CollectConnections(ctx context.Context, limit int) ([]Connection, error) {
+	if limit <= 0 {
+		limit = 1000
+	}
+	rows, err := f.compatQuery(ctx, "connections", limit)
+	if err != nil {
+		return nil, err
+	}
+	return connectionsFromRows(rows), nil
+}
+
+// CollectPackages returns the remote host's installed packages.
+func (f *FleetCollector) CollectPackages(ctx context.Context, limit int) ([]Package, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := f.compatQuery(ctx, "packages", limit)
+	if err != nil {
+		return nil, err
+	}
+	return packagesFromRows(rows), nil
+}
+
+// CollectHardware returns the remote host's identifiers from system_info.
+func (f *FleetCollector) CollectHardware(ctx context.Context) (*HardwareInfo, error) {
+	rows, err := f.compatQuery(ctx, "system_info", 0)
+	if err != nil {
+		return nil, err
+	}
+	return hardwareFromRows(rows)
+}
+
+// CollectOSVersion returns the remote host's OS release and kernel.
+func (f *FleetCollector) CollectOSVersion(ctx context.Context) (*OSVersion, error) {
+	rows, err := f.compatQuery(ctx, "os_version", 0)
+	if err != nil {
+		return nil, err
+	}
+	return osVersionFromRows(rows)
+}
+
+// CollectDiskEncryption returns the remote host's volume encryption. Linux
+// hosts aren't supported, since there is no osquery query that finds
+// their root volume reliably.
+func (f *FleetCollector) CollectDiskEncryption(ctx context.Context) ([]DiskVolume, error) {
+	rows, err := f.compatQuery(ctx, "disk_encryption", 0)
+	if err != nil {
+		return nil, err
+	}
+	vols := volumesFromRows(rows)
+	return vols, checkBootVolume(vols)
+}
@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFleetCollector_RunsQueriesRemotely(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/fleet/hosts/identifier/web-1/query", r.URL.Path)
+		assert.Equal(t, "Bearer tok", r.Header.Get("Authorization"))
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		queries = append(queries, body["query"])
+
+		rows := []map[string]string{}
+		switch {
+		case strings.Contains(body["query"], "osquery_info"):
+			rows = append(rows, map[string]string{"version": "5.12.1", "build_platform": "linux"})
+		case strings.Contains(body["query"], "listening_ports"):
+			rows = append(rows, map[string]string{"port": "22", "protocol": "6", "address": "0.0.0.0", "pid": "812", "name": "sshd"}, map[string]string{"port": "x"})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"status": "online", "error": nil, "rows": rows})
+	}))
+	defer srv.Close()
+
+	f := NewFleetCollector(srv.URL+"/", "tok", "web-1", 0)
+	ports, err := f.CollectOpenPorts(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []PortBinding{{Port: 22, Protocol: "tcp", Address: "0.0.0.0", PID: 812, Process: "sshd"}}, ports)
+
+	_, err = f.CollectPackages(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Contains(t, queries[len(queries)-1], "deb_packages", "uses platform-specific SQL")
+}
+
+func TestFleetCollector_SurfacesQueryErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"offline","error":"host is offline","rows":null}`))
+	}))
+	defer srv.Close()
+
+	err := NewFleetCollector(srv.URL, "tok", "web-1", 0).HealthCheck()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "host is offline")
+}
+
+func TestFleetCollector_StopsWhenContextEnds(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := NewFleetCollector(srv.URL, "tok", "web-1", time.Minute).CollectUsers(ctx)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
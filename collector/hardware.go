@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// HardwareInfo identifies the machine itself, so its reports can be
+// told apart from a namesake's and tied together across a hostname
+// change or a re-image.
+type HardwareInfo struct {
+	// UUID is the SMBIOS system UUID (IOPlatformUUID on macOS). It
+	// survives a re-image.
+	UUID   string `json:"uuid,omitempty"`
+	Serial string `json:"serial,omitempty"`
+	Vendor string `json:"vendor,omitempty"`
+	Model  string `json:"model,omitempty"`
+	// MachineID is the OS installation's ID (/etc/machine-id, Windows
+	// MachineGuid). It changes with a re-image, and is shared by clones
+	// of one disk image that weren't generalized.
+	MachineID string `json:"machine_id,omitempty"`
+}
+
+// placeholderIDs are values firmware vendors ship instead of a real
+// UUID or serial; they identify nothing.
+var placeholderIDs = map[string]bool{
+	"":                                     true,
+	"0":                                    true,
+	"none":                                 true,
+	"n/a":                                  true,
+	"not specified":                        true,
+	"not applicable":                       true,
+	"to be filled by o.e.m.":               true,
+	"default string":                       true,
+	"system serial number":                 true,
+	"0123456789":                           true,
+	"00000000-0000-0000-0000-000000000000": true,
+	"ffffffff-ffff-ffff-ffff-ffffffffffff": true,
+	"03000200-0400-0500-0006-000700080009": true,
+}
+
+// cleanHardwareID trims v and drops placeholders.
+func cleanHardwareID(v string) string {
+	v = strings.TrimSpace(v)
+	if placeholderIDs[strings.ToLower(v)] {
+		return ""
+	}
+	return v
+}
+
+// clean drops placeholder values, and is nil when nothing is left.
+func (h *HardwareInfo) clean() *HardwareInfo {
+	out := HardwareInfo{
+		UUID:      strings.ToLower(cleanHardwareID(h.UUID)),
+		Serial:    cleanHardwareID(h.Serial),
+		Vendor:    cleanHardwareID(h.Vendor),
+		Model:     cleanHardwareID(h.Model),
+		MachineID: strings.ToLower(cleanHardwareID(h.MachineID)),
+	}
+	if out == (HardwareInfo{}) {
+		return nil
+	}
+	return &out
+}
+
+// CollectHardware reads the machine's identifiers: DMI and machine-id on
+// Linux (the UUID and serial need root), ioreg on macOS and
+// Win32_ComputerSystemProduct on Windows.
+func CollectHardware(ctx context.Context) (*HardwareInfo, error) {
+	h, err := readHardware(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return h.clean(), nil
+}
+
+var ioregProperty = regexp.MustCompile(`"(IOPlatformUUID|IOPlatformSerialNumber|manufacturer|model)" = <?"([^"]*)"`)
+
+// parseIOReg reads `ioreg -rd1 -c IOPlatformExpertDevice`.
+func parseIOReg(out string) HardwareInfo {
+	var h HardwareInfo
+	for _, m := range ioregProperty.FindAllStringSubmatch(out, -1) {
+		switch m[1] {
+		case "IOPlatformUUID":
+			h.UUID = m[2]
+		case "IOPlatformSerialNumber":
+			h.Serial = m[2]
+		case "manufacturer":
+			h.Vendor = m[2]
+		case "model":
+			h.Model = m[2]
+		}
+	}
+	return h
+}
+
+// hardwareFromRows reads osquery's system_info.
+func hardwareFromRows(rows []map[string]string) (*HardwareInfo, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("system_info returned no rows")
+	}
+	r := rows[0]
+	h := HardwareInfo{UUID: r["uuid"], Serial: r["hardware_serial"], Vendor: r["hardware_vendor"], Model: r["hardware_model"], MachineID: r["machine_id"]}
+	return h.clean(), nil
+}
@@ -0,0 +1,15 @@
+package collector
+
+import (
+	"context"
+	"os/exec"
+)
+
+// readHardware reads the platform expert device from ioreg.
+func readHardware(ctx context.Context) (HardwareInfo, error) {
+	out, err := exec.CommandContext(ctx, "ioreg", "-rd1", "-c", "IOPlatformExpertDevice").Output()
+	if err != nil {
+		return HardwareInfo{}, err
+	}
+	return parseIOReg(string(out)), nil
+}
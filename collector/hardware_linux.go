@@ -0,0 +1,21 @@
+package collector
+
+import (
+	"context"
+	"os"
+)
+
+// readHardware reads DMI and the machine ID.
+func readHardware(context.Context) (HardwareInfo, error) {
+	read := func(name string) string {
+		b, _ := os.ReadFile("/sys/class/dmi/id/" + name)
+		return string(b)
+	}
+	return HardwareInfo{
+		UUID:      read("product_uuid"),
+		Serial:    read("product_serial"),
+		Vendor:    read("sys_vendor"),
+		Model:     read("product_name"),
+		MachineID: localMachineID(),
+	}, nil
+}
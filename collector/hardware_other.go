@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package collector
+
+import "context"
+
+// readHardware only has the machine ID on this platform.
+func readHardware(context.Context) (HardwareInfo, error) {
+	return HardwareInfo{MachineID: localMachineID()}, nil
+}
@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIOReg(t *testing.T) {
+	out := `+-o J314sAP  <class IOPlatformExpertDevice, id 0x100000209, registered, matched, active, busy 0 (0 ms), retain 37>
+    {
+      "IOPlatformSerialNumber" = "C02XK1ABCD12"
+      "manufacturer" = <"Apple Inc.">
+      "model" = <"MacBookPro18,3">
+      "IOPlatformUUID" = "8A1B2C3D-4E5F-6071-8293-A4B5C6D7E8F9"
+    }`
+	h := parseIOReg(out)
+	assert.Equal(t, HardwareInfo{
+		UUID:   "8A1B2C3D-4E5F-6071-8293-A4B5C6D7E8F9",
+		Serial: "C02XK1ABCD12",
+		Vendor: "Apple Inc.",
+		Model:  "MacBookPro18,3",
+	}, h)
+	assert.Equal(t, "8a1b2c3d-4e5f-6071-8293-a4b5c6d7e8f9", h.clean().UUID, "lowercased")
+}
+
+func TestHardwareFromRows(t *testing.T) {
+	h, err := hardwareFromRows([]map[string]string{{
+		"uuid":            "03000200-0400-0500-0006-000700080009",
+		"hardware_serial": "To Be Filled By O.E.M.",
+		"hardware_vendor": "Dell Inc.",
+		"hardware_model":  "PowerEdge R640 ",
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, &HardwareInfo{Vendor: "Dell Inc.", Model: "PowerEdge R640"}, h, "placeholders dropped")
+
+	h, err = hardwareFromRows([]map[string]string{{"uuid": "00000000-0000-0000-0000-000000000000", "hardware_serial": "0"}})
+	require.NoError(t, err)
+	assert.Nil(t, h)
+
+	_, err = hardwareFromRows(nil)
+	assert.Error(t, err)
+}
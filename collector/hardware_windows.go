@@ -0,0 +1,22 @@
+//go:build windows
+
+package collector
+
+import (
+	"context"
+	"errors"
+)
+
+// readHardware reads Win32_ComputerSystemProduct and the MachineGuid.
+func readHardware(ctx context.Context) (HardwareInfo, error) {
+	rows, err := runPowerShellJSONContext(ctx, "$g = (Get-ItemProperty 'HKLM:\\SOFTWARE\\Microsoft\\Cryptography').MachineGuid; "+
+		"Get-CimInstance Win32_ComputerSystemProduct | Select-Object UUID,IdentifyingNumber,Vendor,Name,@{n='MachineGuid';e={$g}} | ConvertTo-Json -Compress")
+	if err != nil {
+		return HardwareInfo{}, err
+	}
+	if len(rows) == 0 {
+		return HardwareInfo{}, errors.New("Win32_ComputerSystemProduct returned nothing")
+	}
+	r := rows[0]
+	return HardwareInfo{UUID: r["UUID"], Serial: r["IdentifyingNumber"], Vendor: r["Vendor"], Model: r["Name"], MachineID: r["MachineGuid"]}, nil
+}
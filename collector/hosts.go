@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// HostsEntry is one mapping line from the hosts file.
+type HostsEntry struct {
+	IP        string   `json:"ip"`
+	Hostnames []string `json:"hostnames"`
+	Line      int      `json:"line"`
+}
+
+// ProxySettings is the system proxy configuration. Enabled is nil when
+// the platform's settings couldn't be read.
+type ProxySettings struct {
+	Enabled *bool  `json:"enabled"`
+	Server  string `json:"server,omitempty"`
+	PACURL  string `json:"pac_url,omitempty"`
+	// Bypass is the proxy exception list.
+	Bypass []string `json:"bypass,omitempty"`
+	Source string   `json:"source,omitempty"`
+}
+
+// CollectHostsFile reads the hosts file's mappings.
+func CollectHostsFile() ([]HostsEntry, error) {
+	b, err := os.ReadFile(HostsFilePath())
+	if err != nil {
+		return nil, err
+	}
+	return parseHosts(string(b)), nil
+}
+
+func parseHosts(s string) []HostsEntry {
+	var out []HostsEntry
+	sc := bufio.NewScanner(strings.NewReader(s))
+	n := 0
+	for sc.Scan() {
+		n++
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		names := make([]string, 0, len(fields)-1)
+		for _, h := range fields[1:] {
+			names = append(names, strings.ToLower(h))
+		}
+		out = append(out, HostsEntry{IP: fields[0], Hostnames: names, Line: n})
+	}
+	return out
+}
+
+// CollectProxySettings reads the system proxy configuration.
+func CollectProxySettings() ProxySettings {
+	return collectProxySettings()
+}
+
+// parseScutilProxy reads `scutil --proxy`, a property-list dump.
+func parseScutilProxy(out string) ProxySettings {
+	ps := ProxySettings{Source: "scutil --proxy"}
+	props := map[string]string{}
+	inExceptions := false
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if inExceptions {
+			if line == "}" {
+				inExceptions = false
+			} else if _, v, ok := strings.Cut(line, " : "); ok {
+				ps.Bypass = append(ps.Bypass, v)
+			}
+			continue
+		}
+		k, v, ok := strings.Cut(line, " : ")
+		if !ok {
+			continue
+		}
+		if k == "ExceptionsList" {
+			inExceptions = true
+			continue
+		}
+		props[k] = v
+	}
+	on := false
+	for _, scheme := range []string{"HTTP", "HTTPS"} {
+		if props[scheme+"Enable"] == "1" {
+			on = true
+			if ps.Server == "" {
+				ps.Server = props[scheme+"Proxy"] + ":" + props[scheme+"Port"]
+			}
+		}
+	}
+	if props["ProxyAutoConfigEnable"] == "1" {
+		on = true
+		ps.PACURL = props["ProxyAutoConfigURLString"]
+	}
+	ps.Enabled = &on
+	return ps
+}
+
+// parseGSettingsList reads a gsettings string array like
+// ['localhost', '127.0.0.0/8'].
+func parseGSettingsList(s string) []string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "@as ")
+	s = strings.Trim(s, "[]")
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.Trim(strings.TrimSpace(item), "'"); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,12 @@
+package collector
+
+import "os/exec"
+
+// collectProxySettings reads the system proxy from scutil.
+func collectProxySettings() ProxySettings {
+	out, err := exec.Command("scutil", "--proxy").Output()
+	if err != nil {
+		return ProxySettings{}
+	}
+	return parseScutilProxy(string(out))
+}
@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// collectProxySettings reads GNOME's proxy settings, which are what
+// desktop apps honor, falling back to the environment the agent itself
+// was started with.
+func collectProxySettings() ProxySettings {
+	if out, err := exec.Command("gsettings", "get", "org.gnome.system.proxy", "mode").Output(); err == nil {
+		mode := strings.Trim(strings.TrimSpace(string(out)), "'")
+		ps := ProxySettings{Source: "gsettings org.gnome.system.proxy", Enabled: boolPtr(mode == "manual" || mode == "auto")}
+		switch mode {
+		case "manual":
+			host, _ := exec.Command("gsettings", "get", "org.gnome.system.proxy.http", "host").Output()
+			port, _ := exec.Command("gsettings", "get", "org.gnome.system.proxy.http", "port").Output()
+			ps.Server = strings.Trim(strings.TrimSpace(string(host)), "'") + ":" + strings.TrimSpace(string(port))
+		case "auto":
+			url, _ := exec.Command("gsettings", "get", "org.gnome.system.proxy", "autoconfig-url").Output()
+			ps.PACURL = strings.Trim(strings.TrimSpace(string(url)), "'")
+		}
+		if out, err := exec.Command("gsettings", "get", "org.gnome.system.proxy", "ignore-hosts").Output(); err == nil {
+			ps.Bypass = parseGSettingsList(string(out))
+		}
+		return ps
+	}
+	ps := ProxySettings{Source: "environment"}
+	for _, k := range []string{"https_proxy", "HTTPS_PROXY", "http_proxy", "HTTP_PROXY"} {
+		if v := os.Getenv(k); v != "" {
+			ps.Server = v
+			break
+		}
+	}
+	ps.Enabled = boolPtr(ps.Server != "")
+	for _, k := range []string{"no_proxy", "NO_PROXY"} {
+		if v := os.Getenv(k); v != "" {
+			ps.Bypass = strings.Split(v, ",")
+			break
+		}
+	}
+	return ps
+}
@@ -0,0 +1,6 @@
+//go:build !linux && !darwin && !windows
+
+package collector
+
+// collectProxySettings is not implemented on this platform.
+func collectProxySettings() ProxySettings { return ProxySettings{} }
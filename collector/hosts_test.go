@@ -0,0 +1,39 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHosts(t *testing.T) {
+	got := parseHosts("# comment\n127.0.0.1\tlocalhost\n\n0.0.0.0 Falcon.CrowdStrike.com ts01-b.cloudsink.net # blocked\nbogus\n")
+	assert.Equal(t, []HostsEntry{
+		{IP: "127.0.0.1", Hostnames: []string{"localhost"}, Line: 2},
+		{IP: "0.0.0.0", Hostnames: []string{"falcon.crowdstrike.com", "ts01-b.cloudsink.net"}, Line: 4},
+	}, got)
+}
+
+func TestParseScutilProxy(t *testing.T) {
+	out := `<dictionary> {
+  ExceptionsList : <array> {
+    0 : *.local
+    1 : *
+  }
+  HTTPEnable : 1
+  HTTPPort : 8080
+  HTTPProxy : proxy.corp.example
+  ProxyAutoConfigEnable : 0
+}`
+	ps := parseScutilProxy(out)
+	require.NotNil(t, ps.Enabled)
+	assert.True(t, *ps.Enabled)
+	assert.Equal(t, "proxy.corp.example:8080", ps.Server)
+	assert.Equal(t, []string{"*.local", "*"}, ps.Bypass)
+}
+
+func TestParseGSettingsList(t *testing.T) {
+	assert.Equal(t, []string{"localhost", "127.0.0.0/8"}, parseGSettingsList("['localhost', '127.0.0.0/8']\n"))
+	assert.Empty(t, parseGSettingsList("@as []"))
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package collector
+
+import "strings"
+
+// collectProxySettings reads the current user's Internet Settings.
+func collectProxySettings() ProxySettings {
+	rows, err := runPowerShellJSON(`Get-ItemProperty 'HKCU:\Software\Microsoft\Windows\CurrentVersion\Internet Settings' | Select-Object ProxyEnable,ProxyServer,ProxyOverride,AutoConfigURL | ConvertTo-Json -Compress`)
+	if err != nil || len(rows) != 1 {
+		return ProxySettings{}
+	}
+	r := rows[0]
+	ps := ProxySettings{Source: "Internet Settings registry", Server: r["ProxyServer"], PACURL: r["AutoConfigURL"]}
+	ps.Enabled = boolPtr(r["ProxyEnable"] == "1" || ps.PACURL != "")
+	if o := r["ProxyOverride"]; o != "" {
+		ps.Bypass = strings.Split(o, ";")
+	}
+	return ps
+}
@@ -0,0 +1,207 @@
+package collector
+
+import (
+	"bufio"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NetInterface is one network interface with what matters for spotting
+// sniffers and rogue virtualization.
+type NetInterface struct {
+	Name string `json:"name"`
+	// Description is the adapter description on Windows.
+	Description string `json:"description,omitempty"`
+	MAC         string `json:"mac,omitempty"`
+	Up          bool   `json:"up"`
+	// Promiscuous is nil when the platform doesn't say.
+	Promiscuous *bool `json:"promiscuous"`
+	// Kind is loopback, physical, bridge, tap, tun, veth, vlan, bond,
+	// wireguard or virtual (any other software interface).
+	Kind string `json:"kind"`
+	// Master is the bridge or bond the interface is a port of. Bridge
+	// ports are promiscuous by design.
+	Master    string   `json:"master,omitempty"`
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// CollectInterfaces lists network interfaces with their mode and kind.
+func CollectInterfaces() ([]NetInterface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]NetInterface, 0, len(ifaces))
+	for _, ifc := range ifaces {
+		ni := NetInterface{
+			Name: ifc.Name,
+			MAC:  ifc.HardwareAddr.String(),
+			Up:   ifc.Flags&net.FlagUp != 0,
+		}
+		if ifc.Flags&net.FlagLoopback != 0 {
+			ni.Kind = "loopback"
+		}
+		if addrs, err := ifc.Addrs(); err == nil {
+			for _, a := range addrs {
+				ni.Addresses = append(ni.Addresses, a.String())
+			}
+		}
+		out = append(out, ni)
+	}
+
+	details := interfaceDetails()
+	for i := range out {
+		d, ok := details[out[i].Name]
+		if !ok {
+			continue
+		}
+		out[i].Description = d.Description
+		out[i].Promiscuous = d.Promiscuous
+		out[i].Master = d.Master
+		if out[i].Kind == "" {
+			out[i].Kind = d.Kind
+		}
+	}
+	for i := range out {
+		if out[i].Kind == "" {
+			out[i].Kind = kindFromName(out[i].Name)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// linkKinds maps the link types `ip -d link` prints onto interface kinds;
+// others are "virtual".
+var linkKinds = map[string]string{
+	"bridge":    "bridge",
+	"veth":      "veth",
+	"vlan":      "vlan",
+	"bond":      "bond",
+	"wireguard": "wireguard",
+	"macvtap":   "tap",
+}
+
+// parseIPLinkDetails reads `ip -d -o link show`, one interface per line:
+//
+//	3: docker0: <BROADCAST,MULTICAST,UP> mtu 1500 ... promiscuity 0 ... bridge forward_delay 1500 ...
+//	7: tap0: <BROADCAST,MULTICAST,PROMISC> ... promiscuity 1 ... tun type tap pi off ...
+//
+// promiscuity is a counter, so it also catches capture tools that join
+// promiscuous mode through a packet socket without setting IFF_PROMISC.
+func parseIPLinkDetails(out string) map[string]NetInterface {
+	res := map[string]NetInterface{}
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		f := strings.Fields(strings.ReplaceAll(sc.Text(), `\`, " "))
+		if len(f) < 3 {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimSuffix(f[1], ":"), "@")
+		var d NetInterface
+		for i, w := range f {
+			switch {
+			case w == "promiscuity" && i+1 < len(f):
+				if n, err := strconv.Atoi(f[i+1]); err == nil {
+					d.Promiscuous = boolPtr(n > 0)
+				}
+			case w == "master" && i+1 < len(f):
+				d.Master = f[i+1]
+			case w == "link/loopback":
+				d.Kind = "loopback"
+			case w == "maxmtu" && i+2 < len(f) && d.Kind == "":
+				// A software link's kind follows "maxmtu N"; physical
+				// links go straight on to the generic attributes.
+				switch kind := f[i+2]; {
+				case kind == "addrgenmode" || kind == "numtxqueues":
+					d.Kind = "physical"
+				case kind == "tun":
+					d.Kind = "tun"
+					if i+4 < len(f) && f[i+3] == "type" && f[i+4] == "tap" {
+						d.Kind = "tap"
+					}
+				case linkKinds[kind] != "":
+					d.Kind = linkKinds[kind]
+				default:
+					d.Kind = "virtual"
+				}
+			}
+		}
+		res[name] = d
+	}
+	return res
+}
+
+// parseIfconfig reads macOS `ifconfig -a` for the PROMISC flag and
+// bridge members:
+//
+//	en0: flags=8963<UP,BROADCAST,SMART,RUNNING,PROMISC,SIMPLEX,MULTICAST> mtu 1500
+//	bridge0: flags=8863<UP,BROADCAST,SMART,RUNNING,SIMPLEX,MULTICAST> mtu 1500
+//		member: en1 flags=3<LEARNING,DISCOVER>
+func parseIfconfig(out string) map[string]NetInterface {
+	res := map[string]NetInterface{}
+	masters := map[string]string{}
+	current := ""
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if f := strings.Fields(line); len(f) >= 2 && f[0] == "member:" && current != "" {
+				masters[f[1]] = current
+			}
+			continue
+		}
+		name, rest, ok := strings.Cut(line, ": flags=")
+		if !ok {
+			continue
+		}
+		flags := ""
+		if i, j := strings.IndexByte(rest, '<'), strings.IndexByte(rest, '>'); i >= 0 && j > i {
+			flags = rest[i+1 : j]
+		}
+		promisc := false
+		for _, fl := range strings.Split(flags, ",") {
+			promisc = promisc || fl == "PROMISC"
+		}
+		current = name
+		res[name] = NetInterface{Promiscuous: boolPtr(promisc), Kind: kindFromName(name)}
+	}
+	for member, master := range masters {
+		if d, ok := res[member]; ok {
+			d.Master = master
+			res[member] = d
+		}
+	}
+	return res
+}
+
+// kindFromName guesses the kind from conventional interface names when
+// the platform has no better source.
+func kindFromName(name string) string {
+	n := strings.ToLower(name)
+	for _, k := range []struct{ prefix, kind string }{
+		{"lo", "loopback"},
+		{"bridge", "bridge"},
+		{"br", "bridge"},
+		{"virbr", "bridge"},
+		{"docker", "bridge"},
+		{"tap", "tap"},
+		{"utun", "tun"},
+		{"tun", "tun"},
+		{"wg", "wireguard"},
+		{"veth", "veth"},
+		{"vboxnet", "virtual"},
+		{"vmnet", "virtual"},
+		{"vmenet", "virtual"},
+	} {
+		if strings.HasPrefix(n, k.prefix) {
+			return k.kind
+		}
+	}
+	return "physical"
+}
@@ -0,0 +1,12 @@
+package collector
+
+import "os/exec"
+
+// interfaceDetails reads each interface's kind and flags from ifconfig.
+func interfaceDetails() map[string]NetInterface {
+	b, err := exec.Command("ifconfig", "-a").Output()
+	if err != nil {
+		return nil
+	}
+	return parseIfconfig(string(b))
+}
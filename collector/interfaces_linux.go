@@ -0,0 +1,12 @@
+package collector
+
+import "os/exec"
+
+// interfaceDetails reads each link's kind and flags from `ip -d link`.
+func interfaceDetails() map[string]NetInterface {
+	b, err := exec.Command("ip", "-d", "-o", "link", "show").Output()
+	if err != nil {
+		return nil
+	}
+	return parseIPLinkDetails(string(b))
+}
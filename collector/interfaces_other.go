@@ -0,0 +1,6 @@
+//go:build !linux && !darwin && !windows
+
+package collector
+
+// interfaceDetails is not implemented on this platform.
+func interfaceDetails() map[string]NetInterface { return nil }
@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIPLinkDetails(t *testing.T) {
+	out := `1: lo: <LOOPBACK,UP,LOWER_UP> mtu 65536 qdisc noqueue state UNKNOWN\    link/loopback 00:00:00:00:00:00 brd 00:00:00:00:00:00 promiscuity 0  allmulti 0 minmtu 0 maxmtu 0 addrgenmode eui64 numtxqueues 1
+2: eth0: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc fq_codel state UP\    link/ether 02:fc:00:00:00:01 brd ff:ff:ff:ff:ff:ff promiscuity 1  allmulti 0 minmtu 68 maxmtu 65535 addrgenmode eui64 numtxqueues 1 parentbus virtio
+3: docker0: <NO-CARRIER,BROADCAST,MULTICAST,UP> mtu 1500 qdisc noqueue state DOWN\    link/ether 02:42:ac:11:00:01 brd ff:ff:ff:ff:ff:ff promiscuity 0  allmulti 0 minmtu 68 maxmtu 65535 \    bridge forward_delay 1500 hello_time 200 addrgenmode eui64
+4: tap0: <BROADCAST,MULTICAST,PROMISC> mtu 1500 qdisc noop state DOWN\    link/ether 3a:1f:00:00:00:02 brd ff:ff:ff:ff:ff:ff promiscuity 1  allmulti 0 minmtu 68 maxmtu 65521 \    tun type tap pi off vnet_hdr off persist on addrgenmode eui64
+5: veth1a2b@if4: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc noqueue master docker0\    link/ether 6e:00:00:00:00:03 brd ff:ff:ff:ff:ff:ff link-netnsid 0 promiscuity 1  allmulti 0 minmtu 68 maxmtu 65535 \    veth bridge_slave state forwarding addrgenmode eui64
+6: ifb0: <BROADCAST,NOARP> mtu 1500 qdisc noop state DOWN\    link/ether da:ce:73:b1:6b:97 brd ff:ff:ff:ff:ff:ff promiscuity 0  allmulti 0 minmtu 0 maxmtu 0 \    ifb addrgenmode eui64
+`
+	got := parseIPLinkDetails(out)
+	kinds := map[string]string{}
+	for name, d := range got {
+		kinds[name] = d.Kind
+	}
+	assert.Equal(t, map[string]string{
+		"lo": "loopback", "eth0": "physical", "docker0": "bridge",
+		"tap0": "tap", "veth1a2b": "veth", "ifb0": "virtual",
+	}, kinds)
+	require.NotNil(t, got["eth0"].Promiscuous)
+	assert.True(t, *got["eth0"].Promiscuous)
+	assert.False(t, *got["docker0"].Promiscuous)
+	assert.Equal(t, "docker0", got["veth1a2b"].Master)
+}
+
+func TestParseIfconfig(t *testing.T) {
+	out := "lo0: flags=8049<UP,LOOPBACK,RUNNING,MULTICAST> mtu 16384\n" +
+		"\tinet 127.0.0.1 netmask 0xff000000\n" +
+		"en0: flags=8963<UP,BROADCAST,SMART,RUNNING,PROMISC,SIMPLEX,MULTICAST> mtu 1500\n" +
+		"bridge0: flags=8863<UP,BROADCAST,SMART,RUNNING,SIMPLEX,MULTICAST> mtu 1500\n" +
+		"\tmember: en1 flags=3<LEARNING,DISCOVER>\n" +
+		"en1: flags=8963<UP,BROADCAST,SMART,RUNNING,PROMISC,SIMPLEX,MULTICAST> mtu 1500\n"
+	got := parseIfconfig(out)
+	require.Len(t, got, 4)
+	assert.Equal(t, "bridge0", got["en1"].Master)
+	assert.True(t, *got["en0"].Promiscuous)
+	assert.False(t, *got["lo0"].Promiscuous)
+	assert.Equal(t, "bridge", got["bridge0"].Kind)
+	assert.Equal(t, "physical", got["en0"].Kind)
+}
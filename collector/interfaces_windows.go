@@ -0,0 +1,24 @@
+//go:build windows
+
+package collector
+
+// interfaceDetails reads each adapter's description, whether it is
+// virtual and its promiscuous mode.
+func interfaceDetails() map[string]NetInterface {
+	rows, err := runPowerShellJSON("Get-NetAdapter -IncludeHidden | Select-Object Name,InterfaceDescription,PromiscuousMode,Virtual | ConvertTo-Json -Compress")
+	if err != nil {
+		return nil
+	}
+	details := map[string]NetInterface{}
+	for _, r := range rows {
+		d := NetInterface{Description: r["InterfaceDescription"], Kind: "physical"}
+		if r["Virtual"] == "true" {
+			d.Kind = "virtual"
+		}
+		if p := r["PromiscuousMode"]; p != "" {
+			d.Promiscuous = boolPtr(p == "true")
+		}
+		details[r["Name"]] = d
+	}
+	return details
+}
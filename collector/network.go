@@ -1,8 +1,6 @@
 package collector
 
 import (
-	"os/exec"
-	"runtime"
 	"strconv"
 	"strings"
 )
@@ -24,25 +22,17 @@ type NetworkInterface struct {
 // CollectNetwork pulls per-interface counters via `netstat -ib` (macOS)
 // or `/proc/net/dev` (Linux). On parse failures, returns whatever was
 // successfully parsed plus a nil error — partial data is better than none.
+// Other platforms report no interfaces.
 func CollectNetwork() (NetworkStats, error) {
-	switch runtime.GOOS {
-	case "darwin":
-		return collectNetworkMacOS()
-	case "linux":
-		return collectNetworkLinux()
-	default:
-		return NetworkStats{}, nil
-	}
+	return collectNetwork()
 }
 
-func collectNetworkMacOS() (NetworkStats, error) {
-	out, err := exec.Command("netstat", "-ib").Output()
-	if err != nil {
-		return NetworkStats{}, err
-	}
+// parseNetstatIB parses macOS `netstat -ib`, keeping the first (link
+// layer) row of each interface.
+func parseNetstatIB(out string) NetworkStats {
 	var stats NetworkStats
 	seen := map[string]bool{}
-	lines := strings.Split(string(out), "\n")
+	lines := strings.Split(out, "\n")
 	if len(lines) > 0 {
 		lines = lines[1:] // skip header
 	}
@@ -64,16 +54,13 @@ func collectNetworkMacOS() (NetworkStats, error) {
 			Name: name, RXBytes: rxb, TXBytes: txb, RXPkts: rxp, TXPkts: txp,
 		})
 	}
-	return stats, nil
+	return stats
 }
 
-func collectNetworkLinux() (NetworkStats, error) {
-	out, err := exec.Command("cat", "/proc/net/dev").Output()
-	if err != nil {
-		return NetworkStats{}, err
-	}
+// parseProcNetDev parses Linux /proc/net/dev.
+func parseProcNetDev(out string) NetworkStats {
 	var stats NetworkStats
-	lines := strings.Split(string(out), "\n")
+	lines := strings.Split(out, "\n")
 	for i, line := range lines {
 		if i < 2 {
 			continue
@@ -95,5 +82,5 @@ func collectNetworkLinux() (NetworkStats, error) {
 			Name: name, RXBytes: rxb, TXBytes: txb, RXPkts: rxp, TXPkts: txp,
 		})
 	}
-	return stats, nil
+	return stats
 }
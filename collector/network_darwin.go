@@ -0,0 +1,11 @@
+package collector
+
+import "os/exec"
+
+func collectNetwork() (NetworkStats, error) {
+	out, err := exec.Command("netstat", "-ib").Output()
+	if err != nil {
+		return NetworkStats{}, err
+	}
+	return parseNetstatIB(string(out)), nil
+}
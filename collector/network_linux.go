@@ -0,0 +1,11 @@
+package collector
+
+import "os"
+
+func collectNetwork() (NetworkStats, error) {
+	b, err := os.ReadFile("/proc/net/dev")
+	if err != nil {
+		return NetworkStats{}, err
+	}
+	return parseProcNetDev(string(b)), nil
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !darwin
+
+package collector
+
+func collectNetwork() (NetworkStats, error) {
+	return NetworkStats{}, nil
+}
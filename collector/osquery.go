@@ -1,70 +1,145 @@
 package collector
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
-	"path/filepath"
-	"runtime"
+	"sync"
 	"time"
 
-	osquery "github.com/osquery/osquery-go"
+	"compliance-agent/logging"
 )
 
+// logger is the collectors' log.
+func logger() *slog.Logger {
+	return logging.Component("collector")
+}
+
 // OSQueryCollector connects to osquery and runs SQL queries to collect data.
 type OSQueryCollector struct {
 	SocketPath string
-	Timeout    time.Duration
+	// Timeout caps each read or write on the socket, a backstop for a
+	// daemon that stops answering. Whole queries are bounded by the
+	// caller's context, so it only needs to outlast the slowest query.
+	Timeout time.Duration
+	// Daemon configures the osqueryd started when none is running.
+	Daemon DaemonOptions
+	// Unprivileged forbids starting or installing osqueryd (both need
+	// root); the collector only attaches to a daemon that's already up.
+	Unprivileged bool
+
+	// daemon is set when this collector launched osqueryd itself; it
+	// supervises the child and is torn down by Close.
+	daemon *daemonSupervisor
+
+	mu     sync.Mutex // guards client and info; the thrift client isn't goroutine-safe
+	client osqueryConn
+	info   *OSQueryInfo
+}
+
+// osqueryConn is a connection to osquery's extension manager socket. The
+// thrift client is in osquery_thrift.go; AIX has none.
+type osqueryConn interface {
+	QueryContext(ctx context.Context, sql string) (*osqueryResponse, error)
+	Close()
+}
+
+// osqueryResponse is a query's rows and osquery's status for it; a
+// non-zero Code is a failed query, not a failed connection.
+type osqueryResponse struct {
+	Rows    []map[string]string
+	Code    int32
+	Message string
 }
 
 // Collector is an interface for system data collection, enabling future extensions.
 type Collector interface {
-	CollectUsers() ([]map[string]string, error)
-	CollectProcesses(limit int) ([]map[string]string, error)
-	CollectOpenPorts() ([]int, error)
-	CollectPackages(limit int) ([]map[string]string, error)
+	CollectUsers(ctx context.Context) ([]User, error)
+	CollectProcesses(ctx context.Context, limit int) ([]Process, error)
+	CollectOpenPorts(ctx context.Context) ([]PortBinding, error)
+	CollectConnections(ctx context.Context, limit int) ([]Connection, error)
+	CollectPackages(ctx context.Context, limit int) ([]Package, error)
 }
 
 func NewOSQueryCollector() *OSQueryCollector {
 	socket := os.Getenv("OSQUERY_SOCKET")
 	if socket == "" {
-		// Common default on macOS/Linux when using osqueryd
-		socket = "/var/osquery/osquery.em"
+		// Unix socket on macOS/Linux, named pipe on Windows
+		socket = defaultSocketPath()
 	}
-	return &OSQueryCollector{SocketPath: socket, Timeout: 5 * time.Second}
+	return &OSQueryCollector{SocketPath: socket, Timeout: 5 * time.Minute, Daemon: DefaultDaemonOptions()}
 }
 
+// checkTimeout bounds the startup health check and version lookup, which
+// run before any scan context exists.
+const checkTimeout = 5 * time.Second
+
 // EnsureOSQueryRunning checks if osquery is running and starts it if needed
 func (c *OSQueryCollector) EnsureOSQueryRunning() error {
+	// Never talk to a socket another user could have planted or hijacked.
+	if err := checkSocketPermissions(c.SocketPath); err != nil {
+		return err
+	}
+
 	// First check if socket exists and is responsive
 	if err := c.HealthCheck(); err == nil {
+		c.logVersion()
 		return nil // Already running
 	}
 
-	fmt.Println("osquery not running, attempting to start...")
+	if c.Unprivileged {
+		return fmt.Errorf("osquery not running and user mode may not start it")
+	}
+
+	logger().Info("osquery not running, starting osqueryd")
 
 	// Try to start osquery daemon
 	if err := c.startOSQueryDaemon(); err != nil {
-		fmt.Printf("Failed to start osquery: %v\n", err)
-		fmt.Println("Falling back to basic system collection...")
+		logger().Warn("osqueryd failed to start", "err", err)
 		return fmt.Errorf("osquery unavailable: %w", err)
 	}
 
 	// Wait a moment for daemon to start
 	time.Sleep(2 * time.Second)
+	if err := checkSocketPermissions(c.SocketPath); err != nil {
+		c.Close()
+		return err
+	}
 
 	// Verify it's now running
 	if err := c.HealthCheck(); err != nil {
+		c.Close()
 		return fmt.Errorf("osquery failed to start properly: %w", err)
 	}
 
-	fmt.Println("osquery started successfully!")
+	if err := c.VerifyDaemonFlags(daemonStateDir(c.SocketPath)); err != nil {
+		logger().Warn("osqueryd flags", "err", err)
+	}
+
+	logger().Info("osqueryd started", "socket", c.SocketPath)
+	c.logVersion()
 	return nil
 }
 
+// logVersion detects the daemon version at startup so unsupported builds
+// are called out up front rather than through mysterious query failures.
+func (c *OSQueryCollector) logVersion() {
+	info, err := c.Info()
+	if err != nil {
+		logger().Warn("could not detect the osquery version", "err", err)
+		return
+	}
+	if !info.Supported {
+		logger().Warn("osquery is older than the minimum supported; some queries may fail", "version", info.Version, "minimum", minSupportedOSQuery)
+	}
+}
+
 func (c *OSQueryCollector) startOSQueryDaemon() error {
-	// Create socket directory
-	socketDir := filepath.Dir(c.SocketPath)
+	// Create the daemon state directory (next to the socket, or under
+	// ProgramData for a Windows named pipe)
+	socketDir := daemonStateDir(c.SocketPath)
 	if err := os.MkdirAll(socketDir, 0755); err != nil {
 		return fmt.Errorf("failed to create socket directory: %w", err)
 	}
@@ -75,33 +150,37 @@ func (c *OSQueryCollector) startOSQueryDaemon() error {
 		return fmt.Errorf("osquery not found: %w", err)
 	}
 
-	// Start osquery daemon
-	cmd := exec.Command(osqueryPath,
-		"--ephemeral",
-		"--disable_database",
-		"--tls_disable=true",
-		"--extensions_socket="+c.SocketPath,
-		"--pidfile="+filepath.Join(socketDir, "osqueryd.pid"),
-		"--logger_path="+socketDir,
-		"--config_path="+filepath.Join(socketDir, "osquery.conf"),
-	)
+	files, err := c.Daemon.writeDaemonConfig(c.SocketPath, socketDir)
+	if err != nil {
+		return err
+	}
 
-	// Start in background
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start osquery daemon: %w", err)
+	// Start osquery daemon under supervision so it is restarted if it
+	// dies and cleaned up when the agent exits.
+	d := newDaemonSupervisor(osqueryPath, []string{"--flagfile=" + files.Flagfile}, c.SocketPath, files.Pidfile)
+	if err := d.Start(); err != nil {
+		return err
 	}
+	c.daemon = d
+	return nil
+}
 
+// Close releases the osquery connection and stops the osqueryd process
+// this collector started, if any.
+func (c *OSQueryCollector) Close() error {
+	c.mu.Lock()
+	c.closeClientLocked()
+	c.mu.Unlock()
+	if c.daemon != nil {
+		c.daemon.Stop()
+		c.daemon = nil
+	}
 	return nil
 }
 
 func (c *OSQueryCollector) findOSQueryBinary() (string, error) {
 	// Check common locations
-	paths := []string{
-		"/usr/local/bin/osqueryd",
-		"/usr/bin/osqueryd",
-		"/opt/homebrew/bin/osqueryd",
-		"/usr/local/opt/osquery/bin/osqueryd",
-	}
+	paths := osquerydPaths()
 
 	// Also check PATH
 	if path, err := exec.LookPath("osqueryd"); err == nil {
@@ -115,145 +194,251 @@ func (c *OSQueryCollector) findOSQueryBinary() (string, error) {
 	}
 
 	// Try to install osquery
-	return c.installOSQuery()
+	logger().Info("osqueryd not found, installing osquery")
+	return installOSQuery()
 }
 
-func (c *OSQueryCollector) installOSQuery() (string, error) {
-	fmt.Println("osquery not found, attempting to install...")
-
-	switch runtime.GOOS {
-	case "darwin":
-		return c.installOSQueryMacOS()
-	case "linux":
-		return c.installOSQueryLinux()
-	default:
-		return "", fmt.Errorf("unsupported OS: %s", runtime.GOOS)
+// query runs SQL on the shared connection. The thrift client can't be
+// interrupted mid-call, so when ctx ends first the connection is closed
+// to unblock it; the next query dials a fresh one.
+func (c *OSQueryCollector) query(ctx context.Context, query string) ([]map[string]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-}
 
-func (c *OSQueryCollector) installOSQueryMacOS() (string, error) {
-	// Try Homebrew
-	if _, err := exec.LookPath("brew"); err == nil {
-		fmt.Println("Installing osquery via Homebrew...")
-		cmd := exec.Command("brew", "install", "osquery")
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return "", fmt.Errorf("homebrew install failed: %w", err)
+	client, err := c.connectLocked()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.runLocked(ctx, client, query)
+	if err != nil && ctx.Err() == nil {
+		// The daemon may have restarted since the connection was opened;
+		// drop it and retry once on a fresh one.
+		c.closeClientLocked()
+		if client, err = c.connectLocked(); err != nil {
+			return nil, err
+		}
+		if resp, err = c.runLocked(ctx, client, query); err != nil && ctx.Err() == nil {
+			c.closeClientLocked()
+			return nil, fmt.Errorf("osquery query failed: %w", err)
 		}
-		return "/opt/homebrew/bin/osqueryd", nil
 	}
-	return "", fmt.Errorf("homebrew not available")
+	if err != nil {
+		return nil, err
+	}
+	if resp.Code != 0 {
+		return nil, fmt.Errorf("osquery error code %d: %s", resp.Code, resp.Message)
+	}
+	return resp.Rows, nil
 }
 
-func (c *OSQueryCollector) installOSQueryLinux() (string, error) {
-	// Try apt (Ubuntu/Debian)
-	if _, err := exec.LookPath("apt"); err == nil {
-		fmt.Println("Installing osquery via apt...")
-		cmd := exec.Command("sudo", "apt", "update")
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return "", fmt.Errorf("apt update failed: %w", err)
-		}
+// runLocked issues one query, abandoning the connection if ctx is done
+// first. Callers must hold c.mu.
+func (c *OSQueryCollector) runLocked(ctx context.Context, client osqueryConn, query string) (*osqueryResponse, error) {
+	type result struct {
+		resp *osqueryResponse
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := client.QueryContext(ctx, query)
+		done <- result{resp, err}
+	}()
+	select {
+	case r := <-done:
+		return r.resp, r.err
+	case <-ctx.Done():
+		c.closeClientLocked()
+		<-done
+		return nil, ctx.Err()
+	}
+}
 
-		cmd = exec.Command("sudo", "apt", "install", "-y", "osquery")
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return "", fmt.Errorf("apt install failed: %w", err)
-		}
-		return "/usr/bin/osqueryd", nil
+// connectLocked returns the shared extension client, dialing it on first
+// use. Reusing one connection keeps daemon mode from reconnecting to the
+// socket for every query on every interval. Callers must hold c.mu.
+func (c *OSQueryCollector) connectLocked() (osqueryConn, error) {
+	if c.client != nil {
+		return c.client, nil
+	}
+	// The transport waits up to the timeout for a missing Unix socket to
+	// appear; fail fast instead; the daemon is started before querying.
+	if err := checkSocket(c.SocketPath); err != nil {
+		return nil, fmt.Errorf("failed to create osquery client: %w", err)
+	}
+	client, err := dialOSQuery(c.SocketPath, c.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create osquery client: %w", err)
 	}
+	c.client = client
+	return client, nil
+}
 
-	// Try yum (RHEL/CentOS)
-	if _, err := exec.LookPath("yum"); err == nil {
-		fmt.Println("Installing osquery via yum...")
-		cmd := exec.Command("sudo", "yum", "install", "-y", "osquery")
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return "", fmt.Errorf("yum install failed: %w", err)
-		}
-		return "/usr/bin/osqueryd", nil
+func (c *OSQueryCollector) closeClientLocked() {
+	if c.client != nil {
+		c.client.Close()
+		c.client = nil
 	}
+}
 
-	return "", fmt.Errorf("no package manager found (apt/yum)")
+// Info returns the running daemon's version and platform, querying
+// osquery_info on first use.
+func (c *OSQueryCollector) Info() (OSQueryInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	return c.loadInfo(ctx)
 }
 
-func (c *OSQueryCollector) query(query string) ([]map[string]string, error) {
-	client, err := osquery.NewClient(c.SocketPath, c.Timeout)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create osquery client: %w", err)
+func (c *OSQueryCollector) loadInfo(ctx context.Context) (OSQueryInfo, error) {
+	c.mu.Lock()
+	cached := c.info
+	c.mu.Unlock()
+	if cached != nil {
+		return *cached, nil
 	}
-	defer client.Close()
 
-	resp, err := client.Query(query)
+	rows, err := c.query(ctx, "SELECT version, build_platform, build_distro FROM osquery_info;")
 	if err != nil {
-		return nil, fmt.Errorf("osquery query failed: %w", err)
+		return OSQueryInfo{}, fmt.Errorf("read osquery_info: %w", err)
 	}
-	if resp.Status != nil && resp.Status.Code != 0 {
-		return nil, fmt.Errorf("osquery error code %d: %s", resp.Status.Code, resp.Status.Message)
+	if len(rows) == 0 {
+		return OSQueryInfo{}, fmt.Errorf("osquery_info returned no rows")
 	}
-	return resp.Response, nil
+	info := OSQueryInfo{
+		Version:       rows[0]["version"],
+		BuildPlatform: rows[0]["build_platform"],
+		BuildDistro:   rows[0]["build_distro"],
+	}
+	info.Supported = CompareVersions(info.Version, minSupportedOSQuery) >= 0
+	c.mu.Lock()
+	c.info = &info
+	c.mu.Unlock()
+	return info, nil
+}
+
+// compatQuery renders the SQL for a logical query that suits the running
+// daemon. If the version can't be read, unconstrained variants are used.
+func (c *OSQueryCollector) compatQuery(ctx context.Context, name string, limit int) ([]map[string]string, error) {
+	info, _ := c.loadInfo(ctx)
+	return runCompat(ctx, c.query, info, name, limit)
 }
 
 // CollectUsers returns local system users from the users table.
-func (c *OSQueryCollector) CollectUsers() ([]map[string]string, error) {
-	const q = "SELECT username, uid, gid, description, directory, shell FROM users;"
-	return c.query(q)
+func (c *OSQueryCollector) CollectUsers(ctx context.Context) ([]User, error) {
+	rows, err := c.compatQuery(ctx, "users", 0)
+	if err != nil {
+		return nil, err
+	}
+	return usersFromRows(rows), nil
 }
 
 // CollectProcesses returns a subset of processes.
-func (c *OSQueryCollector) CollectProcesses(limit int) ([]map[string]string, error) {
+func (c *OSQueryCollector) CollectProcesses(ctx context.Context, limit int) ([]Process, error) {
 	if limit <= 0 {
 		limit = 50
 	}
-	q := fmt.Sprintf("SELECT pid, name, path, cmdline, uid FROM processes LIMIT %d;", limit)
-	return c.query(q)
+	rows, err := c.compatQuery(ctx, "processes", limit)
+	if err != nil {
+		return nil, err
+	}
+	return processesFromRows(rows), nil
 }
 
 // CollectOpenPorts returns listening TCP/UDP ports using osquery listening_ports table.
-func (c *OSQueryCollector) CollectOpenPorts() ([]int, error) {
-	rows, err := c.query("SELECT port FROM listening_ports WHERE address != '::' AND port > 0;")
+func (c *OSQueryCollector) CollectOpenPorts(ctx context.Context) ([]PortBinding, error) {
+	rows, err := c.compatQuery(ctx, "listening_ports", 0)
 	if err != nil {
 		return nil, err
 	}
-	ports := make([]int, 0, len(rows))
-	for _, r := range rows {
-		var p int
-		// osquery returns strings; safe parse
-		fmt.Sscanf(r["port"], "%d", &p)
-		if p > 0 {
-			ports = append(ports, p)
-		}
+	return portsFromRows(rows), nil
+}
+
+// CollectConnections returns established connections to remote hosts
+// from process_open_sockets.
+func (c *OSQueryCollector) CollectConnections(ctx context.Context, limit int) ([]Connection, error) {
+	if limit <= 0 {
+		limit = 1000
+	}
+	rows, err := c.compatQuery(ctx, "connections", limit)
+	if err != nil {
+		return nil, err
 	}
-	return ports, nil
+	return connectionsFromRows(rows), nil
 }
 
-// CollectPackages tries osquery packages table.
-func (c *OSQueryCollector) CollectPackages(limit int) ([]map[string]string, error) {
+// CollectPackages reads the platform's package table (deb/rpm, homebrew,
+// programs) as selected by the compatibility table.
+func (c *OSQueryCollector) CollectPackages(ctx context.Context, limit int) ([]Package, error) {
 	if limit <= 0 {
 		limit = 100
 	}
-	q := fmt.Sprintf("SELECT name, version, source, arch FROM packages LIMIT %d;", limit)
-	return c.query(q)
+	rows, err := c.compatQuery(ctx, "packages", limit)
+	if err != nil {
+		return nil, err
+	}
+	return packagesFromRows(rows), nil
 }
 
-// HealthCheck ensures the socket is reachable by issuing a trivial distributed ping.
-func (c *OSQueryCollector) HealthCheck() error {
-	client, err := osquery.NewClient(c.SocketPath, c.Timeout)
+// CollectDiskEncryption reads disk_encryption (macOS) or bitlocker_info
+// (Windows). Where osquery has no usable table the daemon is local, so
+// the native commands are used instead.
+func (c *OSQueryCollector) CollectDiskEncryption(ctx context.Context) ([]DiskVolume, error) {
+	info, err := c.loadInfo(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create osquery client: %w", err)
+		return nil, err
 	}
-	defer client.Close()
-	// Lightweight connectivity check: trivial query
-	resp, err := client.Query("SELECT 1 as ok;")
+	if _, err := compatSQL("disk_encryption", info); err != nil {
+		return CollectDiskEncryption(ctx)
+	}
+	rows, err := c.compatQuery(ctx, "disk_encryption", 0)
+	if err != nil {
+		return nil, err
+	}
+	vols := volumesFromRows(rows)
+	return vols, checkBootVolume(vols)
+}
+
+// CollectOSVersion reads os_version and kernel_info.
+func (c *OSQueryCollector) CollectOSVersion(ctx context.Context) (*OSVersion, error) {
+	rows, err := c.compatQuery(ctx, "os_version", 0)
+	if err != nil {
+		return nil, err
+	}
+	return osVersionFromRows(rows)
+}
+
+// CollectHardware reads the machine's identifiers from system_info. The
+// daemon is local, so the Linux machine-id is read from disk.
+func (c *OSQueryCollector) CollectHardware(ctx context.Context) (*HardwareInfo, error) {
+	rows, err := c.compatQuery(ctx, "system_info", 0)
+	if err != nil {
+		return nil, err
+	}
+	h, err := hardwareFromRows(rows)
 	if err != nil {
-		return fmt.Errorf("osquery health query failed: %w", err)
+		return nil, err
+	}
+	// system_info has no machine ID, so Unix hosts add theirs (there is
+	// none on Windows).
+	if id := localMachineID(); id != "" {
+		if h == nil {
+			h = &HardwareInfo{}
+		}
+		h.MachineID = id
+		h = h.clean()
 	}
-	if resp.Status != nil && resp.Status.Code != 0 {
-		return fmt.Errorf("osquery health status %d: %s", resp.Status.Code, resp.Status.Message)
+	return h, nil
+}
+
+// HealthCheck ensures the socket is reachable by issuing a trivial distributed ping.
+func (c *OSQueryCollector) HealthCheck() error {
+	// Lightweight connectivity check: trivial query
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	if _, err := c.query(ctx, "SELECT 1 as ok;"); err != nil {
+		return fmt.Errorf("osquery health check failed: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,31 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// osquerydPaths are where osqueryd is usually installed.
+func osquerydPaths() []string {
+	return []string{
+		"/usr/local/bin/osqueryd",
+		"/opt/homebrew/bin/osqueryd",
+		"/usr/local/opt/osquery/bin/osqueryd",
+	}
+}
+
+// installOSQuery installs osquery with Homebrew.
+func installOSQuery() (string, error) {
+	if _, err := exec.LookPath("brew"); err != nil {
+		return "", fmt.Errorf("homebrew not available")
+	}
+	logger().Info("installing osquery", "with", "homebrew")
+	cmd := exec.Command("brew", "install", "osquery")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("homebrew install failed: %w", err)
+	}
+	return "/opt/homebrew/bin/osqueryd", nil
+}
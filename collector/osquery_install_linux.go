@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// osquerydPaths are where osqueryd is usually installed.
+func osquerydPaths() []string {
+	return []string{"/usr/local/bin/osqueryd", "/usr/bin/osqueryd", "/opt/osquery/bin/osqueryd"}
+}
+
+// installOSQuery installs osquery with apt or yum. osquery ships only
+// glibc builds for x86_64 and aarch64, so on other architectures and on
+// musl distributions (Alpine) it reports why and the agent carries on
+// with the fallback collector.
+func installOSQuery() (string, error) {
+	if runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64" {
+		return "", fmt.Errorf("osquery has no packages for linux/%s", runtime.GOARCH)
+	}
+	if _, err := os.Stat("/etc/alpine-release"); err == nil {
+		return "", fmt.Errorf("osquery has no packages for musl-based Alpine")
+	}
+	run := func(name string, args ...string) error {
+		cmd := exec.Command(name, args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	}
+
+	// Try apt (Ubuntu/Debian)
+	if _, err := exec.LookPath("apt"); err == nil {
+		logger().Info("installing osquery", "with", "apt")
+		if err := run("sudo", "apt", "update"); err != nil {
+			return "", fmt.Errorf("apt update failed: %w", err)
+		}
+		if err := run("sudo", "apt", "install", "-y", "osquery"); err != nil {
+			return "", fmt.Errorf("apt install failed: %w", err)
+		}
+		return "/usr/bin/osqueryd", nil
+	}
+
+	// Try yum (RHEL/CentOS)
+	if _, err := exec.LookPath("yum"); err == nil {
+		logger().Info("installing osquery", "with", "yum")
+		if err := run("sudo", "yum", "install", "-y", "osquery"); err != nil {
+			return "", fmt.Errorf("yum install failed: %w", err)
+		}
+		return "/usr/bin/osqueryd", nil
+	}
+
+	return "", fmt.Errorf("no package manager found (apt/yum)")
+}
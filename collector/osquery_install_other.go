@@ -0,0 +1,19 @@
+//go:build !linux && !darwin && !windows
+
+package collector
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// osquerydPaths are where a locally built or ported osqueryd may live.
+func osquerydPaths() []string {
+	return []string{"/usr/local/bin/osqueryd", "/usr/local/sbin/osqueryd"}
+}
+
+// installOSQuery can't install osquery here: there are no official
+// packages.
+func installOSQuery() (string, error) {
+	return "", fmt.Errorf("unsupported OS: %s", runtime.GOOS)
+}
@@ -0,0 +1,27 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// osquerydPaths is where the official MSI installs osqueryd.
+func osquerydPaths() []string {
+	return []string{`C:\Program Files\osquery\osqueryd\osqueryd.exe`}
+}
+
+// installOSQuery installs osquery with Chocolatey.
+func installOSQuery() (string, error) {
+	if _, err := exec.LookPath("choco"); err != nil {
+		return "", fmt.Errorf("chocolatey not available")
+	}
+	logger().Info("installing osquery", "with", "chocolatey")
+	cmd := exec.Command("choco", "install", "osquery", "-y")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("choco install failed: %w", err)
+	}
+	return `C:\Program Files\osquery\osqueryd\osqueryd.exe`, nil
+}
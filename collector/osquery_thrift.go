@@ -0,0 +1,38 @@
+//go:build !aix
+
+package collector
+
+import (
+	"context"
+	"time"
+
+	osquery "github.com/osquery/osquery-go"
+)
+
+// thriftConn adapts the osquery-go thrift client, which doesn't build
+// on AIX.
+type thriftConn struct {
+	client *osquery.ExtensionManagerClient
+}
+
+func dialOSQuery(socket string, timeout time.Duration) (osqueryConn, error) {
+	client, err := osquery.NewClient(socket, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return thriftConn{client}, nil
+}
+
+func (t thriftConn) QueryContext(ctx context.Context, sql string) (*osqueryResponse, error) {
+	resp, err := t.client.QueryContext(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	out := &osqueryResponse{Rows: resp.Response}
+	if resp.Status != nil {
+		out.Code, out.Message = resp.Status.Code, resp.Status.Message
+	}
+	return out, nil
+}
+
+func (t thriftConn) Close() { t.client.Close() }
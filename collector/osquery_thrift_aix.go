@@ -0,0 +1,10 @@
+package collector
+
+import (
+	"errors"
+	"time"
+)
+
+func dialOSQuery(string, time.Duration) (osqueryConn, error) {
+	return nil, errors.New("osquery is not supported on aix")
+}
@@ -0,0 +1,165 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DaemonOptions tunes the osqueryd the agent launches when none is
+// running. They are rendered into a flagfile and osquery.conf next to the
+// extension socket, so an operator can inspect exactly what was started.
+type DaemonOptions struct {
+	// Watchdog limits. WatchdogLevel is osquery's own scale: 0 normal,
+	// 1 restrictive, -1 disabled.
+	WatchdogLevel            int
+	WatchdogMemoryLimitMB    int
+	WatchdogUtilizationLimit int // percent CPU
+
+	DisableEvents bool
+	EventsExpiry  int // seconds
+	EventsMax     int
+
+	LoggerPlugin string // e.g. "filesystem", "syslog", "stdout"
+
+	// ExtraFlags are passed through verbatim (name without leading "--").
+	ExtraFlags map[string]string
+}
+
+// DefaultDaemonOptions keeps the spawned daemon light: the agent only runs
+// ad-hoc queries, so events are off and the watchdog is strict.
+func DefaultDaemonOptions() DaemonOptions {
+	return DaemonOptions{
+		WatchdogLevel:            0,
+		WatchdogMemoryLimitMB:    200,
+		WatchdogUtilizationLimit: 10,
+		DisableEvents:            true,
+		EventsExpiry:             3600,
+		EventsMax:                50000,
+		LoggerPlugin:             "filesystem",
+	}
+}
+
+// daemonFiles are the paths of the generated configuration.
+type daemonFiles struct {
+	Flagfile string
+	Config   string
+	Pidfile  string
+}
+
+// flags returns the complete flag set for a daemon serving socketPath
+// with its state in dir.
+func (o DaemonOptions) flags(socketPath, dir string) map[string]string {
+	f := map[string]string{
+		"ephemeral":                  "true",
+		"disable_database":           "true",
+		"extensions_socket":          socketPath,
+		"pidfile":                    filepath.Join(dir, "osqueryd.pid"),
+		"config_plugin":              "filesystem",
+		"config_path":                filepath.Join(dir, "osquery.conf"),
+		"logger_plugin":              o.LoggerPlugin,
+		"logger_path":                dir,
+		"watchdog_level":             strconv.Itoa(o.WatchdogLevel),
+		"watchdog_memory_limit":      strconv.Itoa(o.WatchdogMemoryLimitMB),
+		"watchdog_utilization_limit": strconv.Itoa(o.WatchdogUtilizationLimit),
+		"disable_events":             strconv.FormatBool(o.DisableEvents),
+		"events_expiry":              strconv.Itoa(o.EventsExpiry),
+		"events_max":                 strconv.Itoa(o.EventsMax),
+	}
+	if o.LoggerPlugin == "" {
+		f["logger_plugin"] = "filesystem"
+	}
+	for k, v := range o.ExtraFlags {
+		f[strings.TrimLeft(k, "-")] = v
+	}
+	return f
+}
+
+// writeDaemonConfig renders the flagfile and osquery.conf into dir. The
+// config file only carries options (no schedule): the agent drives all
+// queries itself over the extension socket.
+func (o DaemonOptions) writeDaemonConfig(socketPath, dir string) (daemonFiles, error) {
+	flags := o.flags(socketPath, dir)
+	files := daemonFiles{
+		Flagfile: filepath.Join(dir, "osquery.flags"),
+		Config:   flags["config_path"],
+		Pidfile:  flags["pidfile"],
+	}
+
+	names := make([]string, 0, len(flags))
+	for k := range flags {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString("# Generated by compliance-agent; edits are overwritten on restart.\n")
+	for _, k := range names {
+		fmt.Fprintf(&b, "--%s=%s\n", k, flags[k])
+	}
+	if err := os.WriteFile(files.Flagfile, []byte(b.String()), 0o600); err != nil {
+		return files, fmt.Errorf("write osquery flagfile: %w", err)
+	}
+
+	conf := map[string]any{
+		"options": map[string]any{
+			"disable_events": o.DisableEvents,
+			"events_expiry":  o.EventsExpiry,
+			"events_max":     o.EventsMax,
+		},
+		"schedule": map[string]any{},
+	}
+	cb, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return files, err
+	}
+	if err := os.WriteFile(files.Config, cb, 0o600); err != nil {
+		return files, fmt.Errorf("write osquery config: %w", err)
+	}
+	return files, nil
+}
+
+// verifiedFlags are the settings worth confirming on the running daemon;
+// path-like flags are skipped because osquery may canonicalise them.
+var verifiedFlags = []string{
+	"watchdog_level",
+	"watchdog_memory_limit",
+	"watchdog_utilization_limit",
+	"disable_events",
+	"logger_plugin",
+}
+
+// VerifyDaemonFlags checks via the osquery_flags table that the running
+// daemon actually picked up the generated settings. It returns an error
+// listing every mismatch.
+func (c *OSQueryCollector) VerifyDaemonFlags(socketDir string) error {
+	want := c.Daemon.flags(c.SocketPath, socketDir)
+	quoted := make([]string, len(verifiedFlags))
+	for i, n := range verifiedFlags {
+		quoted[i] = "'" + n + "'"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	rows, err := c.query(ctx, "SELECT name, value FROM osquery_flags WHERE name IN ("+strings.Join(quoted, ", ")+");")
+	if err != nil {
+		return fmt.Errorf("read osquery_flags: %w", err)
+	}
+	got := map[string]string{}
+	for _, r := range rows {
+		got[r["name"]] = r["value"]
+	}
+	var mismatches []string
+	for _, n := range verifiedFlags {
+		if got[n] != want[n] {
+			mismatches = append(mismatches, fmt.Sprintf("%s=%q (want %q)", n, got[n], want[n]))
+		}
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("osqueryd ignored generated flags: %s", strings.Join(mismatches, ", "))
+	}
+	return nil
+}
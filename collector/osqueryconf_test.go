@@ -0,0 +1,34 @@
+package collector
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteDaemonConfig(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultDaemonOptions()
+	opts.WatchdogMemoryLimitMB = 350
+	opts.ExtraFlags = map[string]string{"--host_identifier": "uuid"}
+
+	files, err := opts.writeDaemonConfig(filepath.Join(dir, "osquery.em"), dir)
+	require.NoError(t, err)
+
+	flags, err := os.ReadFile(files.Flagfile)
+	require.NoError(t, err)
+	assert.Contains(t, string(flags), "--watchdog_memory_limit=350\n")
+	assert.Contains(t, string(flags), "--disable_events=true\n")
+	assert.Contains(t, string(flags), "--host_identifier=uuid\n")
+	assert.Contains(t, string(flags), "--config_path="+files.Config+"\n")
+
+	var conf map[string]map[string]any
+	b, err := os.ReadFile(files.Config)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &conf))
+	assert.Equal(t, true, conf["options"]["disable_events"])
+}
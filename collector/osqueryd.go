@@ -0,0 +1,220 @@
+package collector
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for the osqueryd watchdog. A daemon that dies more than
+// defaultMaxRestarts times in a row is left down and the collector keeps
+// reporting health-check failures rather than flapping forever.
+const (
+	defaultMaxRestarts    = 5
+	defaultRestartBackoff = 2 * time.Second
+	maxRestartBackoff     = time.Minute
+	// A daemon that stayed up this long is considered healthy again and
+	// its restart counter is reset.
+	stableRunDuration = 5 * time.Minute
+)
+
+// errSupervisorStopped is returned by Start after Stop has been called.
+var errSupervisorStopped = errors.New("osqueryd supervisor stopped")
+
+// daemonSupervisor owns an osqueryd child process: it cleans up stale
+// sockets/pidfiles before launch, forwards the daemon's stderr into the
+// agent log, and restarts it with exponential backoff when it exits.
+type daemonSupervisor struct {
+	binary      string
+	args        []string
+	socketPath  string
+	pidfile     string
+	maxRestarts int
+	backoff     time.Duration
+
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	restarts int
+	stopped  bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newDaemonSupervisor(binary string, args []string, socketPath, pidfile string) *daemonSupervisor {
+	return &daemonSupervisor{
+		binary:      binary,
+		args:        args,
+		socketPath:  socketPath,
+		pidfile:     pidfile,
+		maxRestarts: defaultMaxRestarts,
+		backoff:     defaultRestartBackoff,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Start launches the daemon and the watch loop. It returns once the first
+// process has been spawned; later exits are handled in the background.
+func (d *daemonSupervisor) Start() error {
+	d.cleanStale()
+	cmd, err := d.spawn()
+	if err != nil {
+		return err
+	}
+	go d.watch(cmd)
+	return nil
+}
+
+// Stop terminates the daemon and waits for the watch loop to exit. The
+// pidfile and socket are removed so the next agent run starts clean.
+func (d *daemonSupervisor) Stop() {
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return
+	}
+	d.stopped = true
+	close(d.stop)
+	cmd := d.cmd
+	d.mu.Unlock()
+
+	if cmd != nil && cmd.Process != nil {
+		_ = terminateProcess(cmd.Process)
+		select {
+		case <-d.done:
+		case <-time.After(5 * time.Second):
+			_ = cmd.Process.Kill()
+			<-d.done
+		}
+	}
+	d.removeArtifacts()
+}
+
+// Restarts reports how many times the daemon has been relaunched.
+func (d *daemonSupervisor) Restarts() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.restarts
+}
+
+func (d *daemonSupervisor) spawn() (*exec.Cmd, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped {
+		return nil, errSupervisorStopped
+	}
+	cmd := exec.Command(d.binary, d.args...)
+	cmd.Stderr = &logWriter{prefix: "osqueryd"}
+	// osqueryd forks a worker that inherits stderr; don't let Wait block
+	// on the pipe after the watcher process itself has exited.
+	cmd.WaitDelay = time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start osquery daemon: %w", err)
+	}
+	d.cmd = cmd
+	return cmd, nil
+}
+
+func (d *daemonSupervisor) watch(cmd *exec.Cmd) {
+	defer close(d.done)
+	for {
+		started := time.Now()
+		err := cmd.Wait()
+
+		d.mu.Lock()
+		stopped := d.stopped
+		if time.Since(started) >= stableRunDuration {
+			d.restarts = 0
+		}
+		d.restarts++
+		attempt := d.restarts
+		d.mu.Unlock()
+		if stopped {
+			return
+		}
+
+		logger().Warn("osqueryd exited", "err", err)
+		if attempt > d.maxRestarts {
+			logger().Error("osqueryd restarted too often, giving up", "restarts", d.maxRestarts)
+			d.removeArtifacts()
+			return
+		}
+
+		wait := d.backoff << (attempt - 1)
+		if wait > maxRestartBackoff || wait <= 0 {
+			wait = maxRestartBackoff
+		}
+		logger().Info("restarting osqueryd", "in", wait, "attempt", attempt, "max_restarts", d.maxRestarts)
+		select {
+		case <-d.stop:
+			return
+		case <-time.After(wait):
+		}
+
+		d.removeArtifacts()
+		next, err := d.spawn()
+		if err != nil {
+			logger().Error("osqueryd restart failed", "err", err)
+			return
+		}
+		cmd = next
+	}
+}
+
+// cleanStale removes a pidfile whose process is gone, along with the
+// socket it left behind. A live pid is left alone: that daemon isn't ours
+// to kill, and osqueryd will refuse to start over it with a clear error.
+func (d *daemonSupervisor) cleanStale() {
+	b, err := os.ReadFile(d.pidfile)
+	if err != nil {
+		return
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err == nil && processAlive(pid) {
+		logger().Warn("osqueryd pidfile points at a live process", "pidfile", d.pidfile, "pid", pid)
+		return
+	}
+	logger().Info("removing stale osqueryd pidfile", "pidfile", d.pidfile)
+	d.removeArtifacts()
+}
+
+func (d *daemonSupervisor) removeArtifacts() {
+	paths := []string{d.pidfile}
+	// A named pipe vanishes with its server; only Unix sockets linger.
+	if !isNamedPipe(d.socketPath) {
+		paths = append(paths, d.socketPath)
+	}
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger().Warn("osqueryd cleanup", "path", p, "err", err)
+		}
+	}
+}
+
+// logWriter copies a child process's output into the agent log line by
+// line, with the process it came from. exec.Cmd feeds it from a single
+// goroutine, so no locking is needed.
+type logWriter struct {
+	prefix string
+	buf    bytes.Buffer
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		line, err := w.buf.ReadString('\n')
+		if err != nil {
+			// Incomplete line: keep it for the next write.
+			w.buf.Reset()
+			w.buf.WriteString(line)
+			return len(p), nil
+		}
+		logger().Info(strings.TrimRight(line, "\r\n"), "process", w.prefix)
+	}
+}
@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemonSupervisor_CapsRestarts(t *testing.T) {
+	dir := t.TempDir()
+	d := newDaemonSupervisor("/bin/sh", []string{"-c", "echo crashed >&2; exit 1"},
+		filepath.Join(dir, "osquery.em"), filepath.Join(dir, "osqueryd.pid"))
+	d.maxRestarts = 2
+	d.backoff = time.Millisecond
+	require.NoError(t, d.Start())
+
+	select {
+	case <-d.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor did not give up")
+	}
+	assert.Equal(t, 3, d.Restarts())
+	d.Stop()
+}
+
+func TestDaemonSupervisor_StopTerminatesChild(t *testing.T) {
+	dir := t.TempDir()
+	sock := filepath.Join(dir, "osquery.em")
+	require.NoError(t, os.WriteFile(sock, nil, 0o600))
+	d := newDaemonSupervisor("/bin/sh", []string{"-c", "sleep 30"},
+		sock, filepath.Join(dir, "osqueryd.pid"))
+	require.NoError(t, d.Start())
+	d.Stop()
+
+	select {
+	case <-d.done:
+	default:
+		t.Fatal("watch loop still running after Stop")
+	}
+	_, err := os.Stat(sock)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDaemonSupervisor_CleansStalePidfile(t *testing.T) {
+	dir := t.TempDir()
+	sock := filepath.Join(dir, "osquery.em")
+	pid := filepath.Join(dir, "osqueryd.pid")
+	require.NoError(t, os.WriteFile(sock, nil, 0o600))
+	// pid 0 is never a live process we could have started.
+	require.NoError(t, os.WriteFile(pid, []byte("0\n"), 0o600))
+
+	d := newDaemonSupervisor("/bin/true", nil, sock, pid)
+	d.cleanStale()
+	_, err := os.Stat(pid)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(sock)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestIsNamedPipe(t *testing.T) {
+	assert.True(t, isNamedPipe(`\\.\pipe\osquery.em`))
+	assert.False(t, isNamedPipe("/var/osquery/osquery.em"))
+}
@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+)
+
+// OSVersion identifies the operating system release and kernel.
+type OSVersion struct {
+	// Platform is linux, darwin or windows.
+	Platform string `json:"platform"`
+	// ID is the distribution's os-release ID on Linux (ubuntu, debian,
+	// rhel); otherwise the platform.
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Version is the release to its patch level where the OS exposes
+	// it: 22.04.4 on Ubuntu, 12.5 on Debian, 14.4.1 on macOS and
+	// 10.0.22631.3447 (with the update build revision) on Windows.
+	Version string `json:"version"`
+	Build   string `json:"build,omitempty"`
+	Kernel  string `json:"kernel,omitempty"`
+}
+
+// parseOSRelease reads /etc/os-release. Ubuntu's VERSION carries the
+// point release ("22.04.4 LTS (Jammy Jellyfish)") that VERSION_ID drops.
+func parseOSRelease(content string) *OSVersion {
+	kv := map[string]string{}
+	sc := bufio.NewScanner(strings.NewReader(content))
+	for sc.Scan() {
+		k, v, ok := strings.Cut(strings.TrimSpace(sc.Text()), "=")
+		if ok {
+			kv[k] = strings.Trim(v, `"'`)
+		}
+	}
+	v := &OSVersion{Platform: "linux", ID: kv["ID"], Name: kv["NAME"], Version: kv["VERSION_ID"], Build: kv["BUILD_ID"]}
+	if f := strings.Fields(kv["VERSION"]); len(f) > 0 && strings.HasPrefix(f[0], v.Version+".") {
+		v.Version = f[0]
+	}
+	return v
+}
+
+// parseSwVers reads `sw_vers`: "ProductName:\tmacOS" and so on.
+func parseSwVers(out string) *OSVersion {
+	v := &OSVersion{Platform: "darwin", ID: "darwin"}
+	for _, line := range strings.Split(out, "\n") {
+		k, val, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch val = strings.TrimSpace(val); strings.TrimSpace(k) {
+		case "ProductName":
+			v.Name = val
+		case "ProductVersion":
+			v.Version = val
+		case "BuildVersion":
+			v.Build = val
+		}
+	}
+	return v
+}
+
+// windowsVersion appends the update build revision (UBR), which is what
+// changes with each cumulative update, to the kernel version.
+func windowsVersion(caption, version, build, ubr string) *OSVersion {
+	v := &OSVersion{Platform: "windows", ID: "windows", Name: caption, Version: version, Build: build, Kernel: version}
+	if ubr != "" && ubr != "0" {
+		v.Version += "." + ubr
+		v.Build += "." + ubr
+	}
+	return v
+}
+
+// osVersionFromRows parses the os_version query result. osquery's
+// platform column is the distribution ID on Linux.
+func osVersionFromRows(rows []map[string]string) (*OSVersion, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("os_version returned no rows")
+	}
+	r := rows[0]
+	switch id := r["platform"]; id {
+	case "darwin":
+		return &OSVersion{Platform: "darwin", ID: id, Name: r["name"], Version: r["version"], Build: r["build"], Kernel: r["kernel"]}, nil
+	case "windows":
+		return windowsVersion(r["name"], r["version"], r["build"], r["ubr"]), nil
+	default:
+		v := &OSVersion{Platform: "linux", ID: id, Name: r["name"], Version: r["version"], Build: r["build"], Kernel: r["kernel"]}
+		// "22.04.4 LTS (Jammy Jellyfish)"
+		if f := strings.Fields(v.Version); len(f) > 0 {
+			v.Version = f[0]
+		}
+		return v, nil
+	}
+}
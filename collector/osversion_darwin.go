@@ -0,0 +1,21 @@
+package collector
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// CollectOSVersion reads the OS release from sw_vers and the kernel from
+// uname.
+func CollectOSVersion(ctx context.Context) (*OSVersion, error) {
+	out, err := exec.CommandContext(ctx, "sw_vers").Output()
+	if err != nil {
+		return nil, err
+	}
+	v := parseSwVers(string(out))
+	if k, err := exec.CommandContext(ctx, "uname", "-r").Output(); err == nil {
+		v.Kernel = strings.TrimSpace(string(k))
+	}
+	return v, nil
+}
@@ -0,0 +1,30 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+// CollectOSVersion reads the OS release from /etc/os-release and the
+// kernel from /proc.
+func CollectOSVersion(context.Context) (*OSVersion, error) {
+	b, err := os.ReadFile("/etc/os-release")
+	if err != nil {
+		return nil, err
+	}
+	v := parseOSRelease(string(b))
+	if v.ID == "debian" {
+		// os-release only has the major version; the point release
+		// is in debian_version.
+		if dv, err := os.ReadFile("/etc/debian_version"); err == nil {
+			if s := strings.TrimSpace(string(dv)); strings.HasPrefix(s, v.Version+".") {
+				v.Version = s
+			}
+		}
+	}
+	if k, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		v.Kernel = strings.TrimSpace(string(k))
+	}
+	return v, nil
+}
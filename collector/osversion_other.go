@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package collector
+
+import "context"
+
+// CollectOSVersion is not implemented on this platform.
+func CollectOSVersion(context.Context) (*OSVersion, error) { return nil, nil }
@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOSRelease(t *testing.T) {
+	ubuntu := `NAME="Ubuntu"
+VERSION_ID="22.04"
+VERSION="22.04.4 LTS (Jammy Jellyfish)"
+ID=ubuntu
+ID_LIKE=debian
+`
+	assert.Equal(t, &OSVersion{Platform: "linux", ID: "ubuntu", Name: "Ubuntu", Version: "22.04.4"}, parseOSRelease(ubuntu))
+
+	rhel := `NAME="Red Hat Enterprise Linux"
+VERSION="9.3 (Plow)"
+ID="rhel"
+VERSION_ID="9.3"
+`
+	assert.Equal(t, "9.3", parseOSRelease(rhel).Version)
+
+	arch := "NAME=\"Arch Linux\"\nID=arch\nBUILD_ID=rolling\n"
+	assert.Equal(t, &OSVersion{Platform: "linux", ID: "arch", Name: "Arch Linux", Build: "rolling"}, parseOSRelease(arch))
+}
+
+func TestParseSwVers(t *testing.T) {
+	out := "ProductName:\t\tmacOS\nProductVersion:\t\t14.4.1\nBuildVersion:\t\t23E224\n"
+	assert.Equal(t, &OSVersion{Platform: "darwin", ID: "darwin", Name: "macOS", Version: "14.4.1", Build: "23E224"}, parseSwVers(out))
+}
+
+func TestOSVersionFromRows(t *testing.T) {
+	v, err := osVersionFromRows([]map[string]string{{
+		"name": "Microsoft Windows 11 Pro", "version": "10.0.22631", "build": "22631", "platform": "windows", "kernel": "10.0.22631.3447", "ubr": "3447",
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, &OSVersion{Platform: "windows", ID: "windows", Name: "Microsoft Windows 11 Pro", Version: "10.0.22631.3447", Build: "22631.3447", Kernel: "10.0.22631"}, v)
+
+	v, err = osVersionFromRows([]map[string]string{{
+		"name": "Ubuntu", "version": "22.04.4 LTS (Jammy Jellyfish)", "platform": "ubuntu", "kernel": "5.15.0-105-generic",
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, &OSVersion{Platform: "linux", ID: "ubuntu", Name: "Ubuntu", Version: "22.04.4", Kernel: "5.15.0-105-generic"}, v)
+
+	_, err = osVersionFromRows(nil)
+	assert.Error(t, err)
+}
+
+func TestCompatSQL_OSVersion(t *testing.T) {
+	q, err := compatSQL("os_version", OSQueryInfo{Version: "5.12.0", BuildPlatform: "windows"})
+	require.NoError(t, err)
+	assert.Contains(t, q, `CurrentVersion\UBR') AS ubr`)
+	q, err = compatSQL("os_version", OSQueryInfo{Version: "5.12.0", BuildPlatform: "darwin"})
+	require.NoError(t, err)
+	assert.NotContains(t, q, "registry")
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package collector
+
+import (
+	"context"
+	"errors"
+)
+
+// CollectOSVersion reads the OS release from Win32_OperatingSystem and
+// the update build revision from the registry.
+func CollectOSVersion(ctx context.Context) (*OSVersion, error) {
+	rows, err := runPowerShellJSONContext(ctx, "$cv = Get-ItemProperty 'HKLM:\\SOFTWARE\\Microsoft\\Windows NT\\CurrentVersion'; "+
+		"Get-CimInstance Win32_OperatingSystem | Select-Object Caption,Version,BuildNumber,@{n='UBR';e={$cv.UBR}} | ConvertTo-Json -Compress")
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("Win32_OperatingSystem returned nothing")
+	}
+	r := rows[0]
+	return windowsVersion(r["Caption"], r["Version"], r["BuildNumber"], r["UBR"]), nil
+}
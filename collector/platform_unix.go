@@ -0,0 +1,69 @@
+//go:build !windows
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// defaultSocketPath is where osqueryd listens by default on macOS/Linux.
+func defaultSocketPath() string {
+	return "/var/osquery/osquery.em"
+}
+
+// daemonStateDir is where the pidfile, flagfile and logs of a spawned
+// osqueryd live: next to its Unix socket.
+func daemonStateDir(socketPath string) string {
+	return filepath.Dir(socketPath)
+}
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+// terminateProcess asks the process to exit cleanly.
+func terminateProcess(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM)
+}
+
+// HostsFilePath is the platform's hosts file.
+func HostsFilePath() string {
+	return "/etc/hosts"
+}
+
+// checkSocket fails when the Unix socket at path doesn't exist.
+func checkSocket(path string) error {
+	_, err := os.Stat(path)
+	return err
+}
+
+// localMachineID is systemd's (or D-Bus's) machine ID.
+func localMachineID() string {
+	for _, p := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if b, err := os.ReadFile(p); err == nil {
+			return strings.TrimSpace(string(b))
+		}
+	}
+	return ""
+}
+
+// DefaultSSHDConfigPath is where the platform's OpenSSH server keeps its
+// configuration.
+func DefaultSSHDConfigPath() string {
+	return "/etc/ssh/sshd_config"
+}
+
+// sshdPaths are where sshd is installed outside PATH.
+func sshdPaths() []string {
+	return []string{"/usr/sbin/sshd", "/usr/local/sbin/sshd"}
+}
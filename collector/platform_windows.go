@@ -0,0 +1,75 @@
+//go:build windows
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// defaultSocketPath is osqueryd's default extensions named pipe.
+func defaultSocketPath() string {
+	return `\\.\pipe\osquery.em`
+}
+
+// daemonStateDir is where the pidfile, flagfile and logs of a spawned
+// osqueryd live. A named pipe has no directory of its own, so state goes
+// under ProgramData like the official osquery MSI.
+func daemonStateDir(socketPath string) string {
+	if !isNamedPipe(socketPath) {
+		return filepath.Dir(socketPath)
+	}
+	base := os.Getenv("ProgramData")
+	if base == "" {
+		base = `C:\ProgramData`
+	}
+	return filepath.Join(base, "osquery")
+}
+
+// processAlive relies on FindProcess opening a handle on Windows, which
+// fails once the process is gone.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
+
+// terminateProcess kills the process: Windows has no SIGTERM equivalent
+// for console-less children.
+func terminateProcess(p *os.Process) error {
+	return p.Kill()
+}
+
+// HostsFilePath is the platform's hosts file, under %SystemRoot%.
+func HostsFilePath() string {
+	root := os.Getenv("SystemRoot")
+	if root == "" {
+		root = `C:\Windows`
+	}
+	return filepath.Join(root, "System32", "drivers", "etc", "hosts")
+}
+
+// checkSocket has nothing to check: a named pipe can't be stat'ed
+// before it is opened.
+func checkSocket(string) error { return nil }
+
+// localMachineID is "": Windows' machine ID is the MachineGuid, read
+// with the rest of the hardware.
+func localMachineID() string { return "" }
+
+// DefaultSSHDConfigPath is where the Windows OpenSSH server keeps its
+// configuration, under %ProgramData%.
+func DefaultSSHDConfigPath() string {
+	return filepath.Join(os.Getenv("ProgramData"), "ssh", "sshd_config")
+}
+
+// sshdPaths is where the Windows OpenSSH feature installs sshd.
+func sshdPaths() []string {
+	return []string{filepath.Join(os.Getenv("SystemRoot"), "System32", "OpenSSH", "sshd.exe")}
+}
@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PowerSettings is the host's sleep and hibernation configuration. Each
+// setting is nil when it couldn't be determined, so "unknown" isn't
+// reported as a violation.
+type PowerSettings struct {
+	// Laptop is true when the host has an internal battery; the laptop
+	// policy only applies to those.
+	Laptop bool `json:"laptop"`
+	// SleepOnLidClose: closing the lid suspends or hibernates the host.
+	SleepOnLidClose *bool `json:"sleep_on_lid_close"`
+	// PasswordAfterSleep: waking from sleep requires authentication.
+	PasswordAfterSleep *bool `json:"password_after_sleep"`
+	// HibernationEncrypted: the hibernation image lands on encrypted
+	// storage (FileVault, BitLocker, or a dm-crypt swap/resume device).
+	HibernationEncrypted *bool  `json:"hibernation_encrypted"`
+	Source               string `json:"source,omitempty"`
+}
+
+// CollectPowerSettings reads power management settings for the current
+// platform. It never fails; unreadable settings are left nil.
+func CollectPowerSettings() PowerSettings {
+	return collectPowerSettings()
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+// parsePmset turns `pmset -g` output into setting → value.
+func parsePmset(out string) map[string]string {
+	m := map[string]string{}
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && !strings.HasSuffix(fields[0], ":") {
+			m[fields[0]] = fields[1]
+		}
+	}
+	return m
+}
+
+// parseLogindLidSwitch returns the HandleLidSwitch value set in a
+// logind.conf, or "" when it isn't set.
+func parseLogindLidSwitch(conf string) string {
+	action := ""
+	sc := bufio.NewScanner(strings.NewReader(conf))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if k, v, ok := strings.Cut(line, "="); ok && strings.TrimSpace(k) == "HandleLidSwitch" {
+			action = strings.TrimSpace(v)
+		}
+	}
+	return action
+}
+
+func lidActionSleeps(action string) bool {
+	switch action {
+	case "suspend", "hibernate", "hybrid-sleep", "suspend-then-hibernate", "poweroff":
+		return true
+	}
+	return false
+}
+
+// swapsEncrypted reports whether every active swap device, or the device
+// a swapfile lives on, sits on dm-crypt. Hibernation writes to swap, so
+// an unencrypted one leaks memory contents. backing names a swap's block
+// device under sysBlock. nil means no disk swap is configured
+// (hibernation isn't possible) or a swap's device couldn't be found.
+func swapsEncrypted(procSwaps, sysBlock string, backing func(path, kind string) (string, error)) *bool {
+	lines := strings.Split(strings.TrimSpace(procSwaps), "\n")
+	found := false
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		dev, err := backing(fields[0], fields[1])
+		if err != nil {
+			return nil
+		}
+		// zram is compressed memory; hibernation can't write to it.
+		if strings.HasPrefix(dev, "zram") {
+			continue
+		}
+		found = true
+		if !dmCrypt(sysBlock, dev) {
+			return boolPtr(false)
+		}
+	}
+	if !found {
+		return nil
+	}
+	return boolPtr(true)
+}
+
+// dmCrypt reports whether dev is a dm-crypt mapping (its dm uuid starts
+// CRYPT-) or is built on one, such as swap on LVM on LUKS.
+func dmCrypt(sysBlock, dev string) bool {
+	uuid, _ := os.ReadFile(filepath.Join(sysBlock, dev, "dm", "uuid"))
+	if strings.HasPrefix(string(uuid), "CRYPT-") {
+		return true
+	}
+	slaves, _ := os.ReadDir(filepath.Join(sysBlock, dev, "slaves"))
+	for _, s := range slaves {
+		if dmCrypt(sysBlock, s.Name()) {
+			return true
+		}
+	}
+	return false
+}
+
+var powercfgIndex = regexp.MustCompile(`(?i)Current AC Power Setting Index:\s*0x([0-9a-f]+)`)
+
+// parsePowercfgIndex extracts the AC value from `powercfg /query` output.
+func parsePowercfgIndex(out string) (int64, bool) {
+	m := powercfgIndex.FindStringSubmatch(out)
+	if m == nil {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(m[1], 16, 64)
+	return v, err == nil
+}
@@ -0,0 +1,32 @@
+package collector
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// collectPowerSettings reads pmset, the screen lock and FileVault.
+func collectPowerSettings() PowerSettings {
+	ps := PowerSettings{Source: "pmset"}
+	if out, err := exec.Command("pmset", "-g", "batt").Output(); err == nil {
+		ps.Laptop = strings.Contains(string(out), "InternalBattery")
+	}
+	// macOS sleeps on lid close unless sleep has been disabled outright
+	// (`pmset disablesleep 1`, reported as SleepDisabled).
+	if out, err := exec.Command("pmset", "-g").Output(); err == nil {
+		settings := parsePmset(string(out))
+		ps.SleepOnLidClose = boolPtr(settings["SleepDisabled"] != "1")
+	}
+	// The screen lock preference is per user; the agent's own view is
+	// what applies at the login window after wake.
+	if out, err := exec.Command("sysadminctl", "-screenLock", "status").CombinedOutput(); err == nil {
+		s := strings.ToLower(string(out))
+		ps.PasswordAfterSleep = boolPtr(!strings.Contains(s, "screenlock is off"))
+	}
+	// The hibernation image is written to the boot volume, so FileVault
+	// covers it.
+	if out, err := exec.Command("fdesetup", "status").Output(); err == nil {
+		ps.HibernationEncrypted = boolPtr(strings.Contains(string(out), "FileVault is On"))
+	}
+	return ps
+}
@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// collectPowerSettings reads logind's lid switch, GNOME's lock on
+// suspend and the swap devices hibernation writes to.
+func collectPowerSettings() PowerSettings {
+	ps := PowerSettings{Source: "logind"}
+	if bats, _ := filepath.Glob("/sys/class/power_supply/BAT*"); len(bats) > 0 {
+		ps.Laptop = true
+	}
+
+	// logind defaults to suspend; drop-ins override the main file.
+	action := "suspend"
+	confs := []string{"/etc/systemd/logind.conf"}
+	dropins, _ := filepath.Glob("/etc/systemd/logind.conf.d/*.conf")
+	for _, path := range append(confs, dropins...) {
+		if b, err := os.ReadFile(path); err == nil {
+			if a := parseLogindLidSwitch(string(b)); a != "" {
+				action = a
+			}
+		}
+	}
+	ps.SleepOnLidClose = boolPtr(lidActionSleeps(action))
+
+	// gsettings reads the calling user's dconf database. Root's says
+	// nothing about the desktop user's lock screen, so it stays unknown.
+	if os.Geteuid() != 0 {
+		if out, err := exec.Command("gsettings", "get", "org.gnome.desktop.screensaver", "ubuntu-lock-on-suspend").Output(); err == nil {
+			ps.PasswordAfterSleep = boolPtr(strings.TrimSpace(string(out)) == "true")
+		} else if out, err := exec.Command("gsettings", "get", "org.gnome.desktop.screensaver", "lock-enabled").Output(); err == nil {
+			ps.PasswordAfterSleep = boolPtr(strings.TrimSpace(string(out)) == "true")
+		}
+	}
+
+	if b, err := os.ReadFile("/proc/swaps"); err == nil {
+		ps.HibernationEncrypted = swapsEncrypted(string(b), sysClassBlock, swapBacking)
+	}
+	return ps
+}
+
+// sysClassBlock is where sysfs lists every block device and partition.
+const sysClassBlock = "/sys/class/block"
+
+// swapBacking names the block device holding a swap partition, or the
+// filesystem a swapfile is on.
+func swapBacking(path, kind string) (string, error) {
+	if kind == "file" {
+		out, err := exec.Command("findmnt", "-n", "-o", "SOURCE", "-T", path).Output()
+		if err != nil {
+			return "", err
+		}
+		// btrfs appends the subvolume: /dev/mapper/root[/@swap].
+		path, _, _ = strings.Cut(strings.TrimSpace(string(out)), "[")
+	}
+	dev, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(dev), nil
+}
@@ -0,0 +1,6 @@
+//go:build !linux && !darwin && !windows
+
+package collector
+
+// collectPowerSettings is not implemented on this platform.
+func collectPowerSettings() PowerSettings { return PowerSettings{} }
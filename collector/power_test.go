@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLogindLidSwitch(t *testing.T) {
+	conf := "[Login]\n#HandleLidSwitch=suspend\nHandleLidSwitch=ignore\n"
+	assert.Equal(t, "ignore", parseLogindLidSwitch(conf))
+	assert.False(t, lidActionSleeps("ignore"))
+	assert.Equal(t, "", parseLogindLidSwitch("[Login]\n"))
+}
+
+func TestSwapsEncrypted(t *testing.T) {
+	// dm-1 is an LVM volume on the LUKS mapping dm-0; sda3 is a plain
+	// partition.
+	sys := t.TempDir()
+	for dir, uuid := range map[string]string{"dm-0": "CRYPT-LUKS2-0f1e-luks", "dm-1": "LVM-Kd93"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(sys, dir, "dm"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(sys, dir, "dm", "uuid"), []byte(uuid+"\n"), 0o644))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(sys, "dm-1", "slaves", "dm-0"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(sys, "sda3"), 0o755))
+	backing := func(path, kind string) (string, error) {
+		return map[string]string{"/dev/mapper/vg-swap": "dm-1", "/swapfile": "sda3", "/dev/zram0": "zram0"}[path], nil
+	}
+
+	header := "Filename\tType\tSize\tUsed\tPriority\n"
+	assert.Nil(t, swapsEncrypted(header, sys, backing))
+	assert.Nil(t, swapsEncrypted(header+"/dev/zram0 partition 8388604 0 100\n", sys, backing))
+
+	enc := swapsEncrypted(header+"/dev/mapper/vg-swap partition 8388604 0 -2\n/dev/zram0 partition 8388604 0 100\n", sys, backing)
+	require.NotNil(t, enc)
+	assert.True(t, *enc)
+
+	plain := swapsEncrypted(header+"/dev/mapper/vg-swap partition 8388604 0 -2\n/swapfile file 2097148 0 -3\n", sys, backing)
+	require.NotNil(t, plain)
+	assert.False(t, *plain)
+}
+
+func TestParsePowercfgIndex(t *testing.T) {
+	out := `    Power Setting GUID: 5ca83367-6e45-459f-a27b-476b1d01c936  (Lid close action)
+      Possible Setting Index: 000
+      Possible Setting Friendly Name: Do nothing
+    Current AC Power Setting Index: 0x00000001
+    Current DC Power Setting Index: 0x00000000
+`
+	v, ok := parsePowercfgIndex(out)
+	require.True(t, ok)
+	assert.Equal(t, int64(1), v)
+
+	_, ok = parsePowercfgIndex("nothing here")
+	assert.False(t, ok)
+}
+
+func TestParsePmset(t *testing.T) {
+	out := "System-wide power settings:\n SleepDisabled\t\t1\nCurrently in use:\n standby              1\n sleep                1 (sleep prevented by coreaudiod)\n"
+	m := parsePmset(out)
+	assert.Equal(t, "1", m["SleepDisabled"])
+	assert.Equal(t, "1", m["sleep"])
+}
@@ -0,0 +1,34 @@
+//go:build windows
+
+package collector
+
+import "os/exec"
+
+// collectPowerSettings reads the power scheme and BitLocker.
+func collectPowerSettings() PowerSettings {
+	ps := PowerSettings{Source: "powercfg"}
+	if rows, err := runPowerShellJSON("Get-CimInstance Win32_Battery | Select-Object Name | ConvertTo-Json -Compress"); err == nil {
+		ps.Laptop = len(rows) > 0
+	}
+	// LIDACTION: 0 do nothing, 1 sleep, 2 hibernate, 3 shut down.
+	if v, ok := powercfgValue("SUB_BUTTONS", "LIDACTION"); ok {
+		ps.SleepOnLidClose = boolPtr(v != 0)
+	}
+	// CONSOLELOCK: 1 requires sign-in on wake.
+	if v, ok := powercfgValue("SUB_NONE", "CONSOLELOCK"); ok {
+		ps.PasswordAfterSleep = boolPtr(v != 0)
+	}
+	// hiberfil.sys lives on the system drive.
+	if rows, err := runPowerShellJSON("Get-BitLockerVolume -MountPoint $env:SystemDrive | Select-Object ProtectionStatus | ConvertTo-Json -Compress"); err == nil && len(rows) == 1 {
+		ps.HibernationEncrypted = boolPtr(rows[0]["ProtectionStatus"] == "1" || rows[0]["ProtectionStatus"] == "On")
+	}
+	return ps
+}
+
+func powercfgValue(subgroup, setting string) (int64, bool) {
+	out, err := exec.Command("powercfg", "/query", "SCHEME_CURRENT", subgroup, setting).Output()
+	if err != nil {
+		return 0, false
+	}
+	return parsePowercfgIndex(string(out))
+}
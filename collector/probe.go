@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Probe reads one configuration fact for benchmark checks. Probes are
+// data so benchmark packs can be written without new collector code.
+type Probe struct {
+	// ID is unique within a run, e.g. "cis-ubuntu-22.04/5.2.7".
+	ID string `json:"id" yaml:"id"`
+	// Kind is file_setting, file_mode or command.
+	Kind string `json:"kind" yaml:"kind"`
+	// Paths are tried in order; globs are expanded. For file_setting the
+	// first file that sets Key wins, matching sshd's first-match rule
+	// when drop-in directories come first.
+	Paths []string `json:"paths,omitempty" yaml:"paths"`
+	// Key is the setting name for file_setting ("PermitRootLogin").
+	Key string `json:"key,omitempty" yaml:"key"`
+	// Command is the argv for command probes; the value is its trimmed
+	// stdout.
+	Command []string `json:"command,omitempty" yaml:"command"`
+	// RequirePath makes the probe not applicable when the path doesn't
+	// exist, e.g. SSH checks on a host without an SSH server.
+	RequirePath string `json:"require_path,omitempty" yaml:"require_path"`
+}
+
+// ProbeResult is what a probe found. Found is false when the setting,
+// file or command output is absent; Error explains failures other than
+// absence.
+type ProbeResult struct {
+	ID    string `json:"id"`
+	Value string `json:"value"`
+	Found bool   `json:"found"`
+	// Source is the file or command the value came from.
+	Source string `json:"source,omitempty"`
+	// NotApplicable is set when RequirePath is missing.
+	NotApplicable bool   `json:"not_applicable,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// RunProbes evaluates every probe. A failing probe doesn't stop the rest.
+func RunProbes(probes []Probe) []ProbeResult {
+	out := make([]ProbeResult, 0, len(probes))
+	for _, p := range probes {
+		r := runProbe(p)
+		r.ID = p.ID
+		out = append(out, r)
+	}
+	return out
+}
+
+func runProbe(p Probe) ProbeResult {
+	if p.RequirePath != "" {
+		if _, err := os.Stat(p.RequirePath); err != nil {
+			return ProbeResult{NotApplicable: true, Source: p.RequirePath}
+		}
+	}
+	switch p.Kind {
+	case "file_setting":
+		for _, path := range expandPaths(p.Paths) {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			if v, ok := settingValue(string(b), p.Key); ok {
+				return ProbeResult{Value: v, Found: true, Source: path}
+			}
+		}
+		return ProbeResult{}
+	case "file_mode":
+		for _, path := range expandPaths(p.Paths) {
+			fi, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			return ProbeResult{Value: fmt.Sprintf("%04o", fi.Mode().Perm()), Found: true, Source: path}
+		}
+		return ProbeResult{}
+	case "command":
+		if len(p.Command) == 0 {
+			return ProbeResult{Error: "no command"}
+		}
+		if _, err := exec.LookPath(p.Command[0]); err != nil {
+			return ProbeResult{Source: p.Command[0]}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		// A non-zero exit is an answer too (systemctl is-enabled on a
+		// disabled unit), so keep whatever it printed.
+		b, err := exec.CommandContext(ctx, p.Command[0], p.Command[1:]...).Output()
+		v := strings.TrimSpace(string(b))
+		r := ProbeResult{Value: v, Found: v != "", Source: strings.Join(p.Command, " ")}
+		if err != nil && v == "" {
+			if _, exit := err.(*exec.ExitError); !exit {
+				r.Error = err.Error()
+			}
+		}
+		return r
+	}
+	return ProbeResult{Error: fmt.Sprintf("unknown probe kind %q", p.Kind)}
+}
+
+func expandPaths(patterns []string) []string {
+	var out []string
+	for _, p := range patterns {
+		if !strings.ContainsAny(p, "*?[") {
+			out = append(out, p)
+			continue
+		}
+		matches, _ := filepath.Glob(p)
+		out = append(out, matches...)
+	}
+	return out
+}
+
+// settingValue finds key in a "key value" or "key = value" config file,
+// case-insensitively, ignoring comments. The first occurrence wins. An
+// sshd-style Match block ends the global section, since what follows is
+// conditional.
+func settingValue(content, key string) (string, bool) {
+	sc := bufio.NewScanner(strings.NewReader(content))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		k = strings.TrimSpace(k)
+		if !ok || strings.ContainsAny(k, " \t") {
+			f := strings.Fields(line)
+			k, v = f[0], strings.TrimPrefix(line, f[0])
+		}
+		if strings.EqualFold(k, "Match") {
+			break
+		}
+		if strings.EqualFold(k, key) {
+			return strings.TrimSpace(v), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettingValue(t *testing.T) {
+	sshd := "# PermitRootLogin yes\nPort 22\npermitrootlogin  no\nPermitRootLogin yes\nMatch User backup\n  X11Forwarding yes\n"
+	v, ok := settingValue(sshd, "PermitRootLogin")
+	assert.True(t, ok)
+	assert.Equal(t, "no", v)
+	_, ok = settingValue(sshd, "X11Forwarding")
+	assert.False(t, ok, "settings inside a Match block aren't global")
+
+	v, ok = settingValue("minlen = 14\nminclass=4\n", "minclass")
+	assert.True(t, ok)
+	assert.Equal(t, "4", v)
+	v, _ = settingValue("minlen = 14\n", "minlen")
+	assert.Equal(t, "14", v)
+}
+
+func TestRunProbes(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sshd_config.d"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sshd_config.d", "50-cloud.conf"), []byte("PasswordAuthentication yes\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sshd_config"), []byte("PasswordAuthentication no\nPermitRootLogin no\n"), 0o600))
+	paths := []string{filepath.Join(dir, "sshd_config.d", "*.conf"), filepath.Join(dir, "sshd_config")}
+
+	got := RunProbes([]Probe{
+		{ID: "a", Kind: "file_setting", Paths: paths, Key: "PasswordAuthentication"},
+		{ID: "b", Kind: "file_setting", Paths: paths, Key: "PermitRootLogin"},
+		{ID: "c", Kind: "file_setting", Paths: paths, Key: "Banner"},
+		{ID: "d", Kind: "file_mode", Paths: []string{filepath.Join(dir, "sshd_config")}},
+		{ID: "e", Kind: "command", Command: []string{"echo", " enabled "}},
+		{ID: "f", Kind: "command", Command: []string{"no-such-binary-xyz"}},
+		{ID: "g", Kind: "registry"},
+		{ID: "h", Kind: "file_mode", Paths: []string{"/etc/passwd"}, RequirePath: filepath.Join(dir, "missing")},
+	})
+	require.Len(t, got, 8)
+	assert.Equal(t, ProbeResult{ID: "a", Value: "yes", Found: true, Source: filepath.Join(dir, "sshd_config.d", "50-cloud.conf")}, got[0])
+	assert.Equal(t, "no", got[1].Value)
+	assert.False(t, got[2].Found)
+	assert.Equal(t, "0600", got[3].Value)
+	assert.Equal(t, "enabled", got[4].Value)
+	assert.False(t, got[5].Found)
+	assert.Empty(t, got[5].Error, "a missing tool is absence, not an error")
+	assert.Contains(t, got[6].Error, "unknown probe kind")
+	assert.True(t, got[7].NotApplicable)
+}
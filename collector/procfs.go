@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Linux /proc parsers, used when the usual tools are missing: BusyBox
+// and distroless images have no ss, getent or GNU ps, but /proc is
+// always there.
+
+// procNetSocket is one row of /proc/net/{tcp,tcp6,udp,udp6}.
+type procNetSocket struct {
+	LocalAddress  string
+	LocalPort     int
+	RemoteAddress string
+	RemotePort    int
+	// State is the kernel TCP state: 0A is LISTEN, 01 ESTABLISHED. An
+	// unconnected UDP socket shows 07 (CLOSE).
+	State string
+	Inode string
+}
+
+// parseProcNet parses a /proc/net socket table. Addresses are hex,
+// 32 bits at a time in host byte order.
+func parseProcNet(table string) []procNetSocket {
+	var socks []procNetSocket
+	for i, line := range strings.Split(table, "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 10 {
+			continue
+		}
+		laddr, lport, ok1 := parseProcNetAddr(fields[1])
+		raddr, rport, ok2 := parseProcNetAddr(fields[2])
+		if !ok1 || !ok2 {
+			continue
+		}
+		socks = append(socks, procNetSocket{
+			LocalAddress: laddr, LocalPort: lport,
+			RemoteAddress: raddr, RemotePort: rport,
+			State: fields[3], Inode: fields[9],
+		})
+	}
+	return socks
+}
+
+// parseProcNetAddr decodes "0100007F:0016" (127.0.0.1:22), or its
+// 32-digit IPv6 form.
+func parseProcNetAddr(s string) (string, int, bool) {
+	host, port, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", 0, false
+	}
+	p, err := strconv.ParseUint(port, 16, 16)
+	if err != nil {
+		return "", 0, false
+	}
+	b, err := hex.DecodeString(host)
+	if err != nil || (len(b) != net.IPv4len && len(b) != net.IPv6len) {
+		return "", 0, false
+	}
+	// Each 32-bit word is in the kernel's byte order.
+	for i := 0; i < len(b); i += 4 {
+		binary.BigEndian.PutUint32(b[i:], binary.NativeEndian.Uint32(b[i:]))
+	}
+	return net.IP(b).String(), int(p), true
+}
+
+// procNetListeners turns socket tables into listeners: TCP sockets in
+// LISTEN and UDP sockets with no peer. proto is "tcp" or "udp".
+func procNetListeners(socks []procNetSocket, proto string) []PortBinding {
+	var ports []PortBinding
+	for _, s := range socks {
+		listening := s.State == "0A"
+		if proto == "udp" {
+			listening = s.RemotePort == 0
+		}
+		if listening && s.LocalPort > 0 {
+			ports = append(ports, PortBinding{Port: s.LocalPort, Protocol: proto, Address: s.LocalAddress, PID: -1})
+		}
+	}
+	return ports
+}
+
+// procNetConnections returns the established TCP connections to remote
+// hosts.
+func procNetConnections(socks []procNetSocket) []Connection {
+	var conns []Connection
+	for _, s := range socks {
+		if s.State != "01" {
+			continue
+		}
+		c := Connection{
+			PID:           -1,
+			Protocol:      "tcp",
+			LocalAddress:  s.LocalAddress,
+			LocalPort:     s.LocalPort,
+			RemoteAddress: s.RemoteAddress,
+			RemotePort:    s.RemotePort,
+		}
+		if remoteConnection(c) {
+			conns = append(conns, c)
+		}
+	}
+	return conns
+}
+
+// parseProcStatusUID returns the real UID from /proc/<pid>/status.
+func parseProcStatusUID(status string) int {
+	for _, line := range strings.Split(status, "\n") {
+		if rest, ok := strings.CutPrefix(line, "Uid:"); ok {
+			if f := strings.Fields(rest); len(f) > 0 {
+				return atoiOr(f[0], -1)
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Captured on little-endian hosts; the parser uses the host's byte order.
+const procNetTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 17751 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 23001 1 0000000000000000 100 0 0 10 0
+   2: 0500000A:C6A2 22D8B85D:01BB 01 00000000:00000000 02:000A7B6C 00000000  1000        0 24410 2 0000000000000000 20 4 30 10 -1
+   3: 0100007F:A1B2 0100007F:1F90 01 00000000:00000000 00:00000000 00000000  1000        0 24411 1 0000000000000000 20 4 30 10 -1
+`
+
+const procNetTCP6 = `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:0016 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 17753 1 0000000000000000 100 0 0 10 0
+   1: 0000000000000000FFFF00000100007F:0277 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 17754 1 0000000000000000 100 0 0 10 0
+`
+
+func TestParseProcNet(t *testing.T) {
+	socks := parseProcNet(procNetTCP)
+	assert.Len(t, socks, 4)
+	assert.Equal(t, procNetSocket{
+		LocalAddress: "10.0.0.5", LocalPort: 50850,
+		RemoteAddress: "93.184.216.34", RemotePort: 443,
+		State: "01", Inode: "24410",
+	}, socks[2])
+
+	assert.Equal(t, []PortBinding{
+		{Port: 22, Protocol: "tcp", Address: "0.0.0.0", PID: -1},
+		{Port: 8080, Protocol: "tcp", Address: "127.0.0.1", PID: -1},
+	}, procNetListeners(socks, "tcp"))
+	assert.Equal(t, []Connection{{
+		PID: -1, Protocol: "tcp",
+		LocalAddress: "10.0.0.5", LocalPort: 50850,
+		RemoteAddress: "93.184.216.34", RemotePort: 443,
+	}}, procNetConnections(socks), "loopback connections are dropped")
+
+	assert.Equal(t, []PortBinding{
+		{Port: 22, Protocol: "tcp", Address: "::", PID: -1},
+		{Port: 631, Protocol: "tcp", Address: "127.0.0.1", PID: -1},
+	}, procNetListeners(parseProcNet(procNetTCP6), "tcp"))
+}
+
+func TestProcNetListeners_UDP(t *testing.T) {
+	socks := parseProcNet(`  sl  local_address rem_address   st
+  0: 3500007F:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000   101        0 16231 2 0000000000000000 0
+  1: 0500000A:D431 08080808:0035 01 00000000:00000000 00:00000000 00000000  1000        0 26001 2 0000000000000000 0
+`)
+	assert.Equal(t, []PortBinding{{Port: 53, Protocol: "udp", Address: "127.0.0.53", PID: -1}}, procNetListeners(socks, "udp"))
+}
+
+func TestParseProcStatusUID(t *testing.T) {
+	assert.Equal(t, 1000, parseProcStatusUID("Name:\tbash\nState:\tS (sleeping)\nUid:\t1000\t1000\t1000\t1000\n"))
+	assert.Equal(t, -1, parseProcStatusUID("Name:\tkthreadd\n"))
+}
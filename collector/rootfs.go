@@ -0,0 +1,286 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinks bounds symlink resolution under a root, as the kernel does.
+const maxSymlinks = 40
+
+// RootFS collects from a filesystem tree rather than the running system:
+// an extracted container image, or a disk mounted somewhere else. It
+// reads the files a live collector would ask the OS about, so only
+// on-disk state is covered; nothing runs in a tree, so it has no
+// processes, listening ports or connections.
+type RootFS struct {
+	Dir string
+}
+
+// NewRootFS collects from the tree at dir.
+func NewRootFS(dir string) *RootFS {
+	return &RootFS{Dir: dir}
+}
+
+// InRoot maps the absolute path p inside root to a path on this host,
+// resolving symlinks as if root were /: an absolute link target, or ..
+// past the top, stays under root. An image's /etc/os-release ->
+// ../usr/lib/os-release resolves inside the image, never to the host's
+// file.
+func InRoot(root, p string) (string, error) {
+	root = filepath.Clean(root)
+	rest := strings.Split(filepath.ToSlash(filepath.Clean("/"+p)), "/")
+	resolved := "/"
+	for links := 0; len(rest) > 0; {
+		part := rest[0]
+		rest = rest[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+		next := filepath.Join(resolved, part)
+		fi, err := os.Lstat(filepath.Join(root, next))
+		if err != nil || fi.Mode()&fs.ModeSymlink == 0 {
+			// A missing component resolves to itself, so callers see
+			// the not-exist error when they open it.
+			resolved = next
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return "", fmt.Errorf("%s: too many levels of symbolic links", p)
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		rest = append(strings.Split(filepath.ToSlash(target), "/"), rest...)
+	}
+	return filepath.Join(root, resolved), nil
+}
+
+// path is InRoot under r.
+func (r *RootFS) path(p string) (string, error) {
+	return InRoot(r.Dir, p)
+}
+
+// ReadFile reads the file at the absolute path p in the tree.
+func (r *RootFS) ReadFile(p string) ([]byte, error) {
+	hp, err := r.path(p)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(hp)
+}
+
+// Hostname is the tree's /etc/hostname, or "" when it has none.
+func (r *RootFS) Hostname() string {
+	b, err := r.ReadFile("/etc/hostname")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// CollectUsers returns the tree's /etc/passwd accounts.
+func (r *RootFS) CollectUsers(ctx context.Context) ([]User, error) {
+	b, err := r.ReadFile("/etc/passwd")
+	if errors.Is(err, fs.ErrNotExist) {
+		// Distroless and scratch images have no accounts at all.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parsePasswd(string(b)), nil
+}
+
+// CollectProcesses returns nothing: nothing runs in a tree.
+func (r *RootFS) CollectProcesses(ctx context.Context, limit int) ([]Process, error) {
+	return nil, nil
+}
+
+// CollectOpenPorts returns nothing: nothing listens in a tree.
+func (r *RootFS) CollectOpenPorts(ctx context.Context) ([]PortBinding, error) {
+	return nil, nil
+}
+
+// CollectConnections returns nothing: nothing connects from a tree.
+func (r *RootFS) CollectConnections(ctx context.Context, limit int) ([]Connection, error) {
+	return nil, nil
+}
+
+// rpmDBPaths are where rpm keeps its database, newest layout first.
+var rpmDBPaths = []string{"/usr/lib/sysimage/rpm", "/var/lib/rpm"}
+
+// CollectPackages reads the tree's dpkg or apk database directly. An rpm
+// database is Berkeley DB or SQLite, so it is read with this host's rpm
+// (`rpm --root`) when there is one. A tree with none of them, such as a
+// distroless image, is errNoPackageManager rather than no packages.
+func (r *RootFS) CollectPackages(ctx context.Context, limit int) ([]Package, error) {
+	if b, err := r.ReadFile("/var/lib/dpkg/status"); err == nil {
+		return parseDpkgStatus(string(b), limit), nil
+	}
+	if b, err := r.ReadFile(apkInstalledDB); err == nil {
+		return parseApkInstalled(string(b), limit), nil
+	}
+	for _, db := range rpmDBPaths {
+		hp, err := r.path(db)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(hp); err != nil {
+			continue
+		}
+		if _, err := exec.LookPath("rpm"); err != nil {
+			return nil, fmt.Errorf("%s holds an rpm database, which needs rpm installed to read", db)
+		}
+		out, err := exec.CommandContext(ctx, "rpm", "--root", r.Dir, "--dbpath", db, "-qa", "--qf", rpmQueryFormat).Output()
+		if err != nil {
+			return nil, fmt.Errorf("rpm --root: %w", err)
+		}
+		return parseRPMList(string(out), limit), ctx.Err()
+	}
+	return nil, errNoPackageManager
+}
+
+// parseDpkgStatus parses dpkg's status database (/var/lib/dpkg/status),
+// keeping the packages whose status is "install ok installed".
+func parseDpkgStatus(db string, limit int) []Package {
+	var packages []Package
+	var cur Package
+	installed := false
+	flush := func() {
+		if cur.Name != "" && installed && len(packages) < limit {
+			cur.Source = "dpkg"
+			packages = append(packages, cur)
+		}
+		cur, installed = Package{}, false
+	}
+	sc := bufio.NewScanner(strings.NewReader(db))
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok || strings.HasPrefix(line, " ") {
+			continue
+		}
+		switch key {
+		case "Package":
+			cur.Name = value
+		case "Version":
+			cur.Version = value
+		case "Architecture":
+			cur.Arch = value
+		case "Status":
+			installed = strings.HasSuffix(value, " installed")
+		}
+	}
+	flush()
+	return packages
+}
+
+// OSRelease reads the tree's os-release(5), /etc/os-release or else
+// /usr/lib/os-release.
+func (r *RootFS) OSRelease() ([]byte, error) {
+	b, err := r.ReadFile("/etc/os-release")
+	if errors.Is(err, fs.ErrNotExist) {
+		b, err = r.ReadFile("/usr/lib/os-release")
+	}
+	return b, err
+}
+
+// CollectOSVersion reads the tree's os-release. There is no kernel: a
+// tree boots whatever kernel it is given.
+func (r *RootFS) CollectOSVersion(ctx context.Context) (*OSVersion, error) {
+	b, err := r.OSRelease()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	v := parseOSRelease(string(b))
+	if v.ID == "debian" {
+		if dv, err := r.ReadFile("/etc/debian_version"); err == nil {
+			if s := strings.TrimSpace(string(dv)); strings.HasPrefix(s, v.Version+".") {
+				v.Version = s
+			}
+		}
+	}
+	return v, nil
+}
+
+// CollectSSHDConfig parses the tree's sshd_config at path (the default
+// when empty) and its Includes, which resolve inside the tree. sshd -T
+// isn't asked: the host's sshd is not the tree's. It returns nil when
+// there is no config file.
+func (r *RootFS) CollectSSHDConfig(ctx context.Context, path string) (*SSHDConfig, error) {
+	if path == "" {
+		path = "/etc/ssh/sshd_config"
+	}
+	hp, err := r.path(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(hp); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	settings := map[string]string{}
+	if err := readSSHDConfig(r.Dir, hp, filepath.Dir(hp), settings, 0); err != nil {
+		return nil, err
+	}
+	return &SSHDConfig{Source: path, Settings: settings}, nil
+}
+
+// CollectHostsFile reads the tree's /etc/hosts.
+func (r *RootFS) CollectHostsFile() ([]HostsEntry, error) {
+	b, err := r.ReadFile("/etc/hosts")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseHosts(string(b)), nil
+}
+
+// CollectSecretFiles scans the tree's paths for secrets, as
+// CollectSecretFiles does the host's, reporting paths as they are in the
+// tree. Whether other users could reach a file is judged from the tree's
+// own directories up to its root, which stands for /.
+func (r *RootFS) CollectSecretFiles(ctx context.Context, paths []string) ([]SecretFile, error) {
+	var hostPaths []string
+	for _, p := range paths {
+		if hp, err := r.path(p); err == nil {
+			hostPaths = append(hostPaths, hp)
+		}
+	}
+	root := filepath.Clean(r.Dir)
+	files, err := collectSecretFiles(ctx, hostPaths, map[string]bool{root: true})
+	for i := range files {
+		if rel, err := filepath.Rel(root, files[i].Path); err == nil {
+			files[i].Path = "/" + filepath.ToSlash(rel)
+		}
+	}
+	return files, err
+}
@@ -0,0 +1,195 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SecretFile is a private key, keystore, dotenv file or server
+// certificate found under a scanned path. Only metadata is recorded:
+// private key bodies are skipped unread, and keystores and dotenv files
+// are identified by name alone.
+type SecretFile struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"` // "private_key" | "keystore" | "env" | "certificate"
+	Mode string `json:"mode"`
+	// WorldReadable: any local user can read the file, through every
+	// directory above it. Always false on Windows, where access is
+	// governed by ACLs rather than mode bits.
+	WorldReadable bool `json:"world_readable"`
+	// Subject and NotAfter describe the first certificate in a PEM file,
+	// when it isn't a CA certificate.
+	Subject  string     `json:"subject,omitempty"`
+	NotAfter *time.Time `json:"not_after,omitempty"`
+}
+
+// secretScanLimit caps the entries visited per scan, so a path with a
+// huge tree (an /opt full of vendored code) can't stall collection.
+const secretScanLimit = 50000
+
+var errSecretScanLimit = fmt.Errorf("stopped after %d entries", secretScanLimit)
+
+// pemReadLimit caps how much of a certificate file is read.
+const pemReadLimit = 1 << 20
+
+// CollectSecretFiles walks paths for secret material. Missing paths are
+// skipped, as are symlinks (the trust store is mostly links to public CA
+// certificates) and directories the agent can't read.
+func CollectSecretFiles(ctx context.Context, paths []string) ([]SecretFile, error) {
+	return collectSecretFiles(ctx, paths, map[string]bool{})
+}
+
+// collectSecretFiles is CollectSecretFiles with the traversable cache
+// seeded by the caller.
+func collectSecretFiles(ctx context.Context, paths []string, dirs map[string]bool) ([]SecretFile, error) {
+	var out []SecretFile
+	var errs []error
+	visited := 0
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if path == root && errors.Is(err, fs.ErrNotExist) {
+					return fs.SkipDir
+				}
+				if d != nil && d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if visited++; visited > secretScanLimit {
+				return errSecretScanLimit
+			}
+			if d.IsDir() {
+				if path != root && (d.Name() == ".git" || d.Name() == "node_modules") {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			f, ok := inspectSecretFile(path)
+			if !ok {
+				return nil
+			}
+			if fi, err := d.Info(); err == nil {
+				f.Mode = fmt.Sprintf("%04o", fi.Mode().Perm())
+				f.WorldReadable = worldReadable(fi) && traversable(filepath.Dir(path), dirs)
+			}
+			out = append(out, f)
+			return nil
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return out, ctx.Err()
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", root, err))
+			if errors.Is(err, errSecretScanLimit) {
+				break
+			}
+		}
+	}
+	return out, errors.Join(errs...)
+}
+
+// inspectSecretFile classifies path by name, reading only certificate
+// files. ok is false for anything that isn't secret material or a
+// server certificate.
+func inspectSecretFile(path string) (SecretFile, bool) {
+	f := SecretFile{Path: path}
+	name := strings.ToLower(filepath.Base(path))
+	switch ext := filepath.Ext(name); {
+	case name == ".env" || strings.HasPrefix(name, ".env.") || ext == ".env":
+		switch ext {
+		case ".example", ".sample", ".template", ".dist":
+			return f, false
+		}
+		f.Kind = "env"
+	case ext == ".jks" || ext == ".jceks" || ext == ".keystore" || ext == ".p12" || ext == ".pfx" || ext == ".bks":
+		f.Kind = "keystore"
+	case ext == ".key" || name == "id_rsa" || name == "id_dsa" || name == "id_ecdsa" || name == "id_ed25519":
+		f.Kind = "private_key"
+	case ext == ".pem" || ext == ".crt" || ext == ".cer":
+		hasKey, cert, err := inspectPEM(path)
+		if err != nil {
+			return f, false
+		}
+		if cert != nil && !signsCertificates(cert) {
+			f.Subject = cert.Subject.String()
+			f.NotAfter = &cert.NotAfter
+			f.Kind = "certificate"
+		}
+		if hasKey {
+			f.Kind = "private_key"
+		}
+	}
+	return f, f.Kind != ""
+}
+
+// inspectPEM reports whether a PEM file holds a private key and parses
+// its first certificate. Lines inside a key block are skipped without
+// being decoded or kept.
+func inspectPEM(path string) (hasKey bool, cert *x509.Certificate, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, nil, err
+	}
+	defer file.Close()
+	sc := bufio.NewScanner(io.LimitReader(file, pemReadLimit))
+	var b64 strings.Builder
+	inCert, done := false, false
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case strings.HasPrefix(line, "-----BEGIN ") && strings.Contains(line, "PRIVATE KEY"):
+			hasKey = true
+		case line == "-----BEGIN CERTIFICATE-----" && !done:
+			inCert = true
+		case line == "-----END CERTIFICATE-----" && inCert:
+			inCert, done = false, true
+			if der, err := base64.StdEncoding.DecodeString(b64.String()); err == nil {
+				cert, _ = x509.ParseCertificate(der)
+			}
+		case inCert:
+			b64.WriteString(line)
+		}
+	}
+	return hasKey, cert, sc.Err()
+}
+
+// signsCertificates reports whether cert is a CA certificate, such as the
+// roots in a trust bundle. A bare CA:TRUE isn't enough: openssl req -x509
+// sets it on the self-signed server certificates it creates.
+func signsCertificates(cert *x509.Certificate) bool {
+	return cert.IsCA && cert.KeyUsage&x509.KeyUsageCertSign != 0
+}
+
+// traversable reports whether other users can reach into dir: it and
+// every directory above it are world-searchable. Results are cached in
+// seen across a scan.
+func traversable(dir string, seen map[string]bool) bool {
+	if ok, cached := seen[dir]; cached {
+		return ok
+	}
+	ok := true
+	if fi, err := os.Stat(dir); err != nil || !worldSearchable(fi) {
+		ok = false
+	} else if parent := filepath.Dir(dir); parent != dir {
+		ok = traversable(parent, seen)
+	}
+	seen[dir] = ok
+	return ok
+}
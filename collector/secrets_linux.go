@@ -0,0 +1,8 @@
+package collector
+
+// DefaultSecretPaths are the directories scanned when the policy doesn't
+// list any: the system TLS stores and where web servers and packaged
+// applications keep their configuration.
+func DefaultSecretPaths() []string {
+	return []string{"/etc/ssl", "/etc/pki", "/etc/nginx", "/etc/apache2", "/etc/httpd", "/srv", "/opt"}
+}
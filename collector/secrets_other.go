@@ -0,0 +1,7 @@
+//go:build !linux && !darwin
+
+package collector
+
+// DefaultSecretPaths is empty on this platform: the policy has to list
+// the paths to scan.
+func DefaultSecretPaths() []string { return nil }
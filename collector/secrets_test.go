@@ -0,0 +1,103 @@
+//go:build !windows
+
+package collector
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	// TempDir creates 0700 directories; open them up so world-readable
+	// files inside are reachable.
+	require.NoError(t, os.Chmod(filepath.Dir(dir), 0o755))
+	require.NoError(t, os.Chmod(dir, 0o755))
+	write := func(name string, mode os.FileMode, data []byte) {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, data, mode))
+		require.NoError(t, os.Chmod(p, mode))
+	}
+	notAfter := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	leaf := testCertPEM(t, "www.example.com", false, x509.KeyUsageDigitalSignature, notAfter)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("not a real key")})
+
+	write("site.key", 0o644, []byte("secret"))
+	write("app/.env", 0o600, []byte("TOKEN=secret"))
+	write("app/.env.example", 0o644, []byte("TOKEN="))
+	write("app/store.jks", 0o644, []byte{0xfe, 0xed})
+	write("site.crt", 0o644, leaf)
+	write("ca.crt", 0o644, testCertPEM(t, "Example CA", true, x509.KeyUsageCertSign, notAfter))
+	// openssl req -x509 marks its self-signed certificates CA:TRUE.
+	write("selfsigned.crt", 0o644, testCertPEM(t, "test.local", true, 0, notAfter))
+	write("combined.pem", 0o644, append(append([]byte{}, leaf...), keyPEM...))
+	write("readme.txt", 0o644, []byte("hello"))
+	write("locked/inner.key", 0o644, []byte("secret"))
+	require.NoError(t, os.Chmod(filepath.Join(dir, "locked"), 0o750))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "site.key"), filepath.Join(dir, "link.key")))
+
+	files, err := CollectSecretFiles(context.Background(), []string{dir, filepath.Join(dir, "missing")})
+	require.NoError(t, err)
+	byName := map[string]SecretFile{}
+	for _, f := range files {
+		rel, _ := filepath.Rel(dir, f.Path)
+		byName[rel] = f
+	}
+	assert.ElementsMatch(t, []string{"site.key", "app/.env", "app/store.jks", "site.crt", "selfsigned.crt", "combined.pem", "locked/inner.key"}, keys(byName))
+
+	assert.Equal(t, SecretFile{Path: filepath.Join(dir, "site.key"), Kind: "private_key", Mode: "0644", WorldReadable: true}, byName["site.key"])
+	assert.False(t, byName["app/.env"].WorldReadable)
+	assert.Equal(t, "env", byName["app/.env"].Kind)
+	assert.Equal(t, "keystore", byName["app/store.jks"].Kind)
+	assert.False(t, byName["locked/inner.key"].WorldReadable, "directory blocks other users")
+
+	crt := byName["site.crt"]
+	assert.Equal(t, "certificate", crt.Kind)
+	assert.Equal(t, "CN=www.example.com", crt.Subject)
+	require.NotNil(t, crt.NotAfter)
+	assert.True(t, crt.NotAfter.Equal(notAfter))
+
+	combined := byName["combined.pem"]
+	assert.Equal(t, "private_key", combined.Kind)
+	assert.NotNil(t, combined.NotAfter)
+}
+
+func keys(m map[string]SecretFile) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
+
+func testCertPEM(t *testing.T, cn string, ca bool, usage x509.KeyUsage, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:              notAfter,
+		IsCA:                  ca,
+		BasicConstraintsValid: true,
+		KeyUsage:              usage,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package collector
+
+import "io/fs"
+
+// worldReadable reports whether the "other" read bit is set.
+func worldReadable(fi fs.FileInfo) bool {
+	return fi.Mode().Perm()&0o004 != 0
+}
+
+// worldSearchable reports whether the "other" execute bit is set on a
+// directory, which is what lets other users open files inside it.
+func worldSearchable(fi fs.FileInfo) bool {
+	return fi.Mode().Perm()&0o001 != 0
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package collector
+
+import "io/fs"
+
+// worldReadable is always false on Windows: Go synthesizes mode bits from
+// the read-only attribute, and real access is governed by the file's ACL.
+func worldReadable(fs.FileInfo) bool {
+	return false
+}
+
+func worldSearchable(fs.FileInfo) bool {
+	return false
+}
@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// SharingService is a file, printer or peer-to-peer sharing service found
+// enabled on the host, with the evidence that shows it.
+type SharingService struct {
+	// Service is one of smb, afp, nfs, cups, airdrop, windows_file_sharing.
+	Service  string `json:"service"`
+	Port     int    `json:"port,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	Evidence string `json:"evidence"`
+	// Unknown is set when whether the service is enabled couldn't be
+	// read; such a service is reported but not counted as enabled.
+	Unknown bool `json:"unknown,omitempty"`
+}
+
+// sharingPorts maps well-known sharing ports to their service.
+var sharingPorts = map[int]string{
+	139:  "smb",
+	445:  "smb",
+	548:  "afp",
+	631:  "cups",
+	2049: "nfs",
+}
+
+// CollectSharingServices reports sharing services that are reachable from
+// the network, using the listening sockets already collected plus the
+// platform's own sharing settings. Loopback listeners (CUPS binds to
+// localhost by default) are not sharing.
+func CollectSharingServices(bindings []PortBinding) []SharingService {
+	out := sharingFromBindings(bindings, ownSMBPorts)
+	return dedupeSharing(append(out, platformSharing()...))
+}
+
+// sharingFromBindings reports the sharing ports listened on beyond
+// loopback, leaving out SMB's when ownSMB says the OS itself holds them.
+func sharingFromBindings(bindings []PortBinding, ownSMB bool) []SharingService {
+	var out []SharingService
+	for _, b := range bindings {
+		svc, ok := sharingPorts[b.Port]
+		if !ok || isLoopback(b.Address) {
+			continue
+		}
+		if ownSMB && svc == "smb" {
+			continue
+		}
+		out = append(out, SharingService{
+			Service:  svc,
+			Port:     b.Port,
+			Protocol: b.Protocol,
+			Evidence: fmt.Sprintf("listening on %s:%d/%s", b.Address, b.Port, b.Protocol),
+		})
+	}
+	return out
+}
+
+func isLoopback(addr string) bool {
+	return addr == "::1" || addr == "localhost" || strings.HasPrefix(addr, "127.")
+}
+
+// cupsSharing asks CUPS whether printer sharing is on.
+func cupsSharing() (SharingService, bool) {
+	out, err := exec.Command("cupsctl").Output()
+	if err != nil || !cupsShareEnabled(string(out)) {
+		return SharingService{}, false
+	}
+	return SharingService{Service: "cups", Port: 631, Protocol: "tcp", Evidence: "cupsctl: _share_printers=1"}, true
+}
+
+func cupsShareEnabled(cupsctl string) bool {
+	for _, line := range strings.Split(cupsctl, "\n") {
+		if strings.TrimSpace(line) == "_share_printers=1" {
+			return true
+		}
+	}
+	return false
+}
+
+// airDropState decides from DisableAirDrop and DiscoverableMode, either
+// empty when it couldn't be read. With neither read, AirDrop is reported
+// as unknown rather than enabled.
+func airDropState(disableAirDrop, discoverableMode string) (SharingService, bool) {
+	switch {
+	case disableAirDrop == "1", discoverableMode == "Off":
+		return SharingService{}, false
+	case discoverableMode != "":
+		return SharingService{Service: "airdrop", Evidence: "com.apple.sharingd DiscoverableMode " + discoverableMode}, true
+	}
+	return SharingService{Service: "airdrop", Unknown: true, Evidence: "no managed DisableAirDrop or console user DiscoverableMode to read"}, true
+}
+
+// dedupeSharing drops repeats of the same service and port, e.g. SMB
+// listening on both IPv4 and IPv6, keeping the first evidence seen.
+func dedupeSharing(in []SharingService) []SharingService {
+	seen := map[string]bool{}
+	var out []SharingService
+	for _, s := range in {
+		key := fmt.Sprintf("%s/%d/%s", s.Service, s.Port, s.Evidence)
+		if s.Port != 0 && s.Service != "windows_file_sharing" {
+			key = fmt.Sprintf("%s/%d", s.Service, s.Port)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, s)
+	}
+	return out
+}
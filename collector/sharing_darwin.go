@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// ownSMBPorts is false: macOS only listens for SMB with File Sharing on.
+const ownSMBPorts = false
+
+// platformSharing reports the file sharing launchd jobs, AirDrop and
+// CUPS printer sharing.
+func platformSharing() []SharingService {
+	var out []SharingService
+	// `sharing -l` lists share points only when File Sharing is set up;
+	// the services themselves are launchd jobs.
+	if b, err := exec.Command("launchctl", "print-disabled", "system").Output(); err == nil {
+		disabled := string(b)
+		for _, j := range []struct{ job, svc string }{
+			{"com.apple.smbd", "smb"},
+			{"com.apple.AppleFileServer", "afp"},
+		} {
+			if strings.Contains(disabled, fmt.Sprintf("%q => enabled", j.job)) {
+				out = append(out, SharingService{Service: j.svc, Evidence: "launchd job " + j.job + " enabled"})
+			}
+		}
+	}
+	if s, ok := airDropSharing(); ok {
+		out = append(out, s)
+	}
+	if s, ok := cupsSharing(); ok {
+		out = append(out, s)
+	}
+	return out
+}
+
+// managedNetworkBrowser is where an MDM profile's AirDrop restriction
+// lands; root's own com.apple.NetworkBrowser says nothing about users.
+const managedNetworkBrowser = "/Library/Managed Preferences/com.apple.NetworkBrowser"
+
+// airDropSharing reads AirDrop's state from the managed DisableAirDrop,
+// else the console user's sharingd DiscoverableMode.
+func airDropSharing() (SharingService, bool) {
+	disabled, _ := exec.Command("defaults", "read", managedNetworkBrowser, "DisableAirDrop").Output()
+	var mode []byte
+	if u, err := consoleUser(); err == nil {
+		mode, _ = exec.Command("defaults", "read", filepath.Join(u.HomeDir, "Library/Preferences/com.apple.sharingd"), "DiscoverableMode").Output()
+	}
+	return airDropState(strings.TrimSpace(string(disabled)), strings.TrimSpace(string(mode)))
+}
+
+// consoleUser is the user logged in at the macOS console: the owner of
+// /dev/console, which is root at the login window.
+func consoleUser() (*user.User, error) {
+	b, err := exec.Command("stat", "-f", "%Su", "/dev/console").Output()
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(string(b))
+	if name == "" || name == "root" {
+		return nil, errors.New("no user logged in at the console")
+	}
+	return user.Lookup(name)
+}
@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharingFromBindings(t *testing.T) {
+	bindings := []PortBinding{
+		{Port: 631, Protocol: "tcp", Address: "127.0.0.1"},
+		{Port: 445, Protocol: "tcp", Address: "0.0.0.0"},
+		{Port: 445, Protocol: "tcp", Address: "::"},
+		{Port: 22, Protocol: "tcp", Address: "0.0.0.0"},
+	}
+	got := dedupeSharing(sharingFromBindings(bindings, false))
+	assert.Equal(t, []SharingService{
+		{Service: "smb", Port: 445, Protocol: "tcp", Evidence: "listening on 0.0.0.0:445/tcp"},
+	}, got)
+	assert.Empty(t, sharingFromBindings(bindings, true))
+}
+
+func TestAirDropState(t *testing.T) {
+	_, ok := airDropState("1", "Everyone")
+	assert.False(t, ok, "managed DisableAirDrop wins")
+	_, ok = airDropState("", "Off")
+	assert.False(t, ok)
+
+	s, ok := airDropState("0", "Everyone")
+	assert.True(t, ok)
+	assert.False(t, s.Unknown)
+	assert.Equal(t, "com.apple.sharingd DiscoverableMode Everyone", s.Evidence)
+
+	s, ok = airDropState("", "")
+	assert.True(t, ok)
+	assert.True(t, s.Unknown)
+}
+
+func TestCupsShareEnabled(t *testing.T) {
+	assert.True(t, cupsShareEnabled("_debug_logging=0\n_share_printers=1\n_user_cancel_any=0\n"))
+	assert.False(t, cupsShareEnabled("_share_printers=0\n"))
+}
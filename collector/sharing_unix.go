@@ -0,0 +1,14 @@
+//go:build !darwin && !windows
+
+package collector
+
+// ownSMBPorts is false: Samba only listens when it is set up to share.
+const ownSMBPorts = false
+
+// platformSharing reports CUPS printer sharing.
+func platformSharing() []SharingService {
+	if s, ok := cupsSharing(); ok {
+		return []SharingService{s}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+//go:build windows
+
+package collector
+
+import "fmt"
+
+// ownSMBPorts is true: Windows always listens on 139 and 445 for its
+// own SMB traffic (named pipes, the administrative shares), so
+// Get-SmbShare says whether anything is actually shared.
+const ownSMBPorts = true
+
+// platformSharing reports the user-created SMB shares.
+func platformSharing() []SharingService {
+	// Administrative shares (C$, ADMIN$, IPC$) exist everywhere; only
+	// user-created shares count as file sharing.
+	rows, err := runPowerShellJSON("Get-SmbShare | Where-Object { -not $_.Special } | Select-Object Name,Path | ConvertTo-Json -Compress")
+	if err != nil {
+		return nil
+	}
+	var out []SharingService
+	for _, r := range rows {
+		out = append(out, SharingService{
+			Service:  "windows_file_sharing",
+			Port:     445,
+			Protocol: "tcp",
+			Evidence: fmt.Sprintf("SMB share %s (%s)", r["Name"], r["Path"]),
+		})
+	}
+	return out
+}
@@ -0,0 +1,30 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+
+	"compliance-agent/errcode"
+)
+
+// isNamedPipe reports whether path names a Windows named pipe rather than
+// a Unix domain socket.
+func isNamedPipe(path string) bool {
+	return strings.HasPrefix(path, `\\.\pipe\`)
+}
+
+// UnsafeSocketError means the osquery extension socket failed the
+// ownership/permission check and the agent refused to connect to it.
+// Talking to a socket another user controls would let them feed the
+// compliance agent whatever answers they like.
+type UnsafeSocketError struct {
+	Path   string
+	Reason string
+}
+
+func (e *UnsafeSocketError) Error() string {
+	return fmt.Sprintf("refusing osquery socket %s: %s", e.Path, e.Reason)
+}
+
+// ErrorCode files a refused socket under permission problems.
+func (e *UnsafeSocketError) ErrorCode() errcode.Code { return errcode.PermissionDenied }
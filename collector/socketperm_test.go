@@ -0,0 +1,44 @@
+//go:build !windows
+
+package collector
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSocketPermissions(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0o755))
+	sock := filepath.Join(dir, "osquery.em")
+
+	assert.NoError(t, checkSocketPermissions(sock), "missing socket is fine")
+
+	require.NoError(t, os.WriteFile(sock, nil, 0o600))
+	assert.NoError(t, checkSocketPermissions(sock))
+
+	require.NoError(t, os.Chmod(sock, 0o666))
+	err := checkSocketPermissions(sock)
+	var unsafe *UnsafeSocketError
+	require.True(t, errors.As(err, &unsafe))
+	assert.Contains(t, unsafe.Reason, "world-writable")
+}
+
+func TestCheckSocketPermissions_OpenDirectory(t *testing.T) {
+	dir := t.TempDir()
+	sock := filepath.Join(dir, "osquery.em")
+	require.NoError(t, os.WriteFile(sock, nil, 0o600))
+	require.NoError(t, os.Chmod(dir, 0o777))
+
+	err := checkSocketPermissions(sock)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sticky bit")
+
+	require.NoError(t, os.Chmod(dir, 0o777|os.ModeSticky))
+	assert.NoError(t, checkSocketPermissions(sock))
+}
@@ -0,0 +1,45 @@
+//go:build !windows
+
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// checkSocketPermissions refuses an extension socket that another local
+// user could have planted or could hijack: world-writable sockets, sockets
+// owned by someone other than root or us, and sockets in a world-writable
+// directory without the sticky bit (where anyone can swap the file).
+// A missing socket is not an error; there is nothing to hijack yet.
+func checkSocketPermissions(path string) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("stat osquery socket: %w", err)
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		return &UnsafeSocketError{Path: path, Reason: "is a symlink"}
+	}
+	if fi.Mode().Perm()&0o002 != 0 {
+		return &UnsafeSocketError{Path: path, Reason: fmt.Sprintf("is world-writable (mode %s)", fi.Mode().Perm())}
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		if st.Uid != 0 && int(st.Uid) != os.Geteuid() {
+			return &UnsafeSocketError{Path: path, Reason: fmt.Sprintf("is owned by uid %d, not root or the agent user", st.Uid)}
+		}
+	}
+	dir := filepath.Dir(path)
+	di, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("stat osquery socket directory: %w", err)
+	}
+	if di.Mode().Perm()&0o002 != 0 && di.Mode()&os.ModeSticky == 0 {
+		return &UnsafeSocketError{Path: path, Reason: fmt.Sprintf("lives in world-writable directory %s without the sticky bit", dir)}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+//go:build windows
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procGetNamedPipeServerProcessId = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetNamedPipeServerProcessId")
+
+// checkSocketPermissions refuses an extension pipe that another local
+// user could have created before osqueryd: the pipe must be owned by
+// SYSTEM or Administrators, and so must the process serving it. Named
+// pipes have no directory to squat in, so the owner is what matters.
+// Checking connects to the pipe once. A missing pipe is not an error;
+// there is nothing to hijack yet.
+func checkSocketPermissions(path string) error {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	h, err := windows.CreateFile(name, windows.FILE_READ_ATTRIBUTES|windows.READ_CONTROL, 0, nil, windows.OPEN_EXISTING, 0, 0)
+	if errors.Is(err, windows.ERROR_FILE_NOT_FOUND) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open osquery pipe: %w", err)
+	}
+	defer windows.CloseHandle(h)
+
+	sd, err := windows.GetSecurityInfo(h, windows.SE_KERNEL_OBJECT, windows.OWNER_SECURITY_INFORMATION)
+	if err != nil {
+		return fmt.Errorf("read osquery pipe owner: %w", err)
+	}
+	owner, _, err := sd.Owner()
+	if err != nil {
+		return fmt.Errorf("read osquery pipe owner: %w", err)
+	}
+	if !trustedSID(owner) {
+		return &UnsafeSocketError{Path: path, Reason: fmt.Sprintf("is owned by %s, not SYSTEM or Administrators", owner)}
+	}
+
+	var pid uint32
+	if r, _, err := procGetNamedPipeServerProcessId.Call(uintptr(h), uintptr(unsafe.Pointer(&pid))); r == 0 {
+		return fmt.Errorf("find osquery pipe server: %w", err)
+	}
+	user, trusted, err := processTrusted(pid)
+	if err != nil {
+		return fmt.Errorf("read osquery pipe server %d: %w", pid, err)
+	}
+	if !trusted {
+		return &UnsafeSocketError{Path: path, Reason: fmt.Sprintf("is served by process %d running as %s, not SYSTEM or an administrator", pid, user)}
+	}
+	return nil
+}
+
+// trustedSID reports whether sid is LocalSystem or the Administrators
+// group.
+func trustedSID(sid *windows.SID) bool {
+	return sid.IsWellKnown(windows.WinLocalSystemSid) || sid.IsWellKnown(windows.WinBuiltinAdministratorsSid)
+}
+
+// processTrusted reports whether process pid runs as SYSTEM or with the
+// Administrators group enabled, i.e. elevated. user is the process's
+// account, for the refusal message.
+func processTrusted(pid uint32) (user *windows.SID, trusted bool, err error) {
+	p, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return nil, false, err
+	}
+	defer windows.CloseHandle(p)
+	var tok windows.Token
+	if err := windows.OpenProcessToken(p, windows.TOKEN_QUERY, &tok); err != nil {
+		return nil, false, err
+	}
+	defer tok.Close()
+	tu, err := tok.GetTokenUser()
+	if err != nil {
+		return nil, false, err
+	}
+	if trustedSID(tu.User.Sid) {
+		return tu.User.Sid, true, nil
+	}
+	groups, err := tok.GetTokenGroups()
+	if err != nil {
+		return nil, false, err
+	}
+	for _, g := range groups.AllGroups() {
+		if g.Attributes&windows.SE_GROUP_ENABLED != 0 && g.Sid.IsWellKnown(windows.WinBuiltinAdministratorsSid) {
+			return tu.User.Sid, true, nil
+		}
+	}
+	return tu.User.Sid, false, nil
+}
@@ -0,0 +1,55 @@
+//go:build windows
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/windows"
+)
+
+func TestTrustedSID(t *testing.T) {
+	for sidType, want := range map[windows.WELL_KNOWN_SID_TYPE]bool{
+		windows.WinLocalSystemSid:           true,
+		windows.WinBuiltinAdministratorsSid: true,
+		windows.WinBuiltinUsersSid:          false,
+		windows.WinWorldSid:                 false,
+	} {
+		sid, err := windows.CreateWellKnownSid(sidType)
+		require.NoError(t, err)
+		assert.Equal(t, want, trustedSID(sid), sid.String())
+	}
+}
+
+func TestCheckSocketPermissions(t *testing.T) {
+	path := fmt.Sprintf(`\\.\pipe\compliance-agent-test-%d`, os.Getpid())
+	assert.NoError(t, checkSocketPermissions(path), "missing pipe is fine")
+
+	tu, err := windows.GetCurrentProcessToken().GetTokenUser()
+	require.NoError(t, err)
+	if trustedSID(tu.User.Sid) {
+		t.Skip("running as SYSTEM, can't create an untrusted pipe")
+	}
+	// A pipe owned by an ordinary account, as one planted by another
+	// user would be.
+	sd, err := windows.SecurityDescriptorFromString("O:" + tu.User.Sid.String() + "D:(A;;GA;;;WD)")
+	require.NoError(t, err)
+	sa := &windows.SecurityAttributes{SecurityDescriptor: sd}
+	sa.Length = uint32(unsafe.Sizeof(*sa))
+	name, err := windows.UTF16PtrFromString(path)
+	require.NoError(t, err)
+	h, err := windows.CreateNamedPipe(name, windows.PIPE_ACCESS_DUPLEX, windows.PIPE_TYPE_BYTE, windows.PIPE_UNLIMITED_INSTANCES, 0, 0, 0, sa)
+	require.NoError(t, err)
+	defer windows.CloseHandle(h)
+
+	err = checkSocketPermissions(path)
+	var unsafeErr *UnsafeSocketError
+	require.True(t, errors.As(err, &unsafeErr), "err = %v", err)
+	assert.Contains(t, unsafeErr.Reason, "not SYSTEM or Administrators")
+}
@@ -0,0 +1,158 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// SSHDConfig is the SSH server's global configuration, keyed by lowercase
+// option name as `sshd -T` prints it.
+type SSHDConfig struct {
+	// Source is "sshd -T" when the server reported its effective
+	// configuration, otherwise the config file that was parsed. A parsed
+	// file only has the options it sets; the rest are sshd's defaults.
+	Source   string            `json:"source"`
+	Settings map[string]string `json:"settings"`
+}
+
+// maxSSHDIncludeDepth matches sshd's own limit on nested Include files.
+const maxSSHDIncludeDepth = 16
+
+// CollectSSHDConfig reads the SSH server configuration at path (the
+// platform default when empty). It asks `sshd -T` for the effective
+// configuration, which needs root and the host keys, and otherwise
+// parses the file and its Includes itself. It returns nil when there is
+// no config file, i.e. no SSH server.
+func CollectSSHDConfig(ctx context.Context, path string) (*SSHDConfig, error) {
+	if path == "" {
+		path = DefaultSSHDConfigPath()
+	}
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if bin := sshdBinary(); bin != "" {
+		out, err := exec.CommandContext(ctx, bin, "-T", "-f", path).Output()
+		if err == nil {
+			if s := parseSSHDDump(string(out)); len(s) > 0 {
+				return &SSHDConfig{Source: "sshd -T", Settings: s}, nil
+			}
+		}
+	}
+	settings := map[string]string{}
+	if err := readSSHDConfig("", path, filepath.Dir(path), settings, 0); err != nil {
+		return nil, err
+	}
+	return &SSHDConfig{Source: path, Settings: settings}, nil
+}
+
+// sshdBinary finds sshd, which usually lives in an sbin directory that
+// isn't on an unprivileged PATH.
+func sshdBinary() string {
+	if p, err := exec.LookPath("sshd"); err == nil {
+		return p
+	}
+	for _, p := range sshdPaths() {
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// parseSSHDDump reads `sshd -T` output: one "option value" per line.
+// Options that may repeat (e.g. hostkey) keep their first value.
+func parseSSHDDump(out string) map[string]string {
+	settings := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		k, v, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if k == "" {
+			continue
+		}
+		if _, ok := settings[k]; !ok {
+			settings[k] = strings.TrimSpace(v)
+		}
+	}
+	return settings
+}
+
+// readSSHDConfig adds the global options set in path to settings. As in
+// sshd the first value for an option wins, Include is expanded where it
+// appears (relative patterns against dir, absolute ones under root when
+// set), and a Match block ends the global section.
+func readSSHDConfig(root, path, dir string, settings map[string]string, depth int) error {
+	if depth > maxSSHDIncludeDepth {
+		return fmt.Errorf("%s: includes nested too deeply", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return parseSSHDConfig(f, settings, func(patterns []string) error {
+		for _, pat := range patterns {
+			switch {
+			case !filepath.IsAbs(pat):
+				pat = filepath.Join(dir, pat)
+			case root != "":
+				pat = filepath.Join(root, pat)
+			}
+			matches, _ := filepath.Glob(pat)
+			for _, m := range matches {
+				if err := readSSHDConfig(root, m, dir, settings, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// parseSSHDConfig reads one config file into settings, calling include
+// for each Include line. A Match block in an included file only ends
+// that file's global section, as in current OpenSSH.
+func parseSSHDConfig(r io.Reader, settings map[string]string, include func([]string) error) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		k, v := sshdKeyValue(line)
+		switch k {
+		case "match":
+			return nil
+		case "include":
+			if err := include(strings.Fields(v)); err != nil {
+				return err
+			}
+		default:
+			if _, ok := settings[k]; !ok {
+				settings[k] = v
+			}
+		}
+	}
+	return sc.Err()
+}
+
+// sshdKeyValue splits "Key value", "Key=value" or "Key = value",
+// lowercasing the key and unquoting the value.
+func sshdKeyValue(line string) (string, string) {
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return strings.ToLower(line), ""
+	}
+	k, v := line[:i], strings.TrimLeft(line[i:], " \t")
+	v = strings.TrimSpace(strings.TrimPrefix(v, "="))
+	return strings.ToLower(k), strings.Trim(v, `"`)
+}
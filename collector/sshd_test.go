@@ -0,0 +1,57 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSSHDConfig(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sshd_config.d"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sshd_config.d", "50-cloud.conf"), []byte(`
+PasswordAuthentication yes
+Match User backup
+	PermitRootLogin yes
+`), 0o644))
+	main := filepath.Join(dir, "sshd_config")
+	require.NoError(t, os.WriteFile(main, []byte(`# hardened
+Include sshd_config.d/*.conf
+PermitRootLogin=no
+PasswordAuthentication no
+MaxAuthTries = 3
+Banner "/etc/issue.net"
+
+Match Address 10.0.0.0/8
+	PasswordAuthentication yes
+`), 0o644))
+
+	settings := map[string]string{}
+	require.NoError(t, readSSHDConfig("", main, dir, settings, 0))
+	assert.Equal(t, map[string]string{
+		"passwordauthentication": "yes", // the drop-in comes first
+		"permitrootlogin":        "no",
+		"maxauthtries":           "3",
+		"banner":                 "/etc/issue.net",
+	}, settings)
+}
+
+func TestParseSSHDDump(t *testing.T) {
+	out := "port 22\nhostkey /etc/ssh/ssh_host_rsa_key\nhostkey /etc/ssh/ssh_host_ed25519_key\npermitrootlogin without-password\nmaxauthtries 6\n"
+	assert.Equal(t, map[string]string{
+		"port":            "22",
+		"hostkey":         "/etc/ssh/ssh_host_rsa_key",
+		"permitrootlogin": "without-password",
+		"maxauthtries":    "6",
+	}, parseSSHDDump(out))
+}
+
+func TestCollectSSHDConfig_NoServer(t *testing.T) {
+	cfg, err := CollectSSHDConfig(context.Background(), filepath.Join(t.TempDir(), "sshd_config"))
+	require.NoError(t, err)
+	assert.Nil(t, cfg)
+}
@@ -2,7 +2,6 @@ package collector
 
 import (
 	"os/exec"
-	"runtime"
 	"strconv"
 	"strings"
 )
@@ -10,16 +9,17 @@ import (
 // SystemMetrics is the lightweight host-level summary that feeds the
 // ML feature builder.
 type SystemMetrics struct {
-	LoadAvg1m     float64 `json:"load_avg_1m"`
-	LoadAvg5m     float64 `json:"load_avg_5m"`
-	LoadAvg15m    float64 `json:"load_avg_15m"`
-	MemTotalKB    int64   `json:"mem_total_kb"`
-	MemFreeKB     int64   `json:"mem_free_kb"`
-	CPUCount      int     `json:"cpu_count"`
+	LoadAvg1m  float64 `json:"load_avg_1m"`
+	LoadAvg5m  float64 `json:"load_avg_5m"`
+	LoadAvg15m float64 `json:"load_avg_15m"`
+	MemTotalKB int64   `json:"mem_total_kb"`
+	MemFreeKB  int64   `json:"mem_free_kb"`
+	CPUCount   int     `json:"cpu_count"`
 }
 
 // CollectSystemMetrics is best-effort: failures in any one field don't
-// fail the whole call, so a partially populated struct is normal.
+// fail the whole call, so a partially populated struct is normal. The
+// readers are per platform (sysmetrics_<os>.go).
 func CollectSystemMetrics() (SystemMetrics, error) {
 	var s SystemMetrics
 	s.LoadAvg1m, s.LoadAvg5m, s.LoadAvg15m = readLoadAvg()
@@ -28,12 +28,18 @@ func CollectSystemMetrics() (SystemMetrics, error) {
 	return s, nil
 }
 
-func readLoadAvg() (float64, float64, float64) {
+// uptimeLoadAvg reads the load averages from uptime(1).
+func uptimeLoadAvg() (float64, float64, float64) {
 	out, err := exec.Command("uptime").Output()
 	if err != nil {
 		return 0, 0, 0
 	}
-	s := string(out)
+	return parseUptimeLoadAvg(string(out))
+}
+
+// parseUptimeLoadAvg handles "load average:" (Linux, BSD) and "load
+// averages:" (macOS).
+func parseUptimeLoadAvg(s string) (float64, float64, float64) {
 	idx := strings.Index(s, "load average")
 	if idx < 0 {
 		idx = strings.Index(s, "load averages")
@@ -59,48 +65,18 @@ func readLoadAvg() (float64, float64, float64) {
 	return parse(0), parse(1), parse(2)
 }
 
-func readCPUCount() int {
-	switch runtime.GOOS {
-	case "darwin":
-		out, err := exec.Command("sysctl", "-n", "hw.ncpu").Output()
-		if err == nil {
-			n, _ := strconv.Atoi(strings.TrimSpace(string(out)))
-			return n
-		}
-	case "linux":
-		out, err := exec.Command("nproc").Output()
-		if err == nil {
-			n, _ := strconv.Atoi(strings.TrimSpace(string(out)))
-			return n
-		}
-	}
-	return runtime.NumCPU()
-}
-
-func readMem() (total, free int64) {
-	switch runtime.GOOS {
-	case "linux":
-		out, err := exec.Command("cat", "/proc/meminfo").Output()
-		if err != nil {
-			return
-		}
-		for _, line := range strings.Split(string(out), "\n") {
-			f := strings.Fields(line)
-			if len(f) < 2 {
-				continue
-			}
-			switch f[0] {
-			case "MemTotal:":
-				total, _ = strconv.ParseInt(f[1], 10, 64)
-			case "MemAvailable:":
-				free, _ = strconv.ParseInt(f[1], 10, 64)
-			}
+// parseMeminfo returns MemTotal and MemAvailable in KiB.
+func parseMeminfo(meminfo string) (total, free int64) {
+	for _, line := range strings.Split(meminfo, "\n") {
+		f := strings.Fields(line)
+		if len(f) < 2 {
+			continue
 		}
-	case "darwin":
-		out, err := exec.Command("sysctl", "-n", "hw.memsize").Output()
-		if err == nil {
-			t, _ := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
-			total = t / 1024
+		switch f[0] {
+		case "MemTotal:":
+			total, _ = strconv.ParseInt(f[1], 10, 64)
+		case "MemAvailable:":
+			free, _ = strconv.ParseInt(f[1], 10, 64)
 		}
 	}
 	return
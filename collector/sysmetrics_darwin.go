@@ -0,0 +1,32 @@
+package collector
+
+import (
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+func readLoadAvg() (float64, float64, float64) {
+	return uptimeLoadAvg()
+}
+
+func readCPUCount() int {
+	out, err := exec.Command("sysctl", "-n", "hw.ncpu").Output()
+	if err == nil {
+		if n, _ := strconv.Atoi(strings.TrimSpace(string(out))); n > 0 {
+			return n
+		}
+	}
+	return runtime.NumCPU()
+}
+
+// readMem reports total memory only; free is left 0.
+func readMem() (total, free int64) {
+	out, err := exec.Command("sysctl", "-n", "hw.memsize").Output()
+	if err == nil {
+		t, _ := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		total = t / 1024
+	}
+	return
+}
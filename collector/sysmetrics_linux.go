@@ -0,0 +1,39 @@
+package collector
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Linux reads /proc directly rather than through uptime, nproc and cat,
+// which BusyBox may lack or format differently.
+
+func readLoadAvg() (float64, float64, float64) {
+	b, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return uptimeLoadAvg()
+	}
+	f := strings.Fields(string(b))
+	if len(f) < 3 {
+		return 0, 0, 0
+	}
+	l1, _ := strconv.ParseFloat(f[0], 64)
+	l5, _ := strconv.ParseFloat(f[1], 64)
+	l15, _ := strconv.ParseFloat(f[2], 64)
+	return l1, l5, l15
+}
+
+// readCPUCount is the CPUs this process may run on, as nproc reports.
+func readCPUCount() int {
+	return runtime.NumCPU()
+}
+
+func readMem() (total, free int64) {
+	b, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return
+	}
+	return parseMeminfo(string(b))
+}
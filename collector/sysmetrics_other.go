@@ -0,0 +1,18 @@
+//go:build !linux && !darwin
+
+package collector
+
+import "runtime"
+
+func readLoadAvg() (float64, float64, float64) {
+	return uptimeLoadAvg()
+}
+
+func readCPUCount() int {
+	return runtime.NumCPU()
+}
+
+// readMem is not implemented on this platform.
+func readMem() (total, free int64) {
+	return 0, 0
+}
@@ -0,0 +1,422 @@
+package collector
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// TLSService is a local listener that answered a TLS handshake, with the
+// protocol versions and cipher suites it accepted.
+type TLSService struct {
+	Port int `json:"port"`
+	// Address is what was probed: loopback for wildcard binds, else the
+	// bound local address.
+	Address  string   `json:"address"`
+	Versions []string `json:"versions"`
+	// CipherSuites lists the accepted suites per version, in the order
+	// the server preferred them. TLS 1.3 records only the negotiated one.
+	CipherSuites map[string][]string `json:"cipher_suites"`
+}
+
+// tlsProbeTimeout bounds each handshake attempt; the probes are local, so
+// anything slower is a service that doesn't speak TLS.
+const tlsProbeTimeout = 3 * time.Second
+
+// ProbeTLS handshakes with every listening TCP port (or only ports, when
+// given) on this host and records what each TLS service accepts. Only
+// loopback and the host's own addresses are ever dialed. SSLv3 and the
+// anonymous, NULL and export suites are offered with hand-built
+// ClientHellos, since crypto/tls no longer speaks them.
+func ProbeTLS(ctx context.Context, bindings []PortBinding, ports []int) ([]TLSService, error) {
+	local, err := localAddrs()
+	if err != nil {
+		return nil, err
+	}
+	want := map[int]bool{}
+	for _, p := range ports {
+		want[p] = true
+	}
+	seen := map[int]bool{}
+	var out []TLSService
+	for _, b := range bindings {
+		if b.Protocol != "tcp" || seen[b.Port] || (len(want) > 0 && !want[b.Port]) {
+			continue
+		}
+		host, ok := probeTarget(b.Address, local)
+		if !ok {
+			continue
+		}
+		seen[b.Port] = true
+		if err := ctx.Err(); err != nil {
+			return out, err
+		}
+		if svc, ok := probeTLSService(ctx, net.JoinHostPort(host, strconv.Itoa(b.Port))); ok {
+			svc.Port = b.Port
+			svc.Address = host
+			out = append(out, svc)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Port < out[j].Port })
+	return out, ctx.Err()
+}
+
+func localAddrs() (map[string]bool, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	local := map[string]bool{}
+	for _, a := range addrs {
+		if ip, _, err := net.ParseCIDR(a.String()); err == nil {
+			local[ip.String()] = true
+		}
+	}
+	return local, nil
+}
+
+// probeTarget picks the address to dial for a listener bound to addr, and
+// refuses anything that isn't this host.
+func probeTarget(addr string, local map[string]bool) (string, bool) {
+	switch addr {
+	case "", "*", "0.0.0.0":
+		return "127.0.0.1", true
+	case "::", "[::]":
+		return "::1", true
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return "", false
+	}
+	if ip.IsLoopback() || local[ip.String()] {
+		return ip.String(), true
+	}
+	return "", false
+}
+
+// Protocol versions on the wire.
+const (
+	versionSSL30 uint16 = 0x0300
+	versionTLS10 uint16 = 0x0301
+	versionTLS11 uint16 = 0x0302
+	versionTLS12 uint16 = 0x0303
+	versionTLS13 uint16 = 0x0304
+)
+
+// TLSVersionName names a wire protocol version the way reports do.
+func TLSVersionName(v uint16) string {
+	switch v {
+	case versionSSL30:
+		return "SSLv3"
+	case versionTLS10:
+		return "TLS1.0"
+	case versionTLS11:
+		return "TLS1.1"
+	case versionTLS12:
+		return "TLS1.2"
+	case versionTLS13:
+		return "TLS1.3"
+	}
+	return fmt.Sprintf("0x%04x", v)
+}
+
+// probeTLSService enumerates versions and suites for one listener. It
+// reports false for services that don't answer like TLS.
+func probeTLSService(ctx context.Context, addr string) (TLSService, bool) {
+	svc := TLSService{CipherSuites: map[string][]string{}}
+	// TLS 1.2 goes first: a service that doesn't answer it with TLS
+	// records (even an alert) isn't TLS, and the rest is skipped.
+	found := map[uint16][]uint16{}
+	for _, v := range []uint16{versionTLS12, versionTLS11, versionTLS10, versionSSL30} {
+		suites, speaksTLS := enumerateSuites(ctx, addr, v)
+		if v == versionTLS12 && !speaksTLS {
+			return svc, false
+		}
+		found[v] = suites
+	}
+	for _, v := range []uint16{versionSSL30, versionTLS10, versionTLS11, versionTLS12} {
+		if len(found[v]) == 0 {
+			continue
+		}
+		name := TLSVersionName(v)
+		svc.Versions = append(svc.Versions, name)
+		for _, id := range found[v] {
+			svc.CipherSuites[name] = append(svc.CipherSuites[name], cipherSuiteName(id))
+		}
+	}
+	if suite, ok := probeTLS13(ctx, addr); ok {
+		svc.Versions = append(svc.Versions, "TLS1.3")
+		svc.CipherSuites["TLS1.3"] = []string{tls.CipherSuiteName(suite)}
+	}
+	return svc, len(svc.Versions) > 0
+}
+
+// enumerateSuites offers every known suite at version v, removing the
+// server's pick each round until it refuses, so the result is in server
+// preference order. speaksTLS reports whether the server answered with
+// TLS records at all.
+func enumerateSuites(ctx context.Context, addr string, v uint16) (accepted []uint16, speaksTLS bool) {
+	offered := make([]uint16, len(cipherSuites))
+	for i, cs := range cipherSuites {
+		offered[i] = cs.id
+	}
+	for len(offered) > 0 && ctx.Err() == nil {
+		hello, err := sendClientHello(ctx, addr, v, offered)
+		if err != nil {
+			var alert tlsAlertError
+			return accepted, speaksTLS || errors.As(err, &alert)
+		}
+		speaksTLS = true
+		// A lower version back means v itself isn't enabled.
+		if hello.version != v {
+			return accepted, true
+		}
+		i := indexOf(offered, hello.suite)
+		if i < 0 {
+			return accepted, true
+		}
+		accepted = append(accepted, hello.suite)
+		offered = append(offered[:i], offered[i+1:]...)
+	}
+	return accepted, speaksTLS
+}
+
+func indexOf(xs []uint16, x uint16) int {
+	for i, e := range xs {
+		if e == x {
+			return i
+		}
+	}
+	return -1
+}
+
+// probeTLS13 completes a TLS 1.3 handshake with crypto/tls, which is the
+// only way to learn the version: TLS 1.3 hides it behind a key exchange
+// the hand-built hello doesn't do.
+func probeTLS13(ctx context.Context, addr string) (uint16, bool) {
+	d := tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: tlsProbeTimeout},
+		Config: &tls.Config{
+			// Configuration, not identity, is being checked.
+			InsecureSkipVerify: true,
+			MinVersion:         tls.VersionTLS13,
+			MaxVersion:         tls.VersionTLS13,
+		},
+	}
+	ctx, cancel := context.WithTimeout(ctx, tlsProbeTimeout)
+	defer cancel()
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return 0, false
+	}
+	defer conn.Close()
+	return conn.(*tls.Conn).ConnectionState().CipherSuite, true
+}
+
+type serverHello struct {
+	version uint16
+	suite   uint16
+}
+
+// tlsAlertError is the server refusing a hello with a TLS alert.
+type tlsAlertError struct {
+	level, desc byte
+}
+
+func (e tlsAlertError) Error() string {
+	return fmt.Sprintf("tls alert %d/%d", e.level, e.desc)
+}
+
+// sendClientHello opens a connection, sends one ClientHello and reads the
+// ServerHello or alert that comes back.
+func sendClientHello(ctx context.Context, addr string, v uint16, suites []uint16) (serverHello, error) {
+	d := net.Dialer{Timeout: tlsProbeTimeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return serverHello{}, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(tlsProbeTimeout))
+	if _, err := conn.Write(buildClientHello(v, suites)); err != nil {
+		return serverHello{}, err
+	}
+	return readServerHello(conn)
+}
+
+// buildClientHello returns a ClientHello record for version v offering
+// suites. TLS hellos carry the extensions ECDHE suites and TLS 1.2
+// signatures need; SSLv3 ones carry none, as SSLv3 servers may choke on
+// them.
+func buildClientHello(v uint16, suites []uint16) []byte {
+	var body []byte
+	body = binary.BigEndian.AppendUint16(body, v)
+	random := make([]byte, 32)
+	_, _ = rand.Read(random)
+	body = append(body, random...)
+	body = append(body, 0) // no session ID
+	body = binary.BigEndian.AppendUint16(body, uint16(2*len(suites)))
+	for _, s := range suites {
+		body = binary.BigEndian.AppendUint16(body, s)
+	}
+	body = append(body, 1, 0) // null compression only
+
+	if v >= versionTLS10 {
+		var ext []byte
+		// supported_groups: x25519, P-256, P-384, P-521
+		ext = appendExtension(ext, 0x000a, []byte{0, 8, 0, 0x1d, 0, 0x17, 0, 0x18, 0, 0x19})
+		// ec_point_formats: uncompressed
+		ext = appendExtension(ext, 0x000b, []byte{1, 0})
+		if v >= versionTLS12 {
+			var algs []byte
+			for _, a := range []uint16{0x0403, 0x0503, 0x0603, 0x0804, 0x0805, 0x0806, 0x0401, 0x0501, 0x0601, 0x0201, 0x0203} {
+				algs = binary.BigEndian.AppendUint16(algs, a)
+			}
+			ext = appendExtension(ext, 0x000d, binary.BigEndian.AppendUint16(nil, uint16(len(algs))), algs...)
+		}
+		// renegotiation_info, empty: some servers refuse hellos without it.
+		ext = appendExtension(ext, 0xff01, []byte{0})
+		body = binary.BigEndian.AppendUint16(body, uint16(len(ext)))
+		body = append(body, ext...)
+	}
+
+	hs := []byte{1, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}
+	hs = append(hs, body...)
+	recVersion := min(v, versionTLS10)
+	rec := []byte{22}
+	rec = binary.BigEndian.AppendUint16(rec, recVersion)
+	rec = binary.BigEndian.AppendUint16(rec, uint16(len(hs)))
+	return append(rec, hs...)
+}
+
+func appendExtension(b []byte, typ uint16, data []byte, more ...byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, typ)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)+len(more)))
+	b = append(b, data...)
+	return append(b, more...)
+}
+
+// readServerHello reads the first record and pulls the version and suite
+// out of a ServerHello, or the alert the server sent instead.
+func readServerHello(r io.Reader) (serverHello, error) {
+	hdr := make([]byte, 5)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return serverHello{}, err
+	}
+	typ, n := hdr[0], int(binary.BigEndian.Uint16(hdr[3:]))
+	if hdr[1] != 3 || n == 0 || n > 1<<14+2048 {
+		return serverHello{}, fmt.Errorf("not a TLS record")
+	}
+	rec := make([]byte, n)
+	if _, err := io.ReadFull(r, rec); err != nil {
+		return serverHello{}, err
+	}
+	switch typ {
+	case 21:
+		if len(rec) < 2 {
+			return serverHello{}, fmt.Errorf("short alert")
+		}
+		return serverHello{}, tlsAlertError{rec[0], rec[1]}
+	case 22:
+	default:
+		return serverHello{}, fmt.Errorf("unexpected record type %d", typ)
+	}
+	// handshake type(1) length(3) version(2) random(32) session_id(1+n) suite(2)
+	if len(rec) < 39 || rec[0] != 2 {
+		return serverHello{}, fmt.Errorf("not a ServerHello")
+	}
+	sidLen := int(rec[38])
+	if len(rec) < 39+sidLen+2 {
+		return serverHello{}, fmt.Errorf("short ServerHello")
+	}
+	return serverHello{
+		version: binary.BigEndian.Uint16(rec[4:]),
+		suite:   binary.BigEndian.Uint16(rec[39+sidLen:]),
+	}, nil
+}
+
+// cipherSuites are the suites offered during enumeration: everything
+// current servers negotiate plus the legacy, anonymous, NULL and export
+// suites compliance cares about finding.
+var cipherSuites = []struct {
+	id   uint16
+	name string
+}{
+	{0xc02b, "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+	{0xc02f, "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+	{0xc02c, "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"},
+	{0xc030, "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+	{0xcca9, "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"},
+	{0xcca8, "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"},
+	{0xccaa, "TLS_DHE_RSA_WITH_CHACHA20_POLY1305_SHA256"},
+	{0x009e, "TLS_DHE_RSA_WITH_AES_128_GCM_SHA256"},
+	{0x009f, "TLS_DHE_RSA_WITH_AES_256_GCM_SHA384"},
+	{0xc023, "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256"},
+	{0xc024, "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA384"},
+	{0xc027, "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256"},
+	{0xc028, "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA384"},
+	{0xc009, "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA"},
+	{0xc00a, "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA"},
+	{0xc013, "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA"},
+	{0xc014, "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA"},
+	{0x0067, "TLS_DHE_RSA_WITH_AES_128_CBC_SHA256"},
+	{0x006b, "TLS_DHE_RSA_WITH_AES_256_CBC_SHA256"},
+	{0x0033, "TLS_DHE_RSA_WITH_AES_128_CBC_SHA"},
+	{0x0039, "TLS_DHE_RSA_WITH_AES_256_CBC_SHA"},
+	{0x009c, "TLS_RSA_WITH_AES_128_GCM_SHA256"},
+	{0x009d, "TLS_RSA_WITH_AES_256_GCM_SHA384"},
+	{0x003c, "TLS_RSA_WITH_AES_128_CBC_SHA256"},
+	{0x003d, "TLS_RSA_WITH_AES_256_CBC_SHA256"},
+	{0x002f, "TLS_RSA_WITH_AES_128_CBC_SHA"},
+	{0x0035, "TLS_RSA_WITH_AES_256_CBC_SHA"},
+	{0x0041, "TLS_RSA_WITH_CAMELLIA_128_CBC_SHA"},
+	{0x0084, "TLS_RSA_WITH_CAMELLIA_256_CBC_SHA"},
+	{0xc012, "TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA"},
+	{0x0016, "TLS_DHE_RSA_WITH_3DES_EDE_CBC_SHA"},
+	{0x000a, "TLS_RSA_WITH_3DES_EDE_CBC_SHA"},
+	{0xc007, "TLS_ECDHE_ECDSA_WITH_RC4_128_SHA"},
+	{0xc011, "TLS_ECDHE_RSA_WITH_RC4_128_SHA"},
+	{0x0005, "TLS_RSA_WITH_RC4_128_SHA"},
+	{0x0004, "TLS_RSA_WITH_RC4_128_MD5"},
+	{0x0009, "TLS_RSA_WITH_DES_CBC_SHA"},
+	{0x00a6, "TLS_DH_anon_WITH_AES_128_GCM_SHA256"},
+	{0x00a7, "TLS_DH_anon_WITH_AES_256_GCM_SHA384"},
+	{0x006c, "TLS_DH_anon_WITH_AES_128_CBC_SHA256"},
+	{0x006d, "TLS_DH_anon_WITH_AES_256_CBC_SHA256"},
+	{0x0034, "TLS_DH_anon_WITH_AES_128_CBC_SHA"},
+	{0x003a, "TLS_DH_anon_WITH_AES_256_CBC_SHA"},
+	{0x001b, "TLS_DH_anon_WITH_3DES_EDE_CBC_SHA"},
+	{0x001a, "TLS_DH_anon_WITH_DES_CBC_SHA"},
+	{0x0018, "TLS_DH_anon_WITH_RC4_128_MD5"},
+	{0xc018, "TLS_ECDH_anon_WITH_AES_128_CBC_SHA"},
+	{0xc019, "TLS_ECDH_anon_WITH_AES_256_CBC_SHA"},
+	{0xc017, "TLS_ECDH_anon_WITH_3DES_EDE_CBC_SHA"},
+	{0xc016, "TLS_ECDH_anon_WITH_RC4_128_SHA"},
+	{0xc015, "TLS_ECDH_anon_WITH_NULL_SHA"},
+	{0x0001, "TLS_RSA_WITH_NULL_MD5"},
+	{0x0002, "TLS_RSA_WITH_NULL_SHA"},
+	{0x003b, "TLS_RSA_WITH_NULL_SHA256"},
+	{0xc006, "TLS_ECDHE_ECDSA_WITH_NULL_SHA"},
+	{0xc010, "TLS_ECDHE_RSA_WITH_NULL_SHA"},
+	{0x0003, "TLS_RSA_EXPORT_WITH_RC4_40_MD5"},
+	{0x0008, "TLS_RSA_EXPORT_WITH_DES40_CBC_SHA"},
+	{0x0014, "TLS_DHE_RSA_EXPORT_WITH_DES40_CBC_SHA"},
+	{0x0017, "TLS_DH_anon_EXPORT_WITH_RC4_40_MD5"},
+	{0x0019, "TLS_DH_anon_EXPORT_WITH_DES40_CBC_SHA"},
+}
+
+func cipherSuiteName(id uint16) string {
+	for _, cs := range cipherSuites {
+		if cs.id == id {
+			return cs.name
+		}
+	}
+	return fmt.Sprintf("0x%04X", id)
+}
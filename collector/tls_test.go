@@ -0,0 +1,160 @@
+package collector
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeTarget(t *testing.T) {
+	local := map[string]bool{"10.0.0.5": true}
+	for addr, want := range map[string]string{
+		"0.0.0.0":   "127.0.0.1",
+		"::":        "::1",
+		"127.0.0.1": "127.0.0.1",
+		"10.0.0.5":  "10.0.0.5",
+		"10.0.0.6":  "",
+		"bogus":     "",
+	} {
+		got, ok := probeTarget(addr, local)
+		assert.Equal(t, want, got, addr)
+		assert.Equal(t, want != "", ok, addr)
+	}
+}
+
+func TestProbeTLS_GoServer(t *testing.T) {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{selfSigned(t)},
+		MinVersion:   tls.VersionTLS10,
+	})
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				_ = c.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	svcs, err := ProbeTLS(context.Background(), []PortBinding{{Port: port, Protocol: "tcp", Address: "127.0.0.1"}}, nil)
+	require.NoError(t, err)
+	require.Len(t, svcs, 1)
+	assert.Equal(t, port, svcs[0].Port)
+	assert.Contains(t, svcs[0].Versions, "TLS1.2")
+	assert.Contains(t, svcs[0].Versions, "TLS1.3")
+	assert.NotContains(t, svcs[0].Versions, "SSLv3")
+	assert.Contains(t, svcs[0].CipherSuites["TLS1.2"], "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256")
+}
+
+func TestProbeTLS_SkipsNonTLS(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = c.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+			c.Close()
+		}
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+	svcs, err := ProbeTLS(context.Background(), []PortBinding{{Port: port, Protocol: "tcp", Address: "0.0.0.0"}}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, svcs)
+}
+
+// A server only a hand-built hello can find: TLS 1.0 with an anonymous
+// suite.
+func TestProbeTLS_FindsAnonymousSuite(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveLegacyHello(c, versionTLS10, 0x0034)
+		}
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	svcs, err := ProbeTLS(context.Background(), []PortBinding{{Port: port, Protocol: "tcp"}}, []int{port})
+	require.NoError(t, err)
+	require.Len(t, svcs, 1)
+	assert.Equal(t, []string{"TLS1.0"}, svcs[0].Versions)
+	assert.Equal(t, []string{"TLS_DH_anon_WITH_AES_128_CBC_SHA"}, svcs[0].CipherSuites["TLS1.0"])
+}
+
+// serveLegacyHello answers a ClientHello at version v that offers suite
+// with a ServerHello picking it, and anything else with a handshake
+// failure alert.
+func serveLegacyHello(c net.Conn, v, suite uint16) {
+	defer c.Close()
+	hdr := make([]byte, 5)
+	if _, err := io.ReadFull(c, hdr); err != nil {
+		return
+	}
+	rec := make([]byte, binary.BigEndian.Uint16(hdr[3:]))
+	if _, err := io.ReadFull(c, rec); err != nil {
+		return
+	}
+	// type(1) length(3) version(2) random(32) session_id(1+n) suites(2+n)
+	offered := binary.BigEndian.Uint16(rec[4:]) == v
+	off := 39 + int(rec[38])
+	n := int(binary.BigEndian.Uint16(rec[off:]))
+	found := false
+	for i := 0; i < n; i += 2 {
+		found = found || binary.BigEndian.Uint16(rec[off+2+i:]) == suite
+	}
+	if !offered || !found {
+		_, _ = c.Write([]byte{21, 3, 1, 0, 2, 2, 40})
+		return
+	}
+	body := binary.BigEndian.AppendUint16(nil, v)
+	body = append(body, make([]byte, 32)...)
+	body = append(body, 0)
+	body = binary.BigEndian.AppendUint16(body, suite)
+	body = append(body, 0)
+	hs := append([]byte{2, 0, byte(len(body) >> 8), byte(len(body))}, body...)
+	out := append([]byte{22, 3, 1, 0, byte(len(hs))}, hs...)
+	_, _ = c.Write(out)
+}
+
+func selfSigned(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
@@ -0,0 +1,199 @@
+package collector
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Typed collection models. JSON tags keep the field names the report has
+// always used; numeric IDs are now numbers, and -1 means "unknown" where a
+// source can't provide one (e.g. UIDs on Windows).
+
+// User is a local account.
+type User struct {
+	Username    string `json:"username"`
+	UID         int    `json:"uid"`
+	GID         int    `json:"gid"`
+	Description string `json:"description,omitempty"`
+	Directory   string `json:"directory"`
+	Shell       string `json:"shell"`
+	// SID is set on Windows, where UID holds the SID's RID.
+	SID string `json:"sid,omitempty"`
+}
+
+// Process is one running process.
+type Process struct {
+	PID     int    `json:"pid"`
+	Name    string `json:"name"`
+	Path    string `json:"path,omitempty"`
+	Cmdline string `json:"cmdline,omitempty"`
+	UID     int    `json:"uid"`
+	// User is the owning account name when the source reports a name
+	// rather than a UID (ps aux).
+	User string `json:"user,omitempty"`
+}
+
+// Package is one installed software package.
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Source  string `json:"source"`
+	Arch    string `json:"arch,omitempty"`
+	// SHA1 is the hex SHA-1 digest the package database records, where
+	// it keeps one: rpm's header digest or apk's checksum of the
+	// package's control data. SBOMs carry it as the package checksum.
+	SHA1 string `json:"sha1,omitempty"`
+}
+
+// PortBinding is a listening socket and, where the source can see it,
+// the process that owns it.
+type PortBinding struct {
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"` // "tcp" | "udp"
+	Address  string `json:"address,omitempty"`
+	PID      int    `json:"pid"`
+	Process  string `json:"process,omitempty"`
+}
+
+// Connection is an established connection to a remote host. Country,
+// ASN and ASOrg are filled in by GeoIP enrichment when a database is
+// configured.
+type Connection struct {
+	PID           int    `json:"pid"`
+	Process       string `json:"process,omitempty"`
+	Protocol      string `json:"protocol"`
+	LocalAddress  string `json:"local_address"`
+	LocalPort     int    `json:"local_port"`
+	RemoteAddress string `json:"remote_address"`
+	RemotePort    int    `json:"remote_port"`
+	// Country is the ISO 3166-1 alpha-2 code of the remote address.
+	Country string `json:"country,omitempty"`
+	ASN     uint   `json:"asn,omitempty"`
+	ASOrg   string `json:"as_org,omitempty"`
+}
+
+// Ports returns the distinct port numbers in bindings, in first-seen
+// order, for consumers that only care about the number.
+func Ports(bindings []PortBinding) []int {
+	seen := map[int]bool{}
+	var out []int
+	for _, b := range bindings {
+		if !seen[b.Port] {
+			seen[b.Port] = true
+			out = append(out, b.Port)
+		}
+	}
+	return out
+}
+
+// atoiOr parses s as an int, returning def when s is empty or invalid.
+func atoiOr(s string, def int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func usersFromRows(rows []map[string]string) []User {
+	users := make([]User, 0, len(rows))
+	for _, r := range rows {
+		users = append(users, User{
+			Username:    r["username"],
+			UID:         atoiOr(r["uid"], -1),
+			GID:         atoiOr(r["gid"], -1),
+			Description: r["description"],
+			Directory:   r["directory"],
+			Shell:       r["shell"],
+		})
+	}
+	return users
+}
+
+func processesFromRows(rows []map[string]string) []Process {
+	procs := make([]Process, 0, len(rows))
+	for _, r := range rows {
+		procs = append(procs, Process{
+			PID:     atoiOr(r["pid"], -1),
+			Name:    r["name"],
+			Path:    r["path"],
+			Cmdline: r["cmdline"],
+			UID:     atoiOr(r["uid"], -1),
+		})
+	}
+	return procs
+}
+
+func packagesFromRows(rows []map[string]string) []Package {
+	pkgs := make([]Package, 0, len(rows))
+	for _, r := range rows {
+		pkgs = append(pkgs, Package{
+			Name:    r["name"],
+			Version: r["version"],
+			Source:  r["source"],
+			Arch:    r["arch"],
+			SHA1:    r["sha1"],
+		})
+	}
+	return pkgs
+}
+
+// portsFromRows parses the listening_ports query result. osquery reports
+// the protocol as an IP protocol number.
+func portsFromRows(rows []map[string]string) []PortBinding {
+	ports := make([]PortBinding, 0, len(rows))
+	for _, r := range rows {
+		// osquery returns strings; safe parse
+		p := atoiOr(r["port"], 0)
+		if p <= 0 {
+			continue
+		}
+		ports = append(ports, PortBinding{
+			Port:     p,
+			Protocol: protocolName(r["protocol"]),
+			Address:  r["address"],
+			PID:      atoiOr(r["pid"], -1),
+			Process:  r["name"],
+		})
+	}
+	return ports
+}
+
+func connectionsFromRows(rows []map[string]string) []Connection {
+	conns := make([]Connection, 0, len(rows))
+	for _, r := range rows {
+		c := Connection{
+			PID:           atoiOr(r["pid"], -1),
+			Process:       r["name"],
+			Protocol:      protocolName(r["protocol"]),
+			LocalAddress:  r["local_address"],
+			LocalPort:     atoiOr(r["local_port"], 0),
+			RemoteAddress: r["remote_address"],
+			RemotePort:    atoiOr(r["remote_port"], 0),
+		}
+		if remoteConnection(c) {
+			conns = append(conns, c)
+		}
+	}
+	return conns
+}
+
+// remoteConnection drops loopback and half-parsed entries; only traffic
+// leaving the host is interesting.
+func remoteConnection(c Connection) bool {
+	ip := net.ParseIP(c.RemoteAddress)
+	return ip != nil && !ip.IsLoopback() && !ip.IsUnspecified() && c.RemotePort > 0
+}
+
+func protocolName(p string) string {
+	switch p {
+	case "6", "tcp":
+		return "tcp"
+	case "17", "udp":
+		return "udp"
+	case "":
+		return "tcp"
+	}
+	return p
+}
@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsersFromRows(t *testing.T) {
+	users := usersFromRows([]map[string]string{
+		{"username": "root", "uid": "0", "gid": "0", "directory": "/root", "shell": "/bin/bash"},
+		{"username": "odd", "uid": "", "gid": "x"},
+	})
+	require.Len(t, users, 2)
+	assert.Equal(t, 0, users[0].UID)
+	assert.Equal(t, -1, users[1].UID, "missing UID is unknown, not root")
+	assert.Equal(t, -1, users[1].GID)
+
+	b, err := json.Marshal(users[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"username":"root","uid":0,"gid":0,"directory":"/root","shell":"/bin/bash"}`, string(b))
+}
+
+func TestPortsFromRows(t *testing.T) {
+	got := portsFromRows([]map[string]string{
+		{"port": "53", "protocol": "17", "address": "127.0.0.53"},
+		{"port": "22", "protocol": "6", "address": "0.0.0.0", "pid": "812", "name": "sshd"},
+		{"port": "0"},
+	})
+	assert.Equal(t, []PortBinding{
+		{Port: 53, Protocol: "udp", Address: "127.0.0.53", PID: -1},
+		{Port: 22, Protocol: "tcp", Address: "0.0.0.0", PID: 812, Process: "sshd"},
+	}, got)
+	assert.Equal(t, []int{53, 22}, Ports(append(got, PortBinding{Port: 22, Protocol: "tcp", Address: "::"})))
+}
+
+func TestConnectionsFromRows(t *testing.T) {
+	got := connectionsFromRows([]map[string]string{
+		{"pid": "42", "name": "curl", "protocol": "6", "local_address": "10.0.0.5", "local_port": "51234", "remote_address": "203.0.113.7", "remote_port": "443"},
+		{"pid": "7", "name": "postgres", "protocol": "6", "local_address": "127.0.0.1", "local_port": "5432", "remote_address": "127.0.0.1", "remote_port": "40000"},
+	})
+	assert.Equal(t, []Connection{{
+		PID: 42, Process: "curl", Protocol: "tcp",
+		LocalAddress: "10.0.0.5", LocalPort: 51234,
+		RemoteAddress: "203.0.113.7", RemotePort: 443,
+	}}, got, "loopback connections are dropped")
+}
+
+func TestParseNetstatConnections(t *testing.T) {
+	linux := `Active Internet connections (w/o servers)
+Proto Recv-Q Send-Q Local Address           Foreign Address         State
+tcp        0      0 10.0.0.5:51234          203.0.113.7:443         ESTABLISHED
+tcp        0      0 127.0.0.1:5432          127.0.0.1:40000         ESTABLISHED
+tcp6       0      0 2001:db8::5:22          2001:db8::9:60000       ESTABLISHED
+tcp        0      0 10.0.0.5:51300          203.0.113.8:443         TIME_WAIT
+`
+	got := parseNetstatConnections(linux, ":")
+	require.Len(t, got, 2)
+	assert.Equal(t, "203.0.113.7", got[0].RemoteAddress)
+	assert.Equal(t, 443, got[0].RemotePort)
+	assert.Equal(t, -1, got[0].PID)
+	assert.Equal(t, "2001:db8::9", got[1].RemoteAddress)
+
+	darwin := "tcp4       0      0  192.168.1.5.51234      17.253.144.10.443      ESTABLISHED\n"
+	got = parseNetstatConnections(darwin, ".")
+	require.Len(t, got, 1)
+	assert.Equal(t, "17.253.144.10", got[0].RemoteAddress)
+	assert.Equal(t, 51234, got[0].LocalPort)
+}
+
+func TestParseSSListeners(t *testing.T) {
+	out := `tcp   LISTEN 0      128          0.0.0.0:22        0.0.0.0:*    users:(("sshd",pid=812,fd=3))
+tcp   LISTEN 0      4096   127.0.0.53%lo:53        0.0.0.0:*    users:(("systemd-resolve",pid=601,fd=14))
+tcp   LISTEN 0      511             [::]:8080         [::]:*
+udp   UNCONN 0      0                  *:5353            *:*    users:(("avahi-daemon",pid=700,fd=12),("avahi-daemon",pid=700,fd=13))
+`
+	assert.Equal(t, []PortBinding{
+		{Port: 22, Protocol: "tcp", Address: "0.0.0.0", PID: 812, Process: "sshd"},
+		{Port: 53, Protocol: "tcp", Address: "127.0.0.53", PID: 601, Process: "systemd-resolve"},
+		{Port: 8080, Protocol: "tcp", Address: "::", PID: -1},
+		{Port: 5353, Protocol: "udp", Address: "0.0.0.0", PID: 700, Process: "avahi-daemon"},
+	}, parseSSListeners(out))
+}
+
+func TestParseLsofListeners(t *testing.T) {
+	out := `COMMAND     PID   USER   FD   TYPE             DEVICE SIZE/OFF NODE NAME
+launchd       1   root   11u  IPv6 0x1234567890abcdef      0t0  TCP *:22 (LISTEN)
+launchd       1   root   12u  IPv6 0x1234567890abcdef      0t0  TCP *:22 (LISTEN)
+Code\x20Helper 4242 dev  30u  IPv4 0x1234567890abcde0      0t0  TCP 127.0.0.1:9229 (LISTEN)
+mDNSRespo   350   root    7u  IPv6 0x1234567890abcde1      0t0  UDP [::1]:5353
+Chrome      900    dev   40u  IPv4 0x1234567890abcde2      0t0  UDP 192.168.1.5:60000->142.250.74.3:443
+`
+	assert.Equal(t, []PortBinding{
+		{Port: 22, Protocol: "tcp", Address: "0.0.0.0", PID: 1, Process: "launchd"},
+		{Port: 9229, Protocol: "tcp", Address: "127.0.0.1", PID: 4242, Process: "Code Helper"},
+		{Port: 5353, Protocol: "udp", Address: "::1", PID: 350, Process: "mDNSRespo"},
+	}, parseLsofListeners(out))
+}
+
+func TestParseNetstatListeners(t *testing.T) {
+	out := `Active Internet connections (only servers)
+Proto Recv-Q Send-Q Local Address           Foreign Address         State
+tcp        0      0 0.0.0.0:22              0.0.0.0:*               LISTEN
+tcp6       0      0 :::80                   :::*                    LISTEN
+`
+	assert.Equal(t, []PortBinding{
+		{Port: 22, Protocol: "tcp", Address: "0.0.0.0", PID: -1},
+		{Port: 80, Protocol: "tcp", Address: "::", PID: -1},
+	}, parseNetstatListeners(out))
+}
@@ -0,0 +1,183 @@
+package collector
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// UserScope is what can be seen about one account: persistence it set up
+// (launch agents/login items, crontab), browser extensions, apps installed
+// without admin rights, SSH keys and screen-lock settings. User mode
+// collects it for the invoking account; system scans with workstation
+// rules collect it for every interactive account.
+type UserScope struct {
+	Username          string             `json:"username"`
+	Home              string             `json:"home"`
+	LaunchAgents      []string           `json:"launch_agents"`
+	Crontab           []string           `json:"crontab"`
+	BrowserExtensions []BrowserExtension `json:"browser_extensions"`
+	UserApps          []string           `json:"user_apps"`
+	AuthorizedKeys    []AuthorizedKey    `json:"authorized_keys"`
+	ScreenLock        ScreenLock         `json:"screen_lock"`
+}
+
+// BrowserExtension is one installed extension in a browser profile.
+type BrowserExtension struct {
+	Browser string `json:"browser"`
+	ID      string `json:"id"`
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// CollectUserScope gathers user-scoped data for the given home directory.
+// Every source is best-effort: a missing browser or empty crontab simply
+// yields an empty list.
+func CollectUserScope(username, home string) (UserScope, error) {
+	s := UserScope{Username: username, Home: home}
+	s.LaunchAgents = listUserLaunchAgents(home)
+	s.Crontab = readUserCrontab(username)
+	s.BrowserExtensions = listBrowserExtensions(home)
+	s.UserApps = listUserApps(home)
+	s.AuthorizedKeys = readAuthorizedKeys(home)
+	s.ScreenLock = readScreenLock(username, home)
+	return s, nil
+}
+
+func listUserLaunchAgents(home string) []string {
+	return listFiles(userLaunchAgentDirs(home), func(name string) bool {
+		return strings.HasSuffix(name, ".plist") || strings.HasSuffix(name, ".desktop") ||
+			strings.HasSuffix(name, ".service") || strings.HasSuffix(name, ".timer")
+	})
+}
+
+// readUserCrontab returns the non-comment lines of a user's crontab.
+// Reading another account's crontab needs root; `crontab -l` exits
+// non-zero when there is none.
+func readUserCrontab(username string) []string {
+	cmd := exec.Command("crontab", "-l")
+	if cur, err := user.Current(); err == nil && cur.Username != username {
+		cmd = exec.Command("crontab", "-l", "-u", username)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+	var lines []string
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func listBrowserExtensions(home string) []BrowserExtension {
+	var exts []BrowserExtension
+	for browser, rel := range chromiumProfiles() {
+		root := filepath.Join(home, filepath.FromSlash(rel))
+		profiles, _ := filepath.Glob(filepath.Join(root, "*", "Extensions"))
+		for _, extDir := range profiles {
+			ids, err := os.ReadDir(extDir)
+			if err != nil {
+				continue
+			}
+			for _, id := range ids {
+				if !id.IsDir() {
+					continue
+				}
+				ext := BrowserExtension{Browser: browser, ID: id.Name()}
+				// Layout is <id>/<version>/manifest.json; take any version.
+				manifests, _ := filepath.Glob(filepath.Join(extDir, id.Name(), "*", "manifest.json"))
+				if len(manifests) > 0 {
+					ext.Version = filepath.Base(filepath.Dir(manifests[0]))
+					ext.Name = manifestName(manifests[0])
+				}
+				exts = append(exts, ext)
+			}
+		}
+	}
+	exts = append(exts, listFirefoxExtensions(home)...)
+	sort.Slice(exts, func(i, j int) bool {
+		if exts[i].Browser != exts[j].Browser {
+			return exts[i].Browser < exts[j].Browser
+		}
+		return exts[i].ID < exts[j].ID
+	})
+	return exts
+}
+
+func manifestName(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var m struct {
+		Name string `json:"name"`
+	}
+	if json.Unmarshal(b, &m) != nil {
+		return ""
+	}
+	// Localised names ("__MSG_appName__") aren't worth resolving here.
+	if strings.HasPrefix(m.Name, "__MSG_") {
+		return ""
+	}
+	return m.Name
+}
+
+func listFirefoxExtensions(home string) []BrowserExtension {
+	files, _ := filepath.Glob(filepath.Join(firefoxProfilesDir(home), "*", "extensions.json"))
+	var exts []BrowserExtension
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		var doc struct {
+			Addons []struct {
+				ID            string `json:"id"`
+				Version       string `json:"version"`
+				Location      string `json:"location"`
+				DefaultLocale struct {
+					Name string `json:"name"`
+				} `json:"defaultLocale"`
+			} `json:"addons"`
+		}
+		if json.Unmarshal(b, &doc) != nil {
+			continue
+		}
+		for _, a := range doc.Addons {
+			// Built-in system add-ons aren't user choices.
+			if a.Location == "app-builtin" || a.Location == "app-system-defaults" {
+				continue
+			}
+			exts = append(exts, BrowserExtension{Browser: "firefox", ID: a.ID, Name: a.DefaultLocale.Name, Version: a.Version})
+		}
+	}
+	return exts
+}
+
+// listFiles returns the full paths of entries in dirs accepted by keep,
+// sorted. Missing directories are skipped.
+func listFiles(dirs []string, keep func(name string) bool) []string {
+	var out []string
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if keep(e.Name()) {
+				out = append(out, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
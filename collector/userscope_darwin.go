@@ -0,0 +1,34 @@
+package collector
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// userLaunchAgentDirs are where an account's launchd agents live.
+func userLaunchAgentDirs(home string) []string {
+	return []string{filepath.Join(home, "Library", "LaunchAgents")}
+}
+
+// chromiumProfiles maps browser names to their user data directories,
+// relative to home.
+func chromiumProfiles() map[string]string {
+	return map[string]string{
+		"chrome":   "Library/Application Support/Google/Chrome",
+		"chromium": "Library/Application Support/Chromium",
+		"edge":     "Library/Application Support/Microsoft Edge",
+		"brave":    "Library/Application Support/BraveSoftware/Brave-Browser",
+	}
+}
+
+// firefoxProfilesDir holds an account's Firefox profiles.
+func firefoxProfilesDir(home string) string {
+	return filepath.Join(home, "Library", "Application Support", "Firefox", "Profiles")
+}
+
+// listUserApps lists the apps in ~/Applications.
+func listUserApps(home string) []string {
+	return listFiles([]string{filepath.Join(home, "Applications")}, func(name string) bool {
+		return strings.HasSuffix(name, ".app")
+	})
+}
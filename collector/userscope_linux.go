@@ -0,0 +1,37 @@
+package collector
+
+import "path/filepath"
+
+// userLaunchAgentDirs are where an account's XDG autostart entries and
+// systemd user units live.
+func userLaunchAgentDirs(home string) []string {
+	return []string{
+		filepath.Join(home, ".config", "autostart"),
+		filepath.Join(home, ".config", "systemd", "user"),
+	}
+}
+
+// chromiumProfiles maps browser names to their user data directories,
+// relative to home.
+func chromiumProfiles() map[string]string {
+	return map[string]string{
+		"chrome":   ".config/google-chrome",
+		"chromium": ".config/chromium",
+		"edge":     ".config/microsoft-edge",
+		"brave":    ".config/BraveSoftware/Brave-Browser",
+	}
+}
+
+// firefoxProfilesDir holds an account's Firefox profiles.
+func firefoxProfilesDir(home string) string {
+	return filepath.Join(home, ".mozilla", "firefox")
+}
+
+// listUserApps lists the desktop entries and programs installed in the
+// account's ~/.local.
+func listUserApps(home string) []string {
+	return listFiles([]string{
+		filepath.Join(home, ".local", "share", "applications"),
+		filepath.Join(home, ".local", "bin"),
+	}, func(string) bool { return true })
+}
@@ -0,0 +1,19 @@
+//go:build !darwin && !linux
+
+package collector
+
+import "path/filepath"
+
+// userLaunchAgentDirs is empty on this platform.
+func userLaunchAgentDirs(string) []string { return nil }
+
+// chromiumProfiles is empty on this platform.
+func chromiumProfiles() map[string]string { return nil }
+
+// firefoxProfilesDir holds an account's Firefox profiles, as on Linux.
+func firefoxProfilesDir(home string) string {
+	return filepath.Join(home, ".mozilla", "firefox")
+}
+
+// listUserApps is not implemented on this platform.
+func listUserApps(string) []string { return nil }
@@ -0,0 +1,38 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListBrowserExtensions_Chromium(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("browser paths only defined for linux/darwin")
+	}
+	home := t.TempDir()
+	rel := chromiumProfiles()["chrome"]
+	ver := filepath.Join(home, filepath.FromSlash(rel), "Default", "Extensions", "abcdef", "1.2.3_0")
+	require.NoError(t, os.MkdirAll(ver, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(ver, "manifest.json"), []byte(`{"name":"Password Helper"}`), 0o644))
+
+	exts := listBrowserExtensions(home)
+	require.Len(t, exts, 1)
+	assert.Equal(t, BrowserExtension{Browser: "chrome", ID: "abcdef", Name: "Password Helper", Version: "1.2.3_0"}, exts[0])
+}
+
+func TestListFiles_SkipsMissingDirs(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.plist"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.plist"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o644))
+
+	got := listFiles([]string{dir, filepath.Join(dir, "missing")}, func(n string) bool {
+		return filepath.Ext(n) == ".plist"
+	})
+	assert.Equal(t, []string{filepath.Join(dir, "a.plist"), filepath.Join(dir, "b.plist")}, got)
+}
@@ -0,0 +1,131 @@
+package collector
+
+import (
+	"net"
+	"sort"
+	"strings"
+)
+
+// VPNSignature identifies a VPN client by the processes, packages and
+// services it installs. Matching is a case-insensitive substring match.
+type VPNSignature struct {
+	Name      string   `json:"name" yaml:"name"`
+	Processes []string `json:"processes,omitempty" yaml:"processes"`
+	Packages  []string `json:"packages,omitempty" yaml:"packages"`
+	Services  []string `json:"services,omitempty" yaml:"services"`
+}
+
+// DefaultVPNSignatures covers the common corporate VPN clients.
+var DefaultVPNSignatures = []VPNSignature{
+	{Name: "cisco-secure-client", Processes: []string{"vpnagentd", "vpnui", "cisco secure client"}, Packages: []string{"cisco-secure-client", "anyconnect"}, Services: []string{"vpnagent", "com.cisco.anyconnect", "csc_vpnagent"}},
+	{Name: "globalprotect", Processes: []string{"pangps", "pangpa", "globalprotect"}, Packages: []string{"globalprotect"}, Services: []string{"pangps", "com.paloaltonetworks.gp"}},
+	{Name: "forticlient", Processes: []string{"forticlient", "fortitray", "fortisslvpn"}, Packages: []string{"forticlient"}, Services: []string{"fortinet", "forticlient"}},
+	{Name: "zscaler", Processes: []string{"zsatunnel", "zscaler"}, Packages: []string{"zscaler"}, Services: []string{"zsatunnel", "com.zscaler"}},
+	{Name: "openvpn", Processes: []string{"openvpn"}, Packages: []string{"openvpn"}, Services: []string{"openvpn"}},
+	{Name: "wireguard", Processes: []string{"wireguard", "wg-quick"}, Packages: []string{"wireguard"}, Services: []string{"wg-quick", "wireguard"}},
+	{Name: "tailscale", Processes: []string{"tailscaled"}, Packages: []string{"tailscale"}, Services: []string{"tailscaled", "tailscale"}},
+}
+
+// VPNStatus is what the agent found of VPN clients and tunnels.
+type VPNStatus struct {
+	Clients []VPNClient `json:"clients,omitempty"`
+	// TunnelActive is true when a tunnel-type interface is up with an
+	// address. nil when interfaces couldn't be listed.
+	TunnelActive *bool    `json:"tunnel_active"`
+	Tunnels      []string `json:"tunnels,omitempty"`
+}
+
+// VPNClient is one detected client with what matched.
+type VPNClient struct {
+	Name     string   `json:"name"`
+	Evidence []string `json:"evidence"`
+	// Running is true when one of its processes is running.
+	Running bool `json:"running"`
+}
+
+// DetectVPN matches signatures against collected processes and packages
+// plus the platform's service list, and checks for active tunnels.
+func DetectVPN(procs []Process, pkgs []Package, signatures []VPNSignature) VPNStatus {
+	st := VPNStatus{Clients: matchVPN(procs, pkgs, listServices(), signatures)}
+	if ifaces, err := net.Interfaces(); err == nil {
+		st.Tunnels = activeTunnels(ifaces)
+		st.TunnelActive = boolPtr(len(st.Tunnels) > 0)
+	}
+	return st
+}
+
+func matchVPN(procs []Process, pkgs []Package, services []string, signatures []VPNSignature) []VPNClient {
+	var out []VPNClient
+	for _, sig := range signatures {
+		c := VPNClient{Name: sig.Name}
+		seen := map[string]bool{}
+		add := func(e string) {
+			if !seen[e] {
+				seen[e] = true
+				c.Evidence = append(c.Evidence, e)
+			}
+		}
+		for _, p := range procs {
+			if anyContains(p.Name, sig.Processes) || anyContains(p.Path, sig.Processes) {
+				c.Running = true
+				add("process " + p.Name)
+			}
+		}
+		for _, p := range pkgs {
+			if anyContains(p.Name, sig.Packages) {
+				add("package " + p.Name + " " + p.Version)
+			}
+		}
+		for _, s := range services {
+			if anyContains(s, sig.Services) {
+				add("service " + s)
+			}
+		}
+		if len(c.Evidence) > 0 {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func anyContains(s string, needles []string) bool {
+	s = strings.ToLower(s)
+	if s == "" {
+		return false
+	}
+	for _, n := range needles {
+		if n != "" && strings.Contains(s, strings.ToLower(n)) {
+			return true
+		}
+	}
+	return false
+}
+
+// tunnelPrefixes are interface names VPN clients create.
+var tunnelPrefixes = []string{"tun", "tap", "utun", "wg", "ppp", "ipsec", "gpd", "cscotun", "tailscale", "zt"}
+
+func activeTunnels(ifaces []net.Interface) []string {
+	var out []string
+	for _, ifc := range ifaces {
+		if ifc.Flags&net.FlagUp == 0 || !isTunnelName(ifc.Name) {
+			continue
+		}
+		if addrs, err := ifc.Addrs(); err != nil || len(addrs) == 0 {
+			continue
+		}
+		out = append(out, ifc.Name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func isTunnelName(name string) bool {
+	n := strings.ToLower(name)
+	for _, p := range tunnelPrefixes {
+		if strings.HasPrefix(n, p) {
+			return true
+		}
+	}
+	// Windows adapters are named after the driver.
+	return strings.Contains(n, "vpn") || strings.Contains(n, "wireguard") || strings.Contains(n, "anyconnect")
+}
@@ -0,0 +1,21 @@
+package collector
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// listServices returns the loaded launchd labels.
+func listServices() []string {
+	var out []string
+	b, err := exec.Command("launchctl", "list").Output()
+	if err != nil {
+		return nil
+	}
+	for _, line := range strings.Split(string(b), "\n")[1:] {
+		if f := strings.Fields(line); len(f) == 3 {
+			out = append(out, f[2])
+		}
+	}
+	return out
+}
@@ -0,0 +1,23 @@
+package collector
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// listServices returns the enabled rc.d scripts.
+func listServices() []string {
+	var out []string
+	// service -e prints the path of each enabled rc.d script.
+	b, err := exec.Command("service", "-e").Output()
+	if err != nil {
+		return nil
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, filepath.Base(line))
+		}
+	}
+	return out
+}
@@ -0,0 +1,21 @@
+package collector
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// listServices returns the installed systemd service units.
+func listServices() []string {
+	var out []string
+	b, err := exec.Command("systemctl", "list-unit-files", "--type=service", "--no-legend", "--no-pager").Output()
+	if err != nil {
+		return nil
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if f := strings.Fields(line); len(f) > 0 {
+			out = append(out, strings.TrimSuffix(f[0], ".service"))
+		}
+	}
+	return out
+}
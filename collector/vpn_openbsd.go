@@ -0,0 +1,15 @@
+package collector
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// listServices returns the enabled rc.d daemons.
+func listServices() []string {
+	b, err := exec.Command("rcctl", "ls", "on").Output()
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(b))
+}
@@ -0,0 +1,6 @@
+//go:build !linux && !darwin && !windows && !freebsd && !openbsd
+
+package collector
+
+// listServices is not implemented on this platform.
+func listServices() []string { return nil }
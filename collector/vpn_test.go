@@ -0,0 +1,28 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchVPN(t *testing.T) {
+	procs := []Process{{Name: "PanGPS"}, {Name: "sshd"}}
+	pkgs := []Package{{Name: "openvpn", Version: "2.6.3"}}
+	services := []string{"openvpn-client@", "sshd"}
+
+	got := matchVPN(procs, pkgs, services, DefaultVPNSignatures)
+	assert.Equal(t, []VPNClient{
+		{Name: "globalprotect", Evidence: []string{"process PanGPS"}, Running: true},
+		{Name: "openvpn", Evidence: []string{"package openvpn 2.6.3", "service openvpn-client@"}},
+	}, got)
+}
+
+func TestIsTunnelName(t *testing.T) {
+	for _, n := range []string{"utun3", "tun0", "wg0", "tailscale0", "Cisco AnyConnect Secure Mobility Client Connection"} {
+		assert.True(t, isTunnelName(n), n)
+	}
+	for _, n := range []string{"eth0", "en0", "lo", "Wi-Fi"} {
+		assert.False(t, isTunnelName(n), n)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package collector
+
+// listServices returns the installed service names.
+func listServices() []string {
+	var out []string
+	rows, err := runPowerShellJSON("Get-Service | Select-Object Name | ConvertTo-Json -Compress")
+	if err != nil {
+		return nil
+	}
+	for _, r := range rows {
+		out = append(out, r["Name"])
+	}
+	return out
+}
@@ -0,0 +1,162 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// WebEndpoint is what a local web console answered to one unauthenticated
+// GET.
+type WebEndpoint struct {
+	URL    string `json:"url"`
+	Status int    `json:"status,omitempty"`
+	// Location is the redirect target of a 3xx answer.
+	Location string `json:"location,omitempty"`
+	// Headers holds the security-relevant response headers that were set.
+	Headers map[string]string `json:"headers,omitempty"`
+	Title   string            `json:"title,omitempty"`
+	// DefaultCredentials names the default-login or unfinished-setup
+	// signatures the page matched.
+	DefaultCredentials []string `json:"default_credentials,omitempty"`
+	Error              string   `json:"error,omitempty"`
+}
+
+// webHeaders are the response headers worth recording.
+var webHeaders = []string{
+	"Strict-Transport-Security",
+	"Content-Security-Policy",
+	"X-Frame-Options",
+	"X-Content-Type-Options",
+	"Server",
+}
+
+// webBodyLimit caps how much of a page is read for signatures.
+const webBodyLimit = 256 << 10
+
+type webSignature struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// defaultCredentialSignatures match pages that advertise vendor default
+// logins or an installation nobody finished setting up.
+var defaultCredentialSignatures = []webSignature{
+	{"default password notice", regexp.MustCompile(`(?i)default (password|credentials|login)`)},
+	{"admin/admin hint", regexp.MustCompile(`(?i)\b(admin|root)\s*/\s*(admin|password|root|1234|12345)\b`)},
+	{"guest/guest hint", regexp.MustCompile(`(?i)\bguest\s*/\s*guest\b`)},
+	{"Jenkins unlock page", regexp.MustCompile(`(?i)unlock jenkins`)},
+	{"Tomcat default page", regexp.MustCompile(`(?i)you've successfully installed tomcat`)},
+	{"setup wizard", regexp.MustCompile(`(?i)(initial|first[- ]time) (setup|configuration) (wizard|required)`)},
+}
+
+var titleRE = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// CollectWebEndpoints fetches each URL once, without following redirects
+// or sending credentials, and records the status, security headers and
+// any default-credential signatures. Only loopback and this host's own
+// addresses are dialed; other hosts are refused. Certificates aren't
+// verified, since local consoles are usually self-signed and TLS setup is
+// checked separately. Failed fetches are recorded per endpoint and
+// returned together.
+func CollectWebEndpoints(ctx context.Context, urls []string, extraSignatures []string) ([]WebEndpoint, error) {
+	local, err := localAddrs()
+	if err != nil {
+		return nil, err
+	}
+	sigs := append([]webSignature(nil), defaultCredentialSignatures...)
+	for _, p := range extraSignatures {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("credential pattern %q: %w", p, err)
+		}
+		sigs = append(sigs, webSignature{p, re})
+	}
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:     localDialer(local),
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	defer client.CloseIdleConnections()
+
+	var out []WebEndpoint
+	var errs []error
+	for _, u := range urls {
+		ep := fetchWebEndpoint(ctx, client, u, sigs)
+		if ep.Error != "" {
+			errs = append(errs, errors.New(ep.Error))
+		}
+		out = append(out, ep)
+	}
+	return out, errors.Join(errs...)
+}
+
+func fetchWebEndpoint(ctx context.Context, client *http.Client, url string, sigs []webSignature) WebEndpoint {
+	ep := WebEndpoint{URL: url}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		ep.Error = err.Error()
+		return ep
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		ep.Error = err.Error()
+		return ep
+	}
+	defer resp.Body.Close()
+	ep.Status = resp.StatusCode
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		ep.Location = resp.Header.Get("Location")
+	}
+	for _, h := range webHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			if ep.Headers == nil {
+				ep.Headers = map[string]string{}
+			}
+			ep.Headers[h] = v
+		}
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webBodyLimit))
+	if m := titleRE.FindSubmatch(body); m != nil {
+		ep.Title = strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+	}
+	for _, s := range sigs {
+		if s.re.Match(body) {
+			ep.DefaultCredentials = append(ep.DefaultCredentials, s.name)
+		}
+	}
+	return ep
+}
+
+// localDialer resolves the host and dials the first address that belongs
+// to this machine, refusing the connection otherwise.
+func localDialer(local map[string]bool) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: 5 * time.Second}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			if ip.IP.IsLoopback() || local[ip.IP.String()] {
+				return d.DialContext(ctx, network, net.JoinHostPort(ip.IP.String(), port))
+			}
+		}
+		return nil, fmt.Errorf("%s is not an address of this host", host)
+	}
+}
@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectWebEndpoints(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://appliance.local/", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=63072000")
+		w.Header().Set("Server", "lighttpd")
+		_, _ = w.Write([]byte("<html><title> Router &amp; Admin </title><p>Log in with admin / admin. Acme Unit 7</p></html>"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	eps, err := CollectWebEndpoints(context.Background(), []string{srv.URL + "/", srv.URL + "/login"}, []string{`Acme Unit \d`})
+	require.NoError(t, err)
+	require.Len(t, eps, 2)
+	assert.Equal(t, http.StatusMovedPermanently, eps[0].Status)
+	assert.Equal(t, "https://appliance.local/", eps[0].Location)
+
+	assert.Equal(t, http.StatusOK, eps[1].Status)
+	assert.Equal(t, "Router & Admin", eps[1].Title)
+	assert.Equal(t, map[string]string{"Strict-Transport-Security": "max-age=63072000", "Server": "lighttpd"}, eps[1].Headers)
+	assert.Equal(t, []string{"admin/admin hint", `Acme Unit \d`}, eps[1].DefaultCredentials)
+}
+
+func TestCollectWebEndpoints_RefusesOtherHosts(t *testing.T) {
+	eps, err := CollectWebEndpoints(context.Background(), []string{"http://192.0.2.10/"}, nil)
+	require.Error(t, err)
+	require.Len(t, eps, 1)
+	assert.Contains(t, eps[0].Error, "not an address of this host")
+}
@@ -0,0 +1,437 @@
+// Package compare sets hosts' newest reports side by side, or one host
+// against the norm of its peers. It lists the rules that fail on some
+// hosts and pass on others, and the configuration and inventory facts
+// that differ, with the facts that could explain each failing rule
+// listed beside it.
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/report"
+)
+
+// NormID is the host ID of the peers' norm in a comparison from
+// AgainstNorm.
+const NormID = "norm"
+
+// Input is a host's newest report.
+type Input struct {
+	ID     string
+	Report report.ComplianceReport
+}
+
+// Comparison is what differs between the hosts.
+type Comparison struct {
+	Hosts []Host `json:"hosts"`
+	// Rules fail on some of the hosts and pass on the others, the most
+	// severe first.
+	Rules []Rule `json:"rules"`
+	// Facts differ between the hosts. Facts that could explain one of
+	// Rules come first.
+	Facts []Fact `json:"facts"`
+}
+
+// Host is one column of a comparison.
+type Host struct {
+	ID          string    `json:"id"`
+	Hostname    string    `json:"hostname"`
+	GeneratedAt time.Time `json:"generated_at,omitempty"`
+	Violations  int       `json:"violations"`
+	// Peers is how many hosts the norm was drawn from; zero for a host.
+	Peers int `json:"peers,omitempty"`
+}
+
+// Rule is a violation category that fails on some hosts only.
+type Rule struct {
+	Rule     string            `json:"rule"`
+	Severity analyzer.Severity `json:"severity"`
+	// Failing maps each failing host's ID to its violation messages.
+	// The norm fails a rule more than half its peers fail, and has no
+	// messages of its own.
+	Failing map[string][]string `json:"failing"`
+	Passing []string            `json:"passing"`
+	// PeerShare is the fraction of the norm's peers that fail the rule.
+	PeerShare float64 `json:"peer_share,omitempty"`
+	// Facts are the keys of differing facts that bear on the rule.
+	Facts []string `json:"facts,omitempty"`
+}
+
+// Fact is a configuration or inventory value that differs.
+type Fact struct {
+	// Key is "section/name", or the section alone for a host-wide value,
+	// e.g. "sshd/permitrootlogin", "package/openssl" or "os_version".
+	Key string `json:"key"`
+	// Values maps host ID to value. A host without the fact (a package
+	// that isn't installed, a user that doesn't exist) is left out.
+	Values map[string]string `json:"values"`
+	// Share is the fraction of the norm's peers that have its value.
+	Share float64 `json:"share,omitempty"`
+	// Explains lists the rules in Rules the fact bears on.
+	Explains []string `json:"explains,omitempty"`
+}
+
+// explains maps a fact section to the violation categories it bears on.
+// A category matches when it equals one of them or starts with it and an
+// underscore, so "ssh" covers "ssh_root_login". A section missing here
+// bears on the category of the same name only.
+var explains = map[string][]string{
+	"user":            {"user", "authorized_keys"},
+	"port":            {"port", "listeners", "root_listener", "sharing", "telnetd"},
+	"package":         {"package", "vulnerability", "telnetd"},
+	"os_version":      {"os_version", "vulnerability"},
+	"kernel_version":  {"kernel_version", "vulnerability"},
+	"sshd":            {"ssh"},
+	"disk_encryption": {"disk_encryption", "hibernation_encryption"},
+	"power":           {"sleep_on_lid_close", "password_after_sleep", "hibernation_encryption", "screen_lock"},
+	"agent":           {"component", "agent"},
+}
+
+// bearsOn reports whether facts in section can explain category.
+func bearsOn(section, category string) bool {
+	cats, ok := explains[section]
+	if !ok {
+		cats = []string{section}
+	}
+	for _, c := range cats {
+		if category == c || strings.HasPrefix(category, c+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+func section(key string) string {
+	s, _, _ := strings.Cut(key, "/")
+	return s
+}
+
+// Facts flattens the configuration and inventory in a report into
+// comparable key/value pairs.
+func Facts(rep report.ComplianceReport) map[string]string {
+	f := map[string]string{}
+	set := func(key, value string) {
+		if value != "" {
+			f[key] = value
+		}
+	}
+	setBool := func(key string, b *bool) {
+		if b != nil {
+			set(key, strconv.FormatBool(*b))
+		}
+	}
+
+	if rep.Agent != nil {
+		set("agent_version", rep.Agent.Version)
+	}
+	if ver := rep.OSVersion; ver != nil {
+		v := strings.TrimSpace(ver.Name + " " + ver.Version)
+		if ver.Build != "" {
+			v += " build " + ver.Build
+		}
+		set("os_version", v)
+		set("kernel_version", ver.Kernel)
+	}
+	for _, u := range rep.Users {
+		if u.Username != "" {
+			set("user/"+u.Username, fmt.Sprintf("uid %d, shell %s", u.UID, u.Shell))
+		}
+	}
+	ports := map[int][]string{}
+	for _, p := range rep.OpenPorts {
+		ports[p] = nil
+	}
+	for _, b := range rep.PortBindings {
+		// The address is left out: it differs between hosts by design.
+		ports[b.Port] = append(ports[b.Port], strings.TrimSpace(b.Protocol+" "+b.Process))
+	}
+	for p, bound := range ports {
+		set("port/"+strconv.Itoa(p), joinUnique(bound, "open"))
+	}
+	pkgs := map[string][]string{}
+	for _, p := range rep.Packages {
+		pkgs[p.Name] = append(pkgs[p.Name], p.Version)
+	}
+	for name, versions := range pkgs {
+		set("package/"+name, joinUnique(versions, "installed"))
+	}
+	if fw := rep.Firewall; fw != nil {
+		setBool("firewall", fw.Enabled)
+		for _, b := range fw.Backends {
+			set("firewall/"+b.Name, strconv.FormatBool(b.Enabled))
+		}
+	}
+	if s := rep.SSHD; s != nil {
+		for k, v := range s.Settings {
+			set("sshd/"+strings.ToLower(k), v)
+		}
+	}
+	for _, d := range rep.DiskEncryption {
+		name := d.Mount
+		if name == "" {
+			name = d.Name
+		}
+		set("disk_encryption/"+name, strconv.FormatBool(d.Encrypted))
+	}
+	for _, a := range rep.RequiredAgents {
+		switch {
+		case a.Running == nil:
+			set("agent/"+a.Name, strings.TrimSpace("installed "+a.Version))
+		case *a.Running:
+			set("agent/"+a.Name, strings.TrimSpace("running "+a.Version))
+		default:
+			set("agent/"+a.Name, strings.TrimSpace("not running "+a.Version))
+		}
+	}
+	if p := rep.Power; p != nil {
+		setBool("power/sleep_on_lid_close", p.SleepOnLidClose)
+		setBool("power/password_after_sleep", p.PasswordAfterSleep)
+		setBool("power/hibernation_encrypted", p.HibernationEncrypted)
+	}
+	if b := rep.Bluetooth; b != nil {
+		setBool("bluetooth/powered", b.Powered)
+		setBool("bluetooth/discoverable", b.Discoverable)
+	}
+	if v := rep.VPN; v != nil {
+		setBool("vpn/tunnel_active", v.TunnelActive)
+		for _, c := range v.Clients {
+			state := "installed"
+			if c.Running {
+				state = "running"
+			}
+			set("vpn/"+c.Name, state)
+		}
+	}
+	for _, s := range rep.Sharing {
+		set("sharing/"+s.Service, "on")
+	}
+	if p := rep.Proxy; p != nil {
+		switch {
+		case p.Server != "":
+			set("proxy", p.Server)
+		case p.PACURL != "":
+			set("proxy", "pac "+p.PACURL)
+		case p.Enabled != nil && !*p.Enabled:
+			set("proxy", "off")
+		}
+	}
+	for _, r := range rep.Benchmark {
+		switch {
+		case r.NotApplicable:
+		case r.Found:
+			set("cis/"+r.ID, r.Value)
+		default:
+			set("cis/"+r.ID, "not set")
+		}
+	}
+	return f
+}
+
+// joinUnique sorts and joins the distinct non-empty values, or is def
+// when there are none.
+func joinUnique(values []string, def string) string {
+	seen := map[string]bool{}
+	var out []string
+	for _, v := range values {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	if len(out) == 0 {
+		return def
+	}
+	sort.Strings(out)
+	return strings.Join(out, ", ")
+}
+
+// column is one host's facts and failing rules.
+type column struct {
+	host    Host
+	facts   map[string]string
+	failing map[string][]string
+	worst   map[string]analyzer.Severity
+}
+
+func columnOf(in Input) column {
+	c := column{
+		host: Host{
+			ID:          in.ID,
+			Hostname:    in.Report.Hostname,
+			GeneratedAt: in.Report.GeneratedAt,
+			Violations:  len(in.Report.Violations),
+		},
+		facts:   Facts(in.Report),
+		failing: map[string][]string{},
+		worst:   map[string]analyzer.Severity{},
+	}
+	for _, v := range in.Report.Violations {
+		c.failing[v.Category] = append(c.failing[v.Category], v.Message)
+		if v.Severity.Rank() > c.worst[v.Category].Rank() || c.worst[v.Category] == "" {
+			c.worst[v.Category] = v.Severity
+		}
+	}
+	return c
+}
+
+// Compare sets two or more hosts side by side.
+func Compare(hosts []Input) (Comparison, error) {
+	if len(hosts) < 2 {
+		return Comparison{}, errors.New("compare needs at least two hosts")
+	}
+	cols := make([]column, len(hosts))
+	for i, h := range hosts {
+		cols[i] = columnOf(h)
+	}
+	return build(cols, nil, nil), nil
+}
+
+// AgainstNorm compares a host with the norm of its peers: for each fact
+// the value most of them have, and the rules more than half of them
+// fail.
+func AgainstNorm(target Input, peers []Input) (Comparison, error) {
+	if len(peers) == 0 {
+		return Comparison{}, errors.New("compare: the host has no peers")
+	}
+	norm := column{
+		host:    Host{ID: NormID, Hostname: "peers' norm", Peers: len(peers)},
+		facts:   map[string]string{},
+		failing: map[string][]string{},
+		worst:   map[string]analyzer.Severity{},
+	}
+	counts := map[string]map[string]int{}
+	fails := map[string]int{}
+	violations := 0
+	for _, p := range peers {
+		c := columnOf(p)
+		violations += c.host.Violations
+		for k, v := range c.facts {
+			if counts[k] == nil {
+				counts[k] = map[string]int{}
+			}
+			counts[k][v]++
+		}
+		for cat := range c.failing {
+			fails[cat]++
+			if sev := c.worst[cat]; sev.Rank() > norm.worst[cat].Rank() || norm.worst[cat] == "" {
+				norm.worst[cat] = sev
+			}
+		}
+	}
+	norm.host.Violations = (violations + len(peers)/2) / len(peers)
+
+	shares := map[string]float64{}
+	for k, byValue := range counts {
+		// A peer without the fact counts toward "absent".
+		best, bestN, have := "", len(peers), 0
+		for _, n := range byValue {
+			have += n
+		}
+		bestN -= have
+		for v, n := range byValue {
+			if n > bestN || (n == bestN && best != "" && v < best) {
+				best, bestN = v, n
+			}
+		}
+		if best != "" {
+			norm.facts[k] = best
+		}
+		shares[k] = float64(bestN) / float64(len(peers))
+	}
+	peerShare := map[string]float64{}
+	for cat, n := range fails {
+		peerShare[cat] = float64(n) / float64(len(peers))
+		if 2*n > len(peers) {
+			norm.failing[cat] = []string{}
+		}
+	}
+	return build([]column{columnOf(target), norm}, shares, peerShare), nil
+}
+
+// build diffs the columns. shares and peerShare are set for a norm.
+func build(cols []column, shares, peerShare map[string]float64) Comparison {
+	cmp := Comparison{Rules: []Rule{}, Facts: []Fact{}}
+	for _, c := range cols {
+		cmp.Hosts = append(cmp.Hosts, c.host)
+	}
+
+	cats := map[string]bool{}
+	for _, c := range cols {
+		for cat := range c.failing {
+			cats[cat] = true
+		}
+	}
+	for cat := range cats {
+		r := Rule{Rule: cat, Failing: map[string][]string{}, Passing: []string{}}
+		for _, c := range cols {
+			msgs, ok := c.failing[cat]
+			if !ok {
+				r.Passing = append(r.Passing, c.host.ID)
+				continue
+			}
+			r.Failing[c.host.ID] = msgs
+			if sev := c.worst[cat]; sev.Rank() > r.Severity.Rank() || r.Severity == "" {
+				r.Severity = sev
+			}
+		}
+		if len(r.Passing) == 0 {
+			continue // fails everywhere: nothing to explain
+		}
+		r.PeerShare = peerShare[cat]
+		cmp.Rules = append(cmp.Rules, r)
+	}
+	sort.Slice(cmp.Rules, func(i, j int) bool {
+		a, b := cmp.Rules[i], cmp.Rules[j]
+		if a.Severity.Rank() != b.Severity.Rank() {
+			return a.Severity.Rank() > b.Severity.Rank()
+		}
+		return a.Rule < b.Rule
+	})
+
+	keys := map[string]bool{}
+	for _, c := range cols {
+		for k := range c.facts {
+			keys[k] = true
+		}
+	}
+	for k := range keys {
+		f := Fact{Key: k, Values: map[string]string{}, Share: shares[k]}
+		same := true
+		for i, c := range cols {
+			v, ok := c.facts[k]
+			if ok {
+				f.Values[c.host.ID] = v
+			}
+			if i > 0 && v != cols[0].facts[k] {
+				same = false
+			}
+		}
+		if same {
+			continue
+		}
+		for i := range cmp.Rules {
+			if bearsOn(section(k), cmp.Rules[i].Rule) {
+				f.Explains = append(f.Explains, cmp.Rules[i].Rule)
+				cmp.Rules[i].Facts = append(cmp.Rules[i].Facts, k)
+			}
+		}
+		cmp.Facts = append(cmp.Facts, f)
+	}
+	sort.Slice(cmp.Facts, func(i, j int) bool {
+		a, b := cmp.Facts[i], cmp.Facts[j]
+		if (len(a.Explains) > 0) != (len(b.Explains) > 0) {
+			return len(a.Explains) > 0
+		}
+		return a.Key < b.Key
+	})
+	for i := range cmp.Rules {
+		sort.Strings(cmp.Rules[i].Facts)
+	}
+	return cmp
+}
@@ -0,0 +1,119 @@
+package compare
+
+import (
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/collector"
+	"compliance-agent/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func host(name, rootLogin string, violations ...analyzer.Violation) report.ComplianceReport {
+	return report.ComplianceReport{
+		GeneratedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Hostname:    name,
+		OSVersion:   &collector.OSVersion{Name: "Ubuntu", Version: "22.04", Kernel: "5.15.0-91"},
+		Users:       []collector.User{{Username: "root", UID: 0, Shell: "/bin/bash"}},
+		OpenPorts:   []int{22},
+		PortBindings: []collector.PortBinding{
+			{Port: 22, Protocol: "tcp", Address: "10.0.0.1", Process: "sshd"},
+		},
+		Packages:   []collector.Package{{Name: "openssl", Version: "3.0.2"}},
+		SSHD:       &collector.SSHDConfig{Settings: map[string]string{"PermitRootLogin": rootLogin}},
+		Violations: violations,
+	}
+}
+
+var rootLogin = analyzer.Violation{Category: "ssh_root_login", Severity: analyzer.SeverityHigh, Message: "PermitRootLogin is yes"}
+
+func TestFacts(t *testing.T) {
+	rep := host("web-1", "no")
+	rep.Packages = append(rep.Packages, collector.Package{Name: "openssl", Version: "1.1.1", Arch: "i386"})
+	rep.OpenPorts = append(rep.OpenPorts, 8080)
+	f := Facts(rep)
+	assert.Equal(t, "Ubuntu 22.04", f["os_version"])
+	assert.Equal(t, "5.15.0-91", f["kernel_version"])
+	assert.Equal(t, "uid 0, shell /bin/bash", f["user/root"])
+	assert.Equal(t, "tcp sshd", f["port/22"], "the address differs between hosts by design")
+	assert.Equal(t, "open", f["port/8080"])
+	assert.Equal(t, "1.1.1, 3.0.2", f["package/openssl"])
+	assert.Equal(t, "no", f["sshd/permitrootlogin"])
+}
+
+func TestCompare(t *testing.T) {
+	bad := host("web-2", "yes", rootLogin)
+	bad.Packages = append(bad.Packages, collector.Package{Name: "telnet", Version: "0.17"})
+	cmp, err := Compare([]Input{{ID: "a", Report: host("web-1", "no")}, {ID: "b", Report: bad}})
+	require.NoError(t, err)
+
+	require.Len(t, cmp.Hosts, 2)
+	require.Len(t, cmp.Rules, 1)
+	r := cmp.Rules[0]
+	assert.Equal(t, "ssh_root_login", r.Rule)
+	assert.Equal(t, analyzer.SeverityHigh, r.Severity)
+	assert.Equal(t, map[string][]string{"b": {"PermitRootLogin is yes"}}, r.Failing)
+	assert.Equal(t, []string{"a"}, r.Passing)
+	assert.Equal(t, []string{"sshd/permitrootlogin"}, r.Facts)
+
+	require.Len(t, cmp.Facts, 2)
+	assert.Equal(t, Fact{Key: "sshd/permitrootlogin", Values: map[string]string{"a": "no", "b": "yes"},
+		Explains: []string{"ssh_root_login"}}, cmp.Facts[0], "facts that explain a rule come first")
+	assert.Equal(t, Fact{Key: "package/telnet", Values: map[string]string{"b": "0.17"}}, cmp.Facts[1])
+
+	md, err := cmp.Markdown()
+	require.NoError(t, err)
+	assert.Contains(t, string(md), "# web-1 vs web-2")
+	assert.Contains(t, string(md), "- **web-2** fails: PermitRootLogin is yes")
+	assert.Contains(t, string(md), "| sshd/permitrootlogin | no | yes |")
+	assert.Contains(t, string(md), "| package/telnet | — | 0.17 |")
+
+	_, err = Compare([]Input{{ID: "a", Report: bad}})
+	assert.Error(t, err)
+}
+
+func TestAgainstNorm(t *testing.T) {
+	peers := []Input{
+		{ID: "p1", Report: host("web-1", "no")},
+		{ID: "p2", Report: host("web-2", "no")},
+		{ID: "p3", Report: host("web-3", "yes", rootLogin)},
+	}
+	peers[2].Report.Packages = nil
+	target := host("web-9", "yes", rootLogin)
+	target.Packages = nil
+
+	cmp, err := AgainstNorm(Input{ID: "t", Report: target}, peers)
+	require.NoError(t, err)
+	require.Len(t, cmp.Hosts, 2)
+	assert.Equal(t, Host{ID: NormID, Hostname: "peers' norm", Peers: 3}, cmp.Hosts[1])
+
+	require.Len(t, cmp.Rules, 1)
+	assert.Equal(t, []string{NormID}, cmp.Rules[0].Passing, "only one peer in three fails it")
+	assert.InDelta(t, 1.0/3, cmp.Rules[0].PeerShare, 1e-9)
+
+	byKey := map[string]Fact{}
+	for _, f := range cmp.Facts {
+		byKey[f.Key] = f
+	}
+	assert.Len(t, byKey, 2)
+	assert.Equal(t, map[string]string{"t": "yes", NormID: "no"}, byKey["sshd/permitrootlogin"].Values)
+	assert.InDelta(t, 2.0/3, byKey["sshd/permitrootlogin"].Share, 1e-9)
+	assert.Equal(t, map[string]string{NormID: "3.0.2"}, byKey["package/openssl"].Values)
+
+	md, err := cmp.Markdown()
+	require.NoError(t, err)
+	assert.Contains(t, string(md), "# web-9 against its 3 peers")
+	assert.Contains(t, string(md), "- **peers' norm** passes")
+	assert.Contains(t, string(md), "| package/openssl | — | 3.0.2 | 67% |")
+
+	// A rule most peers fail is the norm.
+	peers[0].Report.Violations = []analyzer.Violation{rootLogin}
+	cmp, err = AgainstNorm(Input{ID: "t", Report: target}, peers)
+	require.NoError(t, err)
+	assert.Empty(t, cmp.Rules)
+
+	_, err = AgainstNorm(Input{ID: "t", Report: target}, nil)
+	assert.Error(t, err)
+}
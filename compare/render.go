@@ -0,0 +1,123 @@
+package compare
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// maxOtherFacts caps the differences that explain no rule in Markdown;
+// two hosts' package lists alone can differ by hundreds.
+const maxOtherFacts = 50
+
+// Title names the hosts compared.
+func (c Comparison) Title() string {
+	if len(c.Hosts) == 2 && c.Hosts[1].ID == NormID {
+		if c.Hosts[1].Peers == 1 {
+			return c.Hosts[0].Hostname + " against its peer"
+		}
+		return fmt.Sprintf("%s against its %d peers", c.Hosts[0].Hostname, c.Hosts[1].Peers)
+	}
+	names := make([]string, len(c.Hosts))
+	for i, h := range c.Hosts {
+		names[i] = h.Hostname
+	}
+	return strings.Join(names, " vs ")
+}
+
+// Explaining is the differing facts that bear on a rule.
+func (c Comparison) Explaining(rule string) []Fact {
+	var out []Fact
+	for _, f := range c.Facts {
+		for _, r := range f.Explains {
+			if r == rule {
+				out = append(out, f)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Others is the differing facts that bear on none of the rules.
+func (c Comparison) Others() []Fact {
+	var out []Fact
+	for _, f := range c.Facts {
+		if len(f.Explains) == 0 {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+var funcs = map[string]any{
+	"cell": func(v string) string {
+		if v == "" {
+			return "—"
+		}
+		return strings.ReplaceAll(v, "|", `\|`)
+	},
+	"fails": func(r Rule, id string) string {
+		msgs, ok := r.Failing[id]
+		switch {
+		case !ok:
+			return "passes"
+		case id == NormID:
+			return fmt.Sprintf("fails (%.0f%% of peers)", 100*r.PeerShare)
+		case len(msgs) == 1:
+			return "fails: " + strings.ReplaceAll(msgs[0], "|", `\|`)
+		default:
+			return fmt.Sprintf("fails (%d violations)", len(msgs))
+		}
+	},
+	"percent": func(f float64) string { return fmt.Sprintf("%.0f%%", 100*f) },
+	"head": func(fs []Fact) []Fact {
+		if len(fs) > maxOtherFacts {
+			return fs[:maxOtherFacts]
+		}
+		return fs
+	},
+	"more":   func(fs []Fact) int { return max(0, len(fs)-maxOtherFacts) },
+	"isNorm": func(h Host) bool { return h.ID == NormID },
+}
+
+var markdownTemplate = template.Must(template.New("compare").Funcs(funcs).Parse(`{{$c := .}}# {{.Title}}
+
+| Host | Report | Violations |
+|---|---|---|
+{{range .Hosts}}| {{.Hostname}} | {{if isNorm .}}{{.Peers}} hosts{{else}}{{.GeneratedAt.UTC.Format "2006-01-02 15:04"}}{{end}} | {{.Violations}} |
+{{end}}
+## Rules that differ
+{{if .Rules}}{{range .Rules}}{{$r := .}}
+### {{.Rule}} ({{.Severity}})
+
+{{range $c.Hosts}}- **{{.Hostname}}** {{fails $r .ID}}
+{{end}}{{with $c.Explaining .Rule}}
+| Fact |{{range $c.Hosts}} {{.Hostname}} |{{end}}
+|---|{{range $c.Hosts}}---|{{end}}
+{{range .}}{{$f := .}}| {{.Key}} |{{range $c.Hosts}} {{cell (index $f.Values .ID)}} |{{end}}
+{{end}}{{else}}
+No differing fact bears on this rule.
+{{end}}{{end}}{{else}}
+Every rule passes or fails on all of them alike.
+{{end}}
+## Other differences
+{{with .Others}}
+| Fact |{{range $c.Hosts}} {{.Hostname}} |{{end}}{{if (index $c.Hosts 1).Peers}} Peers with the norm |{{end}}
+|---|{{range $c.Hosts}}---|{{end}}{{if (index $c.Hosts 1).Peers}}---|{{end}}
+{{range head .}}{{$f := .}}| {{.Key}} |{{range $c.Hosts}} {{cell (index $f.Values .ID)}} |{{end}}{{if (index $c.Hosts 1).Peers}} {{percent .Share}} |{{end}}
+{{end}}{{with more .}}
+…and {{.}} more.
+{{end}}{{else}}
+None.
+{{end}}`))
+
+// Markdown renders the comparison as Markdown.
+func (c Comparison) Markdown() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := markdownTemplate.Execute(&buf, c); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -11,17 +11,49 @@ import (
 	"strings"
 	"time"
 
+	"compliance-agent/errcode"
+
 	"gopkg.in/yaml.v3"
 )
 
 // Config groups everything the agent needs at runtime.
 type Config struct {
-	Mode      string         `yaml:"mode"` // "oneshot" | "streaming"
-	Interval  time.Duration  `yaml:"interval"`
-	Baseline  BaselineConfig `yaml:"baseline"`
-	ML        MLConfig       `yaml:"ml"`
-	Alerting  AlertConfig    `yaml:"alerting"`
-	Exporter  ExporterConfig `yaml:"exporter"`
+	Mode     string        `yaml:"mode"` // "oneshot" | "daemon" | "streaming"
+	Interval time.Duration `yaml:"interval"`
+	// CollectTimeout bounds each collector in a scan; one that overruns is
+	// recorded as an error and the scan goes on without it.
+	CollectTimeout time.Duration `yaml:"collect_timeout"`
+	// Scope is "system" (default, expects root) or "user" for unprivileged
+	// workstation scans of the invoking account only.
+	Scope string `yaml:"scope"`
+	// Root, when set, scans the filesystem mounted there (a forensic
+	// image, a rescue mount, an image build's chroot) instead of the
+	// running system.
+	Root     string         `yaml:"root"`
+	Baseline BaselineConfig `yaml:"baseline"`
+	ML       MLConfig       `yaml:"ml"`
+	Alerting AlertConfig    `yaml:"alerting"`
+	Sinks    SinkConfig     `yaml:"sinks"`
+	Exporter ExporterConfig `yaml:"exporter"`
+	OSQuery  OSQueryConfig  `yaml:"osquery"`
+	Fleet    FleetConfig    `yaml:"fleet"`
+	History  HistoryConfig  `yaml:"history"`
+	Identity IdentityConfig `yaml:"identity"`
+	Evidence EvidenceConfig `yaml:"evidence"`
+	GeoIP    GeoIPConfig    `yaml:"geoip"`
+	DNS      DNSConfig      `yaml:"dns"`
+	OSV      OSVConfig      `yaml:"osv"`
+	Risk     RiskConfig     `yaml:"risk"`
+	Summary  SummaryConfig  `yaml:"summary"`
+	Server   ServerConfig   `yaml:"server"`
+	Central  CentralConfig  `yaml:"central"`
+	// PolicySource is where the agent fetches its policy each scan.
+	PolicySource PolicySourceConfig `yaml:"policy_source"`
+	SBOM         SBOMConfig         `yaml:"sbom"`
+	// Encryption encrypts the reports the agent saves.
+	Encryption EncryptionConfig `yaml:"encryption"`
+	Release    ReleaseConfig    `yaml:"release"`
+	Log        LogConfig        `yaml:"log"`
 }
 
 type BaselineConfig struct {
@@ -29,13 +61,292 @@ type BaselineConfig struct {
 }
 
 type MLConfig struct {
-	URL        string        `yaml:"url"`
-	Timeout    time.Duration `yaml:"timeout"`
-	Threshold  float64       `yaml:"threshold"`
+	URL       string        `yaml:"url"`
+	Timeout   time.Duration `yaml:"timeout"`
+	Threshold float64       `yaml:"threshold"`
 }
 
 type AlertConfig struct {
 	OnAnomaly bool `yaml:"on_anomaly"`
+	// Enabled lists the alerter backends to build, by registered name.
+	Enabled   []string             `yaml:"enabled"`
+	Slack     SlackAlertConfig     `yaml:"slack"`
+	PagerDuty PagerDutyAlertConfig `yaml:"pagerduty"`
+	Webhook   WebhookAlertConfig   `yaml:"webhook"`
+	Syslog    SyslogAlertConfig    `yaml:"syslog"`
+	// Destinations are extra, named instances of a backend with their
+	// own settings, so one scan can notify two Slack channels. List a
+	// destination's name in Enabled to use it.
+	Destinations []DestinationConfig `yaml:"destinations"`
+	// Detail sets how much each destination, by backend or destination
+	// name, is told. Destinations not listed get the full detail.
+	Detail map[string]DetailConfig `yaml:"detail"`
+	Dedup  DedupConfig             `yaml:"dedup"`
+	// Mode is "all" (the default) to send every violation each run, or
+	// "delta" to send only those new since the previous run plus a
+	// resolved notice for those that disappeared.
+	Mode string `yaml:"mode"`
+	// DeltaState is where delta mode keeps what each alerter has been
+	// told across restarts. Empty keeps it in memory.
+	DeltaState string `yaml:"delta_state"`
+	// Correlation groups related violations from one scan into incidents.
+	Correlation CorrelationConfig `yaml:"correlation"`
+	// ChatLimit caps how many violations, riskiest first, a chat message
+	// (Slack) lists; the rest are counted. Zero lists them all.
+	ChatLimit int `yaml:"chat_limit"`
+	// Links are the links back to the fleet server that alerts carry.
+	Links LinksConfig `yaml:"links"`
+}
+
+// LinksConfig templates the links alerts carry, so a notification leads
+// to the host and finding it is about. BaseURL defaults to central.url;
+// without either, alerts carry no links. Host, Finding and Ack are Go
+// text/templates over .Server (BaseURL), .AgentID (the agent's ID on the
+// fleet server), .Hostname, and for Finding and Ack .Fingerprint,
+// .Category, .Control and .Severity. Host and Finding default to the
+// fleet server's host and finding endpoints; Ack has no default. A link
+// using a value that is empty, such as .AgentID before the agent has
+// enrolled, is left out.
+type LinksConfig struct {
+	BaseURL string `yaml:"base_url"`
+	Host    string `yaml:"host"`
+	Finding string `yaml:"finding"`
+	Ack     string `yaml:"ack"`
+	// CredentialsPath is central.credentials_path, which the agent ID is
+	// read from once the agent has enrolled. The agent sets it.
+	CredentialsPath string `yaml:"-"`
+}
+
+// CorrelationConfig turns violation correlation on (the default) and
+// optionally replaces the built-in rules.
+type CorrelationConfig struct {
+	Enabled bool              `yaml:"enabled"`
+	Rules   []CorrelationRule `yaml:"rules"`
+}
+
+// CorrelationRule raises an incident when violations from at least
+// MinSignals of its Signals (default all) appear in one scan. Each signal
+// names the violation categories that count as it. Severity defaults to
+// critical.
+type CorrelationRule struct {
+	Name       string              `yaml:"name"`
+	Severity   string              `yaml:"severity"`
+	Signals    map[string][]string `yaml:"signals"`
+	MinSignals int                 `yaml:"min_signals"`
+}
+
+// DestinationConfig is a named alert destination: Type is the backend
+// (slack, pagerduty, webhook or syslog) and only its section is read.
+type DestinationConfig struct {
+	Name      string               `yaml:"name"`
+	Type      string               `yaml:"type"`
+	Slack     SlackAlertConfig     `yaml:"slack"`
+	PagerDuty PagerDutyAlertConfig `yaml:"pagerduty"`
+	Webhook   WebhookAlertConfig   `yaml:"webhook"`
+	Syslog    SyslogAlertConfig    `yaml:"syslog"`
+}
+
+// DetailConfig is how much of a scan a destination is told. Level is
+// "full" (the default: reports and every violation as found),
+// "violations" (the same, but only violations at or above MinSeverity)
+// or "summary" (report counts by category and severity, without
+// violation messages, users or metadata; no per-violation alerts).
+// MinSeverity also limits what a summary counts.
+type DetailConfig struct {
+	Level       string `yaml:"level"`
+	MinSeverity string `yaml:"min_severity"`
+}
+
+// DedupConfig suppresses repeat alerts for unchanged findings. A
+// violation (hostname, category and message) sent to an alerter within
+// Window isn't sent to it again, nor is a report whose violations are
+// all unchanged. Zero Window disables dedup. StatePath keeps what was
+// sent across runs; without it a daemon remembers in memory.
+type DedupConfig struct {
+	Window    time.Duration `yaml:"window"`
+	StatePath string        `yaml:"state_path"`
+}
+
+// SlackAlertConfig overrides the SLACK_* environment variables. Setting
+// BotToken (or SLACK_BOT_TOKEN) posts through the Web API instead of the
+// webhook, so violation details and resolutions thread under each report.
+type SlackAlertConfig struct {
+	WebhookURL string `yaml:"webhook_url"`
+	Channel    string `yaml:"channel"`
+	BotToken   string `yaml:"bot_token"`
+	// ThreadState keeps each host's last report thread across runs, so a
+	// one-shot agent can post resolutions under the previous report.
+	// Daemons remember it in memory without one.
+	ThreadState string `yaml:"thread_state"`
+	// APIURL overrides the Web API base URL (proxies, tests).
+	APIURL string `yaml:"api_url"`
+}
+
+// PagerDutyAlertConfig configures the PagerDuty Events API v2 alerter.
+// RoutingKey overrides PAGERDUTY_ROUTING_KEY; MinSeverity (default
+// critical) is the least severe violation that pages.
+type PagerDutyAlertConfig struct {
+	RoutingKey  string `yaml:"routing_key"`
+	MinSeverity string `yaml:"min_severity"`
+	// EventsURL overrides the Events API endpoint (EU service region,
+	// proxies, tests).
+	EventsURL string `yaml:"events_url"`
+}
+
+// WebhookAlertConfig configures the generic outbound webhook alerter. URL
+// overrides WEBHOOK_URL and Secret WEBHOOK_SECRET. Template (or
+// TemplateFile) is a Go text/template that renders the JSON body; empty
+// posts the report or violations as plain JSON. Events picks "report",
+// "violations" or both (the default).
+type WebhookAlertConfig struct {
+	URL          string            `yaml:"url"`
+	Events       []string          `yaml:"events"`
+	Headers      map[string]string `yaml:"headers"`
+	Template     string            `yaml:"template"`
+	TemplateFile string            `yaml:"template_file"`
+	// Secret, when set, signs each body with HMAC-SHA256 in
+	// SignatureHeader (default X-Compliance-Signature).
+	Secret          string `yaml:"secret"`
+	SignatureHeader string `yaml:"signature_header"`
+	// MinSeverity drops violations below it from violation events.
+	MinSeverity string        `yaml:"min_severity"`
+	Timeout     time.Duration `yaml:"timeout"`
+	// AllowHTTP permits a plain http:// URL, for local receivers.
+	AllowHTTP bool `yaml:"allow_http"`
+}
+
+// SyslogAlertConfig configures the syslog alerter, which writes each
+// violation as an RFC 5424 message. Network is "local" (the default:
+// journald on Linux when it is running, else the local syslog socket),
+// "journald", "udp", "tcp" or "tls"; Address (or SYSLOG_ADDRESS) is the
+// remote collector's host:port.
+type SyslogAlertConfig struct {
+	Network string `yaml:"network"`
+	Address string `yaml:"address"`
+	// Facility defaults to local0. AppName (default compliance-agent) is
+	// the APP-NAME, or SYSLOG_IDENTIFIER in the journal.
+	Facility string `yaml:"facility"`
+	AppName  string `yaml:"app_name"`
+	// Events picks "report", "violations", "resolved" or any mix; all by
+	// default. MinSeverity drops violations below it.
+	Events      []string `yaml:"events"`
+	MinSeverity string   `yaml:"min_severity"`
+	// CAFile verifies a tls collector against this CA instead of the
+	// system roots; CertFile and KeyFile present a client certificate.
+	CAFile   string        `yaml:"ca_file"`
+	CertFile string        `yaml:"cert_file"`
+	KeyFile  string        `yaml:"key_file"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// SinkConfig configures report sinks: destinations that receive every
+// full report and its violations as data, like a SIEM, rather than as
+// notifications.
+type SinkConfig struct {
+	// Enabled lists the sink backends to build, by registered name.
+	Enabled       []string                `yaml:"enabled"`
+	Splunk        SplunkSinkConfig        `yaml:"splunk"`
+	Elasticsearch ElasticsearchSinkConfig `yaml:"elasticsearch"`
+	Datadog       DatadogSinkConfig       `yaml:"datadog"`
+	ObjectStore   ObjectStoreSinkConfig   `yaml:"object_store"`
+}
+
+// ObjectStoreSinkConfig configures the object store sink. URL is
+// s3://bucket/prefix, gs://bucket/prefix or azblob://container/prefix;
+// credentials come from each cloud's usual environment variables (see
+// package objectstore). Reports are written under
+// <prefix>reports/<hostname>/YYYY/MM/DD/, and violations as JSON lines
+// under <prefix>violations/.
+type ObjectStoreSinkConfig struct {
+	URL string `yaml:"url"`
+	// Events picks "report", "violations" or both (the default).
+	Events []string `yaml:"events"`
+	// Gzip compresses each object and adds .gz to its name.
+	Gzip bool `yaml:"gzip"`
+	// MaxRetries and RetryBackoff work as for Splunk.
+	MaxRetries   int           `yaml:"max_retries"`
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+	Timeout      time.Duration `yaml:"timeout"`
+}
+
+// ElasticsearchSinkConfig configures the Elasticsearch/OpenSearch sink.
+// Reports and violations go to monthly indices named
+// <IndexPrefix>-reports-YYYY.MM and <IndexPrefix>-violations-YYYY.MM.
+// APIKey (or ELASTICSEARCH_API_KEY) is preferred over Username and
+// Password (or ELASTICSEARCH_PASSWORD).
+type ElasticsearchSinkConfig struct {
+	URL      string `yaml:"url"`
+	APIKey   string `yaml:"api_key"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// IndexPrefix defaults to "compliance".
+	IndexPrefix string `yaml:"index_prefix"`
+	// SkipTemplate leaves the index templates to the cluster's admins,
+	// for credentials that may only write documents.
+	SkipTemplate bool `yaml:"skip_template"`
+	// ILMPolicy names an Elasticsearch lifecycle policy the templates
+	// attach to new indices. OpenSearch's ISM ignores it.
+	ILMPolicy string `yaml:"ilm_policy"`
+	// Events picks "report", "violations" or both (the default).
+	Events []string `yaml:"events"`
+	// BatchSize caps the documents per bulk request (default 500).
+	BatchSize int `yaml:"batch_size"`
+	// MaxRetries and RetryBackoff work as for Splunk; rejections the
+	// cluster marks as 429 are retried too.
+	MaxRetries   int           `yaml:"max_retries"`
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+	Timeout      time.Duration `yaml:"timeout"`
+	CAFile       string        `yaml:"ca_file"`
+}
+
+// DatadogSinkConfig configures the Datadog sink: violation counts as
+// metrics, and violations as logs or events. APIKey overrides DD_API_KEY;
+// Site is the Datadog site (default datadoghq.com, or datadoghq.eu,
+// us5.datadoghq.com, ...). Tags are added to everything sent.
+type DatadogSinkConfig struct {
+	Site    string   `yaml:"site"`
+	APIKey  string   `yaml:"api_key"`
+	Tags    []string `yaml:"tags"`
+	Service string   `yaml:"service"`
+	// MetricPrefix names the metrics (default "compliance").
+	MetricPrefix string `yaml:"metric_prefix"`
+	// Violations sends each violation as "logs" (the default), "events"
+	// or "none" (metrics only).
+	Violations string `yaml:"violations"`
+	// APIURL and LogsURL override the endpoints Site implies (proxies,
+	// tests).
+	APIURL       string        `yaml:"api_url"`
+	LogsURL      string        `yaml:"logs_url"`
+	MaxRetries   int           `yaml:"max_retries"`
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+	Timeout      time.Duration `yaml:"timeout"`
+}
+
+// SplunkSinkConfig configures the Splunk HTTP Event Collector sink. URL
+// is the HEC base URL (https://splunk.example.com:8088); Token overrides
+// SPLUNK_HEC_TOKEN. Index, Source and Sourcetype are set on every event;
+// violation events use ViolationSourcetype. Empty Index uses the token's
+// default index.
+type SplunkSinkConfig struct {
+	URL                 string `yaml:"url"`
+	Token               string `yaml:"token"`
+	Index               string `yaml:"index"`
+	Source              string `yaml:"source"`
+	Sourcetype          string `yaml:"sourcetype"`
+	ViolationSourcetype string `yaml:"violation_sourcetype"`
+	// Events picks "report", "violations" or both (the default).
+	Events []string `yaml:"events"`
+	// BatchSize caps the events per request (default 100).
+	BatchSize int `yaml:"batch_size"`
+	// MaxRetries is how often a failed request is retried, with
+	// exponential backoff from RetryBackoff (defaults 3 and 1s). Only
+	// network errors, 429 and 5xx responses are retried.
+	MaxRetries   int           `yaml:"max_retries"`
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+	Timeout      time.Duration `yaml:"timeout"`
+	// CAFile verifies HEC's certificate against this CA instead of the
+	// system roots, for Splunk's self-signed default.
+	CAFile string `yaml:"ca_file"`
 }
 
 type ExporterConfig struct {
@@ -43,22 +354,323 @@ type ExporterConfig struct {
 	Addr    string `yaml:"addr"`
 }
 
+// OSQueryConfig controls the osqueryd the agent spawns when none is
+// running. Fields map onto osquery flags of the same name.
+type OSQueryConfig struct {
+	Socket                   string            `yaml:"socket"`
+	WatchdogLevel            int               `yaml:"watchdog_level"`
+	WatchdogMemoryLimitMB    int               `yaml:"watchdog_memory_limit_mb"`
+	WatchdogUtilizationLimit int               `yaml:"watchdog_utilization_limit"`
+	DisableEvents            bool              `yaml:"disable_events"`
+	EventsExpiry             int               `yaml:"events_expiry"`
+	EventsMax                int               `yaml:"events_max"`
+	LoggerPlugin             string            `yaml:"logger_plugin"`
+	ExtraFlags               map[string]string `yaml:"extra_flags"`
+}
+
+// FleetConfig switches collection to a Fleet server's live-query API for
+// hosts whose local osquery socket is not accessible. Empty URL disables.
+type FleetConfig struct {
+	URL            string        `yaml:"url"`
+	Token          string        `yaml:"token"`
+	HostIdentifier string        `yaml:"host_identifier"`
+	Timeout        time.Duration `yaml:"timeout"`
+}
+
+// HistoryConfig controls the local SQLite report history. Empty Path
+// disables it; zero Retention or MaxReports means no limit. Runs older
+// than AnonymizeAfter, when set, keep only aggregate metrics and
+// violation fingerprints.
+type HistoryConfig struct {
+	Path           string        `yaml:"path"`
+	Retention      time.Duration `yaml:"retention"`
+	MaxReports     int           `yaml:"max_reports"`
+	AnonymizeAfter time.Duration `yaml:"anonymize_after"`
+}
+
+// IdentityConfig is where the agent keeps its stable ID. Empty Path
+// leaves reports without one.
+type IdentityConfig struct {
+	Path string `yaml:"path"`
+}
+
+// EvidenceConfig controls tamper-evident audit output. Manifest is the
+// evidence manifest (SHA-256 of each artifact plus scan metadata) written
+// for external timestamping; Log is the append-only, hash-chained log of
+// every scan. Empty paths disable them.
+type EvidenceConfig struct {
+	Manifest string `yaml:"manifest"`
+	Log      string `yaml:"log"`
+}
+
+// GeoIPConfig points at local MMDB files used to tag established
+// connections with country and ASN. Both empty disables enrichment.
+type GeoIPConfig struct {
+	CountryDB string `yaml:"country_db"`
+	ASNDB     string `yaml:"asn_db"`
+}
+
+// DNSConfig lists local resolver query logs (dnsmasq, Unbound, BIND) to
+// sample lookups from, in addition to the platform resolver cache.
+type DNSConfig struct {
+	LogPaths []string `yaml:"log_paths"`
+}
+
+// OSVConfig controls package vulnerability lookups against OSV.dev.
+// Ecosystem overrides the one detected from /etc/os-release, e.g.
+// "Debian:12" on a Debian derivative.
+type OSVConfig struct {
+	URL       string        `yaml:"url"`
+	CachePath string        `yaml:"cache_path"`
+	CacheTTL  time.Duration `yaml:"cache_ttl"`
+	Ecosystem string        `yaml:"ecosystem"`
+}
+
+// RiskConfig scores violations for ordering. Criticality is how much
+// this host matters (low, medium, high or critical; default medium).
+// Exposure overrides the factor for categories, by default 1.5 for
+// network-reachable findings (open ports, sshd, TLS, sharing, ...) and 1
+// for the rest.
+type RiskConfig struct {
+	Criticality string             `yaml:"criticality"`
+	Exposure    map[string]float64 `yaml:"exposure"`
+}
+
+// SummaryConfig configures the executive summary (`compliance-agent
+// summary`, and the fleet server's /api/v1/summary). Period is how far
+// back it looks; Top how many recurring rules it lists. A host fails when
+// it has a violation at or above FailSeverity. SLA is how long a
+// violation of each severity may stay open; severities not listed have
+// no SLA.
+type SummaryConfig struct {
+	Period       time.Duration            `yaml:"period"`
+	Top          int                      `yaml:"top"`
+	FailSeverity string                   `yaml:"fail_severity"`
+	SLA          map[string]time.Duration `yaml:"sla"`
+	Email        EmailConfig              `yaml:"email"`
+}
+
+// EmailConfig is where `summary -send` mails the summary. SMTPAddr is
+// host:port; STARTTLS is used when the server offers it. Password falls
+// back to SMTP_PASSWORD.
+type EmailConfig struct {
+	SMTPAddr string   `yaml:"smtp_addr"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+}
+
+// ServerConfig configures `compliance-agent server`, the fleet server
+// agents enroll with, upload reports to and fetch policy from. It serves
+// HTTPS from CertFile and KeyFile unless InsecureHTTP is set, e.g. behind
+// a TLS-terminating proxy. EnrollTokens fall back to
+// COMPLIANCE_ENROLL_TOKEN and AdminToken, which guards the host and
+// report endpoints, to COMPLIANCE_ADMIN_TOKEN. An empty PolicyPath leaves
+// agents on their local policy. Zero Retention or MaxReportsPerHost means
+// no limit. Reports older than AnonymizeAfter, when set, keep only
+// aggregate metrics and violation fingerprints.
+//
+// With ClientCACert and ClientCAKey set the server uses mutual TLS: it
+// signs each agent a client certificate at enrollment, valid for
+// ClientCertTTL, and the agent endpoints require it on top of the token.
+type ServerConfig struct {
+	Addr              string        `yaml:"addr"`
+	CertFile          string        `yaml:"cert_file"`
+	KeyFile           string        `yaml:"key_file"`
+	InsecureHTTP      bool          `yaml:"insecure_http"`
+	DBPath            string        `yaml:"db_path"`
+	EnrollTokens      []string      `yaml:"enroll_tokens"`
+	AdminToken        string        `yaml:"admin_token"`
+	PolicyPath        string        `yaml:"policy_path"`
+	MaxReportBytes    int64         `yaml:"max_report_bytes"`
+	Retention         time.Duration `yaml:"retention"`
+	MaxReportsPerHost int           `yaml:"max_reports_per_host"`
+	AnonymizeAfter    time.Duration `yaml:"anonymize_after"`
+	ClientCACert      string        `yaml:"client_ca_cert"`
+	ClientCAKey       string        `yaml:"client_ca_key"`
+	ClientCertTTL     time.Duration `yaml:"client_cert_ttl"`
+	// PolicySignature is the policy's signature file, handed to agents
+	// with it; by default PolicyPath + ".sig" when that exists.
+	PolicySignature string `yaml:"policy_signature"`
+	// UnknownFields is what the server does with a JSON member it has no
+	// field for in an agent's request: "ignore" drops it, "reject"
+	// refuses the request.
+	UnknownFields string `yaml:"unknown_fields"`
+	// MetricsToken lets Prometheus scrape /metrics without the admin
+	// token, which reads every report.
+	MetricsToken string `yaml:"metrics_token"`
+	// ReportKey is the private key (see `encrypt keygen`) that decrypts
+	// uploads from agents with central.encrypt_to set.
+	ReportKey string `yaml:"report_key"`
+}
+
+// CentralConfig points the agent at a fleet server. Empty URL disables
+// it. The agent enrolls with EnrollToken (or COMPLIANCE_ENROLL_TOKEN)
+// the first time and keeps the credentials it is issued in
+// CredentialsPath, with the client certificate a mutual-TLS server
+// issues. CAFile verifies a server with a private CA, and is the only CA
+// trusted when set; PinSHA256 further pins the server's chain to the
+// base64 SHA-256 of one of its public keys.
+type CentralConfig struct {
+	URL             string        `yaml:"url"`
+	EnrollToken     string        `yaml:"enroll_token"`
+	CredentialsPath string        `yaml:"credentials_path"`
+	CAFile          string        `yaml:"ca_file"`
+	PinSHA256       []string      `yaml:"pin_sha256"`
+	Timeout         time.Duration `yaml:"timeout"`
+	// EncryptTo is the fleet server's report public key: reports are
+	// encrypted to it before upload, so only the server can read them,
+	// whatever terminates TLS in between.
+	EncryptTo string `yaml:"encrypt_to"`
+}
+
+// PolicySourceConfig fetches the policy from URL, an https:// URL or an
+// s3://, gs:// or azblob:// bucket/key URL, instead of the fleet server.
+// SignatureURL defaults to URL + ".sig". A policy from either source is
+// only used when one of PublicKeys signed it; they are required whenever
+// a policy is fetched, unless AllowUnsigned opts out of signing.
+type PolicySourceConfig struct {
+	URL           string        `yaml:"url"`
+	SignatureURL  string        `yaml:"signature_url"`
+	PublicKeys    []string      `yaml:"public_keys"`
+	AllowUnsigned bool          `yaml:"allow_unsigned"`
+	Timeout       time.Duration `yaml:"timeout"`
+}
+
+// EncryptionConfig encrypts the report files the agent writes from a
+// scan (run, daemon and report) to Recipients, X25519 public
+// keys given as files, PEM or base64. Only the holder of a matching
+// private key can read them; see package reportcrypt.
+type EncryptionConfig struct {
+	Recipients []string `yaml:"recipients"`
+}
+
+// LogConfig sets the agent's log level (debug, info, warn or error) and
+// format (text or JSON lines), both on stderr. --log-level and
+// --log-format override them.
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// ReleaseConfig sets what run, daemon and server do when a release
+// binary's signature doesn't check out at startup: VerifySignature is
+// "warn" (log it and go on), "enforce" (refuse to start) or "off".
+// Binaries built without a release key, such as a plain go build, never
+// check; see package release.
+type ReleaseConfig struct {
+	VerifySignature string `yaml:"verify_signature"`
+}
+
+// SBOMConfig configures the sbom command. Namespace is the base URI of
+// SPDX document namespaces; each document gets a unique one under it.
+// Organization, when set, is named as a creator of SPDX documents.
+type SBOMConfig struct {
+	Namespace       string                `yaml:"namespace"`
+	Organization    string                `yaml:"organization"`
+	DependencyTrack DependencyTrackConfig `yaml:"dependency_track"`
+}
+
+// DependencyTrackConfig is where `sbom -submit` uploads SBOMs. URL and
+// APIKey fall back to DEPENDENCY_TRACK_URL and DEPENDENCY_TRACK_API_KEY;
+// the key needs the BOM_UPLOAD permission, and PROJECT_CREATION_UPLOAD
+// for AutoCreate. ProjectName defaults to the hostname (the reference
+// for an image) and ProjectVersion to "latest".
+type DependencyTrackConfig struct {
+	URL            string        `yaml:"url"`
+	APIKey         string        `yaml:"api_key"`
+	ProjectName    string        `yaml:"project_name"`
+	ProjectVersion string        `yaml:"project_version"`
+	AutoCreate     bool          `yaml:"auto_create"`
+	Timeout        time.Duration `yaml:"timeout"`
+}
+
 // Default returns the safe defaults used when no config file is provided.
 func Default() Config {
 	return Config{
-		Mode:     "oneshot",
-		Interval: 5 * time.Minute,
-		Baseline: BaselineConfig{Path: "compliance_baseline.json"},
+		Mode:           "oneshot",
+		Scope:          "system",
+		Interval:       5 * time.Minute,
+		CollectTimeout: 2 * time.Minute,
+		Baseline:       BaselineConfig{Path: "compliance_baseline.json"},
 		ML: MLConfig{
 			URL:       envOr("ML_SERVICE_URL", ""),
 			Timeout:   2 * time.Second,
 			Threshold: 0.7,
 		},
-		Alerting: AlertConfig{OnAnomaly: true},
+		Alerting: AlertConfig{
+			OnAnomaly:   true,
+			Enabled:     []string{"slack"},
+			Dedup:       DedupConfig{Window: 24 * time.Hour},
+			Correlation: CorrelationConfig{Enabled: true},
+			ChatLimit:   10,
+		},
 		Exporter: ExporterConfig{
 			Enabled: envBool("EXPORTER_ENABLED", false),
 			Addr:    envOr("EXPORTER_ADDR", ":9100"),
 		},
+		OSQuery: OSQueryConfig{
+			Socket:                   envOr("OSQUERY_SOCKET", ""),
+			WatchdogMemoryLimitMB:    200,
+			WatchdogUtilizationLimit: 10,
+			DisableEvents:            true,
+			EventsExpiry:             3600,
+			EventsMax:                50000,
+			LoggerPlugin:             "filesystem",
+		},
+		Fleet: FleetConfig{
+			URL:     envOr("FLEET_URL", ""),
+			Token:   envOr("FLEET_API_TOKEN", ""),
+			Timeout: 30 * time.Second,
+		},
+		History: HistoryConfig{
+			Path:      "compliance_history.db",
+			Retention: 90 * 24 * time.Hour,
+		},
+		Identity: IdentityConfig{Path: "agent_identity.json"},
+		OSV: OSVConfig{
+			URL:       "https://api.osv.dev",
+			CachePath: "osv_cache.json",
+			CacheTTL:  24 * time.Hour,
+		},
+		Summary: SummaryConfig{
+			Period:       7 * 24 * time.Hour,
+			Top:          5,
+			FailSeverity: "high",
+			SLA: map[string]time.Duration{
+				"critical": 72 * time.Hour,
+				"high":     7 * 24 * time.Hour,
+				"medium":   30 * 24 * time.Hour,
+			},
+		},
+		Server: ServerConfig{
+			Addr:           ":8443",
+			DBPath:         "fleet.db",
+			MaxReportBytes: 32 << 20,
+			Retention:      90 * 24 * time.Hour,
+			ClientCertTTL:  30 * 24 * time.Hour,
+			UnknownFields:  "ignore",
+		},
+		Central: CentralConfig{
+			URL:             envOr("COMPLIANCE_SERVER_URL", ""),
+			CredentialsPath: "agent_credentials.json",
+			Timeout:         30 * time.Second,
+		},
+		PolicySource: PolicySourceConfig{Timeout: 30 * time.Second},
+		SBOM: SBOMConfig{
+			Namespace: "https://spdx.org/spdxdocs",
+			DependencyTrack: DependencyTrackConfig{
+				URL:        envOr("DEPENDENCY_TRACK_URL", ""),
+				AutoCreate: true,
+				Timeout:    time.Minute,
+			},
+		},
+		Release: ReleaseConfig{VerifySignature: "warn"},
+		Log: LogConfig{
+			Level:  envOr("LOG_LEVEL", "info"),
+			Format: envOr("LOG_FORMAT", "text"),
+		},
 	}
 }
 
@@ -77,7 +689,7 @@ func Load(path string) (Config, error) {
 		return c, fmt.Errorf("read %s: %w", path, err)
 	}
 	if err := yaml.Unmarshal(b, &c); err != nil {
-		return c, fmt.Errorf("parse %s: %w", path, err)
+		return c, errcode.Errorf(errcode.ParseError, "parse %s: %w", path, err)
 	}
 	return c, nil
 }
@@ -14,6 +14,7 @@ func TestDefault_NoEnv(t *testing.T) {
 	c := Default()
 	assert.Equal(t, "oneshot", c.Mode)
 	assert.Equal(t, 5*time.Minute, c.Interval)
+	assert.Equal(t, 2*time.Minute, c.CollectTimeout)
 	assert.InDelta(t, 0.7, c.ML.Threshold, 1e-9)
 }
 
@@ -35,6 +36,9 @@ ml:
 exporter:
   enabled: true
   addr: ":9101"
+osquery:
+  watchdog_memory_limit_mb: 500
+  logger_plugin: syslog
 `), 0o644))
 
 	c, err := Load(path)
@@ -44,4 +48,7 @@ exporter:
 	assert.Equal(t, "http://ml:8000/score", c.ML.URL)
 	assert.InDelta(t, 0.5, c.ML.Threshold, 1e-9)
 	assert.True(t, c.Exporter.Enabled)
+	assert.Equal(t, 500, c.OSQuery.WatchdogMemoryLimitMB)
+	assert.Equal(t, "syslog", c.OSQuery.LoggerPlugin)
+	assert.True(t, c.OSQuery.DisableEvents, "unset keys keep their defaults")
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"compliance-agent/errcode"
+	"compliance-agent/reportcrypt"
+)
+
+const encryptUsage = `Usage:
+  %[1]s encrypt keygen -o name                  write name.key and name.pub
+  %[1]s encrypt -to key[,key...] [-o out] file   encrypt a file (default file.enc)
+  %[1]s decrypt -key name.key [-o out] file      decrypt a report (- for stdout)
+`
+
+// cmdEncrypt implements `compliance-agent encrypt`: the key pairs saved
+// reports are encrypted to (encryption.recipients), and encrypting a file
+// such as a report written before encryption was turned on.
+func cmdEncrypt(args []string) {
+	if len(args) > 0 && args[0] == "keygen" {
+		encryptKeygen(args[1:])
+		return
+	}
+	fs := flag.NewFlagSet("encrypt", flag.ContinueOnError)
+	to := fs.String("to", "", "Public keys to encrypt to, comma-separated: files, PEM or base64 (required)")
+	out := fs.String("o", "", "Write the encrypted file here (default <file>.enc)")
+	parseFlags(fs, args)
+	if *to == "" || fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, encryptUsage, os.Args[0])
+		usageError("encrypt: want -to and one file")
+	}
+	path := fs.Arg(0)
+	if *out == "" {
+		*out = path + ".enc"
+	}
+	recipients, err := reportcrypt.ParseRecipients(strings.Split(*to, ","))
+	if err != nil {
+		fatal(errcode.New(errcode.ParseError, err), "encrypt: -to")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		fatal(err, "encrypt")
+	}
+	if reportcrypt.IsEncrypted(b) {
+		usageError("encrypt: %s is already encrypted", path)
+	}
+	enc, err := reportcrypt.Encrypt(recipients, b)
+	if err != nil {
+		fatal(err, "encrypt")
+	}
+	if err := os.WriteFile(*out, enc, 0o600); err != nil {
+		fatal(err, "encrypt")
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s, readable with the private key of any of %d key(s)\n", *out, len(recipients))
+}
+
+func encryptKeygen(args []string) {
+	fs := flag.NewFlagSet("encrypt keygen", flag.ContinueOnError)
+	out := fs.String("o", "report-encryption", "Write the key pair to this name plus .key and .pub")
+	parseFlags(fs, args)
+	privPEM, pubPEM, err := reportcrypt.GenerateKey()
+	if err != nil {
+		fatal(err, "keygen")
+	}
+	// O_EXCL: overwriting the private key would lose every report
+	// encrypted to it.
+	f, err := os.OpenFile(*out+".key", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		fatal(err, "keygen")
+	}
+	if _, err := f.Write(privPEM); err != nil {
+		fatal(err, "keygen")
+	}
+	if err := f.Close(); err != nil {
+		fatal(err, "keygen")
+	}
+	if err := os.WriteFile(*out+".pub", pubPEM, 0o644); err != nil {
+		fatal(err, "keygen")
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s.key (keep it off the agents) and %s.pub (for encryption.recipients)\n", *out, *out)
+}
+
+func cmdDecrypt(args []string) {
+	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	keyPath := fs.String("key", "", "Private key from encrypt keygen (required)")
+	out := fs.String("o", "", "Write the report here (default <file> without .enc; - for stdout)")
+	parseFlags(fs, args)
+	if *keyPath == "" || fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, encryptUsage, os.Args[0])
+		usageError("decrypt: want -key and one file")
+	}
+	path := fs.Arg(0)
+	if *out == "" {
+		if *out = strings.TrimSuffix(path, ".enc"); *out == path {
+			*out = "-"
+		}
+	}
+	key, err := reportcrypt.LoadPrivateKey(*keyPath)
+	if err != nil {
+		fatal(err, "decrypt")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		fatal(err, "decrypt")
+	}
+	plain, err := reportcrypt.Decrypt(key, b)
+	if err != nil {
+		fatal(err, "decrypt "+path)
+	}
+	if *out == "-" {
+		_, _ = os.Stdout.Write(plain)
+		return
+	}
+	if err := os.WriteFile(*out, plain, 0o600); err != nil {
+		fatal(err, "decrypt")
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", *out)
+}
@@ -0,0 +1,155 @@
+// Package errcode sorts the agent's errors into a few stable categories,
+// so logs, the report's errors, metrics and exit statuses can be matched
+// on a code instead of on message text that changes with every wording
+// fix and every platform's error strings.
+package errcode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// Code is an error category. The values are stable: alerts, dashboards
+// and scripts match on them.
+type Code string
+
+const (
+	// CollectorUnavailable is a data source that isn't there: a missing
+	// command, an osquery or Fleet that can't be reached, a dataset the
+	// platform doesn't have.
+	CollectorUnavailable Code = "collector-unavailable"
+	// PermissionDenied is a file, socket or API the agent wasn't allowed
+	// to use, including an osquery socket it refused as unsafe.
+	PermissionDenied Code = "permission-denied"
+	// Timeout is anything that ran out of time.
+	Timeout Code = "timeout"
+	// ParseError is input that couldn't be read: a config, policy or
+	// report file, or a command's or API's output.
+	ParseError Code = "parse-error"
+	// NotifierFailure is a report, alert or upload that didn't reach an
+	// alerter, sink or the fleet server.
+	NotifierFailure Code = "notifier-failure"
+	// Internal is a bug: a subsystem panicked.
+	Internal Code = "internal"
+	// Usage is a command run wrongly: a bad flag value, flags that
+	// conflict or a required one missing.
+	Usage Code = "usage"
+	// NotConfigured is a command with nothing configured to act on, such
+	// as notify test without any alerter or sink enabled.
+	NotConfigured Code = "not-configured"
+	// CheckFailed is a check a command was run for that didn't pass: a
+	// policy or release signature that doesn't verify, or an image that
+	// regressed from its golden report.
+	CheckFailed Code = "check-failed"
+	// Unknown is an error none of the above covers.
+	Unknown Code = "unknown"
+)
+
+// Codes lists every code, in the order the README documents them.
+var Codes = []Code{CollectorUnavailable, PermissionDenied, Timeout, ParseError, NotifierFailure, Internal, Usage, NotConfigured, CheckFailed, Unknown}
+
+// exitCodes follow sysexits.h where it has a match. None is 1 or 2,
+// which the default -exit-codes give to violations.
+var exitCodes = map[Code]int{
+	CollectorUnavailable: 69, // EX_UNAVAILABLE
+	PermissionDenied:     77, // EX_NOPERM
+	Timeout:              75, // EX_TEMPFAIL
+	ParseError:           65, // EX_DATAERR
+	NotifierFailure:      76, // EX_PROTOCOL
+	Internal:             70, // EX_SOFTWARE
+	Usage:                64, // EX_USAGE
+	NotConfigured:        78, // EX_CONFIG
+	CheckFailed:          79, // none; the first after sysexits.h
+	Unknown:              71, // EX_OSERR
+}
+
+// ExitCode is the exit status of a command stopped by an error of code c.
+func (c Code) ExitCode() int {
+	if n, ok := exitCodes[c]; ok {
+		return n
+	}
+	return exitCodes[Unknown]
+}
+
+// Reserved reports whether n is the exit status of some code, so
+// -exit-codes can't give it to violations too.
+func Reserved(n int) bool {
+	for _, c := range exitCodes {
+		if c == n {
+			return true
+		}
+	}
+	return false
+}
+
+// Error is an error with its category set where it happened.
+type Error struct {
+	Code Code
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+// New tags err with code c; a nil err stays nil.
+func New(c Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: c, Err: err}
+}
+
+// Errorf is fmt.Errorf tagged with code c.
+func Errorf(c Code, format string, args ...any) error {
+	return &Error{Code: c, Err: fmt.Errorf(format, args...)}
+}
+
+// Of returns err's code: the one it was tagged with, or given by an
+// error type in its chain with an ErrorCode method, else one inferred
+// from the standard library errors in its chain. It is "" for nil.
+func Of(err error) Code {
+	if err == nil {
+		return ""
+	}
+	var tagged *Error
+	if errors.As(err, &tagged) {
+		return tagged.Code
+	}
+	var coded interface{ ErrorCode() Code }
+	if errors.As(err, &coded) {
+		return coded.ErrorCode()
+	}
+	var (
+		netErr  net.Error
+		syntax  *json.SyntaxError
+		typeErr *json.UnmarshalTypeError
+		numErr  *strconv.NumError
+		dnsErr  *net.DNSError
+		opErr   *net.OpError
+	)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return Timeout
+	case errors.Is(err, fs.ErrPermission):
+		return PermissionDenied
+	case errors.As(err, &syntax), errors.As(err, &typeErr), errors.As(err, &numErr):
+		return ParseError
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, errors.ErrUnsupported),
+		errors.As(err, &dnsErr), errors.As(err, &opErr) && opErr.Op == "dial":
+		return CollectorUnavailable
+	}
+	return Unknown
+}
+
+// Format is err with its code in front, "[timeout] ...", for logs.
+func Format(err error) string {
+	return fmt.Sprintf("[%s] %v", Of(err), err)
+}
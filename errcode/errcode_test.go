@@ -0,0 +1,68 @@
+package errcode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os/exec"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type refused struct{}
+
+func (refused) Error() string   { return "refusing socket" }
+func (refused) ErrorCode() Code { return PermissionDenied }
+
+func TestOf(t *testing.T) {
+	var syntax error = &json.SyntaxError{}
+	for _, c := range []struct {
+		err  error
+		want Code
+	}{
+		{nil, ""},
+		{New(ParseError, errors.New("bad")), ParseError},
+		{fmt.Errorf("fleet: %w", Errorf(Timeout, "no answer in %s", "30s")), Timeout},
+		{fmt.Errorf("setup: %w", refused{}), PermissionDenied},
+		{fmt.Errorf("users: %w", context.DeadlineExceeded), Timeout},
+		{&net.OpError{Op: "read", Err: timeoutErr{}}, Timeout},
+		{&fs.PathError{Op: "open", Path: "/etc/shadow", Err: fs.ErrPermission}, PermissionDenied},
+		{fmt.Errorf("osquery: %w", syntax), ParseError},
+		{&strconv.NumError{Func: "Atoi", Num: "x", Err: strconv.ErrSyntax}, ParseError},
+		{&exec.Error{Name: "dpkg-query", Err: exec.ErrNotFound}, CollectorUnavailable},
+		{&net.OpError{Op: "dial", Net: "unix", Err: errors.New("connection refused")}, CollectorUnavailable},
+		{&fs.PathError{Op: "open", Path: "report.json", Err: fs.ErrNotExist}, Unknown},
+		{errors.ErrUnsupported, CollectorUnavailable},
+		{errors.New("status 500"), Unknown},
+		{context.Canceled, Unknown},
+	} {
+		assert.Equal(t, c.want, Of(c.err), "%v", c.err)
+	}
+}
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+func TestExitCode(t *testing.T) {
+	seen := map[int]Code{}
+	for _, c := range Codes {
+		n := c.ExitCode()
+		assert.NotContains(t, seen, n, "%s and %s share an exit code", c, seen[n])
+		seen[n] = c
+	}
+	assert.Equal(t, 65, ParseError.ExitCode())
+	assert.Equal(t, Unknown.ExitCode(), Code("nonsense").ExitCode())
+	assert.True(t, Reserved(77))
+	assert.True(t, Reserved(CheckFailed.ExitCode()), "failed verifications aren't violations either")
+	assert.False(t, Reserved(1), "the default -exit-codes use 1 and 2")
+	assert.False(t, Reserved(2))
+	assert.Equal(t, "[timeout] context deadline exceeded", Format(context.DeadlineExceeded))
+}
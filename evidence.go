@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"compliance-agent/config"
+	"compliance-agent/evidence"
+	"compliance-agent/logging"
+	"compliance-agent/report"
+)
+
+// evidenceFile is one artifact for the evidence manifest.
+type evidenceFile struct{ role, path string }
+
+// writeEvidence writes the evidence manifest for rep covering files. Unset
+// paths and stdout ("-") are skipped.
+func writeEvidence(manifestPath string, rep report.ComplianceReport, files ...evidenceFile) error {
+	m := evidence.New(rep)
+	for _, f := range files {
+		if f.path == "" || f.path == "-" {
+			continue
+		}
+		if err := m.Add(f.role, f.path); err != nil {
+			return err
+		}
+	}
+	digest, err := m.Write(manifestPath)
+	if err != nil {
+		return err
+	}
+	logging.Component("evidence").Info("saved the evidence manifest", "path", manifestPath, "sha256", digest)
+	return nil
+}
+
+// cmdVerifyLog implements `compliance-agent verify-log`: walk the evidence
+// log's hash chain and exit non-zero at the first modified, reordered or
+// missing entry.
+func cmdVerifyLog(args []string) {
+	fs := flag.NewFlagSet("verify-log", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to YAML config (optional)")
+	logPath := fs.String("log", "", "Evidence log (overrides config evidence.log)")
+	parseFlags(fs, args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fatal(err, "config load")
+	}
+	path := cfg.Evidence.Log
+	if *logPath != "" {
+		path = *logPath
+	}
+	if path == "" {
+		usageError("evidence log is disabled (evidence.log is empty)")
+	}
+	n, err := evidence.VerifyLog(path)
+	if err != nil {
+		fatal(fmt.Errorf("%w (%d entries verified before it)", err, n), path)
+	}
+	fmt.Printf("%s: %d entries, chain intact\n", path, n)
+}
@@ -0,0 +1,182 @@
+package evidence
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/report"
+)
+
+// LogEntry is one scan in the evidence log. Hash covers every other field,
+// including PrevHash, so editing or deleting an entry breaks the chain
+// from that point on.
+type LogEntry struct {
+	Seq               int64     `json:"seq"`
+	Time              time.Time `json:"time"`
+	Hostname          string    `json:"hostname"`
+	Scope             string    `json:"scope,omitempty"`
+	ReportGeneratedAt time.Time `json:"report_generated_at"`
+	// ReportSHA256 is the digest of the report's JSON form, so a
+	// surviving report file can be matched to its entry.
+	ReportSHA256 string               `json:"report_sha256"`
+	Violations   []analyzer.Violation `json:"violations"`
+	Errors       int                  `json:"errors"`
+	PrevHash     string               `json:"prev_hash"`
+	Hash         string               `json:"hash"`
+}
+
+func (e LogEntry) computeHash() (string, error) {
+	e.Hash = ""
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log is an append-only, hash-chained JSON-lines file of scan summaries.
+// The agent only ever appends; for OS-level enforcement on Linux, mark the
+// file append-only with `chattr +a`.
+type Log struct {
+	mu   sync.Mutex
+	f    *os.File
+	seq  int64
+	last string
+}
+
+// OpenLog opens (creating if needed) the log at path and positions the
+// chain after its last entry. It doesn't verify the existing entries; see
+// VerifyLog.
+func OpenLog(path string) (*Log, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("evidence log dir: %w", err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{f: f}
+	err = readEntries(f, func(e LogEntry) error {
+		l.seq, l.last = e.Seq, e.Hash
+		return nil
+	})
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("evidence log %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// Append records a scan and returns the entry written.
+func (l *Log) Append(rep report.ComplianceReport) (LogEntry, error) {
+	b, err := rep.ToJSON()
+	if err != nil {
+		return LogEntry{}, err
+	}
+	sum := sha256.Sum256(b)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e := LogEntry{
+		Seq:               l.seq + 1,
+		Time:              time.Now().UTC(),
+		Hostname:          rep.Hostname,
+		Scope:             rep.Scope,
+		ReportGeneratedAt: rep.GeneratedAt,
+		ReportSHA256:      hex.EncodeToString(sum[:]),
+		Violations:        rep.Violations,
+		Errors:            len(rep.Errors),
+		PrevHash:          l.last,
+	}
+	if e.Hash, err = e.computeHash(); err != nil {
+		return LogEntry{}, err
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return LogEntry{}, err
+	}
+	if _, err := l.f.Write(append(line, '\n')); err != nil {
+		return LogEntry{}, err
+	}
+	if err := l.f.Sync(); err != nil {
+		return LogEntry{}, err
+	}
+	l.seq, l.last = e.Seq, e.Hash
+	return e, nil
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	return l.f.Close()
+}
+
+// ErrChainBroken is wrapped by VerifyLog when an entry doesn't match its
+// hash or doesn't follow the entry before it.
+var ErrChainBroken = errors.New("evidence log chain broken")
+
+// VerifyLog checks every entry's hash and link to its predecessor. It
+// returns the number of entries that verified before the first problem.
+func VerifyLog(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n := 0
+	var prev LogEntry
+	err = readEntries(f, func(e LogEntry) error {
+		want, err := e.computeHash()
+		if err != nil {
+			return err
+		}
+		switch {
+		case e.Hash != want:
+			return fmt.Errorf("%w: entry %d was modified", ErrChainBroken, e.Seq)
+		case e.PrevHash != prev.Hash:
+			return fmt.Errorf("%w: entry %d doesn't link to entry %d", ErrChainBroken, e.Seq, prev.Seq)
+		case e.Seq != prev.Seq+1:
+			return fmt.Errorf("%w: entry %d follows entry %d (missing entries)", ErrChainBroken, e.Seq, prev.Seq)
+		}
+		prev = e
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// readEntries calls fn for each line of the log in order.
+func readEntries(r io.Reader, fn func(LogEntry) error) error {
+	br := bufio.NewReader(r)
+	for lineNo := 1; ; lineNo++ {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var e LogEntry
+			if jerr := json.Unmarshal(line, &e); jerr != nil {
+				return fmt.Errorf("%w: line %d: %v", ErrChainBroken, lineNo, jerr)
+			}
+			if ferr := fn(e); ferr != nil {
+				return ferr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package evidence
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeLog(t *testing.T, path string, n int) {
+	t.Helper()
+	l, err := OpenLog(path)
+	require.NoError(t, err)
+	defer l.Close()
+	for i := 0; i < n; i++ {
+		_, err := l.Append(report.ComplianceReport{
+			Hostname:   "host1",
+			Violations: []analyzer.Violation{{Category: "port", Severity: analyzer.SeverityMedium, Message: "unexpected open port: 23"}},
+		})
+		require.NoError(t, err)
+	}
+}
+
+func TestLog_AppendAcrossOpens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "evidence.log")
+	writeLog(t, path, 2)
+	writeLog(t, path, 1) // a restarted agent continues the chain
+
+	n, err := VerifyLog(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
+
+func TestVerifyLog_DetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "evidence.log")
+	writeLog(t, path, 3)
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(string(b), "\n")
+
+	// Editing an entry breaks its hash.
+	edited := strings.Replace(lines[1], "port: 23", "port: 22", 1)
+	require.NoError(t, os.WriteFile(path, []byte(lines[0]+edited+lines[2]), 0o600))
+	n, err := VerifyLog(path)
+	assert.ErrorIs(t, err, ErrChainBroken)
+	assert.Contains(t, err.Error(), "entry 2 was modified")
+	assert.Equal(t, 1, n)
+
+	// Deleting an entry breaks the link.
+	require.NoError(t, os.WriteFile(path, []byte(lines[0]+lines[2]), 0o600))
+	_, err = VerifyLog(path)
+	assert.ErrorIs(t, err, ErrChainBroken)
+	assert.Contains(t, err.Error(), "entry 3 doesn't link to entry 1")
+}
@@ -0,0 +1,113 @@
+// Package evidence writes a manifest of the artifacts a scan produced, with
+// their SHA-256 digests, so audit evidence can be proven unmodified later.
+//
+// The agent deliberately doesn't contact a timestamping service: hosts may
+// be air-gapped, and a local clock proves nothing. Instead the manifest and
+// a digest of it are written next to the artifacts, and the digest is what
+// gets submitted to an external timestamping or notarization service
+// (e.g. an RFC 3161 TSA via `openssl ts -query`).
+package evidence
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"compliance-agent/report"
+)
+
+// ManifestVersion is bumped when the manifest layout changes.
+const ManifestVersion = 1
+
+// Artifact is one file covered by the manifest.
+type Artifact struct {
+	// Role says what the file is: report, collection, baseline, policy.
+	Role   string `json:"role"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest describes one scan's evidence.
+type Manifest struct {
+	Version int `json:"manifest_version"`
+	// CreatedAt comes from the host clock and is informational only; the
+	// external timestamp is the trusted one.
+	CreatedAt         time.Time      `json:"created_at"`
+	Hostname          string         `json:"hostname"`
+	Scope             string         `json:"scope,omitempty"`
+	ReportGeneratedAt time.Time      `json:"report_generated_at"`
+	Violations        int            `json:"violations"`
+	BySeverity        map[string]int `json:"by_severity"`
+	Errors            int            `json:"errors"`
+	Artifacts         []Artifact     `json:"artifacts"`
+}
+
+// New starts a manifest with the scan metadata from rep.
+func New(rep report.ComplianceReport) *Manifest {
+	m := &Manifest{
+		Version:           ManifestVersion,
+		CreatedAt:         time.Now().UTC(),
+		Hostname:          rep.Hostname,
+		Scope:             rep.Scope,
+		ReportGeneratedAt: rep.GeneratedAt,
+		Violations:        len(rep.Violations),
+		BySeverity:        map[string]int{},
+		Errors:            len(rep.Errors),
+	}
+	for _, v := range rep.Violations {
+		m.BySeverity[string(v.Severity)]++
+	}
+	return m
+}
+
+// Add hashes the file at path and records it under role.
+func (m *Manifest) Add(role, path string) error {
+	a, err := HashFile(path)
+	if err != nil {
+		return err
+	}
+	a.Role = role
+	m.Artifacts = append(m.Artifacts, a)
+	return nil
+}
+
+// HashFile returns the size and SHA-256 of a file.
+func HashFile(path string) (Artifact, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Artifact{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return Artifact{}, fmt.Errorf("hash %s: %w", path, err)
+	}
+	return Artifact{Path: path, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// Write saves the manifest as JSON at path, plus path+".sha256" holding
+// the manifest's own digest in sha256sum format. It returns that digest.
+func (m *Manifest) Write(path string) (string, error) {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	b = append(b, '\n')
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	digest := hex.EncodeToString(sum[:])
+	line := fmt.Sprintf("%s  %s\n", digest, filepath.Base(path))
+	if err := os.WriteFile(path+".sha256", []byte(line), 0o644); err != nil {
+		return "", err
+	}
+	return digest, nil
+}
@@ -0,0 +1,58 @@
+package evidence
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	reportPath := filepath.Join(dir, "compliance_report.json")
+	require.NoError(t, os.WriteFile(reportPath, []byte("hello"), 0o644))
+
+	rep := report.ComplianceReport{
+		GeneratedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Hostname:    "host1",
+		Violations: []analyzer.Violation{
+			{Category: "user", Severity: analyzer.SeverityHigh},
+			{Category: "port", Severity: analyzer.SeverityMedium},
+			{Category: "user", Severity: analyzer.SeverityHigh},
+		},
+	}
+	m := New(rep)
+	require.NoError(t, m.Add("report", reportPath))
+	assert.Error(t, m.Add("baseline", filepath.Join(dir, "missing.json")))
+
+	manifestPath := filepath.Join(dir, "evidence_manifest.json")
+	digest, err := m.Write(manifestPath)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(manifestPath)
+	require.NoError(t, err)
+	sum := sha256.Sum256(b)
+	assert.Equal(t, hex.EncodeToString(sum[:]), digest)
+	side, err := os.ReadFile(manifestPath + ".sha256")
+	require.NoError(t, err)
+	assert.Equal(t, digest+"  evidence_manifest.json\n", string(side))
+
+	var got Manifest
+	require.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, ManifestVersion, got.Version)
+	assert.Equal(t, "host1", got.Hostname)
+	assert.Equal(t, 3, got.Violations)
+	assert.Equal(t, map[string]int{"high": 2, "medium": 1}, got.BySeverity)
+	require.Len(t, got.Artifacts, 1)
+	// sha256("hello")
+	assert.Equal(t, Artifact{Role: "report", Path: reportPath, Size: 5,
+		SHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"}, got.Artifacts[0])
+}
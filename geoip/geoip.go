@@ -0,0 +1,112 @@
+// Package geoip looks up the country and autonomous system of remote
+// addresses in local MaxMind DB (MMDB) files, so connections can be
+// checked against country and ASN rules without calling an online
+// service.
+//
+// No database ships with the agent because the common ones are licensed.
+// GeoLite2-Country/City and GeoLite2-ASN work, as do DB-IP and IPinfo
+// MMDB files that use the same field names. A single file with both
+// country and ASN fields can be given as either path.
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"compliance-agent/collector"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Info is what a lookup found; zero fields are unknown.
+type Info struct {
+	Country string
+	ASN     uint
+	ASOrg   string
+}
+
+// record is the union of the country and ASN database layouts.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+	ASN   uint   `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+// DB holds the open database files.
+type DB struct {
+	readers []*maxminddb.Reader
+}
+
+// Open opens the country and ASN databases; either path may be empty.
+func Open(countryPath, asnPath string) (*DB, error) {
+	db := &DB{}
+	for _, path := range []string{countryPath, asnPath} {
+		if path == "" {
+			continue
+		}
+		r, err := maxminddb.Open(path)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("geoip %s: %w", path, err)
+		}
+		db.readers = append(db.readers, r)
+	}
+	if len(db.readers) == 0 {
+		return nil, errors.New("geoip: no database configured")
+	}
+	return db, nil
+}
+
+// Close releases the database files.
+func (db *DB) Close() error {
+	var errs []error
+	for _, r := range db.readers {
+		errs = append(errs, r.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Lookup returns what the databases know about ip. Earlier databases win
+// when both have a field.
+func (db *DB) Lookup(ip net.IP) (Info, error) {
+	var info Info
+	for _, r := range db.readers {
+		var rec record
+		if err := r.Lookup(ip, &rec); err != nil {
+			return Info{}, err
+		}
+		if info.Country == "" {
+			info.Country = rec.Country.ISOCode
+		}
+		if info.Country == "" {
+			info.Country = rec.RegisteredCountry.ISOCode
+		}
+		if info.ASN == 0 {
+			info.ASN, info.ASOrg = rec.ASN, rec.ASOrg
+		}
+	}
+	return info, nil
+}
+
+// Enrich fills in Country, ASN and ASOrg on each connection. Addresses
+// the databases don't cover (private ranges) are left blank.
+func (db *DB) Enrich(conns []collector.Connection) error {
+	for i := range conns {
+		ip := net.ParseIP(conns[i].RemoteAddress)
+		if ip == nil {
+			continue
+		}
+		info, err := db.Lookup(ip)
+		if err != nil {
+			return err
+		}
+		conns[i].Country, conns[i].ASN, conns[i].ASOrg = info.Country, info.ASN, info.ASOrg
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package geoip
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"compliance-agent/collector"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeDB builds a small MMDB with one record per network.
+func writeDB(t *testing.T, dbType string, records map[string]mmdbtype.Map) string {
+	t.Helper()
+	tree, err := mmdbwriter.New(mmdbwriter.Options{DatabaseType: dbType, IncludeReservedNetworks: true})
+	require.NoError(t, err)
+	for cidr, rec := range records {
+		_, network, err := net.ParseCIDR(cidr)
+		require.NoError(t, err)
+		require.NoError(t, tree.Insert(network, rec))
+	}
+	path := filepath.Join(t.TempDir(), dbType+".mmdb")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = tree.WriteTo(f)
+	require.NoError(t, err)
+	return path
+}
+
+func TestEnrich(t *testing.T) {
+	country := writeDB(t, "GeoLite2-Country", map[string]mmdbtype.Map{
+		"203.0.113.0/24":  {"country": mmdbtype.Map{"iso_code": mmdbtype.String("KP")}},
+		"198.51.100.0/24": {"registered_country": mmdbtype.Map{"iso_code": mmdbtype.String("NL")}},
+	})
+	asn := writeDB(t, "GeoLite2-ASN", map[string]mmdbtype.Map{
+		"203.0.113.0/24": {
+			"autonomous_system_number":       mmdbtype.Uint32(64500),
+			"autonomous_system_organization": mmdbtype.String("Example Transit"),
+		},
+	})
+	db, err := Open(country, asn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	conns := []collector.Connection{
+		{RemoteAddress: "203.0.113.7"},
+		{RemoteAddress: "198.51.100.1"},
+		{RemoteAddress: "10.1.2.3"},
+	}
+	require.NoError(t, db.Enrich(conns))
+	assert.Equal(t, collector.Connection{RemoteAddress: "203.0.113.7", Country: "KP", ASN: 64500, ASOrg: "Example Transit"}, conns[0])
+	assert.Equal(t, "NL", conns[1].Country, "registered country is the fallback")
+	assert.Empty(t, conns[2].Country)
+}
+
+func TestOpen_NoDatabase(t *testing.T) {
+	_, err := Open("", "")
+	assert.Error(t, err)
+	_, err = Open(filepath.Join(t.TempDir(), "missing.mmdb"), "")
+	assert.Error(t, err)
+}
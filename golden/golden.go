@@ -0,0 +1,178 @@
+// Package golden checks a scan of a freshly built machine image against
+// the report of a known-good ("golden") build, so an image pipeline can
+// fail the build on a regression: a violation the golden image didn't
+// have, or configuration drift where nothing should change.
+package golden
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"compliance-agent/alerting"
+	"compliance-agent/analyzer"
+	"compliance-agent/compare"
+	"compliance-agent/report"
+)
+
+// DefaultGuard is the fact sections in which an added or changed fact is
+// a regression. Package and OS versions are left out: they move with
+// every patched build.
+const DefaultGuard = "user,port,sshd,firewall,disk_encryption,agent,sharing,proxy,cis"
+
+// Options tune what counts as a regression.
+type Options struct {
+	// FailOn is the least severe new violation that fails the image.
+	FailOn analyzer.Severity
+	// Guard lists the fact sections (see compare.Facts) where drift fails
+	// the image.
+	Guard []string
+	// Ignore lists fact keys, or path.Match patterns such as "port/68" or
+	// "user/packer*", left out of the check.
+	Ignore []string
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	// NewViolations are in the image but not the golden report.
+	NewViolations []analyzer.Violation `json:"new_violations"`
+	// Resolved are in the golden report but no longer in the image.
+	Resolved []analyzer.Violation `json:"resolved"`
+	// Drift is every fact that differs, the regressions first.
+	Drift       []Change `json:"drift"`
+	Regressions int      `json:"regressions"`
+
+	failOn analyzer.Severity
+}
+
+// Change is a fact that differs from the golden image. An empty Golden
+// or Current means the fact is absent there.
+type Change struct {
+	Key        string `json:"key"`
+	Golden     string `json:"golden,omitempty"`
+	Current    string `json:"current,omitempty"`
+	Regression bool   `json:"regression"`
+}
+
+// Failed reports whether the image regressed.
+func (r Result) Failed() bool { return r.Regressions > 0 }
+
+// ParseList splits a comma-separated flag value.
+func ParseList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Check compares the image's report with the golden one.
+func Check(golden, current report.ComplianceReport, opts Options) Result {
+	res := Result{NewViolations: []analyzer.Violation{}, Resolved: []analyzer.Violation{}, Drift: []Change{}, failOn: opts.FailOn}
+
+	// Both are fingerprinted under one name: the build host's differs
+	// from the golden one's by design.
+	added, resolved := alerting.Delta("image", golden.Violations, current.Violations)
+	for _, v := range added {
+		res.NewViolations = append(res.NewViolations, v)
+		if res.fails(v) {
+			res.Regressions++
+		}
+	}
+	res.Resolved = append(res.Resolved, resolved...)
+
+	guarded := map[string]bool{}
+	for _, s := range opts.Guard {
+		guarded[s] = true
+	}
+	before, after := compare.Facts(golden), compare.Facts(current)
+	keys := map[string]bool{}
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	for k := range keys {
+		g, c := before[k], after[k]
+		if g == c || ignored(k, opts.Ignore) {
+			continue
+		}
+		section, _, _ := strings.Cut(k, "/")
+		// Something going away (a user removed, a port closed) is not a
+		// regression; what must be present is the policy's to require.
+		ch := Change{Key: k, Golden: g, Current: c, Regression: guarded[section] && c != ""}
+		if ch.Regression {
+			res.Regressions++
+		}
+		res.Drift = append(res.Drift, ch)
+	}
+	sort.Slice(res.Drift, func(i, j int) bool {
+		a, b := res.Drift[i], res.Drift[j]
+		if a.Regression != b.Regression {
+			return a.Regression
+		}
+		return a.Key < b.Key
+	})
+	return res
+}
+
+func (r Result) fails(v analyzer.Violation) bool {
+	return r.failOn == "" || v.Severity.Rank() >= r.failOn.Rank()
+}
+
+func ignored(key string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, key); ok || p == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Text renders the result for a build log.
+func (r Result) Text() string {
+	var b strings.Builder
+	if r.Failed() {
+		fmt.Fprintf(&b, "FAIL: %d regression(s) from the golden image\n", r.Regressions)
+	} else {
+		b.WriteString("PASS: no regression from the golden image\n")
+	}
+	var other []string
+	for _, v := range r.NewViolations {
+		line := fmt.Sprintf("new violation  %-8s %s: %s", v.Severity, v.Category, v.Message)
+		if r.fails(v) {
+			fmt.Fprintf(&b, "  %s\n", line)
+		} else {
+			other = append(other, line)
+		}
+	}
+	for _, c := range r.Drift {
+		line := fmt.Sprintf("drift          %s: %s -> %s", c.Key, absent(c.Golden), absent(c.Current))
+		if c.Regression {
+			fmt.Fprintf(&b, "  %s\n", line)
+		} else {
+			other = append(other, line)
+		}
+	}
+	if len(other) > 0 {
+		b.WriteString("Also changed (not a regression):\n")
+		for _, line := range other {
+			fmt.Fprintf(&b, "  %s\n", line)
+		}
+	}
+	if n := len(r.Resolved); n > 0 {
+		fmt.Fprintf(&b, "Resolved since the golden image: %d violation(s)\n", n)
+	}
+	return b.String()
+}
+
+func absent(v string) string {
+	if v == "" {
+		return "(absent)"
+	}
+	return v
+}
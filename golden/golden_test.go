@@ -0,0 +1,69 @@
+package golden
+
+import (
+	"testing"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/collector"
+	"compliance-agent/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func image(hostname string) report.ComplianceReport {
+	return report.ComplianceReport{
+		Hostname:  hostname,
+		Users:     []collector.User{{Username: "root", Shell: "/bin/bash"}, {Username: "packer-1234", Shell: "/bin/sh"}},
+		OpenPorts: []int{22},
+		Packages:  []collector.Package{{Name: "openssl", Version: "3.0.2"}},
+		SSHD:      &collector.SSHDConfig{Settings: map[string]string{"PermitRootLogin": "no"}},
+		Violations: []analyzer.Violation{
+			{Category: "package", Severity: analyzer.SeverityLow, Message: "package missing: auditd"},
+		},
+	}
+}
+
+func TestCheck(t *testing.T) {
+	golden := image("golden-build")
+	opts := Options{FailOn: analyzer.SeverityMedium, Guard: ParseList(DefaultGuard), Ignore: []string{"user/packer-*"}}
+
+	// A rebuild with only patched packages and a different build user
+	// passes.
+	cur := image("build-42")
+	cur.Users[1].Username = "packer-5678"
+	cur.Packages[0].Version = "3.0.13"
+	res := Check(golden, cur, opts)
+	assert.False(t, res.Failed())
+	assert.Empty(t, res.NewViolations)
+	assert.Equal(t, []Change{{Key: "package/openssl", Golden: "3.0.2", Current: "3.0.13"}}, res.Drift)
+	assert.Contains(t, res.Text(), "PASS")
+
+	// An opened port, a loosened sshd setting and a new high violation
+	// fail it; a new low violation and a closed port don't.
+	cur = image("build-43")
+	cur.OpenPorts = []int{8080}
+	cur.SSHD.Settings["PermitRootLogin"] = "yes"
+	cur.Violations = []analyzer.Violation{
+		{Category: "ssh_root_login", Severity: analyzer.SeverityHigh, Message: "PermitRootLogin is yes"},
+		{Category: "screen_lock", Severity: analyzer.SeverityLow, Message: "no screen lock"},
+	}
+	res = Check(golden, cur, opts)
+	require.True(t, res.Failed())
+	assert.Equal(t, 3, res.Regressions)
+	assert.Len(t, res.NewViolations, 2)
+	assert.Len(t, res.Resolved, 1)
+	assert.Equal(t, []Change{
+		{Key: "port/8080", Current: "open", Regression: true},
+		{Key: "sshd/permitrootlogin", Golden: "no", Current: "yes", Regression: true},
+		{Key: "port/22", Golden: "open"},
+	}, res.Drift)
+
+	text := res.Text()
+	assert.Contains(t, text, "FAIL: 3 regression(s)")
+	assert.Contains(t, text, "  drift          sshd/permitrootlogin: no -> yes\n")
+	assert.Contains(t, text, "Also changed (not a regression):\n  new violation  low      screen_lock: no screen lock\n  drift          port/22: open -> (absent)\n")
+	assert.Contains(t, text, "Resolved since the golden image: 1 violation(s)")
+
+	// Without FailOn every new violation counts.
+	assert.Equal(t, 4, Check(golden, cur, Options{Guard: opts.Guard}).Regressions)
+}
@@ -0,0 +1,77 @@
+// Package guard contains panics raised by individual agent subsystems
+// (collectors, analyzer passes, notifiers) so one bad parser on an unusual
+// host is recorded as a structured error instead of taking down the whole
+// scan or daemon.
+package guard
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"compliance-agent/errcode"
+	"compliance-agent/logging"
+	"compliance-agent/report"
+)
+
+// ErrPanic is wrapped by the error Run returns when fn panicked. Callers
+// use errors.Is(err, ErrPanic) to tell a contained crash from an ordinary
+// failure they may still want to act on.
+var ErrPanic = errors.New("subsystem panicked")
+
+// Recorder runs subsystem calls with panic recovery and keeps the
+// resulting errors for the report. It is safe for concurrent use.
+type Recorder struct {
+	mu   sync.Mutex
+	errs []report.RunError
+}
+
+// Run calls fn, recovering any panic. A recovered panic is recorded with
+// its stack and returned as an error wrapping ErrPanic; ordinary errors
+// from fn are returned untouched and not recorded.
+func (r *Recorder) Run(stage, subsystem string, fn func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			logging.Component(stage).Error("subsystem panicked", "subsystem", subsystem, "code", errcode.Internal, "panic", p)
+			r.add(report.RunError{
+				Stage:     stage,
+				Subsystem: subsystem,
+				Code:      string(errcode.Internal),
+				Message:   fmt.Sprint(p),
+				Panic:     true,
+				Stack:     string(debug.Stack()),
+			})
+			err = fmt.Errorf("%s/%s: %w: %v", stage, subsystem, ErrPanic, p)
+		}
+	}()
+	return fn()
+}
+
+// Record stores a non-panic error against a subsystem, for callers that
+// decide an ordinary failure belongs in the report too. Its code is
+// errcode.Of(err), except that every failure to deliver (stage "notify"
+// or "export") is a notifier failure, whatever the cause.
+func (r *Recorder) Record(stage, subsystem string, err error) {
+	if err == nil {
+		return
+	}
+	code := errcode.Of(err)
+	if stage == "notify" || stage == "export" {
+		code = errcode.NotifierFailure
+	}
+	r.add(report.RunError{Stage: stage, Subsystem: subsystem, Code: string(code), Message: err.Error()})
+}
+
+// Errors returns a copy of everything recorded so far.
+func (r *Recorder) Errors() []report.RunError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]report.RunError(nil), r.errs...)
+}
+
+func (r *Recorder) add(e report.RunError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, e)
+}
@@ -0,0 +1,62 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder_RecoversPanic(t *testing.T) {
+	var r Recorder
+	err := r.Run("collect", "users", func() error {
+		var m map[string]int
+		m["boom"] = 1
+		return nil
+	})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrPanic))
+
+	errs := r.Errors()
+	require.Len(t, errs, 1)
+	assert.Equal(t, "collect", errs[0].Stage)
+	assert.Equal(t, "users", errs[0].Subsystem)
+	assert.True(t, errs[0].Panic)
+	assert.Equal(t, "internal", errs[0].Code)
+	assert.NotEmpty(t, errs[0].Stack)
+}
+
+func TestRecorder_PassesThroughOrdinaryErrors(t *testing.T) {
+	var r Recorder
+	want := errors.New("socket closed")
+	err := r.Run("collect", "ports", func() error { return want })
+	assert.Equal(t, want, err)
+	assert.Empty(t, r.Errors())
+}
+
+func TestRecorder_Record(t *testing.T) {
+	var r Recorder
+	r.Record("notify", "slack", nil)
+	r.Record("notify", "slack", errors.New("status 500"))
+	errs := r.Errors()
+	require.Len(t, errs, 1)
+	assert.False(t, errs[0].Panic)
+	assert.Equal(t, "status 500", errs[0].Message)
+	assert.Equal(t, "notifier-failure", errs[0].Code)
+}
+
+func TestRecorder_RecordCodes(t *testing.T) {
+	var r Recorder
+	r.Record("collect", "sshd", &fs.PathError{Op: "open", Path: "/etc/ssh/sshd_config", Err: fs.ErrPermission})
+	r.Record("collect", "packages", &exec.Error{Name: "dpkg-query", Err: exec.ErrNotFound})
+	r.Record("export", "splunk", context.DeadlineExceeded)
+	var codes []string
+	for _, e := range r.Errors() {
+		codes = append(codes, e.Code)
+	}
+	assert.Equal(t, []string{"permission-denied", "collector-unavailable", "notifier-failure"}, codes)
+}
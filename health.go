@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"compliance-agent/collector"
+	"compliance-agent/report"
+)
+
+// agentHealth keeps what a report's health section says about the agent
+// between scans. Its methods are no-ops on nil, for the commands that
+// deliver a report outside a scanner.
+type agentHealth struct {
+	started time.Time
+	// failedScans counts scans since the last report that didn't make one.
+	failedScans int
+	// backlog counts reports the fleet server hasn't received since the
+	// last successful upload.
+	backlog    int
+	deliveries map[[2]string]*report.DeliveryStats
+	// policyHashes caches each policy file's hash as first read, which
+	// is when it was loaded: a file edited since isn't the one in force.
+	policyHashes map[string]string
+}
+
+func newAgentHealth(now time.Time) *agentHealth {
+	return &agentHealth{
+		started:      now,
+		deliveries:   map[[2]string]*report.DeliveryStats{},
+		policyHashes: map[string]string{},
+	}
+}
+
+// delivered counts one attempt to deliver to a destination.
+func (h *agentHealth) delivered(stage, dest string, err error) {
+	if h == nil {
+		return
+	}
+	d := h.deliveries[[2]string{stage, dest}]
+	if d == nil {
+		d = &report.DeliveryStats{Stage: stage, Destination: dest}
+		h.deliveries[[2]string{stage, dest}] = d
+	}
+	now := time.Now().UTC()
+	if err != nil {
+		d.Failed++
+		d.LastFailure = &now
+		d.LastError = err.Error()
+	} else {
+		d.Sent++
+		d.LastSuccess = &now
+	}
+}
+
+// fillHealth completes the health section collect started, and starts
+// counting failed scans afresh for the next report.
+func (s *scanner) fillHealth(h *report.AgentHealth) {
+	now := time.Now()
+	h.StartedAt = s.health.started.UTC()
+	h.UptimeSeconds = int64(now.Sub(s.health.started).Seconds())
+	h.FailedScans = s.health.failedScans
+	h.UploadBacklog = s.health.backlog
+	s.health.failedScans = 0
+
+	if b, err := json.Marshal(s.cfg); err == nil {
+		h.ConfigSHA256 = sha256Hex(b)
+	}
+	switch {
+	case s.remotePolicy != nil && s.policySource != nil:
+		h.PolicySource, h.PolicySHA256 = "url", sha256Hex(s.remotePolicy)
+	case s.remotePolicy != nil:
+		h.PolicySource, h.PolicySHA256 = "central", sha256Hex(s.remotePolicy)
+	case s.policyPath != "":
+		h.PolicySource = "file"
+		sum, ok := s.health.policyHashes[s.policyPath]
+		if !ok {
+			if b, err := os.ReadFile(s.policyPath); err == nil {
+				sum = sha256Hex(b)
+			}
+			s.health.policyHashes[s.policyPath] = sum
+		}
+		h.PolicySHA256 = sum
+	default:
+		h.PolicySource = "builtin"
+	}
+
+	h.Deliveries = make([]report.DeliveryStats, 0, len(s.health.deliveries))
+	for _, d := range s.health.deliveries {
+		h.Deliveries = append(h.Deliveries, *d)
+	}
+	sort.Slice(h.Deliveries, func(i, j int) bool {
+		a, b := h.Deliveries[i], h.Deliveries[j]
+		if a.Stage != b.Stage {
+			return a.Stage < b.Stage
+		}
+		return a.Destination < b.Destination
+	})
+}
+
+// collectorKind names the inventory backend c is.
+func collectorKind(c collector.Collector) string {
+	switch c.(type) {
+	case *collector.OSQueryCollector:
+		return "osquery"
+	case *collector.FleetCollector:
+		return "fleet"
+	case *collector.FallbackCollector:
+		return "native"
+	case *collector.RootFS:
+		return "rootfs"
+	}
+	return "other"
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
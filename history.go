@@ -0,0 +1,278 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"compliance-agent/config"
+	"compliance-agent/errcode"
+	"compliance-agent/report"
+	"compliance-agent/storage"
+)
+
+const historyUsage = `Usage:
+  %[1]s history [ls] [-host name] [-at time] [-limit n] [-json]   list past runs, newest first
+  %[1]s history show [-host name] [-at time | -id n] [-o file]    print the report in force at a time
+
+-at takes RFC 3339, "2006-01-02 15:04[:05]", a date (the end of that day,
+local time) or a duration ago such as 36h or 7d. With -fleet, runs come
+from the fleet server's database (server.db_path) and -host, an agent ID
+or hostname, is required.
+`
+
+// cmdHistory implements `compliance-agent history`: past runs from the
+// report history database with violation counts by severity, and the
+// report a host had at a point in time.
+func cmdHistory(args []string) {
+	sub := "ls"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		sub, args = args[0], args[1:]
+	}
+	switch sub {
+	case "ls", "list":
+		historyList(args)
+	case "show":
+		historyShow(args)
+	default:
+		fmt.Fprintf(os.Stderr, historyUsage, os.Args[0])
+		usageError("history: unknown subcommand %q", sub)
+	}
+}
+
+// historyFlags are the flags history's subcommands share.
+type historyFlags struct {
+	configPath, dbPath, host, at *string
+	fleet                        *bool
+}
+
+func addHistoryFlags(fs *flag.FlagSet) historyFlags {
+	return historyFlags{
+		configPath: fs.String("config", "", "Path to YAML config (optional)"),
+		dbPath:     fs.String("db", "", "Database to read (overrides history.path, or server.db_path with -fleet)"),
+		host:       fs.String("host", "", "Only this host's runs (with -fleet, an agent ID or hostname)"),
+		at:         fs.String("at", "", "Only runs from at or before this time"),
+		fleet:      fs.Bool("fleet", false, "Read the fleet server's database instead of the local history"),
+	}
+}
+
+// historySource is the local report history or the fleet database, each
+// holding runs and the reports they stored.
+type historySource struct {
+	runs   func(until time.Time, limit int) ([]storage.Run, error)
+	report func(id int64) (report.ComplianceReport, error)
+	close  func() error
+}
+
+// open opens the database the flags name, resolving -host against the
+// fleet's agents.
+func (f historyFlags) open() historySource {
+	cfg := loadConfig(*f.configPath)
+	if *f.fleet {
+		return openFleetHistory(cfg, *f.dbPath, *f.host)
+	}
+	path := cfg.History.Path
+	if *f.dbPath != "" {
+		path = *f.dbPath
+	}
+	if path == "" {
+		usageError("report history is disabled (history.path is empty)")
+	}
+	if _, err := os.Stat(path); err != nil {
+		fatal(errcode.Errorf(errcode.CollectorUnavailable, "no report history at %s: %w", path, err), "history")
+	}
+	store, err := storage.Open(path)
+	if err != nil {
+		fatal(err, "history")
+	}
+	host := *f.host
+	return historySource{
+		runs: func(until time.Time, limit int) ([]storage.Run, error) {
+			return store.RunsUntil(host, until, limit)
+		},
+		report: store.Report,
+		close:  store.Close,
+	}
+}
+
+func openFleetHistory(cfg config.Config, dbPath, host string) historySource {
+	if host == "" {
+		usageError("-fleet needs -host (an agent ID or hostname)")
+	}
+	path := cfg.Server.DBPath
+	if dbPath != "" {
+		path = dbPath
+	}
+	if _, err := os.Stat(path); err != nil {
+		fatal(errcode.Errorf(errcode.CollectorUnavailable, "no fleet database at %s: %w", path, err), "history")
+	}
+	store, err := storage.OpenFleet(path)
+	if err != nil {
+		fatal(err, "history")
+	}
+	agentID := findAgent(store, host, path).ID
+	return historySource{
+		runs: func(until time.Time, limit int) ([]storage.Run, error) {
+			return store.AgentRunsUntil(agentID, until, limit)
+		},
+		report: func(id int64) (report.ComplianceReport, error) {
+			rep, owner, ok, err := store.Report(id)
+			if err == nil && (!ok || owner != agentID) {
+				err = fmt.Errorf("no report with id %d for %s", id, host)
+			}
+			return rep, err
+		},
+		close: store.Close,
+	}
+}
+
+// findAgent resolves host, an agent ID or hostname, against the fleet
+// database at path, exiting when it names no agent or several.
+func findAgent(store *storage.FleetStore, host, path string) storage.Agent {
+	agents, err := store.Agents()
+	if err != nil {
+		fatal(err, "read fleet")
+	}
+	var matches []storage.Agent
+	for _, a := range agents {
+		if a.ID == host {
+			matches = []storage.Agent{a}
+			break
+		}
+		if strings.EqualFold(a.Hostname, host) {
+			matches = append(matches, a)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		usageError("no agent %q in %s", host, path)
+	case 1:
+	default:
+		var ids []string
+		for _, a := range matches {
+			ids = append(ids, a.ID)
+		}
+		usageError("%d agents are named %s; pick one by ID: %s", len(matches), host, strings.Join(ids, ", "))
+	}
+	return matches[0]
+}
+
+func historyList(args []string) {
+	fs := flag.NewFlagSet("history ls", flag.ContinueOnError)
+	hf := addHistoryFlags(fs)
+	limit := fs.Int("limit", 20, "Number of runs to show (0 for all)")
+	asJSON := fs.Bool("json", false, "Print runs as JSON")
+	parseFlags(fs, args)
+	until := historyTime(*hf.at)
+
+	src := hf.open()
+	defer src.close()
+	runs, err := src.runs(until, *limit)
+	if err != nil {
+		fatal(err, "read history")
+	}
+	if *asJSON {
+		dumpJSON(runs)
+		return
+	}
+	if len(runs) == 0 {
+		if until.IsZero() && *hf.host == "" {
+			fmt.Println("No runs recorded yet.")
+		} else {
+			fmt.Println("No matching runs.")
+		}
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tGENERATED\tHOST\tVIOLATIONS\tCRITICAL\tHIGH\tMEDIUM\tLOW\tINFO\tERRORS")
+	for _, r := range runs {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n",
+			r.ID, r.GeneratedAt.Local().Format("2006-01-02 15:04:05"), r.Hostname, r.Violations,
+			r.BySeverity["critical"], r.BySeverity["high"], r.BySeverity["medium"],
+			r.BySeverity["low"], r.BySeverity["info"], r.Errors)
+	}
+	w.Flush()
+}
+
+func historyShow(args []string) {
+	fs := flag.NewFlagSet("history show", flag.ContinueOnError)
+	hf := addHistoryFlags(fs)
+	id := fs.Int64("id", 0, "Show this run (from history ls) instead of the one in force at -at")
+	format := fs.String("output-format", "json", "Output format: json, html, junit, markdown or pdf")
+	out := fs.String("o", "-", "Output file (- for stdout)")
+	parseFlags(fs, args)
+	if *id != 0 && *hf.at != "" {
+		usageError("-id and -at are exclusive")
+	}
+	checkFormat(*format)
+	until := historyTime(*hf.at)
+
+	src := hf.open()
+	defer src.close()
+	runID := *id
+	if runID == 0 {
+		runs, err := src.runs(until, 1)
+		if err != nil {
+			fatal(err, "read history")
+		}
+		if len(runs) == 0 {
+			if until.IsZero() {
+				usageError("no runs recorded yet")
+			}
+			usageError("no run from at or before %s", until.Local().Format(time.RFC3339))
+		}
+		runID = runs[0].ID
+	}
+	rep, err := src.report(runID)
+	if err != nil {
+		fatal(err, "read history")
+	}
+	if err := writeReport(&rep, *format, *out); err != nil {
+		fatal(err, "write report")
+	}
+	fmt.Fprintf(os.Stderr, "Run %d: %s at %s, %d violation(s)\n",
+		runID, rep.Hostname, rep.GeneratedAt.Local().Format("2006-01-02 15:04:05"), len(rep.Violations))
+}
+
+// historyTime parses -at, exiting on a bad value; empty is the zero time,
+// meaning now.
+func historyTime(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	t, err := parseWhen(s, time.Now())
+	if err != nil {
+		fatal(errcode.New(errcode.Usage, err), "-at")
+	}
+	return t
+}
+
+// parseWhen parses a point in time given as RFC 3339, a local date and
+// time, a bare date (its last instant, so the day's runs count) or a
+// duration before now such as 36h or 7d.
+func parseWhen(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("want RFC 3339, YYYY-MM-DD [HH:MM[:SS]] or a duration ago like 36h or 7d, got %q", s)
+}
@@ -0,0 +1,232 @@
+// Package hygiene measures how each of a policy's checks behaves across
+// the fleet, from every host's newest report, to guide policy upkeep at
+// scale: checks that fail almost everywhere are probably mis-tuned,
+// checks that never fail may be dead weight, and checks that are often
+// not applicable are being suppressed more than they run.
+package hygiene
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/report"
+	"compliance-agent/storage"
+)
+
+// DefaultNoisyShare is the share of hosts failing a check above which
+// it is listed as noisy.
+const DefaultNoisyShare = 0.9
+
+// DefaultTop is how many of the most suppressed checks are listed.
+const DefaultTop = 10
+
+// Options tune the analysis.
+type Options struct {
+	// NoisyShare is the least share of the hosts a check ran on that
+	// must fail it for it to be noisy; 0 means DefaultNoisyShare.
+	NoisyShare float64
+	// Top caps MostSuppressed; 0 means DefaultTop.
+	Top int
+}
+
+// Rule is one check's record across the fleet.
+type Rule struct {
+	Check string `json:"check"`
+	Title string `json:"title,omitempty"`
+	// Hosts is how many hosts the check ran on, and Failing how many of
+	// those failed it, with Violations between them.
+	Hosts      int     `json:"hosts"`
+	Failing    int     `json:"failing"`
+	Share      float64 `json:"share"`
+	Violations int     `json:"violations"`
+	// Suppressed is how many hosts listed the check as not applicable
+	// (applies_to, or a live-only check in a --root or image scan), and
+	// SuppressedShare their share of all the hosts.
+	Suppressed      int     `json:"suppressed"`
+	SuppressedShare float64 `json:"suppressed_share"`
+}
+
+// Analysis is the policy's checks across the fleet.
+type Analysis struct {
+	// Hosts is how many hosts have a report.
+	Hosts      int     `json:"hosts"`
+	NoisyShare float64 `json:"noisy_share"`
+	// Rules are every check, in policy order.
+	Rules []Rule `json:"rules"`
+	// Noisy fail on at least NoisyShare of the hosts they ran on, the
+	// noisiest first.
+	Noisy []Rule `json:"noisy"`
+	// Silent ran on some host and failed on none.
+	Silent []Rule `json:"silent"`
+	// MostSuppressed are the checks most often not applicable, most
+	// suppressed first.
+	MostSuppressed []Rule `json:"most_suppressed"`
+
+	checks []analyzer.Check
+	top    int
+}
+
+// NewAnalysis starts an analysis of policies' checks.
+func NewAnalysis(policies analyzer.Policies, opts Options) *Analysis {
+	if opts.NoisyShare <= 0 {
+		opts.NoisyShare = DefaultNoisyShare
+	}
+	if opts.Top <= 0 {
+		opts.Top = DefaultTop
+	}
+	a := &Analysis{NoisyShare: opts.NoisyShare, checks: policies.Checks(), top: opts.Top}
+	for _, c := range a.checks {
+		a.Rules = append(a.Rules, Rule{Check: c.Name, Title: c.Title})
+	}
+	return a
+}
+
+// Add counts a host's newest report.
+func (a *Analysis) Add(rep report.ComplianceReport) {
+	a.Hosts++
+	for i, c := range a.checks {
+		r := &a.Rules[i]
+		if notApplicable(c.Name, rep.NotApplicable) {
+			r.Suppressed++
+			continue
+		}
+		r.Hosts++
+		n := 0
+		for _, v := range rep.Violations {
+			if c.Matches(v) {
+				n++
+			}
+		}
+		if n > 0 {
+			r.Failing++
+			r.Violations += n
+		}
+	}
+}
+
+// notApplicable reports whether the report lists check as not
+// applicable; a profile covers each of its controls.
+func notApplicable(check string, na []analyzer.NotApplicable) bool {
+	for _, n := range na {
+		switch n.Kind {
+		case "rule":
+			if check == "rules/"+n.Name {
+				return true
+			}
+		case "script":
+			if check == "scripts/"+n.Name {
+				return true
+			}
+		case "profile":
+			if strings.HasPrefix(check, "profiles/"+n.Name+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Finish works out the shares and the lists.
+func (a *Analysis) Finish() Analysis {
+	out := *a
+	out.Rules = append([]Rule{}, a.Rules...)
+	out.Noisy, out.Silent, out.MostSuppressed = []Rule{}, []Rule{}, []Rule{}
+	for i := range out.Rules {
+		r := &out.Rules[i]
+		if r.Hosts > 0 {
+			r.Share = float64(r.Failing) / float64(r.Hosts)
+		}
+		if out.Hosts > 0 {
+			r.SuppressedShare = float64(r.Suppressed) / float64(out.Hosts)
+		}
+		switch {
+		case r.Hosts == 0:
+		case r.Share >= out.NoisyShare:
+			out.Noisy = append(out.Noisy, *r)
+		case r.Failing == 0:
+			out.Silent = append(out.Silent, *r)
+		}
+		if r.Suppressed > 0 {
+			out.MostSuppressed = append(out.MostSuppressed, *r)
+		}
+	}
+	sort.SliceStable(out.Noisy, func(i, j int) bool { return out.Noisy[i].Share > out.Noisy[j].Share })
+	sort.SliceStable(out.MostSuppressed, func(i, j int) bool {
+		return out.MostSuppressed[i].SuppressedShare > out.MostSuppressed[j].SuppressedShare
+	})
+	if len(out.MostSuppressed) > a.top {
+		out.MostSuppressed = out.MostSuppressed[:a.top]
+	}
+	return out
+}
+
+// ForFleet analyzes policies' checks over every host's newest report on
+// the fleet server.
+func ForFleet(store *storage.FleetStore, policies analyzer.Policies, opts Options) (Analysis, error) {
+	a := NewAnalysis(policies, opts)
+	agents, err := store.Agents()
+	if err != nil {
+		return Analysis{}, err
+	}
+	for _, ag := range agents {
+		if ag.Latest == nil {
+			continue
+		}
+		rep, _, ok, err := store.Report(ag.Latest.ID)
+		if err != nil {
+			return Analysis{}, err
+		}
+		if ok {
+			a.Add(rep)
+		}
+	}
+	return a.Finish(), nil
+}
+
+var markdownTemplate = template.Must(template.New("hygiene").Funcs(template.FuncMap{
+	"pct": func(f float64) string { return strconv.FormatFloat(math.Round(f*1000)/10, 'f', -1, 64) + "%" },
+}).Parse(`# Rule effectiveness
+
+{{.Hosts}} host(s) with a report, {{len .Rules}} check(s) in the policy.
+
+## Noisy: failing on {{pct .NoisyShare}} of hosts or more
+{{if .Noisy}}
+Probably mis-tuned: a check that fails nearly everywhere is an exception
+list or threshold away from being useful.
+
+| Check | Failing | Hosts | Share | Violations |
+|---|---|---|---|---|
+{{range .Noisy}}| {{.Check}} | {{.Failing}} | {{.Hosts}} | {{pct .Share}} | {{.Violations}} |
+{{end}}{{else}}
+No check fails on {{pct .NoisyShare}} of hosts.
+{{end}}
+## Silent: never failing
+{{if .Silent}}
+| Check | Hosts |
+|---|---|
+{{range .Silent}}| {{.Check}} | {{.Hosts}} |
+{{end}}{{else}}
+Every check that ran failed somewhere.
+{{end}}
+## Most suppressed: not applicable
+{{if .MostSuppressed}}
+| Check | Suppressed | Share of hosts |
+|---|---|---|
+{{range .MostSuppressed}}| {{.Check}} | {{.Suppressed}} | {{pct .SuppressedShare}} |
+{{end}}{{else}}
+No check was suppressed.
+{{end}}`))
+
+// Markdown renders the analysis as Markdown.
+func (a Analysis) Markdown() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := markdownTemplate.Execute(&buf, a); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,89 @@
+package hygiene
+
+import (
+	"fmt"
+	"testing"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalysis(t *testing.T) {
+	policies, err := analyzer.ParsePolicies([]byte(`
+allowed_users: [root]
+require_firewall_enabled: true
+rules:
+  - name: no-telnet
+    target: process
+    expr: process.name == "telnetd"
+  - name: mac-only
+    target: host
+    expr: "false"
+    applies_to: {platforms: [darwin]}
+`))
+	require.NoError(t, err)
+	a := NewAnalysis(policies, Options{Top: 1})
+	for i := 0; i < 10; i++ {
+		rep := report.ComplianceReport{Hostname: fmt.Sprintf("web-%d", i), Violations: []analyzer.Violation{
+			{Category: "user", Message: "unexpected user present: deploy"},
+			{Category: "user", Message: "unexpected user present: backup"},
+		}}
+		if i < 3 {
+			rep.Violations = append(rep.Violations, analyzer.Violation{Category: "no-telnet", Message: "telnetd"})
+		}
+		if i > 0 {
+			rep.NotApplicable = []analyzer.NotApplicable{{Kind: "rule", Name: "mac-only"}}
+		}
+		if i > 5 {
+			rep.NotApplicable = append(rep.NotApplicable, analyzer.NotApplicable{Kind: "rule", Name: "no-telnet"})
+		}
+		a.Add(rep)
+	}
+	res := a.Finish()
+
+	assert.Equal(t, 10, res.Hosts)
+	require.Len(t, res.Noisy, 1)
+	assert.Equal(t, Rule{Check: "allowed_users", Title: "only allowed users exist", Hosts: 10, Failing: 10, Share: 1, Violations: 20}, res.Noisy[0])
+
+	var silent []string
+	for _, r := range res.Silent {
+		silent = append(silent, r.Check)
+	}
+	assert.Equal(t, []string{"allowed_ports", "require_firewall_enabled", "rules/mac-only"}, silent)
+
+	require.Len(t, res.MostSuppressed, 1, "capped at Top")
+	assert.Equal(t, "rules/mac-only", res.MostSuppressed[0].Check)
+	assert.InDelta(t, 0.9, res.MostSuppressed[0].SuppressedShare, 1e-9)
+
+	telnet := res.Rules[3]
+	assert.Equal(t, "rules/no-telnet", telnet.Check)
+	assert.Equal(t, 6, telnet.Hosts)
+	assert.Equal(t, 3, telnet.Failing)
+	assert.InDelta(t, 0.5, telnet.Share, 1e-9)
+	assert.Equal(t, 4, telnet.Suppressed)
+
+	md, err := res.Markdown()
+	require.NoError(t, err)
+	assert.Contains(t, string(md), "| allowed_users | 10 | 10 | 100% | 20 |")
+	assert.Contains(t, string(md), "| rules/mac-only | 9 | 90% |")
+}
+
+func TestNotApplicable(t *testing.T) {
+	na := []analyzer.NotApplicable{{Kind: "profile", Name: "cis-ubuntu-22.04"}, {Kind: "script", Name: "umask"}}
+	assert.True(t, notApplicable("profiles/cis-ubuntu-22.04/5.2.7", na))
+	assert.False(t, notApplicable("profiles/cis-ubuntu-22.04-extra/1.1", na))
+	assert.True(t, notApplicable("scripts/umask", na))
+	assert.False(t, notApplicable("rules/umask", na))
+}
+
+func TestAnalysis_Empty(t *testing.T) {
+	res := NewAnalysis(analyzer.DefaultPolicies(), Options{}).Finish()
+	assert.Equal(t, DefaultNoisyShare, res.NoisyShare)
+	assert.Empty(t, res.Noisy)
+	assert.Empty(t, res.Silent, "no check ran")
+	md, err := res.Markdown()
+	require.NoError(t, err)
+	assert.Contains(t, string(md), "No check fails on 90% of hosts.")
+}
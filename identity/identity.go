@@ -0,0 +1,106 @@
+// Package identity keeps the agent's stable ID: a random UUID minted on
+// the first scan and saved to disk, reported with the machine's hardware
+// identifiers so that reports from one machine can be tied together
+// across hostname changes and re-images.
+package identity
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"compliance-agent/collector"
+	"compliance-agent/logging"
+	"compliance-agent/report"
+)
+
+// saved is the identity file. HardwareUUID is the machine the ID was
+// minted on.
+type saved struct {
+	AgentID         string    `json:"agent_id"`
+	Since           time.Time `json:"since"`
+	PreviousAgentID string    `json:"previous_agent_id,omitempty"`
+	HardwareUUID    string    `json:"hardware_uuid,omitempty"`
+}
+
+// Resolve returns the identity of a report from the machine hw (nil if
+// unknown), reading the agent ID from path. A missing file is created
+// with a new ID. So is one saved on other hardware: the disk was cloned
+// or moved, and two machines must not share an ID.
+func Resolve(path string, hw *collector.HardwareInfo, now time.Time) (report.Identity, error) {
+	var hwUUID string
+	if hw != nil {
+		hwUUID = hw.UUID
+	}
+
+	var cur saved
+	b, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(b, &cur); err != nil || cur.AgentID == "" {
+			return report.Identity{}, fmt.Errorf("identity %s is corrupt; delete it to mint a new agent ID", path)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return report.Identity{}, fmt.Errorf("identity: %w", err)
+	}
+
+	dirty := false
+	switch {
+	case cur.AgentID == "":
+		cur, dirty = saved{Since: now.UTC(), HardwareUUID: hwUUID}, true
+		if cur.AgentID, err = newUUID(); err != nil {
+			return report.Identity{}, err
+		}
+	case hwUUID != "" && cur.HardwareUUID != "" && hwUUID != cur.HardwareUUID:
+		prev := cur.AgentID
+		cur, dirty = saved{Since: now.UTC(), HardwareUUID: hwUUID, PreviousAgentID: prev}, true
+		if cur.AgentID, err = newUUID(); err != nil {
+			return report.Identity{}, err
+		}
+		logging.Component("identity").Warn("agent ID was minted on other hardware, minted a new one", "previous_agent_id", prev, "agent_id", cur.AgentID)
+	case hwUUID != "" && cur.HardwareUUID == "":
+		// First scan that could read the hardware UUID, e.g. the first
+		// as root.
+		cur.HardwareUUID, dirty = hwUUID, true
+	}
+	if dirty {
+		if err := write(path, cur); err != nil {
+			return report.Identity{}, err
+		}
+	}
+	return report.Identity{
+		AgentID:         cur.AgentID,
+		Since:           cur.Since,
+		PreviousAgentID: cur.PreviousAgentID,
+		Hardware:        hw,
+	}, nil
+}
+
+func write(path string, s saved) error {
+	b, _ := json.MarshalIndent(s, "", "  ")
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("identity dir: %w", err)
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("identity: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// newUUID is a random (version 4) UUID.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
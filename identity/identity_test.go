@@ -0,0 +1,59 @@
+package identity
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var uuidV4 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestResolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "agent_identity.json")
+	first := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// Unprivileged first scan: no hardware UUID yet.
+	id, err := Resolve(path, nil, first)
+	require.NoError(t, err)
+	assert.Regexp(t, uuidV4, id.AgentID)
+	assert.Equal(t, first, id.Since)
+	assert.Nil(t, id.Hardware)
+
+	hw := &collector.HardwareInfo{UUID: "4c4c4544-0042-3510-8052-b4c04f333232", Serial: "ABC123"}
+	again, err := Resolve(path, hw, first.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, id.AgentID, again.AgentID, "stable across scans")
+	assert.Equal(t, first, again.Since)
+	assert.Equal(t, hw, again.Hardware)
+	assert.Contains(t, readFile(t, path), hw.UUID, "hardware UUID learned")
+
+	// The same disk on other hardware gets a new ID.
+	other := &collector.HardwareInfo{UUID: "564d8a3e-0000-0000-0000-000000000001"}
+	clone, err := Resolve(path, other, first.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.NotEqual(t, id.AgentID, clone.AgentID)
+	assert.Equal(t, id.AgentID, clone.PreviousAgentID)
+	assert.Equal(t, first.Add(2*time.Hour), clone.Since)
+
+	// A scan that can't read the hardware keeps the ID.
+	same, err := Resolve(path, nil, first.Add(3*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, clone.AgentID, same.AgentID)
+
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o644))
+	_, err = Resolve(path, hw, first)
+	assert.ErrorContains(t, err, "corrupt")
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(b)
+}
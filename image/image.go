@@ -0,0 +1,387 @@
+// Package image unpacks a container image into a root filesystem the
+// collectors can read: a directory already holding one, a `docker save`
+// or OCI archive, or an image reference exported through the local
+// Docker or Podman engine.
+package image
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"compliance-agent/collector"
+)
+
+// Info describes the image, from its config where there is one.
+type Info struct {
+	// Ref is what was scanned: a reference, archive or directory.
+	Ref string `json:"ref"`
+	// ID is the image ID, the digest of its config.
+	ID           string    `json:"id,omitempty"`
+	RepoTags     []string  `json:"repo_tags,omitempty"`
+	OS           string    `json:"os,omitempty"`
+	Architecture string    `json:"architecture,omitempty"`
+	Created      time.Time `json:"created,omitempty"`
+	// User is the user the image runs as; empty means root.
+	User         string   `json:"user,omitempty"`
+	ExposedPorts []string `json:"exposed_ports,omitempty"`
+	Entrypoint   []string `json:"entrypoint,omitempty"`
+	Cmd          []string `json:"cmd,omitempty"`
+	Layers       int      `json:"layers,omitempty"`
+}
+
+// Image is an image unpacked to Dir. Close removes what Open extracted.
+type Image struct {
+	Info
+	Dir     string
+	cleanup string
+}
+
+// Close removes the extracted filesystem; a directory scanned in place is
+// left alone.
+func (img *Image) Close() error {
+	if img.cleanup == "" {
+		return nil
+	}
+	return os.RemoveAll(img.cleanup)
+}
+
+// Open makes ref's filesystem readable: a directory is used as is, a file
+// is read as a `docker save` or OCI image archive, and anything else is
+// exported with `docker save` (or `podman save`) first. Archives are
+// unpacked under tmpDir (the system default when empty).
+func Open(ctx context.Context, ref, tmpDir string) (*Image, error) {
+	if fi, err := os.Stat(ref); err == nil {
+		if fi.IsDir() {
+			return &Image{Info: Info{Ref: ref}, Dir: ref}, nil
+		}
+		return openArchive(ref, ref, tmpDir)
+	}
+	scratch, err := os.MkdirTemp(tmpDir, "image-")
+	if err != nil {
+		return nil, err
+	}
+	archive := filepath.Join(scratch, "image.tar")
+	if err := save(ctx, ref, archive); err != nil {
+		os.RemoveAll(scratch)
+		return nil, err
+	}
+	img, err := openArchive(ref, archive, scratch)
+	// The unpacked tree has its own scratch directory; the export is
+	// no longer needed.
+	os.RemoveAll(scratch)
+	return img, err
+}
+
+// engines export an image to a tar archive, in order of preference.
+var engines = []string{"docker", "podman"}
+
+func save(ctx context.Context, ref, archive string) error {
+	var tried []string
+	for _, engine := range engines {
+		if _, err := exec.LookPath(engine); err != nil {
+			continue
+		}
+		out, err := exec.CommandContext(ctx, engine, "save", "-o", archive, ref).CombinedOutput()
+		if err == nil {
+			return nil
+		}
+		tried = append(tried, fmt.Sprintf("%s save: %v: %s", engine, err, strings.TrimSpace(string(out))))
+	}
+	if len(tried) == 0 {
+		return fmt.Errorf("%s: not a file or directory, and neither docker nor podman is installed to export it", ref)
+	}
+	return errors.New(strings.Join(tried, "; "))
+}
+
+// dockerManifest is one image in a `docker save` manifest.json.
+type dockerManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// ociIndex is an OCI image index (index.json, or a multi-platform
+// manifest list).
+type ociIndex struct {
+	MediaType string          `json:"mediaType"`
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations"`
+	Platform    *struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+	} `json:"platform"`
+}
+
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Config    ociDescriptor   `json:"config"`
+	Layers    []ociDescriptor `json:"layers"`
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+// imageConfig is the part of an image config Info reports.
+type imageConfig struct {
+	OS           string    `json:"os"`
+	Architecture string    `json:"architecture"`
+	Created      time.Time `json:"created"`
+	Config       struct {
+		User         string              `json:"User"`
+		ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+		Entrypoint   []string            `json:"Entrypoint"`
+		Cmd          []string            `json:"Cmd"`
+	} `json:"config"`
+}
+
+// openArchive unpacks the archive's blobs to a scratch directory, then
+// applies its layers in order to a root filesystem beside them.
+func openArchive(ref, archive, tmpDir string) (*Image, error) {
+	scratch, err := os.MkdirTemp(tmpDir, "image-")
+	if err != nil {
+		return nil, err
+	}
+	img, err := unpack(ref, archive, scratch)
+	if err != nil {
+		os.RemoveAll(scratch)
+		return nil, err
+	}
+	return img, nil
+}
+
+func unpack(ref, archive, scratch string) (*Image, error) {
+	blobs := filepath.Join(scratch, "blobs")
+	if err := extractFlat(archive, blobs); err != nil {
+		return nil, fmt.Errorf("%s: %w", archive, err)
+	}
+	configPath, layers, tags, err := manifestOf(blobs)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", archive, err)
+	}
+	img := &Image{Info: Info{Ref: ref, RepoTags: tags, Layers: len(layers)}, Dir: filepath.Join(scratch, "rootfs"), cleanup: scratch}
+	if configPath != "" {
+		b, err := os.ReadFile(filepath.Join(blobs, configPath))
+		if err != nil {
+			return nil, fmt.Errorf("%s: image config: %w", archive, err)
+		}
+		var c imageConfig
+		if err := json.Unmarshal(b, &c); err != nil {
+			return nil, fmt.Errorf("%s: image config: %w", archive, err)
+		}
+		img.ID = "sha256:" + strings.TrimSuffix(path.Base(configPath), ".json")
+		img.OS, img.Architecture, img.Created = c.OS, c.Architecture, c.Created
+		img.User, img.Entrypoint, img.Cmd = c.Config.User, c.Config.Entrypoint, c.Config.Cmd
+		for p := range c.Config.ExposedPorts {
+			img.ExposedPorts = append(img.ExposedPorts, p)
+		}
+		sort.Strings(img.ExposedPorts)
+	}
+	if err := os.Mkdir(img.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	for i, l := range layers {
+		if err := applyLayer(img.Dir, filepath.Join(blobs, l)); err != nil {
+			return nil, fmt.Errorf("%s: layer %d: %w", archive, i+1, err)
+		}
+	}
+	// The blobs are in the rootfs now.
+	os.RemoveAll(blobs)
+	return img, nil
+}
+
+// manifestOf finds the image config and layers in an unpacked archive:
+// manifest.json from `docker save`, else the OCI layout's index.json,
+// picking this host's platform from a multi-platform index.
+func manifestOf(blobs string) (config string, layers, tags []string, err error) {
+	if b, err := os.ReadFile(filepath.Join(blobs, "manifest.json")); err == nil {
+		var m []dockerManifest
+		if err := json.Unmarshal(b, &m); err != nil {
+			return "", nil, nil, fmt.Errorf("manifest.json: %w", err)
+		}
+		switch len(m) {
+		case 0:
+			return "", nil, nil, errors.New("manifest.json lists no image")
+		case 1:
+		default:
+			return "", nil, nil, fmt.Errorf("archive holds %d images; save one at a time", len(m))
+		}
+		// The paths come from the archive; keep them inside it.
+		for _, l := range m[0].Layers {
+			p, ok := cleanName(l)
+			if !ok {
+				return "", nil, nil, fmt.Errorf("manifest.json: bad layer path %q", l)
+			}
+			layers = append(layers, p)
+		}
+		config, _ = cleanName(m[0].Config)
+		return config, layers, m[0].RepoTags, nil
+	}
+	b, err := os.ReadFile(filepath.Join(blobs, "index.json"))
+	if err != nil {
+		return "", nil, nil, errors.New("neither manifest.json nor index.json: not a docker save or OCI archive")
+	}
+	var idx ociIndex
+	if err := json.Unmarshal(b, &idx); err != nil {
+		return "", nil, nil, fmt.Errorf("index.json: %w", err)
+	}
+	for depth := 0; depth < 4; depth++ {
+		d, err := pickManifest(idx.Manifests)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		if name := d.Annotations["org.opencontainers.image.ref.name"]; name != "" {
+			tags = []string{name}
+		}
+		var m ociManifest
+		if err := readBlob(blobs, d.Digest, &m); err != nil {
+			return "", nil, nil, err
+		}
+		if len(m.Manifests) > 0 {
+			// A manifest list: descend to this platform's image.
+			idx = ociIndex{Manifests: m.Manifests}
+			continue
+		}
+		for _, l := range m.Layers {
+			p, err := blobPath(l.Digest)
+			if err != nil {
+				return "", nil, nil, err
+			}
+			layers = append(layers, p)
+		}
+		config, err := blobPath(m.Config.Digest)
+		return config, layers, tags, err
+	}
+	return "", nil, nil, errors.New("index.json: manifest lists nested too deeply")
+}
+
+// pickManifest chooses the manifest for this host's platform, or the
+// only one.
+func pickManifest(ms []ociDescriptor) (ociDescriptor, error) {
+	if len(ms) == 0 {
+		return ociDescriptor{}, errors.New("index lists no manifests")
+	}
+	if len(ms) == 1 {
+		return ms[0], nil
+	}
+	for _, m := range ms {
+		if m.Platform != nil && m.Platform.OS == "linux" && m.Platform.Architecture == runtime.GOARCH {
+			return m, nil
+		}
+	}
+	return ociDescriptor{}, fmt.Errorf("index has %d manifests and none for linux/%s", len(ms), runtime.GOARCH)
+}
+
+// blobPath is where an OCI layout keeps the blob with digest d.
+func blobPath(d string) (string, error) {
+	alg, hex, ok := strings.Cut(d, ":")
+	if !ok || alg == "" || strings.ContainsAny(alg+hex, "/\\.") {
+		return "", fmt.Errorf("bad digest %q", d)
+	}
+	return path.Join("blobs", alg, hex), nil
+}
+
+func readBlob(blobs, digest string, v any) error {
+	p, err := blobPath(digest)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(filepath.Join(blobs, p))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// extractFlat extracts an image archive's regular files to dir. Archives
+// only hold blobs and JSON, so links and special files are skipped.
+func extractFlat(archive, dir string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := decompress(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		name, ok := cleanName(h.Name)
+		if !ok {
+			continue
+		}
+		dst := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		if err := writeFile(dst, tr, 0o644); err != nil {
+			return err
+		}
+	}
+}
+
+// cleanName makes an archive member's name relative and rejects one that
+// would land outside the extraction directory.
+func cleanName(name string) (string, bool) {
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	if name == "/" {
+		return "", false
+	}
+	return strings.TrimPrefix(name, "/"), true
+}
+
+func writeFile(dst string, r io.Reader, mode os.FileMode) error {
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// PortBindings are the exposed ports, which the image will listen on
+// wherever it runs, as the bindings port rules check.
+func (i Info) PortBindings() []collector.PortBinding {
+	var out []collector.PortBinding
+	for _, p := range i.ExposedPorts {
+		num, proto, _ := strings.Cut(p, "/")
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			continue
+		}
+		if proto == "" {
+			proto = "tcp"
+		}
+		out = append(out, collector.PortBinding{Port: n, Protocol: proto, Address: "0.0.0.0"})
+	}
+	return out
+}
@@ -0,0 +1,177 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"compliance-agent/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// entry is one tar member; a link makes it a symlink.
+type entry struct {
+	name, body, link string
+	dir              bool
+}
+
+func tarOf(t *testing.T, entries []entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		h := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+		switch {
+		case e.dir:
+			h.Typeflag, h.Mode, h.Size = tar.TypeDir, 0o755, 0
+		case e.link != "":
+			h.Typeflag, h.Linkname, h.Size = tar.TypeSymlink, e.link, 0
+		}
+		require.NoError(t, tw.WriteHeader(h))
+		_, err := tw.Write([]byte(e.body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func gzipped(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+var (
+	testConfig = []byte(`{"os":"linux","architecture":"amd64","config":{"User":"app","ExposedPorts":{"8080/tcp":{},"53/udp":{}}}}`)
+	layer1     = []entry{
+		{name: "etc/", dir: true},
+		{name: "etc/passwd", body: "root:x:0:0:root:/root:/bin/sh\n"},
+		{name: "etc/old.conf", body: "old"},
+		{name: "var/cache/a", body: "a"},
+		// A later layer's write through this must stay in the tree.
+		{name: "escape", link: "/tmp"},
+	}
+	layer2 = []entry{
+		{name: "etc/passwd", body: "root:x:0:0:root:/root:/bin/sh\napp:x:1000:1000::/app:/bin/sh\n"},
+		{name: "etc/.wh.old.conf"},
+		{name: "var/cache/b", body: "b"},
+		{name: "var/cache/.wh..wh..opq"},
+		{name: "escape/planted", body: "x"},
+		{name: "../../outside", body: "x"},
+	}
+)
+
+func dockerArchive(t *testing.T, dir string) string {
+	t.Helper()
+	manifest, _ := json.Marshal([]dockerManifest{{
+		Config:   digest(testConfig) + ".json",
+		RepoTags: []string{"registry.example.com/app:1.2"},
+		Layers:   []string{"l1/layer.tar", "l2/layer.tar"},
+	}})
+	archive := filepath.Join(dir, "docker.tar")
+	require.NoError(t, os.WriteFile(archive, tarOf(t, []entry{
+		{name: "manifest.json", body: string(manifest)},
+		{name: digest(testConfig) + ".json", body: string(testConfig)},
+		{name: "l1/layer.tar", body: string(tarOf(t, layer1))},
+		{name: "l2/layer.tar", body: string(tarOf(t, layer2))},
+	}), 0o644))
+	return archive
+}
+
+func ociArchive(t *testing.T, dir string) string {
+	t.Helper()
+	l1, l2 := gzipped(t, tarOf(t, layer1)), gzipped(t, tarOf(t, layer2))
+	manifest, _ := json.Marshal(map[string]any{
+		"config": map[string]string{"digest": "sha256:" + digest(testConfig)},
+		"layers": []map[string]string{{"digest": "sha256:" + digest(l1)}, {"digest": "sha256:" + digest(l2)}},
+	})
+	index, _ := json.Marshal(map[string]any{"manifests": []map[string]any{{
+		"digest":      "sha256:" + digest(manifest),
+		"annotations": map[string]string{"org.opencontainers.image.ref.name": "app:1.2"},
+	}}})
+	archive := filepath.Join(dir, "oci.tar")
+	require.NoError(t, os.WriteFile(archive, tarOf(t, []entry{
+		{name: "oci-layout", body: `{"imageLayoutVersion":"1.0.0"}`},
+		{name: "index.json", body: string(index)},
+		{name: "blobs/sha256/" + digest(manifest), body: string(manifest)},
+		{name: "blobs/sha256/" + digest(testConfig), body: string(testConfig)},
+		{name: "blobs/sha256/" + digest(l1), body: string(l1)},
+		{name: "blobs/sha256/" + digest(l2), body: string(l2)},
+	}), 0o644))
+	return archive
+}
+
+func TestOpen(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks")
+	}
+	for name, build := range map[string]func(*testing.T, string) string{
+		"docker save": dockerArchive,
+		"oci":         ociArchive,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			img, err := Open(context.Background(), build(t, dir), dir)
+			require.NoError(t, err)
+
+			assert.Equal(t, "sha256:"+digest(testConfig), img.ID)
+			assert.Equal(t, "app", img.User)
+			assert.Equal(t, []string{"53/udp", "8080/tcp"}, img.ExposedPorts)
+			assert.Equal(t, []collector.PortBinding{
+				{Port: 53, Protocol: "udp", Address: "0.0.0.0"},
+				{Port: 8080, Protocol: "tcp", Address: "0.0.0.0"},
+			}, img.PortBindings())
+			assert.Equal(t, 2, img.Layers)
+			assert.NotEmpty(t, img.RepoTags)
+
+			passwd, err := os.ReadFile(filepath.Join(img.Dir, "etc/passwd"))
+			require.NoError(t, err)
+			assert.Contains(t, string(passwd), "app:x:1000", "upper layer wins")
+			assert.NoFileExists(t, filepath.Join(img.Dir, "etc/old.conf"), "whiteout")
+			assert.NoFileExists(t, filepath.Join(img.Dir, "var/cache/a"), "opaque directory")
+			assert.FileExists(t, filepath.Join(img.Dir, "var/cache/b"), "the layer's own files survive its opaque marker")
+			assert.FileExists(t, filepath.Join(img.Dir, "tmp/planted"), "written through the symlink, inside the tree")
+			assert.NoFileExists(t, "/tmp/planted")
+			assert.FileExists(t, filepath.Join(img.Dir, "outside"), "../ names are kept inside")
+
+			require.NoError(t, img.Close())
+			assert.NoDirExists(t, img.Dir)
+		})
+	}
+}
+
+func TestOpen_Directory(t *testing.T) {
+	dir := t.TempDir()
+	img, err := Open(context.Background(), dir, "")
+	require.NoError(t, err)
+	assert.Equal(t, dir, img.Dir)
+	require.NoError(t, img.Close())
+	assert.DirExists(t, dir, "a directory scanned in place is left alone")
+}
+
+func TestOpen_NotAnImage(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "x.tar")
+	require.NoError(t, os.WriteFile(archive, tarOf(t, []entry{{name: "hello", body: "x"}}), 0o644))
+	_, err := Open(context.Background(), archive, dir)
+	assert.ErrorContains(t, err, "not a docker save or OCI archive")
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1, "scratch directories are removed on failure")
+}
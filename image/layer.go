@@ -0,0 +1,185 @@
+package image
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// Whiteouts, in the OCI layer format, delete a lower layer's file
+// (.wh.<name>) or everything below a directory (.wh..wh..opq).
+const (
+	whiteoutPrefix = ".wh."
+	opaqueWhiteout = ".wh..wh..opq"
+)
+
+// decompress sees through gzip, which most layers and some archives use.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return gzip.NewReader(br)
+	case bytes.Equal(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return nil, errors.New("zstd-compressed layers are not supported; export the image with docker save")
+	}
+	return br, nil
+}
+
+// applyLayer applies the layer tar at layerPath to root. Whiteouts are
+// applied first, since they only delete what lower layers left and may
+// come after the layer's own files. Every path is resolved inside root,
+// so a symlink planted by a lower layer can't redirect a write onto the
+// host.
+func applyLayer(root, layerPath string) error {
+	if err := eachEntry(layerPath, func(h *tar.Header, _ io.Reader) error {
+		name, ok := cleanName(h.Name)
+		if !ok {
+			return nil
+		}
+		dir, base := path.Split(name)
+		switch {
+		case base == opaqueWhiteout:
+			return clearDir(root, dir)
+		case strings.HasPrefix(base, whiteoutPrefix):
+			return remove(root, path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return eachEntry(layerPath, func(h *tar.Header, r io.Reader) error {
+		name, ok := cleanName(h.Name)
+		if !ok || strings.HasPrefix(path.Base(name), whiteoutPrefix) {
+			return nil
+		}
+		return extract(root, name, h, r)
+	})
+}
+
+func eachEntry(layerPath string, fn func(*tar.Header, io.Reader) error) error {
+	f, err := os.Open(layerPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := decompress(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(h, tr); err != nil {
+			return fmt.Errorf("%s: %w", h.Name, err)
+		}
+	}
+}
+
+// parent resolves the directory name is in, inside root, creating it if
+// a layer omitted it.
+func parent(root, name string) (string, error) {
+	dir, err := collector.InRoot(root, path.Dir("/"+name))
+	if err != nil {
+		return "", err
+	}
+	return dir, os.MkdirAll(dir, 0o755)
+}
+
+func remove(root, name string) error {
+	dir, err := parent(root, name)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(dir, path.Base(name)))
+}
+
+func clearDir(root, dir string) error {
+	hp, err := collector.InRoot(root, "/"+dir)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(hp)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(hp, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extract writes one entry. Files are kept readable and directories
+// writable by the agent whatever the layer says, so the tree can be read
+// and removed; ownership isn't kept, as nothing runs from the tree.
+// Devices and FIFOs are skipped.
+func extract(root, name string, h *tar.Header, r io.Reader) error {
+	dir, err := parent(root, name)
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(dir, path.Base(name))
+	mode := fs.FileMode(h.Mode) & fs.ModePerm
+	if h.Typeflag == tar.TypeDir {
+		if fi, err := os.Lstat(dst); err == nil && fi.IsDir() {
+			return os.Chmod(dst, mode|0o700)
+		}
+		_ = os.RemoveAll(dst)
+		return os.Mkdir(dst, mode|0o700)
+	}
+	switch h.Typeflag {
+	case tar.TypeReg, tar.TypeSymlink, tar.TypeLink:
+	default:
+		return nil
+	}
+	// A file replaces whatever a lower layer had there.
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	switch h.Typeflag {
+	case tar.TypeSymlink:
+		return os.Symlink(h.Linkname, dst)
+	case tar.TypeLink:
+		target, ok := cleanName(h.Linkname)
+		if !ok {
+			return nil
+		}
+		src, err := collector.InRoot(root, "/"+target)
+		if err != nil {
+			return err
+		}
+		if err := os.Link(src, dst); err == nil {
+			return nil
+		}
+		// Across filesystems, or a target that is itself a link: copy.
+		f, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return writeFile(dst, f, 0o644)
+	}
+	return writeFile(dst, r, mode|0o600)
+}
@@ -0,0 +1,79 @@
+// Package logging sets up the agent's logs: log/slog, as text or JSON
+// lines on stderr, at a minimum level. Subsystems log through
+// Component, so every line says which part of the agent wrote it and on
+// which host, and a daemon's logs can be shipped and queried like its
+// reports.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Levels and Formats are the accepted --log-level and --log-format
+// values.
+var (
+	Levels  = []string{"debug", "info", "warn", "error"}
+	Formats = []string{"text", "json"}
+)
+
+// ParseLevel reads a level name, case-insensitively; "warning" is warn.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q (want %s)", s, strings.Join(Levels, ", "))
+}
+
+// New returns a logger writing format ("text", the default, or "json")
+// to w from level up.
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q (want %s)", format, strings.Join(Formats, " or "))
+}
+
+// Setup makes a logger from New, writing to stderr, the default. What is
+// still written with the log package is a fatal error on the way out of
+// a command, so it is logged at error level.
+func Setup(format, level string) error {
+	l, err := New(os.Stderr, format, level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(l)
+	slog.SetLogLoggerLevel(slog.LevelError)
+	return nil
+}
+
+var host = sync.OnceValue(func() string {
+	h, _ := os.Hostname()
+	return h
+})
+
+// Component returns the default logger with the component and this
+// host's name attached. It reads the default at each call, so it can be
+// used from before Setup runs.
+func Component(name string) *slog.Logger {
+	return slog.Default().With("component", name, "host", host())
+}
@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevel(t *testing.T) {
+	for in, want := range map[string]slog.Level{
+		"":        slog.LevelInfo,
+		"debug":   slog.LevelDebug,
+		"INFO":    slog.LevelInfo,
+		"warning": slog.LevelWarn,
+		"error":   slog.LevelError,
+	} {
+		got, err := ParseLevel(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	_, err := ParseLevel("verbose")
+	assert.ErrorContains(t, err, "want debug, info, warn, error")
+}
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(&buf, "json", "warn")
+	require.NoError(t, err)
+	l.Info("dropped")
+	l.With("component", "collector").Warn("osqueryd exited", "err", "exit status 1")
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line), buf.String())
+	assert.Equal(t, "WARN", line["level"])
+	assert.Equal(t, "osqueryd exited", line["msg"])
+	assert.Equal(t, "collector", line["component"])
+	assert.Equal(t, "exit status 1", line["err"])
+
+	buf.Reset()
+	l, err = New(&buf, "", "")
+	require.NoError(t, err)
+	l.Debug("dropped")
+	l.Info("scanning", "interval", "5m0s")
+	assert.Contains(t, buf.String(), `level=INFO msg=scanning interval=5m0s`)
+	assert.NotContains(t, buf.String(), "dropped")
+
+	_, err = New(&buf, "logfmt", "info")
+	assert.ErrorContains(t, err, "want text or json")
+	_, err = New(&buf, "json", "loud")
+	assert.ErrorContains(t, err, "unknown log level")
+}
+
+func TestComponent(t *testing.T) {
+	defer func(l *slog.Logger) { slog.SetDefault(l) }(slog.Default())
+	var buf bytes.Buffer
+	l, err := New(&buf, "json", "info")
+	require.NoError(t, err)
+	slog.SetDefault(l)
+
+	Component("alerting").Info("report sent", "alerter", "slack")
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	host, _ := os.Hostname()
+	assert.Equal(t, "alerting", line["component"])
+	assert.Equal(t, host, line["host"])
+	assert.Equal(t, "slack", line["alerter"])
+}
+
+func TestSetup(t *testing.T) {
+	defer func(l *slog.Logger) { slog.SetDefault(l) }(slog.Default())
+	defer slog.SetLogLoggerLevel(slog.LevelInfo)
+	assert.ErrorContains(t, Setup("xml", "info"), "unknown log format")
+	require.NoError(t, Setup("json", "error"))
+	assert.True(t, slog.Default().Enabled(context.Background(), slog.LevelError))
+	assert.False(t, slog.Default().Enabled(context.Background(), slog.LevelWarn))
+
+	// What still goes through the log package is a fatal error, logged
+	// at error level so a level filter keeps it.
+	var buf bytes.Buffer
+	l, err := New(&buf, "json", "error")
+	require.NoError(t, err)
+	slog.SetDefault(l)
+	slog.SetLogLoggerLevel(slog.LevelError)
+	log.Printf("config load: no such file")
+	assert.Contains(t, buf.String(), `"level":"ERROR","msg":"config load: no such file"`)
+}
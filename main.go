@@ -3,202 +3,151 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
+	"strings"
 
-	"compliance-agent/alerting"
-	"compliance-agent/analyzer"
 	"compliance-agent/baseline"
 	"compliance-agent/collector"
 	"compliance-agent/config"
+	"compliance-agent/errcode"
 	"compliance-agent/exporter"
+	"compliance-agent/logging"
 	"compliance-agent/ml"
 	"compliance-agent/mode"
-	"compliance-agent/report"
 )
 
 func main() {
-	// Parse command line flags
-	testSlack := flag.Bool("test-slack", false, "Test Slack connection and send a test message")
-	configPath := flag.String("config", "", "Path to YAML config (optional)")
-	streaming := flag.Bool("streaming", false, "Run in streaming mode (loop forever)")
-	flag.Parse()
-
-	if *testSlack {
-		fmt.Println("Testing Slack connection...")
-		slackClient := alerting.NewSlackClient()
-		if err := slackClient.TestConnection(); err != nil {
-			log.Fatalf("Slack test failed: %v\nSet SLACK_WEBHOOK_URL environment variable", err)
-		}
-		fmt.Println("✅ Slack connection test successful!")
+	flag.Usage = usage
+	args := takeLogFlags(os.Args[1:])
+	setupLogging(config.Default().Log)
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		usage()
 		return
 	}
-
-	cfg, err := config.Load(*configPath)
-	if err != nil {
-		log.Fatalf("config load: %v", err)
-	}
-	if *streaming {
-		cfg.Mode = "streaming"
+	cmd, ok := commands[name]
+	if !ok {
+		usage()
+		usageError("unknown command %q", name)
 	}
+	cmd.run(args)
+}
 
-	// Streaming mode short-circuits the one-shot flow.
-	if cfg.Mode == "streaming" {
-		runStreaming(cfg)
-		return
-	}
-
-	fmt.Println("Compliance Agent: collecting system data...")
-
-	var c collector.Collector = collector.NewOSQueryCollector()
+// logLevel and logFormat are the global --log-level and --log-format.
+var logLevel, logFormat string
 
-	// Try to ensure osquery is running, fallback to basic collection if not
-	if osqCollector, ok := c.(*collector.OSQueryCollector); ok {
-		if err := osqCollector.EnsureOSQueryRunning(); err != nil {
-			fmt.Printf("Using fallback data collection: %v\n", err)
-			c = collector.NewFallbackCollector()
+// takeLogFlags removes --log-level and --log-format from args, wherever
+// they are, so every command takes them: `daemon --log-level debug`.
+func takeLogFlags(args []string) []string {
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			return append(rest, args[i:]...)
 		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		var dst *string
+		if strings.HasPrefix(args[i], "-") {
+			switch name {
+			case "log-level":
+				dst = &logLevel
+			case "log-format":
+				dst = &logFormat
+			}
+		}
+		if dst == nil {
+			rest = append(rest, args[i])
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				usageError("--%s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		*dst = value
 	}
+	return rest
+}
 
-	users, err := c.CollectUsers()
-	if err != nil {
-		log.Fatalf("failed to collect users: %v", err)
+// setupLogging applies cfg under any --log-level and --log-format given.
+func setupLogging(cfg config.LogConfig) {
+	if logLevel != "" {
+		cfg.Level = logLevel
 	}
-	procs, err := c.CollectProcesses(25)
-	if err != nil {
-		log.Fatalf("failed to collect processes: %v", err)
+	if logFormat != "" {
+		cfg.Format = logFormat
 	}
-
-	// Phase 5 additions: open ports and packages
-	openPorts, err := c.CollectOpenPorts()
-	if err != nil {
-		log.Printf("failed to collect open ports: %v", err)
+	if err := logging.Setup(cfg.Format, cfg.Level); err != nil {
+		fatal(errcode.New(errcode.ParseError, err), "log config")
 	}
-	packages, err := c.CollectPackages(200)
-	if err != nil {
-		log.Printf("failed to collect packages: %v", err)
-	}
-
-	fmt.Println("Users:")
-	dumpJSON(users)
-	fmt.Println("Processes:")
-	dumpJSON(procs)
-
-	// Phase 3: simple compliance policies
-	policies := analyzer.Policies{
-		AllowedUsers: []string{"root", "admin"},
-		AllowedPorts: []int{22, 80, 443},
-	}
-	userViolations := analyzer.AnalyzeUsers(users, policies)
-	portViolations := analyzer.AnalyzePorts(openPorts, policies)
-	fmt.Println("Compliance Violations (users):")
-	dumpJSON(userViolations)
-	fmt.Println("Compliance Violations (ports):")
-	dumpJSON(portViolations)
+}
 
-	// Phase 4: build and save JSON report
-	hostname, _ := os.Hostname()
-	var violations []map[string]string
-	for _, v := range userViolations {
-		violations = append(violations, map[string]string{"category": v.Category, "message": v.Message})
-	}
-	for _, v := range portViolations {
-		violations = append(violations, map[string]string{"category": v.Category, "message": v.Message})
-	}
-	// Behavioral / UEBA layer: build the baseline-aware feature vector,
-	// score it, and attach the score to the report metadata so downstream
-	// SIEM rules can branch on it.
-	bstore := baseline.NewStore(cfg.Baseline.Path)
-	if err := bstore.Load(); err != nil {
-		log.Printf("baseline load: %v", err)
-	}
-	snap := baseline.SnapshotFromCollected(hostname, procs, openPorts, users, packages)
-	bstore.Update(snap)
-	feats := ml.BuildFeatures(snap, bstore.Data())
-	scorer := ml.NewScorer(cfg.ML.URL, cfg.ML.Timeout)
-	score, model, scoreErr := scorer.Score(context.Background(), feats)
-	if scoreErr != nil {
-		log.Printf("ml score failed: %v (model=%s)", scoreErr, model)
-	}
-	if err := bstore.Save(); err != nil {
-		log.Printf("baseline save: %v", err)
-	}
-	mlMeta := map[string]interface{}{
-		"score":     score,
-		"model":     model,
-		"threshold": cfg.ML.Threshold,
-		"anomaly":   score >= cfg.ML.Threshold,
-		"features":  feats,
+// newCollector picks the data source: the filesystem tree under --root
+// when one is given, otherwise Fleet when configured, then local osquery
+// (starting a managed osqueryd if needed), and finally native system
+// commands. The returned func releases the osquery connection and any
+// daemon the agent started; fatal and exit run it too, so the daemon
+// is stopped on every way out. setupErr is a non-fatal problem worth
+// reporting as a finding, such as an osquery socket that failed the
+// permission check.
+func newCollector(cfg config.Config) (c collector.Collector, closeFn func(), setupErr error) {
+	if cfg.Root != "" {
+		return collector.NewRootFS(cfg.Root), func() {}, nil
+	}
+	// A configured Fleet server takes precedence: those hosts typically
+	// don't expose the local extension socket at all.
+	if cfg.Fleet.URL != "" {
+		fc := collector.NewFleetCollector(cfg.Fleet.URL, cfg.Fleet.Token, cfg.Fleet.HostIdentifier, cfg.Fleet.Timeout)
+		if err := fc.HealthCheck(); err != nil {
+			logging.Component("collector").Warn("Fleet unavailable, using local collection", "url", cfg.Fleet.URL, "err", err)
+		} else {
+			return fc, func() {}, nil
+		}
 	}
 
-	rep := report.ComplianceReport{
-		GeneratedAt:   time.Now().UTC(),
-		Hostname:      hostname,
-		Users:         users,
-		Processes:     procs,
-		OpenPorts:     openPorts,
-		Packages:      packages,
-		Violations:    violations,
-		ExtraMetadata: map[string]interface{}{"ml": mlMeta},
-	}
-	b, _ := rep.ToJSON()
-	fmt.Println("Compliance Report JSON:")
-	fmt.Println(string(b))
-	if err := rep.SaveToFile("compliance_report.json"); err != nil {
-		log.Printf("failed to save report: %v", err)
-	} else {
-		fmt.Println("Saved report to compliance_report.json")
+	osq := collector.NewOSQueryCollector()
+	osq.Unprivileged = cfg.Scope == "user"
+	if cfg.OSQuery.Socket != "" {
+		osq.SocketPath = cfg.OSQuery.Socket
+	}
+	osq.Daemon = collector.DaemonOptions{
+		WatchdogLevel:            cfg.OSQuery.WatchdogLevel,
+		WatchdogMemoryLimitMB:    cfg.OSQuery.WatchdogMemoryLimitMB,
+		WatchdogUtilizationLimit: cfg.OSQuery.WatchdogUtilizationLimit,
+		DisableEvents:            cfg.OSQuery.DisableEvents,
+		EventsExpiry:             cfg.OSQuery.EventsExpiry,
+		EventsMax:                cfg.OSQuery.EventsMax,
+		LoggerPlugin:             cfg.OSQuery.LoggerPlugin,
+		ExtraFlags:               cfg.OSQuery.ExtraFlags,
 	}
 
-	// Phase 5: Send alerts to Slack (if configured)
-	slackClient := alerting.NewSlackClient()
-
-	// Test Slack connection first
-	if err := slackClient.TestConnection(); err != nil {
-		fmt.Printf("Slack not configured or connection failed: %v\n", err)
-		fmt.Println("To enable Slack alerts, set SLACK_WEBHOOK_URL environment variable")
-	} else {
-		fmt.Println("Slack connection successful! Sending compliance report...")
-
-		// Convert report to Slack format
-		slackReport := alerting.ComplianceReport{
-			GeneratedAt:   rep.GeneratedAt,
-			Hostname:      rep.Hostname,
-			Users:         rep.Users,
-			Processes:     rep.Processes,
-			OpenPorts:     rep.OpenPorts,
-			Packages:      rep.Packages,
-			Violations:    rep.Violations,
-			ExtraMetadata: rep.ExtraMetadata,
-		}
-
-		// Send compliance report
-		if err := slackClient.SendComplianceReport(slackReport); err != nil {
-			log.Printf("Failed to send compliance report to Slack: %v", err)
-		} else {
-			fmt.Println("✅ Compliance report sent to Slack successfully!")
-		}
-
-		// Send critical violation alerts if any
-		if len(violations) > 0 {
-			if err := slackClient.SendViolationAlert(hostname, violations); err != nil {
-				log.Printf("Failed to send violation alert to Slack: %v", err)
-			} else {
-				fmt.Println("🚨 Violation alerts sent to Slack!")
-			}
+	// Try to ensure osquery is running, fallback to basic collection if not
+	if err := osq.EnsureOSQueryRunning(); err != nil {
+		var unsafe *collector.UnsafeSocketError
+		if errors.As(err, &unsafe) {
+			logging.Component("collector").Warn("osquery socket rejected", "err", err)
+			setupErr = err
 		}
+		logging.Component("collector").Info("using the fallback collector", "reason", err)
+		return collector.NewFallbackCollector(), func() {}, setupErr
 	}
+	closeOsq := func() { osq.Close() }
+	onExit(closeOsq)
+	return osq, closeOsq, nil
 }
 
 func dumpJSON(v any) {
 	b, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
-		log.Printf("json encode error: %v", err)
+		slog.Error("json encode failed", "err", err)
 		return
 	}
 	fmt.Println(string(b))
@@ -208,29 +157,24 @@ func dumpJSON(v any) {
 // loop. It uses the same collector/baseline/ML stack as the one-shot path
 // so we don't have two code paths drifting apart.
 func runStreaming(cfg config.Config) {
-	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	ctx, cancel := signalContext()
 	defer cancel()
 
-	var c collector.Collector = collector.NewOSQueryCollector()
-	if osqC, ok := c.(*collector.OSQueryCollector); ok {
-		if err := osqC.EnsureOSQueryRunning(); err != nil {
-			log.Printf("osquery unavailable, using fallback: %v", err)
-			c = collector.NewFallbackCollector()
-		}
-	}
+	c, closeCollector, _ := newCollector(cfg)
+	defer closeCollector()
 
 	bstore := baseline.NewStore(cfg.Baseline.Path)
 	if err := bstore.Load(); err != nil {
-		log.Printf("baseline load: %v", err)
+		logging.Component("baseline").Warn("baseline load failed", "path", cfg.Baseline.Path, "err", err)
 	}
 
 	var exp *exporter.Server
 	if cfg.Exporter.Enabled {
 		exp = exporter.New(cfg.Exporter.Addr)
 		go func() {
-			log.Printf("exporter listening on %s", cfg.Exporter.Addr)
+			logging.Component("exporter").Info("exporter listening", "addr", cfg.Exporter.Addr)
 			if err := exp.ListenAndServe(); err != nil {
-				log.Printf("exporter shutdown: %v", err)
+				logging.Component("exporter").Info("exporter shut down", "err", err)
 			}
 		}()
 	}
@@ -243,6 +187,6 @@ func runStreaming(cfg config.Config) {
 		Exporter:  exp,
 	}
 	if err := mode.RunStreaming(ctx, runner); err != nil && err != context.Canceled {
-		log.Printf("streaming exited: %v", err)
+		logging.Component("streaming").Info("streaming exited", "err", err)
 	}
 }
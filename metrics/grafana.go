@@ -0,0 +1,304 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The files `assets grafana` writes, as shipped in assets/grafana.
+const (
+	DashboardFile  = "compliance-fleet-dashboard.json"
+	AlertRulesFile = "compliance-alerts.rules.yml"
+)
+
+// notReporting is how long a host may go without uploading before the
+// dashboard and the alerts count it as not reporting: a day, several
+// times any sensible daemon interval.
+const notReporting = 24 * 60 * 60
+
+// Grafana dashboard JSON, as much of it as the dashboard uses.
+type (
+	dashboard struct {
+		Title         string     `json:"title"`
+		UID           string     `json:"uid"`
+		Description   string     `json:"description"`
+		Tags          []string   `json:"tags"`
+		SchemaVersion int        `json:"schemaVersion"`
+		Version       int        `json:"version"`
+		Editable      bool       `json:"editable"`
+		Refresh       string     `json:"refresh"`
+		Time          timeRange  `json:"time"`
+		Templating    templating `json:"templating"`
+		Panels        []panel    `json:"panels"`
+	}
+	timeRange struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	templating struct {
+		List []variable `json:"list"`
+	}
+	variable struct {
+		Name       string      `json:"name"`
+		Label      string      `json:"label"`
+		Type       string      `json:"type"`
+		Query      string      `json:"query"`
+		Datasource *datasource `json:"datasource,omitempty"`
+		Refresh    int         `json:"refresh,omitempty"`
+		IncludeAll bool        `json:"includeAll,omitempty"`
+		Multi      bool        `json:"multi,omitempty"`
+		AllValue   string      `json:"allValue,omitempty"`
+	}
+	datasource struct {
+		Type string `json:"type"`
+		UID  string `json:"uid"`
+	}
+	panel struct {
+		ID              int              `json:"id"`
+		Title           string           `json:"title"`
+		Description     string           `json:"description,omitempty"`
+		Type            string           `json:"type"`
+		Datasource      datasource       `json:"datasource"`
+		GridPos         gridPos          `json:"gridPos"`
+		Targets         []target         `json:"targets"`
+		FieldConfig     fieldConfig      `json:"fieldConfig"`
+		Options         map[string]any   `json:"options,omitempty"`
+		Transformations []transformation `json:"transformations,omitempty"`
+	}
+	gridPos struct {
+		H int `json:"h"`
+		W int `json:"w"`
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+	target struct {
+		RefID        string     `json:"refId"`
+		Datasource   datasource `json:"datasource"`
+		Expr         string     `json:"expr"`
+		LegendFormat string     `json:"legendFormat,omitempty"`
+		Instant      bool       `json:"instant,omitempty"`
+		Range        bool       `json:"range,omitempty"`
+		Format       string     `json:"format,omitempty"`
+	}
+	fieldConfig struct {
+		Defaults  fieldDefaults `json:"defaults"`
+		Overrides []any         `json:"overrides"`
+	}
+	fieldDefaults struct {
+		Unit       string      `json:"unit,omitempty"`
+		Decimals   *int        `json:"decimals,omitempty"`
+		Thresholds *thresholds `json:"thresholds,omitempty"`
+		Color      *fieldColor `json:"color,omitempty"`
+	}
+	thresholds struct {
+		Mode  string      `json:"mode"`
+		Steps []threshold `json:"steps"`
+	}
+	threshold struct {
+		Color string   `json:"color"`
+		Value *float64 `json:"value"` // nil is the base step
+	}
+	fieldColor struct {
+		Mode string `json:"mode"`
+	}
+	transformation struct {
+		ID      string         `json:"id"`
+		Options map[string]any `json:"options"`
+	}
+)
+
+// prometheus is the dashboard's data source: whichever one the
+// datasource variable picks.
+var prometheus = datasource{Type: "prometheus", UID: "${datasource}"}
+
+// onPlatform selects family f's series on the platforms picked.
+func onPlatform(f Family, matchers string) string {
+	if matchers != "" {
+		matchers += ","
+	}
+	return fmt.Sprintf(`%s{%splatform=~"$platform"}`, f.Name, matchers)
+}
+
+func steps(base string, more ...any) *thresholds {
+	t := &thresholds{Mode: "absolute", Steps: []threshold{{Color: base}}}
+	for i := 0; i+1 < len(more); i += 2 {
+		v := more[i+1].(float64)
+		t.Steps = append(t.Steps, threshold{Color: more[i].(string), Value: &v})
+	}
+	return t
+}
+
+// Dashboard is the fleet compliance dashboard, as Grafana imports it.
+// It reads the fleet server's metrics from a Prometheus data source
+// picked when it is opened, and filters hosts by platform.
+func Dashboard() ([]byte, error) {
+	zero := 0
+	stat := func(title, desc, expr string, th *thresholds) panel {
+		return panel{
+			Title: title, Description: desc, Type: "stat",
+			Targets:     []target{{Expr: expr, Instant: true}},
+			FieldConfig: fieldConfig{Defaults: fieldDefaults{Decimals: &zero, Thresholds: th, Color: &fieldColor{Mode: "thresholds"}}},
+			Options:     map[string]any{"colorMode": "background", "graphMode": "none", "reduceOptions": map[string]any{"calcs": []string{"lastNotNull"}}},
+		}
+	}
+	hidden := map[string]any{"Time": true, "__name__": true, "job": true, "instance": true}
+	panels := []panel{
+		stat("Hosts", "Enrolled hosts.", fmt.Sprintf("count(%s)", onPlatform(HostInfo, "")), steps("blue")),
+		stat("Violations", "Violations in every host's newest report.",
+			fmt.Sprintf("sum(%s) or vector(0)", onPlatform(Violations, "")), steps("green", "orange", 1.0)),
+		stat("Hosts with critical violations", "", fmt.Sprintf(`count(%s > 0) or vector(0)`, onPlatform(Violations, `severity="critical"`)),
+			steps("green", "red", 1.0)),
+		stat("Hosts not reporting", "Hosts that haven't uploaded a report for a day.",
+			fmt.Sprintf("count(time() - %s > %d) or vector(0)", onPlatform(LastSeen, ""), notReporting), steps("green", "orange", 1.0)),
+		{
+			Title: "Violations by severity", Type: "timeseries",
+			Targets: []target{{Expr: fmt.Sprintf("sum by (severity) (%s)", onPlatform(Violations, "")), LegendFormat: "{{severity}}", Range: true}},
+			FieldConfig: fieldConfig{Defaults: fieldDefaults{Decimals: &zero}, Overrides: []any{
+				severityColor("critical", "red"), severityColor("high", "orange"), severityColor("medium", "yellow"),
+				severityColor("low", "blue"), severityColor("info", "text"),
+			}},
+			Options: map[string]any{"legend": map[string]any{"displayMode": "list", "placement": "bottom"}},
+		},
+		{
+			Title: "Report ingest", Description: "Reports the fleet server stored and refused, per second.", Type: "timeseries",
+			Targets: []target{
+				{Expr: fmt.Sprintf("sum(rate(%s[5m]))", ReportsReceived.Name), LegendFormat: "stored", Range: true},
+				{Expr: fmt.Sprintf("sum by (reason) (rate(%s[5m]))", ReportsRejected.Name), LegendFormat: "refused: {{reason}}", Range: true},
+			},
+			FieldConfig: fieldConfig{Defaults: fieldDefaults{Unit: "reqps"}},
+		},
+		{
+			Title: "Riskiest hosts", Description: "The riskiest violation on each host, riskiest first.", Type: "table",
+			Targets:     []target{{Expr: fmt.Sprintf("sort_desc(topk(20, %s))", onPlatform(MaxRisk, "")), Instant: true, Format: "table"}},
+			FieldConfig: fieldConfig{Defaults: fieldDefaults{Thresholds: steps("green", "orange", 4.0, "red", 7.0)}},
+			Transformations: []transformation{{ID: "organize", Options: map[string]any{
+				"excludeByName": hidden, "renameByName": map[string]any{"Value": "max risk"},
+			}}},
+		},
+		{
+			Title: "Hosts not reporting", Description: "Time since each host's last upload, for those quiet for a day.", Type: "table",
+			Targets:     []target{{Expr: fmt.Sprintf("sort_desc(time() - %s > %d)", onPlatform(LastSeen, ""), notReporting), Instant: true, Format: "table"}},
+			FieldConfig: fieldConfig{Defaults: fieldDefaults{Unit: "s"}},
+			Transformations: []transformation{{ID: "organize", Options: map[string]any{
+				"excludeByName": hidden, "renameByName": map[string]any{"Value": "silent for"},
+			}}},
+		},
+	}
+	layout := []gridPos{
+		{4, 6, 0, 0}, {4, 6, 6, 0}, {4, 6, 12, 0}, {4, 6, 18, 0},
+		{9, 12, 0, 4}, {9, 12, 12, 4},
+		{10, 12, 0, 13}, {10, 12, 12, 13},
+	}
+	for i := range panels {
+		p := &panels[i]
+		p.ID, p.GridPos, p.Datasource = i+1, layout[i], prometheus
+		if p.FieldConfig.Overrides == nil {
+			p.FieldConfig.Overrides = []any{}
+		}
+		for j := range p.Targets {
+			p.Targets[j].RefID = string(rune('A' + j))
+			p.Targets[j].Datasource = prometheus
+		}
+	}
+
+	d := dashboard{
+		Title:         "Fleet compliance",
+		UID:           "compliance-fleet",
+		Description:   "Compliance posture of the hosts reporting to the compliance-agent fleet server.",
+		Tags:          []string{"compliance", "compliance-agent"},
+		SchemaVersion: 39,
+		Version:       1,
+		Editable:      true,
+		Refresh:       "1m",
+		Time:          timeRange{From: "now-7d", To: "now"},
+		Templating: templating{List: []variable{
+			{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+			{
+				Name: "platform", Label: "Platform", Type: "query", Datasource: &prometheus,
+				Query: fmt.Sprintf("label_values(%s, platform)", HostInfo.Name), Refresh: 2,
+				IncludeAll: true, Multi: true, AllValue: ".*",
+			},
+		}},
+		Panels: panels,
+	}
+	return marshalIndent(d)
+}
+
+func severityColor(severity, color string) map[string]any {
+	return map[string]any{
+		"matcher":    map[string]any{"id": "byName", "options": severity},
+		"properties": []any{map[string]any{"id": "color", "value": map[string]any{"mode": "fixed", "fixedColor": color}}},
+	}
+}
+
+// Prometheus alerting rules.
+type (
+	ruleFile struct {
+		Groups []ruleGroup `yaml:"groups"`
+	}
+	ruleGroup struct {
+		Name  string `yaml:"name"`
+		Rules []rule `yaml:"rules"`
+	}
+	rule struct {
+		Alert       string            `yaml:"alert"`
+		Expr        string            `yaml:"expr"`
+		For         string            `yaml:"for"`
+		Labels      map[string]string `yaml:"labels"`
+		Annotations map[string]string `yaml:"annotations"`
+	}
+)
+
+// AlertRules is a Prometheus rule file alerting on the fleet server's
+// metrics: critical and high violations, hosts that stopped reporting or
+// whose scans fail, refused uploads, and the metrics going missing.
+func AlertRules() ([]byte, error) {
+	alert := func(name, expr, forDur, severity, summary, description string) rule {
+		return rule{
+			Alert: name, Expr: expr, For: forDur,
+			Labels:      map[string]string{"severity": severity},
+			Annotations: map[string]string{"summary": summary, "description": description},
+		}
+	}
+	f := ruleFile{Groups: []ruleGroup{{Name: "compliance", Rules: []rule{
+		alert("ComplianceCriticalViolations", fmt.Sprintf(`%s{severity="critical"} > 0`, Violations.Name), "15m", "critical",
+			"{{ $labels.hostname }} has {{ $value }} critical compliance violations",
+			"The newest report from {{ $labels.hostname }} ({{ $labels.platform }}, host ID {{ $labels.host_id }}) has critical violations."),
+		alert("ComplianceHighViolations", fmt.Sprintf(`%s{severity="high"} > 0`, Violations.Name), "1h", "warning",
+			"{{ $labels.hostname }} has {{ $value }} high-severity compliance violations",
+			"The newest report from {{ $labels.hostname }} ({{ $labels.platform }}, host ID {{ $labels.host_id }}) has high-severity violations."),
+		alert("ComplianceHostNotReporting", fmt.Sprintf("time() - %s > %d", LastSeen.Name, notReporting), "15m", "warning",
+			"{{ $labels.hostname }} hasn't reported for over a day",
+			"The fleet server has had no report from {{ $labels.hostname }} (host ID {{ $labels.host_id }}) for {{ $value | humanizeDuration }}. Its agent may be stopped, uninstalled or unable to reach the server."),
+		alert("ComplianceRunErrors", fmt.Sprintf("%s > 0", Errors.Name), "2h", "warning",
+			"{{ $labels.hostname }} reports {{ $value }} run errors",
+			"Parts of the scan on {{ $labels.hostname }} (host ID {{ $labels.host_id }}) failed, so its report is incomplete. The report's errors say which, with their codes."),
+		alert("ComplianceReportsRefused", fmt.Sprintf("sum by (reason) (rate(%s[15m])) > 0", ReportsRejected.Name), "15m", "warning",
+			"The fleet server is refusing reports ({{ $labels.reason }})",
+			"Agents' uploads are being refused as {{ $labels.reason }}; the server logs say which hosts. Their reports are not stored."),
+		alert("ComplianceMetricsAbsent", fmt.Sprintf("absent(%s)", Hosts.Name), "10m", "critical",
+			"No fleet compliance metrics",
+			"Prometheus has no fleet server metrics: the server is down or isn't being scraped, and none of the other compliance alerts can fire."),
+	}}}}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(f); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func marshalIndent(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,94 @@
+// Package metrics names the Prometheus metrics the fleet server exposes
+// on /metrics and writes them in the text exposition format. The Grafana
+// dashboard and alerting rules `assets grafana` ships are generated from
+// the same names, so they can't drift apart.
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Family is a metric name with its help text and type.
+type Family struct {
+	Name, Help, Type string
+}
+
+// The per-host families carry host_id, hostname and platform labels;
+// those about the newest report are only written once a host has one.
+var (
+	Hosts = Family{"compliance_hosts", "Hosts enrolled with the fleet server.", "gauge"}
+	// HostInfo is 1 per host, with its agent_version as a label.
+	HostInfo   = Family{"compliance_host_info", "Enrolled host, with its agent version.", "gauge"}
+	Violations = Family{"compliance_violations", "Violations in the host's newest report, by severity.", "gauge"}
+	Errors     = Family{"compliance_errors", "Run errors in the host's newest report.", "gauge"}
+	MaxRisk    = Family{"compliance_max_risk", "Risk score of the riskiest violation in the host's newest report.", "gauge"}
+	LastReport = Family{"compliance_last_report_timestamp_seconds", "When the host's newest report was generated.", "gauge"}
+	LastSeen   = Family{"compliance_last_seen_timestamp_seconds", "When the host last uploaded a report.", "gauge"}
+
+	ReportsReceived = Family{"compliance_fleet_reports_received_total", "Reports the fleet server stored.", "counter"}
+	// ReportsRejected has a reason label, one of RejectReasons.
+	ReportsRejected = Family{"compliance_fleet_reports_rejected_total", "Reports the fleet server refused, by reason.", "counter"}
+)
+
+// RejectReasons are the reason label values of ReportsRejected.
+var RejectReasons = []string{"too_large", "invalid"}
+
+// Severities are the severity label values of Violations, worst first.
+var Severities = []string{"critical", "high", "medium", "low", "info"}
+
+// Writer writes metrics in the Prometheus text format (version 0.0.4).
+type Writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+// ContentType is the Content-Type of what a Writer writes.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// Family starts f's samples with its HELP and TYPE lines.
+func (w *Writer) Family(f Family) {
+	w.write("# HELP " + f.Name + " " + f.Help + "\n# TYPE " + f.Name + " " + f.Type + "\n")
+}
+
+// Sample writes one sample of f; labels are name, value pairs.
+func (w *Writer) Sample(f Family, value float64, labels ...string) {
+	var b strings.Builder
+	b.WriteString(f.Name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(labels[i] + `="` + escapeLabel(labels[i+1]) + `"`)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteString(" " + strconv.FormatFloat(value, 'g', -1, 64) + "\n")
+	w.write(b.String())
+}
+
+// Flush writes out what's buffered, returning the first error.
+func (w *Writer) Flush() error {
+	if w.err == nil {
+		w.err = w.w.Flush()
+	}
+	return w.err
+}
+
+func (w *Writer) write(s string) {
+	if w.err == nil {
+		_, w.err = w.w.WriteString(s)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string { return labelEscaper.Replace(v) }
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestWriter(t *testing.T) {
+	var b strings.Builder
+	w := NewWriter(&b)
+	w.Family(Violations)
+	w.Sample(Violations, 3, "hostname", `web "1"`+"\n\\", "severity", "high")
+	w.Sample(Hosts, 1.5)
+	require.NoError(t, w.Flush())
+	assert.Equal(t, `# HELP compliance_violations Violations in the host's newest report, by severity.
+# TYPE compliance_violations gauge
+compliance_violations{hostname="web \"1\"\n\\",severity="high"} 3
+compliance_hosts 1.5
+`, b.String())
+}
+
+// The shipped files are what `assets grafana` writes.
+func TestAssets_Published(t *testing.T) {
+	for name, gen := range map[string]func() ([]byte, error){DashboardFile: Dashboard, AlertRulesFile: AlertRules} {
+		b, err := gen()
+		require.NoError(t, err)
+		published, err := os.ReadFile(filepath.Join("..", "assets", "grafana", name))
+		require.NoError(t, err)
+		assert.Equal(t, string(b), string(published), "assets/grafana/%s is stale: go run . assets grafana -o assets/grafana", name)
+	}
+}
+
+var metricName = regexp.MustCompile(`\bcompliance_[a-z_]+`)
+
+// metricsUsed checks every metric an expression names is one the server
+// writes.
+func metricsUsed(t *testing.T, expr string) {
+	t.Helper()
+	known := map[string]bool{}
+	for _, f := range []Family{Hosts, HostInfo, Violations, Errors, MaxRisk, LastReport, LastSeen, ReportsReceived, ReportsRejected} {
+		known[f.Name] = true
+	}
+	names := metricName.FindAllString(expr, -1)
+	assert.NotEmpty(t, names, expr)
+	for _, n := range names {
+		assert.True(t, known[n], "%s in %s", n, expr)
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	b, err := Dashboard()
+	require.NoError(t, err)
+	var d struct {
+		UID    string `json:"uid"`
+		Panels []struct {
+			ID      int    `json:"id"`
+			Title   string `json:"title"`
+			GridPos struct{ H, W, X, Y int }
+			Targets []struct {
+				RefID string `json:"refId"`
+				Expr  string `json:"expr"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}
+	require.NoError(t, json.Unmarshal(b, &d))
+	assert.Equal(t, "compliance-fleet", d.UID)
+	require.Len(t, d.Panels, 8)
+	for i, p := range d.Panels {
+		assert.Equal(t, i+1, p.ID)
+		assert.LessOrEqual(t, p.GridPos.X+p.GridPos.W, 24, p.Title)
+		require.NotEmpty(t, p.Targets, p.Title)
+		for _, tg := range p.Targets {
+			metricsUsed(t, tg.Expr)
+		}
+	}
+	assert.Equal(t, `count(compliance_violations{severity="critical",platform=~"$platform"} > 0) or vector(0)`, d.Panels[2].Targets[0].Expr)
+	assert.Equal(t, "B", d.Panels[5].Targets[1].RefID)
+}
+
+func TestAlertRules(t *testing.T) {
+	b, err := AlertRules()
+	require.NoError(t, err)
+	var f ruleFile
+	require.NoError(t, yaml.Unmarshal(b, &f))
+	require.Len(t, f.Groups, 1)
+	seen := map[string]bool{}
+	for _, r := range f.Groups[0].Rules {
+		assert.False(t, seen[r.Alert], "duplicate %s", r.Alert)
+		seen[r.Alert] = true
+		metricsUsed(t, r.Expr)
+		assert.Contains(t, []string{"critical", "warning"}, r.Labels["severity"], r.Alert)
+		assert.NotEmpty(t, r.Annotations["summary"], r.Alert)
+		assert.NotEmpty(t, r.For, r.Alert)
+	}
+	assert.True(t, seen["ComplianceCriticalViolations"])
+	assert.True(t, seen["ComplianceHostNotReporting"])
+}
@@ -5,8 +5,9 @@ package mode
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"time"
 
@@ -14,9 +15,16 @@ import (
 	"compliance-agent/collector"
 	"compliance-agent/config"
 	"compliance-agent/exporter"
+	"compliance-agent/guard"
+	"compliance-agent/logging"
 	"compliance-agent/ml"
 )
 
+// logger is streaming mode's log.
+func logger() *slog.Logger {
+	return logging.Component("streaming")
+}
+
 // Runner is the dependency surface streaming mode talks through. main()
 // builds the concrete impl; tests can swap in fakes.
 type Runner struct {
@@ -37,7 +45,7 @@ func RunStreaming(ctx context.Context, r Runner) error {
 
 	// First snapshot immediately so we don't wait an interval to bootstrap.
 	if err := r.once(ctx); err != nil {
-		log.Printf("streaming: initial tick failed: %v", err)
+		logger().Warn("initial tick failed", "err", err)
 	}
 
 	for {
@@ -46,7 +54,7 @@ func RunStreaming(ctx context.Context, r Runner) error {
 			return ctx.Err()
 		case <-tick.C:
 			if err := r.once(ctx); err != nil {
-				log.Printf("streaming: tick failed: %v", err)
+				logger().Warn("tick failed", "err", err)
 			}
 		}
 	}
@@ -54,24 +62,41 @@ func RunStreaming(ctx context.Context, r Runner) error {
 
 func (r Runner) once(ctx context.Context) error {
 	hostname, _ := os.Hostname()
-	users, err := r.Collector.CollectUsers()
-	if err != nil {
+	// A panicking collector must not kill the daemon; it's logged and
+	// recorded alongside the snapshot, and the tick carries on.
+	var rec guard.Recorder
+	var users []collector.User
+	var procs []collector.Process
+	var ports []collector.PortBinding
+	var pkgs []collector.Package
+	if err := rec.Run("collect", "users", func() (err error) {
+		users, err = r.Collector.CollectUsers(ctx)
+		return err
+	}); err != nil && !errors.Is(err, guard.ErrPanic) {
 		return fmt.Errorf("users: %w", err)
 	}
-	procs, err := r.Collector.CollectProcesses(50)
-	if err != nil {
+	if err := rec.Run("collect", "processes", func() (err error) {
+		procs, err = r.Collector.CollectProcesses(ctx, 50)
+		return err
+	}); err != nil && !errors.Is(err, guard.ErrPanic) {
 		return fmt.Errorf("procs: %w", err)
 	}
-	ports, _ := r.Collector.CollectOpenPorts()
-	pkgs, _ := r.Collector.CollectPackages(200)
+	_ = rec.Run("collect", "ports", func() (err error) {
+		ports, err = r.Collector.CollectOpenPorts(ctx)
+		return err
+	})
+	_ = rec.Run("collect", "packages", func() (err error) {
+		pkgs, err = r.Collector.CollectPackages(ctx, 200)
+		return err
+	})
 
-	snap := baseline.SnapshotFromCollected(hostname, procs, ports, users, pkgs)
+	snap := baseline.SnapshotFromCollected(hostname, procs, collector.Ports(ports), users, pkgs)
 	r.Baseline.Update(snap)
 
 	feats := ml.BuildFeatures(snap, r.Baseline.Data())
 	score, model, scoreErr := r.Scorer.Score(ctx, feats)
 	if scoreErr != nil {
-		log.Printf("score failed (%s): %v", model, scoreErr)
+		logger().Warn("ml score failed", "model", model, "err", scoreErr)
 	}
 
 	out := map[string]any{
@@ -82,6 +107,9 @@ func (r Runner) once(ctx context.Context) error {
 		"anomaly":   score >= r.Cfg.ML.Threshold,
 		"timestamp": snap.CollectedAt,
 	}
+	if errs := rec.Errors(); len(errs) > 0 {
+		out["errors"] = errs
+	}
 
 	if r.Exporter != nil {
 		b, _ := json.Marshal(out)
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"compliance-agent/alerting"
+	"compliance-agent/config"
+	"compliance-agent/errcode"
+	"compliance-agent/sink"
+)
+
+// destination is an alerter or sink `notify test` can exercise.
+type destination struct {
+	name string
+	kind string // "alerter" | "sink"
+	// err is set when the destination couldn't be built from config.
+	err  error
+	test func(ctx context.Context, hostname string) error
+}
+
+// cmdNotify is `notify test [destination|all]`: it sends a harmless test
+// message to each configured alerter and sink and prints how each went,
+// to check routing config after changing it.
+func cmdNotify(args []string) {
+	if len(args) == 0 || args[0] != "test" {
+		fmt.Fprintf(os.Stderr, "Usage: %s notify test [-config path] [destination|all]\n", os.Args[0])
+		usageError("notify: want test")
+	}
+	fs := flag.NewFlagSet("notify test", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to YAML config (optional)")
+	timeout := fs.Duration("timeout", 30*time.Second, "Give up on a sink after this long")
+	parseFlags(fs, args[1:])
+	target := "all"
+	if fs.NArg() > 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s notify test [-config path] [destination|all]\n", os.Args[0])
+		usageError("notify test: one destination at most")
+	}
+	if fs.NArg() == 1 {
+		target = fs.Arg(0)
+	}
+
+	cfg := loadConfig(*configPath)
+	dests, err := destinations(cfg, target)
+	if err != nil {
+		fatal(errcode.New(errcode.Usage, err), "notify test")
+	}
+	if len(dests) == 0 {
+		fatal(errcode.Errorf(errcode.NotConfigured, "no destinations configured: set alerting.enabled or sinks.enabled"), "notify test")
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+	hostname, _ := os.Hostname()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DESTINATION\tKIND\tRESULT\tLATENCY\tDETAIL")
+	failed := 0
+	for _, d := range dests {
+		start := time.Now()
+		err := d.err
+		if err == nil {
+			tctx, tcancel := context.WithTimeout(ctx, *timeout)
+			err = d.test(tctx, hostname)
+			tcancel()
+		}
+		latency := time.Since(start).Round(time.Millisecond)
+		if err != nil {
+			failed++
+			fmt.Fprintf(tw, "%s\t%s\tFAILED\t%s\t%s\n", d.name, d.kind, latency, oneLine(err))
+		} else {
+			fmt.Fprintf(tw, "%s\t%s\tok\t%s\t\n", d.name, d.kind, latency)
+		}
+		_ = tw.Flush()
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d destinations failed\n", failed, len(dests))
+		os.Exit(errcode.NotifierFailure.ExitCode())
+	}
+}
+
+// destinations builds what target names: every enabled alerter and
+// sink for "all", else the one alerter or sink of that name, enabled or
+// not, so a destination can be tried before it is switched on. Dedup is
+// off: a test must really send.
+func destinations(cfg config.Config, target string) ([]destination, error) {
+	alerterNames := cfg.Alerting.Enabled
+	sinkNames := cfg.Sinks.Enabled
+	if target != "all" {
+		alerterNames, sinkNames = nil, nil
+		isDestination := slices.ContainsFunc(cfg.Alerting.Destinations, func(d config.DestinationConfig) bool { return d.Name == target })
+		if isDestination || slices.Contains(alerting.Registered(), target) {
+			alerterNames = []string{target}
+		}
+		if slices.Contains(sink.Registered(), target) {
+			sinkNames = []string{target}
+		}
+		if alerterNames == nil && sinkNames == nil {
+			known := slices.Concat(alerting.Registered(), sink.Registered())
+			slices.Sort(known)
+			return nil, fmt.Errorf("unknown destination %q (known: all, %s)", target, strings.Join(known, ", "))
+		}
+	}
+
+	var out []destination
+	for _, name := range alerterNames {
+		acfg := alertingConfig(cfg)
+		acfg.Enabled = []string{name}
+		acfg.Dedup.Window = 0
+		d := destination{name: name, kind: "alerter"}
+		alerters, err := alerting.Build(acfg)
+		if err != nil {
+			d.err = err
+		} else {
+			a := alerters[0]
+			d.test = func(_ context.Context, hostname string) error { return alerting.SendTest(a, hostname) }
+		}
+		out = append(out, d)
+	}
+	for _, name := range sinkNames {
+		scfg := cfg.Sinks
+		scfg.Enabled = []string{name}
+		d := destination{name: name, kind: "sink"}
+		sinks, err := sink.Build(scfg)
+		if err != nil {
+			d.err = err
+		} else {
+			d.test = sinks[0].Test
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// oneLine keeps a multi-line error on its table row.
+func oneLine(err error) string {
+	return strings.Join(strings.Fields(err.Error()), " ")
+}
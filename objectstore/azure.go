@@ -0,0 +1,170 @@
+package objectstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// azureVersion is the Blob service REST API version requests ask for.
+const azureVersion = "2021-08-06"
+
+// azureStore reads and writes block blobs, authorized with the account's
+// shared key or a SAS token.
+type azureStore struct {
+	client  *http.Client
+	url     string
+	base    string // the container's URL, with a trailing slash
+	prefix  string
+	account string
+	key     []byte // shared key; nil with a SAS
+	sas     string // query string, without the "?"
+}
+
+// newAzure opens azblob://container/prefix. Credentials come from
+// AZURE_STORAGE_CONNECTION_STRING, or AZURE_STORAGE_ACCOUNT with
+// AZURE_STORAGE_KEY or AZURE_STORAGE_SAS_TOKEN; the URL's ?account=
+// overrides the account. A connection string's BlobEndpoint points at
+// another endpoint, such as Azurite.
+func newAzure(u *url.URL, client *http.Client) (*azureStore, error) {
+	s := &azureStore{
+		client: client,
+		url:    "azblob://" + u.Host + "/" + prefix(u),
+		prefix: prefix(u),
+	}
+	var endpoint, key string
+	if cs := os.Getenv("AZURE_STORAGE_CONNECTION_STRING"); cs != "" {
+		fields := map[string]string{}
+		for _, part := range strings.Split(cs, ";") {
+			if k, v, ok := strings.Cut(part, "="); ok {
+				fields[k] = v
+			}
+		}
+		s.account, key, s.sas, endpoint = fields["AccountName"], fields["AccountKey"], fields["SharedAccessSignature"], fields["BlobEndpoint"]
+	}
+	if s.account == "" {
+		s.account = os.Getenv("AZURE_STORAGE_ACCOUNT")
+	}
+	if a := u.Query().Get("account"); a != "" {
+		s.account = a
+	}
+	if key == "" && s.sas == "" {
+		key, s.sas = os.Getenv("AZURE_STORAGE_KEY"), os.Getenv("AZURE_STORAGE_SAS_TOKEN")
+	}
+	s.sas = strings.TrimPrefix(s.sas, "?")
+	if s.account == "" && endpoint == "" {
+		return nil, errors.New("azblob: no storage account (set AZURE_STORAGE_ACCOUNT or ?account=)")
+	}
+	if key != "" && s.sas == "" {
+		b, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("azblob: account key: %w", err)
+		}
+		s.key = b
+	}
+	if s.key != nil && s.account == "" {
+		return nil, errors.New("azblob: a shared key needs the account name (AccountName, AZURE_STORAGE_ACCOUNT or ?account=)")
+	}
+	if s.key == nil && s.sas == "" {
+		return nil, errors.New("azblob: no credentials (set AZURE_STORAGE_KEY, AZURE_STORAGE_SAS_TOKEN or AZURE_STORAGE_CONNECTION_STRING)")
+	}
+	if endpoint == "" {
+		endpoint = "https://" + s.account + ".blob.core.windows.net"
+	}
+	s.base = strings.TrimSuffix(endpoint, "/") + "/" + u.Host + "/"
+	return s, nil
+}
+
+func (s *azureStore) URL() string { return s.url }
+
+func (s *azureStore) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	u := s.base + escapeKey(s.prefix+key)
+	if s.sas != "" {
+		u += "?" + s.sas
+	}
+	req, err := newRequest(ctx, http.MethodPut, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	req.Header.Set("X-Ms-Version", azureVersion)
+	req.Header.Set("X-Ms-Date", time.Now().UTC().Format(http.TimeFormat))
+	if s.key != nil {
+		req.Header.Set("Authorization", "SharedKey "+s.account+":"+s.signature(req))
+	}
+	_, err = do(s.client, req)
+	return err
+}
+
+func (s *azureStore) Get(ctx context.Context, key, etag string) ([]byte, string, error) {
+	u := s.base + escapeKey(s.prefix+key)
+	if s.sas != "" {
+		u += "?" + s.sas
+	}
+	req, err := newRequest(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	req.Header.Set("X-Ms-Version", azureVersion)
+	req.Header.Set("X-Ms-Date", time.Now().UTC().Format(http.TimeFormat))
+	if s.key != nil {
+		req.Header.Set("Authorization", "SharedKey "+s.account+":"+s.signature(req))
+	}
+	return get(s.client, req)
+}
+
+// signature is the Shared Key signature of req.
+func (s *azureStore) signature(req *http.Request) string {
+	length := ""
+	if req.ContentLength > 0 {
+		length = strconv.FormatInt(req.ContentLength, 10)
+	}
+	h := req.Header
+	var b strings.Builder
+	for _, v := range []string{
+		req.Method, h.Get("Content-Encoding"), h.Get("Content-Language"), length,
+		h.Get("Content-MD5"), h.Get("Content-Type"), h.Get("Date"), h.Get("If-Modified-Since"),
+		h.Get("If-Match"), h.Get("If-None-Match"), h.Get("If-Unmodified-Since"), h.Get("Range"),
+	} {
+		b.WriteString(v + "\n")
+	}
+	var names []string
+	for k := range h {
+		if k = strings.ToLower(k); strings.HasPrefix(k, "x-ms-") {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		b.WriteString(k + ":" + strings.TrimSpace(h.Get(k)) + "\n")
+	}
+	b.WriteString("/" + s.account + req.URL.EscapedPath())
+	q := req.URL.Query()
+	params := make([]string, 0, len(q))
+	for k := range q {
+		params = append(params, k)
+	}
+	sort.Strings(params)
+	for _, k := range params {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		b.WriteString("\n" + strings.ToLower(k) + ":" + strings.Join(vals, ","))
+	}
+	m := hmac.New(sha256.New, s.key)
+	m.Write([]byte(b.String()))
+	return base64.StdEncoding.EncodeToString(m.Sum(nil))
+}
@@ -0,0 +1,238 @@
+package objectstore
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gcsScope is the OAuth scope tokens are requested with.
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// gcsStore writes objects with the JSON API's media upload and reads
+// them with its media download.
+type gcsStore struct {
+	client   *http.Client
+	url      string
+	endpoint string
+	bucket   string
+	prefix   string
+	tokens   *gcsTokens // nil against an emulator
+}
+
+// newGCS opens gs://bucket/prefix. Tokens come from, in order,
+// GOOGLE_OAUTH_ACCESS_TOKEN, the service account key file
+// GOOGLE_APPLICATION_CREDENTIALS names, or the metadata server of the
+// GCE, GKE or Cloud Run instance the agent runs on. STORAGE_EMULATOR_HOST
+// points at an emulator such as fake-gcs-server, without credentials.
+func newGCS(u *url.URL, client *http.Client) (*gcsStore, error) {
+	s := &gcsStore{
+		client:   client,
+		url:      "gs://" + u.Host + "/" + prefix(u),
+		endpoint: "https://storage.googleapis.com",
+		bucket:   u.Host,
+		prefix:   prefix(u),
+	}
+	if emu := os.Getenv("STORAGE_EMULATOR_HOST"); emu != "" {
+		if !strings.Contains(emu, "://") {
+			emu = "http://" + emu
+		}
+		s.endpoint = strings.TrimSuffix(emu, "/")
+		return s, nil
+	}
+	s.tokens = &gcsTokens{client: client, token: os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")}
+	if s.tokens.token != "" {
+		s.tokens.expiry = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+		return s, nil
+	}
+	if path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); path != "" {
+		sa, err := loadServiceAccount(path)
+		if err != nil {
+			return nil, fmt.Errorf("gs: GOOGLE_APPLICATION_CREDENTIALS: %w", err)
+		}
+		s.tokens.account = sa
+	}
+	return s, nil
+}
+
+func (s *gcsStore) URL() string { return s.url }
+
+func (s *gcsStore) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	q := url.Values{"uploadType": {"media"}, "name": {s.prefix + key}}
+	req, err := newRequest(ctx, http.MethodPost,
+		s.endpoint+"/upload/storage/v1/b/"+url.PathEscape(s.bucket)+"/o?"+q.Encode(), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.tokens != nil {
+		token, err := s.tokens.get(ctx)
+		if err != nil {
+			return fmt.Errorf("gs: access token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	_, err = do(s.client, req)
+	return err
+}
+
+func (s *gcsStore) Get(ctx context.Context, key, etag string) ([]byte, string, error) {
+	req, err := newRequest(ctx, http.MethodGet,
+		s.endpoint+"/storage/v1/b/"+url.PathEscape(s.bucket)+"/o/"+url.PathEscape(s.prefix+key)+"?alt=media", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if s.tokens != nil {
+		token, err := s.tokens.get(ctx)
+		if err != nil {
+			return nil, "", fmt.Errorf("gs: access token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return get(s.client, req)
+}
+
+// serviceAccount is the part of a service account key file tokens are
+// minted from.
+type serviceAccount struct {
+	email    string
+	key      *rsa.PrivateKey
+	tokenURI string
+}
+
+func loadServiceAccount(path string) (*serviceAccount, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f struct {
+		Type        string `json:"type"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+	if f.Type != "service_account" {
+		return nil, fmt.Errorf("%s: want a service account key, got type %q", path, f.Type)
+	}
+	block, _ := pem.Decode([]byte(f.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("%s: no private key", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: private key: %w", path, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: private key is not RSA", path)
+	}
+	if f.TokenURI == "" {
+		f.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &serviceAccount{email: f.ClientEmail, key: key, tokenURI: f.TokenURI}, nil
+}
+
+// gcsTokens caches an OAuth access token until shortly before it
+// expires.
+type gcsTokens struct {
+	client  *http.Client
+	account *serviceAccount // nil uses the metadata server
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func (t *gcsTokens) get(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Now().Before(t.expiry) {
+		return t.token, nil
+	}
+	var req *http.Request
+	var err error
+	if t.account != nil {
+		req, err = t.account.tokenRequest(ctx, time.Now())
+	} else {
+		host := os.Getenv("GCE_METADATA_HOST")
+		if host == "" {
+			host = "metadata.google.internal"
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet,
+			"http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+		if req != nil {
+			req.Header.Set("Metadata-Flavor", "Google")
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	b, err := do(t.client, req)
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return "", err
+	}
+	if resp.AccessToken == "" {
+		return "", errors.New("no access_token in the response")
+	}
+	t.token = resp.AccessToken
+	t.expiry = time.Now().Add(time.Duration(resp.ExpiresIn)*time.Second - time.Minute)
+	return t.token, nil
+}
+
+// tokenRequest exchanges a JWT signed with the account's key for an
+// access token (RFC 7523).
+func (a *serviceAccount) tokenRequest(ctx context.Context, now time.Time) (*http.Request, error) {
+	enc := base64.RawURLEncoding
+	claims, err := json.Marshal(map[string]any{
+		"iss":   a.email,
+		"scope": gcsScope,
+		"aud":   a.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	unsigned := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(nil, a.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + enc.EncodeToString(sig)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
@@ -0,0 +1,171 @@
+// Package objectstore reads and writes objects in cloud object storage:
+// Amazon S3 (and S3-compatible stores), Google Cloud Storage and Azure
+// Blob Storage, picked by the URL's scheme. Each backend signs its own
+// requests with the credentials its cloud's tools read from the
+// environment, so no cloud SDK is needed.
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ObjectStore is a bucket (or container) and a key prefix in it.
+type ObjectStore interface {
+	// Put writes body to key under the store's prefix, replacing any
+	// object already there.
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+	// Get reads key under the store's prefix and returns it with its
+	// ETag. With etag set, an object that still has it is
+	// ErrNotModified. A missing object is a *StatusError.
+	Get(ctx context.Context, key, etag string) (body []byte, newETag string, err error)
+	// URL is the store's URL, for logs ("gs://bucket/prefix").
+	URL() string
+}
+
+// Schemes lists the URL schemes Open understands.
+var Schemes = []string{"s3", "gs", "azblob"}
+
+// Open returns the store rawURL names:
+//
+//	s3://bucket/prefix          Amazon S3, or an S3-compatible store
+//	gs://bucket/prefix          Google Cloud Storage
+//	azblob://container/prefix   Azure Blob Storage
+//
+// The prefix is optional. client makes every request, including for
+// credentials; nil uses http.DefaultClient.
+func Open(rawURL string, client *http.Client) (ObjectStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%q has no bucket", rawURL)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	switch u.Scheme {
+	case "s3":
+		return newS3(u, client)
+	case "gs":
+		return newGCS(u, client)
+	case "azblob":
+		return newAzure(u, client)
+	}
+	return nil, fmt.Errorf("%q: want a URL starting %s://", rawURL, strings.Join(Schemes, "://, "))
+}
+
+// ErrNotModified is a conditional Get of an object that hasn't changed.
+var ErrNotModified = errors.New("not modified")
+
+// maxObjectBytes caps an object Get reads.
+const maxObjectBytes = 64 << 20
+
+// StatusError is a request the store answered with a non-2xx status.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return e.Status
+	}
+	return e.Status + ": " + e.Body
+}
+
+// Temporary reports whether the request may succeed if retried as is:
+// 429 and 5xx.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// do sends req and drains the response, turning a non-2xx one into a
+// *StatusError.
+func do(client *http.Client, req *http.Request) ([]byte, error) {
+	req.Header.Set("User-Agent", "compliance-agent")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return io.ReadAll(resp.Body)
+	}
+	return nil, statusError(resp)
+}
+
+// get sends a GET built by newRequest and returns the object and its
+// ETag: ErrNotModified for a 304, a *StatusError for another non-2xx.
+func get(client *http.Client, req *http.Request) ([]byte, string, error) {
+	req.Header.Set("User-Agent", "compliance-agent")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return nil, "", ErrNotModified
+	case resp.StatusCode/100 != 2:
+		return nil, "", statusError(resp)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxObjectBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(b) > maxObjectBytes {
+		return nil, "", fmt.Errorf("object larger than %d bytes", maxObjectBytes)
+	}
+	return b, resp.Header.Get("ETag"), nil
+}
+
+func statusError(resp *http.Response) *StatusError {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(msg))}
+}
+
+func newRequest(ctx context.Context, method, u string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	// An empty PUT still sends Content-Length: 0, which the stores want.
+	req.ContentLength = int64(len(body))
+	return req, nil
+}
+
+// prefix is the URL's path as a key prefix: no leading slash, and a
+// trailing one unless empty.
+func prefix(u *url.URL) string {
+	p := strings.Trim(u.Path, "/")
+	if p == "" {
+		return ""
+	}
+	return p + "/"
+}
+
+// escapeKey percent-encodes each segment of a key, leaving only the
+// unreserved characters, as S3's signatures and Azure's URLs want.
+func escapeKey(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
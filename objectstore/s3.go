@@ -0,0 +1,147 @@
+package objectstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptySHA256 is the SHA-256 of an empty body, the payload hash of a GET.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3Store reads and writes S3 with AWS Signature Version 4 requests,
+// signed with the standard AWS_* environment variables. Without credentials,
+// requests go unsigned, for a public bucket or one that allows anonymous
+// writes.
+type s3Store struct {
+	client       *http.Client
+	url          string
+	base         string // scheme://host/[bucket/] that keys are appended to
+	prefix       string
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// newS3 opens s3://bucket/prefix. The region is the URL's ?region=, else
+// AWS_REGION or AWS_DEFAULT_REGION, else us-east-1. AWS_ENDPOINT_URL_S3
+// (or AWS_ENDPOINT_URL) points at an S3-compatible store such as MinIO,
+// addressed path-style.
+func newS3(u *url.URL, client *http.Client) (*s3Store, error) {
+	s := &s3Store{
+		client:       client,
+		url:          "s3://" + u.Host + "/" + prefix(u),
+		prefix:       prefix(u),
+		region:       u.Query().Get("region"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if s.region == "" {
+			s.region = os.Getenv(env)
+		}
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_S3")
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if endpoint != "" {
+		s.base = strings.TrimSuffix(endpoint, "/") + "/" + u.Host + "/"
+	} else {
+		s.base = "https://" + u.Host + ".s3." + s.region + ".amazonaws.com/"
+	}
+	return s, nil
+}
+
+func (s *s3Store) URL() string { return s.url }
+
+func (s *s3Store) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	req, err := newRequest(ctx, http.MethodPut, s.base+escapeKey(s.prefix+key), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	sum := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(sum[:]), time.Now())
+	_, err = do(s.client, req)
+	return err
+}
+
+func (s *s3Store) Get(ctx context.Context, key, etag string) ([]byte, string, error) {
+	req, err := newRequest(ctx, http.MethodGet, s.base+escapeKey(s.prefix+key), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	s.sign(req, emptySHA256, time.Now())
+	return get(s.client, req)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// sign adds the SigV4 Authorization header for a body with the given
+// SHA-256, signing every header already set plus host.
+func (s *s3Store) sign(req *http.Request, payloadHash string, now time.Time) {
+	if s.accessKey == "" || s.secretKey == "" {
+		return
+	}
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{
+		req.Method, path, req.URL.Query().Encode(), canonHeaders.String(), signed, payloadHash,
+	}, "\n")
+	sum := sha256.Sum256([]byte(canonical))
+	scope := day + "/" + s.region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), day)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/"+scope+
+		", SignedHeaders="+signed+", Signature="+hex.EncodeToString(hmacSHA256(key, toSign)))
+}
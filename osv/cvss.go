@@ -0,0 +1,82 @@
+package osv
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// CVSS3BaseScore computes the base score of a CVSS v3.0/v3.1 vector such
+// as "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", per the v3.1
+// specification section 7.
+func CVSS3BaseScore(vector string) (float64, error) {
+	parts := strings.Split(vector, "/")
+	if len(parts) == 0 || !strings.HasPrefix(parts[0], "CVSS:3.") {
+		return 0, fmt.Errorf("not a CVSS v3 vector: %q", vector)
+	}
+	m := map[string]string{}
+	for _, p := range parts[1:] {
+		k, v, ok := strings.Cut(p, ":")
+		if !ok {
+			return 0, fmt.Errorf("bad CVSS metric %q", p)
+		}
+		m[k] = v
+	}
+	weight := func(metric string, weights map[string]float64) (float64, error) {
+		w, ok := weights[m[metric]]
+		if !ok {
+			return 0, fmt.Errorf("bad or missing CVSS metric %s:%s", metric, m[metric])
+		}
+		return w, nil
+	}
+	changed := m["S"] == "C"
+	if m["S"] != "U" && !changed {
+		return 0, fmt.Errorf("bad or missing CVSS metric S:%s", m["S"])
+	}
+	prWeights := map[string]float64{"N": 0.85, "L": 0.62, "H": 0.27}
+	if changed {
+		prWeights = map[string]float64{"N": 0.85, "L": 0.68, "H": 0.5}
+	}
+	cia := map[string]float64{"H": 0.56, "L": 0.22, "N": 0}
+	var w [7]float64
+	var err error
+	for i, spec := range []struct {
+		metric  string
+		weights map[string]float64
+	}{
+		{"AV", map[string]float64{"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2}},
+		{"AC", map[string]float64{"L": 0.77, "H": 0.44}},
+		{"PR", prWeights},
+		{"UI", map[string]float64{"N": 0.85, "R": 0.62}},
+		{"C", cia},
+		{"I", cia},
+		{"A", cia},
+	} {
+		if w[i], err = weight(spec.metric, spec.weights); err != nil {
+			return 0, err
+		}
+	}
+	iss := 1 - (1-w[4])*(1-w[5])*(1-w[6])
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, nil
+	}
+	exploitability := 8.22 * w[0] * w[1] * w[2] * w[3]
+	if changed {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), nil
+	}
+	return roundUp(math.Min(impact+exploitability, 10)), nil
+}
+
+// roundUp is the specification's Roundup: the smallest one-decimal
+// number not below x, computed in integers to avoid float artifacts.
+func roundUp(x float64) float64 {
+	i := int64(math.Round(x * 100000))
+	if i%10000 == 0 {
+		return float64(i) / 100000
+	}
+	return float64(i/10000+1) / 10
+}
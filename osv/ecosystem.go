@@ -0,0 +1,84 @@
+package osv
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"compliance-agent/collector"
+)
+
+// Ecosystems maps the collector's package sources onto OSV ecosystems for
+// this host, based on /etc/os-release. When override is set it is used
+// for every OS package source instead (e.g. "Debian:12" for a derivative
+// distribution). Sources OSV doesn't cover, like Homebrew and Windows
+// programs, are absent.
+func Ecosystems(override string) map[string]string {
+	b, _ := os.ReadFile("/etc/os-release")
+	return EcosystemsFor(override, string(b))
+}
+
+// EcosystemsFor is Ecosystems for the system whose os-release is
+// osRelease, e.g. a container image's.
+func EcosystemsFor(override, osRelease string) map[string]string {
+	eco := override
+	if eco == "" {
+		rel := parseOSRelease(osRelease)
+		eco = distroEcosystem(rel["ID"], rel["VERSION_ID"])
+	}
+	if eco == "" {
+		return map[string]string{}
+	}
+	return map[string]string{"deb": eco, "dpkg": eco, "rpm": eco}
+}
+
+// parseOSRelease reads the KEY=value lines of os-release(5).
+func parseOSRelease(s string) map[string]string {
+	out := map[string]string{}
+	sc := bufio.NewScanner(strings.NewReader(s))
+	for sc.Scan() {
+		k, v, ok := strings.Cut(strings.TrimSpace(sc.Text()), "=")
+		if ok && !strings.HasPrefix(k, "#") {
+			out[k] = strings.Trim(v, `"'`)
+		}
+	}
+	return out
+}
+
+// distroEcosystem names the OSV ecosystem of a distribution release, or
+// "" when OSV doesn't track it.
+func distroEcosystem(id, version string) string {
+	major, _, _ := strings.Cut(version, ".")
+	if major == "" {
+		return "" // rolling releases like Debian sid
+	}
+	switch id {
+	case "debian":
+		return "Debian:" + major
+	case "ubuntu":
+		// LTS releases are the even-year April ones.
+		if yy, mm, ok := strings.Cut(version, "."); ok && mm == "04" && len(yy) == 2 && (yy[1]-'0')%2 == 0 {
+			return "Ubuntu:" + version + ":LTS"
+		}
+		return "Ubuntu:" + version
+	case "almalinux":
+		return "AlmaLinux:" + major
+	case "rocky":
+		return "Rocky Linux:" + major
+	}
+	return ""
+}
+
+// Queries turns collected packages into OSV queries, skipping packages
+// whose source has no ecosystem.
+func Queries(pkgs []collector.Package, ecosystems map[string]string) []Query {
+	var out []Query
+	for _, p := range pkgs {
+		eco, ok := ecosystems[p.Source]
+		if !ok || p.Name == "" || p.Version == "" {
+			continue
+		}
+		out = append(out, Query{Ecosystem: eco, Name: p.Name, Version: p.Version})
+	}
+	return out
+}
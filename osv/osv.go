@@ -0,0 +1,418 @@
+// Package osv looks up known vulnerabilities for installed packages in
+// the OSV database (https://osv.dev). Queries are batched, and both the
+// per-package answers and the vulnerability records are cached on disk so
+// a daemon doesn't re-send its whole package list every interval.
+//
+// OSV keys OS packages by distribution release ("Debian:12",
+// "Ubuntu:22.04:LTS"), so only package sources with a known ecosystem are
+// checked; see Ecosystems.
+package osv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultURL is the public OSV API.
+const DefaultURL = "https://api.osv.dev"
+
+// batchSize is the most queries OSV accepts in one querybatch call.
+const batchSize = 1000
+
+// Query identifies one installed package version.
+type Query struct {
+	Ecosystem string
+	Name      string
+	Version   string
+}
+
+func (q Query) key() string {
+	return q.Ecosystem + "|" + q.Name + "|" + q.Version
+}
+
+// Finding is one vulnerability affecting one installed package.
+type Finding struct {
+	Package   string `json:"package"`
+	Version   string `json:"version"`
+	Ecosystem string `json:"ecosystem"`
+	// ID is the OSV record ID (GHSA-..., DSA-..., USN-..., CVE-...).
+	ID string `json:"id"`
+	// Aliases are the same issue under other IDs, typically the CVE.
+	Aliases []string `json:"aliases,omitempty"`
+	Summary string   `json:"summary,omitempty"`
+	// Severity is critical, high, medium or low; empty when the record
+	// doesn't rate it.
+	Severity string `json:"severity,omitempty"`
+	// Score is the CVSS v3 base score when the record has a vector.
+	Score float64 `json:"score,omitempty"`
+	// Fixed is the first fixed version for this package, if known.
+	Fixed string `json:"fixed,omitempty"`
+}
+
+// Client queries OSV through a disk cache.
+type Client struct {
+	url       string
+	http      *http.Client
+	cachePath string
+	ttl       time.Duration
+
+	mu    sync.Mutex
+	cache *cacheFile
+}
+
+// NewClient returns a client for the OSV API at baseURL (DefaultURL when
+// empty). An empty cachePath disables the disk cache; ttl defaults to a
+// day.
+func NewClient(baseURL, cachePath string, ttl time.Duration) *Client {
+	if baseURL == "" {
+		baseURL = DefaultURL
+	}
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &Client{
+		url:       strings.TrimSuffix(baseURL, "/"),
+		http:      &http.Client{Timeout: 30 * time.Second},
+		cachePath: cachePath,
+		ttl:       ttl,
+	}
+}
+
+// Scan returns the vulnerabilities affecting the queried packages. Cached
+// answers younger than the TTL are reused; the rest are fetched in
+// batches. A lookup that fails for some vulnerability records still
+// returns the findings it could resolve, along with the error.
+func (c *Client) Scan(ctx context.Context, queries []Query) ([]Finding, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cache == nil {
+		c.cache = loadCache(c.cachePath)
+	}
+	now := time.Now()
+
+	var stale []Query
+	seen := map[string]bool{}
+	for _, q := range queries {
+		if seen[q.key()] {
+			continue
+		}
+		seen[q.key()] = true
+		if e, ok := c.cache.Queries[q.key()]; !ok || now.Sub(e.At) > c.ttl {
+			stale = append(stale, q)
+		}
+	}
+	for start := 0; start < len(stale); start += batchSize {
+		batch := stale[start:min(start+batchSize, len(stale))]
+		ids, err := c.queryBatch(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for i, q := range batch {
+			c.cache.Queries[q.key()] = queryEntry{IDs: ids[i], At: now}
+		}
+	}
+
+	var findings []Finding
+	var errs []error
+	reported := map[string]bool{}
+	for _, q := range queries {
+		if reported[q.key()] {
+			continue
+		}
+		reported[q.key()] = true
+		for _, id := range c.cache.Queries[q.key()].IDs {
+			rec, err := c.record(ctx, id, now)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			findings = append(findings, rec.finding(q))
+		}
+	}
+	c.cache.prune(now, c.ttl)
+	if err := c.cache.save(c.cachePath); err != nil {
+		errs = append(errs, err)
+	}
+	return findings, errors.Join(errs...)
+}
+
+type batchRequest struct {
+	Queries []batchQuery `json:"queries"`
+}
+
+type batchQuery struct {
+	Package struct {
+		Name      string `json:"name"`
+		Ecosystem string `json:"ecosystem"`
+	} `json:"package"`
+	Version string `json:"version"`
+}
+
+type batchResponse struct {
+	Results []struct {
+		Vulns []struct {
+			ID string `json:"id"`
+		} `json:"vulns"`
+	} `json:"results"`
+}
+
+// queryBatch asks which vulnerabilities affect each query, returning the
+// IDs per query in order.
+func (c *Client) queryBatch(ctx context.Context, queries []Query) ([][]string, error) {
+	req := batchRequest{Queries: make([]batchQuery, len(queries))}
+	for i, q := range queries {
+		req.Queries[i].Package.Name = q.Name
+		req.Queries[i].Package.Ecosystem = q.Ecosystem
+		req.Queries[i].Version = q.Version
+	}
+	var resp batchResponse
+	if err := c.do(ctx, http.MethodPost, "/v1/querybatch", req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(queries) {
+		return nil, fmt.Errorf("osv querybatch: %d results for %d queries", len(resp.Results), len(queries))
+	}
+	out := make([][]string, len(queries))
+	for i, r := range resp.Results {
+		for _, v := range r.Vulns {
+			out[i] = append(out[i], v.ID)
+		}
+	}
+	return out, nil
+}
+
+// record returns the summarized vulnerability record, from the cache when
+// fresh.
+func (c *Client) record(ctx context.Context, id string, now time.Time) (record, error) {
+	if e, ok := c.cache.Vulns[id]; ok && now.Sub(e.At) <= c.ttl {
+		return e.Record, nil
+	}
+	var v vuln
+	if err := c.do(ctx, http.MethodGet, "/v1/vulns/"+url.PathEscape(id), nil, &v); err != nil {
+		return record{}, err
+	}
+	rec := v.summarize()
+	c.cache.Vulns[id] = vulnEntry{Record: rec, At: now}
+	return rec, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("osv: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("osv %s: %s: %s", path, resp.Status, strings.TrimSpace(string(b)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// vuln is the part of an OSV record the agent uses.
+type vuln struct {
+	ID       string   `json:"id"`
+	Aliases  []string `json:"aliases"`
+	Summary  string   `json:"summary"`
+	Details  string   `json:"details"`
+	Severity []struct {
+		Type  string `json:"type"`
+		Score string `json:"score"`
+	} `json:"severity"`
+	Affected []struct {
+		Package struct {
+			Name string `json:"name"`
+		} `json:"package"`
+		Ranges []struct {
+			Events []map[string]string `json:"events"`
+		} `json:"ranges"`
+		DatabaseSpecific map[string]any `json:"database_specific"`
+	} `json:"affected"`
+	// DatabaseSpecific is free-form per database; GHSA puts its
+	// severity rating there.
+	DatabaseSpecific map[string]any `json:"database_specific"`
+}
+
+// record is a vulnerability reduced to what findings need; it's what the
+// cache stores.
+type record struct {
+	ID       string            `json:"id"`
+	Aliases  []string          `json:"aliases,omitempty"`
+	Summary  string            `json:"summary,omitempty"`
+	Severity string            `json:"severity,omitempty"`
+	Score    float64           `json:"score,omitempty"`
+	Fixed    map[string]string `json:"fixed,omitempty"`
+}
+
+func (v vuln) summarize() record {
+	rec := record{ID: v.ID, Aliases: v.Aliases, Summary: v.Summary, Fixed: map[string]string{}}
+	if rec.Summary == "" {
+		rec.Summary, _, _ = strings.Cut(strings.TrimSpace(v.Details), "\n")
+	}
+	rated := ""
+	for _, s := range v.Severity {
+		switch {
+		case strings.HasPrefix(s.Score, "CVSS:3."):
+			if score, err := CVSS3BaseScore(s.Score); err == nil && score > rec.Score {
+				rec.Score = score
+			}
+		case s.Type == "Ubuntu":
+			rated = s.Score
+		}
+	}
+	if sev, ok := v.DatabaseSpecific["severity"].(string); ok && sev != "" {
+		rated = sev
+	}
+	for _, a := range v.Affected {
+		if sev, ok := a.DatabaseSpecific["severity"].(string); ok && rated == "" {
+			rated = sev
+		}
+		for _, r := range a.Ranges {
+			for _, e := range r.Events {
+				if f := e["fixed"]; f != "" {
+					rec.Fixed[a.Package.Name] = f
+				}
+			}
+		}
+	}
+	if rec.Score > 0 {
+		rec.Severity = severityForScore(rec.Score)
+	} else {
+		rec.Severity = normalizeSeverity(rated)
+	}
+	return rec
+}
+
+func (r record) finding(q Query) Finding {
+	return Finding{
+		Package:   q.Name,
+		Version:   q.Version,
+		Ecosystem: q.Ecosystem,
+		ID:        r.ID,
+		Aliases:   r.Aliases,
+		Summary:   r.Summary,
+		Severity:  r.Severity,
+		Score:     r.Score,
+		Fixed:     r.Fixed[q.Name],
+	}
+}
+
+func severityForScore(s float64) string {
+	switch {
+	case s >= 9:
+		return "critical"
+	case s >= 7:
+		return "high"
+	case s >= 4:
+		return "medium"
+	}
+	return "low"
+}
+
+// normalizeSeverity maps the ratings databases use (GHSA's MODERATE,
+// Ubuntu's negligible) onto the agent's names.
+func normalizeSeverity(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "critical":
+		return "critical"
+	case "high", "important":
+		return "high"
+	case "moderate", "medium":
+		return "medium"
+	case "low", "negligible":
+		return "low"
+	}
+	return ""
+}
+
+type cacheFile struct {
+	Queries map[string]queryEntry `json:"queries"`
+	Vulns   map[string]vulnEntry  `json:"vulns"`
+}
+
+type queryEntry struct {
+	IDs []string  `json:"ids,omitempty"`
+	At  time.Time `json:"at"`
+}
+
+type vulnEntry struct {
+	Record record    `json:"record"`
+	At     time.Time `json:"at"`
+}
+
+// loadCache reads the cache file; a missing or unreadable one starts
+// empty, since everything in it can be fetched again.
+func loadCache(path string) *cacheFile {
+	c := &cacheFile{}
+	if path != "" {
+		if b, err := os.ReadFile(path); err == nil {
+			_ = json.Unmarshal(b, c)
+		}
+	}
+	if c.Queries == nil {
+		c.Queries = map[string]queryEntry{}
+	}
+	if c.Vulns == nil {
+		c.Vulns = map[string]vulnEntry{}
+	}
+	return c
+}
+
+// prune drops expired entries, so packages removed from the host don't
+// stay in the cache forever.
+func (c *cacheFile) prune(now time.Time, ttl time.Duration) {
+	for k, e := range c.Queries {
+		if now.Sub(e.At) > ttl {
+			delete(c.Queries, k)
+		}
+	}
+	for k, e := range c.Vulns {
+		if now.Sub(e.At) > ttl {
+			delete(c.Vulns, k)
+		}
+	}
+}
+
+func (c *cacheFile) save(path string) error {
+	if path == "" {
+		return nil
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
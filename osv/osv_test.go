@@ -0,0 +1,113 @@
+package osv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"compliance-agent/collector"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCVSS3BaseScore(t *testing.T) {
+	cases := map[string]float64{
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H": 9.8,
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H": 10.0,
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N": 6.1,
+		"CVSS:3.1/AV:L/AC:L/PR:L/UI:N/S:U/C:H/I:N/A:N": 5.5,
+		"CVSS:3.0/AV:N/AC:H/PR:N/UI:N/S:U/C:N/I:N/A:N": 0,
+	}
+	for vector, want := range cases {
+		got, err := CVSS3BaseScore(vector)
+		require.NoError(t, err, vector)
+		assert.Equal(t, want, got, vector)
+	}
+	for _, bad := range []string{"CVSS:4.0/AV:N", "CVSS:3.1/AV:X/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", "CVSS:3.1/AV:N"} {
+		_, err := CVSS3BaseScore(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestDistroEcosystem(t *testing.T) {
+	assert.Equal(t, "Ubuntu:22.04:LTS", distroEcosystem("ubuntu", "22.04"))
+	assert.Equal(t, "Ubuntu:23.10", distroEcosystem("ubuntu", "23.10"))
+	assert.Equal(t, "Debian:12", distroEcosystem("debian", "12"))
+	assert.Equal(t, "Rocky Linux:9", distroEcosystem("rocky", "9.3"))
+	assert.Equal(t, "", distroEcosystem("debian", ""))
+	assert.Equal(t, "", distroEcosystem("arch", ""))
+
+	rel := parseOSRelease("NAME=\"Ubuntu\"\nID=ubuntu\nVERSION_ID=\"22.04\"\n")
+	assert.Equal(t, "22.04", rel["VERSION_ID"])
+
+	q := Queries([]collector.Package{
+		{Name: "openssl", Version: "3.0.2-0ubuntu1.10", Source: "deb"},
+		{Name: "wget", Version: "1.21.4", Source: "homebrew"},
+	}, map[string]string{"deb": "Ubuntu:22.04:LTS"})
+	assert.Equal(t, []Query{{Ecosystem: "Ubuntu:22.04:LTS", Name: "openssl", Version: "3.0.2-0ubuntu1.10"}}, q)
+}
+
+func TestClientScan(t *testing.T) {
+	var batches, lookups atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/querybatch":
+			batches.Add(1)
+			var req batchRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			resp := map[string]any{}
+			var results []any
+			for _, q := range req.Queries {
+				if q.Package.Name == "openssl" {
+					results = append(results, map[string]any{"vulns": []any{map[string]string{"id": "USN-6119-1"}, map[string]string{"id": "GHSA-xxxx-yyyy-zzzz"}}})
+				} else {
+					results = append(results, map[string]any{})
+				}
+			}
+			resp["results"] = results
+			_ = json.NewEncoder(w).Encode(resp)
+		case "/v1/vulns/USN-6119-1":
+			lookups.Add(1)
+			_, _ = w.Write([]byte(`{"id":"USN-6119-1","aliases":["CVE-2023-2650"],"details":"OpenSSL could be made to crash.\nMore text.",
+				"severity":[{"type":"Ubuntu","score":"medium"},{"type":"CVSS_V3","score":"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:H"}],
+				"affected":[{"package":{"ecosystem":"Ubuntu:22.04:LTS","name":"openssl"},"ranges":[{"type":"ECOSYSTEM","events":[{"introduced":"0"},{"fixed":"3.0.2-0ubuntu1.10"}]}]}]}`))
+		case "/v1/vulns/GHSA-xxxx-yyyy-zzzz":
+			lookups.Add(1)
+			_, _ = w.Write([]byte(`{"id":"GHSA-xxxx-yyyy-zzzz","summary":"Moderate thing","database_specific":{"severity":"MODERATE","cwe_ids":["CWE-20"]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cache := filepath.Join(t.TempDir(), "osv.json")
+	queries := []Query{
+		{Ecosystem: "Ubuntu:22.04:LTS", Name: "openssl", Version: "3.0.2-0ubuntu1.9"},
+		{Ecosystem: "Ubuntu:22.04:LTS", Name: "openssl", Version: "3.0.2-0ubuntu1.9"},
+		{Ecosystem: "Ubuntu:22.04:LTS", Name: "bash", Version: "5.1-6ubuntu1"},
+	}
+	got, err := NewClient(srv.URL, cache, time.Hour).Scan(context.Background(), queries)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, Finding{
+		Package: "openssl", Version: "3.0.2-0ubuntu1.9", Ecosystem: "Ubuntu:22.04:LTS",
+		ID: "USN-6119-1", Aliases: []string{"CVE-2023-2650"}, Summary: "OpenSSL could be made to crash.",
+		Severity: "high", Score: 7.5, Fixed: "3.0.2-0ubuntu1.10",
+	}, got[0])
+	assert.Equal(t, "medium", got[1].Severity)
+	assert.Equal(t, int32(1), batches.Load())
+	assert.Equal(t, int32(2), lookups.Load())
+
+	// A fresh client reads the disk cache instead of calling OSV again.
+	again, err := NewClient(srv.URL, cache, time.Hour).Scan(context.Background(), queries)
+	require.NoError(t, err)
+	assert.Equal(t, got, again)
+	assert.Equal(t, int32(1), batches.Load())
+	assert.Equal(t, int32(2), lookups.Load())
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"compliance-agent/config"
+	"compliance-agent/errcode"
+	"compliance-agent/policydist"
+	"compliance-agent/release"
+)
+
+const packageUsage = `Usage:
+  %[1]s package keygen -o name                  write name.key and name.pub
+  %[1]s package sign -key name.key binary...    sign release binaries (to binary.sig)
+  %[1]s package verify [-key name.pub] [-sig sig] [binary]
+
+verify checks this binary, or the one given, against the release keys
+built in with packaging/build.sh, or against -key. See packaging/.
+`
+
+// cmdPackage implements `compliance-agent package`: the signing keys and
+// signatures of the release binaries packaging/build.sh builds.
+func cmdPackage(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, packageUsage, os.Args[0])
+		usageError("package: want a subcommand")
+	}
+	switch args[0] {
+	case "keygen":
+		packageKeygen(args[1:])
+	case "sign":
+		packageSign(args[1:])
+	case "verify":
+		packageVerify(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, packageUsage, os.Args[0])
+		usageError("package: unknown subcommand %q", args[0])
+	}
+}
+
+func packageKeygen(args []string) {
+	fs := flag.NewFlagSet("package keygen", flag.ContinueOnError)
+	out := fs.String("o", "release-signing", "Write the key pair to this name plus .key and .pub")
+	parseFlags(fs, args)
+	pubPEM, err := policydist.WriteKeyPair(*out)
+	if err != nil {
+		fatal(err, "keygen")
+	}
+	pub, err := policydist.ParsePublicKey(string(pubPEM))
+	if err != nil {
+		fatal(err, "keygen")
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s.key (the RELEASE_SIGNING_KEY secret) and %s.pub\n", *out, *out)
+	fmt.Fprintf(os.Stderr, "RELEASE_PUBLIC_KEY=%s\n", base64.StdEncoding.EncodeToString(pub))
+}
+
+func packageSign(args []string) {
+	fs := flag.NewFlagSet("package sign", flag.ContinueOnError)
+	keyPath := fs.String("key", "", "Private key from package keygen (required)")
+	parseFlags(fs, args)
+	if *keyPath == "" || fs.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, packageUsage, os.Args[0])
+		usageError("package sign: want -key and a binary")
+	}
+	key, err := policydist.LoadPrivateKey(*keyPath)
+	if err != nil {
+		fatal(err, "sign")
+	}
+	for _, bin := range fs.Args() {
+		sig, err := release.Sign(key, bin)
+		if err != nil {
+			fatal(err, "sign")
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", sig)
+	}
+}
+
+func packageVerify(args []string) {
+	fs := flag.NewFlagSet("package verify", flag.ContinueOnError)
+	keyPath := fs.String("key", "", "Public key, as a file or base64 (default the release keys built in)")
+	sigPath := fs.String("sig", "", "Signature file (default <binary>.sig)")
+	parseFlags(fs, args)
+	if fs.NArg() > 1 {
+		fmt.Fprintf(os.Stderr, packageUsage, os.Args[0])
+		usageError("package verify: one binary at most")
+	}
+	var keys []ed25519.PublicKey
+	var err error
+	if *keyPath != "" {
+		keys, err = policydist.ParsePublicKeys([]string{*keyPath})
+	} else {
+		keys, err = release.Keys()
+	}
+	if errors.Is(err, release.ErrNoKey) {
+		usageError("verify: this build has no release key; give one with -key")
+	}
+	if err != nil {
+		fatal(err, "verify")
+	}
+	path := fs.Arg(0)
+	if path == "" {
+		if path, err = release.Executable(); err != nil {
+			fatal(err, "verify")
+		}
+	}
+	if err := release.Verify(keys, path, *sigPath); err != nil {
+		fmt.Printf("FAIL %s: %v\n", path, err)
+		os.Exit(errcode.CheckFailed.ExitCode())
+	}
+	fmt.Printf("OK %s\n", path)
+}
+
+// checkReleaseSignature checks the running binary's release signature
+// at startup, as cfg says. Builds without a release key skip it.
+func checkReleaseSignature(cfg config.ReleaseConfig) {
+	switch cfg.VerifySignature {
+	case "off":
+		return
+	case "", "warn", "enforce":
+	default:
+		fatal(errcode.Errorf(errcode.ParseError, "release.verify_signature: want warn, enforce or off, got %q", cfg.VerifySignature), "config")
+	}
+	_, err := release.VerifySelf()
+	switch {
+	case err == nil, errors.Is(err, release.ErrNoKey):
+	case cfg.VerifySignature == "enforce":
+		fatal(fmt.Errorf("%w (release.verify_signature is enforce)", err), "release signature")
+	default:
+		slog.Warn("release signature check failed", "err", err)
+	}
+}
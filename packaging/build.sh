@@ -0,0 +1,153 @@
+#!/usr/bin/env bash
+# Builds, signs and packages a compliance-agent release into dist/.
+#
+#   packaging/build.sh [stage...]
+#
+# Stages, run in the order given (default: binaries deb rpm checksums):
+#
+#   binaries   cross-build every target with the version and release key
+#              linked in, sign each binary (<binary>.sig) and archive it
+#   deb, rpm   Linux packages with nfpm (needs binaries)
+#   pkg        the macOS installer with pkgbuild; run on macOS (needs binaries)
+#   msi        the Windows installer with WiX v4; run on Windows (needs binaries)
+#   checksums  SHA256SUMS over dist/, signed as SHA256SUMS.sig
+#
+# Environment:
+#
+#   VERSION              release version (default: git describe)
+#   RELEASE_SIGNING_KEY  private key file from `compliance-agent package keygen`
+#   RELEASE_PUBLIC_KEY   its public key, base64 as keygen prints it; several
+#                        comma-separated while a key is rotated
+#   NFPM_SIGNING_KEY_FILE, NFPM_PASSPHRASE
+#                        GPG key for the .deb/.rpm signatures (optional)
+#   MACOS_APP_IDENTITY, MACOS_INSTALLER_IDENTITY
+#                        Developer ID identities for codesign and productsign (optional)
+#   WINDOWS_SIGN_CERT, WINDOWS_SIGN_PASSWORD
+#                        PFX for signtool's Authenticode signatures (optional)
+#
+# Without RELEASE_SIGNING_KEY the binaries are unsigned and don't check
+# themselves; that is for local testing only.
+set -euo pipefail
+
+cd "$(dirname "$0")/.."
+VERSION=${VERSION:-$(git describe --tags --always --dirty)}
+DIST=dist
+TARGETS=(linux/amd64 linux/arm64 darwin/amd64 darwin/arm64 windows/amd64)
+
+# tool is this host's build of the agent, for `package sign`.
+tool=$DIST/.tool/compliance-agent$(go env GOEXE)
+
+die() {
+	echo "build.sh: $*" >&2
+	exit 1
+}
+
+# sign writes <file>.sig with the release key, when there is one.
+sign() {
+	if [ -n "${RELEASE_SIGNING_KEY:-}" ]; then
+		"$tool" package sign -key "$RELEASE_SIGNING_KEY" "$@"
+	fi
+}
+
+# config writes the default config for a platform to $1, with the Linux
+# state and config directories replaced by $2.
+config() {
+	mkdir -p "$1"
+	sed -e "s#/var/lib/compliance-agent#$2#g" -e "s#/etc/compliance-agent#$2#g" configs/agent.yaml >"$1/agent.yaml"
+	cp configs/policy.yaml "$1/policy.yaml"
+}
+
+binaries() {
+	if [ -n "${RELEASE_SIGNING_KEY:-}" ] && [ -z "${RELEASE_PUBLIC_KEY:-}" ]; then
+		die "RELEASE_SIGNING_KEY is set but RELEASE_PUBLIC_KEY isn't"
+	fi
+	local ldflags="-s -w -X compliance-agent/buildinfo.Version=$VERSION -X compliance-agent/buildinfo.ReleaseKey=${RELEASE_PUBLIC_KEY:-}"
+	for target in "${TARGETS[@]}"; do
+		local goos=${target%/*} goarch=${target#*/} exe=compliance-agent
+		[ "$goos" = windows ] && exe=compliance-agent.exe
+		local out=$DIST/bin/$goos-$goarch
+		echo "==> $target"
+		mkdir -p "$out"
+		CGO_ENABLED=0 GOOS=$goos GOARCH=$goarch go build -trimpath -ldflags "$ldflags" -o "$out/$exe" .
+		sign "$out/$exe"
+		tar -czf "$DIST/compliance-agent-$VERSION-$goos-$goarch.tar.gz" -C "$out" .
+	done
+}
+
+linux_package() {
+	command -v nfpm >/dev/null || die "nfpm not found (go install github.com/goreleaser/nfpm/v2/cmd/nfpm@latest)"
+	for arch in amd64 arm64; do
+		[ -f "$DIST/bin/linux-$arch/compliance-agent" ] || die "no linux/$arch binary; run the binaries stage first"
+		echo "==> $1 $arch"
+		ARCH=$arch VERSION=${VERSION#v} BIN_DIR=$DIST/bin/linux-$arch NFPM_SIGNING_KEY_FILE=${NFPM_SIGNING_KEY_FILE:-} \
+			nfpm package -f packaging/nfpm.yaml -p "$1" -t "$DIST/"
+	done
+}
+
+pkg() {
+	[ "$(uname -s)" = Darwin ] || die "pkg builds on macOS"
+	local root=$DIST/pkg/root libexec=$DIST/pkg/root/usr/local/libexec/compliance-agent
+	rm -rf "$DIST/pkg"
+	mkdir -p "$libexec" "$root/Library/LaunchDaemons"
+	# One universal binary, signed after codesign changes it.
+	lipo -create -output "$libexec/compliance-agent" "$DIST"/bin/darwin-{amd64,arm64}/compliance-agent
+	if [ -n "${MACOS_APP_IDENTITY:-}" ]; then
+		codesign --force --options runtime --timestamp --sign "$MACOS_APP_IDENTITY" "$libexec/compliance-agent"
+	fi
+	sign "$libexec/compliance-agent"
+	cp packaging/darwin/io.github.jayy-77.compliance-agent.plist "$root/Library/LaunchDaemons/"
+	config "$root/usr/local/share/compliance-agent" "/Library/Application Support/compliance-agent"
+
+	local version=${VERSION#v} out=$DIST/compliance-agent-$VERSION-darwin.pkg
+	pkgbuild --root "$root" --scripts packaging/darwin/scripts --identifier io.github.jayy-77.compliance-agent \
+		--version "${version%%-*}" --install-location / "$DIST/pkg/component.pkg"
+	productbuild --package "$DIST/pkg/component.pkg" "$out.unsigned"
+	if [ -n "${MACOS_INSTALLER_IDENTITY:-}" ]; then
+		productsign --sign "$MACOS_INSTALLER_IDENTITY" "$out.unsigned" "$out"
+		rm "$out.unsigned"
+	else
+		mv "$out.unsigned" "$out"
+	fi
+}
+
+# authenticode signs Windows files with signtool, when there is a
+# certificate.
+authenticode() {
+	if [ -n "${WINDOWS_SIGN_CERT:-}" ]; then
+		signtool sign /fd SHA256 /tr http://timestamp.digicert.com /td SHA256 \
+			/f "$WINDOWS_SIGN_CERT" /p "${WINDOWS_SIGN_PASSWORD:-}" "$@"
+	fi
+}
+
+msi() {
+	command -v wix >/dev/null || die "wix not found (dotnet tool install --global wix)"
+	local bin=$DIST/bin/windows-amd64
+	[ -f "$bin/compliance-agent.exe" ] || die "no windows/amd64 binary; run the binaries stage first"
+	# Authenticode changes the file, so the release signature comes after.
+	authenticode "$bin/compliance-agent.exe"
+	sign "$bin/compliance-agent.exe"
+	config "$DIST/msi/config" "C:/ProgramData/compliance-agent"
+
+	# MSI versions are numeric: 1.2.3 from v1.2.3-rc1.
+	local version=${VERSION#v} out=$DIST/compliance-agent-$VERSION-windows-amd64.msi
+	wix build -arch x64 -d Version="${version%%-*}" -d BinDir="$bin" -d ConfigDir="$DIST/msi/config" \
+		-o "$out" packaging/windows/compliance-agent.wxs
+	authenticode "$out"
+}
+
+checksums() {
+	(cd "$DIST" && find . -maxdepth 1 -type f \( -name '*.tar.gz' -o -name '*.deb' -o -name '*.rpm' -o -name '*.pkg' -o -name '*.msi' \) |
+		sed 's#^\./##' | sort | xargs sha256sum >SHA256SUMS)
+	sign "$DIST/SHA256SUMS"
+}
+
+mkdir -p "$DIST/.tool"
+go build -o "$tool" .
+[ $# -gt 0 ] || set -- binaries deb rpm checksums
+for stage in "$@"; do
+	case $stage in
+	binaries | pkg | msi | checksums) "$stage" ;;
+	deb | rpm) linux_package "$stage" ;;
+	*) die "unknown stage $stage" ;;
+	esac
+done
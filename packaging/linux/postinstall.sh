@@ -0,0 +1,11 @@
+#!/bin/sh
+# Enable and (re)start the agent once the package is in place. Not on
+# hosts without systemd, such as containers.
+set -e
+mkdir -p /var/lib/compliance-agent
+chmod 0750 /var/lib/compliance-agent
+if [ -d /run/systemd/system ]; then
+	systemctl daemon-reload
+	systemctl enable compliance-agent.service
+	systemctl restart compliance-agent.service
+fi
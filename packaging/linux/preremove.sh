@@ -0,0 +1,12 @@
+#!/bin/sh
+# Stop the agent when the package is removed, but not on an upgrade:
+# deb passes "remove" only on removal, rpm the count of versions left
+# installed, 0 on removal.
+set -e
+case "$1" in
+remove | 0) ;;
+*) exit 0 ;;
+esac
+if [ -d /run/systemd/system ]; then
+	systemctl disable --now compliance-agent.service || true
+fi
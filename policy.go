@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/errcode"
+	"compliance-agent/policydist"
+	"compliance-agent/preview"
+	"compliance-agent/report"
+	"compliance-agent/storage"
+)
+
+const policyUsage = `Usage:
+  %[1]s policy keygen -o name                     write name.key and name.pub
+  %[1]s policy sign -key name.key [-o sig] file   sign a policy (default file.sig)
+  %[1]s policy verify -key name.pub [-sig sig] file
+  %[1]s policy preview (-against report.json | -fleet [-host h]) [-current old.yaml] [-json] new.yaml
+
+preview re-analyzes a report, or with -fleet every host's newest report in
+the fleet server's database (server.db_path), under the proposed policy and
+lists the violations it would add and remove. They are compared with those
+the report has, or with a re-analysis under -current.
+`
+
+// cmdPolicy implements `compliance-agent policy`: the signing keys and
+// signatures for policies agents fetch from the fleet server or a
+// policy_source URL, and the preview of a policy change.
+func cmdPolicy(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, policyUsage, os.Args[0])
+		usageError("policy: want a subcommand")
+	}
+	switch args[0] {
+	case "keygen":
+		policyKeygen(args[1:])
+	case "sign":
+		policySign(args[1:])
+	case "verify":
+		policyVerify(args[1:])
+	case "preview":
+		policyPreview(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, policyUsage, os.Args[0])
+		usageError("policy: unknown subcommand %q", args[0])
+	}
+}
+
+func policyKeygen(args []string) {
+	fs := flag.NewFlagSet("policy keygen", flag.ContinueOnError)
+	out := fs.String("o", "policy-signing", "Write the key pair to this name plus .key and .pub")
+	parseFlags(fs, args)
+	if _, err := policydist.WriteKeyPair(*out); err != nil {
+		fatal(err, "keygen")
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s.key (keep it off the agents) and %s.pub (for policy_source.public_keys)\n", *out, *out)
+}
+
+func policySign(args []string) {
+	fs := flag.NewFlagSet("policy sign", flag.ContinueOnError)
+	keyPath := fs.String("key", "", "Private key from policy keygen (required)")
+	out := fs.String("o", "", "Write the signature here (default <policy>.sig)")
+	parseFlags(fs, args)
+	if *keyPath == "" || fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, policyUsage, os.Args[0])
+		usageError("policy sign: want -key and one policy")
+	}
+	path := fs.Arg(0)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		fatal(err, "sign")
+	}
+	// Refuse to vouch for a policy agents would reject anyway.
+	if _, err := analyzer.ParsePolicies(b); err != nil {
+		fatal(err, "sign: "+path)
+	}
+	key, err := policydist.LoadPrivateKey(*keyPath)
+	if err != nil {
+		fatal(err, "sign")
+	}
+	if *out == "" {
+		*out = path + ".sig"
+	}
+	if err := os.WriteFile(*out, []byte(policydist.Sign(key, b)+"\n"), 0o644); err != nil {
+		fatal(err, "sign")
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", *out)
+}
+
+func policyVerify(args []string) {
+	fs := flag.NewFlagSet("policy verify", flag.ContinueOnError)
+	keyPath := fs.String("key", "", "Public key, as a file or base64 (required)")
+	sigPath := fs.String("sig", "", "Signature file (default <policy>.sig)")
+	parseFlags(fs, args)
+	if *keyPath == "" || fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, policyUsage, os.Args[0])
+		usageError("policy verify: want -key and one policy")
+	}
+	path := fs.Arg(0)
+	if *sigPath == "" {
+		*sigPath = path + ".sig"
+	}
+	keys, err := policydist.ParsePublicKeys([]string{*keyPath})
+	if err != nil {
+		fatal(err, "verify")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		fatal(err, "verify")
+	}
+	sig, err := os.ReadFile(*sigPath)
+	if err != nil {
+		fatal(err, "verify")
+	}
+	if err := policydist.Verify(keys, b, sig); err != nil {
+		fmt.Printf("FAIL %s: %v\n", path, err)
+		os.Exit(errcode.CheckFailed.ExitCode())
+	}
+	fmt.Printf("OK %s\n", path)
+}
+
+func policyPreview(args []string) {
+	fs := flag.NewFlagSet("policy preview", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to YAML config (optional; for risk scoring and server.db_path)")
+	against := fs.String("against", "", "Report to preview the change on")
+	fleet := fs.Bool("fleet", false, "Preview it on every host's newest report in the fleet server's database")
+	dbPath := fs.String("db", "", "Fleet database to read (overrides server.db_path)")
+	host := fs.String("host", "", "With -fleet, only this host (an agent ID or hostname)")
+	current := fs.String("current", "", "Policy in force to compare with (default: the violations the report has)")
+	profile := addProfileFlag(fs)
+	asJSON := fs.Bool("json", false, "Print the preview as JSON")
+	// Flags may follow the proposed policy, as in `preview new.yaml -against r.json`.
+	var files []string
+	for {
+		parseFlags(fs, args)
+		if fs.NArg() == 0 {
+			break
+		}
+		files, args = append(files, fs.Arg(0)), fs.Args()[1:]
+	}
+	if len(files) != 1 || (*against == "") == !*fleet {
+		fmt.Fprintf(os.Stderr, policyUsage, os.Args[0])
+		usageError("policy preview: want one policy and either -against or -fleet")
+	}
+
+	cfg := loadConfig(*configPath)
+	risk, err := riskModel(cfg.Risk)
+	if err != nil {
+		fatal(errcode.New(errcode.ParseError, err), "config")
+	}
+	proposed := withProfiles(loadPolicies(files[0]), *profile)
+	var inForce *analyzer.Policies
+	if *current != "" {
+		p := withProfiles(loadPolicies(*current), *profile)
+		inForce = &p
+	}
+	// hostPreview re-analyzes a copy of rep under each policy; rep keeps
+	// the violations it was stored with.
+	hostPreview := func(rep report.ComplianceReport) preview.Host {
+		if rep.Anonymized {
+			return preview.Host{Hostname: rep.Hostname, GeneratedAt: rep.GeneratedAt, Skipped: "the report is anonymized and has no inventory"}
+		}
+		before, after := rep, rep
+		if inForce != nil {
+			analyze(&before, *inForce, risk, nil)
+		}
+		analyze(&after, proposed, risk, nil)
+		return preview.Compare(before, after)
+	}
+
+	res := preview.Result{Hosts: []preview.Host{}}
+	if *fleet {
+		path := cfg.Server.DBPath
+		if *dbPath != "" {
+			path = *dbPath
+		}
+		if _, err := os.Stat(path); err != nil {
+			fatal(errcode.Errorf(errcode.CollectorUnavailable, "no fleet database at %s: %w", path, err), "policy")
+		}
+		store, err := storage.OpenFleet(path)
+		if err != nil {
+			fatal(err, "policy")
+		}
+		defer store.Close()
+		var agents []storage.Agent
+		if *host != "" {
+			agents = []storage.Agent{findAgent(store, *host, path)}
+		} else if agents, err = store.Agents(); err != nil {
+			store.Close()
+			fatal(err, "read fleet")
+		}
+		for _, a := range agents {
+			if a.Latest == nil {
+				continue
+			}
+			rep, _, ok, err := store.Report(a.Latest.ID)
+			if err == nil && !ok {
+				err = fmt.Errorf("no such report")
+			}
+			if err != nil {
+				store.Close()
+				fatal(err, fmt.Sprintf("read %s's report %d", a.Hostname, a.Latest.ID))
+			}
+			h := hostPreview(rep)
+			h.AgentID = a.ID
+			res.Add(h)
+		}
+		res.Sort()
+	} else {
+		res.Add(hostPreview(readReport(*against)))
+	}
+
+	if *asJSON {
+		b, _ := json.MarshalIndent(res, "", "  ")
+		fmt.Println(string(b))
+		return
+	}
+	fmt.Print(res.Text())
+}
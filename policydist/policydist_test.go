@@ -0,0 +1,152 @@
+package policydist
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"compliance-agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	privPEM, pubPEM, err := GenerateKey()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	keyPath, pubPath := filepath.Join(dir, "signing.key"), filepath.Join(dir, "signing.pub")
+	require.NoError(t, os.WriteFile(keyPath, privPEM, 0o600))
+	require.NoError(t, os.WriteFile(pubPath, pubPEM, 0o644))
+
+	priv, err := LoadPrivateKey(keyPath)
+	require.NoError(t, err)
+	policy := []byte("allowed_users: [root]\n")
+	sig := Sign(priv, policy)
+
+	raw := priv.Public().(ed25519.PublicKey)
+	for name, k := range map[string]string{
+		"file":       pubPath,
+		"pem":        string(pubPEM),
+		"raw base64": base64.StdEncoding.EncodeToString(raw),
+	} {
+		pub, err := ParsePublicKey(k)
+		require.NoError(t, err, name)
+		assert.Equal(t, raw, pub, name)
+	}
+
+	_, other, err := GenerateKey()
+	require.NoError(t, err)
+	keys, err := ParsePublicKeys([]string{string(other), pubPath})
+	require.NoError(t, err)
+	assert.NoError(t, Verify(keys, policy, []byte(sig+"\n")), "any key will do")
+	rawSig, _ := base64.StdEncoding.DecodeString(sig)
+	assert.NoError(t, Verify(keys, policy, rawSig), "raw signatures as openssl writes them")
+	assert.ErrorContains(t, Verify(keys, []byte("allowed_users: [root, eve]\n"), []byte(sig)), "matches none")
+	assert.ErrorIs(t, Verify(keys, policy, nil), ErrUnsigned)
+	assert.Error(t, Verify(keys, policy, []byte("not a signature")))
+
+	_, err = ParsePublicKeys([]string{"/nonexistent.pub"})
+	assert.ErrorContains(t, err, "public_keys[0]")
+}
+
+func TestWriteKeyPair(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "signing")
+	pubPEM, err := WriteKeyPair(name)
+	require.NoError(t, err)
+
+	priv, err := LoadPrivateKey(name + ".key")
+	require.NoError(t, err)
+	pub, err := ParsePublicKey(string(pubPEM))
+	require.NoError(t, err)
+	assert.Equal(t, priv.Public(), pub)
+	onDisk, err := os.ReadFile(name + ".pub")
+	require.NoError(t, err)
+	assert.Equal(t, pubPEM, onDisk)
+	fi, err := os.Stat(name + ".key")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	// A second run must not replace the key.
+	_, err = WriteKeyPair(name)
+	assert.ErrorIs(t, err, os.ErrExist)
+}
+
+func TestOpenObject(t *testing.T) {
+	t.Setenv("AWS_ENDPOINT_URL_S3", "")
+	t.Setenv("AWS_ENDPOINT_URL", "")
+	u, _ := url.Parse("s3://policies/fleet/policy v2.yaml?region=eu-west-1")
+	o, err := openObject(u, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "s3://policies/fleet/", o.store.URL())
+	assert.Equal(t, "policy v2.yaml", o.key)
+
+	u, _ = url.Parse("s3://policies")
+	_, err = openObject(u, nil)
+	assert.ErrorContains(t, err, "want s3://bucket/key")
+}
+
+func TestSource_Fetch(t *testing.T) {
+	privPEM, pubPEM, err := GenerateKey()
+	require.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "k")
+	require.NoError(t, os.WriteFile(keyPath, privPEM, 0o600))
+	priv, err := LoadPrivateKey(keyPath)
+	require.NoError(t, err)
+
+	policy := "allowed_users: [root]\n"
+	var fetches int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bucket/policy.yaml":
+			assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			fetches++
+			_, _ = w.Write([]byte(policy))
+		case "/bucket/policy.yaml.sig":
+			_, _ = w.Write([]byte(Sign(priv, []byte(policy))))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("AWS_ENDPOINT_URL_S3", srv.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	src, err := NewSource(config.PolicySourceConfig{URL: "s3://bucket/policy.yaml", Timeout: 5 * time.Second})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		b, sig, ok, err := src.Fetch(context.Background())
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, policy, string(b))
+		keys, err := ParsePublicKeys([]string{string(pubPEM)})
+		require.NoError(t, err)
+		assert.NoError(t, Verify(keys, b, sig))
+	}
+	assert.Equal(t, 1, fetches, "the second fetch is a 304")
+
+	missing, err := NewSource(config.PolicySourceConfig{URL: "s3://bucket/none.yaml"})
+	require.NoError(t, err)
+	_, _, ok, err := missing.Fetch(context.Background())
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = NewSource(config.PolicySourceConfig{URL: "http://mirror/policy.yaml"})
+	assert.ErrorContains(t, err, "public_keys")
+	_, err = NewSource(config.PolicySourceConfig{URL: "ftp://mirror/policy.yaml"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,176 @@
+// Package policydist distributes policies to agents: Ed25519 signatures
+// over a policy file, and fetching a signed policy from an HTTPS or S3
+// URL.
+//
+// A signature is the raw 64-byte Ed25519 signature over the policy
+// file's bytes, stored base64-encoded (or raw, as `openssl pkeyutl
+// -sign -rawin` writes it) next to the policy as <policy>.sig. Keys are
+// PEM, PKCS #8 for the private key and PKIX for the public one, the
+// formats `openssl genpkey -algorithm ed25519` writes.
+package policydist
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrUnsigned is returned by Verify for a policy with no signature.
+var ErrUnsigned = errors.New("policy is not signed")
+
+// GenerateKey makes a signing key pair, both PEM.
+func GenerateKey() (privPEM, pubPEM []byte, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), nil
+}
+
+// WriteKeyPair generates a key pair and writes it to name.key (0600)
+// and name.pub, returning the public key PEM. It refuses to replace an
+// existing name.key: overwriting a signing key would orphan everything
+// it signed.
+func WriteKeyPair(name string) (pubPEM []byte, err error) {
+	privPEM, pubPEM, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(name+".key", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(privPEM); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(name+".pub", pubPEM, 0o644); err != nil {
+		return nil, err
+	}
+	return pubPEM, nil
+}
+
+// LoadPrivateKey reads a PEM PKCS #8 Ed25519 private key.
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s: not a PEM private key", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 key", path)
+	}
+	return priv, nil
+}
+
+// ParsePublicKey reads a public key given as PEM, as the base64 of the
+// raw 32-byte key or of its PKIX encoding, or as the path of a PEM file.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	var der []byte
+	switch {
+	case strings.HasPrefix(s, "-----BEGIN"):
+		block, _ := pem.Decode([]byte(s))
+		if block == nil {
+			return nil, errors.New("public key: bad PEM")
+		}
+		der = block.Bytes
+	default:
+		if raw, err := base64.StdEncoding.DecodeString(s); err == nil {
+			if len(raw) == ed25519.PublicKeySize {
+				return ed25519.PublicKey(raw), nil
+			}
+			der = raw
+			break
+		}
+		b, err := os.ReadFile(s)
+		if err != nil {
+			return nil, fmt.Errorf("public key: %w", err)
+		}
+		return ParsePublicKey(string(b))
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("public key: %w", err)
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("public key: not an Ed25519 key")
+	}
+	return pub, nil
+}
+
+// ParsePublicKeys reads each of keys with ParsePublicKey.
+func ParsePublicKeys(keys []string) ([]ed25519.PublicKey, error) {
+	out := make([]ed25519.PublicKey, 0, len(keys))
+	for i, k := range keys {
+		pub, err := ParsePublicKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("public_keys[%d]: %w", i, err)
+		}
+		out = append(out, pub)
+	}
+	return out, nil
+}
+
+// Sign signs a policy, returning the base64 signature.
+func Sign(key ed25519.PrivateKey, policy []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, policy))
+}
+
+// DecodeSignature reads a signature file: base64, or the raw 64 bytes.
+func DecodeSignature(b []byte) ([]byte, error) {
+	if len(b) == ed25519.SignatureSize {
+		return b, nil
+	}
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(b)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return nil, errors.New("signature: want 64 bytes, raw or base64")
+	}
+	return sig, nil
+}
+
+// Verify checks that sig (as in a signature file) signs policy under one
+// of keys.
+func Verify(keys []ed25519.PublicKey, policy, sig []byte) error {
+	if len(bytes.TrimSpace(sig)) == 0 {
+		return ErrUnsigned
+	}
+	raw, err := DecodeSignature(sig)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if ed25519.Verify(k, policy, raw) {
+			return nil
+		}
+	}
+	return errors.New("policy signature matches none of the public keys")
+}
@@ -0,0 +1,176 @@
+package policydist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+
+	"compliance-agent/config"
+	"compliance-agent/objectstore"
+)
+
+// maxPolicyBytes caps a fetched policy or signature.
+const maxPolicyBytes = 8 << 20
+
+// Source fetches a policy and its signature from an HTTPS URL or an
+// object store (s3://, gs://, azblob://). The policy is fetched
+// conditionally (ETag), so an unchanged one costs a 304; the signature
+// is fetched again whenever the policy is.
+type Source struct {
+	client *http.Client
+	policy object
+	sig    object
+
+	etag     string
+	cache    []byte
+	cacheSig []byte
+}
+
+// object is where a policy or signature file is: an http(s) URL, or a
+// key in an object store.
+type object struct {
+	url   string
+	store objectstore.ObjectStore // nil for an http(s) URL
+	key   string
+}
+
+// NewSource builds a source from cfg. URL must be set.
+func NewSource(cfg config.PolicySourceConfig) (*Source, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("policy_source.url: %w", err)
+	}
+	s := &Source{client: &http.Client{Timeout: cfg.Timeout}}
+	switch {
+	case u.Scheme == "https":
+		s.policy = object{url: cfg.URL}
+	case u.Scheme == "http":
+		// Plain HTTP is only for a local mirror; the signature is what
+		// makes it safe, so it is required.
+		if len(cfg.PublicKeys) == 0 {
+			return nil, errors.New("policy_source.url: http:// needs public_keys to verify the policy")
+		}
+		s.policy = object{url: cfg.URL}
+	case slices.Contains(objectstore.Schemes, u.Scheme):
+		if s.policy, err = openObject(u, s.client); err != nil {
+			return nil, fmt.Errorf("policy_source.url: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("policy_source.url: want https://, s3://, gs:// or azblob://, got %q", cfg.URL)
+	}
+	s.sig = object{url: s.policy.url + ".sig", store: s.policy.store, key: s.policy.key + ".sig"}
+	if cfg.SignatureURL != "" {
+		su, err := url.Parse(cfg.SignatureURL)
+		if err != nil {
+			return nil, fmt.Errorf("policy_source.signature_url: %w", err)
+		}
+		switch {
+		case su.Scheme == u.Scheme && s.policy.store != nil:
+			if s.sig, err = openObject(su, s.client); err != nil {
+				return nil, fmt.Errorf("policy_source.signature_url: %w", err)
+			}
+		case su.Scheme == "https" || su.Scheme == "http":
+			s.sig = object{url: cfg.SignatureURL}
+		default:
+			return nil, fmt.Errorf("policy_source.signature_url: want the scheme of url, got %q", cfg.SignatureURL)
+		}
+	}
+	return s, nil
+}
+
+// openObject opens the store holding scheme://bucket/key, with the
+// key's directory as the store's prefix.
+func openObject(u *url.URL, client *http.Client) (object, error) {
+	dir, key := path.Split(u.Path)
+	if u.Host == "" || key == "" {
+		return object{}, fmt.Errorf("want %s://bucket/key", u.Scheme)
+	}
+	su := *u
+	su.Path = dir
+	store, err := objectstore.Open(su.String(), client)
+	if err != nil {
+		return object{}, err
+	}
+	return object{url: u.String(), store: store, key: key}, nil
+}
+
+// URL is where the policy comes from, for logs.
+func (s *Source) URL() string { return s.policy.url }
+
+// Fetch returns the policy and its signature file's contents (nil when
+// there is none). ok is false when there is no policy at the URL.
+func (s *Source) Fetch(ctx context.Context) (policy, sig []byte, ok bool, err error) {
+	policy, etag, err := s.get(ctx, s.policy, s.etag)
+	var status *objectstore.StatusError
+	switch {
+	case errors.Is(err, objectstore.ErrNotModified) && s.cache != nil:
+		return s.cache, s.cacheSig, true, nil
+	case errors.As(err, &status) && status.StatusCode == http.StatusNotFound:
+		return nil, nil, false, nil
+	case err != nil:
+		return nil, nil, false, fmt.Errorf("policy: %s: %w", s.policy.url, err)
+	}
+
+	sig, _, err = s.get(ctx, s.sig, "")
+	switch {
+	case errors.As(err, &status) && (status.StatusCode == http.StatusNotFound || status.StatusCode == http.StatusForbidden):
+		// S3 answers 403 for a missing object the caller may not list.
+		sig = nil
+	case err != nil:
+		return nil, nil, false, fmt.Errorf("policy signature: %s: %w", s.sig.url, err)
+	}
+	s.etag, s.cache, s.cacheSig = etag, policy, sig
+	return policy, sig, true, nil
+}
+
+// get reads o, conditionally on etag when set. Plain URLs fail the way
+// stores do: objectstore.ErrNotModified for a 304, an
+// *objectstore.StatusError for another non-2xx.
+func (s *Source) get(ctx context.Context, o object, etag string) ([]byte, string, error) {
+	if o.store != nil {
+		b, newETag, err := o.store.Get(ctx, o.key, etag)
+		if err == nil && len(b) > maxPolicyBytes {
+			return nil, "", fmt.Errorf("larger than %d bytes", maxPolicyBytes)
+		}
+		return b, newETag, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return nil, "", objectstore.ErrNotModified
+	case resp.StatusCode/100 != 2:
+		return nil, "", &objectstore.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	b, err := readLimited(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return b, resp.Header.Get("ETag"), nil
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, maxPolicyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxPolicyBytes {
+		return nil, fmt.Errorf("larger than %d bytes", maxPolicyBytes)
+	}
+	return b, nil
+}
@@ -0,0 +1,161 @@
+// Package preview works out what a proposed policy change would do to
+// the findings hosts already report, by comparing the violations a
+// report has under the policy in force with those a re-analysis under
+// the proposed one finds, before the change is rolled out.
+package preview
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"compliance-agent/alerting"
+	"compliance-agent/analyzer"
+	"compliance-agent/report"
+)
+
+// Host is the change one host's report would see.
+type Host struct {
+	Hostname    string    `json:"hostname"`
+	AgentID     string    `json:"agent_id,omitempty"`
+	GeneratedAt time.Time `json:"generated_at"`
+	// Added are violations the proposed policy finds and the current
+	// one doesn't; Removed the other way round.
+	Added     []analyzer.Violation `json:"added"`
+	Removed   []analyzer.Violation `json:"removed"`
+	Unchanged int                  `json:"unchanged"`
+	// NewChecks and DroppedChecks are the policy checks the change adds
+	// and takes away, when the policy in force is known.
+	NewChecks     []string `json:"new_checks,omitempty"`
+	DroppedChecks []string `json:"dropped_checks,omitempty"`
+	// Unverified are checks whose dataset the report doesn't have:
+	// agents only collect it once their policy asks for it, so what those
+	// checks would find can't be previewed.
+	Unverified []analyzer.Check `json:"unverified,omitempty"`
+	// Skipped says why the host couldn't be previewed at all.
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// Changed reports whether the host's findings would change.
+func (h Host) Changed() bool { return len(h.Added) > 0 || len(h.Removed) > 0 }
+
+// Result is a preview over one report or a fleet.
+type Result struct {
+	Hosts   []Host `json:"hosts"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	// Changed counts the hosts whose findings would change.
+	Changed int `json:"changed"`
+}
+
+// Compare diffs current, a report analyzed under the policy in force,
+// with proposed, the same report re-analyzed under the proposed policy.
+func Compare(current, proposed report.ComplianceReport) Host {
+	h := Host{Hostname: current.Hostname, GeneratedAt: current.GeneratedAt, Added: []analyzer.Violation{}, Removed: []analyzer.Violation{}}
+	added, removed := alerting.Delta(current.Hostname, current.Violations, proposed.Violations)
+	h.Added = append(h.Added, added...)
+	h.Removed = append(h.Removed, removed...)
+	h.Unchanged = len(proposed.Violations) - len(added)
+
+	have := datasets(proposed)
+	for _, c := range proposed.Checks {
+		if c.Dataset != "" && !have[c.Dataset] {
+			h.Unverified = append(h.Unverified, c)
+		}
+	}
+
+	// A report doesn't record its checks; only a re-analysis under the
+	// policy in force has them.
+	if current.Checks == nil {
+		return h
+	}
+	before, after := map[string]bool{}, map[string]bool{}
+	for _, c := range current.Checks {
+		before[c.Name] = true
+	}
+	for _, c := range proposed.Checks {
+		after[c.Name] = true
+		if !before[c.Name] {
+			h.NewChecks = append(h.NewChecks, c.Name)
+		}
+	}
+	for _, c := range current.Checks {
+		if !after[c.Name] {
+			h.DroppedChecks = append(h.DroppedChecks, c.Name)
+		}
+	}
+	return h
+}
+
+// datasets lists the report's datasets that were written, empty or not.
+func datasets(rep report.ComplianceReport) map[string]bool {
+	var fields map[string]json.RawMessage
+	b, _ := json.Marshal(rep)
+	_ = json.Unmarshal(b, &fields)
+	have := map[string]bool{}
+	for name, v := range fields {
+		if string(v) != "null" {
+			have[name] = true
+		}
+	}
+	return have
+}
+
+// Add adds a host's preview to the result.
+func (r *Result) Add(h Host) {
+	r.Hosts = append(r.Hosts, h)
+	r.Added += len(h.Added)
+	r.Removed += len(h.Removed)
+	if h.Changed() {
+		r.Changed++
+	}
+}
+
+// Sort puts the hosts with the most change first.
+func (r *Result) Sort() {
+	sort.SliceStable(r.Hosts, func(i, j int) bool {
+		a, b := r.Hosts[i], r.Hosts[j]
+		if na, nb := len(a.Added)+len(a.Removed), len(b.Added)+len(b.Removed); na != nb {
+			return na > nb
+		}
+		return a.Hostname < b.Hostname
+	})
+}
+
+// Text renders the result for a terminal.
+func (r Result) Text() string {
+	var b strings.Builder
+	previewed := 0
+	for _, h := range r.Hosts {
+		if h.Skipped == "" {
+			previewed++
+		}
+	}
+	fmt.Fprintf(&b, "%d violation(s) added, %d removed on %d of %d host(s)\n", r.Added, r.Removed, r.Changed, previewed)
+	for _, h := range r.Hosts {
+		if h.Skipped != "" {
+			fmt.Fprintf(&b, "\n%s: skipped, %s\n", h.Hostname, h.Skipped)
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s (report of %s): +%d -%d, %d unchanged\n",
+			h.Hostname, h.GeneratedAt.UTC().Format(time.RFC3339), len(h.Added), len(h.Removed), h.Unchanged)
+		for _, v := range h.Added {
+			fmt.Fprintf(&b, "  + %-8s %s: %s\n", v.Severity, v.Category, v.Message)
+		}
+		for _, v := range h.Removed {
+			fmt.Fprintf(&b, "  - %-8s %s: %s\n", v.Severity, v.Category, v.Message)
+		}
+		if len(h.NewChecks) > 0 {
+			fmt.Fprintf(&b, "  new checks: %s\n", strings.Join(h.NewChecks, ", "))
+		}
+		if len(h.DroppedChecks) > 0 {
+			fmt.Fprintf(&b, "  dropped checks: %s\n", strings.Join(h.DroppedChecks, ", "))
+		}
+		for _, c := range h.Unverified {
+			fmt.Fprintf(&b, "  not previewed: %s needs %s, which this report doesn't have\n", c.Name, c.Dataset)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package preview
+
+import (
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/collector"
+	"compliance-agent/report"
+	"github.com/stretchr/testify/assert"
+)
+
+func host(name string, violations ...analyzer.Violation) report.ComplianceReport {
+	return report.ComplianceReport{
+		Hostname:    name,
+		GeneratedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Users:       []collector.User{{Username: "root"}, {Username: "bin"}},
+		OpenPorts:   []int{22},
+		Violations:  violations,
+	}
+}
+
+var (
+	binUser = analyzer.Violation{Category: "user", Severity: analyzer.SeverityHigh, Message: "unexpected user present: bin"}
+	sshPort = analyzer.Violation{Category: "port", Severity: analyzer.SeverityMedium, Message: "unexpected open port: 22"}
+)
+
+func TestCompare(t *testing.T) {
+	current := host("web-1", binUser)
+	proposed := host("web-1", sshPort)
+	proposed.Checks = []analyzer.Check{
+		{Name: "allowed_users", Dataset: "users"},
+		{Name: "sshd", Dataset: "sshd"},
+		{Name: "rules/telnet"},
+	}
+
+	h := Compare(current, proposed)
+	assert.Equal(t, []analyzer.Violation{sshPort}, h.Added)
+	assert.Equal(t, []analyzer.Violation{binUser}, h.Removed)
+	assert.Equal(t, 0, h.Unchanged)
+	assert.True(t, h.Changed())
+	assert.Equal(t, []analyzer.Check{{Name: "sshd", Dataset: "sshd"}}, h.Unverified, "the report has no sshd dataset")
+	assert.Nil(t, h.NewChecks, "the recorded report doesn't know its checks")
+
+	current.Checks = []analyzer.Check{{Name: "allowed_users"}, {Name: "require_firewall_enabled"}}
+	h = Compare(current, proposed)
+	assert.Equal(t, []string{"sshd", "rules/telnet"}, h.NewChecks)
+	assert.Equal(t, []string{"require_firewall_enabled"}, h.DroppedChecks)
+
+	h = Compare(current, current)
+	assert.False(t, h.Changed())
+	assert.Equal(t, 1, h.Unchanged)
+	assert.NotNil(t, h.Added)
+}
+
+func TestResult(t *testing.T) {
+	var r Result
+	r.Add(Compare(host("a", binUser), host("a", binUser)))
+	r.Add(Compare(host("b", binUser), host("b", sshPort, binUser)))
+	r.Add(Host{Hostname: "c", Skipped: "the report is anonymized and has no inventory"})
+	r.Sort()
+
+	assert.Equal(t, 1, r.Added)
+	assert.Equal(t, 0, r.Removed)
+	assert.Equal(t, 1, r.Changed)
+	assert.Equal(t, "b", r.Hosts[0].Hostname, "the most changed host first")
+
+	text := r.Text()
+	assert.Contains(t, text, "1 violation(s) added, 0 removed on 1 of 2 host(s)\n")
+	assert.Contains(t, text, "b (report of 2026-03-01T12:00:00Z): +1 -0, 1 unchanged\n  + medium   port: unexpected open port: 22\n")
+	assert.Contains(t, text, "c: skipped, the report is anonymized")
+}
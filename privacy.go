@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"text/tabwriter"
+	"time"
+
+	"compliance-agent/errcode"
+	"compliance-agent/privacy"
+	"compliance-agent/report"
+	"compliance-agent/storage"
+)
+
+const privacyUsage = `Usage:
+  %[1]s privacy export (-host name | -user name) [-o file]   everything the fleet database holds on them
+  %[1]s privacy purge (-host name | -user name) -reason text [-yes]
+  %[1]s privacy log [-json]                                 the purges made so far
+
+-host is an agent ID or hostname: purging it deletes the agent and all its
+reports. -user is a username: purging it removes their accounts,
+processes, cron jobs, violations and user-scope data from every report
+and redacts their name wherever else it appears. Without -yes, purge
+only says what it would remove.
+`
+
+// cmdPrivacy implements `compliance-agent privacy`: data-subject access
+// and erasure on the fleet server's database, with a record of each
+// erasure kept in it.
+func cmdPrivacy(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, privacyUsage, os.Args[0])
+		usageError("privacy: want a subcommand")
+	}
+	switch args[0] {
+	case "export":
+		privacyExport(args[1:])
+	case "purge":
+		privacyPurge(args[1:])
+	case "log":
+		privacyLog(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, privacyUsage, os.Args[0])
+		usageError("privacy: unknown subcommand %q", args[0])
+	}
+}
+
+// privacyFlags are the flags privacy's subcommands share.
+type privacyFlags struct {
+	configPath, dbPath, host, user *string
+}
+
+// addPrivacyFlags adds the database flags and, for commands about a data
+// subject, -host and -user.
+func addPrivacyFlags(fs *flag.FlagSet, subject bool) privacyFlags {
+	f := privacyFlags{
+		configPath: fs.String("config", "", "Path to YAML config (optional)"),
+		dbPath:     fs.String("db", "", "Fleet database (overrides config server.db_path)"),
+	}
+	if subject {
+		f.host = fs.String("host", "", "The data subject is this host: an agent ID or hostname")
+		f.user = fs.String("user", "", "The data subject is this user: a username")
+	}
+	return f
+}
+
+// open opens the fleet database, checking exactly one subject is given
+// if the command takes one.
+func (f privacyFlags) open() (*storage.FleetStore, string) {
+	if f.host != nil && (*f.host == "") == (*f.user == "") {
+		usageError("give one of -host or -user")
+	}
+	path := loadConfig(*f.configPath).Server.DBPath
+	if *f.dbPath != "" {
+		path = *f.dbPath
+	}
+	if _, err := os.Stat(path); err != nil {
+		fatal(errcode.Errorf(errcode.CollectorUnavailable, "no fleet database at %s: %w", path, err), "privacy")
+	}
+	store, err := storage.OpenFleet(path)
+	if err != nil {
+		fatal(err, "privacy")
+	}
+	return store, path
+}
+
+// subjectExport is what `privacy export` writes: for a host, its agent
+// record and every report it uploaded; for a user, where each report
+// mentions them.
+type subjectExport struct {
+	Subject    privacySubject `json:"subject"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Agent      *storage.Agent `json:"agent,omitempty"`
+	Reports    []subjectData  `json:"reports"`
+}
+
+type privacySubject struct {
+	Host string `json:"host,omitempty"`
+	User string `json:"user,omitempty"`
+}
+
+type subjectData struct {
+	ID          int64                    `json:"id"`
+	AgentID     string                   `json:"agent_id"`
+	Hostname    string                   `json:"hostname"`
+	GeneratedAt time.Time                `json:"generated_at"`
+	Report      *report.ComplianceReport `json:"report,omitempty"`
+	Matches     []privacy.Match          `json:"matches,omitempty"`
+}
+
+func privacyExport(args []string) {
+	fs := flag.NewFlagSet("privacy export", flag.ContinueOnError)
+	pf := addPrivacyFlags(fs, true)
+	out := fs.String("o", "-", "Output file (- for stdout)")
+	parseFlags(fs, args)
+	store, path := pf.open()
+	defer store.Close()
+
+	exp := subjectExport{
+		Subject:    privacySubject{Host: *pf.host, User: *pf.user},
+		ExportedAt: time.Now().UTC(),
+		Reports:    []subjectData{},
+	}
+	var err error
+	if *pf.host != "" {
+		agent := findAgent(store, *pf.host, path)
+		exp.Agent = &agent
+		exp.Reports, err = agentData(store, agent.ID)
+	} else {
+		exp.Reports, err = userData(store, *pf.user)
+	}
+	if err != nil {
+		fatal(err, "export")
+	}
+	b, err := json.MarshalIndent(exp, "", "  ")
+	if err != nil {
+		fatal(err, "export")
+	}
+	if *out == "-" {
+		fmt.Println(string(b))
+	} else if err := os.WriteFile(*out, append(b, '\n'), 0o600); err != nil {
+		fatal(err, "export")
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d report(s)\n", len(exp.Reports))
+}
+
+// agentData is every report the agent uploaded, oldest first.
+func agentData(store *storage.FleetStore, agentID string) ([]subjectData, error) {
+	runs, err := store.AgentRuns(agentID, 0)
+	if err != nil {
+		return nil, err
+	}
+	data := []subjectData{}
+	for i := len(runs) - 1; i >= 0; i-- {
+		rep, _, _, err := store.Report(runs[i].ID)
+		if err != nil {
+			return nil, fmt.Errorf("report %d: %w", runs[i].ID, err)
+		}
+		data = append(data, subjectData{
+			ID: runs[i].ID, AgentID: agentID, Hostname: rep.Hostname, GeneratedAt: rep.GeneratedAt, Report: &rep,
+		})
+	}
+	return data, nil
+}
+
+// userData is where each report mentions the user.
+func userData(store *storage.FleetStore, username string) ([]subjectData, error) {
+	data := []subjectData{}
+	err := store.EachReport(func(id int64, agentID string, body []byte) error {
+		matches, err := privacy.Find(body, username)
+		if err != nil {
+			return fmt.Errorf("report %d: %w", id, err)
+		}
+		if len(matches) == 0 {
+			return nil
+		}
+		var head struct {
+			Hostname    string    `json:"hostname"`
+			GeneratedAt time.Time `json:"generated_at"`
+		}
+		_ = json.Unmarshal(body, &head)
+		data = append(data, subjectData{
+			ID: id, AgentID: agentID, Hostname: head.Hostname, GeneratedAt: head.GeneratedAt, Matches: matches,
+		})
+		return nil
+	})
+	return data, err
+}
+
+func privacyPurge(args []string) {
+	fs := flag.NewFlagSet("privacy purge", flag.ContinueOnError)
+	pf := addPrivacyFlags(fs, true)
+	reason := fs.String("reason", "", "Why, e.g. the erasure request's ticket (required; kept in the purge record)")
+	operator := fs.String("operator", "", "Who is purging (default: the current OS user)")
+	yes := fs.Bool("yes", false, "Purge; without it, only say what would be removed")
+	parseFlags(fs, args)
+	if *reason == "" {
+		usageError("-reason is required: it goes in the purge record")
+	}
+	store, path := pf.open()
+	defer store.Close()
+	if *operator == "" {
+		if u, err := user.Current(); err == nil {
+			*operator = u.Username
+		}
+	}
+	rec := storage.Purge{Operator: *operator, Reason: *reason, User: *pf.user}
+
+	var agent storage.Agent
+	if *pf.host != "" {
+		agent = findAgent(store, *pf.host, path)
+		rec.Hostname = agent.Hostname
+	}
+	if !*yes {
+		if *pf.host != "" {
+			runs, err := store.AgentRuns(agent.ID, 0)
+			if err != nil {
+				fatal(err, "purge")
+			}
+			fmt.Fprintf(os.Stderr, "Would delete agent %s (%s) and its %d report(s).\n", agent.ID, agent.Hostname, len(runs))
+		} else {
+			data, err := userData(store, *pf.user)
+			if err != nil {
+				fatal(err, "purge")
+			}
+			n := 0
+			for _, d := range data {
+				n += len(d.Matches)
+			}
+			fmt.Fprintf(os.Stderr, "Would remove or redact %d item(s) on %s in %d report(s).\n", n, *pf.user, len(data))
+		}
+		fmt.Fprintln(os.Stderr, "Nothing was changed; re-run with -yes to purge.")
+		return
+	}
+
+	var err error
+	if *pf.host != "" {
+		rec, err = store.PurgeAgent(agent.ID, rec)
+	} else {
+		rec, err = store.RedactReports(func(body []byte) ([]byte, int, error) {
+			return privacy.Redact(body, *pf.user)
+		}, rec)
+	}
+	if err != nil {
+		fatal(err, "purge")
+	}
+	dumpJSON(rec)
+}
+
+func privacyLog(args []string) {
+	fs := flag.NewFlagSet("privacy log", flag.ContinueOnError)
+	pf := addPrivacyFlags(fs, false)
+	asJSON := fs.Bool("json", false, "Print purges as JSON")
+	parseFlags(fs, args)
+	store, _ := pf.open()
+	defer store.Close()
+	purges, err := store.Purges()
+	if err != nil {
+		fatal(err, "read purges")
+	}
+	if *asJSON {
+		dumpJSON(purges)
+		return
+	}
+	if len(purges) == 0 {
+		fmt.Println("No purges recorded.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tOPERATOR\tSUBJECT\tDELETED\tREDACTED\tREASON")
+	for _, p := range purges {
+		subject := "user " + p.User
+		if p.Agent != "" {
+			subject = "host " + p.Hostname + " (" + p.Agent + ")"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d\t%s\n", p.ID, p.Time.Local().Format("2006-01-02 15:04:05"),
+			p.Operator, subject, p.ReportsDeleted, p.ReportsRedacted, p.Reason)
+	}
+	w.Flush()
+}
@@ -0,0 +1,223 @@
+// Package privacy finds and removes what stored reports hold about one
+// user, for data-subject access and erasure requests.
+//
+// It works on a report's JSON rather than its Go type, so every dataset
+// is covered, including ones added after this package was written: an
+// object with a "username" or "user" field naming the user (an account,
+// a process, a cron job, a violation, a user scope) belongs to them, as
+// does a process running under their UID, and any other string naming
+// them, such as a home directory path or a violation message, mentions
+// them.
+package privacy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Redacted replaces the user's name in strings that mention them.
+const Redacted = "[redacted]"
+
+// Match is one place a report holds data on the user: an object that
+// belongs to them or a string that mentions them.
+type Match struct {
+	// Path locates it in the report, e.g. "processes[3]" or
+	// "violations[0].message".
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+}
+
+// Find lists where the report in body holds data on user.
+func Find(body []byte, user string) ([]Match, error) {
+	if user == "" {
+		return nil, errors.New("empty user")
+	}
+	doc, err := decode(body)
+	if err != nil {
+		return nil, err
+	}
+	w := walker{user: user, uids: uids(doc, user)}
+	w.find("", doc)
+	return w.matches, nil
+}
+
+// Redact removes from the report in body each object that belongs to
+// user and replaces their name in every other string with Redacted. It
+// returns the new report and the number of objects and strings changed;
+// with none, body is returned as is.
+func Redact(body []byte, user string) ([]byte, int, error) {
+	if user == "" {
+		return nil, 0, errors.New("empty user")
+	}
+	doc, err := decode(body)
+	if err != nil {
+		return nil, 0, err
+	}
+	w := walker{user: user, uids: uids(doc, user)}
+	doc = w.redact(doc)
+	if w.changed == 0 {
+		return body, 0, nil
+	}
+	out, err := json.Marshal(doc)
+	return out, w.changed, err
+}
+
+func decode(body []byte) (any, error) {
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	var doc any
+	if err := d.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode report: %w", err)
+	}
+	return doc, nil
+}
+
+// uids are the user's UIDs per the report's account list, so processes
+// only identified by UID can be tied to them.
+func uids(doc any, user string) map[string]bool {
+	ids := map[string]bool{}
+	top, _ := doc.(map[string]any)
+	users, _ := top["users"].([]any)
+	for _, u := range users {
+		u, _ := u.(map[string]any)
+		if u["username"] == user {
+			if uid, ok := u["uid"].(json.Number); ok && !strings.HasPrefix(uid.String(), "-") {
+				ids[uid.String()] = true
+			}
+		}
+	}
+	return ids
+}
+
+type walker struct {
+	user    string
+	uids    map[string]bool
+	matches []Match
+	changed int
+}
+
+// owned reports whether v is an object belonging to the user.
+func (w *walker) owned(v any) bool {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return false
+	}
+	if obj["username"] == w.user || obj["user"] == w.user {
+		return true
+	}
+	// A process with no owner name: "pid" tells it from an account.
+	if uid, ok := obj["uid"].(json.Number); ok && w.uids[uid.String()] {
+		_, isProcess := obj["pid"]
+		return isProcess
+	}
+	return false
+}
+
+func (w *walker) find(path string, v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		if w.owned(v) {
+			w.matches = append(w.matches, Match{Path: path, Value: v})
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			w.find(join(path, k), v[k])
+		}
+	case []any:
+		for i, e := range v {
+			w.find(fmt.Sprintf("%s[%d]", path, i), e)
+		}
+	case string:
+		if mentions(v, w.user) {
+			w.matches = append(w.matches, Match{Path: path, Value: v})
+		}
+	}
+}
+
+func (w *walker) redact(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if w.owned(e) {
+				v[k] = nil
+				w.changed++
+				continue
+			}
+			v[k] = w.redact(e)
+		}
+		return v
+	case []any:
+		kept := v[:0]
+		for _, e := range v {
+			if w.owned(e) {
+				w.changed++
+				continue
+			}
+			kept = append(kept, w.redact(e))
+		}
+		return kept
+	case string:
+		if s, n := replace(v, w.user); n > 0 {
+			w.changed++
+			return s
+		}
+		return v
+	}
+	return v
+}
+
+// mentions reports whether s names user as a whole word: "/home/eve/.ssh"
+// and "unexpected user present: eve" do, "never" doesn't.
+func mentions(s, user string) bool {
+	_, n := replace(s, user)
+	return n > 0
+}
+
+// replace replaces each whole-word occurrence of user in s with Redacted.
+func replace(s, user string) (string, int) {
+	var b strings.Builder
+	n, done := 0, 0
+	for from := 0; ; {
+		i := strings.Index(s[from:], user)
+		if i < 0 {
+			break
+		}
+		start := from + i
+		end := start + len(user)
+		from = end
+		if wordByte(s, start-1) || wordByte(s, end) {
+			continue
+		}
+		b.WriteString(s[done:start])
+		b.WriteString(Redacted)
+		done = end
+		n++
+	}
+	b.WriteString(s[done:])
+	return b.String(), n
+}
+
+// wordByte reports whether s[i] exists and could be part of a username.
+func wordByte(s string, i int) bool {
+	if i < 0 || i >= len(s) {
+		return false
+	}
+	c := s[i]
+	return c == '_' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
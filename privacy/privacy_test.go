@@ -0,0 +1,98 @@
+package privacy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testReport = `{
+  "hostname": "laptop-7",
+  "user_scope": {"username": "eve", "home": "/home/eve"},
+  "users": [
+    {"username": "root", "uid": 0, "directory": "/root"},
+    {"username": "eve", "uid": 1001, "directory": "/home/eve"},
+    {"username": "steve", "uid": 1002, "directory": "/home/steve"}
+  ],
+  "processes": [
+    {"pid": 1, "name": "init", "uid": 0},
+    {"pid": 40, "name": "vim", "uid": 1001, "cmdline": "vim notes.txt"},
+    {"pid": 41, "name": "ps", "uid": 1002, "user": "steve"}
+  ],
+  "cron_jobs": [{"source": "/var/spool/cron/eve", "user": "eve", "schedule": "@daily", "command": "backup"}],
+  "violations": [
+    {"category": "user", "severity": "high", "user": "eve", "message": "unexpected user present: eve"},
+    {"category": "secret_exposure", "severity": "high", "message": "private key /home/eve/.ssh/id_rsa is world-readable"},
+    {"category": "port", "severity": "medium", "message": "unexpected open port: 8080 (never seen)"}
+  ]
+}`
+
+func TestFind(t *testing.T) {
+	matches, err := Find([]byte(testReport), "eve")
+	require.NoError(t, err)
+	var paths []string
+	for _, m := range matches {
+		paths = append(paths, m.Path)
+	}
+	assert.Equal(t, []string{
+		"cron_jobs[0]",
+		"processes[1]",
+		"user_scope",
+		"users[1]",
+		"violations[0]",
+		"violations[1].message",
+	}, paths, "steve and never don't name eve")
+
+	_, err = Find([]byte(testReport), "")
+	assert.Error(t, err)
+}
+
+func TestRedact(t *testing.T) {
+	out, n, err := Redact([]byte(testReport), "eve")
+	require.NoError(t, err)
+	assert.Equal(t, 6, n)
+	var rep struct {
+		UserScope  any `json:"user_scope"`
+		Users      []struct{ Username string }
+		Processes  []struct{ Name string }
+		CronJobs   []any `json:"cron_jobs"`
+		Violations []struct{ Message string }
+	}
+	require.NoError(t, json.Unmarshal(out, &rep))
+	assert.Nil(t, rep.UserScope)
+	require.Len(t, rep.Users, 2)
+	assert.Equal(t, "steve", rep.Users[1].Username)
+	assert.Len(t, rep.Processes, 2)
+	assert.Empty(t, rep.CronJobs)
+	require.Len(t, rep.Violations, 2)
+	assert.Equal(t, "private key /home/[redacted]/.ssh/id_rsa is world-readable", rep.Violations[0].Message)
+
+	matches, err := Find(out, "eve")
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+
+	same, n, err := Redact([]byte(testReport), "mallory")
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Equal(t, testReport, string(same), "untouched")
+}
+
+func TestReplace(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		n        int
+	}{
+		{"eve", Redacted, 1},
+		{"eve eve", Redacted + " " + Redacted, 2},
+		{"/home/eve/x", "/home/" + Redacted + "/x", 1},
+		{"eveeve eve", "eveeve " + Redacted, 1},
+		{"steve, eve-admin, eve_2, never", "steve, eve-admin, eve_2, never", 0},
+		{"login eve.", "login " + Redacted + ".", 1},
+	} {
+		got, n := replace(tc.in, "eve")
+		assert.Equal(t, tc.want, got, tc.in)
+		assert.Equal(t, tc.n, n, tc.in)
+	}
+}
@@ -0,0 +1,114 @@
+// Package release checks that an agent binary is one the release
+// pipeline built: an Ed25519 signature over the binary's bytes, stored
+// next to it as <binary>.sig. Keys and signatures are in policydist's
+// formats, so `openssl pkeyutl -sign -rawin` can sign a release too.
+//
+// packaging/build.sh links the release public key into each binary
+// (buildinfo.ReleaseKey), signs it, and the packages install the
+// signature beside it, so an installed agent can check itself.
+package release
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"compliance-agent/buildinfo"
+	"compliance-agent/policydist"
+)
+
+var (
+	// ErrUnsigned is returned for a binary with no signature file.
+	ErrUnsigned = errors.New("binary is not signed")
+	// ErrNoKey is returned by Keys and VerifySelf for a build with no
+	// release key linked in, such as a plain go build.
+	ErrNoKey = errors.New("no release key built in")
+)
+
+// SignatureFile is where binary's signature is kept.
+func SignatureFile(binary string) string {
+	return binary + ".sig"
+}
+
+// Keys returns the release keys linked into this binary.
+func Keys() ([]ed25519.PublicKey, error) {
+	if strings.TrimSpace(buildinfo.ReleaseKey) == "" {
+		return nil, ErrNoKey
+	}
+	return policydist.ParsePublicKeys(strings.Split(buildinfo.ReleaseKey, ","))
+}
+
+// Sign signs binary with key and writes the signature to
+// SignatureFile(binary), returning its path.
+func Sign(key ed25519.PrivateKey, binary string) (string, error) {
+	b, err := os.ReadFile(binary)
+	if err != nil {
+		return "", err
+	}
+	path := SignatureFile(binary)
+	if err := os.WriteFile(path, []byte(policydist.Sign(key, b)+"\n"), 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Verify checks binary against the signature in sigPath (by default
+// SignatureFile(binary)) under one of keys.
+func Verify(keys []ed25519.PublicKey, binary, sigPath string) error {
+	if sigPath == "" {
+		sigPath = SignatureFile(binary)
+	}
+	sig, err := os.ReadFile(sigPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: no %s", ErrUnsigned, sigPath)
+	}
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(sig)) == 0 {
+		return fmt.Errorf("%w: %s is empty", ErrUnsigned, sigPath)
+	}
+	raw, err := policydist.DecodeSignature(sig)
+	if err != nil {
+		return fmt.Errorf("%s: %w", sigPath, err)
+	}
+	b, err := os.ReadFile(binary)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if ed25519.Verify(k, b, raw) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: signature matches none of the release keys", binary)
+}
+
+// Executable returns the path of the running binary, with symlinks
+// resolved: the packages link /usr/bin/compliance-agent to the binary
+// whose signature sits beside it.
+func Executable() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(path)
+}
+
+// VerifySelf checks the running binary under the release keys linked
+// into it, returning the binary's path.
+func VerifySelf() (string, error) {
+	keys, err := Keys()
+	if err != nil {
+		return "", err
+	}
+	path, err := Executable()
+	if err != nil {
+		return "", err
+	}
+	return path, Verify(keys, path, "")
+}
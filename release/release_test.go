@@ -0,0 +1,84 @@
+package release
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"compliance-agent/buildinfo"
+	"compliance-agent/policydist"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKey(t *testing.T, dir, name string) (ed25519.PrivateKey, string) {
+	t.Helper()
+	privPEM, pubPEM, err := policydist.GenerateKey()
+	require.NoError(t, err)
+	keyPath := filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(keyPath, privPEM, 0o600))
+	key, err := policydist.LoadPrivateKey(keyPath)
+	require.NoError(t, err)
+	return key, string(pubPEM)
+}
+
+func TestSignVerify(t *testing.T) {
+	dir := t.TempDir()
+	key, pubPEM := newKey(t, dir, "release")
+	pub, err := policydist.ParsePublicKey(pubPEM)
+	require.NoError(t, err)
+	bin := filepath.Join(dir, "compliance-agent")
+	require.NoError(t, os.WriteFile(bin, []byte("\x7fELF agent"), 0o755))
+
+	assert.ErrorIs(t, Verify([]ed25519.PublicKey{pub}, bin, ""), ErrUnsigned)
+
+	sigPath, err := Sign(key, bin)
+	require.NoError(t, err)
+	assert.Equal(t, bin+".sig", sigPath)
+	require.NoError(t, Verify([]ed25519.PublicKey{pub}, bin, ""))
+
+	// A rotated-out key is fine as long as one key matches.
+	_, otherPEM := newKey(t, dir, "other")
+	other, err := policydist.ParsePublicKey(otherPEM)
+	require.NoError(t, err)
+	require.NoError(t, Verify([]ed25519.PublicKey{other, pub}, bin, ""))
+	assert.ErrorContains(t, Verify([]ed25519.PublicKey{other}, bin, ""), "matches none of the release keys")
+
+	require.NoError(t, os.WriteFile(bin, []byte("\x7fELF patched agent"), 0o755))
+	assert.ErrorContains(t, Verify([]ed25519.PublicKey{pub}, bin, ""), "matches none of the release keys")
+
+	require.NoError(t, os.WriteFile(sigPath, []byte("not a signature"), 0o644))
+	assert.ErrorContains(t, Verify([]ed25519.PublicKey{pub}, bin, ""), "want 64 bytes")
+	require.NoError(t, os.WriteFile(sigPath, nil, 0o644))
+	assert.ErrorIs(t, Verify([]ed25519.PublicKey{pub}, bin, ""), ErrUnsigned)
+}
+
+func TestKeys(t *testing.T) {
+	defer func(k string) { buildinfo.ReleaseKey = k }(buildinfo.ReleaseKey)
+
+	buildinfo.ReleaseKey = ""
+	_, err := Keys()
+	assert.ErrorIs(t, err, ErrNoKey)
+	_, err = VerifySelf()
+	assert.ErrorIs(t, err, ErrNoKey)
+
+	pub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	old, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	buildinfo.ReleaseKey = b64(pub) + "," + b64(old)
+	keys, err := Keys()
+	require.NoError(t, err)
+	assert.Equal(t, []ed25519.PublicKey{pub, old}, keys)
+
+	// The test binary isn't signed.
+	path, err := VerifySelf()
+	assert.ErrorIs(t, err, ErrUnsigned)
+	assert.NotEmpty(t, path)
+}
+
+func b64(k ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(k)
+}
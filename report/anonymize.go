@@ -0,0 +1,88 @@
+package report
+
+import "compliance-agent/analyzer"
+
+// Metrics are the aggregate counts an anonymized report keeps in place of
+// its inventory and findings.
+type Metrics struct {
+	Users       int            `json:"users"`
+	Processes   int            `json:"processes"`
+	OpenPorts   int            `json:"open_ports"`
+	Packages    int            `json:"packages"`
+	Connections int            `json:"connections"`
+	Violations  int            `json:"violations"`
+	BySeverity  map[string]int `json:"by_severity"`
+	ByCategory  map[string]int `json:"by_category"`
+	Errors      int            `json:"errors"`
+}
+
+// Anonymize returns a copy of the report reduced to what data-minimization
+// rules allow to be kept: who produced it and when, aggregate metrics,
+// and each violation's category, severity, control, risk and fingerprint.
+// Messages, usernames, paths, command lines and the rest of the inventory
+// are dropped, as are error messages, including the agent health's last
+// delivery errors. The fingerprint is taken before the
+// message goes, so a finding that recurs still matches it. Anonymizing an
+// anonymized report returns it unchanged.
+func (r *ComplianceReport) Anonymize() ComplianceReport {
+	if r.Anonymized {
+		return *r
+	}
+	m := &Metrics{
+		Users:       len(r.Users),
+		Processes:   len(r.Processes),
+		OpenPorts:   len(r.OpenPorts),
+		Packages:    len(r.Packages),
+		Connections: len(r.Connections),
+		Violations:  len(r.Violations),
+		BySeverity:  map[string]int{},
+		ByCategory:  map[string]int{},
+		Errors:      len(r.Errors),
+	}
+	violations := make([]analyzer.Violation, 0, len(r.Violations))
+	for _, v := range r.Violations {
+		m.BySeverity[string(v.Severity)]++
+		m.ByCategory[v.Category]++
+		violations = append(violations, analyzer.Violation{
+			Category:    v.Category,
+			Severity:    v.Severity,
+			Control:     v.Control,
+			Risk:        v.Risk,
+			Fingerprint: analyzer.Fingerprint(r.Hostname, v),
+		})
+	}
+	var errs []RunError
+	for _, e := range r.Errors {
+		errs = append(errs, RunError{Stage: e.Stage, Subsystem: e.Subsystem, Code: e.Code, Panic: e.Panic})
+	}
+	var health *AgentHealth
+	if r.Health != nil {
+		h := *r.Health
+		h.Deliveries = nil
+		for _, d := range r.Health.Deliveries {
+			d.LastError = ""
+			h.Deliveries = append(h.Deliveries, d)
+		}
+		health = &h
+	}
+	return ComplianceReport{
+		SchemaVersion: r.SchemaVersion,
+		GeneratedAt:   r.GeneratedAt,
+		Hostname:      r.Hostname,
+		Platform:      r.Platform,
+		Scope:         r.Scope,
+		SupportTier:   r.SupportTier,
+		Agent:         r.Agent,
+		Identity:      r.Identity,
+		Image:         r.Image,
+		Root:          r.Root,
+		Unavailable:   r.Unavailable,
+		OSVersion:     r.OSVersion,
+		Violations:    violations,
+		NotApplicable: r.NotApplicable,
+		Errors:        errs,
+		Anonymized:    true,
+		Metrics:       m,
+		Health:        health,
+	}
+}
@@ -0,0 +1,71 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/collector"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymize(t *testing.T) {
+	eve := analyzer.Violation{Category: "user", Severity: analyzer.SeverityHigh, User: "eve", Message: "unexpected user present: eve", Risk: 7}
+	r := ComplianceReport{
+		GeneratedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Hostname:    "laptop-7",
+		Platform:    "darwin",
+		Scope:       "system",
+		Agent:       &AgentInfo{Version: "1.4.0"},
+		UserScope:   &collector.UserScope{Username: "eve"},
+		Users:       []collector.User{{Username: "root"}, {Username: "eve"}},
+		Processes:   []collector.Process{{Name: "ssh", Cmdline: "ssh -i /Users/eve/.ssh/id_ed25519 prod"}},
+		OpenPorts:   []int{22, 8080},
+		Packages:    []collector.Package{{Name: "openssl", Version: "3.0.2"}},
+		Violations: []analyzer.Violation{
+			eve,
+			{Category: "port", Severity: analyzer.SeverityMedium, Message: "unexpected open port: 8080"},
+			{Category: "cis", Control: "5.2.7", Message: "/etc/ssh/sshd_config allows root login"},
+		},
+		Errors: []RunError{{Stage: "collect", Subsystem: "packages", Message: "open /Users/eve/Library: permission denied"}},
+		Health: &AgentHealth{Collector: "osquery", Deliveries: []DeliveryStats{
+			{Stage: "notify", Destination: "slack", Sent: 3, Failed: 1, LastError: "post https://hooks.slack.com/services/T0/B0/x: timeout"},
+		}},
+	}
+
+	a := r.Anonymize()
+	assert.True(t, a.Anonymized)
+	assert.Equal(t, r.GeneratedAt, a.GeneratedAt)
+	assert.Equal(t, "laptop-7", a.Hostname)
+	assert.Equal(t, r.Agent, a.Agent)
+	assert.Nil(t, a.UserScope)
+	assert.Empty(t, a.Users)
+	assert.Empty(t, a.Processes)
+	assert.Empty(t, a.OpenPorts)
+	assert.Empty(t, a.Packages)
+	assert.Equal(t, &Metrics{
+		Users: 2, Processes: 1, OpenPorts: 2, Packages: 1, Violations: 3, Errors: 1,
+		BySeverity: map[string]int{"high": 1, "medium": 1, "": 1},
+		ByCategory: map[string]int{"user": 1, "port": 1, "cis": 1},
+	}, a.Metrics)
+
+	require.Len(t, a.Violations, 3)
+	assert.Equal(t, analyzer.Violation{
+		Category: "user", Severity: analyzer.SeverityHigh, Risk: 7, Fingerprint: analyzer.Fingerprint("laptop-7", eve),
+	}, a.Violations[0])
+	assert.Equal(t, "5.2.7", a.Violations[2].Control)
+	for i, v := range a.Violations {
+		assert.Equal(t, analyzer.Fingerprint(r.Hostname, r.Violations[i]), analyzer.Fingerprint(a.Hostname, v),
+			"a recurring finding still matches")
+	}
+	assert.Equal(t, []RunError{{Stage: "collect", Subsystem: "packages"}}, a.Errors)
+	require.NotNil(t, a.Health)
+	assert.Equal(t, "osquery", a.Health.Collector)
+	assert.Equal(t, []DeliveryStats{{Stage: "notify", Destination: "slack", Sent: 3, Failed: 1}}, a.Health.Deliveries)
+	assert.Equal(t, "eve", r.Users[1].Username, "the original is left alone")
+	assert.NotEmpty(t, r.Health.Deliveries[0].LastError)
+
+	assert.Equal(t, a, a.Anonymize(), "idempotent")
+}
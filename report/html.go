@@ -0,0 +1,142 @@
+package report
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+
+	"compliance-agent/analyzer"
+)
+
+// htmlTemplate is a single self-contained page: inline CSS, no scripts or
+// external assets, so the file can be emailed or archived as evidence.
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"sevClass": func(s analyzer.Severity) string {
+		if s == "" {
+			return "sev-medium"
+		}
+		return "sev-" + string(s)
+	},
+	"join": strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Compliance Report — {{.Hostname}}</title>
+<style>
+body { font-family: -apple-system, Segoe UI, Helvetica, Arial, sans-serif; margin: 2rem; color: #222; }
+h1 { margin-bottom: .2rem; }
+.summary { display: flex; gap: 1rem; flex-wrap: wrap; margin: 1rem 0 2rem; }
+.card { border: 1px solid #ddd; border-radius: 6px; padding: .6rem 1rem; min-width: 8rem; }
+.card b { display: block; font-size: 1.4rem; }
+.ok { color: #2e7d32; } .bad { color: #c62828; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5rem; }
+th, td { border: 1px solid #ddd; padding: .35rem .6rem; text-align: left; font-size: .9rem; vertical-align: top; }
+th { background: #f5f5f5; }
+details { margin-bottom: 1rem; }
+summary { cursor: pointer; font-weight: 600; }
+.sev-critical { background: #8b0000; color: #fff; }
+.sev-high { background: #e53935; color: #fff; }
+.sev-medium { background: #fb8c00; color: #fff; }
+.sev-low { background: #1e88e5; color: #fff; }
+.sev-info { background: #9e9e9e; color: #fff; }
+.pill { border-radius: 3px; padding: 0 .4rem; font-size: .8rem; }
+</style>
+</head>
+<body>
+<h1>Compliance Report</h1>
+<div>{{.Hostname}} · generated {{.GeneratedAt.Format "2006-01-02 15:04:05 UTC"}}{{if eq .Scope "user"}} · <b>user-scope scan</b>{{with .UserScope}} ({{.Username}}){{end}}{{end}}{{if eq .Scope "image"}} · <b>container image</b>{{with .Image}}{{with .ID}} ({{.}}){{end}}{{end}}{{end}}{{with .Root}} · <b>mounted root {{.}}</b>{{end}}{{if .SupportTier}} · <b>{{.SupportTier}} support tier: reduced-fidelity report</b>{{end}}{{if .Anonymized}} · <b>anonymized: metrics and fingerprints only</b>{{end}}</div>
+
+<div class="summary">
+  <div class="card"><b class="{{if .Violations}}bad{{else}}ok{{end}}">{{len .Violations}}</b>violations</div>
+{{- with .Metrics}}
+  <div class="card"><b>{{.Users}}</b>users</div>
+  <div class="card"><b>{{.Processes}}</b>processes</div>
+  <div class="card"><b>{{.OpenPorts}}</b>open ports</div>
+  <div class="card"><b>{{.Packages}}</b>packages</div>
+{{- else}}
+  <div class="card"><b>{{len .Users}}</b>users</div>
+  <div class="card"><b>{{len .Processes}}</b>processes</div>
+  <div class="card"><b>{{len .OpenPorts}}</b>open ports</div>
+  <div class="card"><b>{{len .Packages}}</b>packages</div>
+{{- end}}
+</div>
+
+<h2>Violations</h2>
+{{if not .Groups}}<p class="ok">No violations detected.</p>{{end}}
+{{range .Groups}}
+<h3>{{.Category}} ({{len .Violations}})</h3>
+<table>
+<tr><th>Risk</th><th>Severity</th><th>User</th><th>Message</th></tr>
+{{range .Violations}}<tr><td>{{if .Risk}}{{.Risk}}{{end}}</td><td><span class="pill {{sevClass .Severity}}">{{or .Severity "medium"}}</span></td><td>{{.User}}</td><td>{{or .Message .Fingerprint}}</td></tr>
+{{end}}</table>
+{{end}}
+
+{{if .NotApplicable}}
+<h2>Not applicable</h2>
+<table>
+<tr><th>Status</th><th>Check</th><th>Reason</th></tr>
+{{range .NotApplicable}}<tr><td><span class="pill sev-info">N/A</span></td><td>{{.Kind}} {{.Name}}</td><td>{{.Reason}}</td></tr>
+{{end}}</table>
+{{end}}
+
+{{if .Unavailable}}
+<h2>Not collected</h2>
+<p>Only a running system has these, so this scan couldn't check them: {{join .Unavailable ", "}}.</p>
+{{end}}
+
+{{if .Errors}}
+<h2>Run errors</h2>
+<table>
+<tr><th>Stage</th><th>Subsystem</th><th>Message</th></tr>
+{{range .Errors}}<tr><td>{{.Stage}}</td><td>{{.Subsystem}}</td><td>{{.Message}}</td></tr>
+{{end}}</table>
+{{end}}
+
+<h2>Inventory</h2>
+<details><summary>Users ({{len .Users}})</summary>
+<table>
+<tr><th>Username</th><th>UID</th><th>GID</th><th>Home</th><th>Shell</th></tr>
+{{range .Users}}<tr><td>{{.Username}}</td><td>{{.UID}}</td><td>{{.GID}}</td><td>{{.Directory}}</td><td>{{.Shell}}</td></tr>
+{{end}}</table>
+</details>
+<details><summary>Processes ({{len .Processes}})</summary>
+<table>
+<tr><th>PID</th><th>Name</th><th>Owner</th><th>Command line</th></tr>
+{{range .Processes}}<tr><td>{{.PID}}</td><td>{{.Name}}</td><td>{{or .User .UID}}</td><td>{{.Cmdline}}</td></tr>
+{{end}}</table>
+</details>
+<details><summary>Open ports ({{len .OpenPorts}})</summary>
+<table>
+{{if .PortBindings}}<tr><th>Port</th><th>Protocol</th><th>Address</th><th>PID</th><th>Process</th></tr>
+{{range .PortBindings}}<tr><td>{{.Port}}</td><td>{{.Protocol}}</td><td>{{.Address}}</td><td>{{if gt .PID 0}}{{.PID}}{{end}}</td><td>{{.Process}}</td></tr>
+{{end}}{{else}}<tr><th>Port</th></tr>
+{{range .OpenPorts}}<tr><td>{{.}}</td></tr>
+{{end}}{{end}}</table>
+</details>
+<details><summary>Packages ({{len .Packages}})</summary>
+<table>
+<tr><th>Name</th><th>Version</th><th>Source</th><th>Arch</th></tr>
+{{range .Packages}}<tr><td>{{.Name}}</td><td>{{.Version}}</td><td>{{.Source}}</td><td>{{.Arch}}</td></tr>
+{{end}}</table>
+</details>
+</body>
+</html>
+`))
+
+// RenderHTML produces a self-contained HTML report: summary header,
+// violations grouped by category with the riskiest group and finding
+// first, and collapsible inventory sections.
+func (r *ComplianceReport) RenderHTML() ([]byte, error) {
+	groups := analyzer.GroupByRisk(r.Violations)
+
+	var buf bytes.Buffer
+	err := htmlTemplate.Execute(&buf, struct {
+		*ComplianceReport
+		Groups []analyzer.RiskGroup
+	}{r, groups})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,59 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/collector"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderHTML_GroupsAndEscapes(t *testing.T) {
+	r := ComplianceReport{
+		GeneratedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Hostname:    "web-1",
+		Users:       []collector.User{{Username: "root", UID: 0}},
+		OpenPorts:   []int{22, 8080},
+		PortBindings: []collector.PortBinding{
+			{Port: 22, Protocol: "tcp", Address: "0.0.0.0"},
+			{Port: 8080, Protocol: "tcp", Address: "127.0.0.1"},
+		},
+		Violations: []analyzer.Violation{
+			{Category: "port", Severity: analyzer.SeverityMedium, Message: "unexpected open port: 8080"},
+			{Category: "user", Severity: analyzer.SeverityHigh, Message: "unexpected user present: <script>"},
+		},
+	}
+	b, err := r.RenderHTML()
+	require.NoError(t, err)
+	html := string(b)
+
+	assert.Contains(t, html, "<title>Compliance Report — web-1</title>")
+	assert.Contains(t, html, "<h3>port (1)</h3>")
+	assert.Contains(t, html, "<h3>user (1)</h3>")
+	assert.Contains(t, html, "sev-high")
+	assert.Contains(t, html, "&lt;script&gt;")
+	assert.NotContains(t, html, "<script>")
+	assert.Contains(t, html, "<details><summary>Open ports (2)</summary>")
+	assert.Contains(t, html, "<td>8080</td><td>tcp</td><td>127.0.0.1</td>")
+	assert.NotContains(t, html, "reduced-fidelity")
+	assert.NotContains(t, html, "Not applicable")
+
+	r.NotApplicable = []analyzer.NotApplicable{{Kind: "rule", Name: "rdp-exposed", Reason: "ubuntu is not one of windows"}}
+	b, err = r.RenderHTML()
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "<td>rule rdp-exposed</td><td>ubuntu is not one of windows</td>")
+
+	r.SupportTier = collector.MinimalTier
+	b, err = r.RenderHTML()
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "minimal support tier: reduced-fidelity report")
+
+	r.Root, r.Unavailable = "/mnt/target", []string{"processes", "port_bindings"}
+	b, err = r.RenderHTML()
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "<b>mounted root /mnt/target</b>")
+	assert.Contains(t, string(b), "couldn't check them: processes, port_bindings.")
+}
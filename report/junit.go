@@ -0,0 +1,201 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strings"
+
+	"compliance-agent/analyzer"
+)
+
+// junitTestSuites is the JUnit XML layout Jenkins, GitLab CI, GitHub
+// Actions test reporters and Azure Pipelines read.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Hostname   string          `xml:"hostname,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+	Skipped   *junitSkipped `xml:"skipped"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// RenderJUnit renders the report as a JUnit XML test report, one suite
+// for the host, so CI shows a scan in its native test views. Each of the
+// policy's checks (see analyzer.Policies.Checks) is a test case, failed
+// by the violations it matches; checks not applicable to the host are
+// skipped, as are checks whose data an image or --root scan can't
+// collect, and collectors that failed are errors. A report read back from
+// disk has no checks, so only its violations' categories are listed.
+func (r *ComplianceReport) RenderJUnit() ([]byte, error) {
+	suite := junitTestSuite{
+		Name:     r.Hostname,
+		Hostname: r.Hostname,
+	}
+	if !r.GeneratedAt.IsZero() {
+		suite.Timestamp = r.GeneratedAt.UTC().Format("2006-01-02T15:04:05")
+	}
+	for _, p := range []junitProperty{{"platform", r.Platform}, {"scope", r.Scope}} {
+		if p.Value != "" {
+			suite.Properties = append(suite.Properties, p)
+		}
+	}
+	if r.Agent != nil {
+		suite.Properties = append(suite.Properties, junitProperty{"agent_version", r.Agent.Version})
+	}
+
+	matched := make([]bool, len(r.Violations))
+	for _, c := range r.Checks {
+		tc := junitTestCase{Name: c.Name, ClassName: r.Hostname}
+		var failed []analyzer.Violation
+		for i, v := range r.Violations {
+			if !matched[i] && c.Matches(v) {
+				matched[i] = true
+				failed = append(failed, v)
+			}
+		}
+		if len(failed) > 0 {
+			tc.Failure = junitFailure(failed)
+		} else if why, ok := r.notApplicable(c); ok {
+			tc.Skipped = &junitSkipped{Message: why}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	// Violations no check claims (none at all when the checks weren't
+	// recorded) are grouped by category.
+	var categories []string
+	byCategory := map[string][]analyzer.Violation{}
+	for i, v := range r.Violations {
+		if matched[i] {
+			continue
+		}
+		if _, ok := byCategory[v.Category]; !ok {
+			categories = append(categories, v.Category)
+		}
+		byCategory[v.Category] = append(byCategory[v.Category], v)
+	}
+	for _, cat := range categories {
+		suite.Cases = append(suite.Cases, junitTestCase{
+			Name: cat, ClassName: r.Hostname, Failure: junitFailure(byCategory[cat]),
+		})
+	}
+	for _, e := range r.Errors {
+		suite.Cases = append(suite.Cases, junitTestCase{
+			Name: e.Stage + "/" + e.Subsystem, ClassName: r.Hostname,
+			Error: &junitProblem{Message: e.Message, Type: e.Stage, Text: e.Stack},
+		})
+	}
+
+	for _, tc := range suite.Cases {
+		suite.Tests++
+		switch {
+		case tc.Failure != nil:
+			suite.Failures++
+		case tc.Error != nil:
+			suite.Errors++
+		case tc.Skipped != nil:
+			suite.Skipped++
+		}
+	}
+	doc := junitTestSuites{
+		Name: "compliance-agent", Tests: suite.Tests, Failures: suite.Failures,
+		Errors: suite.Errors, Skipped: suite.Skipped, Suites: []junitTestSuite{suite},
+	}
+	b, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// junitFailure is one failure for a check's violations: the worst as the
+// message, with every violation listed in the body.
+func junitFailure(vs []analyzer.Violation) *junitProblem {
+	worst := vs[0]
+	var b strings.Builder
+	for _, v := range vs {
+		if v.Severity.Rank() > worst.Severity.Rank() {
+			worst = v
+		}
+		sev := v.Severity
+		if sev == "" {
+			sev = analyzer.SeverityMedium
+		}
+		fmt.Fprintf(&b, "[%s] %s\n", sev, violationText(v))
+	}
+	f := &junitProblem{Message: violationText(worst), Type: string(worst.Severity), Text: b.String()}
+	if f.Type == "" {
+		f.Type = string(analyzer.SeverityMedium)
+	}
+	if len(vs) > 1 {
+		f.Message = fmt.Sprintf("%d violations; worst: %s", len(vs), violationText(worst))
+	}
+	return f
+}
+
+// violationText is v's message, or its fingerprint once anonymized.
+func violationText(v analyzer.Violation) string {
+	if v.Message == "" && v.Fingerprint != "" {
+		return "fingerprint " + v.Fingerprint
+	}
+	return v.Message
+}
+
+// notApplicable finds why check c wasn't run: its rule or script doesn't
+// apply to the host, its benchmark profile was left out, or its data
+// can't be collected by this kind of scan.
+func (r *ComplianceReport) notApplicable(c analyzer.Check) (string, bool) {
+	if c.Dataset != "" && slices.Contains(r.Unavailable, c.Dataset) {
+		what := "a mounted root"
+		if r.Scope == "image" {
+			what = "an image"
+		}
+		return c.Dataset + " can't be collected from " + what, true
+	}
+	kind, rest, _ := strings.Cut(c.Name, "/")
+	for _, na := range r.NotApplicable {
+		switch {
+		case kind == "rules" && na.Kind == "rule" && na.Name == rest,
+			kind == "scripts" && na.Kind == "script" && na.Name == rest,
+			kind == "profiles" && na.Kind == "profile" && strings.HasPrefix(rest, na.Name+"/"):
+			return na.Reason, true
+		}
+	}
+	return "", false
+}
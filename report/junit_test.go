@@ -0,0 +1,95 @@
+package report
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderJUnit(t *testing.T) {
+	policies := analyzer.Policies{
+		SSHD:    analyzer.SSHDPolicy{MaxAuthTries: 4},
+		Rules:   []analyzer.Rule{{Name: "telnet-running"}},
+		Scripts: []analyzer.Script{{Name: "gatekeeper"}},
+	}
+	r := ComplianceReport{
+		GeneratedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Hostname:    "web-1",
+		Platform:    "linux",
+		Checks:      policies.Checks(),
+		Violations: []analyzer.Violation{
+			{Category: "user", Severity: analyzer.SeverityHigh, Message: "unexpected user present: eve"},
+			{Category: "ssh_max_auth_tries", Severity: analyzer.SeverityMedium, Message: "MaxAuthTries is 6"},
+			{Category: "ssh_root_login", Severity: analyzer.SeverityHigh, Message: "PermitRootLogin is yes"},
+			{Category: "agent", Message: "osquery unavailable & falling back"},
+		},
+		NotApplicable: []analyzer.NotApplicable{{Kind: "script", Name: "gatekeeper", Reason: "linux is not one of darwin"}},
+		Errors:        []RunError{{Stage: "collect", Subsystem: "packages", Message: "dpkg: exit status 2"}},
+	}
+	b, err := r.RenderJUnit()
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `<?xml version="1.0" encoding="UTF-8"?>`)
+	assert.Contains(t, string(b), "&amp; falling back", "escaped")
+
+	var doc junitTestSuites
+	require.NoError(t, xml.Unmarshal(b, &doc))
+	assert.Equal(t, 7, doc.Tests)
+	assert.Equal(t, 3, doc.Failures)
+	assert.Equal(t, 1, doc.Errors)
+	assert.Equal(t, 1, doc.Skipped)
+	require.Len(t, doc.Suites, 1)
+	suite := doc.Suites[0]
+	assert.Equal(t, "web-1", suite.Name)
+	assert.Equal(t, "2026-03-01T12:00:00", suite.Timestamp)
+	assert.Contains(t, suite.Properties, junitProperty{"platform", "linux"})
+
+	cases := map[string]junitTestCase{}
+	for _, c := range suite.Cases {
+		cases[c.Name] = c
+		assert.Equal(t, "web-1", c.ClassName)
+	}
+	require.NotNil(t, cases["allowed_users"].Failure)
+	assert.Equal(t, "high", cases["allowed_users"].Failure.Type)
+	assert.Nil(t, cases["allowed_ports"].Failure, "passed")
+	assert.Nil(t, cases["allowed_ports"].Skipped)
+
+	sshd := cases["sshd"].Failure
+	require.NotNil(t, sshd)
+	assert.Equal(t, "high", sshd.Type, "the worst violation")
+	assert.Equal(t, "2 violations; worst: PermitRootLogin is yes", sshd.Message)
+	assert.Equal(t, "[medium] MaxAuthTries is 6\n[high] PermitRootLogin is yes\n", sshd.Text)
+
+	assert.Nil(t, cases["rules/telnet-running"].Failure)
+	require.NotNil(t, cases["scripts/gatekeeper"].Skipped)
+	assert.Equal(t, "linux is not one of darwin", cases["scripts/gatekeeper"].Skipped.Message)
+	require.NotNil(t, cases["agent"].Failure, "a violation no check claims")
+	assert.Equal(t, "medium", cases["agent"].Failure.Type)
+	require.NotNil(t, cases["collect/packages"].Error)
+	assert.Equal(t, "dpkg: exit status 2", cases["collect/packages"].Error.Message)
+
+	r.Checks = analyzer.Policies{RequireFirewallEnabled: true}.Checks()
+	r.Scope, r.Unavailable = "image", []string{"processes", "firewall"}
+	b, err = r.RenderJUnit()
+	require.NoError(t, err)
+	var image junitTestSuites
+	require.NoError(t, xml.Unmarshal(b, &image))
+	require.NotNil(t, image.Suites[0].Cases[2].Skipped)
+	assert.Equal(t, "firewall can't be collected from an image", image.Suites[0].Cases[2].Skipped.Message)
+
+	// A report read back from disk lists only what failed.
+	r.Checks = nil
+	b, err = r.RenderJUnit()
+	require.NoError(t, err)
+	var saved junitTestSuites
+	require.NoError(t, xml.Unmarshal(b, &saved))
+	var names []string
+	for _, c := range saved.Suites[0].Cases {
+		names = append(names, c.Name)
+	}
+	assert.Equal(t, []string{"user", "ssh_max_auth_tries", "ssh_root_login", "agent", "collect/packages"}, names)
+}
@@ -0,0 +1,133 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"compliance-agent/analyzer"
+)
+
+var markdownFuncs = map[string]any{
+	// cell makes v safe inside a table cell: pipes escaped, line breaks
+	// flattened, empty shown as a dash.
+	"cell": func(v any) string {
+		s := strings.TrimSpace(fmt.Sprint(v))
+		if s == "" {
+			return "—"
+		}
+		s = strings.ReplaceAll(s, "|", `\|`)
+		return strings.Join(strings.Fields(s), " ")
+	},
+	"sev": func(s analyzer.Severity) analyzer.Severity {
+		if s == "" {
+			return analyzer.SeverityMedium
+		}
+		return s
+	},
+	"risk": func(f float64) string {
+		if f == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%.1f", f)
+	},
+	"join": strings.Join,
+}
+
+var markdownTemplate = template.Must(template.New("report").Funcs(markdownFuncs).Parse(`# Compliance report: {{cell .Hostname}}
+
+| | |
+|---|---|
+| Host | {{cell .Hostname}} |
+| Generated | {{.GeneratedAt.UTC.Format "2006-01-02 15:04:05 UTC"}} |
+{{- with .Platform}}
+| Platform | {{cell .}} |{{end}}
+{{- with .OSVersion}}
+| OS | {{cell .Name}} {{cell .Version}}{{with .Kernel}} (kernel {{cell .}}){{end}} |{{end}}
+{{- with .Scope}}
+| Scope | {{cell .}} |{{end}}
+{{- with .Image}}
+| Image | {{cell .Ref}}{{with .ID}} ({{cell .}}){{end}} |{{end}}
+{{- with .Root}}
+| Mounted root | {{cell .}} |{{end}}
+{{- with .SupportTier}}
+| Support tier | {{cell .}}: reduced-fidelity report |{{end}}
+{{- with .Agent}}
+| Agent | {{cell .Version}} |{{end}}
+{{- if .Anonymized}}
+| Anonymized | metrics and fingerprints only |{{end}}
+
+**{{len .Violations}} violation{{if ne (len .Violations) 1}}s{{end}}**
+{{- range .Severities}} · {{.Count}} {{.Severity}}{{end}} ·
+{{- with .Metrics}} {{.Users}} users · {{.Processes}} processes · {{.OpenPorts}} open ports · {{.Packages}} packages
+{{- else}} {{len .Users}} users · {{len .Processes}} processes · {{len .OpenPorts}} open ports · {{len .Packages}} packages{{end}}
+{{- if .Errors}} · {{len .Errors}} run error{{if ne (len .Errors) 1}}s{{end}}{{end}}
+
+## Violations
+{{if not .Groups}}
+No violations detected.
+{{else}}
+| Risk | Severity | Category | User | Message |
+|---|---|---|---|---|
+{{range .Groups}}{{range .Violations}}| {{risk .Risk}} | {{sev .Severity}} | {{cell .Category}}{{with .Control}} {{cell .}}{{end}} | {{with .User}}{{cell .}}{{end}} | {{cell (or .Message .Fingerprint)}} |
+{{end}}{{end}}{{end}}
+{{- if .NotApplicable}}
+## Not applicable
+
+| Check | Reason |
+|---|---|
+{{range .NotApplicable}}| {{cell .Kind}} {{cell .Name}} | {{cell .Reason}} |
+{{end}}{{end}}
+{{- if .Unavailable}}
+## Not collected
+
+Only a running system has these, so this scan couldn't check them: {{join .Unavailable ", "}}.
+{{end}}
+{{- if .Errors}}
+## Run errors
+
+| Stage | Subsystem | Message |
+|---|---|---|
+{{range .Errors}}| {{cell .Stage}} | {{cell .Subsystem}} | {{cell .Message}} |
+{{end}}{{end}}`))
+
+// severityCount is how many violations a report has of one severity.
+type severityCount struct {
+	Severity analyzer.Severity
+	Count    int
+}
+
+// ToMarkdown renders the report as GitHub-flavored Markdown for pull
+// requests, wikis and chat: a table of host metadata, a line of counts,
+// the violations riskiest first, and what wasn't checked or failed.
+// Inventory is left out; the counts line says how big it is.
+func (r *ComplianceReport) ToMarkdown() ([]byte, error) {
+	counts := map[analyzer.Severity]int{}
+	for _, v := range r.Violations {
+		sev := v.Severity
+		if sev == "" {
+			sev = analyzer.SeverityMedium
+		}
+		counts[sev]++
+	}
+	var severities []severityCount
+	for _, sev := range []analyzer.Severity{
+		analyzer.SeverityCritical, analyzer.SeverityHigh, analyzer.SeverityMedium, analyzer.SeverityLow, analyzer.SeverityInfo,
+	} {
+		if counts[sev] > 0 {
+			severities = append(severities, severityCount{sev, counts[sev]})
+		}
+	}
+
+	var buf bytes.Buffer
+	err := markdownTemplate.Execute(&buf, struct {
+		*ComplianceReport
+		Groups     []analyzer.RiskGroup
+		Severities []severityCount
+	}{r, analyzer.GroupByRisk(r.Violations), severities})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
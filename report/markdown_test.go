@@ -0,0 +1,57 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/collector"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToMarkdown(t *testing.T) {
+	r := ComplianceReport{
+		GeneratedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Hostname:    "web-1",
+		Platform:    "linux",
+		Scope:       "system",
+		Agent:       &AgentInfo{Version: "1.4.0"},
+		OSVersion:   &collector.OSVersion{Name: "Ubuntu", Version: "22.04.4", Kernel: "5.15.0-105"},
+		Users:       []collector.User{{Username: "root"}, {Username: "eve"}},
+		OpenPorts:   []int{22},
+		Violations: []analyzer.Violation{
+			{Category: "port", Severity: analyzer.SeverityMedium, Message: "unexpected open port: 8080", Risk: 4},
+			{Category: "user", Severity: analyzer.SeverityCritical, User: "eve", Message: "unexpected user | present: eve", Risk: 9},
+			{Category: "cis", Control: "5.2.7", Message: "root login\npermitted"},
+		},
+		NotApplicable: []analyzer.NotApplicable{{Kind: "rule", Name: "gatekeeper", Reason: "needs darwin"}},
+		Errors:        []RunError{{Stage: "collect", Subsystem: "packages", Message: "dpkg: not found"}},
+	}
+	b, err := r.ToMarkdown()
+	require.NoError(t, err)
+	md := string(b)
+
+	assert.True(t, strings.HasPrefix(md, "# Compliance report: web-1\n"))
+	assert.Contains(t, md, "| Generated | 2026-03-01 12:00:00 UTC |\n")
+	assert.Contains(t, md, "| OS | Ubuntu 22.04.4 (kernel 5.15.0-105) |\n")
+	assert.Contains(t, md, "**3 violations** · 1 critical · 2 medium · 2 users · 0 processes · 1 open ports · 0 packages · 1 run error\n")
+	assert.NotContains(t, md, "| Image |")
+
+	rows := strings.Split(md[strings.Index(md, "| Risk |"):], "\n")
+	require.Greater(t, len(rows), 5)
+	assert.Equal(t, `| 9.0 | critical | user | eve | unexpected user \| present: eve |`, rows[2], "riskiest first, pipes escaped")
+	assert.Equal(t, "| 4.0 | medium | port |  | unexpected open port: 8080 |", rows[3])
+	assert.Equal(t, "|  | medium | cis 5.2.7 |  | root login permitted |", rows[4], "one line per row")
+	assert.Contains(t, md, "## Not applicable\n\n| Check | Reason |\n|---|---|\n| rule gatekeeper | needs darwin |\n")
+	assert.Contains(t, md, "| collect | packages | dpkg: not found |\n")
+
+	clean := ComplianceReport{GeneratedAt: r.GeneratedAt, Hostname: "web-2"}
+	b, err = clean.ToMarkdown()
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "**0 violations** · 0 users")
+	assert.Contains(t, string(b), "No violations detected.")
+	assert.NotContains(t, string(b), "## Run errors")
+}
@@ -0,0 +1,553 @@
+//go:build !no_pdf && !slim
+
+package report
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/buildinfo"
+)
+
+func init() { buildinfo.Register("pdf") }
+
+// The PDF is written by hand, like the SBOM and JUnit formats: A4 pages
+// set in the two Helvetica faces every PDF reader has built in, so
+// nothing needs embedding and no PDF library is linked in.
+const (
+	pdfPageW, pdfPageH = 595.0, 842.0
+	pdfMargin          = 50.0
+	// pdfFooter is how far below the text column the page footer sits.
+	pdfFooter = 20.0
+	// pdfMaxCellLines caps a table cell, so one huge message can't make a
+	// row taller than a page.
+	pdfMaxCellLines = 20
+)
+
+type pdfColor [3]float64
+
+var (
+	pdfBlack = pdfColor{0, 0, 0}
+	pdfWhite = pdfColor{1, 1, 1}
+	pdfGray  = pdfColor{0.38, 0.38, 0.38}
+	pdfRule  = pdfColor{0.85, 0.85, 0.85}
+	pdfShade = pdfColor{0.96, 0.96, 0.96}
+)
+
+// severityColors match the HTML report's severity badges.
+var severityColors = map[analyzer.Severity]pdfColor{
+	analyzer.SeverityCritical: {0.545, 0, 0},
+	analyzer.SeverityHigh:     {0.898, 0.224, 0.208},
+	analyzer.SeverityMedium:   {0.984, 0.549, 0},
+	analyzer.SeverityLow:      {0.118, 0.533, 0.898},
+	analyzer.SeverityInfo:     {0.62, 0.62, 0.62},
+}
+
+var severityOrder = []analyzer.Severity{
+	analyzer.SeverityCritical, analyzer.SeverityHigh, analyzer.SeverityMedium, analyzer.SeverityLow, analyzer.SeverityInfo,
+}
+
+// RenderPDF renders the report as a PDF for audit evidence packages: a
+// summary page with the host's metadata, counts and charts of violations
+// by severity and category, then tables of the violations riskiest first,
+// what wasn't checked, and what failed. Like the Markdown report, it
+// leaves the inventory out. Generating the same report twice gives the
+// same bytes.
+func (r *ComplianceReport) RenderPDF() ([]byte, error) {
+	w := &pdfWriter{}
+	w.newPage()
+
+	host := r.Hostname
+	if host == "" {
+		host = "unknown host"
+	}
+	w.line(24, true, pdfBlack, "Compliance report")
+	w.y -= 4
+	w.line(14, true, pdfBlack, host)
+	w.y -= 10
+
+	meta := [][2]string{{"Generated", r.GeneratedAt.UTC().Format("2006-01-02 15:04:05 UTC")}}
+	if r.Platform != "" {
+		meta = append(meta, [2]string{"Platform", r.Platform})
+	}
+	if ver := r.OSVersion; ver != nil {
+		v := strings.TrimSpace(ver.Name + " " + ver.Version)
+		if ver.Kernel != "" {
+			v += " (kernel " + ver.Kernel + ")"
+		}
+		meta = append(meta, [2]string{"OS", v})
+	}
+	if r.Scope != "" {
+		meta = append(meta, [2]string{"Scope", r.Scope})
+	}
+	if r.Image != nil {
+		v := r.Image.Ref
+		if r.Image.ID != "" {
+			v += " (" + r.Image.ID + ")"
+		}
+		meta = append(meta, [2]string{"Image", v})
+	}
+	if r.Root != "" {
+		meta = append(meta, [2]string{"Mounted root", r.Root})
+	}
+	if r.SupportTier != "" {
+		meta = append(meta, [2]string{"Support tier", r.SupportTier + ": reduced-fidelity report"})
+	}
+	if r.Agent != nil {
+		meta = append(meta, [2]string{"Agent", r.Agent.Version})
+	}
+	if r.Identity != nil {
+		meta = append(meta, [2]string{"Agent ID", r.Identity.AgentID})
+	}
+	if r.Anonymized {
+		meta = append(meta, [2]string{"Anonymized", "metrics and fingerprints only"})
+	}
+	for _, m := range meta {
+		w.need(14)
+		w.text(pdfMargin, w.y-10, 10, true, pdfBlack, m[0])
+		lines := w.wrap(m[1], 10, false, pdfPageW-2*pdfMargin-90)
+		for i, l := range lines {
+			if i > 0 {
+				w.need(14)
+			}
+			w.text(pdfMargin+90, w.y-10, 10, false, pdfBlack, l)
+			w.y -= 14
+		}
+	}
+	w.y -= 10
+
+	users, procs, ports, pkgs := len(r.Users), len(r.Processes), len(r.OpenPorts), len(r.Packages)
+	if m := r.Metrics; m != nil {
+		users, procs, ports, pkgs = m.Users, m.Processes, m.OpenPorts, m.Packages
+	}
+	counts := fmt.Sprintf("%d violation%s · %d users · %d processes · %d open ports · %d packages",
+		len(r.Violations), plural(len(r.Violations)), users, procs, ports, pkgs)
+	if len(r.Errors) > 0 {
+		counts += fmt.Sprintf(" · %d run error%s", len(r.Errors), plural(len(r.Errors)))
+	}
+	w.paragraph(11, true, counts)
+
+	if len(r.Violations) == 0 {
+		w.y -= 10
+		w.paragraph(11, false, "No violations detected.")
+	} else {
+		bySeverity := map[analyzer.Severity]int{}
+		byCategory := map[string]int{}
+		for _, v := range r.Violations {
+			sev := v.Severity
+			if sev == "" {
+				sev = analyzer.SeverityMedium
+			}
+			bySeverity[sev]++
+			byCategory[v.Category]++
+		}
+		var bars []pdfBar
+		for _, sev := range severityOrder {
+			bars = append(bars, pdfBar{string(sev), bySeverity[sev], severityColors[sev]})
+		}
+		w.heading("Violations by severity")
+		w.barChart(bars)
+
+		bars = bars[:0]
+		for c, n := range byCategory {
+			bars = append(bars, pdfBar{c, n, pdfGray})
+		}
+		sort.Slice(bars, func(i, j int) bool {
+			if bars[i].count != bars[j].count {
+				return bars[i].count > bars[j].count
+			}
+			return bars[i].label < bars[j].label
+		})
+		title := "Violations by category"
+		if len(bars) > 10 {
+			bars, title = bars[:10], "Violations by category (top 10)"
+		}
+		w.heading(title)
+		w.barChart(bars)
+
+		w.newPage()
+		w.heading("Violations")
+		var rows [][]string
+		for _, g := range analyzer.GroupByRisk(r.Violations) {
+			for _, v := range g.Violations {
+				sev := v.Severity
+				if sev == "" {
+					sev = analyzer.SeverityMedium
+				}
+				risk := ""
+				if v.Risk != 0 {
+					risk = fmt.Sprintf("%.1f", v.Risk)
+				}
+				category := v.Category
+				if v.Control != "" {
+					category += " " + v.Control
+				}
+				msg := v.Message
+				if msg == "" {
+					msg = v.Fingerprint
+				}
+				rows = append(rows, []string{risk, string(sev), category, v.User, msg})
+			}
+		}
+		w.table([]pdfColumn{{"Risk", 35}, {"Severity", 55}, {"Category", 95}, {"User", 60}, {"Message", 250}}, rows, 1)
+	}
+
+	if len(r.NotApplicable) > 0 {
+		w.heading("Not applicable")
+		var rows [][]string
+		for _, na := range r.NotApplicable {
+			rows = append(rows, []string{na.Kind + " " + na.Name, na.Reason})
+		}
+		w.table([]pdfColumn{{"Check", 165}, {"Reason", 330}}, rows, -1)
+	}
+	if len(r.Unavailable) > 0 {
+		w.heading("Not collected")
+		w.paragraph(9, false, "Only a running system has these, so this scan couldn't check them: "+strings.Join(r.Unavailable, ", ")+".")
+	}
+	if len(r.Errors) > 0 {
+		w.heading("Run errors")
+		var rows [][]string
+		for _, e := range r.Errors {
+			rows = append(rows, []string{e.Stage, e.Subsystem, e.Message})
+		}
+		w.table([]pdfColumn{{"Stage", 60}, {"Subsystem", 100}, {"Message", 335}}, rows, -1)
+	}
+
+	for i, p := range w.pages {
+		w.page = p
+		w.text(pdfMargin, pdfMargin-pdfFooter, 8, false, pdfGray, "Compliance report: "+host)
+		footer := fmt.Sprintf("Page %d of %d", i+1, len(w.pages))
+		w.text(pdfPageW-pdfMargin-w.width(footer, 8, false), pdfMargin-pdfFooter, 8, false, pdfGray, footer)
+	}
+	return w.bytes("Compliance report: "+host, r.GeneratedAt)
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// pdfWriter lays out text, tables and charts top to bottom over as many
+// pages as they take. y is the top of the free space on the page.
+type pdfWriter struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	y     float64
+	// header, while a table is being drawn, repeats its header row at
+	// the top of each new page.
+	header func()
+}
+
+func (w *pdfWriter) newPage() {
+	w.page = &bytes.Buffer{}
+	w.pages = append(w.pages, w.page)
+	w.y = pdfPageH - pdfMargin
+	if w.header != nil {
+		w.header()
+	}
+}
+
+// need starts a new page unless h more points fit on this one.
+func (w *pdfWriter) need(h float64) {
+	if w.y-h < pdfMargin {
+		w.newPage()
+	}
+}
+
+// text draws s with its baseline at (x, y).
+func (w *pdfWriter) text(x, y, size float64, bold bool, c pdfColor, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(w.page, "%s rg BT /%s %g Tf %.2f %.2f Td %s Tj ET\n", c, font, size, x, y, pdfString(s))
+}
+
+// fill draws a filled rectangle with its lower left corner at (x, y).
+func (w *pdfWriter) fill(x, y, width, height float64, c pdfColor) {
+	fmt.Fprintf(w.page, "%s rg %.2f %.2f %.2f %.2f re f\n", c, x, y, width, height)
+}
+
+// rule draws a hairline across the text column at y.
+func (w *pdfWriter) rule(y float64) {
+	fmt.Fprintf(w.page, "%s RG 0.5 w %.2f %.2f m %.2f %.2f l S\n", pdfRule, pdfMargin, y, pdfPageW-pdfMargin, y)
+}
+
+// line draws one line of text at the left margin and moves below it.
+func (w *pdfWriter) line(size float64, bold bool, c pdfColor, s string) {
+	w.need(size * 1.3)
+	w.text(pdfMargin, w.y-size, size, bold, c, s)
+	w.y -= size * 1.3
+}
+
+// paragraph draws s wrapped to the text column.
+func (w *pdfWriter) paragraph(size float64, bold bool, s string) {
+	for _, l := range w.wrap(s, size, bold, pdfPageW-2*pdfMargin) {
+		w.line(size, bold, pdfBlack, l)
+	}
+}
+
+// heading starts a section, on a new page if there's no room for it and
+// a little of what follows.
+func (w *pdfWriter) heading(s string) {
+	w.y -= 12
+	w.need(60)
+	w.line(13, true, pdfBlack, s)
+	w.y -= 4
+}
+
+type pdfColumn struct {
+	title string
+	width float64
+}
+
+// table draws rows under a header row, wrapping each cell to its column
+// and repeating the header on each page it runs onto. The cells of
+// column sevCol, if not -1, are severities and get their color.
+func (w *pdfWriter) table(cols []pdfColumn, rows [][]string, sevCol int) {
+	const size, leading, pad = 8.0, 10.0, 3.0
+	w.header = func() {
+		h := leading + 2*pad
+		w.fill(pdfMargin, w.y-h, pdfPageW-2*pdfMargin, h, pdfShade)
+		x := pdfMargin
+		for _, c := range cols {
+			w.text(x+pad, w.y-pad-size, size, true, pdfBlack, c.title)
+			x += c.width
+		}
+		w.y -= h
+		w.rule(w.y)
+	}
+	w.need(3 * (leading + 2*pad))
+	w.header()
+	for _, row := range rows {
+		cells := make([][]string, len(cols))
+		lines := 1
+		for i, c := range cols {
+			cells[i] = w.wrap(row[i], size, false, c.width-2*pad)
+			if len(cells[i]) > pdfMaxCellLines {
+				cells[i] = append(cells[i][:pdfMaxCellLines-1], "…")
+			}
+			lines = max(lines, len(cells[i]))
+		}
+		h := float64(lines)*leading + 2*pad
+		w.need(h)
+		x := pdfMargin
+		for i, c := range cols {
+			color := pdfBlack
+			if sev, ok := severityColors[analyzer.Severity(row[i])]; ok && i == sevCol {
+				w.fill(x, w.y-h, c.width, h, sev)
+				color = pdfWhite
+			}
+			for j, l := range cells[i] {
+				if l == "" {
+					continue
+				}
+				w.text(x+pad, w.y-pad-size-float64(j)*leading, size, false, color, l)
+			}
+			x += c.width
+		}
+		w.y -= h
+		w.rule(w.y)
+	}
+	w.header = nil
+}
+
+type pdfBar struct {
+	label string
+	count int
+	color pdfColor
+}
+
+// barChart draws a horizontal bar per label, scaled to the largest count.
+func (w *pdfWriter) barChart(bars []pdfBar) {
+	const labelW, barMax, barH, gap = 110.0, 300.0, 12.0, 5.0
+	most := 0
+	for _, b := range bars {
+		most = max(most, b.count)
+	}
+	for _, b := range bars {
+		w.need(barH + gap)
+		label := b.label
+		if label == "" {
+			label = "(none)"
+		}
+		for w.width(label, 9, false) > labelW-8 && len([]rune(label)) > 1 {
+			label = string([]rune(label)[:len([]rune(label))-2]) + "…"
+		}
+		w.text(pdfMargin, w.y-barH+3, 9, false, pdfBlack, label)
+		width := 0.0
+		if most > 0 {
+			width = barMax * float64(b.count) / float64(most)
+		}
+		if width > 0 {
+			w.fill(pdfMargin+labelW, w.y-barH, width, barH, b.color)
+		}
+		w.text(pdfMargin+labelW+width+5, w.y-barH+3, 9, true, pdfBlack, fmt.Sprint(b.count))
+		w.y -= barH + gap
+	}
+}
+
+// wrap breaks s into lines no wider than width, splitting words that
+// don't fit on a line of their own.
+func (w *pdfWriter) wrap(s string, size float64, bold bool, width float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		if line != "" && w.width(line+" "+word, size, bold) <= width {
+			line += " " + word
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		for w.width(word, size, bold) > width {
+			runes := []rune(word)
+			n := len(runes) - 1
+			for n > 1 && w.width(string(runes[:n]), size, bold) > width {
+				n--
+			}
+			lines = append(lines, string(runes[:n]))
+			word = string(runes[n:])
+		}
+		line = word
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// width is how wide s is set in points.
+func (w *pdfWriter) width(s string, size float64, bold bool) float64 {
+	widths := helveticaWidths
+	if bold {
+		widths = helveticaBoldWidths
+	}
+	total := 0
+	for _, r := range s {
+		if b := winAnsi(r); b >= 32 && b < 127 {
+			total += widths[b-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// bytes assembles the pages into a PDF file.
+func (w *pdfWriter) bytes(title string, created time.Time) ([]byte, error) {
+	var b bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-5 are fixed; each page is then a page object followed by
+	// its content stream.
+	kids := make([]string, len(w.pages))
+	for i := range w.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(w.pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	obj(fmt.Sprintf("<< /Title %s /Producer (compliance-agent) /CreationDate (D:%sZ) >>",
+		pdfString(title), created.UTC().Format("20060102150405")))
+	for i, p := range w.pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %g %g] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageW, pdfPageH, 7+2*i))
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		if _, err := zw.Write(p.Bytes()); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		obj(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", z.Len(), z.Bytes()))
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return b.Bytes(), nil
+}
+
+func (c pdfColor) String() string {
+	return fmt.Sprintf("%.3g %.3g %.3g", c[0], c[1], c[2])
+}
+
+// pdfString is s as a PDF string literal in WinAnsiEncoding, with
+// characters it lacks shown as "?".
+func pdfString(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		switch c := winAnsi(r); {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c >= 32 && c < 127:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "\\%03o", c)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// winAnsiExtras are the characters WinAnsiEncoding puts in 0x80-0x9F.
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// winAnsi is r's byte in WinAnsiEncoding: ASCII and Latin-1 as
+// themselves, control characters as spaces, and "?" for the rest.
+func winAnsi(r rune) byte {
+	switch {
+	case r < 32 || r == 127:
+		return ' '
+	case r < 127, r >= 0xA0 && r <= 0xFF:
+		return byte(r)
+	}
+	if c, ok := winAnsiExtras[r]; ok {
+		return c
+	}
+	return '?'
+}
+
+// helveticaWidths and helveticaBoldWidths are the printable ASCII
+// characters' widths, from space to tilde, in thousandths of the font
+// size, per Adobe's font metrics.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
@@ -0,0 +1,10 @@
+//go:build no_pdf || slim
+
+package report
+
+import "errors"
+
+// RenderPDF fails: the PDF renderer was left out of this build.
+func (r *ComplianceReport) RenderPDF() ([]byte, error) {
+	return nil, errors.New("pdf: not compiled into this agent (built with no_pdf)")
+}
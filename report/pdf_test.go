@@ -0,0 +1,130 @@
+//go:build !no_pdf && !slim
+
+package report
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/collector"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pdfPages checks the file's cross-reference table points at its
+// objects and returns each page's decompressed content.
+func pdfPages(t *testing.T, b []byte) []string {
+	t.Helper()
+	require.True(t, bytes.HasPrefix(b, []byte("%PDF-1.4\n")))
+	require.True(t, bytes.HasSuffix(b, []byte("%%EOF\n")))
+
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(b)
+	require.NotNil(t, m)
+	xref, _ := strconv.Atoi(string(m[1]))
+	require.True(t, bytes.HasPrefix(b[xref:], []byte("xref\n")))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(b[xref:], -1)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		assert.True(t, bytes.HasPrefix(b[off:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+	}
+
+	var pages []string
+	streams := regexp.MustCompile(`(?s)<< /Length (\d+) /Filter /FlateDecode >>\nstream\n`)
+	for _, loc := range streams.FindAllSubmatchIndex(b, -1) {
+		n, _ := strconv.Atoi(string(b[loc[2]:loc[3]]))
+		zr, err := zlib.NewReader(bytes.NewReader(b[loc[1] : loc[1]+n]))
+		require.NoError(t, err)
+		content, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		pages = append(pages, string(content))
+	}
+	assert.Contains(t, string(b), fmt.Sprintf("/Count %d >>", len(pages)))
+	return pages
+}
+
+func TestRenderPDF(t *testing.T) {
+	r := ComplianceReport{
+		GeneratedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Hostname:    "web-1",
+		Platform:    "linux",
+		Agent:       &AgentInfo{Version: "1.4.0"},
+		OSVersion:   &collector.OSVersion{Name: "Ubuntu", Version: "22.04.4", Kernel: "5.15.0-105"},
+		Users:       []collector.User{{Username: "root"}, {Username: "eve"}},
+		Violations: []analyzer.Violation{
+			{Category: "port", Severity: analyzer.SeverityMedium, Message: "unexpected open port: 8080", Risk: 4},
+			{Category: "user", Severity: analyzer.SeverityCritical, User: "eve", Message: "unexpected user (eve) present — café", Risk: 9},
+		},
+		Errors: []RunError{{Stage: "collect", Subsystem: "packages", Message: "dpkg: not found"}},
+	}
+	b, err := r.RenderPDF()
+	require.NoError(t, err)
+	pages := pdfPages(t, b)
+	require.Len(t, pages, 2, "a summary page, then the details")
+
+	assert.Contains(t, pages[0], "(Compliance report) Tj")
+	assert.Contains(t, pages[0], "(Ubuntu 22.04.4 \\(kernel 5.15.0-105\\)) Tj")
+	assert.Contains(t, pages[0], "(2 violations \\267 2 users \\267 0 processes \\267 0 open ports \\267 0 packages \\267 1 run error) Tj")
+	assert.Contains(t, pages[0], "(Violations by severity) Tj")
+	assert.Contains(t, pages[0], "(critical) Tj")
+	assert.Contains(t, pages[0], "(Page 1 of 2) Tj")
+
+	assert.Contains(t, pages[1], "(unexpected user \\(eve\\) present \\227 caf\\351) Tj", "WinAnsi, escaped")
+	assert.Less(t, strings.Index(pages[1], "present"), strings.Index(pages[1], "8080"), "riskiest first")
+	assert.Contains(t, pages[1], "(dpkg: not found) Tj")
+	assert.Contains(t, pages[1], "(Page 2 of 2) Tj")
+
+	again, err := r.RenderPDF()
+	require.NoError(t, err)
+	assert.Equal(t, b, again, "deterministic")
+
+	clean := ComplianceReport{GeneratedAt: r.GeneratedAt, Hostname: "web-2"}
+	b, err = clean.RenderPDF()
+	require.NoError(t, err)
+	pages = pdfPages(t, b)
+	require.Len(t, pages, 1)
+	assert.Contains(t, pages[0], "(No violations detected.) Tj")
+}
+
+func TestRenderPDF_Paginates(t *testing.T) {
+	r := ComplianceReport{Hostname: "web-1"}
+	for i := 0; i < 200; i++ {
+		r.Violations = append(r.Violations, analyzer.Violation{
+			Category: "package", Severity: analyzer.SeverityHigh,
+			Message: fmt.Sprintf("vulnerable package %d: %s", i, strings.Repeat("x", 300)),
+		})
+	}
+	b, err := r.RenderPDF()
+	require.NoError(t, err)
+	pages := pdfPages(t, b)
+	require.Greater(t, len(pages), 5)
+	for _, p := range pages[1:] {
+		assert.Contains(t, p, "(Message) Tj", "the table header repeats on every page")
+	}
+	all := strings.Join(pages, "")
+	for i := 0; i < 200; i++ {
+		assert.Contains(t, all, fmt.Sprintf("(vulnerable package %d:) Tj", i))
+	}
+}
+
+func TestPDFWrap(t *testing.T) {
+	w := &pdfWriter{}
+	lines := w.wrap("the quick brown fox jumps over the lazy dog", 10, false, 100)
+	assert.Equal(t, []string{"the quick brown fox", "jumps over the lazy", "dog"}, lines)
+	for _, l := range w.wrap(strings.Repeat("a", 100), 10, false, 100) {
+		assert.LessOrEqual(t, w.width(l, 10, false), 100.0, "long words are split")
+	}
+	assert.Equal(t, []string{""}, w.wrap("", 10, false, 100))
+	for i, n := range helveticaWidths {
+		assert.Positive(t, n, "width of %q", rune(32+i))
+		assert.Positive(t, helveticaBoldWidths[i], "bold width of %q", rune(32+i))
+	}
+}
@@ -1,33 +1,247 @@
 package report
 
 import (
-    "encoding/json"
-    "os"
-    "time"
+	"encoding/json"
+	"os"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/collector"
+	"compliance-agent/image"
+	"compliance-agent/osv"
 )
 
+// SchemaVersion is the version of the report format this build writes.
+// It goes up when a field is removed, renamed or changes meaning; fields
+// added alongside the existing ones don't change it. `compliance-agent
+// schema json-schema` prints the format as a JSON Schema.
+const SchemaVersion = 1
+
 type ComplianceReport struct {
-    GeneratedAt   time.Time              `json:"generated_at"`
-    Hostname      string                 `json:"hostname"`
-    Users         []map[string]string    `json:"users"`
-    Processes     []map[string]string    `json:"processes"`
-    OpenPorts     []int                  `json:"open_ports"`
-    FirewallRules []string               `json:"firewall_rules,omitempty"`
-    Packages      []map[string]string    `json:"packages,omitempty"`
-    Violations    []map[string]string    `json:"violations"`
-    ExtraMetadata map[string]interface{} `json:"meta,omitempty"`
+	// SchemaVersion is the report format's version, SchemaVersion when
+	// written. Reports from before the field existed are version 1.
+	SchemaVersion int       `json:"schema_version,omitempty"`
+	GeneratedAt   time.Time `json:"generated_at"`
+	Hostname      string    `json:"hostname"`
+	Platform      string    `json:"platform,omitempty"` // runtime.GOOS of the scanned host
+	// Scope is "system" for a privileged host scan, "user" for an
+	// unprivileged scan that only covers the invoking account, or
+	// "image" for a container image's filesystem.
+	Scope string `json:"scope,omitempty"`
+	// SupportTier is "minimal" for reduced-fidelity reports from legacy
+	// UNIX hosts (Solaris, illumos, AIX), which only cover users,
+	// processes, network sockets and packages. Data missing there was
+	// not collected, not found clean.
+	SupportTier string `json:"support_tier,omitempty"`
+	// Agent identifies the binary that produced the report, including
+	// which optional subsystems it was built with.
+	Agent *AgentInfo `json:"agent,omitempty"`
+	// Identity is the agent's stable ID and the machine's hardware
+	// identifiers, which tie reports together where the hostname can't.
+	Identity *Identity `json:"identity,omitempty"`
+	// Image describes the container image a scan-image report covers;
+	// Hostname is then the image reference.
+	Image *image.Info `json:"image,omitempty"`
+	// Root is the mounted filesystem a --root scan read instead of the
+	// running system; Hostname is then the tree's /etc/hostname.
+	Root string `json:"root,omitempty"`
+	// Unavailable lists the datasets the scan was asked for but could
+	// not collect by its nature: what only a running system has, in a
+	// --root or image scan. Their rules had nothing to check.
+	Unavailable []string             `json:"unavailable,omitempty"`
+	UserScope   *collector.UserScope `json:"user_scope,omitempty"`
+	// Accounts holds per-account workstation data, one entry per
+	// interactive user, when workstation rules are in the policy.
+	Accounts []collector.UserScope `json:"accounts,omitempty"`
+	// Power is collected when the policy has laptop rules.
+	Power *collector.PowerSettings `json:"power,omitempty"`
+	// Sharing lists network-reachable sharing services, collected when
+	// the policy prohibits sharing.
+	Sharing []collector.SharingService `json:"sharing,omitempty"`
+	// Bluetooth is collected when the policy has Bluetooth rules.
+	Bluetooth *collector.BluetoothState `json:"bluetooth,omitempty"`
+	// VPN is collected when the policy has VPN rules.
+	VPN *collector.VPNStatus `json:"vpn,omitempty"`
+	// Hosts and Proxy are collected when the policy has hosts file or
+	// proxy rules.
+	Hosts []collector.HostsEntry   `json:"hosts,omitempty"`
+	Proxy *collector.ProxySettings `json:"proxy,omitempty"`
+	// CronJobs are the system's and users' crontab entries, collected
+	// with collect -all and by --root scans.
+	CronJobs []collector.CronJob `json:"cron_jobs,omitempty"`
+	// Connections are established remote connections, collected when
+	// the policy has connection rules and GeoIP-tagged when configured.
+	Connections []collector.Connection `json:"connections,omitempty"`
+	// DNSQueries are sampled recent lookups, collected when the policy
+	// has DNS rules.
+	DNSQueries []collector.DNSQuery `json:"dns_queries,omitempty"`
+	// ARP is the IPv4 neighbor table and default gateway, collected when
+	// the policy has ARP rules.
+	ARP *collector.ARPTable `json:"arp,omitempty"`
+	// Interfaces are the network interfaces, collected when the policy
+	// has interface rules.
+	Interfaces []collector.NetInterface `json:"interfaces,omitempty"`
+	// DiskEncryption lists mounted volumes and their encryption,
+	// collected when the policy requires disk encryption.
+	DiskEncryption []collector.DiskVolume `json:"disk_encryption,omitempty"`
+	// Firewall is the host firewall state, collected when the policy
+	// requires it enabled.
+	Firewall *collector.FirewallStatus `json:"firewall,omitempty"`
+	// SSHD is the SSH server configuration, collected when the policy
+	// has SSH rules and the host runs an SSH server.
+	SSHD *collector.SSHDConfig `json:"sshd,omitempty"`
+	// OSVersion is the OS release and kernel, collected when the policy
+	// sets minimum versions.
+	OSVersion *collector.OSVersion `json:"os_version,omitempty"`
+	// RequiredAgents is what was found of each third-party agent the
+	// policy requires: binary, package version, running state and config
+	// hashes.
+	RequiredAgents []collector.AgentState `json:"required_agents,omitempty"`
+	// Secrets are private keys, keystores, dotenv files and server
+	// certificates found under the policy's paths, by metadata only.
+	Secrets []collector.SecretFile `json:"secrets,omitempty"`
+	// TLSServices are local listeners that answered a TLS handshake, with
+	// the versions and suites they accept; probed when the policy enables
+	// TLS probing.
+	TLSServices []collector.TLSService `json:"tls_services,omitempty"`
+	// WebEndpoints are the local web consoles the policy lists, as
+	// fetched unauthenticated.
+	WebEndpoints []collector.WebEndpoint `json:"web_endpoints,omitempty"`
+	// Vulnerabilities are OSV findings for installed packages, collected
+	// when the policy enables scanning.
+	Vulnerabilities []osv.Finding `json:"vulnerabilities,omitempty"`
+	// Benchmark holds the probe results for the policy's benchmark
+	// profiles (e.g. CIS), keyed by "<profile>/<control>".
+	Benchmark []collector.ProbeResult `json:"benchmark,omitempty"`
+	Users     []collector.User        `json:"users"`
+	Processes []collector.Process     `json:"processes"`
+	// OpenPorts keeps the plain port list existing consumers read;
+	// PortBindings carries the protocol and address behind each one.
+	OpenPorts     []int                   `json:"open_ports"`
+	PortBindings  []collector.PortBinding `json:"port_bindings,omitempty"`
+	FirewallRules []string                `json:"firewall_rules,omitempty"`
+	Packages      []collector.Package     `json:"packages,omitempty"`
+	Violations    []analyzer.Violation    `json:"violations"`
+	// NotApplicable lists the policy's rules and scripts that don't
+	// apply to this host's platform or OS release, and so weren't run.
+	NotApplicable []analyzer.NotApplicable `json:"not_applicable,omitempty"`
+	// Checks are the policy's checks the analysis evaluated, for the
+	// JUnit renderer to list the ones that passed. They aren't saved.
+	Checks        []analyzer.Check       `json:"-"`
+	Errors        []RunError             `json:"errors,omitempty"`
+	ExtraMetadata map[string]interface{} `json:"meta,omitempty"`
+	// Unchanged lists the analyzers whose input was identical to an
+	// earlier daemon scan, so their violations were reused rather than
+	// re-evaluated.
+	Unchanged []UnchangedAnalysis `json:"unchanged,omitempty"`
+	// Anonymized marks a report reduced to Metrics and violation
+	// fingerprints (see Anonymize); its inventory is gone.
+	Anonymized bool     `json:"anonymized,omitempty"`
+	Metrics    *Metrics `json:"metrics,omitempty"`
+	// Health is the agent's own state when it made the report, so fleet
+	// health can be read from the reports themselves.
+	Health *AgentHealth `json:"health,omitempty"`
 }
 
-func (r *ComplianceReport) ToJSON() ([]byte, error) {
-    return json.MarshalIndent(r, "", "  ")
+// RunError records a subsystem failure that was contained during the run
+// (e.g. a collector that panicked on an unusual host) so the report shows
+// which data is missing and why.
+type RunError struct {
+	Stage     string `json:"stage"`     // "collect" | "analyze" | "notify"
+	Subsystem string `json:"subsystem"` // e.g. "users", "ports", "slack"
+	// Code is the error's category (see package errcode), e.g. "timeout"
+	// or "permission-denied", to alert on instead of Message.
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+	Panic   bool   `json:"panic,omitempty"`
+	Stack   string `json:"stack,omitempty"`
 }
 
-func (r *ComplianceReport) SaveToFile(path string) error {
-    data, err := r.ToJSON()
-    if err != nil {
-        return err
-    }
-    return os.WriteFile(path, data, 0644)
+// AgentInfo is the agent version and the optional subsystems compiled
+// into it (see package buildinfo). A slim build lists fewer features, so
+// a missing Rego or history result can be told apart from a failure.
+type AgentInfo struct {
+	Version  string   `json:"version"`
+	Features []string `json:"features"`
+}
+
+// Identity is who a report is from. AgentID is minted on the agent's
+// first scan and kept on disk, so it survives hostname changes; Hardware
+// survives a re-image too. An agent whose saved ID was minted on other
+// hardware, e.g. on a clone of a disk image, mints a new ID and keeps the
+// old one as PreviousAgentID.
+type Identity struct {
+	AgentID         string                  `json:"agent_id"`
+	Since           time.Time               `json:"since"`
+	PreviousAgentID string                  `json:"previous_agent_id,omitempty"`
+	Hardware        *collector.HardwareInfo `json:"hardware,omitempty"`
+}
+
+// AgentHealth is how the agent that made a report is doing: how long it
+// has been running, what it failed to do since its previous report, which
+// configuration and policy it runs, what it could collect, and how its
+// deliveries are going. Deliveries count those before this report; its
+// own are sent after it is made.
+type AgentHealth struct {
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+	// FailedScans counts the scans since the previous report that failed
+	// before producing one.
+	FailedScans int `json:"failed_scans"`
+	// UploadBacklog counts the reports since the last successful upload
+	// that the fleet server didn't receive. The agent doesn't spool
+	// reports, so these are missing there, not waiting to be retried.
+	UploadBacklog int `json:"upload_backlog"`
+	// ConfigSHA256 hashes the effective configuration: the file with
+	// environment overrides and defaults applied.
+	ConfigSHA256 string `json:"config_sha256"`
+	// PolicySource is "builtin", "file", "url" (policy_source) or
+	// "central" (the fleet server); PolicySHA256 hashes the policy
+	// document in force, for any but the built-in one.
+	PolicySource string `json:"policy_source"`
+	PolicySHA256 string `json:"policy_sha256,omitempty"`
+	// Collector is the inventory backend: "osquery", "fleet", "native"
+	// (system commands, when osquery isn't available) or "rootfs".
+	Collector  string            `json:"collector"`
+	Collectors []CollectorStatus `json:"collectors,omitempty"`
+	Deliveries []DeliveryStats   `json:"deliveries,omitempty"`
+}
+
+// CollectorStatus is how one dataset's collection went: "ok", "failed"
+// (see the report's errors) or "unavailable" in a --root scan.
+type CollectorStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
 }
 
+// DeliveryStats counts the attempts to deliver reports to one
+// destination since the agent started. Stage is "export" for sinks and
+// the fleet server and "notify" for alerters.
+type DeliveryStats struct {
+	Stage       string     `json:"stage"`
+	Destination string     `json:"destination"`
+	Sent        int        `json:"sent"`
+	Failed      int        `json:"failed"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastFailure *time.Time `json:"last_failure,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
 
+// UnchangedAnalysis records an analyzer whose result was reused because
+// its input hasn't changed since the scan at Since.
+type UnchangedAnalysis struct {
+	Subsystem string    `json:"subsystem"`
+	Since     time.Time `json:"since"`
+}
+
+func (r *ComplianceReport) ToJSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+func (r *ComplianceReport) SaveToFile(path string) error {
+	data, err := r.ToJSON()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
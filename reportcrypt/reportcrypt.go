@@ -0,0 +1,272 @@
+// Package reportcrypt encrypts reports to one or more recipients'
+// X25519 public keys, so a saved report, or one on its way to the fleet
+// server, can only be read by whoever holds a matching private key.
+//
+// It follows age's construction with the AES-GCM AEAD: the report is
+// sealed with a random 256-bit file key under AES-256-GCM, and the file
+// key is wrapped for each recipient with a key derived (HKDF-SHA256)
+// from an X25519 exchange with a fresh ephemeral key. An encrypted file
+// is a text header, one line per recipient, then the binary payload:
+//
+//	compliance-agent-encrypted/v1
+//	-> X25519 <ephemeral public key> <wrapped file key>
+//	---
+//	<12-byte nonce><ciphertext>
+//
+// with the values base64 (standard, unpadded). The header is the
+// payload's additional data, so a recipient line can't be swapped or
+// dropped unnoticed. Keys are PEM, PKCS #8 for the private key and PKIX
+// for the public one, the formats `openssl genpkey -algorithm x25519`
+// writes.
+package reportcrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ContentType is the Content-Type of an encrypted report upload.
+const ContentType = "application/vnd.compliance-agent.encrypted"
+
+const (
+	magic     = "compliance-agent-encrypted/v1\n"
+	stanza    = "-> X25519 "
+	headerEnd = "---\n"
+	hkdfInfo  = "compliance-agent/v1/X25519"
+	keySize   = 32
+)
+
+// ErrNoKey is returned by Decrypt when the report wasn't encrypted to
+// the key.
+var ErrNoKey = errors.New("report is not encrypted to this key")
+
+var b64 = base64.RawStdEncoding
+
+// IsEncrypted reports whether b is an encrypted report.
+func IsEncrypted(b []byte) bool { return bytes.HasPrefix(b, []byte(magic)) }
+
+// GenerateKey makes a key pair, both PEM.
+func GenerateKey() (privPEM, pubPEM []byte, err error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(priv.PublicKey())
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), nil
+}
+
+// LoadPrivateKey reads a PEM PKCS #8 X25519 private key.
+func LoadPrivateKey(path string) (*ecdh.PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s: not a PEM private key", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	priv, ok := key.(*ecdh.PrivateKey)
+	if !ok || priv.Curve() != ecdh.X25519() {
+		return nil, fmt.Errorf("%s: not an X25519 key", path)
+	}
+	return priv, nil
+}
+
+// ParseRecipient reads a public key given as PEM, as the base64 of the
+// raw 32-byte key or of its PKIX encoding, or as the path of a PEM file.
+func ParseRecipient(s string) (*ecdh.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	var der []byte
+	switch {
+	case strings.HasPrefix(s, "-----BEGIN"):
+		block, _ := pem.Decode([]byte(s))
+		if block == nil {
+			return nil, errors.New("public key: bad PEM")
+		}
+		der = block.Bytes
+	default:
+		if raw, err := base64.StdEncoding.DecodeString(s); err == nil {
+			if len(raw) == keySize {
+				return ecdh.X25519().NewPublicKey(raw)
+			}
+			der = raw
+			break
+		}
+		b, err := os.ReadFile(s)
+		if err != nil {
+			return nil, fmt.Errorf("public key: %w", err)
+		}
+		return ParseRecipient(string(b))
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("public key: %w", err)
+	}
+	pub, ok := key.(*ecdh.PublicKey)
+	if !ok || pub.Curve() != ecdh.X25519() {
+		return nil, errors.New("public key: not an X25519 key")
+	}
+	return pub, nil
+}
+
+// ParseRecipients reads each of keys with ParseRecipient.
+func ParseRecipients(keys []string) ([]*ecdh.PublicKey, error) {
+	out := make([]*ecdh.PublicKey, 0, len(keys))
+	for i, k := range keys {
+		pub, err := ParseRecipient(k)
+		if err != nil {
+			return nil, fmt.Errorf("recipients[%d]: %w", i, err)
+		}
+		out = append(out, pub)
+	}
+	return out, nil
+}
+
+// Encrypt encrypts plaintext so any one of recipients can decrypt it.
+func Encrypt(recipients []*ecdh.PublicKey, plaintext []byte) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("encrypt: no recipients")
+	}
+	fileKey := make([]byte, keySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, err
+	}
+	var header bytes.Buffer
+	header.WriteString(magic)
+	for _, r := range recipients {
+		eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		shared, err := eph.ECDH(r)
+		if err != nil {
+			return nil, err
+		}
+		wrapped, err := seal(wrapKey(shared, eph.PublicKey(), r), make([]byte, 12), fileKey, nil)
+		if err != nil {
+			return nil, err
+		}
+		header.WriteString(stanza + b64.EncodeToString(eph.PublicKey().Bytes()) + " " + b64.EncodeToString(wrapped) + "\n")
+	}
+	header.WriteString(headerEnd)
+
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	ct, err := seal(fileKey, nonce, plaintext, header.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return append(append(header.Bytes(), nonce...), ct...), nil
+}
+
+// Decrypt decrypts what Encrypt wrote with one recipient's private key.
+func Decrypt(key *ecdh.PrivateKey, data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return nil, errors.New("decrypt: not an encrypted report")
+	}
+	end := bytes.Index(data, []byte("\n"+headerEnd))
+	if end < len(magic) {
+		return nil, errors.New("decrypt: truncated header or no recipients")
+	}
+	header, payload := data[:end+1+len(headerEnd)], data[end+1+len(headerEnd):]
+	if len(payload) < 12 {
+		return nil, errors.New("decrypt: truncated payload")
+	}
+	var fileKey []byte
+	for _, line := range strings.Split(string(data[len(magic):end]), "\n") {
+		fields, ok := strings.CutPrefix(line, stanza)
+		if !ok {
+			return nil, fmt.Errorf("decrypt: bad recipient line %q", line)
+		}
+		ephB64, wrappedB64, ok := strings.Cut(fields, " ")
+		ephRaw, err1 := b64.DecodeString(ephB64)
+		wrapped, err2 := b64.DecodeString(wrappedB64)
+		if !ok || err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("decrypt: bad recipient line %q", line)
+		}
+		eph, err := ecdh.X25519().NewPublicKey(ephRaw)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt: %w", err)
+		}
+		shared, err := key.ECDH(eph)
+		if err != nil {
+			continue
+		}
+		// A stanza for another key fails to open; try the next one.
+		if fk, err := open(wrapKey(shared, eph, key.PublicKey()), make([]byte, 12), wrapped, nil); err == nil {
+			fileKey = fk
+			break
+		}
+	}
+	if fileKey == nil {
+		return nil, ErrNoKey
+	}
+	plaintext, err := open(fileKey, payload[:12], payload[12:], header)
+	if err != nil {
+		return nil, errors.New("decrypt: report was modified or is corrupt")
+	}
+	return plaintext, nil
+}
+
+// wrapKey derives the key that wraps the file key for one recipient,
+// binding both public keys as the salt.
+func wrapKey(shared []byte, eph, recipient *ecdh.PublicKey) []byte {
+	salt := append(append([]byte{}, eph.Bytes()...), recipient.Bytes()...)
+	// HKDF-SHA256 (RFC 5869) for a single output block.
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(shared)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write([]byte(hkdfInfo))
+	expand.Write([]byte{1})
+	return expand.Sum(nil)
+}
+
+func seal(key, nonce, plaintext, ad []byte) ([]byte, error) {
+	aead, err := gcm(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, nonce, plaintext, ad), nil
+}
+
+func open(key, nonce, ciphertext, ad []byte) ([]byte, error) {
+	aead, err := gcm(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, nonce, ciphertext, ad)
+}
+
+func gcm(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,87 @@
+package reportcrypt
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func keyPair(t *testing.T) (*ecdh.PrivateKey, *ecdh.PublicKey) {
+	t.Helper()
+	privPEM, pubPEM, err := GenerateKey()
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "report.key")
+	require.NoError(t, os.WriteFile(path, privPEM, 0o600))
+	priv, err := LoadPrivateKey(path)
+	require.NoError(t, err)
+	pub, err := ParseRecipient(string(pubPEM))
+	require.NoError(t, err)
+	return priv, pub
+}
+
+func TestEncrypt(t *testing.T) {
+	alice, alicePub := keyPair(t)
+	bob, bobPub := keyPair(t)
+	eve, _ := keyPair(t)
+	report := []byte(`{"hostname":"web-1","users":[{"username":"root"}]}`)
+
+	enc, err := Encrypt([]*ecdh.PublicKey{alicePub, bobPub}, report)
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(enc))
+	assert.False(t, IsEncrypted(report))
+	assert.NotContains(t, string(enc), "web-1")
+
+	for _, key := range []*ecdh.PrivateKey{alice, bob} {
+		got, err := Decrypt(key, enc)
+		require.NoError(t, err)
+		assert.Equal(t, report, got)
+	}
+	_, err = Decrypt(eve, enc)
+	assert.ErrorIs(t, err, ErrNoKey)
+
+	// Tampering with the payload or the header is caught.
+	bad := bytes.Clone(enc)
+	bad[len(bad)-1] ^= 1
+	_, err = Decrypt(alice, bad)
+	assert.ErrorContains(t, err, "modified")
+	end := bytes.Index(enc, []byte("\n---\n"))
+	dropped := append(bytes.Clone(enc[:bytes.IndexByte(enc[len(magic):], '\n')+len(magic)+1]), enc[end+1:]...)
+	_, err = Decrypt(alice, dropped)
+	assert.Error(t, err, "alice's line is there, but the header changed")
+
+	_, err = Decrypt(alice, report)
+	assert.Error(t, err)
+	_, err = Decrypt(alice, []byte(magic+headerEnd))
+	assert.Error(t, err)
+	_, err = Encrypt(nil, report)
+	assert.Error(t, err)
+}
+
+func TestParseRecipient(t *testing.T) {
+	_, pub := keyPair(t)
+	raw := base64.StdEncoding.EncodeToString(pub.Bytes())
+	got, err := ParseRecipient(raw)
+	require.NoError(t, err)
+	assert.True(t, pub.Equal(got))
+
+	_, pubPEM, err := GenerateKey()
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "report.pub")
+	require.NoError(t, os.WriteFile(path, pubPEM, 0o644))
+	keys, err := ParseRecipients([]string{path, raw})
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+
+	_, err = ParseRecipients([]string{raw, "/no/such/key.pub"})
+	assert.ErrorContains(t, err, "recipients[1]")
+	_, err = ParseRecipient(`-----BEGIN PUBLIC KEY-----
+MCowBQYDK2VwAyEAGb9ECWmEzf6FQbrBZ9w7lshQhqowtrbLDFw4rXAxZuE=
+-----END PUBLIC KEY-----`)
+	assert.ErrorContains(t, err, "not an X25519 key", "an Ed25519 policy key")
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+
+	"compliance-agent/errcode"
+	"compliance-agent/hygiene"
+	"compliance-agent/storage"
+)
+
+// cmdRuleStats implements `compliance-agent rule-stats`: how the fleet
+// policy's checks behave over every host's newest report in the fleet
+// server's database, for policy upkeep.
+func cmdRuleStats(args []string) {
+	fs := flag.NewFlagSet("rule-stats", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to YAML config (optional)")
+	dbPath := fs.String("db", "", "Fleet database to read (overrides server.db_path)")
+	policyPath := fs.String("policy", "", "Policy whose checks to measure (default server.policy_path)")
+	noisy := fs.Float64("noisy", hygiene.DefaultNoisyShare, "List checks failing on at least this share of hosts as noisy")
+	top := fs.Int("top", hygiene.DefaultTop, "List this many of the most suppressed checks")
+	format := fs.String("format", "markdown", "Output format: markdown or json")
+	outPath := fs.String("o", "", "Write to this file instead of stdout")
+	parseFlags(fs, args)
+	if *noisy <= 0 || *noisy > 1 {
+		usageError("-noisy: want a share of hosts such as 0.9, got %v", *noisy)
+	}
+
+	cfg := loadConfig(*configPath)
+	if *policyPath == "" {
+		*policyPath = cfg.Server.PolicyPath
+	}
+	if *policyPath == "" {
+		usageError("no policy to measure: pass -policy or set server.policy_path")
+	}
+	policies := loadPolicies(*policyPath)
+	path := cfg.Server.DBPath
+	if *dbPath != "" {
+		path = *dbPath
+	}
+	if _, err := os.Stat(path); err != nil {
+		fatal(errcode.Errorf(errcode.CollectorUnavailable, "no fleet database at %s: %w", path, err), "rule-stats")
+	}
+	store, err := storage.OpenFleet(path)
+	if err != nil {
+		fatal(err, "rule-stats")
+	}
+	defer store.Close()
+	a, err := hygiene.ForFleet(store, policies, hygiene.Options{NoisyShare: *noisy, Top: *top})
+	if err != nil {
+		store.Close()
+		fatal(err, "rule-stats")
+	}
+
+	var out []byte
+	switch *format {
+	case "markdown", "md":
+		out, err = a.Markdown()
+	case "json":
+		out, err = json.MarshalIndent(a, "", "  ")
+		out = append(out, '\n')
+	default:
+		store.Close()
+		usageError("unknown -format %q (want markdown or json)", *format)
+	}
+	if err != nil {
+		store.Close()
+		fatal(err, "render")
+	}
+	if *outPath == "" {
+		os.Stdout.Write(out)
+		return
+	}
+	if err := os.WriteFile(*outPath, out, 0o644); err != nil {
+		store.Close()
+		fatal(err, "write")
+	}
+	slog.Info("rule stats written", "path", *outPath)
+}
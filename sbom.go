@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/buildinfo"
+	"compliance-agent/config"
+	"compliance-agent/image"
+	"compliance-agent/report"
+	"compliance-agent/sbom"
+)
+
+// cmdSBOM implements `compliance-agent sbom`: the package inventory as a
+// CycloneDX or SPDX SBOM, from a fresh scan of the host or a mounted root, a
+// container image or a saved report or collection, the CycloneDX one
+// optionally uploaded to Dependency-Track.
+func cmdSBOM(args []string) {
+	fs := flag.NewFlagSet("sbom", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to YAML config (optional)")
+	in := fs.String("i", "", "Saved report or collection to convert (default: scan the host now)")
+	img := fs.String("image", "", "Container image (reference, docker save/OCI archive or unpacked root) to describe instead of the host")
+	tmp := fs.String("tmp", "", "With -image, unpack the image under this directory")
+	root := fs.String("root", "", "Describe the filesystem mounted here instead of the running system")
+	format := fs.String("format", "cyclonedx", "SBOM format: cyclonedx or spdx (SPDX 2.3 JSON)")
+	out := fs.String("o", "sbom.cdx.json", "Output file (- for stdout, empty to only submit; sbom.spdx.json by default with -format spdx)")
+	submit := fs.Bool("submit", false, "Upload the SBOM to the Dependency-Track server in sbom.dependency_track")
+	project := fs.String("project", "", "Dependency-Track project name (overrides config)")
+	version := fs.String("project-version", "", "Dependency-Track project version (overrides config)")
+	parseFlags(fs, args)
+	if len(slices.DeleteFunc([]string{*in, *img, *root}, func(s string) bool { return s == "" })) > 1 {
+		usageError("-i, -image and -root are exclusive")
+	}
+	switch *format {
+	case "cyclonedx":
+	case "spdx":
+		if *submit {
+			usageError("-submit needs -format cyclonedx: Dependency-Track reads only CycloneDX")
+		}
+	default:
+		usageError("unknown -format %q (want cyclonedx or spdx)", *format)
+	}
+	if *format == "spdx" {
+		explicit := false
+		fs.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "o" })
+		if !explicit {
+			*out = "sbom.spdx.json"
+		}
+	}
+	if *out == "" && !*submit {
+		usageError("nothing to do: set -o or -submit")
+	}
+	cfg := loadConfig(*configPath)
+	if *root != "" {
+		cfg.Root = *root
+	}
+	if cfg.Root != "" {
+		checkRoot(&cfg.Root)
+	}
+	if *project != "" {
+		cfg.SBOM.DependencyTrack.ProjectName = *project
+	}
+	if *version != "" {
+		cfg.SBOM.DependencyTrack.ProjectVersion = *version
+	}
+	var dt *sbom.DependencyTrack
+	if *submit {
+		var err error
+		if dt, err = sbom.NewDependencyTrack(cfg.SBOM.DependencyTrack); err != nil {
+			fatal(err, "sbom")
+		}
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+	var rep report.ComplianceReport
+	switch {
+	case *in != "":
+		rep = readReport(*in)
+	case *img != "":
+		i, err := image.Open(ctx, *img, *tmp)
+		if err != nil {
+			fatal(err, "sbom")
+		}
+		// Only the inventory is wanted: no policy, so nothing else is
+		// read and no vulnerability lookups are made.
+		rep, err = collectImage(ctx, cfg, analyzer.Policies{}, i)
+		i.Close()
+		if err != nil {
+			fatal(err, "sbom")
+		}
+	default:
+		cfg.History.Path = ""
+		cfg.Evidence.Log = ""
+		s, closeScanner := startScanner(cfg, analyzer.Policies{})
+		var err error
+		rep, err = s.collect(ctx, collectOptions{Packages: true, OSVersion: true})
+		closeScanner()
+		if err != nil {
+			fatal(err, "sbom")
+		}
+	}
+	if len(rep.Packages) == 0 {
+		slog.Warn("no packages the agent could read", "hostname", rep.Hostname)
+	}
+
+	var b []byte
+	var err error
+	if *format == "spdx" {
+		b, err = sbom.SPDX(rep, buildinfo.AgentVersion(), cfg.SBOM)
+	} else {
+		b, err = sbom.CycloneDX(rep, buildinfo.AgentVersion())
+	}
+	if err != nil {
+		fatal(err, "sbom")
+	}
+	switch *out {
+	case "":
+	case "-":
+		if _, err := os.Stdout.Write(append(b, '\n')); err != nil {
+			fatal(err, "write sbom")
+		}
+	default:
+		if err := os.WriteFile(*out, b, 0644); err != nil {
+			fatal(err, "write sbom")
+		}
+		fmt.Fprintf(os.Stderr, "%s: %d package(s); saved SBOM to %s\n", rep.Hostname, len(rep.Packages), *out)
+	}
+	if dt != nil {
+		submitSBOM(ctx, dt, cfg.SBOM.DependencyTrack, rep, b)
+	}
+}
+
+// submitSBOM uploads b to Dependency-Track as the configured project, by
+// default the scanned host or image.
+func submitSBOM(ctx context.Context, dt *sbom.DependencyTrack, cfg config.DependencyTrackConfig, rep report.ComplianceReport, b []byte) {
+	name, version := cfg.ProjectName, cfg.ProjectVersion
+	if name == "" {
+		name = rep.Hostname
+	}
+	if version == "" {
+		version = "latest"
+	}
+	token, err := dt.Submit(ctx, name, version, b)
+	if err != nil {
+		fatal(err, "sbom")
+	}
+	fmt.Fprintf(os.Stderr, "Submitted SBOM for %s %s to Dependency-Track (task %s)\n", name, version, token)
+}
@@ -0,0 +1,125 @@
+package sbom
+
+import (
+	"encoding/json"
+	"time"
+
+	"compliance-agent/report"
+)
+
+// CycloneDXVersion is the CycloneDX specification version written.
+const CycloneDXVersion = "1.5"
+
+// cdxBOM is the subset of a CycloneDX JSON document this agent writes.
+type cdxBOM struct {
+	BOMFormat    string         `json:"bomFormat"`
+	SpecVersion  string         `json:"specVersion"`
+	SerialNumber string         `json:"serialNumber"`
+	Version      int            `json:"version"`
+	Metadata     cdxMetadata    `json:"metadata"`
+	Components   []cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Timestamp string         `json:"timestamp"`
+	Tools     cdxTools       `json:"tools"`
+	Lifecycle []cdxLifecycle `json:"lifecycles,omitempty"`
+	Component *cdxComponent  `json:"component,omitempty"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxLifecycle struct {
+	Phase string `json:"phase"`
+}
+
+type cdxComponent struct {
+	Type       string        `json:"type"`
+	BOMRef     string        `json:"bom-ref,omitempty"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version,omitempty"`
+	Hashes     []cdxHash     `json:"hashes,omitempty"`
+	PURL       string        `json:"purl,omitempty"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// CycloneDX renders rep's packages as a CycloneDX JSON SBOM. The subject
+// (metadata.component) is the host, or the container image for a
+// scan-image report; each package is a component identified by its
+// package URL, with its SHA-1 where the package database records one and
+// the collector source it came from as a property. The
+// OS release, when collected, is an operating-system component.
+func CycloneDX(rep report.ComplianceReport, toolVersion string) ([]byte, error) {
+	serial, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	subject := &cdxComponent{Type: "device", BOMRef: "subject", Name: rep.Hostname}
+	phase := "operations"
+	if rep.Image != nil {
+		subject.Type = "container"
+		subject.Version = rep.Image.ID
+		phase = "post-build"
+	}
+	if rep.Platform != "" {
+		subject.Properties = append(subject.Properties, cdxProperty{"compliance-agent:platform", rep.Platform})
+	}
+	if rep.Identity != nil && rep.Identity.AgentID != "" {
+		subject.Properties = append(subject.Properties, cdxProperty{"compliance-agent:agent_id", rep.Identity.AgentID})
+	}
+	generated := rep.GeneratedAt
+	if generated.IsZero() {
+		generated = time.Now()
+	}
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  CycloneDXVersion,
+		SerialNumber: "urn:uuid:" + serial,
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: generated.UTC().Format(time.RFC3339),
+			Tools: cdxTools{Components: []cdxComponent{{
+				Type: "application", Name: "compliance-agent", Version: toolVersion,
+			}}},
+			Lifecycle: []cdxLifecycle{{Phase: phase}},
+			Component: subject,
+		},
+		Components: []cdxComponent{},
+	}
+	if v := rep.OSVersion; v != nil && v.ID != "" {
+		bom.Components = append(bom.Components, cdxComponent{
+			Type: "operating-system", BOMRef: "os", Name: v.ID, Version: v.Version,
+		})
+	}
+	refs := map[string]bool{}
+	for _, p := range packages(rep) {
+		// bom-refs must be unique; the same purl from two sources (dpkg
+		// and osquery's deb) is the same package.
+		purl := PURL(p, rep.OSVersion)
+		if refs[purl] {
+			continue
+		}
+		refs[purl] = true
+		c := cdxComponent{Type: "library", BOMRef: purl, Name: p.Name, Version: p.Version, PURL: purl}
+		if p.SHA1 != "" {
+			c.Hashes = []cdxHash{{"SHA-1", p.SHA1}}
+		}
+		if p.Source != "" {
+			c.Properties = []cdxProperty{{"compliance-agent:package:source", p.Source}}
+		}
+		bom.Components = append(bom.Components, c)
+	}
+	return json.MarshalIndent(bom, "", "  ")
+}
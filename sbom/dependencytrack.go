@@ -0,0 +1,86 @@
+package sbom
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"compliance-agent/config"
+)
+
+// DependencyTrack uploads SBOMs to a Dependency-Track server, which then
+// tracks the project's components and their vulnerabilities over time.
+type DependencyTrack struct {
+	url        string
+	apiKey     string
+	autoCreate bool
+	client     *http.Client
+}
+
+// NewDependencyTrack builds a client from config, falling back to the
+// DEPENDENCY_TRACK_API_KEY environment variable for the key.
+func NewDependencyTrack(cfg config.DependencyTrackConfig) (*DependencyTrack, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("dependency_track.url not configured (or DEPENDENCY_TRACK_URL)")
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("dependency_track.url: %q is not an http(s) URL", cfg.URL)
+	}
+	d := &DependencyTrack{
+		url:        strings.TrimSuffix(cfg.URL, "/"),
+		apiKey:     cfg.APIKey,
+		autoCreate: cfg.AutoCreate,
+		client:     &http.Client{Timeout: cfg.Timeout},
+	}
+	if d.apiKey == "" {
+		d.apiKey = os.Getenv("DEPENDENCY_TRACK_API_KEY")
+	}
+	if d.apiKey == "" {
+		return nil, errors.New("dependency_track.api_key not configured (or DEPENDENCY_TRACK_API_KEY)")
+	}
+	return d, nil
+}
+
+// Submit uploads bom to the project name at version, creating it if the
+// server allows (auto_create and the key's PROJECT_CREATION_UPLOAD
+// permission). It returns the token of the server's processing task.
+func (d *DependencyTrack) Submit(ctx context.Context, name, version string, bom []byte) (string, error) {
+	body, err := json.Marshal(map[string]any{
+		"projectName":    name,
+		"projectVersion": version,
+		"autoCreate":     d.autoCreate,
+		"bom":            base64.StdEncoding.EncodeToString(bom),
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, d.url+"/api/v1/bom", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", d.apiKey)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("dependency-track: %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	var out struct {
+		Token string `json:"token"`
+	}
+	_ = json.Unmarshal(b, &out)
+	return out.Token, nil
+}
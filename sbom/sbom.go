@@ -0,0 +1,132 @@
+// Package sbom turns the package inventory of a report into a software
+// bill of materials that vulnerability and licence tooling can read
+// without knowing this agent's report format.
+package sbom
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"strings"
+
+	"compliance-agent/collector"
+	"compliance-agent/report"
+)
+
+// purlTypes maps the collector's package sources onto package URL types.
+// Sources without a purl type of their own (Windows programs, FreeBSD
+// pkg, Solaris IPS, AIX lslpp) become pkg:generic.
+var purlTypes = map[string]string{
+	"dpkg":     "deb",
+	"deb":      "deb",
+	"rpm":      "rpm",
+	"apk":      "apk",
+	"homebrew": "brew",
+}
+
+// purlNamespaces are the namespaces the purl spec uses for distributions
+// whose os-release ID differs.
+var purlNamespaces = map[string]string{
+	"rhel":                "redhat",
+	"opensuse-leap":       "opensuse",
+	"opensuse-tumbleweed": "opensuse",
+	"sles":                "suse",
+}
+
+// PURL is the package URL (https://github.com/package-url/purl-spec) of p,
+// installed on the OS release describes (nil when it wasn't collected). OS
+// packages are namespaced by distribution and qualified with its release,
+// so pkg:deb/debian/openssl@3.0.11-1~deb12u2?arch=amd64&distro=debian-12.5.
+func PURL(p collector.Package, release *collector.OSVersion) string {
+	typ, ok := purlTypes[p.Source]
+	if !ok {
+		typ = "generic"
+	}
+	var b strings.Builder
+	b.WriteString("pkg:" + typ + "/")
+	qualifiers := map[string]string{}
+	switch typ {
+	case "deb", "rpm", "apk":
+		ns := map[string]string{"deb": "debian", "rpm": "redhat", "apk": "alpine"}[typ]
+		if release != nil && release.ID != "" {
+			ns = release.ID
+			if mapped, ok := purlNamespaces[ns]; ok {
+				ns = mapped
+			}
+			if release.Version != "" {
+				qualifiers["distro"] = release.ID + "-" + release.Version
+			}
+		}
+		b.WriteString(escape(strings.ToLower(ns)) + "/")
+	}
+	b.WriteString(escape(p.Name))
+	if p.Version != "" {
+		b.WriteString("@" + escape(p.Version))
+	}
+	if p.Arch != "" && typ != "generic" && typ != "brew" {
+		qualifiers["arch"] = p.Arch
+	}
+	if len(qualifiers) > 0 {
+		keys := make([]string, 0, len(qualifiers))
+		for k := range qualifiers {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for i, k := range keys {
+			sep := "&"
+			if i == 0 {
+				sep = "?"
+			}
+			b.WriteString(sep + k + "=" + escape(qualifiers[k]))
+		}
+	}
+	return b.String()
+}
+
+// escape percent-encodes everything but the characters a purl component
+// may hold literally. An epoch's ':' and a version's '+' are encoded.
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '.', c == '-', c == '_', c == '~':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// packages returns the report's packages in a stable order, without the
+// duplicates two collectors can report for one package.
+func packages(rep report.ComplianceReport) []collector.Package {
+	seen := map[collector.Package]bool{}
+	var out []collector.Package
+	for _, p := range rep.Packages {
+		if p.Name == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		out = append(out, p)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Version < out[j].Version
+	})
+	return out
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
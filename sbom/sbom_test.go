@@ -0,0 +1,195 @@
+package sbom
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"compliance-agent/collector"
+	"compliance-agent/config"
+	"compliance-agent/image"
+	"compliance-agent/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPURL(t *testing.T) {
+	debian := &collector.OSVersion{ID: "debian", Version: "12.5"}
+	rhel := &collector.OSVersion{ID: "rhel", Version: "9.3"}
+	for _, tc := range []struct {
+		pkg     collector.Package
+		release *collector.OSVersion
+		want    string
+	}{
+		{collector.Package{Name: "openssl", Version: "3.0.11-1~deb12u2", Source: "dpkg", Arch: "amd64"}, debian,
+			"pkg:deb/debian/openssl@3.0.11-1~deb12u2?arch=amd64&distro=debian-12.5"},
+		{collector.Package{Name: "libc6", Version: "1:2.36+b1", Source: "deb"}, nil,
+			"pkg:deb/debian/libc6@1%3A2.36%2Bb1"},
+		{collector.Package{Name: "bash", Version: "5.1.8-6.el9", Source: "rpm", Arch: "x86_64"}, rhel,
+			"pkg:rpm/redhat/bash@5.1.8-6.el9?arch=x86_64&distro=rhel-9.3"},
+		{collector.Package{Name: "musl", Version: "1.2.4-r2", Source: "apk", Arch: "x86_64"}, &collector.OSVersion{ID: "alpine", Version: "3.19.1"},
+			"pkg:apk/alpine/musl@1.2.4-r2?arch=x86_64&distro=alpine-3.19.1"},
+		{collector.Package{Name: "jq", Version: "1.7.1", Source: "homebrew", Arch: "arm64"}, nil, "pkg:brew/jq@1.7.1"},
+		{collector.Package{Name: "Mozilla Firefox (x64 en-US)", Version: "124.0", Source: "programs"}, nil,
+			"pkg:generic/Mozilla%20Firefox%20%28x64%20en-US%29@124.0"},
+	} {
+		assert.Equal(t, tc.want, PURL(tc.pkg, tc.release))
+	}
+}
+
+func TestCycloneDX(t *testing.T) {
+	rep := report.ComplianceReport{
+		GeneratedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Hostname:    "web-1",
+		Platform:    "linux",
+		OSVersion:   &collector.OSVersion{ID: "debian", Version: "12.5"},
+		Packages: []collector.Package{
+			{Name: "openssl", Version: "3.0.11", Source: "dpkg", Arch: "amd64"},
+			{Name: "bash", Version: "5.2", Source: "dpkg", Arch: "amd64", SHA1: "cc7d2e42f2e3e8f4a2a81eedd5e16f99e48416bf"},
+			// osquery and the fallback collector can both report one.
+			{Name: "bash", Version: "5.2", Source: "deb", Arch: "amd64"},
+		},
+	}
+	b, err := CycloneDX(rep, "v1.2.3")
+	require.NoError(t, err)
+
+	var bom struct {
+		BOMFormat    string `json:"bomFormat"`
+		SpecVersion  string `json:"specVersion"`
+		SerialNumber string `json:"serialNumber"`
+		Metadata     struct {
+			Timestamp string `json:"timestamp"`
+			Tools     struct {
+				Components []cdxComponent `json:"components"`
+			} `json:"tools"`
+			Component cdxComponent `json:"component"`
+		} `json:"metadata"`
+		Components []cdxComponent `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(b, &bom))
+	assert.Equal(t, "CycloneDX", bom.BOMFormat)
+	assert.Equal(t, "1.5", bom.SpecVersion)
+	assert.Regexp(t, `^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, bom.SerialNumber)
+	assert.Equal(t, "2026-03-01T12:00:00Z", bom.Metadata.Timestamp)
+	assert.Equal(t, "v1.2.3", bom.Metadata.Tools.Components[0].Version)
+	assert.Equal(t, "device", bom.Metadata.Component.Type)
+	assert.Equal(t, "web-1", bom.Metadata.Component.Name)
+
+	require.Len(t, bom.Components, 3, "the OS and two packages, bash once")
+	assert.Equal(t, cdxComponent{Type: "operating-system", BOMRef: "os", Name: "debian", Version: "12.5"}, bom.Components[0])
+	assert.Equal(t, "bash", bom.Components[1].Name, "sorted by name")
+	assert.Equal(t, []cdxHash{{"SHA-1", "cc7d2e42f2e3e8f4a2a81eedd5e16f99e48416bf"}}, bom.Components[1].Hashes)
+	assert.Equal(t, "pkg:deb/debian/openssl@3.0.11?arch=amd64&distro=debian-12.5", bom.Components[2].PURL)
+	assert.Equal(t, bom.Components[2].PURL, bom.Components[2].BOMRef)
+	assert.Equal(t, []cdxProperty{{"compliance-agent:package:source", "dpkg"}}, bom.Components[2].Properties)
+
+	rep.Hostname = "registry.example.com/app:1.2"
+	rep.Image = &image.Info{Ref: rep.Hostname, ID: "sha256:abc"}
+	b, err = CycloneDX(rep, "dev")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &bom))
+	assert.Equal(t, "container", bom.Metadata.Component.Type)
+	assert.Equal(t, "sha256:abc", bom.Metadata.Component.Version)
+}
+
+func TestSPDX(t *testing.T) {
+	rep := report.ComplianceReport{
+		GeneratedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Hostname:    "web-1",
+		OSVersion:   &collector.OSVersion{ID: "alpine", Version: "3.19.1"},
+		Packages: []collector.Package{
+			{Name: "musl", Version: "1.2.4-r2", Source: "apk", Arch: "x86_64", SHA1: "cc7d2e42f2e3e8f4a2a81eedd5e16f99e48416bf"},
+			{Name: "busybox", Version: "1.36.1-r5", Source: "apk", Arch: "x86_64"},
+		},
+	}
+	cfg := config.SBOMConfig{Namespace: "https://sbom.example.com/spdx/", Organization: "Example Corp"}
+	b, err := SPDX(rep, "v1.2.3", cfg)
+	require.NoError(t, err)
+
+	var doc spdxDocument
+	require.NoError(t, json.Unmarshal(b, &doc))
+	assert.Equal(t, "SPDX-2.3", doc.SPDXVersion)
+	assert.Equal(t, "CC0-1.0", doc.DataLicense)
+	assert.Equal(t, "SPDXRef-DOCUMENT", doc.SPDXID)
+	assert.Regexp(t, `^https://sbom\.example\.com/spdx/web-1-[0-9a-f-]{36}$`, doc.DocumentNamespace)
+	assert.Equal(t, spdxCreationInfo{
+		Created:  "2026-03-01T12:00:00Z",
+		Creators: []string{"Tool: compliance-agent-v1.2.3", "Organization: Example Corp"},
+	}, doc.CreationInfo)
+
+	require.Len(t, doc.Packages, 4, "the host, the OS and two packages")
+	assert.Equal(t, "DEVICE", doc.Packages[0].PrimaryPackagePurpose)
+	assert.Equal(t, "OPERATING-SYSTEM", doc.Packages[1].PrimaryPackagePurpose)
+	busybox, musl := doc.Packages[2], doc.Packages[3]
+	assert.Equal(t, "busybox", busybox.Name, "sorted by name")
+	assert.Empty(t, busybox.Checksums)
+	assert.Equal(t, []spdxChecksum{{"SHA1", "cc7d2e42f2e3e8f4a2a81eedd5e16f99e48416bf"}}, musl.Checksums)
+	assert.Equal(t, []spdxExternalRef{{"PACKAGE-MANAGER", "purl", "pkg:apk/alpine/musl@1.2.4-r2?arch=x86_64&distro=alpine-3.19.1"}}, musl.ExternalRefs)
+	assert.Equal(t, "NOASSERTION", musl.DownloadLocation)
+
+	ids := map[string]bool{}
+	for _, p := range doc.Packages {
+		assert.Regexp(t, `^SPDXRef-[A-Za-z0-9.-]+$`, p.SPDXID)
+		assert.False(t, ids[p.SPDXID], "SPDXIDs are unique")
+		ids[p.SPDXID] = true
+	}
+	assert.Equal(t, spdxRelationship{"SPDXRef-DOCUMENT", "DESCRIBES", "SPDXRef-Subject"}, doc.Relationships[0])
+	assert.Len(t, doc.Relationships, 4)
+	for _, r := range doc.Relationships[1:] {
+		assert.Equal(t, "CONTAINS", r.RelationshipType)
+		assert.True(t, ids[r.RelatedSPDXElement])
+	}
+
+	rep.Hostname = "registry.example.com/app:1.2"
+	rep.Image = &image.Info{Ref: rep.Hostname, ID: "sha256:abc"}
+	b, err = SPDX(rep, "dev", config.SBOMConfig{})
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &doc))
+	assert.Equal(t, "CONTAINER", doc.Packages[0].PrimaryPackagePurpose)
+	assert.Regexp(t, `^https://spdx\.org/spdxdocs/registry\.example\.com%2Fapp%3A1\.2-`, doc.DocumentNamespace)
+	assert.Equal(t, []string{"Tool: compliance-agent-dev"}, doc.CreationInfo.Creators)
+}
+
+func TestDependencyTrack_Submit(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/api/v1/bom", r.URL.Path)
+		if r.Header.Get("X-Api-Key") != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(b, &got))
+		w.Write([]byte(`{"token":"task-1"}`))
+	}))
+	defer srv.Close()
+
+	cfg := config.DependencyTrackConfig{URL: srv.URL + "/", APIKey: "secret", AutoCreate: true, Timeout: time.Second}
+	dt, err := NewDependencyTrack(cfg)
+	require.NoError(t, err)
+	token, err := dt.Submit(context.Background(), "web-1", "latest", []byte(`{"bomFormat":"CycloneDX"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "task-1", token)
+	assert.Equal(t, "web-1", got["projectName"])
+	assert.Equal(t, "latest", got["projectVersion"])
+	assert.Equal(t, true, got["autoCreate"])
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte(`{"bomFormat":"CycloneDX"}`)), got["bom"])
+
+	cfg.APIKey = "wrong"
+	dt, err = NewDependencyTrack(cfg)
+	require.NoError(t, err)
+	_, err = dt.Submit(context.Background(), "web-1", "latest", nil)
+	assert.ErrorContains(t, err, "401")
+
+	t.Setenv("DEPENDENCY_TRACK_API_KEY", "")
+	_, err = NewDependencyTrack(config.DependencyTrackConfig{URL: srv.URL})
+	assert.ErrorContains(t, err, "api_key")
+	_, err = NewDependencyTrack(config.DependencyTrackConfig{URL: "ftp://x", APIKey: "k"})
+	assert.ErrorContains(t, err, "not an http(s) URL")
+}
@@ -0,0 +1,140 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"compliance-agent/config"
+	"compliance-agent/report"
+)
+
+// SPDXVersion is the SPDX specification version written.
+const SPDXVersion = "SPDX-2.3"
+
+// spdxDocument is the subset of an SPDX JSON document this agent writes.
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name                  string            `json:"name"`
+	SPDXID                string            `json:"SPDXID"`
+	VersionInfo           string            `json:"versionInfo,omitempty"`
+	DownloadLocation      string            `json:"downloadLocation"`
+	FilesAnalyzed         bool              `json:"filesAnalyzed"`
+	SourceInfo            string            `json:"sourceInfo,omitempty"`
+	Checksums             []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs          []spdxExternalRef `json:"externalRefs,omitempty"`
+	PrimaryPackagePurpose string            `json:"primaryPackagePurpose"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// SPDX renders rep's packages as an SPDX 2.3 JSON document. The document
+// describes the host, or the container image for a scan-image report,
+// which contains the OS release and each package. Packages carry their
+// package URL and, where the package database records one, their SHA-1.
+// The namespace is unique per document, under cfg.Namespace.
+func SPDX(rep report.ComplianceReport, toolVersion string, cfg config.SBOMConfig) ([]byte, error) {
+	id, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	generated := rep.GeneratedAt
+	if generated.IsZero() {
+		generated = time.Now()
+	}
+	creators := []string{"Tool: compliance-agent-" + toolVersion}
+	if cfg.Organization != "" {
+		creators = append(creators, "Organization: "+cfg.Organization)
+	}
+	ns := strings.TrimSuffix(cfg.Namespace, "/")
+	if ns == "" {
+		ns = config.Default().SBOM.Namespace
+	}
+	doc := spdxDocument{
+		SPDXVersion:       SPDXVersion,
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              rep.Hostname,
+		DocumentNamespace: ns + "/" + escape(rep.Hostname) + "-" + id,
+		CreationInfo: spdxCreationInfo{
+			Created:  generated.UTC().Format(time.RFC3339),
+			Creators: creators,
+		},
+	}
+
+	subject := spdxPackage{
+		Name: rep.Hostname, SPDXID: "SPDXRef-Subject", DownloadLocation: "NOASSERTION",
+		PrimaryPackagePurpose: "DEVICE",
+	}
+	if rep.Image != nil {
+		subject.VersionInfo = rep.Image.ID
+		subject.PrimaryPackagePurpose = "CONTAINER"
+	}
+	doc.Packages = append(doc.Packages, subject)
+	doc.Relationships = append(doc.Relationships, spdxRelationship{doc.SPDXID, "DESCRIBES", subject.SPDXID})
+	contains := func(p spdxPackage) {
+		doc.Packages = append(doc.Packages, p)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{subject.SPDXID, "CONTAINS", p.SPDXID})
+	}
+	if v := rep.OSVersion; v != nil && v.ID != "" {
+		contains(spdxPackage{
+			Name: v.ID, SPDXID: "SPDXRef-OperatingSystem", VersionInfo: v.Version,
+			DownloadLocation: "NOASSERTION", PrimaryPackagePurpose: "OPERATING-SYSTEM",
+		})
+	}
+	seen := map[string]bool{}
+	for _, p := range packages(rep) {
+		purl := PURL(p, rep.OSVersion)
+		if seen[purl] {
+			continue
+		}
+		seen[purl] = true
+		pkg := spdxPackage{
+			Name:                  p.Name,
+			SPDXID:                fmt.Sprintf("SPDXRef-Package-%d", len(seen)),
+			VersionInfo:           p.Version,
+			DownloadLocation:      "NOASSERTION",
+			ExternalRefs:          []spdxExternalRef{{"PACKAGE-MANAGER", "purl", purl}},
+			PrimaryPackagePurpose: "LIBRARY",
+		}
+		if p.Source != "" {
+			pkg.SourceInfo = "installed per the " + p.Source + " package inventory"
+		}
+		if p.SHA1 != "" {
+			pkg.Checksums = []spdxChecksum{{"SHA1", p.SHA1}}
+		}
+		contains(pkg)
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
@@ -0,0 +1,1616 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/user"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"compliance-agent/alerting"
+	"compliance-agent/analyzer"
+	"compliance-agent/baseline"
+	"compliance-agent/buildinfo"
+	"compliance-agent/collector"
+	"compliance-agent/config"
+	"compliance-agent/errcode"
+	"compliance-agent/evidence"
+	"compliance-agent/geoip"
+	"compliance-agent/guard"
+	"compliance-agent/identity"
+	"compliance-agent/logging"
+	"compliance-agent/ml"
+	"compliance-agent/osv"
+	"compliance-agent/policydist"
+	"compliance-agent/report"
+	"compliance-agent/reportcrypt"
+	"compliance-agent/server"
+	"compliance-agent/sink"
+	"compliance-agent/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+// scanner runs one full compliance pass: collect, analyze, score, report,
+// alert. One-shot mode calls it once; daemon mode calls it every interval
+// with the same collector so the osquery connection stays open.
+type scanner struct {
+	cfg       config.Config
+	collector collector.Collector
+	policies  analyzer.Policies
+	baseline  *baseline.Store
+	scorer    *ml.Scorer
+	alerters  []alerting.Alerter
+	// risk scores and orders violations.
+	risk analyzer.RiskModel
+	// correlation groups related violations into incident alerts.
+	correlation []config.CorrelationRule
+	// sinks receive every full report.
+	sinks []sink.Sink
+	// history stores every report when cfg.History.Path is set.
+	history *storage.Store
+	// evidenceLog chains a summary of every report when cfg.Evidence.Log
+	// is set.
+	evidenceLog *evidence.Log
+	// geoip tags connections when cfg.GeoIP names a database.
+	geoip *geoip.DB
+	// osv looks up package vulnerabilities when the policy asks.
+	osv *osv.Client
+	// verbose dumps collected data to stdout, which is useful for a
+	// one-shot run at a terminal and noise in daemon logs.
+	verbose bool
+	// outputFormat selects the saved report format: "json", "html",
+	// "junit", "markdown" or "pdf".
+	outputFormat string
+	// outputFile is where the report is saved, instead of the format's
+	// default file name.
+	outputFile string
+	// policyPath is the policy file, hashed into the evidence manifest.
+	policyPath string
+	// profiles is the --profile list, re-applied to a policy fetched from
+	// the fleet server.
+	profiles string
+	// central is the fleet server, when cfg.Central.URL is set: each
+	// report is uploaded to it and its policy, if it has one, replaces
+	// the local one.
+	central *server.Client
+	// policySource is where the policy is fetched from instead, when
+	// cfg.PolicySource.URL is set.
+	policySource *policydist.Source
+	// policyKeys, from cfg.PolicySource.PublicKeys, must have signed a
+	// fetched policy for it to be used. newScanner only leaves it empty
+	// with cfg.PolicySource.AllowUnsigned.
+	policyKeys []ed25519.PublicKey
+	// recipients, from cfg.Encryption.Recipients, are the keys saved
+	// reports are encrypted to.
+	recipients []*ecdh.PublicKey
+	// remotePolicy is the fetched policy in force.
+	remotePolicy []byte
+	// setupErr is a problem found while choosing a collector (e.g. an
+	// unsafe osquery socket); it is reported as an agent violation.
+	setupErr error
+	// cache keeps analyzer results between daemon scans so unchanged
+	// input isn't re-evaluated.
+	cache *analysisCache
+	// delta is what each alerter has been told, in delta alerting mode.
+	delta *alerting.DeltaState
+	// health is the agent's state for each report's health section.
+	health *agentHealth
+}
+
+func newScanner(cfg config.Config, c collector.Collector, policies analyzer.Policies) (*scanner, error) {
+	switch cfg.Alerting.Mode {
+	case "", "all", "delta":
+	default:
+		return nil, fmt.Errorf("alerting.mode %q: want all or delta", cfg.Alerting.Mode)
+	}
+	alerters, err := alerting.Build(alertingConfig(cfg))
+	if err != nil {
+		return nil, err
+	}
+	risk, err := riskModel(cfg.Risk)
+	if err != nil {
+		return nil, err
+	}
+	correlation, err := alerting.CorrelationRules(cfg.Alerting.Correlation)
+	if err != nil {
+		return nil, err
+	}
+	var delta *alerting.DeltaState
+	if cfg.Alerting.Mode == "delta" {
+		if delta, err = alerting.NewDeltaState(cfg.Alerting.DeltaState); err != nil {
+			return nil, fmt.Errorf("alerting.delta_state: %w", err)
+		}
+	}
+	sinks, err := sink.Build(cfg.Sinks)
+	if err != nil {
+		return nil, err
+	}
+	bstore := baseline.NewStore(cfg.Baseline.Path)
+	if err := bstore.Load(); err != nil {
+		logging.Component("baseline").Warn("baseline load failed", "path", cfg.Baseline.Path, "err", err)
+	}
+	var history *storage.Store
+	if cfg.History.Path != "" {
+		// A broken history database shouldn't stop scanning.
+		if history, err = storage.Open(cfg.History.Path); err != nil {
+			logging.Component("history").Warn("report history disabled", "err", err)
+		}
+	}
+	var evidenceLog *evidence.Log
+	if cfg.Evidence.Log != "" {
+		if evidenceLog, err = evidence.OpenLog(cfg.Evidence.Log); err != nil {
+			logging.Component("evidence").Warn("evidence log disabled", "err", err)
+		}
+	}
+	var central *server.Client
+	if cfg.Central.URL != "" {
+		if central, err = server.NewClient(cfg.Central); err != nil {
+			return nil, err
+		}
+	}
+	var source *policydist.Source
+	if cfg.PolicySource.URL != "" {
+		if source, err = policydist.NewSource(cfg.PolicySource); err != nil {
+			return nil, err
+		}
+	}
+	keys, err := policydist.ParsePublicKeys(cfg.PolicySource.PublicKeys)
+	if err != nil {
+		return nil, fmt.Errorf("policy_source.%w", err)
+	}
+	if (central != nil || source != nil) && len(keys) == 0 {
+		if !cfg.PolicySource.AllowUnsigned {
+			return nil, errors.New("policy_source.public_keys: required to verify the policy fetched from central.url or policy_source.url (or set policy_source.allow_unsigned)")
+		}
+		logging.Component("policy").Warn("policy_source.allow_unsigned: fetched policies are used without a signature")
+	}
+	recipients, err := reportcrypt.ParseRecipients(cfg.Encryption.Recipients)
+	if err != nil {
+		return nil, fmt.Errorf("encryption.%w", err)
+	}
+	var geo *geoip.DB
+	if cfg.GeoIP.CountryDB != "" || cfg.GeoIP.ASNDB != "" {
+		if geo, err = geoip.Open(cfg.GeoIP.CountryDB, cfg.GeoIP.ASNDB); err != nil {
+			logging.Component("geoip").Warn("GeoIP enrichment disabled", "err", err)
+		}
+	}
+	return &scanner{
+		cfg:          cfg,
+		collector:    c,
+		policies:     policies,
+		baseline:     bstore,
+		scorer:       ml.NewScorer(cfg.ML.URL, cfg.ML.Timeout),
+		alerters:     alerters,
+		risk:         risk,
+		correlation:  correlation,
+		delta:        delta,
+		sinks:        sinks,
+		history:      history,
+		evidenceLog:  evidenceLog,
+		central:      central,
+		policySource: source,
+		policyKeys:   keys,
+		recipients:   recipients,
+		geoip:        geo,
+		osv:          osv.NewClient(cfg.OSV.URL, cfg.OSV.CachePath, cfg.OSV.CacheTTL),
+		cache:        newAnalysisCache(),
+		health:       newAgentHealth(time.Now()),
+	}, nil
+}
+
+// close releases the history database, evidence log and GeoIP files.
+func (s *scanner) close() {
+	if s.history != nil {
+		s.history.Close()
+	}
+	if s.evidenceLog != nil {
+		s.evidenceLog.Close()
+	}
+	if s.geoip != nil {
+		s.geoip.Close()
+	}
+}
+
+// collectOptions selects the optional, policy-driven collectors.
+type collectOptions struct {
+	Accounts  bool
+	Power     bool
+	Sharing   bool
+	Bluetooth bool
+	// VPNSignatures enables VPN detection with these signatures.
+	VPNSignatures []collector.VPNSignature
+	HostsFile     bool
+	Proxy         bool
+	Connections   bool
+	DNS           bool
+	ARP           bool
+	Interfaces    bool
+	// DiskEncryption reads volume encryption through the collector.
+	DiskEncryption bool
+	// Firewall reads the host firewall state.
+	Firewall bool
+	// SSHD reads the SSH server configuration, from SSHDConfigPath
+	// when set.
+	SSHD           bool
+	SSHDConfigPath string
+	// OSVersion reads the OS release and kernel through the collector.
+	OSVersion bool
+	// Agents are the required third-party agents to check for drift.
+	Agents []collector.AgentSpec
+	// SecretPaths are walked for exposed secrets and expiring
+	// certificates; nil disables the scan.
+	SecretPaths []string
+	// TLS probes local listeners, limited to TLSPorts when set.
+	TLS      bool
+	TLSPorts []int
+	// WebEndpoints are local web consoles to fetch, matched against the
+	// built-in default-credential signatures plus WebPatterns.
+	WebEndpoints []string
+	WebPatterns  []string
+	// Vulnerabilities looks packages up in OSV; it needs the full
+	// package inventory.
+	Vulnerabilities bool
+	// Profiles are benchmark profiles whose probes to run.
+	Profiles []string
+	// Packages collects the full package inventory for package rules.
+	Packages bool
+	// Cron reads the system's and users' crontabs.
+	Cron bool
+}
+
+// optionsFor enables the optional collectors the policy has rules for.
+func optionsFor(p analyzer.Policies) collectOptions {
+	o := collectOptions{
+		Accounts:  p.Workstation.Enabled(),
+		Power:     p.Laptop.Enabled(),
+		Sharing:   p.Sharing.Prohibited,
+		Bluetooth: p.Bluetooth.Enabled(),
+		HostsFile: p.HostsFile.CheckOverrides,
+		Proxy:     p.Proxy.RequireEnabled,
+		Profiles:  p.Profiles,
+	}
+	// CEL rules may target connections too.
+	o.Connections = p.Connections.Enabled() || rulesTarget(p.Rules, "connection")
+	o.DNS = p.DNS.Enabled()
+	o.ARP = p.ARP.Enabled()
+	o.Interfaces = p.Interfaces.Enabled()
+	o.DiskEncryption = p.RequireDiskEncryption
+	o.Firewall = p.RequireFirewallEnabled
+	o.SSHD, o.SSHDConfigPath = p.SSHD.Enabled(), p.SSHD.ConfigPath
+	// Rules and scripts limited to some OS releases need it to decide.
+	o.OSVersion = p.OSVersion.Enabled() || p.NeedsOSVersion()
+	o.Packages = p.ChecksPackages()
+	o.Agents = analyzer.AgentSpecs(p.RequiredAgents)
+	if p.Secrets.Enabled() {
+		o.SecretPaths = secretPaths(p.Secrets)
+	}
+	o.TLS, o.TLSPorts = p.TLS.Enabled(), p.TLS.Ports
+	o.WebEndpoints, o.WebPatterns = p.Web.Endpoints, p.Web.CredentialPatterns
+	o.Vulnerabilities = p.Vulnerabilities.Enabled()
+	if p.VPN.Enabled() {
+		o.VPNSignatures = p.VPN.AllSignatures()
+	}
+	return o
+}
+
+// rulesTarget reports whether any CEL rule targets target.
+func rulesTarget(rules []analyzer.Rule, target string) bool {
+	for _, r := range rules {
+		if r.Target == target {
+			return true
+		}
+	}
+	return false
+}
+
+// allOptions enables every optional collector, for a collection that may
+// later be analyzed against any policy.
+func allOptions(p analyzer.Policies) collectOptions {
+	return collectOptions{
+		Accounts:      true,
+		Power:         true,
+		Sharing:       true,
+		Bluetooth:     true,
+		VPNSignatures: p.VPN.AllSignatures(),
+		HostsFile:     true,
+		Proxy:         true,
+		Connections:   true,
+		DNS:           true,
+		ARP:           true,
+		Interfaces:    true,
+		// Probing connects to every local listener and scanning sends
+		// the package list to OSV, so even a full collection only does
+		// them when the policy opts in.
+		TLS:             p.TLS.Enabled(),
+		TLSPorts:        p.TLS.Ports,
+		WebEndpoints:    p.Web.Endpoints,
+		WebPatterns:     p.Web.CredentialPatterns,
+		Vulnerabilities: p.Vulnerabilities.Enabled(),
+		Profiles:        platformProfiles(),
+		DiskEncryption:  true,
+		Firewall:        true,
+		SSHD:            true,
+		SSHDConfigPath:  p.SSHD.ConfigPath,
+		OSVersion:       true,
+		Packages:        true,
+		Cron:            true,
+		SecretPaths:     secretPaths(p.Secrets),
+		Agents:          analyzer.AgentSpecs(p.RequiredAgents),
+	}
+}
+
+// secretPaths returns the policy's secret scan paths or the platform
+// defaults.
+func secretPaths(p analyzer.SecretsPolicy) []string {
+	if len(p.Paths) > 0 {
+		return p.Paths
+	}
+	return collector.DefaultSecretPaths()
+}
+
+// platformProfiles lists the benchmark profiles for this OS.
+func platformProfiles() []string {
+	var out []string
+	for _, name := range analyzer.ProfileNames() {
+		if p, _ := analyzer.LookupProfile(name); p.Platform == runtime.GOOS {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// scan performs one pass: collect, analyze, deliver, save. Collector
+// failures and timeouts are recorded in the report so the run still
+// produces output, as are failed deliveries; only cancellation of ctx is
+// returned as an error.
+func (s *scanner) scan(ctx context.Context) (report.ComplianceReport, error) {
+	s.refreshPolicy(ctx)
+	rep, err := s.collect(ctx, optionsFor(s.policies))
+	if err != nil {
+		s.health.failedScans++
+		return rep, err
+	}
+	s.fillHealth(rep.Health)
+	if s.verbose {
+		fmt.Println("Users:")
+		dumpJSON(rep.Users)
+		fmt.Println("Processes:")
+		dumpJSON(rep.Processes)
+	}
+
+	analyze(&rep, s.policies, s.risk, s.cache)
+	if s.verbose {
+		fmt.Println("Compliance Violations:")
+		dumpJSON(rep.Violations)
+		b, _ := rep.ToJSON()
+		fmt.Println("Compliance Report JSON:")
+		fmt.Println(string(b))
+	}
+
+	// The previous report is read before this one replaces it on disk,
+	// for alerters delta mode has no baseline for yet.
+	var prev *report.ComplianceReport
+	if s.delta != nil && s.needsPrevious(rep.Hostname) {
+		prev = s.previousReport(rep.Hostname)
+	}
+	s.deliver(ctx, &rep, prev)
+
+	if path, err := s.saveReport(&rep); err != nil {
+		logging.Component("scan").Error("report not saved", "err", err)
+	} else {
+		logging.Component("scan").Info("saved the report", "path", path)
+		if m := s.cfg.Evidence.Manifest; m != "" {
+			if err := writeEvidence(m, rep,
+				evidenceFile{"report", path},
+				evidenceFile{"baseline", s.cfg.Baseline.Path},
+				evidenceFile{"policy", s.policyPath},
+			); err != nil {
+				logging.Component("evidence").Error("evidence manifest not written", "path", m, "err", err)
+			}
+		}
+	}
+	s.record(rep)
+	return rep, nil
+}
+
+// deliver sends rep to the sinks, the fleet server and the alerters,
+// then adds the deliveries that failed or panicked to rep.Errors. It
+// runs before rep is saved, so the report file and history entry list
+// them; the destinations get rep without.
+func (s *scanner) deliver(ctx context.Context, rep *report.ComplianceReport, prev *report.ComplianceReport) {
+	var rec guard.Recorder
+	sendToSinks(ctx, &rec, s.health, s.sinks, *rep)
+	s.upload(ctx, &rec, *rep)
+	sendAlerts(&rec, s.health, s.alerters, s.correlation, *rep, s.delta, prev)
+	rep.Errors = append(rep.Errors, rec.Errors()...)
+}
+
+// refreshPolicy switches to the policy from cfg.PolicySource, else the
+// fleet server's, when there is one that differs from the policy in
+// force. It must also be signed by one of the public keys, unless
+// policy_source.allow_unsigned is set. An unreachable source or a bad or
+// unsigned policy leaves the current one in force.
+func (s *scanner) refreshPolicy(ctx context.Context) {
+	var (
+		from   string
+		b, sig []byte
+		ok     bool
+		err    error
+	)
+	switch {
+	case s.policySource != nil:
+		from = s.policySource.URL()
+		b, sig, ok, err = s.policySource.Fetch(ctx)
+	case s.central != nil:
+		from = s.cfg.Central.URL
+		b, sig, ok, err = s.central.Policy(ctx, s.enrollRequest(ctx))
+	default:
+		return
+	}
+	if err != nil {
+		logging.Component("policy").Warn("policy fetch failed, keeping the current policy", "from", from, "err", err)
+		return
+	}
+	if !ok || bytes.Equal(b, s.remotePolicy) {
+		return
+	}
+	if len(s.policyKeys) > 0 || !s.cfg.PolicySource.AllowUnsigned {
+		if err := policydist.Verify(s.policyKeys, b, sig); err != nil {
+			logging.Component("policy").Warn("policy signature rejected, keeping the current policy", "from", from, "err", err)
+			return
+		}
+	}
+	p, err := analyzer.ParsePolicies(b)
+	if err != nil {
+		logging.Component("policy").Warn("policy does not parse, keeping the current policy", "from", from, "err", err)
+		return
+	}
+	s.policies = withProfiles(p, s.profiles)
+	s.remotePolicy = b
+	// The local file is no longer the policy checked against.
+	s.policyPath = ""
+	logging.Component("policy").Info("using the fetched policy", "from", from)
+}
+
+// enrollRequest identifies the agent to the fleet server. The policy is
+// fetched before the first scan, so that may be where the agent enrolls;
+// it identifies itself as an upload would.
+func (s *scanner) enrollRequest(ctx context.Context) server.EnrollRequest {
+	hostname, _ := os.Hostname()
+	req := server.EnrollRequest{
+		Hostname:     hostname,
+		Platform:     runtime.GOOS,
+		AgentVersion: buildinfo.AgentVersion(),
+	}
+	if s.cfg.Identity.Path != "" {
+		hw, _ := collector.CollectHardware(ctx)
+		if id, err := identity.Resolve(s.cfg.Identity.Path, hw, time.Now()); err == nil {
+			req.AgentUUID = id.AgentID
+			if hw != nil {
+				req.HardwareUUID = hw.UUID
+			}
+		}
+	}
+	return req
+}
+
+// upload sends the report to the fleet server.
+func (s *scanner) upload(ctx context.Context, rec *guard.Recorder, rep report.ComplianceReport) {
+	// A --root scan is of another system, which the fleet server would
+	// file under this agent's host.
+	if s.central == nil || s.cfg.Root != "" {
+		return
+	}
+	var id int64
+	err := delivery(rec, s.health, "export", "central", rec.Run("export", "central", func() (err error) {
+		id, err = s.central.Upload(ctx, rep)
+		return err
+	}))
+	if err != nil {
+		s.health.backlog++
+		logging.Component("central").Error("report upload failed", "server", s.cfg.Central.URL, "code", errcode.NotifierFailure, "err", err)
+	} else {
+		s.health.backlog = 0
+		logging.Component("central").Info("report uploaded", "server", s.cfg.Central.URL, "report_id", id)
+	}
+}
+
+// needsPrevious reports whether an alerter has no delta baseline for
+// hostname yet.
+func (s *scanner) needsPrevious(hostname string) bool {
+	for _, a := range s.alerters {
+		if _, ok := s.delta.Baseline(a.Name(), hostname); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// previousReport returns the last run's report, the delta baseline for
+// an alerter with none recorded: the newest in report history, else the
+// saved JSON report. It returns nil on a first run, when every violation
+// is new.
+func (s *scanner) previousReport(hostname string) *report.ComplianceReport {
+	if s.history != nil {
+		rep, ok, err := s.history.Latest(hostname)
+		if err != nil {
+			logging.Component("alerting").Warn("previous report not read from history", "err", err)
+		} else if ok {
+			return &rep
+		}
+	}
+	// An encrypted report can't be read back without its private key.
+	if s.outputFormat != "" && s.outputFormat != "json" || len(s.recipients) > 0 {
+		return nil
+	}
+	path := s.outputFile
+	if path == "" {
+		path = "compliance_report.json"
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var rep report.ComplianceReport
+	if err := json.Unmarshal(b, &rep); err != nil || rep.Hostname != hostname {
+		return nil
+	}
+	return &rep
+}
+
+// collect gathers the host inventory into a report with no violations
+// yet, scores it against the baseline, and records contained failures in
+// rep.Errors. A problem found while choosing the collector is recorded as
+// a "setup" error so analysis can flag it even from a saved collection.
+func (s *scanner) collect(ctx context.Context, opts collectOptions) (report.ComplianceReport, error) {
+	c := s.collector
+	// A --root scan reads a mounted tree. What only a running system has
+	// is listed as unavailable instead of being read from this host.
+	root, _ := c.(*collector.RootFS)
+	var unavailable []string
+	if root != nil {
+		unavailable = liveOnly(opts)
+	}
+
+	// Each collector runs under panic recovery: a crash in one parser is
+	// recorded in the report and the run carries on with the rest.
+	var rec guard.Recorder
+	rec.Record("setup", "collector", s.setupErr)
+
+	// Independent collectors run concurrently, each with its own timeout,
+	// so one slow source (a busy osquery socket, a hung PowerShell) no
+	// longer holds up the rest. Failures are recorded, not fatal.
+	cl := newCollection(ctx, &rec, s.cfg.CollectTimeout)
+
+	var users []collector.User
+	var procs []collector.Process
+	var bindings []collector.PortBinding
+	var packages []collector.Package
+	collectAsync(cl, "users", &users, func(ctx context.Context) ([]collector.User, error) {
+		return c.CollectUsers(ctx)
+	})
+	if root == nil {
+		collectAsync(cl, "processes", &procs, func(ctx context.Context) ([]collector.Process, error) {
+			return c.CollectProcesses(ctx, 25)
+		})
+	}
+	if root == nil {
+		collectAsync(cl, "ports", &bindings, func(ctx context.Context) ([]collector.PortBinding, error) {
+			return c.CollectOpenPorts(ctx)
+		})
+	}
+	pkgLimit := 200
+	if opts.Vulnerabilities || opts.Packages {
+		pkgLimit = 20000
+	}
+	collectAsync(cl, "packages", &packages, func(ctx context.Context) ([]collector.Package, error) {
+		return c.CollectPackages(ctx, pkgLimit)
+	})
+
+	// User mode: add what the invoking account itself has installed or
+	// persisted, since system-wide sources are out of reach.
+	var userScope *collector.UserScope
+	if s.cfg.Scope == "user" {
+		collectAsync(cl, "user_scope", &userScope, func(context.Context) (*collector.UserScope, error) {
+			us, err := collectCurrentUserScope()
+			return &us, err
+		})
+	}
+
+	var power *collector.PowerSettings
+	if opts.Power && root == nil {
+		collectAsync(cl, "power", &power, func(context.Context) (*collector.PowerSettings, error) {
+			ps := collector.CollectPowerSettings()
+			return &ps, nil
+		})
+	}
+
+	var bt *collector.BluetoothState
+	if opts.Bluetooth && root == nil {
+		collectAsync(cl, "bluetooth", &bt, func(context.Context) (*collector.BluetoothState, error) {
+			st := collector.CollectBluetooth()
+			return &st, nil
+		})
+	}
+
+	var vpn *collector.VPNStatus
+	if opts.VPNSignatures != nil && root == nil {
+		collectAsync(cl, "vpn", &vpn, func(ctx context.Context) (*collector.VPNStatus, error) {
+			// The inventory above is capped for the report; signature
+			// matching needs the full process and package lists.
+			allProcs, err := c.CollectProcesses(ctx, 10000)
+			if err != nil {
+				return nil, err
+			}
+			allPkgs, err := c.CollectPackages(ctx, 100000)
+			if err != nil {
+				return nil, err
+			}
+			st := collector.DetectVPN(allProcs, allPkgs, opts.VPNSignatures)
+			return &st, nil
+		})
+	}
+
+	var hosts []collector.HostsEntry
+	if opts.HostsFile {
+		collectAsync(cl, "hosts", &hosts, func(context.Context) ([]collector.HostsEntry, error) {
+			if root != nil {
+				return root.CollectHostsFile()
+			}
+			return collector.CollectHostsFile()
+		})
+	}
+
+	var cron []collector.CronJob
+	if opts.Cron || root != nil {
+		collectAsync(cl, "cron", &cron, func(ctx context.Context) ([]collector.CronJob, error) {
+			if root != nil {
+				return root.CollectCronJobs(ctx)
+			}
+			return collector.CollectCronJobs(ctx)
+		})
+	}
+
+	var proxy *collector.ProxySettings
+	if opts.Proxy && root == nil {
+		collectAsync(cl, "proxy", &proxy, func(context.Context) (*collector.ProxySettings, error) {
+			ps := collector.CollectProxySettings()
+			return &ps, nil
+		})
+	}
+
+	var conns []collector.Connection
+	if opts.Connections && root == nil {
+		collectAsync(cl, "connections", &conns, func(ctx context.Context) ([]collector.Connection, error) {
+			conns, err := c.CollectConnections(ctx, 1000)
+			if err != nil {
+				return nil, err
+			}
+			if s.geoip != nil {
+				return conns, s.geoip.Enrich(conns)
+			}
+			return conns, nil
+		})
+	}
+
+	var dns []collector.DNSQuery
+	if opts.DNS && root == nil {
+		collectAsync(cl, "dns", &dns, func(context.Context) ([]collector.DNSQuery, error) {
+			return collector.CollectDNSQueries(s.cfg.DNS.LogPaths, 5000)
+		})
+	}
+
+	var arp *collector.ARPTable
+	if opts.ARP && root == nil {
+		collectAsync(cl, "arp", &arp, func(context.Context) (*collector.ARPTable, error) {
+			t, err := collector.CollectARPTable()
+			if err != nil {
+				return nil, err
+			}
+			return &t, nil
+		})
+	}
+
+	var ifaces []collector.NetInterface
+	if opts.Interfaces && root == nil {
+		collectAsync(cl, "interfaces", &ifaces, func(context.Context) ([]collector.NetInterface, error) {
+			return collector.CollectInterfaces()
+		})
+	}
+
+	var disks []collector.DiskVolume
+	if opts.DiskEncryption && root == nil {
+		if dc, ok := c.(interface {
+			CollectDiskEncryption(context.Context) ([]collector.DiskVolume, error)
+		}); ok {
+			collectAsync(cl, "disk_encryption", &disks, dc.CollectDiskEncryption)
+		}
+	}
+
+	var hardware *collector.HardwareInfo
+	if hc, ok := c.(interface {
+		CollectHardware(context.Context) (*collector.HardwareInfo, error)
+	}); ok {
+		collectAsync(cl, "hardware", &hardware, hc.CollectHardware)
+	}
+
+	var osVersion *collector.OSVersion
+	if opts.OSVersion {
+		if oc, ok := c.(interface {
+			CollectOSVersion(context.Context) (*collector.OSVersion, error)
+		}); ok {
+			collectAsync(cl, "os_version", &osVersion, oc.CollectOSVersion)
+		}
+	}
+
+	var firewall *collector.FirewallStatus
+	if opts.Firewall && root == nil {
+		collectAsync(cl, "firewall", &firewall, func(ctx context.Context) (*collector.FirewallStatus, error) {
+			st, err := collector.CollectFirewall(ctx)
+			return &st, err
+		})
+	}
+
+	var sshd *collector.SSHDConfig
+	if opts.SSHD {
+		collectAsync(cl, "sshd", &sshd, func(ctx context.Context) (*collector.SSHDConfig, error) {
+			if root != nil {
+				return root.CollectSSHDConfig(ctx, opts.SSHDConfigPath)
+			}
+			return collector.CollectSSHDConfig(ctx, opts.SSHDConfigPath)
+		})
+	}
+
+	var agents []collector.AgentState
+	if len(opts.Agents) > 0 && root == nil {
+		collectAsync(cl, "required_agents", &agents, func(ctx context.Context) ([]collector.AgentState, error) {
+			// Like VPN detection, this needs the uncapped inventory. A
+			// list that fails stays nil so its checks are skipped.
+			allProcs, perr := c.CollectProcesses(ctx, 10000)
+			if perr != nil {
+				allProcs = nil
+			}
+			allPkgs, kerr := c.CollectPackages(ctx, 100000)
+			if kerr != nil {
+				allPkgs = nil
+			}
+			return collector.CheckAgents(opts.Agents, allProcs, allPkgs), errors.Join(perr, kerr)
+		})
+	}
+
+	var secrets []collector.SecretFile
+	if len(opts.SecretPaths) > 0 {
+		collectAsync(cl, "secrets", &secrets, func(ctx context.Context) ([]collector.SecretFile, error) {
+			if root != nil {
+				return root.CollectSecretFiles(ctx, opts.SecretPaths)
+			}
+			return collector.CollectSecretFiles(ctx, opts.SecretPaths)
+		})
+	}
+
+	var web []collector.WebEndpoint
+	if len(opts.WebEndpoints) > 0 && root == nil {
+		collectAsync(cl, "web", &web, func(ctx context.Context) ([]collector.WebEndpoint, error) {
+			return collector.CollectWebEndpoints(ctx, opts.WebEndpoints, opts.WebPatterns)
+		})
+	}
+
+	var benchmark []collector.ProbeResult
+	var probes []collector.Probe
+	var profiles []string
+	if root == nil {
+		profiles = opts.Profiles
+	}
+	for _, name := range profiles {
+		p, _ := analyzer.LookupProfile(name)
+		if p.Platform != runtime.GOOS {
+			rec.Record("collect", "profiles", fmt.Errorf("profile %s is for %s, not %s; skipped", name, p.Platform, runtime.GOOS))
+			continue
+		}
+		probes = append(probes, p.Probes()...)
+	}
+	if len(probes) > 0 {
+		collectAsync(cl, "profiles", &benchmark, func(context.Context) ([]collector.ProbeResult, error) {
+			return collector.RunProbes(probes), nil
+		})
+	}
+	cl.wait()
+
+	// Second round: collectors that build on the inventory above.
+	var accounts []collector.UserScope
+	if userScope != nil {
+		accounts = []collector.UserScope{*userScope}
+	} else if opts.Accounts && s.cfg.Scope != "user" && root == nil {
+		// Workstation rules apply per person, so walk every interactive
+		// account rather than just the one the agent runs as.
+		collectAsync(cl, "accounts", &accounts, func(context.Context) ([]collector.UserScope, error) {
+			return collector.CollectAccounts(users), nil
+		})
+	}
+
+	var sharing []collector.SharingService
+	if opts.Sharing && root == nil {
+		collectAsync(cl, "sharing", &sharing, func(context.Context) ([]collector.SharingService, error) {
+			return collector.CollectSharingServices(bindings), nil
+		})
+	}
+
+	var tlsServices []collector.TLSService
+	if opts.TLS && root == nil {
+		collectAsync(cl, "tls", &tlsServices, func(ctx context.Context) ([]collector.TLSService, error) {
+			return collector.ProbeTLS(ctx, bindings, opts.TLSPorts)
+		})
+	}
+
+	var vulns []osv.Finding
+	if opts.Vulnerabilities {
+		collectAsync(cl, "vulnerabilities", &vulns, func(ctx context.Context) ([]osv.Finding, error) {
+			ecosystems := osv.Ecosystems(s.cfg.OSV.Ecosystem)
+			if root != nil {
+				release, _ := root.OSRelease()
+				ecosystems = osv.EcosystemsFor(s.cfg.OSV.Ecosystem, string(release))
+			}
+			queries := osv.Queries(packages, ecosystems)
+			if len(queries) == 0 {
+				return nil, errcode.Errorf(errcode.CollectorUnavailable, "no packages from a source OSV covers (set osv.ecosystem to override)")
+			}
+			return s.osv.Scan(ctx, queries)
+		})
+	}
+	cl.wait()
+	if err := ctx.Err(); err != nil {
+		return report.ComplianceReport{}, err
+	}
+	openPorts := collector.Ports(bindings)
+
+	var ident *report.Identity
+	// The identity is this agent's, so a --root scan of another system
+	// goes without.
+	if s.cfg.Identity.Path != "" && root == nil {
+		if id, err := identity.Resolve(s.cfg.Identity.Path, hardware, time.Now()); err != nil {
+			rec.Record("collect", "identity", err)
+		} else {
+			ident = &id
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	meta := map[string]interface{}{}
+	if root != nil {
+		// The tree isn't this host: it has its own name, and nothing in
+		// it belongs in this host's behavioral baseline.
+		if hostname = root.Hostname(); hostname == "" {
+			hostname = root.Dir
+		}
+	} else {
+		// Behavioral / UEBA layer: build the baseline-aware feature
+		// vector, score it, and attach the score to the report metadata
+		// so downstream SIEM rules can branch on it.
+		snap := baseline.SnapshotFromCollected(hostname, procs, openPorts, users, packages)
+		// A partial inventory would read as users and packages
+		// vanishing, so only a complete one is learned from.
+		if cl.ok("users", "processes", "ports", "packages") {
+			s.baseline.Update(snap)
+		} else {
+			logging.Component("baseline").Warn("inventory incomplete, baseline not updated")
+		}
+		if arp != nil && arp.Gateway != nil && arp.Gateway.MAC != "" {
+			arp.KnownGatewayMACs = s.baseline.ObserveGateway(arp.Gateway.IP, arp.Gateway.MAC)
+		}
+		feats := ml.BuildFeatures(snap, s.baseline.Data())
+		score, model, scoreErr := s.scorer.Score(ctx, feats)
+		if scoreErr != nil {
+			logging.Component("ml").Warn("ml score failed", "model", model, "err", scoreErr)
+		}
+		if err := s.baseline.Save(); err != nil {
+			logging.Component("baseline").Error("baseline save failed", "path", s.cfg.Baseline.Path, "err", err)
+		}
+		mlMeta := map[string]interface{}{
+			"score":     score,
+			"model":     model,
+			"threshold": s.cfg.ML.Threshold,
+			"anomaly":   score >= s.cfg.ML.Threshold,
+			"features":  feats,
+		}
+		meta["ml"] = mlMeta
+	}
+	if osq, ok := c.(interface {
+		Info() (collector.OSQueryInfo, error)
+	}); ok {
+		if info, err := osq.Info(); err == nil {
+			meta["osquery"] = info
+		}
+	}
+
+	return report.ComplianceReport{
+		SchemaVersion:   report.SchemaVersion,
+		GeneratedAt:     time.Now().UTC(),
+		Hostname:        hostname,
+		Platform:        runtime.GOOS,
+		Scope:           s.cfg.Scope,
+		Root:            s.cfg.Root,
+		Unavailable:     unavailable,
+		SupportTier:     collector.SupportTier(),
+		Agent:           &report.AgentInfo{Version: buildinfo.AgentVersion(), Features: buildinfo.Features()},
+		Identity:        ident,
+		UserScope:       userScope,
+		Accounts:        accounts,
+		Power:           power,
+		Sharing:         sharing,
+		Bluetooth:       bt,
+		VPN:             vpn,
+		Hosts:           hosts,
+		Proxy:           proxy,
+		CronJobs:        cron,
+		Connections:     conns,
+		DNSQueries:      dns,
+		ARP:             arp,
+		Interfaces:      ifaces,
+		DiskEncryption:  disks,
+		Firewall:        firewall,
+		SSHD:            sshd,
+		OSVersion:       osVersion,
+		Secrets:         secrets,
+		RequiredAgents:  agents,
+		TLSServices:     tlsServices,
+		WebEndpoints:    web,
+		Vulnerabilities: vulns,
+		Benchmark:       benchmark,
+		Users:           users,
+		Processes:       procs,
+		OpenPorts:       openPorts,
+		PortBindings:    bindings,
+		Packages:        packages,
+		Errors:          rec.Errors(),
+		ExtraMetadata:   meta,
+		Health:          &report.AgentHealth{Collector: collectorKind(c), Collectors: cl.statuses(unavailable)},
+	}, nil
+}
+
+// liveOnly names the datasets opts asks for that only a running system
+// has, and a scan of a filesystem tree can't collect.
+func liveOnly(opts collectOptions) []string {
+	var out []string
+	for _, d := range []struct {
+		name   string
+		wanted bool
+	}{
+		{"processes", true},
+		{"port_bindings", true},
+		{"accounts", opts.Accounts},
+		{"power", opts.Power},
+		{"sharing", opts.Sharing},
+		{"bluetooth", opts.Bluetooth},
+		{"vpn", opts.VPNSignatures != nil},
+		{"proxy", opts.Proxy},
+		{"connections", opts.Connections},
+		{"dns_queries", opts.DNS},
+		{"arp", opts.ARP},
+		{"interfaces", opts.Interfaces},
+		{"disk_encryption", opts.DiskEncryption},
+		{"firewall", opts.Firewall},
+		{"required_agents", len(opts.Agents) > 0},
+		{"tls_services", opts.TLS},
+		{"web_endpoints", len(opts.WebEndpoints) > 0},
+		{"benchmark", len(opts.Profiles) > 0},
+	} {
+		if d.wanted {
+			out = append(out, d.name)
+		}
+	}
+	return out
+}
+
+// skipLiveOnly drops the policy's scripts and benchmark profiles from the
+// analysis of an image or a mounted root, listing them as not applicable:
+// they run commands on this host, which isn't the system scanned.
+func skipLiveOnly(rep *report.ComplianceReport, policies analyzer.Policies) analyzer.Policies {
+	var what string
+	switch {
+	case rep.Scope == "image":
+		what = "an image"
+	case rep.Root != "":
+		what = "a mounted root"
+	default:
+		return policies
+	}
+	for _, s := range policies.Scripts {
+		rep.NotApplicable = append(rep.NotApplicable, analyzer.NotApplicable{Kind: "script", Name: s.Name, Reason: "scripts run on a live host, not in " + what})
+	}
+	for _, p := range policies.Profiles {
+		rep.NotApplicable = append(rep.NotApplicable, analyzer.NotApplicable{Kind: "profile", Name: p, Reason: "benchmark probes run on a live host, not in " + what})
+	}
+	policies.Scripts, policies.Profiles = nil, nil
+	return policies
+}
+
+// collection runs collectors concurrently, each under panic recovery and
+// its own timeout, recording failures instead of returning them.
+type collection struct {
+	ctx     context.Context
+	rec     *guard.Recorder
+	timeout time.Duration
+	g       errgroup.Group
+
+	mu      sync.Mutex
+	started []string
+	failed  map[string]bool
+}
+
+func newCollection(ctx context.Context, rec *guard.Recorder, timeout time.Duration) *collection {
+	if timeout <= 0 {
+		timeout = 2 * time.Minute
+	}
+	return &collection{ctx: ctx, rec: rec, timeout: timeout, failed: map[string]bool{}}
+}
+
+// collectAsync starts fn and stores its result in *out when it finishes
+// in time. Collectors don't all honor the context, so one that overruns is
+// recorded and abandoned: its goroutine finishes in the background and
+// its result is dropped, and *out is never written after wait returns.
+func collectAsync[T any](cl *collection, name string, out *T, fn func(context.Context) (T, error)) {
+	cl.mu.Lock()
+	cl.started = append(cl.started, name)
+	cl.mu.Unlock()
+	cl.g.Go(func() error {
+		ctx, cancel := context.WithTimeout(cl.ctx, cl.timeout)
+		defer cancel()
+		type result struct {
+			v   T
+			err error
+		}
+		done := make(chan result, 1)
+		go func() {
+			var r result
+			r.err = cl.rec.Run("collect", name, func() (err error) {
+				r.v, err = fn(ctx)
+				return err
+			})
+			done <- r
+		}()
+		var err error
+		select {
+		case r := <-done:
+			// Keep partial results that come back alongside an error.
+			*out = r.v
+			if err = r.err; err != nil && !errors.Is(err, guard.ErrPanic) {
+				cl.rec.Record("collect", name, err)
+			}
+		case <-ctx.Done():
+			err = ctx.Err()
+			if errors.Is(err, context.DeadlineExceeded) {
+				err = errcode.Errorf(errcode.Timeout, "timed out after %s", cl.timeout)
+			}
+			cl.rec.Record("collect", name, err)
+		}
+		if err != nil {
+			cl.mu.Lock()
+			cl.failed[name] = true
+			cl.mu.Unlock()
+		}
+		return nil
+	})
+}
+
+// wait blocks until every started collector has finished or timed out.
+func (cl *collection) wait() {
+	_ = cl.g.Wait()
+}
+
+// ok reports whether all the named collectors succeeded.
+func (cl *collection) ok(names ...string) bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	for _, n := range names {
+		if cl.failed[n] {
+			return false
+		}
+	}
+	return true
+}
+
+// statuses lists each started collector as "ok" or "failed", then each
+// dataset in unavailable as "unavailable".
+func (cl *collection) statuses(unavailable []string) []report.CollectorStatus {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	out := make([]report.CollectorStatus, 0, len(cl.started)+len(unavailable))
+	for _, n := range cl.started {
+		status := "ok"
+		if cl.failed[n] {
+			status = "failed"
+		}
+		out = append(out, report.CollectorStatus{Name: n, Status: status})
+	}
+	for _, n := range unavailable {
+		out = append(out, report.CollectorStatus{Name: n, Status: "unavailable"})
+	}
+	return out
+}
+
+// packagesInput is the packages analyzer's cached input: whether the
+// packages were collected changes its result.
+type packagesInput struct {
+	Packages  []collector.Package
+	Collected bool
+}
+
+// collectorFailed reports whether rep records the collector name as
+// failed or unavailable, as opposed to having collected nothing.
+func collectorFailed(rep *report.ComplianceReport, name string) bool {
+	if rep.Health != nil {
+		for _, c := range rep.Health.Collectors {
+			if c.Name == name {
+				return c.Status != "ok"
+			}
+		}
+	}
+	for _, e := range rep.Errors {
+		if e.Stage == "collect" && e.Subsystem == name {
+			return true
+		}
+	}
+	return false
+}
+
+// analyze evaluates a collected report against policies, replacing any
+// previous violations, so a saved collection can be re-analyzed. Optional
+// sections that weren't collected simply produce no findings. With a
+// cache, an analyzer whose input and policy hash the same as in an earlier
+// scan reuses that result and is listed in rep.Unchanged. Violations are
+// scored with risk and come out riskiest first.
+func analyze(rep *report.ComplianceReport, policies analyzer.Policies, risk analyzer.RiskModel, cache *analysisCache) {
+	var rec guard.Recorder
+	var violations []analyzer.Violation
+	rep.Unchanged = nil
+	rep.Checks = policies.Checks()
+	policies, rep.NotApplicable = policies.Applicable(rep.Platform, rep.OSVersion)
+	policies = skipLiveOnly(rep, policies)
+	policyJSON, err := json.Marshal(policies)
+	if err != nil {
+		cache = nil
+	}
+	// evaluate runs fn unless its input is cached; a nil input is never
+	// cached. Results are only stored when fn succeeds.
+	evaluate := func(subsystem string, input any, fn func() ([]analyzer.Violation, error)) error {
+		var sum [sha256.Size]byte
+		cacheable := cache != nil && input != nil
+		if cacheable {
+			sum, cacheable = inputHash(policyJSON, input)
+		}
+		if cacheable {
+			if e, ok := cache.lookup(subsystem, sum, rep.GeneratedAt); ok {
+				violations = append(violations, e.violations...)
+				rep.Unchanged = append(rep.Unchanged, report.UnchangedAnalysis{Subsystem: subsystem, Since: e.since})
+				return nil
+			}
+		}
+		return rec.Run("analyze", subsystem, func() error {
+			v, err := fn()
+			violations = append(violations, v...)
+			if err == nil && cacheable {
+				cache.store(subsystem, sum, v, rep.GeneratedAt)
+			}
+			return err
+		})
+	}
+	run := func(subsystem string, input any, fn func() []analyzer.Violation) {
+		_ = evaluate(subsystem, input, func() ([]analyzer.Violation, error) { return fn(), nil })
+	}
+	run("users", rep.Users, func() []analyzer.Violation { return analyzer.AnalyzeUsers(rep.Users, policies) })
+	run("ports", rep.PortBindings, func() []analyzer.Violation { return analyzer.AnalyzePorts(rep.PortBindings, policies) })
+	run("processes", rep.Processes, func() []analyzer.Violation { return analyzer.AnalyzeProcesses(rep.Processes, policies) })
+	pkgsCollected := !collectorFailed(rep, "packages")
+	run("packages", packagesInput{rep.Packages, pkgsCollected}, func() []analyzer.Violation {
+		return analyzer.AnalyzePackages(rep.Packages, pkgsCollected, policies)
+	})
+	run("accounts", rep.Accounts, func() []analyzer.Violation { return analyzer.AnalyzeAccounts(rep.Accounts, policies) })
+	if rep.Power != nil {
+		run("power", rep.Power, func() []analyzer.Violation { return analyzer.AnalyzePower(*rep.Power, policies) })
+	}
+	run("sharing", rep.Sharing, func() []analyzer.Violation { return analyzer.AnalyzeSharing(rep.Sharing, policies) })
+	if rep.Bluetooth != nil {
+		run("bluetooth", rep.Bluetooth, func() []analyzer.Violation { return analyzer.AnalyzeBluetooth(*rep.Bluetooth, policies) })
+	}
+	if rep.VPN != nil {
+		run("vpn", rep.VPN, func() []analyzer.Violation { return analyzer.AnalyzeVPN(*rep.VPN, policies) })
+	}
+	run("hosts", rep.Hosts, func() []analyzer.Violation { return analyzer.AnalyzeHostsFile(rep.Hosts, policies) })
+	if rep.Proxy != nil {
+		run("proxy", rep.Proxy, func() []analyzer.Violation { return analyzer.AnalyzeProxy(*rep.Proxy, policies) })
+	}
+	run("connections", rep.Connections, func() []analyzer.Violation {
+		return analyzer.AnalyzeConnections(rep.Connections, policies)
+	})
+	run("dns", rep.DNSQueries, func() []analyzer.Violation { return analyzer.AnalyzeDNS(rep.DNSQueries, policies) })
+	if rep.ARP != nil {
+		run("arp", rep.ARP, func() []analyzer.Violation { return analyzer.AnalyzeARP(*rep.ARP, policies) })
+	}
+	run("interfaces", rep.Interfaces, func() []analyzer.Violation {
+		return analyzer.AnalyzeInterfaces(rep.Interfaces, policies)
+	})
+	run("disk_encryption", rep.DiskEncryption, func() []analyzer.Violation {
+		return analyzer.AnalyzeDiskEncryption(rep.DiskEncryption, policies)
+	})
+	if rep.Firewall != nil {
+		run("firewall", rep.Firewall, func() []analyzer.Violation { return analyzer.AnalyzeFirewall(*rep.Firewall, policies) })
+	}
+	run("sshd", rep.SSHD, func() []analyzer.Violation { return analyzer.AnalyzeSSHD(rep.SSHD, policies) })
+	run("os_version", rep.OSVersion, func() []analyzer.Violation {
+		return analyzer.AnalyzeOSVersion(rep.OSVersion, policies)
+	})
+	run("required_agents", rep.RequiredAgents, func() []analyzer.Violation {
+		return analyzer.AnalyzeRequiredAgents(rep.RequiredAgents, policies)
+	})
+	// Certificate expiry depends on the date, so secrets aren't cached.
+	run("secrets", nil, func() []analyzer.Violation { return analyzer.AnalyzeSecrets(rep.Secrets, policies) })
+	run("tls", rep.TLSServices, func() []analyzer.Violation { return analyzer.AnalyzeTLS(rep.TLSServices, policies) })
+	run("web", rep.WebEndpoints, func() []analyzer.Violation { return analyzer.AnalyzeWeb(rep.WebEndpoints, policies) })
+	run("vulnerabilities", rep.Vulnerabilities, func() []analyzer.Violation {
+		return analyzer.AnalyzeVulnerabilities(rep.Vulnerabilities, policies)
+	})
+	inv := analyzer.Inventory{
+		Hostname:    rep.Hostname,
+		Platform:    rep.Platform,
+		Users:       rep.Users,
+		Processes:   rep.Processes,
+		Packages:    rep.Packages,
+		Ports:       rep.PortBindings,
+		Connections: rep.Connections,
+	}
+	run("rules", inv, func() []analyzer.Violation { return analyzer.AnalyzeRules(inv, policies) })
+	run("profiles", rep.Benchmark, func() []analyzer.Violation { return analyzer.AnalyzeProfiles(rep.Benchmark, policies) })
+	// Rego and scripts see the collected data only; violations from a
+	// previous analysis of this report would be stale. Their cache key
+	// leaves out what changes on every scan (the scan time, the agent's
+	// health, errors, metadata and the previous cache hits), or it would
+	// never match.
+	input := *rep
+	input.Violations = nil
+	keyed := input
+	keyed.GeneratedAt = time.Time{}
+	keyed.Health = nil
+	keyed.ExtraMetadata = nil
+	keyed.Unchanged = nil
+	keyed.Errors = nil
+	if policies.Rego.Enabled() {
+		if err := evaluate("rego", sourcedInput(keyed, slices.Concat(policies.Rego.Paths, policies.Rego.Bundles)...), func() ([]analyzer.Violation, error) {
+			return analyzer.AnalyzeRego(context.Background(), input, policies)
+		}); err != nil && !errors.Is(err, guard.ErrPanic) {
+			rec.Record("analyze", "rego", err)
+		}
+	}
+	if len(policies.Scripts) > 0 {
+		var paths []string
+		for _, sc := range policies.Scripts {
+			if sc.Path != "" {
+				paths = append(paths, sc.Path)
+			}
+		}
+		if err := evaluate("scripts", sourcedInput(keyed, paths...), func() ([]analyzer.Violation, error) {
+			return analyzer.AnalyzeScripts(context.Background(), input, policies)
+		}); err != nil && !errors.Is(err, guard.ErrPanic) {
+			rec.Record("analyze", "scripts", err)
+		}
+	}
+	for _, e := range rep.Errors {
+		if e.Stage == "setup" {
+			violations = append(violations, analyzer.AgentViolation(e.Message, policies))
+		}
+	}
+	analyzer.ScoreRisk(violations, risk)
+	rep.Violations = violations
+	rep.Errors = append(rep.Errors, rec.Errors()...)
+}
+
+// sourcedInput is a Rego or script evaluation's cache key: the report
+// and the contents of the files the checks are loaded from. It is nil,
+// so never cached, when a file can't be read.
+func sourcedInput(rep report.ComplianceReport, paths ...string) any {
+	sum, ok := sourcesHash(paths...)
+	if !ok {
+		return nil
+	}
+	return struct {
+		Report  report.ComplianceReport
+		Sources [sha256.Size]byte
+	}{rep, sum}
+}
+
+// riskModel builds the risk model from config.
+func riskModel(cfg config.RiskConfig) (analyzer.RiskModel, error) {
+	crit, err := analyzer.ParseCriticality(cfg.Criticality)
+	if err != nil {
+		return analyzer.RiskModel{}, fmt.Errorf("risk.criticality: %w", err)
+	}
+	for cat, f := range cfg.Exposure {
+		if f <= 0 {
+			return analyzer.RiskModel{}, fmt.Errorf("risk.exposure.%s: must be positive", cat)
+		}
+	}
+	return analyzer.RiskModel{Criticality: crit, Exposure: cfg.Exposure}, nil
+}
+
+func collectCurrentUserScope() (collector.UserScope, error) {
+	u, err := user.Current()
+	if err != nil {
+		return collector.UserScope{}, err
+	}
+	return collector.CollectUserScope(u.Username, u.HomeDir)
+}
+
+// saveReport writes the report in the configured output format to the
+// output file, else the format's default file name, and returns the path
+// written.
+func (s *scanner) saveReport(rep *report.ComplianceReport) (string, error) {
+	format := s.outputFormat
+	if format == "" {
+		format = "json"
+	}
+	path := s.outputFile
+	if path == "" {
+		path = s.reportFile(format)
+	}
+	return path, s.writeReport(rep, format, path)
+}
+
+// reportFile is reportFile, with .enc added when reports are encrypted.
+func (s *scanner) reportFile(format string) string {
+	if len(s.recipients) > 0 {
+		return reportFile(format) + ".enc"
+	}
+	return reportFile(format)
+}
+
+// writeReport is writeReport, encrypting the report to s.recipients when
+// there are any. An encrypted file is only readable by its owner.
+func (s *scanner) writeReport(rep *report.ComplianceReport, format, path string) error {
+	if len(s.recipients) == 0 {
+		return writeReport(rep, format, path)
+	}
+	b, err := renderReport(rep, format)
+	if err != nil {
+		return err
+	}
+	if b, err = reportcrypt.Encrypt(s.recipients, b); err != nil {
+		return err
+	}
+	if path == "-" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(path, b, 0o600)
+}
+
+// writeReport writes rep to path as JSON, HTML, JUnit XML, Markdown or PDF;
+// "-" means stdout.
+func writeReport(rep *report.ComplianceReport, format, path string) error {
+	b, err := renderReport(rep, format)
+	if err != nil {
+		return err
+	}
+	if path == "-" {
+		_, err = os.Stdout.Write(append(b, '\n'))
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// renderReport renders rep in format, as writeReport writes it.
+func renderReport(rep *report.ComplianceReport, format string) ([]byte, error) {
+	var b []byte
+	var err error
+	switch format {
+	case "", "json":
+		b, err = rep.ToJSON()
+	case "html":
+		b, err = rep.RenderHTML()
+	case "junit":
+		b, err = rep.RenderJUnit()
+	case "markdown":
+		b, err = rep.ToMarkdown()
+	case "pdf":
+		b, err = rep.RenderPDF()
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+	return b, err
+}
+
+// record appends the report to the history database, applying its
+// retention limits, and to the evidence log.
+func (s *scanner) record(rep report.ComplianceReport) {
+	if s.evidenceLog != nil {
+		if _, err := s.evidenceLog.Append(rep); err != nil {
+			logging.Component("evidence").Error("evidence log append failed", "err", err)
+		}
+	}
+	if s.history == nil {
+		return
+	}
+	if _, err := s.history.Save(rep); err != nil {
+		logging.Component("history").Error("history save failed", "err", err)
+		return
+	}
+	if _, err := s.history.Prune(s.cfg.History.Retention, s.cfg.History.MaxReports); err != nil {
+		logging.Component("history").Error("history prune failed", "err", err)
+	}
+	if _, err := s.history.Anonymize(s.cfg.History.AnonymizeAfter); err != nil {
+		logging.Component("history").Error("history anonymize failed", "err", err)
+	}
+}
+
+// sendToSinks delivers the report to every sink. A sink that fails,
+// after its own retries, is logged and doesn't hold up the others. Each
+// attempt is counted in health, which may be nil.
+func sendToSinks(ctx context.Context, rec *guard.Recorder, health *agentHealth, sinks []sink.Sink, rep report.ComplianceReport) {
+	for _, sk := range sinks {
+		name := sk.Name()
+		err := delivery(rec, health, "export", name, rec.Run("export", name, func() error { return sk.Send(ctx, rep) }))
+		if err != nil {
+			logging.Component("sink").Error("report not sent", "sink", name, "code", errcode.NotifierFailure, "err", err)
+		} else {
+			logging.Component("sink").Info("report sent", "sink", name)
+		}
+	}
+}
+
+// delivery records how one attempt to deliver to dest went, in health's
+// counts and, when it failed other than by a panic Run already recorded,
+// in rec. It returns err.
+func delivery(rec *guard.Recorder, health *agentHealth, stage, dest string, err error) error {
+	health.delivered(stage, dest, err)
+	if err != nil && !errors.Is(err, guard.ErrPanic) {
+		rec.Record(stage, dest, err)
+	}
+	return err
+}
+
+// alertingConfig is cfg.Alerting with its links pointed at the fleet
+// server the agent reports to, unless they name another.
+func alertingConfig(cfg config.Config) config.AlertConfig {
+	acfg := cfg.Alerting
+	if acfg.Links.BaseURL == "" {
+		acfg.Links.BaseURL = cfg.Central.URL
+	}
+	acfg.Links.CredentialsPath = cfg.Central.CredentialsPath
+	return acfg
+}
+
+// sendAlerts sends the report and any violations to every alerter. Each
+// destination is independent: one failing doesn't skip the others. With
+// delta (delta alerting), each alerter is only sent the violations new
+// since its baseline, and alerters that are Resolvers hear about those
+// that disappeared; prev is the baseline for an alerter delta has none
+// for yet. Each alerter's baseline only advances past what it was
+// actually sent. Each attempt is counted in health, which may be nil.
+func sendAlerts(rec *guard.Recorder, health *agentHealth, alerters []alerting.Alerter, correlation []config.CorrelationRule, rep report.ComplianceReport, delta *alerting.DeltaState, prev *report.ComplianceReport) {
+	// Convert report to the alerting format
+	alertReport := alerting.ComplianceReport{
+		GeneratedAt:   rep.GeneratedAt,
+		Hostname:      rep.Hostname,
+		Users:         rep.Users,
+		Processes:     rep.Processes,
+		OpenPorts:     rep.OpenPorts,
+		Packages:      rep.Packages,
+		Violations:    rep.Violations,
+		ExtraMetadata: rep.ExtraMetadata,
+	}
+
+	for _, a := range alerters {
+		name := a.Name()
+		l := logging.Component("alerting").With("alerter", name)
+
+		added := rep.Violations
+		var resolved []analyzer.Violation
+		if delta != nil {
+			base, ok := delta.Baseline(name, rep.Hostname)
+			if !ok && prev != nil {
+				base = prev.Violations
+			}
+			added, resolved = alerting.Delta(rep.Hostname, base, rep.Violations)
+		}
+		addedOK, resolvedOK := sendAlert(rec, health, a, l, alertReport, correlation, added, resolved)
+		if delta != nil {
+			delta.Advance(name, rep.Hostname, rep.Violations, added, resolved, addedOK, resolvedOK)
+		}
+	}
+}
+
+// sendAlert sends one alerter the report, the added violations and, if
+// it is a Resolver, the resolved ones. It reports whether the added and
+// resolved violations were delivered; there being none counts.
+func sendAlert(rec *guard.Recorder, health *agentHealth, a alerting.Alerter, l *slog.Logger, alertReport alerting.ComplianceReport, correlation []config.CorrelationRule, added, resolved []analyzer.Violation) (addedOK, resolvedOK bool) {
+	name := a.Name()
+	r, isResolver := a.(alerting.Resolver)
+
+	// Test the connection first
+	if err := delivery(rec, health, "notify", name, rec.Run("notify", name, a.Test)); err != nil {
+		l.Warn("alerter not configured or unreachable", "err", err)
+		return len(added) == 0, !isResolver || len(resolved) == 0
+	}
+	l.Debug("alerter reachable, sending the report")
+
+	err := delivery(rec, health, "notify", name, rec.Run("notify", name, func() error {
+		return a.SendReport(alertReport)
+	}))
+	if err != nil {
+		l.Error("report not sent", "code", errcode.NotifierFailure, "err", err)
+	} else {
+		l.Info("report sent")
+	}
+
+	// Related violations go out as one incident instead of separately.
+	violations := added
+	incidents, rest := alerting.Correlate(added, correlation)
+	if len(incidents) > 0 {
+		violations = make([]analyzer.Violation, 0, len(incidents)+len(rest))
+		for _, inc := range incidents {
+			l.Info("correlated an incident", "rule", inc.Rule, "signals", inc.Signals)
+			violations = append(violations, inc.Violation())
+		}
+		violations = append(violations, rest...)
+		analyzer.SortByRisk(violations)
+	}
+
+	// Send critical violation alerts if any
+	addedOK = true
+	if len(violations) > 0 {
+		err := delivery(rec, health, "notify", name, rec.Run("notify", name, func() error {
+			return a.SendViolations(alertReport.Hostname, violations)
+		}))
+		if err != nil {
+			addedOK = false
+			l.Error("violation alert not sent", "code", errcode.NotifierFailure, "violations", len(violations), "err", err)
+		} else {
+			l.Info("violation alert sent", "violations", len(violations))
+		}
+	}
+	resolvedOK = true
+	if isResolver && len(resolved) > 0 {
+		err := delivery(rec, health, "notify", name, rec.Run("notify", name, func() error {
+			return r.SendResolved(alertReport.Hostname, resolved)
+		}))
+		if err != nil {
+			resolvedOK = false
+			l.Error("resolved notice not sent", "code", errcode.NotifierFailure, "resolved", len(resolved), "err", err)
+		} else {
+			l.Info("resolved notice sent", "resolved", len(resolved))
+		}
+	}
+	return addedOK, resolvedOK
+}
+
+// runDaemon repeats the scan every interval until SIGINT/SIGTERM. A failed
+// pass is logged and retried on the next tick; an in-flight pass is
+// allowed to finish before shutdown.
+func runDaemon(ctx context.Context, s *scanner, interval time.Duration) {
+	if interval <= 0 {
+		interval = config.Default().Interval
+	}
+	l := logging.Component("daemon")
+	l.Info("scanning on an interval", "interval", interval)
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		if _, err := s.scan(ctx); err != nil {
+			l.Error("scan failed", "code", errcode.Of(err), "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			l.Info("shutting down")
+			return
+		case <-tick.C:
+		}
+	}
+}
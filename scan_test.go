@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"compliance-agent/alerting"
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+	"compliance-agent/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// panicAlerter panics on every report it is sent.
+type panicAlerter struct{}
+
+func (panicAlerter) Name() string                                      { return "broken" }
+func (panicAlerter) Test() error                                       { return nil }
+func (panicAlerter) SendReport(alerting.ComplianceReport) error        { panic("nil map") }
+func (panicAlerter) SendViolations(string, []analyzer.Violation) error { return nil }
+
+func TestDeliver_RecordsPanics(t *testing.T) {
+	s := &scanner{alerters: []alerting.Alerter{panicAlerter{}}}
+	rep := report.ComplianceReport{Hostname: "web-1"}
+	s.deliver(context.Background(), &rep, nil)
+
+	require.Len(t, rep.Errors, 1)
+	e := rep.Errors[0]
+	assert.Equal(t, "notify", e.Stage)
+	assert.Equal(t, "broken", e.Subsystem)
+	assert.True(t, e.Panic)
+	assert.Contains(t, e.Message, "nil map")
+}
+
+func TestNewScanner_RequiresPolicyKeys(t *testing.T) {
+	cfg := config.Default()
+	cfg.Baseline.Path = filepath.Join(t.TempDir(), "baseline.json")
+	cfg.History.Path = ""
+	cfg.PolicySource.URL = "https://policy.example.com/policy.yaml"
+
+	_, err := newScanner(cfg, nil, analyzer.Policies{})
+	assert.ErrorContains(t, err, "policy_source.public_keys")
+
+	cfg.PolicySource.AllowUnsigned = true
+	s, err := newScanner(cfg, nil, analyzer.Policies{})
+	require.NoError(t, err)
+	s.close()
+}
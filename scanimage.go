@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/buildinfo"
+	"compliance-agent/collector"
+	"compliance-agent/config"
+	"compliance-agent/errcode"
+	"compliance-agent/guard"
+	"compliance-agent/image"
+	"compliance-agent/osv"
+	"compliance-agent/report"
+)
+
+// cmdScanImage implements `compliance-agent scan-image`: the policy run
+// against a container image's filesystem instead of a live host, so one
+// policy gates both image builds and the hosts running them.
+func cmdScanImage(args []string) {
+	fs := flag.NewFlagSet("scan-image", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	outputFormat := fs.String("output-format", "json", "Report format: json, html, junit, markdown or pdf")
+	out := fs.String("o", "", "Output file (default image_report.<format>, - for stdout)")
+	tmp := fs.String("tmp", "", "Unpack the image under this directory (default the system temp directory)")
+	exitCodes := addExitCodesFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s scan-image [flags] <image reference | image archive | root directory>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		usageError("scan-image: want one image reference, image archive or root directory")
+	}
+	if *common.root != "" {
+		usageError("scan-image takes a root directory as its argument; drop -root")
+	}
+	checkFormat(*outputFormat)
+	codes := parseExitCodes(*exitCodes)
+	if *out == "" {
+		*out = "image_report." + *outputFormat
+	}
+	cfg, policies := common.load()
+	risk, err := riskModel(cfg.Risk)
+	if err != nil {
+		fatal(errcode.New(errcode.ParseError, err), "config")
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+	img, err := image.Open(ctx, fs.Arg(0), *tmp)
+	if err != nil {
+		fatal(err, "scan-image")
+	}
+	rep, err := collectImage(ctx, cfg, policies, img)
+	img.Close()
+	if err != nil {
+		fatal(err, "scan-image")
+	}
+	analyze(&rep, policies, risk, nil)
+	if err := writeReport(&rep, *outputFormat, *out); err != nil {
+		fatal(err, "write report")
+	}
+	if *out != "-" {
+		fmt.Fprintf(os.Stderr, "%s: %d package(s), %d user(s), %d violation(s); saved report to %s\n",
+			fs.Arg(0), len(rep.Packages), len(rep.Users), len(rep.Violations), *out)
+	}
+	if code := codes.Code(rep.Violations); code != 0 {
+		os.Exit(code)
+	}
+}
+
+// collectImage collects what an image holds on disk: its accounts,
+// packages, OS release, sshd configuration and secrets, plus the ports
+// its config exposes. Everything about a running system (processes,
+// connections, firewall, disk encryption and the like) has nothing to
+// read in an image and is left out, so the rules on it find nothing.
+func collectImage(ctx context.Context, cfg config.Config, policies analyzer.Policies, img *image.Image) (report.ComplianceReport, error) {
+	root := collector.NewRootFS(img.Dir)
+	opts := optionsFor(policies)
+	var rec guard.Recorder
+	cl := newCollection(ctx, &rec, cfg.CollectTimeout)
+
+	var users []collector.User
+	var packages []collector.Package
+	var osVersion *collector.OSVersion
+	var sshd *collector.SSHDConfig
+	var secrets []collector.SecretFile
+	collectAsync(cl, "users", &users, root.CollectUsers)
+	// An image's inventory is small and is what it is scanned for, so
+	// it is never capped for the report.
+	collectAsync(cl, "packages", &packages, func(ctx context.Context) ([]collector.Package, error) {
+		return root.CollectPackages(ctx, 100000)
+	})
+	collectAsync(cl, "os_version", &osVersion, root.CollectOSVersion)
+	if opts.SSHD {
+		collectAsync(cl, "sshd", &sshd, func(ctx context.Context) (*collector.SSHDConfig, error) {
+			return root.CollectSSHDConfig(ctx, opts.SSHDConfigPath)
+		})
+	}
+	if len(opts.SecretPaths) > 0 {
+		collectAsync(cl, "secrets", &secrets, func(ctx context.Context) ([]collector.SecretFile, error) {
+			return root.CollectSecretFiles(ctx, opts.SecretPaths)
+		})
+	}
+	cl.wait()
+
+	var vulns []osv.Finding
+	if opts.Vulnerabilities {
+		collectAsync(cl, "vulnerabilities", &vulns, func(ctx context.Context) ([]osv.Finding, error) {
+			release, _ := root.OSRelease()
+			queries := osv.Queries(packages, osv.EcosystemsFor(cfg.OSV.Ecosystem, string(release)))
+			if len(queries) == 0 {
+				return nil, fmt.Errorf("no packages from a source OSV covers (set osv.ecosystem to override)")
+			}
+			return osv.NewClient(cfg.OSV.URL, cfg.OSV.CachePath, cfg.OSV.CacheTTL).Scan(ctx, queries)
+		})
+	}
+	cl.wait()
+	if err := ctx.Err(); err != nil {
+		return report.ComplianceReport{}, err
+	}
+
+	platform := img.OS
+	if platform == "" {
+		platform = "linux"
+	}
+	bindings := img.PortBindings()
+	info := img.Info
+	// The image's exposed ports stand in for its listeners.
+	unavailable := slices.DeleteFunc(liveOnly(opts), func(d string) bool { return d == "port_bindings" })
+	return report.ComplianceReport{
+		SchemaVersion:   report.SchemaVersion,
+		GeneratedAt:     time.Now().UTC(),
+		Hostname:        img.Ref,
+		Platform:        platform,
+		Scope:           "image",
+		Agent:           &report.AgentInfo{Version: buildinfo.AgentVersion(), Features: buildinfo.Features()},
+		Image:           &info,
+		Unavailable:     unavailable,
+		Users:           users,
+		Packages:        packages,
+		OSVersion:       osVersion,
+		SSHD:            sshd,
+		Secrets:         secrets,
+		Vulnerabilities: vulns,
+		OpenPorts:       collector.Ports(bindings),
+		PortBindings:    bindings,
+		Errors:          rec.Errors(),
+	}, nil
+}
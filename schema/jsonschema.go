@@ -0,0 +1,339 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"compliance-agent/report"
+)
+
+// JSONSchema is a JSON Schema (draft 2020-12) node, covering the subset
+// the report's Go types need.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 any                    `json:"type,omitempty"` // a type name, or a list of them
+	Format               string                 `json:"format,omitempty"`
+	ContentEncoding      string                 `json:"contentEncoding,omitempty"`
+	Const                any                    `json:"const,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	AnyOf                []*JSONSchema          `json:"anyOf,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	Defs                 map[string]*JSONSchema `json:"$defs,omitempty"`
+}
+
+// ReportSchema is the JSON Schema of the report this build writes, read
+// from report.ComplianceReport by reflection. Each named struct is a
+// definition under $defs, named package.Type. Fields Go always writes
+// are required; a nil slice, map or pointer among them may be null.
+func ReportSchema() *JSONSchema {
+	g := &jsonSchemaGen{defs: map[string]*JSONSchema{}}
+	root := g.object(reflect.TypeOf(report.ComplianceReport{}))
+	root.Schema = "https://json-schema.org/draft/2020-12/schema"
+	root.Title = "Compliance report"
+	root.Description = fmt.Sprintf("compliance_report.json, schema_version %d", report.SchemaVersion)
+	root.Properties["schema_version"] = &JSONSchema{
+		Type:        "integer",
+		Const:       report.SchemaVersion,
+		Description: "absent in reports from before the field existed, which are version 1",
+	}
+	root.Defs = g.defs
+	return root
+}
+
+// ReportSchemaJSON is ReportSchema as `schema json-schema` prints it and
+// schema/report.schema.json holds it.
+func ReportSchemaJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(ReportSchema()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type jsonSchemaGen struct {
+	defs map[string]*JSONSchema
+}
+
+// object describes struct t's JSON fields, embedded structs' included.
+func (g *jsonSchemaGen) object(t reflect.Type) *JSONSchema {
+	s := &JSONSchema{Type: "object", Properties: map[string]*JSONSchema{}}
+	g.fields(t, s)
+	return s
+}
+
+func (g *jsonSchemaGen) fields(t reflect.Type, s *JSONSchema) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			g.fields(sf.Type, s)
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		f := g.schemaFor(sf.Type)
+		if !strings.Contains(","+opts+",", ",omitempty,") {
+			s.Required = append(s.Required, name)
+			switch sf.Type.Kind() {
+			case reflect.Pointer, reflect.Slice, reflect.Map:
+				f = nullable(f)
+			}
+		}
+		s.Properties[name] = f
+	}
+}
+
+// nullable lets s be null too.
+func nullable(s *JSONSchema) *JSONSchema {
+	if name, ok := s.Type.(string); ok && s.Ref == "" {
+		n := *s
+		n.Type = []string{name, "null"}
+		return &n
+	}
+	return &JSONSchema{AnyOf: []*JSONSchema{s, {Type: "null"}}}
+}
+
+// schemaFor describes t as encoding/json writes it.
+func (g *jsonSchemaGen) schemaFor(t reflect.Type) *JSONSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	case t.Implements(jsonMarshaler) || reflect.PointerTo(t).Implements(jsonMarshaler):
+		return &JSONSchema{}
+	case t.Implements(textMarshaler) || reflect.PointerTo(t).Implements(textMarshaler):
+		return &JSONSchema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &JSONSchema{Type: "integer", Minimum: &zero}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &JSONSchema{Type: "string", ContentEncoding: "base64"}
+		}
+		return &JSONSchema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name := path.Base(t.PkgPath()) + "." + t.Name()
+		if _, ok := g.defs[name]; !ok {
+			// Registered before it is filled in, for recursive types.
+			def := &JSONSchema{}
+			g.defs[name] = def
+			*def = *g.object(t)
+		}
+		return &JSONSchema{Ref: "#/$defs/" + name}
+	}
+	return &JSONSchema{}
+}
+
+// Problem is one way a document doesn't match a schema. Path is a JSON
+// Pointer to the offending value, "" for the document itself.
+type Problem struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	if p.Path == "" {
+		return p.Message
+	}
+	return p.Path + ": " + p.Message
+}
+
+// Validate checks the JSON document doc against s, a schema ReportSchema
+// made. With strict set, a member an object's schema doesn't list is a
+// problem too; otherwise it is allowed, as newer fields are. The error
+// is for a document that isn't JSON at all.
+func (s *JSONSchema) Validate(doc []byte, strict bool) ([]Problem, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after the JSON value")
+	}
+	val := &jsonValidator{root: s, strict: strict}
+	val.check(s, v, "")
+	return val.problems, nil
+}
+
+type jsonValidator struct {
+	root     *JSONSchema
+	strict   bool
+	problems []Problem
+}
+
+func (val *jsonValidator) fail(at, format string, args ...any) {
+	val.problems = append(val.problems, Problem{Path: at, Message: fmt.Sprintf(format, args...)})
+}
+
+func (val *jsonValidator) check(s *JSONSchema, v any, at string) {
+	if s.Ref != "" {
+		def := val.root.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+		if def == nil {
+			val.fail(at, "unresolved $ref %s", s.Ref)
+			return
+		}
+		s = def
+	}
+	if len(s.AnyOf) > 0 {
+		var first []Problem
+		for i, alt := range s.AnyOf {
+			sub := &jsonValidator{root: val.root, strict: val.strict}
+			sub.check(alt, v, at)
+			if len(sub.problems) == 0 {
+				return
+			}
+			if i == 0 {
+				first = sub.problems
+			}
+		}
+		val.problems = append(val.problems, first...)
+		return
+	}
+	if s.Type != nil && !typeMatches(s.Type, v) {
+		val.fail(at, "want %s, got %s", typeNames(s.Type), jsonType(v))
+		return
+	}
+	if s.Const != nil && fmt.Sprint(v) != fmt.Sprint(s.Const) {
+		val.fail(at, "want %v, got %v", s.Const, v)
+	}
+	switch v := v.(type) {
+	case json.Number:
+		if s.Minimum != nil {
+			if f, err := v.Float64(); err == nil && f < *s.Minimum {
+				val.fail(at, "want at least %v, got %s", *s.Minimum, v)
+			}
+		}
+	case string:
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+				val.fail(at, "want an RFC 3339 timestamp, got %q", v)
+			}
+		}
+		if s.ContentEncoding == "base64" {
+			if _, err := base64.StdEncoding.DecodeString(v); err != nil {
+				val.fail(at, "want base64: %v", err)
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for i, e := range v {
+				val.check(s.Items, e, at+"/"+strconv.Itoa(i))
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				val.fail(at+"/"+pointerEscape(name), "required, missing")
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			sub := at + "/" + pointerEscape(name)
+			switch p, ok := s.Properties[name]; {
+			case ok:
+				val.check(p, v[name], sub)
+			case s.AdditionalProperties != nil:
+				val.check(s.AdditionalProperties, v[name], sub)
+			case val.strict && s.Properties != nil:
+				val.fail(sub, "unknown field")
+			}
+		}
+	}
+}
+
+func typeMatches(want any, v any) bool {
+	got := jsonType(v)
+	for _, t := range typeList(want) {
+		if t == got || t == "number" && got == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+func typeList(t any) []string {
+	if list, ok := t.([]string); ok {
+		return list
+	}
+	return []string{fmt.Sprint(t)}
+}
+
+func typeNames(t any) string { return strings.Join(typeList(t), " or ") }
+
+// jsonType is v's JSON type, with integral numbers as "integer".
+func jsonType(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) && !strings.ContainsAny(v.String(), ".") {
+			return "integer"
+		}
+		return "number"
+	}
+	return "unknown"
+}
+
+// pointerEscape escapes a member name for a JSON Pointer (RFC 6901).
+func pointerEscape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
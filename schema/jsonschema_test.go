@@ -0,0 +1,89 @@
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/collector"
+	"compliance-agent/report"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportSchema_Published(t *testing.T) {
+	b, err := ReportSchemaJSON()
+	require.NoError(t, err)
+	published, err := os.ReadFile("report.schema.json")
+	require.NoError(t, err)
+	assert.Equal(t, string(b), string(published),
+		"report.schema.json is stale: go run . schema json-schema > schema/report.schema.json")
+}
+
+func TestReportSchema(t *testing.T) {
+	s := ReportSchema()
+	assert.Equal(t, report.SchemaVersion, s.Properties["schema_version"].Const)
+	assert.Contains(t, s.Required, "generated_at")
+	assert.NotContains(t, s.Required, "schema_version", "older reports don't have it")
+	assert.NotContains(t, s.Required, "platform", "omitempty")
+	assert.Equal(t, &JSONSchema{Type: "string", Format: "date-time"}, s.Properties["generated_at"])
+	assert.Equal(t, &JSONSchema{Type: []string{"array", "null"}, Items: &JSONSchema{Ref: "#/$defs/collector.User"}},
+		s.Properties["users"], "always written, so null when nil")
+	assert.Equal(t, &JSONSchema{Ref: "#/$defs/collector.OSVersion"}, s.Properties["os_version"])
+
+	user := s.Defs["collector.User"]
+	require.NotNil(t, user)
+	assert.Equal(t, "integer", user.Properties["uid"].Type)
+}
+
+func TestValidate(t *testing.T) {
+	s := ReportSchema()
+	rep := report.ComplianceReport{
+		SchemaVersion: report.SchemaVersion,
+		GeneratedAt:   time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Hostname:      "web-1",
+		Users:         []collector.User{{Username: "root"}},
+		OSVersion:     &collector.OSVersion{Name: "Ubuntu"},
+		Violations:    []analyzer.Violation{{Category: "port", Severity: analyzer.SeverityHigh, Risk: 7.5}},
+		Errors:        []report.RunError{{Stage: "collect", Code: "timeout"}},
+		Health:        &report.AgentHealth{Deliveries: []report.DeliveryStats{{Stage: "sink", Sent: 1}}},
+	}
+	b, err := json.Marshal(rep)
+	require.NoError(t, err)
+	problems, err := s.Validate(b, true)
+	require.NoError(t, err)
+	assert.Empty(t, problems, "what the agent writes is valid")
+	b, _ = json.Marshal(report.ComplianceReport{GeneratedAt: rep.GeneratedAt, Hostname: "web-2"})
+	problems, _ = s.Validate(b, true)
+	assert.Empty(t, problems, "nil slices are null")
+
+	problems, err = s.Validate([]byte(`{
+		"schema_version": 2,
+		"generated_at": "yesterday",
+		"hostname": 7,
+		"processes": [], "open_ports": [80, -1.5], "violations": null,
+		"users": [{"username": "a/b", "uid": "0", "gid": 0, "directory": "/", "shell": "sh", "x~": 1}]
+	}`), true)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Problem{
+		{"/schema_version", "want 1, got 2"},
+		{"/generated_at", `want an RFC 3339 timestamp, got "yesterday"`},
+		{"/hostname", "want string, got integer"},
+		{"/open_ports/1", "want integer, got number"},
+		{"/users/0/uid", "want integer, got string"},
+		{"/users/0/x~0", "unknown field"},
+	}, problems)
+
+	problems, _ = s.Validate([]byte(`{"generated_at":"2026-03-01T12:00:00Z","hostname":"h","users":null,"processes":null,"open_ports":null,"violations":null,"newer":true}`), false)
+	assert.Empty(t, problems, "unknown fields are allowed unless strict")
+	problems, _ = s.Validate([]byte(`{}`), false)
+	assert.Contains(t, problems, Problem{"/hostname", "required, missing"})
+
+	_, err = s.Validate([]byte(`{"hostname":`), false)
+	assert.Error(t, err)
+	_, err = s.Validate([]byte(`{} {}`), false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,282 @@
+// Package schema documents what the agent collects, for policy authors:
+// each report dataset with its fields and types, which platforms collect
+// it, and the variables CEL rules see. Fields are read from the typed
+// models by reflection, so the document always matches the build that
+// produced it.
+package schema
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/buildinfo"
+	"compliance-agent/report"
+)
+
+// Document is the output of `compliance-agent schema dump`.
+type Document struct {
+	Agent report.AgentInfo `json:"agent"`
+	// Platforms maps each platform the agent runs on to its support
+	// tier: "full", or "minimal" for the reduced-fidelity legacy UNIX
+	// collectors.
+	Platforms   map[string]string `json:"platforms"`
+	Datasets    []Dataset         `json:"datasets"`
+	RuleTargets []RuleTarget      `json:"rule_targets"`
+}
+
+// Dataset is one section of the report, under Name in
+// compliance_report.json and in Rego and script input.
+type Dataset struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// CollectedWhen names the policy settings that turn collection on;
+	// "always" for the core inventory.
+	CollectedWhen string   `json:"collected_when"`
+	Platforms     []string `json:"platforms"`
+	Type          string   `json:"type"`
+	Fields        []Field  `json:"fields,omitempty"`
+}
+
+// Field is a JSON field. Type is string, integer, number, boolean,
+// timestamp, object, any, array<T> or map<T>; Fields describes the
+// object (or array or map element) it holds.
+type Field struct {
+	Name   string  `json:"name"`
+	Type   string  `json:"type"`
+	Fields []Field `json:"fields,omitempty"`
+}
+
+// RuleTarget is a CEL rule target and the variable its expression sees.
+// Types are CEL's: int, uint, double, bool, string, list and map.
+type RuleTarget struct {
+	Target   string  `json:"target"`
+	Variable string  `json:"variable"`
+	Fields   []Field `json:"fields"`
+}
+
+var (
+	fullPlatforms    = []string{"linux", "darwin", "windows"}
+	bsdPlatforms     = []string{"freebsd", "openbsd"}
+	minimalPlatforms = []string{"solaris", "illumos", "aix"}
+	allPlatforms     = slices.Concat(fullPlatforms, bsdPlatforms, minimalPlatforms)
+)
+
+// dataset describes a report section; its type comes from the report.
+// platforms are where a collector exists for it: the BSDs and legacy
+// UNIX only have the fallback collectors and the portable ones (files,
+// sockets, probes).
+type dataset struct {
+	name, description, collectedWhen string
+	platforms                        []string
+}
+
+var datasets = []dataset{
+	{"identity", "the agent's persistent ID and the machine's hardware UUID, serial, vendor, model and machine ID", "identity.path set in the config (the default)", allPlatforms},
+	{"image", "the scanned container image's ID, tags, OS, architecture, user, exposed ports and command", "scan-image", allPlatforms},
+	{"users", "local accounts", "always", allPlatforms},
+	{"processes", "running processes (the first 25)", "always", allPlatforms},
+	{"open_ports", "listening port numbers", "always", allPlatforms},
+	{"port_bindings", "listening sockets with protocol, address and owning process", "always", allPlatforms},
+	{"packages", "installed packages (all of them with package rules or vulnerability scanning, else the first 200)", "always", allPlatforms},
+	{"connections", "established remote connections, GeoIP-tagged when configured", "connections, or rules targeting connection", allPlatforms},
+	{"user_scope", "the invoking account's own software and persistence", "scope: user", []string{"linux", "darwin"}},
+	{"accounts", "per-account workstation settings", "workstation", []string{"linux", "darwin"}},
+	{"power", "sleep, screen lock and battery settings", "laptop", fullPlatforms},
+	{"sharing", "network-reachable file, printer and screen sharing", "sharing.prohibited", allPlatforms},
+	{"bluetooth", "radio state and paired devices", "bluetooth", fullPlatforms},
+	{"vpn", "installed VPN clients and active tunnels", "vpn", slices.Concat(fullPlatforms, bsdPlatforms)},
+	{"hosts", "hosts file entries", "hosts_file.check_overrides", allPlatforms},
+	{"cron_jobs", "system and user crontab entries and periodic scripts", "collect -all, or --root", slices.Concat([]string{"linux", "darwin"}, bsdPlatforms, minimalPlatforms)},
+	{"proxy", "system proxy settings", "proxy.require_enabled", fullPlatforms},
+	{"dns_queries", "sampled recent lookups from the resolver cache, or dns.log_paths on any platform", "dns", []string{"linux", "windows"}},
+	{"arp", "IPv4 neighbor table and default gateway", "arp", fullPlatforms},
+	{"interfaces", "network interfaces and their addresses", "interfaces", fullPlatforms},
+	{"disk_encryption", "mounted volumes and their encryption", "require_disk_encryption", fullPlatforms},
+	{"firewall", "host firewall state", "require_firewall_enabled", fullPlatforms},
+	{"sshd", "effective SSH server configuration", "sshd", allPlatforms},
+	{"os_version", "OS release and kernel", "os_version, or applies_to on a rule or script", fullPlatforms},
+	{"required_agents", "third-party agents' binaries, package versions and config hashes", "required_agents", allPlatforms},
+	{"secrets", "keys, keystores, dotenv files and certificates, by metadata only", "secrets (default paths on linux and darwin only)", allPlatforms},
+	{"tls_services", "local TLS listeners with their versions and suites", "tls", allPlatforms},
+	{"web_endpoints", "local web consoles, fetched unauthenticated", "web", allPlatforms},
+	{"vulnerabilities", "OSV findings for deb and rpm packages", "vulnerabilities", []string{"linux"}},
+	{"benchmark", "benchmark profile probe results", "profiles", nil}, // from the profiles
+}
+
+// Dump describes this build's datasets and rule targets. With platform
+// set, only what that platform collects is listed.
+func Dump(platform string) Document {
+	doc := Document{
+		Agent:     report.AgentInfo{Version: buildinfo.AgentVersion(), Features: buildinfo.Features()},
+		Platforms: map[string]string{},
+	}
+	for _, p := range allPlatforms {
+		doc.Platforms[p] = "full"
+		if slices.Contains(minimalPlatforms, p) {
+			doc.Platforms[p] = "minimal"
+		}
+	}
+	fields := reportFields()
+	for _, d := range datasets {
+		platforms := d.platforms
+		if d.name == "benchmark" {
+			platforms = profilePlatforms()
+		}
+		if platform != "" && !slices.Contains(platforms, platform) {
+			continue
+		}
+		f := fields[d.name]
+		doc.Datasets = append(doc.Datasets, Dataset{
+			Name:          d.name,
+			Description:   d.description,
+			CollectedWhen: d.collectedWhen,
+			Platforms:     platforms,
+			Type:          f.Type,
+			Fields:        f.Fields,
+		})
+	}
+	for _, t := range analyzer.RuleTargets {
+		name, sample, _ := analyzer.RuleVariable(t)
+		doc.RuleTargets = append(doc.RuleTargets, RuleTarget{Target: t, Variable: name, Fields: celFields(sample)})
+	}
+	return doc
+}
+
+func profilePlatforms() []string {
+	var out []string
+	for _, name := range analyzer.ProfileNames() {
+		if p, ok := analyzer.LookupProfile(name); ok && !slices.Contains(out, p.Platform) {
+			out = append(out, p.Platform)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// reportFields describes every top-level report field by JSON name.
+func reportFields() map[string]Field {
+	out := map[string]Field{}
+	for _, f := range structFields(reflect.TypeOf(report.ComplianceReport{}), nil) {
+		out[f.Name] = f
+	}
+	return out
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// structFields lists t's JSON fields; seen guards against recursive
+// types.
+func structFields(t reflect.Type, seen []reflect.Type) []Field {
+	var out []Field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			out = append(out, structFields(sf.Type, seen)...)
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		f := describe(sf.Type, seen)
+		f.Name = name
+		out = append(out, f)
+	}
+	return out
+}
+
+// describe gives the JSON type of t as encoding/json writes it.
+func describe(t reflect.Type, seen []reflect.Type) Field {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return Field{Type: "timestamp"}
+	case t.Implements(jsonMarshaler) || reflect.PointerTo(t).Implements(jsonMarshaler):
+		return Field{Type: "any"}
+	case t.Implements(textMarshaler) || reflect.PointerTo(t).Implements(textMarshaler):
+		return Field{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return Field{Type: "string"}
+	case reflect.Bool:
+		return Field{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Field{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Field{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Field{Type: "string"} // base64
+		}
+		e := describe(t.Elem(), seen)
+		return Field{Type: "array<" + e.Type + ">", Fields: e.Fields}
+	case reflect.Map:
+		e := describe(t.Elem(), seen)
+		return Field{Type: "map<" + e.Type + ">", Fields: e.Fields}
+	case reflect.Struct:
+		if slices.Contains(seen, t) {
+			return Field{Type: "object"}
+		}
+		return Field{Type: "object", Fields: structFields(t, append(seen, t))}
+	}
+	return Field{Type: "any"}
+}
+
+// celFields describes a rule variable sample, sorted by name.
+func celFields(sample map[string]any) []Field {
+	out := make([]Field, 0, len(sample))
+	for name, v := range sample {
+		f := celType(v)
+		f.Name = name
+		out = append(out, f)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func celType(v any) Field {
+	switch v := v.(type) {
+	case map[string]any:
+		return Field{Type: "map", Fields: celFields(v)}
+	case []any:
+		f := Field{Type: "list"}
+		if len(v) > 0 {
+			e := celType(v[0])
+			f.Type, f.Fields = "list<"+e.Type+">", e.Fields
+		}
+		return f
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.String:
+		return Field{Type: "string"}
+	case reflect.Bool:
+		return Field{Type: "bool"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Field{Type: "int"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Field{Type: "uint"}
+	case reflect.Float32, reflect.Float64:
+		return Field{Type: "double"}
+	}
+	return Field{Type: "dyn"}
+}
@@ -0,0 +1,75 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDump(t *testing.T) {
+	doc := Dump("")
+	fields := reportFields()
+	byName := map[string]Dataset{}
+	for _, d := range doc.Datasets {
+		// A renamed report field would leave the table pointing nowhere.
+		_, ok := fields[d.Name]
+		assert.True(t, ok, "dataset %s is not a report field", d.Name)
+		assert.NotEmpty(t, d.Platforms, d.Name)
+		byName[d.Name] = d
+	}
+	assert.Len(t, byName, len(datasets))
+
+	users := byName["users"]
+	assert.Equal(t, "array<object>", users.Type)
+	assert.Contains(t, users.Fields, Field{Name: "uid", Type: "integer"})
+	assert.Contains(t, byName["os_version"].Fields, Field{Name: "version", Type: "string"})
+	assert.ElementsMatch(t, []string{"linux", "darwin"}, byName["benchmark"].Platforms)
+	assert.Equal(t, "minimal", doc.Platforms["aix"])
+
+	require.Len(t, doc.RuleTargets, 6)
+	pkg := doc.RuleTargets[2]
+	assert.Equal(t, "package", pkg.Target)
+	assert.Equal(t, "pkg", pkg.Variable)
+	assert.Contains(t, pkg.Fields, Field{Name: "version", Type: "string"})
+	host := doc.RuleTargets[5]
+	var users2 Field
+	for _, f := range host.Fields {
+		if f.Name == "users" {
+			users2 = f
+		}
+	}
+	assert.Equal(t, "list<map>", users2.Type)
+	assert.Contains(t, users2.Fields, Field{Name: "uid", Type: "int"})
+}
+
+func TestDump_Platform(t *testing.T) {
+	var names []string
+	for _, d := range Dump("aix").Datasets {
+		names = append(names, d.Name)
+	}
+	assert.Contains(t, names, "packages")
+	assert.NotContains(t, names, "firewall")
+	assert.NotContains(t, names, "benchmark")
+}
+
+func TestDescribe(t *testing.T) {
+	type node struct {
+		Name     string            `json:"name"`
+		Skip     string            `json:"-"`
+		Children []node            `json:"children,omitempty"`
+		Labels   map[string]string `json:"labels"`
+		Raw      []byte            `json:"raw"`
+		Score    *float64          `json:"score"`
+	}
+	f := describe(reflect.TypeOf(node{}), nil)
+	assert.Equal(t, "object", f.Type)
+	assert.Equal(t, []Field{
+		{Name: "name", Type: "string"},
+		{Name: "children", Type: "array<object>"},
+		{Name: "labels", Type: "map<string>"},
+		{Name: "raw", Type: "string"},
+		{Name: "score", Type: "number"},
+	}, f.Fields)
+}
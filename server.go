@@ -0,0 +1,48 @@
+//go:build !no_server && !slim
+
+package main
+
+import (
+	"flag"
+
+	"compliance-agent/server"
+	"compliance-agent/storage"
+)
+
+// cmdServer runs the fleet server that daemons configured with
+// central.url enroll with and upload to.
+func cmdServer(args []string) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to YAML config (optional)")
+	addr := fs.String("addr", "", "Listen address (overrides config server.addr)")
+	dbPath := fs.String("db", "", "Fleet database (overrides config server.db_path)")
+	policyPath := fs.String("policy", "", "Policy to hand out to agents (overrides config server.policy_path)")
+	parseFlags(fs, args)
+
+	cfg := loadConfig(*configPath)
+	if *addr != "" {
+		cfg.Server.Addr = *addr
+	}
+	if *dbPath != "" {
+		cfg.Server.DBPath = *dbPath
+	}
+	if *policyPath != "" {
+		cfg.Server.PolicyPath = *policyPath
+	}
+	checkReleaseSignature(cfg.Release)
+
+	store, err := storage.OpenFleet(cfg.Server.DBPath)
+	if err != nil {
+		fatal(err, "server")
+	}
+	defer store.Close()
+	srv, err := server.New(cfg, store)
+	if err != nil {
+		fatal(err, "server")
+	}
+	ctx, cancel := signalContext()
+	defer cancel()
+	if err := srv.ListenAndServe(ctx, cfg.Server); err != nil {
+		fatal(err, "server")
+	}
+}
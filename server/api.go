@@ -0,0 +1,52 @@
+// Package server is the fleet server: agents in daemon mode enroll with a
+// shared token, upload each report and fetch their policy from it, and
+// operators list hosts and read their reports through its API. Client is
+// the agent's side of the same API.
+//
+// Builds tagged no_server (or slim) leave the server out; Client and the
+// API types it shares with the server are always there.
+package server
+
+// EnrollRequest is the body of POST /api/v1/enroll. CSR is a PEM
+// certificate request for the agent's key, which a mutual-TLS server
+// requires and signs. AgentUUID and HardwareUUID identify the host: one
+// that enrolled before under either gets its old agent ID back if it
+// proves it is that agent, with PreviousToken, the old agent's client
+// certificate or an admin's approval. Both UUIDs are in every report, so
+// they prove nothing on their own.
+type EnrollRequest struct {
+	Hostname     string `json:"hostname"`
+	Platform     string `json:"platform,omitempty"`
+	AgentVersion string `json:"agent_version,omitempty"`
+	CSR          string `json:"csr,omitempty"`
+	AgentUUID    string `json:"agent_uuid,omitempty"`
+	HardwareUUID string `json:"hardware_uuid,omitempty"`
+	// PreviousToken is the token of the agent's last enrollment, sent
+	// when it enrolls again after the server stopped accepting it.
+	PreviousToken string `json:"previous_token,omitempty"`
+}
+
+// Credentials are what enrollment issues an agent. Token authenticates
+// its later requests; the server keeps only its hash. Certificate is the
+// agent's PEM client certificate from a mutual-TLS server.
+type Credentials struct {
+	AgentID     string `json:"agent_id"`
+	Token       string `json:"token"`
+	Certificate string `json:"certificate,omitempty"`
+}
+
+// CertificateRequest is the body of POST /api/v1/certificate, which
+// renews an agent's client certificate.
+type CertificateRequest struct {
+	CSR string `json:"csr"`
+}
+
+// CertificateResponse is the renewed certificate.
+type CertificateResponse struct {
+	Certificate string `json:"certificate"`
+}
+
+// UploadResponse is the body returned for an uploaded report.
+type UploadResponse struct {
+	ID int64 `json:"id"`
+}
@@ -0,0 +1,483 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"compliance-agent/config"
+	"compliance-agent/logging"
+	"compliance-agent/report"
+	"compliance-agent/reportcrypt"
+)
+
+// errUnauthorized is a 401 from the server.
+var errUnauthorized = errors.New("unauthorized")
+
+// storedCredentials is the credentials file: the server they were
+// issued by, so pointing the agent at another one re-enrolls it, and the
+// PEM key of the client certificate, if any.
+type storedCredentials struct {
+	Server string `json:"server"`
+	Credentials
+	Key string `json:"key,omitempty"`
+}
+
+// Client is an agent's connection to the fleet server. It enrolls on
+// first use and keeps the credentials it is issued on disk.
+type Client struct {
+	base        string
+	enrollToken string
+	credsPath   string
+	http        *http.Client
+	// encryptTo, from central.encrypt_to, is the server's report key
+	// uploads are encrypted to.
+	encryptTo *ecdh.PublicKey
+
+	mu       sync.Mutex
+	creds    *Credentials
+	keyPEM   []byte
+	etag     string
+	cache    []byte
+	cacheSig []byte
+	// previousToken is the token of credentials dropped since the last
+	// enrollment, which proves to the server that the agent is the one
+	// it enrolled before.
+	previousToken string
+
+	// cert is the client certificate offered in TLS handshakes. It is
+	// read during handshakes, while mu may be held, so it has its own
+	// synchronization.
+	cert atomic.Pointer[tls.Certificate]
+}
+
+// NewClient builds a client from config, falling back to the
+// COMPLIANCE_ENROLL_TOKEN environment variable. Plain http URLs are only
+// accepted for localhost. The client offers its certificate to any
+// server that asks for one.
+func NewClient(cfg config.CentralConfig) (*Client, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("central.url: %q is not a URL", cfg.URL)
+	}
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && (u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1" || u.Hostname() == "::1"):
+	default:
+		return nil, fmt.Errorf("central.url: %q must be https", cfg.URL)
+	}
+	c := &Client{
+		base:        strings.TrimSuffix(cfg.URL, "/"),
+		enrollToken: cfg.EnrollToken,
+		credsPath:   cfg.CredentialsPath,
+	}
+	if c.enrollToken == "" {
+		c.enrollToken = os.Getenv("COMPLIANCE_ENROLL_TOKEN")
+	}
+	if c.credsPath == "" {
+		c.credsPath = "agent_credentials.json"
+	}
+	if cfg.EncryptTo != "" {
+		if c.encryptTo, err = reportcrypt.ParseRecipient(cfg.EncryptTo); err != nil {
+			return nil, fmt.Errorf("central.encrypt_to: %w", err)
+		}
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if cert := c.cert.Load(); cert != nil {
+				return cert, nil
+			}
+			return &tls.Certificate{}, nil // none yet: enrolling
+		},
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("central.ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("central.ca_file: no certificates in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(cfg.PinSHA256) > 0 {
+		pins := map[string]bool{}
+		for _, p := range cfg.PinSHA256 {
+			if b, err := base64.StdEncoding.DecodeString(p); err != nil || len(b) != sha256.Size {
+				return nil, fmt.Errorf("central.pin_sha256: %q is not a base64 SHA-256", p)
+			}
+			pins[p] = true
+		}
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			for _, chain := range cs.VerifiedChains {
+				for _, cert := range chain {
+					if pins[spkiPin(cert)] {
+						return nil
+					}
+				}
+			}
+			return errors.New("server certificate chain matches no central.pin_sha256")
+		}
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = tlsConfig
+	c.http = &http.Client{Timeout: timeout, Transport: t}
+	return c, nil
+}
+
+// Enroll makes sure the agent holds credentials, loading them from disk
+// or enrolling with the enroll token.
+func (c *Client) Enroll(ctx context.Context, req EnrollRequest) (Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enrollLocked(ctx, req)
+}
+
+func (c *Client) enrollLocked(ctx context.Context, req EnrollRequest) (Credentials, error) {
+	if c.creds != nil {
+		return *c.creds, nil
+	}
+	if b, err := os.ReadFile(c.credsPath); err == nil {
+		var stored storedCredentials
+		if err := json.Unmarshal(b, &stored); err != nil {
+			return Credentials{}, fmt.Errorf("credentials %s: %w", c.credsPath, err)
+		}
+		if stored.Server == c.base && stored.Token != "" {
+			if err := c.useCertificate(stored.Certificate, []byte(stored.Key)); err != nil {
+				return Credentials{}, fmt.Errorf("credentials %s: %w", c.credsPath, err)
+			}
+			c.creds = &stored.Credentials
+			return stored.Credentials, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return Credentials{}, fmt.Errorf("credentials: %w", err)
+	}
+
+	if c.enrollToken == "" {
+		return Credentials{}, errors.New("not enrolled and no enroll_token (or COMPLIANCE_ENROLL_TOKEN)")
+	}
+	// Every enrollment sends a CSR; a server without mutual TLS ignores
+	// it.
+	keyPEM, csrPEM, err := newKey(req.Hostname)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("enroll: %w", err)
+	}
+	req.CSR = string(csrPEM)
+	if c.previousToken != "" {
+		req.PreviousToken = c.previousToken
+	}
+	body, _ := json.Marshal(req)
+	resp, err := c.do(ctx, http.MethodPost, "/api/v1/enroll", c.enrollToken, http.Header{"Content-Type": {"application/json"}}, body)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("enroll: %w", err)
+	}
+	var creds Credentials
+	if err := json.Unmarshal(resp, &creds); err != nil || creds.Token == "" {
+		return Credentials{}, fmt.Errorf("enroll: unexpected response %q", truncate(resp))
+	}
+	if creds.Certificate == "" {
+		keyPEM = nil
+	}
+	if err := c.useCertificate(creds.Certificate, keyPEM); err != nil {
+		return Credentials{}, fmt.Errorf("enroll: %w", err)
+	}
+	if err := c.save(creds, keyPEM); err != nil {
+		return Credentials{}, err
+	}
+	c.creds, c.keyPEM, c.previousToken = &creds, keyPEM, ""
+	return creds, nil
+}
+
+// useCertificate makes certPEM, if any, the certificate offered to the
+// server. Pooled connections made with the previous one are closed.
+func (c *Client) useCertificate(certPEM string, keyPEM []byte) error {
+	if certPEM == "" {
+		c.cert.Store(nil)
+	} else {
+		pair, err := tls.X509KeyPair([]byte(certPEM), keyPEM)
+		if err != nil {
+			return fmt.Errorf("client certificate: %w", err)
+		}
+		if pair.Leaf, err = x509.ParseCertificate(pair.Certificate[0]); err != nil {
+			return fmt.Errorf("client certificate: %w", err)
+		}
+		c.cert.Store(&pair)
+	}
+	c.keyPEM = keyPEM
+	c.http.CloseIdleConnections()
+	return nil
+}
+
+// renewLocked renews the client certificate once two thirds of its
+// lifetime have passed. A failed renewal is logged and retried on the
+// next request while the certificate is still good; an expired one can't
+// authenticate, so the agent enrolls again.
+func (c *Client) renewLocked(ctx context.Context, req EnrollRequest) error {
+	cert := c.cert.Load()
+	if cert == nil || c.creds == nil {
+		return nil
+	}
+	leaf, now := cert.Leaf, time.Now()
+	if now.After(leaf.NotAfter) {
+		if err := c.forgetLocked(); err != nil {
+			return err
+		}
+		_, err := c.enrollLocked(ctx, req)
+		return err
+	}
+	if now.Before(leaf.NotBefore.Add(leaf.NotAfter.Sub(leaf.NotBefore) * 2 / 3)) {
+		return nil
+	}
+	keyPEM, csrPEM, err := newKey(req.Hostname)
+	if err != nil {
+		return err
+	}
+	body, _ := json.Marshal(CertificateRequest{CSR: string(csrPEM)})
+	resp, err := c.do(ctx, http.MethodPost, "/api/v1/certificate", c.creds.Token, http.Header{"Content-Type": {"application/json"}}, body)
+	var renewed CertificateResponse
+	if err == nil {
+		err = json.Unmarshal(resp, &renewed)
+	}
+	if err == nil {
+		err = c.useCertificate(renewed.Certificate, keyPEM)
+	}
+	if err != nil {
+		logging.Component("central").Warn("client certificate renewal failed", "expires", leaf.NotAfter.Format(time.RFC3339), "err", err)
+		return nil
+	}
+	creds := *c.creds
+	creds.Certificate = renewed.Certificate
+	c.creds = &creds
+	return c.save(creds, keyPEM)
+}
+
+// forgetLocked drops the credentials so the next request enrolls again.
+func (c *Client) forgetLocked() error {
+	if c.creds != nil {
+		c.previousToken = c.creds.Token
+	}
+	c.creds, c.keyPEM = nil, nil
+	c.cert.Store(nil)
+	if err := os.Remove(c.credsPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("credentials: %w", err)
+	}
+	return nil
+}
+
+// save writes the credentials readable by the agent's user only.
+func (c *Client) save(creds Credentials, keyPEM []byte) error {
+	b, _ := json.MarshalIndent(storedCredentials{Server: c.base, Credentials: creds, Key: string(keyPEM)}, "", "  ")
+	if dir := filepath.Dir(c.credsPath); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("credentials dir: %w", err)
+		}
+	}
+	tmp := c.credsPath + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("credentials: %w", err)
+	}
+	return os.Rename(tmp, c.credsPath)
+}
+
+// authed runs fn with the agent token, enrolling first if needed and
+// renewing the client certificate when it is due. A 401 means the server
+// no longer knows the agent, e.g. its database was reset, or wants a
+// certificate the agent lacks, so the credentials are dropped and fn
+// retried once after enrolling again.
+func (c *Client) authed(ctx context.Context, req EnrollRequest, fn func(token string) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		if _, err := c.enrollLocked(ctx, req); err != nil {
+			return err
+		}
+		if err := c.renewLocked(ctx, req); err != nil {
+			return err
+		}
+		err := fn(c.creds.Token)
+		if !errors.Is(err, errUnauthorized) || attempt > 0 {
+			return err
+		}
+		if err := c.forgetLocked(); err != nil {
+			return err
+		}
+	}
+}
+
+// Upload sends a report, gzipped and, with central.encrypt_to, encrypted,
+// and returns the server's ID for it.
+func (c *Client) Upload(ctx context.Context, rep report.ComplianceReport) (int64, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(rep); err != nil {
+		return 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return 0, err
+	}
+	header := http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"gzip"}}
+	body := buf.Bytes()
+	if c.encryptTo != nil {
+		enc, err := reportcrypt.Encrypt([]*ecdh.PublicKey{c.encryptTo}, body)
+		if err != nil {
+			return 0, err
+		}
+		header = http.Header{"Content-Type": {reportcrypt.ContentType}}
+		body = enc
+	}
+	var out UploadResponse
+	err := c.authed(ctx, enrollRequest(rep), func(token string) error {
+		resp, err := c.do(ctx, http.MethodPost, "/api/v1/reports", token, header, body)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(resp, &out)
+	})
+	return out.ID, err
+}
+
+// Policy fetches the policy the server hands out and its signature (nil
+// when unsigned). ok is false when it has none, and the agent should keep
+// its own. An unchanged policy is served from the client's copy.
+func (c *Client) Policy(ctx context.Context, req EnrollRequest) (policy, sig []byte, ok bool, err error) {
+	err = c.authed(ctx, req, func(token string) error {
+		header := http.Header{}
+		if c.etag != "" {
+			header.Set("If-None-Match", c.etag)
+		}
+		r, err := c.request(ctx, http.MethodGet, "/api/v1/policy", token, header, nil)
+		if err != nil {
+			return err
+		}
+		defer r.Body.Close()
+		switch r.StatusCode {
+		case http.StatusNotModified:
+			policy, sig, ok = c.cache, c.cacheSig, true
+			return nil
+		case http.StatusNotFound:
+			return nil
+		}
+		b, err := readResponse(r)
+		if err != nil {
+			return err
+		}
+		if h := r.Header.Get("X-Policy-Signature"); h != "" {
+			sig = []byte(h)
+		}
+		c.etag, c.cache, c.cacheSig = r.Header.Get("ETag"), b, sig
+		policy, ok = b, true
+		return nil
+	})
+	return policy, sig, ok, err
+}
+
+func (c *Client) request(ctx context.Context, method, path, token string, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return c.http.Do(req)
+}
+
+// do sends a request and returns the body of a 2xx response.
+func (c *Client) do(ctx context.Context, method, path, token string, header http.Header, body []byte) ([]byte, error) {
+	r, err := c.request(ctx, method, path, token, header, body)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	return readResponse(r)
+}
+
+func readResponse(r *http.Response) ([]byte, error) {
+	b, _ := io.ReadAll(io.LimitReader(r.Body, 64<<20))
+	if r.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("%w: %s", errUnauthorized, truncate(b))
+	}
+	if r.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("server returned %s: %s", r.Status, truncate(b))
+	}
+	return b, nil
+}
+
+// enrollRequest describes the host a report came from.
+func enrollRequest(rep report.ComplianceReport) EnrollRequest {
+	req := EnrollRequest{Hostname: rep.Hostname, Platform: rep.Platform}
+	if rep.Agent != nil {
+		req.AgentVersion = rep.Agent.Version
+	}
+	if rep.Identity != nil {
+		req.AgentUUID = rep.Identity.AgentID
+		if rep.Identity.Hardware != nil {
+			req.HardwareUUID = rep.Identity.Hardware.UUID
+		}
+	}
+	return req
+}
+
+func truncate(b []byte) string {
+	s := strings.TrimSpace(string(b))
+	if len(s) > 200 {
+		s = s[:200] + "..."
+	}
+	return s
+}
+
+// newKey makes an agent key and a CSR for it, both PEM.
+func newKey(hostname string) (keyPEM, csrPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: hostname},
+	}, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}), nil
+}
+
+// spkiPin is the base64 SHA-256 of a certificate's public key, the form
+// central.pin_sha256 takes.
+func spkiPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,121 @@
+//go:build !no_server && !slim
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"compliance-agent/report"
+)
+
+// maxJSONDepth bounds how deeply a request body may nest. No report goes
+// beyond a dozen levels; the bound stops a body of brackets from making
+// the decoder work through thousands.
+const maxJSONDepth = 64
+
+// Unknown-field policies, for server.unknown_fields.
+const (
+	unknownFieldsIgnore = "ignore"
+	unknownFieldsReject = "reject"
+)
+
+// decodeJSON decodes b, a single JSON value, into v. With strict set, a
+// member v has no field for is an error; otherwise it is dropped, so it
+// never reaches storage either way.
+func decodeJSON(b []byte, v any, strict bool) error {
+	if err := checkDepth(b, maxJSONDepth); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("empty body")
+		}
+		return err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("unexpected data after the JSON value")
+	}
+	return nil
+}
+
+// checkDepth fails when b nests objects and arrays more than max deep.
+// It only counts brackets outside strings; the decoder checks the rest.
+func checkDepth(b []byte, max int) error {
+	depth, inString, escaped := 0, false, false
+	for _, c := range b {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch c {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			if depth++; depth > max {
+				return fmt.Errorf("nested more than %d levels deep", max)
+			}
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return nil
+}
+
+// decodeReport decodes and checks an uploaded report: one JSON object of
+// a schema version this server supports, up to report.SchemaVersion,
+// with the fields storage keys on. A report without a version, from an
+// agent that predates the field, is stored as version 1.
+func decodeReport(b []byte, strict bool) (report.ComplianceReport, error) {
+	var rep report.ComplianceReport
+	if err := decodeJSON(b, &rep, strict); err != nil {
+		return rep, err
+	}
+	if rep.SchemaVersion == 0 {
+		rep.SchemaVersion = 1
+	}
+	if rep.SchemaVersion < 1 || rep.SchemaVersion > report.SchemaVersion {
+		return rep, fmt.Errorf("schema_version %d is not supported (this server reads 1 to %d)", rep.SchemaVersion, report.SchemaVersion)
+	}
+	switch {
+	case rep.Hostname == "" || rep.GeneratedAt.IsZero():
+		return rep, errors.New("hostname and generated_at are required")
+	case len(rep.Hostname) > 253 || strings.ContainsFunc(rep.Hostname, isControl):
+		return rep, errors.New("hostname is not a valid host name")
+	}
+	return rep, nil
+}
+
+func isControl(r rune) bool { return r < 0x20 || r == 0x7f }
+
+// recoverPanics answers a request whose handler panicked with a 500, and
+// logs the panic, instead of dropping the connection.
+func recoverPanics(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if p := recover(); p != nil {
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				logger().Error("panic serving a request", "method", r.Method, "path", r.URL.Path, "panic", p, "stack", string(debug.Stack()))
+				writeError(w, http.StatusInternalServerError, "internal error")
+			}
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,183 @@
+//go:build !no_server && !no_history && !slim
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"compliance-agent/collector"
+	"compliance-agent/config"
+	"compliance-agent/report"
+	"compliance-agent/reportcrypt"
+	"compliance-agent/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const minimalReport = `{"generated_at":"2026-03-01T12:00:00Z","hostname":"web-1"}`
+
+func TestDecodeReport(t *testing.T) {
+	rep, err := decodeReport([]byte(minimalReport), true)
+	require.NoError(t, err)
+	assert.Equal(t, "web-1", rep.Hostname)
+	assert.Equal(t, 1, rep.SchemaVersion, "no version is version 1")
+
+	_, err = decodeReport([]byte(`{"schema_version":1,"generated_at":"2026-03-01T12:00:00Z","hostname":"web-1"}`), true)
+	assert.NoError(t, err)
+
+	unknown := `{"generated_at":"2026-03-01T12:00:00Z","hostname":"web-1","users":[{"username":"root","shoe_size":9}]}`
+	_, err = decodeReport([]byte(unknown), true)
+	assert.ErrorContains(t, err, "shoe_size", "rejected when strict, however deep")
+	rep, err = decodeReport([]byte(unknown), false)
+	require.NoError(t, err)
+	b, _ := json.Marshal(rep)
+	assert.NotContains(t, string(b), "shoe_size", "dropped otherwise, so it isn't stored")
+
+	for body, want := range map[string]string{
+		"":                            "empty body",
+		"not json":                    "invalid character",
+		`[]`:                          "cannot unmarshal array",
+		`{"hostname":"web-1"}`:        "generated_at are required",
+		minimalReport + minimalReport: "unexpected data after",
+		minimalReport + " x":          "unexpected data after",
+		`{"schema_version":2,"generated_at":"2026-03-01T12:00:00Z","hostname":"web-1"}`:                        "schema_version 2 is not supported",
+		`{"schema_version":-1,"generated_at":"2026-03-01T12:00:00Z","hostname":"web-1"}`:                       "schema_version -1 is not supported",
+		`{"generated_at":"2026-03-01T12:00:00Z","hostname":"web-1\n"}`:                                         "not a valid host name",
+		`{"generated_at":"2026-03-01T12:00:00Z","hostname":"web-1","errors":` + strings.Repeat("[", 100) + `}`: "nested more than 64",
+	} {
+		_, err := decodeReport([]byte(body), false)
+		assert.ErrorContains(t, err, want, body)
+	}
+}
+
+func TestCheckDepth(t *testing.T) {
+	assert.NoError(t, checkDepth([]byte(`{"a":[[1]],"b":"[[[[[[\"[["}`), 3), "brackets in strings don't count")
+	assert.Error(t, checkDepth([]byte(`{"a":[[1]]}`), 2))
+}
+
+// newConfiguredServer is newTestServer with edit applied to its config.
+func newConfiguredServer(t *testing.T, edit func(*config.ServerConfig)) (*httptest.Server, *storage.FleetStore, config.Config) {
+	t.Helper()
+	store, err := storage.OpenFleet(filepath.Join(t.TempDir(), "fleet.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	cfg := config.Default()
+	cfg.Server.EnrollTokens = []string{testEnroll}
+	cfg.Server.AdminToken = testAdmin
+	edit(&cfg.Server)
+	s, err := New(cfg, store)
+	require.NoError(t, err)
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+	return srv, store, cfg
+}
+
+func TestServer_UnknownFields(t *testing.T) {
+	srv, store, cfg := newConfiguredServer(t, func(c *config.ServerConfig) { c.UnknownFields = "reject" })
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/v1/enroll", strings.NewReader(`{"hostname":"web-1","color":"red"}`))
+	req.Header.Set("Authorization", "Bearer "+testEnroll)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	c, _ := newTestClient(t, srv.URL, testEnroll)
+	creds, err := c.Enroll(context.Background(), EnrollRequest{Hostname: "web-1"})
+	require.NoError(t, err, "the agent's own requests have no unknown fields")
+	for body, status := range map[string]int{
+		minimalReport: http.StatusCreated,
+		`{"generated_at":"2026-03-01T12:00:00Z","hostname":"web-1","extra":true}`: http.StatusBadRequest,
+	} {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/v1/reports", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+creds.Token)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, status, resp.StatusCode, body)
+	}
+
+	cfg.Server.UnknownFields = "warn"
+	_, err = New(cfg, store)
+	assert.ErrorContains(t, err, "unknown_fields")
+}
+
+func TestServer_EncryptedUpload(t *testing.T) {
+	privPEM, pubPEM, err := reportcrypt.GenerateKey()
+	require.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "report.key")
+	require.NoError(t, os.WriteFile(keyPath, privPEM, 0o600))
+	srv, store, cfg := newConfiguredServer(t, func(c *config.ServerConfig) { c.ReportKey = keyPath })
+
+	c, err := NewClient(config.CentralConfig{URL: srv.URL, EnrollToken: testEnroll,
+		CredentialsPath: filepath.Join(t.TempDir(), "creds.json"), EncryptTo: string(pubPEM)})
+	require.NoError(t, err)
+	ctx := context.Background()
+	rep := report.ComplianceReport{GeneratedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), Hostname: "web-1",
+		Users: []collector.User{{Username: "alice"}}}
+	id, err := c.Upload(ctx, rep)
+	require.NoError(t, err)
+	got, _, ok, err := store.Report(id)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "alice", got.Users[0].Username)
+
+	// A server without the key refuses encrypted uploads.
+	plainSrv, _, _ := newConfiguredServer(t, func(*config.ServerConfig) {})
+	c2, err := NewClient(config.CentralConfig{URL: plainSrv.URL, EnrollToken: testEnroll,
+		CredentialsPath: filepath.Join(t.TempDir(), "creds.json"), EncryptTo: string(pubPEM)})
+	require.NoError(t, err)
+	_, err = c2.Upload(ctx, rep)
+	assert.ErrorContains(t, err, "report_key")
+
+	_, err = NewClient(config.CentralConfig{URL: srv.URL, EncryptTo: "not a key"})
+	assert.ErrorContains(t, err, "central.encrypt_to")
+	cfg.Server.ReportKey = filepath.Join(t.TempDir(), "missing.key")
+	_, err = New(cfg, store)
+	assert.ErrorContains(t, err, "report_key")
+}
+
+func TestRecoverPanics(t *testing.T) {
+	h := recoverPanics(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("boom") }))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"error":"internal error"}`, w.Body.String())
+}
+
+// FuzzDecodeReport feeds the upload parser arbitrary bodies: it must
+// never panic, and a report it accepts must survive the round trip to
+// storage.
+func FuzzDecodeReport(f *testing.F) {
+	for _, seed := range []string{
+		minimalReport,
+		`{"schema_version":1,"generated_at":"2026-03-01T12:00:00Z","hostname":"web-1","violations":[{"category":"port","severity":"high","risk":7.5}]}`,
+		`{"generated_at":"2026-03-01T12:00:00Z","hostname":"web-1","users":[{"username":"root","uid":"0"}],"errors":[{"stage":"collect","code":"timeout"}]}`,
+		`{"generated_at":"not a time","hostname":1}`,
+		`[[[[{}]]]]`,
+		`"\ud800"`,
+		``,
+	} {
+		f.Add([]byte(seed), false)
+		f.Add([]byte(seed), true)
+	}
+	f.Fuzz(func(t *testing.T, b []byte, strict bool) {
+		rep, err := decodeReport(b, strict)
+		if err != nil {
+			return
+		}
+		out, err := json.Marshal(rep)
+		require.NoError(t, err)
+		again, err := decodeReport(out, true)
+		require.NoError(t, err, "%s", out)
+		assert.Equal(t, rep.Hostname, again.Hostname)
+	})
+}
@@ -0,0 +1,106 @@
+//go:build !no_server && !slim
+
+package server
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"compliance-agent/metrics"
+	"compliance-agent/storage"
+)
+
+// ingestStats counts uploads since the server started.
+type ingestStats struct {
+	received atomic.Uint64
+	mu       sync.Mutex
+	refused  map[string]uint64
+}
+
+func (st *ingestStats) rejected(reason string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.refused == nil {
+		st.refused = map[string]uint64{}
+	}
+	st.refused[reason]++
+}
+
+// metricsOnly lets the metrics token, or the admin token, through.
+func (s *Server) metricsOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := bearer(r)
+		if !tokenEqual(token, s.adminToken) && !tokenEqual(token, s.metricsToken) {
+			writeError(w, http.StatusUnauthorized, "metrics or admin token required")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// metrics serves the fleet's compliance for Prometheus: per host, the
+// counts from its newest report and when it last reported, and the
+// server's upload counters.
+func (s *Server) metrics(w http.ResponseWriter, _ *http.Request) {
+	agents, err := s.store.Agents()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", metrics.ContentType)
+	mw := metrics.NewWriter(w)
+
+	mw.Family(metrics.Hosts)
+	mw.Sample(metrics.Hosts, float64(len(agents)))
+	mw.Family(metrics.HostInfo)
+	for _, a := range agents {
+		mw.Sample(metrics.HostInfo, 1, "host_id", a.ID, "hostname", a.Hostname, "platform", a.Platform, "agent_version", a.AgentVersion)
+	}
+	mw.Family(metrics.LastSeen)
+	for _, a := range agents {
+		mw.Sample(metrics.LastSeen, unixSeconds(a.LastSeen.UnixNano()), "host_id", a.ID, "hostname", a.Hostname, "platform", a.Platform)
+	}
+	// A severity with no violations reads 0, so a clean host is
+	// distinguishable from one that never reported.
+	mw.Family(metrics.Violations)
+	for _, a := range agents {
+		if a.Latest == nil {
+			continue
+		}
+		for _, sev := range metrics.Severities {
+			n := a.Latest.BySeverity[sev]
+			if sev == "medium" {
+				n += a.Latest.BySeverity[""] // violations from before severities
+			}
+			mw.Sample(metrics.Violations, float64(n), "host_id", a.ID, "hostname", a.Hostname, "platform", a.Platform, "severity", sev)
+		}
+	}
+	for _, m := range []struct {
+		f     metrics.Family
+		value func(*storage.Run) float64
+	}{
+		{metrics.Errors, func(r *storage.Run) float64 { return float64(r.Errors) }},
+		{metrics.MaxRisk, func(r *storage.Run) float64 { return r.MaxRisk }},
+		{metrics.LastReport, func(r *storage.Run) float64 { return unixSeconds(r.GeneratedAt.UnixNano()) }},
+	} {
+		mw.Family(m.f)
+		for _, a := range agents {
+			if a.Latest != nil {
+				mw.Sample(m.f, m.value(a.Latest), "host_id", a.ID, "hostname", a.Hostname, "platform", a.Platform)
+			}
+		}
+	}
+
+	mw.Family(metrics.ReportsReceived)
+	mw.Sample(metrics.ReportsReceived, float64(s.ingest.received.Load()))
+	mw.Family(metrics.ReportsRejected)
+	s.ingest.mu.Lock()
+	for _, reason := range metrics.RejectReasons {
+		mw.Sample(metrics.ReportsRejected, float64(s.ingest.refused[reason]), "reason", reason)
+	}
+	s.ingest.mu.Unlock()
+	_ = mw.Flush()
+}
+
+func unixSeconds(ns int64) float64 { return float64(ns) / 1e9 }
@@ -0,0 +1,63 @@
+//go:build !no_server && !no_history && !slim
+
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"compliance-agent/config"
+	"compliance-agent/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, url, token string) (int, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url+"/metrics", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusOK {
+		assert.Equal(t, metrics.ContentType, resp.Header.Get("Content-Type"))
+	}
+	return resp.StatusCode, string(b)
+}
+
+func TestServer_Metrics(t *testing.T) {
+	srv, _, _ := newConfiguredServer(t, func(c *config.ServerConfig) { c.MetricsToken = "metrics-secret" })
+	c, _ := newTestClient(t, srv.URL, testEnroll)
+	_, err := c.Upload(context.Background(), testReport())
+	require.NoError(t, err)
+	idle, _ := newTestClient(t, srv.URL, testEnroll)
+	creds, err := idle.Enroll(context.Background(), EnrollRequest{Hostname: "db-1", Platform: "linux"})
+	require.NoError(t, err)
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/v1/reports", strings.NewReader("{}"))
+	req.Header.Set("Authorization", "Bearer "+creds.Token)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	status, _ := scrape(t, srv.URL, "wrong")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = scrape(t, srv.URL, testAdmin)
+	assert.Equal(t, http.StatusOK, status, "the admin token reads metrics too")
+	status, body := scrape(t, srv.URL, "metrics-secret")
+	require.Equal(t, http.StatusOK, status)
+
+	assert.Contains(t, body, "# TYPE compliance_violations gauge\n")
+	assert.Contains(t, body, "compliance_hosts 2\n")
+	assert.Regexp(t, `compliance_violations\{host_id="[0-9a-f]+",hostname="web-1",platform="linux",severity="critical"\} 1\n`, body)
+	assert.Regexp(t, `compliance_violations\{host_id="[0-9a-f]+",hostname="web-1",platform="linux",severity="high"\} 0\n`, body, "a clean severity reads 0")
+	assert.Regexp(t, `compliance_host_info\{host_id="[0-9a-f]+",hostname="db-1",platform="linux",agent_version=""\} 1\n`, body)
+	assert.NotContains(t, body, `hostname="db-1",platform="linux",severity=`, "no report, no report metrics")
+	assert.Regexp(t, `compliance_last_report_timestamp_seconds\{[^}]*hostname="web-1"[^}]*\} 1\.7723664e\+09\n`, body)
+	assert.Contains(t, body, "compliance_fleet_reports_received_total 1\n")
+	assert.Contains(t, body, `compliance_fleet_reports_rejected_total{reason="invalid"} 1`+"\n")
+	assert.Contains(t, body, `compliance_fleet_reports_rejected_total{reason="too_large"} 0`+"\n")
+}
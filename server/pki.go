@@ -0,0 +1,98 @@
+//go:build !no_server && !slim
+
+package server
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+)
+
+// issuer is the CA a mutual-TLS server signs agent client certificates
+// with. Each certificate's common name is the agent ID, which is how a
+// request's certificate is matched to its token.
+type issuer struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+	pool *x509.CertPool
+	ttl  time.Duration
+}
+
+// loadIssuer reads the CA certificate and key PEM files.
+func loadIssuer(certFile, keyFile string, ttl time.Duration) (*issuer, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("client_ca_cert: %w", err)
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("client_ca_key: %w", err)
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("client_ca_cert/client_ca_key: %w", err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("client_ca_cert: %w", err)
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("client_ca_cert %s is not a CA certificate", certFile)
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("client_ca_key: unsupported key type")
+	}
+	if ttl <= 0 {
+		ttl = 30 * 24 * time.Hour
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &issuer{cert: cert, key: key, pool: pool, ttl: ttl}, nil
+}
+
+// sign issues agentID a client certificate for the key in csrPEM.
+func (i *issuer) sign(agentID string, csrPEM []byte, now time.Time) ([]byte, error) {
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, errors.New("csr: want a PEM CERTIFICATE REQUEST")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("csr: %w", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("csr: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	notAfter := now.Add(i.ttl)
+	if notAfter.After(i.cert.NotAfter) {
+		notAfter = i.cert.NotAfter
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		// The CSR's subject is the agent's to choose; only the ID the
+		// server assigned goes in the certificate.
+		Subject:     pkix.Name{CommonName: agentID},
+		NotBefore:   now.Add(-5 * time.Minute), // clock skew
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, i.cert, csr.PublicKey, i.key)
+	if err != nil {
+		return nil, fmt.Errorf("sign client certificate: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
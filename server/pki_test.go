@@ -0,0 +1,258 @@
+//go:build !no_server && !no_history && !slim
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"compliance-agent/config"
+	"compliance-agent/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCA writes a self-signed CA (or, with isCA false, a leaf) and its
+// key to dir.
+func writeCA(t *testing.T, dir string, isCA bool) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fleet agents CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certFile, keyFile = filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+// newMTLSServer starts a mutual-TLS server and returns it with a file
+// holding its (httptest) server certificate for central.ca_file.
+func newMTLSServer(t *testing.T, ttl time.Duration) (*httptest.Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := storage.OpenFleet(filepath.Join(dir, "fleet.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	cfg := config.Default()
+	cfg.Server.EnrollTokens = []string{testEnroll}
+	cfg.Server.AdminToken = testAdmin
+	cfg.Server.ClientCACert, cfg.Server.ClientCAKey = writeCA(t, dir, true)
+	cfg.Server.ClientCertTTL = ttl
+	s, err := New(cfg, store)
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(s.Handler())
+	srv.TLS = s.TLSConfig()
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	serverCA := filepath.Join(dir, "server.crt")
+	require.NoError(t, os.WriteFile(serverCA, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o644))
+	return srv, serverCA
+}
+
+func readCreds(t *testing.T, path string) storedCredentials {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var stored storedCredentials
+	require.NoError(t, json.Unmarshal(b, &stored))
+	return stored
+}
+
+func leafOf(t *testing.T, certPEM string) *x509.Certificate {
+	t.Helper()
+	block, _ := pem.Decode([]byte(certPEM))
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestMTLS_EnrollUploadAndRequireCertificate(t *testing.T) {
+	srv, serverCA := newMTLSServer(t, 30*24*time.Hour)
+	creds := filepath.Join(t.TempDir(), "creds.json")
+	c, err := NewClient(config.CentralConfig{URL: srv.URL, EnrollToken: testEnroll, CredentialsPath: creds, CAFile: serverCA})
+	require.NoError(t, err)
+	_, err = c.Upload(context.Background(), testReport())
+	require.NoError(t, err)
+
+	stored := readCreds(t, creds)
+	require.NotEmpty(t, stored.Certificate)
+	require.NotEmpty(t, stored.Key)
+	leaf := leafOf(t, stored.Certificate)
+	assert.Equal(t, stored.AgentID, leaf.Subject.CommonName)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, leaf.ExtKeyUsage)
+
+	// The token alone is no longer enough.
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	bare := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	post := func(client *http.Client) int {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/v1/reports", bytes.NewReader([]byte(`{}`)))
+		req.Header.Set("Authorization", "Bearer "+stored.Token)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusUnauthorized, post(bare))
+
+	// Nor is another agent's certificate.
+	other := filepath.Join(t.TempDir(), "creds.json")
+	c2, err := NewClient(config.CentralConfig{URL: srv.URL, EnrollToken: testEnroll, CredentialsPath: other, CAFile: serverCA})
+	require.NoError(t, err)
+	_, err = c2.Upload(context.Background(), testReport())
+	require.NoError(t, err)
+	otherCreds := readCreds(t, other)
+	pair, err := tls.X509KeyPair([]byte(otherCreds.Certificate), []byte(otherCreds.Key))
+	require.NoError(t, err)
+	wrong := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{pair}}}}
+	assert.Equal(t, http.StatusUnauthorized, post(wrong))
+
+	// Enrolling without a CSR is refused.
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/v1/enroll", bytes.NewReader([]byte(`{"hostname":"x"}`)))
+	req.Header.Set("Authorization", "Bearer "+testEnroll)
+	resp, err := bare.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestMTLS_ReenrollsForCertificate(t *testing.T) {
+	// Credentials from before the server turned on mutual TLS hold no
+	// certificate; the 401 makes the agent enroll again for one.
+	srv, serverCA := newMTLSServer(t, 30*24*time.Hour)
+	creds := filepath.Join(t.TempDir(), "creds.json")
+	c, err := NewClient(config.CentralConfig{URL: srv.URL, EnrollToken: testEnroll, CredentialsPath: creds, CAFile: serverCA})
+	require.NoError(t, err)
+	require.NoError(t, c.save(Credentials{AgentID: "old", Token: "old-token"}, nil))
+
+	_, err = c.Upload(context.Background(), testReport())
+	require.NoError(t, err)
+	stored := readCreds(t, creds)
+	assert.NotEqual(t, "old", stored.AgentID)
+	assert.NotEmpty(t, stored.Certificate)
+}
+
+func TestMTLS_ReenrollWithCertificateKeepsAgentID(t *testing.T) {
+	srv, serverCA := newMTLSServer(t, 30*24*time.Hour)
+	creds := filepath.Join(t.TempDir(), "creds.json")
+	c, err := NewClient(config.CentralConfig{URL: srv.URL, EnrollToken: testEnroll, CredentialsPath: creds, CAFile: serverCA})
+	require.NoError(t, err)
+	first, err := c.Enroll(context.Background(), EnrollRequest{Hostname: "web-1", AgentUUID: "u1"})
+	require.NoError(t, err)
+
+	// The token is lost but the certificate, offered in the handshake,
+	// still names the agent.
+	c.mu.Lock()
+	c.creds = nil
+	c.mu.Unlock()
+	require.NoError(t, os.Remove(creds))
+	c.http.CloseIdleConnections()
+	again, err := c.Enroll(context.Background(), EnrollRequest{Hostname: "web-1", AgentUUID: "u1"})
+	require.NoError(t, err)
+	assert.Equal(t, first.AgentID, again.AgentID)
+	assert.NotEqual(t, first.Token, again.Token)
+}
+
+func TestMTLS_RenewsCertificate(t *testing.T) {
+	// An hour's certificate is backdated five minutes for clock skew;
+	// the client renews once two thirds of that have passed.
+	srv, serverCA := newMTLSServer(t, time.Hour)
+	creds := filepath.Join(t.TempDir(), "creds.json")
+	c, err := NewClient(config.CentralConfig{URL: srv.URL, EnrollToken: testEnroll, CredentialsPath: creds, CAFile: serverCA})
+	require.NoError(t, err)
+	_, err = c.Upload(context.Background(), testReport())
+	require.NoError(t, err)
+	first := readCreds(t, creds)
+
+	// Not due yet: the same certificate is used.
+	_, err = c.Upload(context.Background(), testReport())
+	require.NoError(t, err)
+	assert.Equal(t, first.Certificate, readCreds(t, creds).Certificate)
+
+	// Swap in a certificate that is due, as if 45 minutes had passed.
+	c.mu.Lock()
+	cert := *c.cert.Load()
+	leaf := *cert.Leaf
+	leaf.NotBefore = time.Now().Add(-45 * time.Minute)
+	leaf.NotAfter = time.Now().Add(20 * time.Minute)
+	cert.Leaf = &leaf
+	c.cert.Store(&cert)
+	c.mu.Unlock()
+
+	_, err = c.Upload(context.Background(), testReport())
+	require.NoError(t, err)
+	renewed := readCreds(t, creds)
+	assert.Equal(t, first.AgentID, renewed.AgentID)
+	assert.Equal(t, first.Token, renewed.Token)
+	assert.NotEqual(t, first.Certificate, renewed.Certificate)
+	assert.NotEqual(t, first.Key, renewed.Key, "renewal rotates the key too")
+	assert.Equal(t, first.AgentID, leafOf(t, renewed.Certificate).Subject.CommonName)
+
+	// The renewed certificate authenticates.
+	_, err = c.Upload(context.Background(), testReport())
+	require.NoError(t, err)
+}
+
+func TestClient_PinSHA256(t *testing.T) {
+	srv, serverCA := newMTLSServer(t, 30*24*time.Hour)
+	upload := func(pin string) error {
+		c, err := NewClient(config.CentralConfig{URL: srv.URL, EnrollToken: testEnroll,
+			CredentialsPath: filepath.Join(t.TempDir(), "creds.json"), CAFile: serverCA, PinSHA256: []string{pin}})
+		require.NoError(t, err)
+		_, err = c.Upload(context.Background(), testReport())
+		return err
+	}
+	assert.NoError(t, upload(spkiPin(srv.Certificate())))
+	assert.ErrorContains(t, upload("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="), "pin_sha256")
+
+	_, err := NewClient(config.CentralConfig{URL: srv.URL, PinSHA256: []string{"not-a-pin"}})
+	assert.ErrorContains(t, err, "pin_sha256")
+}
+
+func TestNew_ClientCA(t *testing.T) {
+	dir := t.TempDir()
+	caCert, caKey := writeCA(t, dir, true)
+	cfg := config.Config{Server: config.ServerConfig{EnrollTokens: []string{testEnroll}, AdminToken: testAdmin,
+		ClientCACert: caCert, ClientCAKey: caKey, InsecureHTTP: true}}
+	_, err := New(cfg, nil)
+	assert.ErrorContains(t, err, "insecure_http")
+
+	leafDir := t.TempDir()
+	cfg.Server.InsecureHTTP = false
+	cfg.Server.ClientCACert, cfg.Server.ClientCAKey = writeCA(t, leafDir, false)
+	_, err = New(cfg, nil)
+	assert.ErrorContains(t, err, "not a CA")
+
+	cfg.Server.ClientCAKey = caKey
+	_, err = New(cfg, nil)
+	assert.ErrorContains(t, err, "client_ca_cert/client_ca_key")
+}
@@ -0,0 +1,137 @@
+//go:build !no_server && !slim
+
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/policydist"
+)
+
+// policyFile is the policy the server hands out and its signature. Both
+// are stat'ed on each request and re-read when either changes, so an
+// edited policy reaches agents on their next scan without a restart.
+type policyFile struct {
+	path    string
+	sigPath string
+	// sigRequired is set when the signature file was configured rather
+	// than defaulted, so its absence is an error.
+	sigRequired bool
+
+	mu     sync.Mutex
+	stamp  string
+	policy []byte
+	sig    string // base64, "" when unsigned
+	etag   string
+}
+
+// loadPolicyFile reads the policy and its signature, by default path +
+// ".sig" when that exists. Unlike a reload, any problem is an error.
+func loadPolicyFile(path, sigPath string) (*policyFile, error) {
+	p := &policyFile{path: path, sigPath: sigPath, sigRequired: sigPath != ""}
+	if p.sigPath == "" {
+		p.sigPath = path + ".sig"
+	}
+	stamp, err := p.stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.load(stamp); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// stat fingerprints both files by size and modification time.
+func (p *policyFile) stat() (string, error) {
+	fi, err := os.Stat(p.path)
+	if err != nil {
+		return "", fmt.Errorf("policy_path: %w", err)
+	}
+	stamp := fmt.Sprintf("%d/%d", fi.Size(), fi.ModTime().UnixNano())
+	si, err := os.Stat(p.sigPath)
+	switch {
+	case err == nil:
+		stamp += fmt.Sprintf(" %d/%d", si.Size(), si.ModTime().UnixNano())
+	case errors.Is(err, fs.ErrNotExist) && !p.sigRequired:
+	default:
+		return "", fmt.Errorf("policy_signature: %w", err)
+	}
+	return stamp, nil
+}
+
+func (p *policyFile) load(stamp string) error {
+	b, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("policy_path: %w", err)
+	}
+	if _, err := analyzer.ParsePolicies(b); err != nil {
+		return fmt.Errorf("policy_path %s: %w", p.path, err)
+	}
+	var sig string
+	raw, err := os.ReadFile(p.sigPath)
+	switch {
+	case err == nil:
+		s, err := policydist.DecodeSignature(raw)
+		if err != nil {
+			return fmt.Errorf("policy_signature %s: %w", p.sigPath, err)
+		}
+		sig = base64.StdEncoding.EncodeToString(s)
+	case errors.Is(err, fs.ErrNotExist) && !p.sigRequired:
+	default:
+		return fmt.Errorf("policy_signature: %w", err)
+	}
+	sum := sha256.New()
+	sum.Write(b)
+	sum.Write([]byte(sig))
+	p.stamp, p.policy, p.sig = stamp, b, sig
+	p.etag = `"` + hex.EncodeToString(sum.Sum(nil)[:16]) + `"`
+	return nil
+}
+
+// current returns the policy in force, re-reading it first if the files
+// changed. A policy that no longer reads or parses is logged and the
+// previous one kept, so a half-saved edit never reaches the fleet.
+func (p *policyFile) current() (policy []byte, sig, etag string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stamp, err := p.stat()
+	if err == nil && stamp != p.stamp {
+		if err = p.load(stamp); err == nil {
+			logger().Info("reloaded the policy", "path", p.path)
+		}
+	}
+	if err != nil && stamp != p.stamp {
+		logger().Error("policy reload failed, keeping the current policy", "path", p.path, "err", err)
+		// Don't log the same problem on every request.
+		p.stamp = stamp
+	}
+	return p.policy, p.sig, p.etag
+}
+
+func (s *Server) getPolicy(w http.ResponseWriter, r *http.Request) {
+	if s.policy == nil {
+		writeError(w, http.StatusNotFound, "no policy configured on this server")
+		return
+	}
+	policy, sig, etag := s.policy.current()
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if sig != "" {
+		w.Header().Set("X-Policy-Signature", sig)
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(policy)
+}
@@ -0,0 +1,918 @@
+//go:build !no_server && !slim
+
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/buildinfo"
+	"compliance-agent/compare"
+	"compliance-agent/config"
+	"compliance-agent/hygiene"
+	"compliance-agent/logging"
+	"compliance-agent/reportcrypt"
+	"compliance-agent/storage"
+	"compliance-agent/summary"
+)
+
+func init() { buildinfo.Register("server") }
+
+// logger is the fleet server's log.
+func logger() *slog.Logger {
+	return logging.Component("fleet")
+}
+
+// HostDetail is the body of GET /api/v1/hosts/{id}.
+type HostDetail struct {
+	storage.Agent
+	Reports []storage.Run `json:"reports"`
+}
+
+// Finding is the body of GET /api/v1/hosts/{id}/findings/{fingerprint},
+// where alert links lead: a violation as of the host's newest report.
+type Finding struct {
+	AgentID     string `json:"agent_id"`
+	Hostname    string `json:"hostname"`
+	Fingerprint string `json:"fingerprint"`
+	// Open is whether the newest report still has the violation, which
+	// is then in Violation.
+	Open        bool                `json:"open"`
+	Violation   *analyzer.Violation `json:"violation,omitempty"`
+	ReportID    int64               `json:"report_id"`
+	GeneratedAt time.Time           `json:"generated_at"`
+}
+
+// Server serves the fleet API from a FleetStore.
+type Server struct {
+	store        *storage.FleetStore
+	enrollTokens []string
+	adminToken   string
+	metricsToken string      // also reads /metrics; "" for the admin token only
+	policy       *policyFile // nil without a policy
+	maxBytes     int64
+	strict       bool // reject unknown JSON members rather than drop them
+	summary      config.SummaryConfig
+	issuer       *issuer // nil unless mutual TLS
+	now          func() time.Time
+	ingest       ingestStats
+	reportKey    *ecdh.PrivateKey // decrypts encrypted uploads; nil refuses them
+}
+
+// New builds a server from cfg.Server over store, falling back to
+// COMPLIANCE_ENROLL_TOKEN and COMPLIANCE_ADMIN_TOKEN; cfg.Summary shapes
+// the fleet summary. The policy file and client CA, if any, are read and
+// checked now so a bad one fails at startup rather than on every agent.
+func New(full config.Config, store *storage.FleetStore) (*Server, error) {
+	cfg := full.Server
+	if _, err := summary.OptionsFor(full.Summary, time.Now()); err != nil {
+		return nil, err
+	}
+	s := &Server{
+		store:        store,
+		enrollTokens: cfg.EnrollTokens,
+		adminToken:   cfg.AdminToken,
+		metricsToken: cfg.MetricsToken,
+		maxBytes:     cfg.MaxReportBytes,
+		strict:       cfg.UnknownFields == unknownFieldsReject,
+		summary:      full.Summary,
+		now:          time.Now,
+	}
+	if len(s.enrollTokens) == 0 {
+		if t := os.Getenv("COMPLIANCE_ENROLL_TOKEN"); t != "" {
+			s.enrollTokens = []string{t}
+		}
+	}
+	if len(s.enrollTokens) == 0 {
+		return nil, errors.New("enroll_tokens not configured (or COMPLIANCE_ENROLL_TOKEN)")
+	}
+	for _, t := range s.enrollTokens {
+		if len(t) < 16 {
+			return nil, errors.New("enroll_tokens: each must be at least 16 characters")
+		}
+	}
+	if s.adminToken == "" {
+		s.adminToken = os.Getenv("COMPLIANCE_ADMIN_TOKEN")
+	}
+	if s.adminToken == "" {
+		return nil, errors.New("admin_token not configured (or COMPLIANCE_ADMIN_TOKEN)")
+	}
+	if s.maxBytes <= 0 {
+		s.maxBytes = 32 << 20
+	}
+	switch cfg.UnknownFields {
+	case "", unknownFieldsIgnore, unknownFieldsReject:
+	default:
+		return nil, fmt.Errorf("unknown_fields: want %q or %q, got %q", unknownFieldsIgnore, unknownFieldsReject, cfg.UnknownFields)
+	}
+	if cfg.ReportKey != "" {
+		var err error
+		if s.reportKey, err = reportcrypt.LoadPrivateKey(cfg.ReportKey); err != nil {
+			return nil, fmt.Errorf("report_key: %w", err)
+		}
+	}
+	if cfg.PolicyPath != "" {
+		var err error
+		if s.policy, err = loadPolicyFile(cfg.PolicyPath, cfg.PolicySignature); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ClientCACert != "" || cfg.ClientCAKey != "" {
+		if cfg.InsecureHTTP {
+			return nil, errors.New("client_ca_cert needs the server to terminate TLS itself, not insecure_http")
+		}
+		var err error
+		if s.issuer, err = loadIssuer(cfg.ClientCACert, cfg.ClientCAKey, cfg.ClientCertTTL); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// TLSConfig is the server's TLS configuration. Under mutual TLS it asks
+// for a client certificate from the client CA; one is optional at the
+// handshake, since enrolling agents have none yet, and agentOnly
+// requires it.
+func (s *Server) TLSConfig() *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if s.issuer != nil {
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		cfg.ClientCAs = s.issuer.pool
+	}
+	return cfg
+}
+
+// Handler routes the API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/enroll", s.enroll)
+	mux.HandleFunc("POST /api/v1/reports", s.agentOnly(s.upload))
+	mux.HandleFunc("GET /api/v1/policy", s.agentOnly(s.getPolicy))
+	mux.HandleFunc("POST /api/v1/certificate", s.agentOnly(s.renewCertificate))
+	mux.HandleFunc("GET /api/v1/hosts", s.adminOnly(s.hosts))
+	mux.HandleFunc("GET /api/v1/hosts/{id}", s.adminOnly(s.host))
+	mux.HandleFunc("POST /api/v1/hosts/{id}/reenroll", s.adminOnly(s.approveReenroll))
+	mux.HandleFunc("GET /api/v1/hosts/{id}/report", s.adminOnly(s.hostReport))
+	mux.HandleFunc("GET /api/v1/hosts/{id}/findings/{fingerprint}", s.adminOnly(s.finding))
+	mux.HandleFunc("GET /api/v1/reports/{id}", s.adminOnly(s.getReport))
+	mux.HandleFunc("GET /api/v1/summary", s.adminOnly(s.getSummary))
+	mux.HandleFunc("GET /api/v1/compare", s.adminOnly(s.compare))
+	mux.HandleFunc("GET /api/v1/rules", s.adminOnly(s.rules))
+	mux.HandleFunc("GET /metrics", s.metricsOnly(s.metrics))
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	return recoverPanics(mux)
+}
+
+// ListenAndServe serves cfg.Addr, over TLS unless cfg.InsecureHTTP, until
+// ctx is done, then shuts down gracefully. Report retention is applied
+// hourly.
+func (s *Server) ListenAndServe(ctx context.Context, cfg config.ServerConfig) error {
+	if !cfg.InsecureHTTP && (cfg.CertFile == "" || cfg.KeyFile == "") {
+		return errors.New("cert_file and key_file are required (or insecure_http behind a TLS proxy)")
+	}
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           s.Handler(),
+		TLSConfig:         s.TLSConfig(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+		WriteTimeout:      time.Minute,
+	}
+	go func() {
+		tick := time.NewTicker(time.Hour)
+		defer tick.Stop()
+		for {
+			if n, err := s.store.Prune(cfg.Retention, cfg.MaxReportsPerHost); err != nil {
+				logger().Error("prune failed", "err", err)
+			} else if n > 0 {
+				logger().Info("pruned reports", "reports", n)
+			}
+			if n, err := s.store.Anonymize(cfg.AnonymizeAfter); err != nil {
+				logger().Error("anonymize failed", "err", err)
+			} else if n > 0 {
+				logger().Info("anonymized reports", "reports", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+
+	var err error
+	if cfg.InsecureHTTP {
+		logger().Warn("fleet server listening without TLS", "addr", cfg.Addr)
+		err = srv.ListenAndServe()
+	} else {
+		logger().Info("fleet server listening", "addr", cfg.Addr)
+		err = srv.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+type agentKey struct{}
+
+// agentOnly authenticates an enrolled agent by its bearer token and,
+// under mutual TLS, its client certificate.
+func (s *Server) agentOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := bearer(r)
+		if token == "" {
+			writeError(w, http.StatusUnauthorized, "missing agent token")
+			return
+		}
+		agent, ok, err := s.store.AgentByToken(hashToken(token))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "unknown agent token")
+			return
+		}
+		if s.issuer != nil {
+			// The handshake verified the chain; this ties the
+			// certificate to the token's agent.
+			if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+				writeError(w, http.StatusUnauthorized, "client certificate required")
+				return
+			}
+			if r.TLS.PeerCertificates[0].Subject.CommonName != agent.ID {
+				writeError(w, http.StatusUnauthorized, "client certificate is not this agent's")
+				return
+			}
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), agentKey{}, agent)))
+	}
+}
+
+// adminOnly requires the admin token.
+func (s *Server) adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !tokenEqual(bearer(r), s.adminToken) {
+			writeError(w, http.StatusUnauthorized, "admin token required")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) enroll(w http.ResponseWriter, r *http.Request) {
+	token := bearer(r)
+	valid := false
+	for _, t := range s.enrollTokens {
+		// No early exit, so timing doesn't say which token came close.
+		valid = tokenEqual(token, t) || valid
+	}
+	if !valid {
+		writeError(w, http.StatusUnauthorized, "invalid enroll token")
+		return
+	}
+	var req EnrollRequest
+	if err := s.readJSON(w, r, 64<<10, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "enroll request: "+err.Error())
+		return
+	}
+	if req.Hostname == "" {
+		writeError(w, http.StatusBadRequest, "hostname is required")
+		return
+	}
+	// A host that enrolls again keeps its fleet ID and history if it
+	// proves it is the agent; otherwise it is a new agent linked to the
+	// one it claims to be.
+	prev, reenroll, err := s.previousAgent(r, req)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	id := prev.ID
+	if !reenroll {
+		if id, err = randomHex(16); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	creds := Credentials{AgentID: id, Token: secret}
+	if s.issuer != nil {
+		if req.CSR == "" {
+			writeError(w, http.StatusBadRequest, "csr is required: this server issues client certificates")
+			return
+		}
+		cert, err := s.issuer.sign(id, []byte(req.CSR), s.now())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		creds.Certificate = string(cert)
+	}
+	agent := storage.Agent{
+		ID:           id,
+		Hostname:     req.Hostname,
+		Platform:     req.Platform,
+		AgentVersion: req.AgentVersion,
+		EnrolledAt:   s.now(),
+		AgentUUID:    req.AgentUUID,
+		HardwareUUID: req.HardwareUUID,
+	}
+	if !reenroll {
+		claimed, known, err := s.store.AgentByIdentity(req.AgentUUID, req.HardwareUUID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if known {
+			agent.PreviousID = claimed.ID
+			logger().Warn("re-enrollment not proven, enrolled as a new agent", "agent_id", id, "hostname", req.Hostname,
+				"previous_agent_id", claimed.ID, "previous_hostname", claimed.Hostname)
+		}
+	}
+	if reenroll {
+		if err := s.store.Reenroll(agent, hashToken(secret)); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		logger().Info("agent re-enrolled", "agent_id", id, "hostname", req.Hostname, "previous_hostname", prev.Hostname)
+		writeJSON(w, http.StatusCreated, creds)
+		return
+	}
+	if err := s.store.Enroll(agent, hashToken(secret)); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	logger().Info("agent enrolled", "agent_id", id, "hostname", req.Hostname)
+	writeJSON(w, http.StatusCreated, creds)
+}
+
+// previousAgent finds the agent an enrolling host proves it was: the
+// holder of PreviousToken, the agent named by the client certificate the
+// request presented, or one with the host's identity that an admin
+// approved to re-enroll. ok is false for a host that proves none.
+func (s *Server) previousAgent(r *http.Request, req EnrollRequest) (a storage.Agent, ok bool, err error) {
+	if req.PreviousToken != "" {
+		if a, ok, err = s.store.AgentByToken(hashToken(req.PreviousToken)); ok || err != nil {
+			return a, ok, err
+		}
+	}
+	// The handshake verified the chain against the client CA.
+	if s.issuer != nil && r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		if a, ok, err = s.store.Agent(r.TLS.PeerCertificates[0].Subject.CommonName); ok || err != nil {
+			return a, ok, err
+		}
+	}
+	if a, ok, err = s.store.AgentByIdentity(req.AgentUUID, req.HardwareUUID); !ok || err != nil {
+		return a, false, err
+	}
+	return a, a.ReenrollApproved, nil
+}
+
+// approveReenroll lets the next host to enroll with the agent's identity
+// take over its ID, for a reinstalled host whose credentials are gone.
+func (s *Server) approveReenroll(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	ok, err := s.store.ApproveReenroll(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such host")
+		return
+	}
+	logger().Info("re-enrollment approved", "agent_id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// renewCertificate issues an agent a new client certificate before its
+// current one, which authenticated the request, expires.
+func (s *Server) renewCertificate(w http.ResponseWriter, r *http.Request) {
+	if s.issuer == nil {
+		writeError(w, http.StatusNotFound, "this server does not issue client certificates")
+		return
+	}
+	agent := r.Context().Value(agentKey{}).(storage.Agent)
+	var req CertificateRequest
+	if err := s.readJSON(w, r, 64<<10, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "certificate request: "+err.Error())
+		return
+	}
+	cert, err := s.issuer.sign(agent.ID, []byte(req.CSR), s.now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, CertificateResponse{Certificate: string(cert)})
+}
+
+func (s *Server) upload(w http.ResponseWriter, r *http.Request) {
+	agent := r.Context().Value(agentKey{}).(storage.Agent)
+	var body io.Reader = http.MaxBytesReader(w, r.Body, s.maxBytes)
+	gzipped := r.Header.Get("Content-Encoding") == "gzip"
+	// An encrypted upload is a gzipped report encrypted to report_key.
+	if r.Header.Get("Content-Type") == reportcrypt.ContentType {
+		if s.reportKey == nil {
+			s.ingest.rejected("invalid")
+			writeError(w, http.StatusUnsupportedMediaType, "encrypted report, but the server has no report_key")
+			return
+		}
+		enc, err := io.ReadAll(body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.ingest.rejected("too_large")
+			writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		var plain []byte
+		if err == nil {
+			plain, err = reportcrypt.Decrypt(s.reportKey, enc)
+		}
+		if err != nil {
+			s.ingest.rejected("invalid")
+			writeError(w, http.StatusBadRequest, "report: "+err.Error())
+			return
+		}
+		body, gzipped = bytes.NewReader(plain), true
+	}
+	if gzipped {
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			s.ingest.rejected("invalid")
+			writeError(w, http.StatusBadRequest, "gzip: "+err.Error())
+			return
+		}
+		defer zr.Close()
+		// Bound the inflated size too, against a gzip bomb.
+		body = io.LimitReader(zr, s.maxBytes+1)
+	}
+	b, err := io.ReadAll(body)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		s.ingest.rejected("too_large")
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	case err != nil:
+		s.ingest.rejected("invalid")
+		writeError(w, http.StatusBadRequest, "report: "+err.Error())
+		return
+	}
+	if int64(len(b)) > s.maxBytes {
+		s.ingest.rejected("too_large")
+		writeError(w, http.StatusRequestEntityTooLarge, "report exceeds max_report_bytes")
+		return
+	}
+	rep, err := decodeReport(b, s.strict)
+	if err != nil {
+		s.ingest.rejected("invalid")
+		writeError(w, http.StatusBadRequest, "report: "+err.Error())
+		return
+	}
+	id, err := s.store.SaveReport(agent.ID, rep)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.ingest.received.Add(1)
+	writeJSON(w, http.StatusCreated, UploadResponse{ID: id})
+}
+
+func (s *Server) hosts(w http.ResponseWriter, _ *http.Request) {
+	agents, err := s.store.Agents()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if agents == nil {
+		agents = []storage.Agent{}
+	}
+	writeJSON(w, http.StatusOK, agents)
+}
+
+func (s *Server) host(w http.ResponseWriter, r *http.Request) {
+	agent, ok, err := s.store.Agent(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such host")
+		return
+	}
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			writeError(w, http.StatusBadRequest, "limit: want a non-negative integer")
+			return
+		}
+	}
+	at, ok := queryTime(w, r, "at")
+	if !ok {
+		return
+	}
+	runs, err := s.store.AgentRunsUntil(agent.ID, at, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if runs == nil {
+		runs = []storage.Run{}
+	}
+	writeJSON(w, http.StatusOK, HostDetail{Agent: agent, Reports: runs})
+}
+
+// hostReport serves a host's newest full report or, with ?at= (RFC
+// 3339), the newest from at or before then: what the host looked like at
+// that time.
+func (s *Server) hostReport(w http.ResponseWriter, r *http.Request) {
+	at, ok := queryTime(w, r, "at")
+	if !ok {
+		return
+	}
+	agent, ok, err := s.store.Agent(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok || agent.Latest == nil {
+		writeError(w, http.StatusNotFound, "no report for this host")
+		return
+	}
+	id := agent.Latest.ID
+	if !at.IsZero() {
+		runs, err := s.store.AgentRunsUntil(agent.ID, at, 1)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if len(runs) == 0 {
+			writeError(w, http.StatusNotFound, "no report for this host from before "+at.Format(time.RFC3339))
+			return
+		}
+		id = runs[0].ID
+	}
+	s.writeReport(w, id)
+}
+
+// finding serves one of a host's violations, by fingerprint, from its
+// newest report; a fixed one is reported closed rather than not found,
+// since the alert that linked here is still out there.
+func (s *Server) finding(w http.ResponseWriter, r *http.Request) {
+	agent, ok, err := s.store.Agent(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok || agent.Latest == nil {
+		writeError(w, http.StatusNotFound, "no report for this host")
+		return
+	}
+	rep, _, ok, err := s.store.Report(agent.Latest.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "no report for this host")
+		return
+	}
+	f := Finding{
+		AgentID:     agent.ID,
+		Hostname:    rep.Hostname,
+		Fingerprint: r.PathValue("fingerprint"),
+		ReportID:    agent.Latest.ID,
+		GeneratedAt: rep.GeneratedAt,
+	}
+	for i, v := range rep.Violations {
+		if analyzer.Fingerprint(rep.Hostname, v) == f.Fingerprint {
+			f.Open, f.Violation = true, &rep.Violations[i]
+			break
+		}
+	}
+	writeJSON(w, http.StatusOK, f)
+}
+
+// queryTime parses the RFC 3339 query parameter name, zero when absent.
+// ok is false when it is malformed and an error has been written.
+func queryTime(w http.ResponseWriter, r *http.Request, name string) (t time.Time, ok bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return t, true
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, name+": want an RFC 3339 time")
+		return t, false
+	}
+	return t, true
+}
+
+func (s *Server) getReport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "report id: want an integer")
+		return
+	}
+	s.writeReport(w, id)
+}
+
+func (s *Server) writeReport(w http.ResponseWriter, id int64) {
+	rep, _, ok, err := s.store.Report(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such report")
+		return
+	}
+	writeJSON(w, http.StatusOK, rep)
+}
+
+// getSummary serves the fleet's executive summary, by default for the
+// configured period up to now; ?period= and ?to= (RFC 3339) override
+// them and ?format= picks json (the default), markdown or html.
+func (s *Server) getSummary(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	cfg := s.summary
+	if v := q.Get("period"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, "period: want a positive duration such as 168h")
+			return
+		}
+		cfg.Period = d
+	}
+	to := s.now()
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "to: want an RFC 3339 time")
+			return
+		}
+		to = t
+	}
+	opts, err := summary.OptionsFor(cfg, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sum, err := summary.ForFleet(s.store, opts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var body []byte
+	switch q.Get("format") {
+	case "", "json":
+		writeJSON(w, http.StatusOK, sum)
+		return
+	case "markdown":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		body, err = sum.Markdown()
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		body, err = sum.HTML()
+	default:
+		writeError(w, http.StatusBadRequest, "format: want json, markdown or html")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	_, _ = w.Write(body)
+}
+
+// rules measures the fleet policy's checks over every host's newest
+// report: those failing on at least ?noisy= of hosts (default 0.9), those
+// never failing and the ?top= most often not applicable.
+func (s *Server) rules(w http.ResponseWriter, r *http.Request) {
+	if s.policy == nil {
+		writeError(w, http.StatusNotFound, "the server hands out no policy (server.policy_path) to measure")
+		return
+	}
+	q := r.URL.Query()
+	var opts hygiene.Options
+	if v := q.Get("noisy"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f > 1 {
+			writeError(w, http.StatusBadRequest, "noisy: want a share of hosts such as 0.9")
+			return
+		}
+		opts.NoisyShare = f
+	}
+	if v := q.Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "top: want a positive number")
+			return
+		}
+		opts.Top = n
+	}
+	b, _, _ := s.policy.current()
+	policies, err := analyzer.ParsePolicies(b)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	a, err := hygiene.ForFleet(s.store, policies, opts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	switch q.Get("format") {
+	case "", "json":
+		writeJSON(w, http.StatusOK, a)
+	case "markdown":
+		body, err := a.Markdown()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		_, _ = w.Write(body)
+	default:
+		writeError(w, http.StatusBadRequest, "format: want json or markdown")
+	}
+}
+
+// compare sets the newest reports of the ?host= hosts side by side. With
+// one host it compares it with the norm of its ?peer= hosts, by default
+// every other host on its platform.
+func (s *Server) compare(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	ids := q["host"]
+	if len(ids) == 0 {
+		writeError(w, http.StatusBadRequest, "host: name at least one host ID")
+		return
+	}
+	hosts := make([]compare.Input, 0, len(ids))
+	for _, id := range ids {
+		in, ok, err := s.latest(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !ok {
+			writeError(w, http.StatusNotFound, "no report for host "+id)
+			return
+		}
+		hosts = append(hosts, in)
+	}
+
+	var cmp compare.Comparison
+	var err error
+	if len(hosts) > 1 {
+		cmp, err = compare.Compare(hosts)
+	} else {
+		var peers []compare.Input
+		if peers, err = s.peers(hosts[0], q["peer"]); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if len(peers) == 0 {
+			writeError(w, http.StatusBadRequest, "no peer has a report to compare with; name a second host or ?peer=")
+			return
+		}
+		cmp, err = compare.AgainstNorm(hosts[0], peers)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	switch q.Get("format") {
+	case "", "json":
+		writeJSON(w, http.StatusOK, cmp)
+	case "markdown":
+		body, err := cmp.Markdown()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		_, _ = w.Write(body)
+	default:
+		writeError(w, http.StatusBadRequest, "format: want json or markdown")
+	}
+}
+
+// latest loads a host's newest report. ok is false for an unknown host
+// or one that hasn't reported.
+func (s *Server) latest(id string) (compare.Input, bool, error) {
+	agent, ok, err := s.store.Agent(id)
+	if err != nil || !ok || agent.Latest == nil {
+		return compare.Input{}, false, err
+	}
+	rep, _, ok, err := s.store.Report(agent.Latest.ID)
+	return compare.Input{ID: id, Report: rep}, ok, err
+}
+
+// peers loads the newest reports of the hosts named, or when none are,
+// of every other host on the target's platform. Hosts with no report
+// are skipped.
+func (s *Server) peers(target compare.Input, ids []string) ([]compare.Input, error) {
+	if len(ids) == 0 {
+		agents, err := s.store.Agents()
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range agents {
+			if a.ID != target.ID && a.Latest != nil && a.Platform == target.Report.Platform {
+				ids = append(ids, a.ID)
+			}
+		}
+	}
+	var peers []compare.Input
+	for _, id := range ids {
+		if id == target.ID {
+			continue
+		}
+		in, ok, err := s.latest(id)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			peers = append(peers, in)
+		}
+	}
+	return peers, nil
+}
+
+func bearer(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
+
+// tokenEqual compares in constant time; an empty token matches nothing.
+func tokenEqual(got, want string) bool {
+	return got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// readJSON decodes a request body of at most limit bytes into v.
+func (s *Server) readJSON(w http.ResponseWriter, r *http.Request, limit int64, v any) error {
+	b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		return err
+	}
+	return decodeJSON(b, v, s.strict)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,533 @@
+//go:build !no_server && !no_history && !slim
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"compliance-agent/alerting"
+	"compliance-agent/analyzer"
+	"compliance-agent/collector"
+	"compliance-agent/compare"
+	"compliance-agent/config"
+	"compliance-agent/hygiene"
+	"compliance-agent/policydist"
+	"compliance-agent/report"
+	"compliance-agent/storage"
+	"compliance-agent/summary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testEnroll = "enroll-0123456789abcdef"
+	testAdmin  = "admin-secret"
+)
+
+func newTestServer(t *testing.T, policy string) (*httptest.Server, *storage.FleetStore) {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := storage.OpenFleet(filepath.Join(dir, "fleet.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	cfg := config.Default()
+	cfg.Server.EnrollTokens = []string{testEnroll}
+	cfg.Server.AdminToken = testAdmin
+	if policy != "" {
+		cfg.Server.PolicyPath = filepath.Join(dir, "policy.yaml")
+		require.NoError(t, os.WriteFile(cfg.Server.PolicyPath, []byte(policy), 0o644))
+	}
+	s, err := New(cfg, store)
+	require.NoError(t, err)
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+	return srv, store
+}
+
+func newTestClient(t *testing.T, url, enrollToken string) (*Client, string) {
+	t.Helper()
+	creds := filepath.Join(t.TempDir(), "creds.json")
+	c, err := NewClient(config.CentralConfig{URL: url, EnrollToken: enrollToken, CredentialsPath: creds})
+	require.NoError(t, err)
+	return c, creds
+}
+
+func adminGet(t *testing.T, url string, v any) int {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Authorization", "Bearer "+testAdmin)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if v != nil && resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+	}
+	return resp.StatusCode
+}
+
+func testReport() report.ComplianceReport {
+	return report.ComplianceReport{
+		GeneratedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Hostname:    "web-1",
+		Platform:    "linux",
+		Agent:       &report.AgentInfo{Version: "1.4.0"},
+		Violations: []analyzer.Violation{
+			{Category: "user", Severity: analyzer.SeverityCritical, Message: "unexpected user present: eve"},
+		},
+	}
+}
+
+func TestServer_EnrollUploadAndList(t *testing.T) {
+	srv, _ := newTestServer(t, "")
+	c, credsPath := newTestClient(t, srv.URL, testEnroll)
+
+	id, err := c.Upload(context.Background(), testReport())
+	require.NoError(t, err)
+	assert.NotZero(t, id)
+
+	info, err := os.Stat(credsPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	var hosts []storage.Agent
+	require.Equal(t, http.StatusOK, adminGet(t, srv.URL+"/api/v1/hosts", &hosts))
+	require.Len(t, hosts, 1)
+	assert.Equal(t, "web-1", hosts[0].Hostname)
+	assert.Equal(t, "1.4.0", hosts[0].AgentVersion)
+	require.NotNil(t, hosts[0].Latest)
+	assert.Equal(t, map[string]int{"critical": 1}, hosts[0].Latest.BySeverity)
+
+	var detail HostDetail
+	require.Equal(t, http.StatusOK, adminGet(t, srv.URL+"/api/v1/hosts/"+hosts[0].ID, &detail))
+	assert.Len(t, detail.Reports, 1)
+
+	var rep report.ComplianceReport
+	require.Equal(t, http.StatusOK, adminGet(t, srv.URL+"/api/v1/hosts/"+hosts[0].ID+"/report", &rep))
+	assert.Equal(t, testReport().Violations, rep.Violations)
+	require.Equal(t, http.StatusOK, adminGet(t, srv.URL+"/api/v1/reports/"+strconv.FormatInt(hosts[0].Latest.ID, 10), &rep))
+
+	assert.Equal(t, http.StatusNotFound, adminGet(t, srv.URL+"/api/v1/hosts/nope", nil))
+	assert.Equal(t, http.StatusNotFound, adminGet(t, srv.URL+"/api/v1/reports/999", nil))
+
+	// A second client with the same credentials file doesn't re-enroll.
+	c2, err := NewClient(config.CentralConfig{URL: srv.URL, CredentialsPath: credsPath})
+	require.NoError(t, err)
+	_, err = c2.Upload(context.Background(), testReport())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, adminGet(t, srv.URL+"/api/v1/hosts", &hosts))
+	assert.Len(t, hosts, 1)
+}
+
+func TestServer_HostReportAt(t *testing.T) {
+	srv, _ := newTestServer(t, "")
+	c, _ := newTestClient(t, srv.URL, testEnroll)
+	older := testReport()
+	older.GeneratedAt = older.GeneratedAt.Add(-7 * 24 * time.Hour)
+	older.Violations = nil
+	for _, rep := range []report.ComplianceReport{older, testReport()} {
+		_, err := c.Upload(context.Background(), rep)
+		require.NoError(t, err)
+	}
+	var hosts []storage.Agent
+	require.Equal(t, http.StatusOK, adminGet(t, srv.URL+"/api/v1/hosts", &hosts))
+	base := srv.URL + "/api/v1/hosts/" + hosts[0].ID
+
+	var rep report.ComplianceReport
+	require.Equal(t, http.StatusOK, adminGet(t, base+"/report?at=2026-02-25T00:00:00Z", &rep))
+	assert.Equal(t, older.GeneratedAt, rep.GeneratedAt, "the newest from before then")
+	assert.Empty(t, rep.Violations)
+	require.Equal(t, http.StatusOK, adminGet(t, base+"/report?at=2026-03-01T12:00:00Z", &rep))
+	assert.NotEmpty(t, rep.Violations, "at is inclusive")
+	assert.Equal(t, http.StatusNotFound, adminGet(t, base+"/report?at=2026-01-01T00:00:00Z", nil))
+	assert.Equal(t, http.StatusBadRequest, adminGet(t, base+"/report?at=last-tuesday", nil))
+
+	var detail HostDetail
+	require.Equal(t, http.StatusOK, adminGet(t, base+"?at=2026-02-25T00:00:00Z", &detail))
+	require.Len(t, detail.Reports, 1)
+	assert.Equal(t, older.GeneratedAt, detail.Reports[0].GeneratedAt)
+}
+
+func TestServer_Summary(t *testing.T) {
+	srv, _ := newTestServer(t, "")
+	c, _ := newTestClient(t, srv.URL, testEnroll)
+	_, err := c.Upload(context.Background(), testReport())
+	require.NoError(t, err)
+
+	var sum summary.Summary
+	require.Equal(t, http.StatusOK, adminGet(t, srv.URL+"/api/v1/summary?to=2026-03-02T00:00:00Z", &sum))
+	assert.Equal(t, "fleet", sum.Scope)
+	assert.Equal(t, 1, sum.Hosts)
+	assert.Equal(t, 83.3, sum.Score)
+	require.Len(t, sum.TopRules, 1)
+	assert.Equal(t, "user", sum.TopRules[0].Rule)
+
+	require.Equal(t, http.StatusOK, adminGet(t, srv.URL+"/api/v1/summary?to=2026-03-02T00:00:00Z&period=1h", &sum))
+	assert.Zero(t, sum.Hosts)
+	assert.Equal(t, http.StatusOK, adminGet(t, srv.URL+"/api/v1/summary?format=markdown", nil))
+	assert.Equal(t, http.StatusBadRequest, adminGet(t, srv.URL+"/api/v1/summary?format=pdf", nil))
+	assert.Equal(t, http.StatusBadRequest, adminGet(t, srv.URL+"/api/v1/summary?period=soon", nil))
+}
+
+func TestServer_Finding(t *testing.T) {
+	srv, _ := newTestServer(t, "")
+	c, creds := newTestClient(t, srv.URL, testEnroll)
+	_, err := c.Upload(context.Background(), testReport())
+	require.NoError(t, err)
+
+	// The links alerts carry lead here.
+	links, err := alerting.NewLinks(config.LinksConfig{BaseURL: srv.URL, CredentialsPath: creds})
+	require.NoError(t, err)
+	v := testReport().Violations[0]
+	var f Finding
+	require.Equal(t, http.StatusOK, adminGet(t, links.Finding("web-1", v).Finding, &f))
+	assert.True(t, f.Open)
+	assert.Equal(t, v.Message, f.Violation.Message)
+	assert.Equal(t, "web-1", f.Hostname)
+	var detail HostDetail
+	require.Equal(t, http.StatusOK, adminGet(t, links.Host("web-1"), &detail))
+
+	fixed := testReport()
+	fixed.GeneratedAt = fixed.GeneratedAt.Add(time.Hour)
+	fixed.Violations = nil
+	_, err = c.Upload(context.Background(), fixed)
+	require.NoError(t, err)
+	f = Finding{}
+	require.Equal(t, http.StatusOK, adminGet(t, links.Finding("web-1", v).Finding, &f))
+	assert.False(t, f.Open, "fixed since the alert")
+	assert.Nil(t, f.Violation)
+	assert.Equal(t, http.StatusNotFound, adminGet(t, srv.URL+"/api/v1/hosts/nope/findings/abc", nil))
+}
+
+func TestServer_Rules(t *testing.T) {
+	srv, _ := newTestServer(t, "allowed_users: [root]\nrequire_firewall_enabled: true\n")
+	c, _ := newTestClient(t, srv.URL, testEnroll)
+	_, err := c.Upload(context.Background(), testReport())
+	require.NoError(t, err)
+
+	var a hygiene.Analysis
+	require.Equal(t, http.StatusOK, adminGet(t, srv.URL+"/api/v1/rules", &a))
+	assert.Equal(t, 1, a.Hosts)
+	require.Len(t, a.Noisy, 1)
+	assert.Equal(t, "allowed_users", a.Noisy[0].Check)
+	require.Len(t, a.Silent, 2)
+	assert.Equal(t, "require_firewall_enabled", a.Silent[1].Check)
+
+	assert.Equal(t, http.StatusOK, adminGet(t, srv.URL+"/api/v1/rules?format=markdown&noisy=0.5&top=3", nil))
+	assert.Equal(t, http.StatusBadRequest, adminGet(t, srv.URL+"/api/v1/rules?noisy=90", nil))
+	assert.Equal(t, http.StatusBadRequest, adminGet(t, srv.URL+"/api/v1/rules?top=-1", nil))
+
+	noPolicy, _ := newTestServer(t, "")
+	assert.Equal(t, http.StatusNotFound, adminGet(t, noPolicy.URL+"/api/v1/rules", nil))
+}
+
+func TestServer_Auth(t *testing.T) {
+	srv, _ := newTestServer(t, "")
+
+	c, _ := newTestClient(t, srv.URL, "wrong-0123456789abcdef")
+	_, err := c.Upload(context.Background(), testReport())
+	assert.ErrorContains(t, err, "invalid enroll token")
+
+	c, _ = newTestClient(t, srv.URL, "")
+	_, err = c.Upload(context.Background(), testReport())
+	assert.ErrorContains(t, err, "COMPLIANCE_ENROLL_TOKEN")
+
+	for _, path := range []string{"/api/v1/hosts", "/api/v1/reports/1"} {
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, path)
+	}
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/v1/reports", strings.NewReader("{}"))
+	req.Header.Set("Authorization", "Bearer "+testAdmin)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "the admin token isn't an agent token")
+}
+
+func TestClient_ReenrollsWhenForgotten(t *testing.T) {
+	srv, _ := newTestServer(t, "")
+	c, credsPath := newTestClient(t, srv.URL, testEnroll)
+	require.NoError(t, os.WriteFile(credsPath,
+		[]byte(`{"server":"`+srv.URL+`","agent_id":"gone","token":"stale"}`), 0o600))
+
+	_, err := c.Upload(context.Background(), testReport())
+	require.NoError(t, err)
+	b, err := os.ReadFile(credsPath)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "stale")
+}
+
+func TestServer_Policy(t *testing.T) {
+	policy := "allowed_users: [root]\n"
+	srv, _ := newTestServer(t, policy)
+	c, _ := newTestClient(t, srv.URL, testEnroll)
+	req := EnrollRequest{Hostname: "web-1"}
+
+	got, sig, ok, err := c.Policy(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, policy, string(got))
+	assert.Nil(t, sig, "unsigned")
+	assert.NotEmpty(t, c.etag)
+
+	got, _, ok, err = c.Policy(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, ok, "304 served from cache")
+	assert.Equal(t, policy, string(got))
+
+	srv, _ = newTestServer(t, "")
+	c, _ = newTestClient(t, srv.URL, testEnroll)
+	_, _, ok, err = c.Policy(context.Background(), req)
+	require.NoError(t, err)
+	assert.False(t, ok, "no server policy")
+}
+
+func TestServer_PolicyReloadAndSignature(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.OpenFleet(filepath.Join(dir, "fleet.db"))
+	require.NoError(t, err)
+	defer store.Close()
+	privPEM, pubPEM, err := policydist.GenerateKey()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "k"), privPEM, 0o600))
+	priv, err := policydist.LoadPrivateKey(filepath.Join(dir, "k"))
+	require.NoError(t, err)
+	keys, err := policydist.ParsePublicKeys([]string{string(pubPEM)})
+	require.NoError(t, err)
+
+	cfg := config.Default()
+	cfg.Server.EnrollTokens = []string{testEnroll}
+	cfg.Server.AdminToken = testAdmin
+	cfg.Server.PolicyPath = filepath.Join(dir, "policy.yaml")
+	write := func(policy string, mtime time.Time) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(cfg.Server.PolicyPath, []byte(policy), 0o644))
+		require.NoError(t, os.WriteFile(cfg.Server.PolicyPath+".sig", []byte(policydist.Sign(priv, []byte(policy))), 0o644))
+		// Edits within the filesystem's timestamp granularity still show.
+		require.NoError(t, os.Chtimes(cfg.Server.PolicyPath, mtime, mtime))
+		require.NoError(t, os.Chtimes(cfg.Server.PolicyPath+".sig", mtime, mtime))
+	}
+	v1, v2 := "allowed_users: [root]\n", "allowed_users: [root, deploy]\n"
+	write(v1, time.Now().Add(-time.Hour))
+	s, err := New(cfg, store)
+	require.NoError(t, err)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+	c, _ := newTestClient(t, srv.URL, testEnroll)
+	req := EnrollRequest{Hostname: "web-1"}
+
+	got, sig, ok, err := c.Policy(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, v1, string(got))
+	assert.NoError(t, policydist.Verify(keys, got, sig))
+
+	write(v2, time.Now())
+	got, sig, _, err = c.Policy(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, v2, string(got), "reloaded without a restart")
+	assert.NoError(t, policydist.Verify(keys, got, sig))
+
+	require.NoError(t, os.WriteFile(cfg.Server.PolicyPath, []byte("allowed_users: {"), 0o644))
+	got, sig, _, err = c.Policy(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, v2, string(got), "a broken edit keeps the previous policy")
+	assert.NoError(t, policydist.Verify(keys, got, sig))
+
+	cfg.Server.PolicySignature = filepath.Join(dir, "missing.sig")
+	_, err = New(cfg, store)
+	assert.ErrorContains(t, err, "policy_signature")
+}
+
+func TestServer_RejectsBadReports(t *testing.T) {
+	srv, _ := newTestServer(t, "")
+	c, _ := newTestClient(t, srv.URL, testEnroll)
+	creds, err := c.Enroll(context.Background(), EnrollRequest{Hostname: "web-1"})
+	require.NoError(t, err)
+
+	for _, body := range []string{"not json", `{"hostname":"web-1"}`} {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/v1/reports", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+creds.Token)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+	}
+}
+
+func TestNew_Config(t *testing.T) {
+	t.Setenv("COMPLIANCE_ENROLL_TOKEN", "")
+	t.Setenv("COMPLIANCE_ADMIN_TOKEN", "")
+	_, err := New(config.Config{Server: config.ServerConfig{AdminToken: testAdmin}}, nil)
+	assert.ErrorContains(t, err, "COMPLIANCE_ENROLL_TOKEN")
+	_, err = New(config.Config{Server: config.ServerConfig{EnrollTokens: []string{"short"}, AdminToken: testAdmin}}, nil)
+	assert.ErrorContains(t, err, "at least 16")
+	_, err = New(config.Config{Server: config.ServerConfig{EnrollTokens: []string{testEnroll}}}, nil)
+	assert.ErrorContains(t, err, "COMPLIANCE_ADMIN_TOKEN")
+
+	bad := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(bad, []byte("allowed_users: {"), 0o644))
+	_, err = New(config.Config{Server: config.ServerConfig{EnrollTokens: []string{testEnroll}, AdminToken: testAdmin, PolicyPath: bad}}, nil)
+	assert.ErrorContains(t, err, "policy_path")
+
+	_, err = NewClient(config.CentralConfig{URL: "http://fleet.example.com"})
+	assert.ErrorContains(t, err, "must be https")
+}
+
+func adminPost(t *testing.T, url string) int {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, nil)
+	req.Header.Set("Authorization", "Bearer "+testAdmin)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestServer_ReenrollKeepsAgentID(t *testing.T) {
+	srv, _ := newTestServer(t, "")
+	rep := testReport()
+	rep.Identity = &report.Identity{AgentID: "5b0c2a6e-0000-4000-8000-000000000001", Hardware: &collector.HardwareInfo{UUID: "hw-1"}}
+	c, _ := newTestClient(t, srv.URL, testEnroll)
+	_, err := c.Upload(context.Background(), rep)
+	require.NoError(t, err)
+	id := c.creds.AgentID
+
+	// The server stopped taking the token: the old one proves the agent.
+	c.mu.Lock()
+	require.NoError(t, c.forgetLocked())
+	c.mu.Unlock()
+	_, err = c.Upload(context.Background(), rep)
+	require.NoError(t, err)
+	assert.Equal(t, id, c.creds.AgentID)
+
+	// Reinstalled: the credentials are gone but the identity is not, and
+	// an admin approved it.
+	assert.Equal(t, http.StatusNotFound, adminPost(t, srv.URL+"/api/v1/hosts/nope/reenroll"))
+	require.Equal(t, http.StatusNoContent, adminPost(t, srv.URL+"/api/v1/hosts/"+id+"/reenroll"))
+	c2, _ := newTestClient(t, srv.URL, testEnroll)
+	_, err = c2.Upload(context.Background(), rep)
+	require.NoError(t, err)
+	assert.Equal(t, id, c2.creds.AgentID)
+
+	// Re-imaged: a new agent ID on the same hardware.
+	require.Equal(t, http.StatusNoContent, adminPost(t, srv.URL+"/api/v1/hosts/"+id+"/reenroll"))
+	rep.Identity = &report.Identity{AgentID: "5b0c2a6e-0000-4000-8000-000000000002", Hardware: &collector.HardwareInfo{UUID: "hw-1"}}
+	c3, _ := newTestClient(t, srv.URL, testEnroll)
+	_, err = c3.Upload(context.Background(), rep)
+	require.NoError(t, err)
+	assert.Equal(t, id, c3.creds.AgentID)
+
+	var hosts []storage.Agent
+	require.Equal(t, http.StatusOK, adminGet(t, srv.URL+"/api/v1/hosts", &hosts))
+	require.Len(t, hosts, 1)
+	assert.Equal(t, rep.Identity.AgentID, hosts[0].AgentUUID)
+	assert.Equal(t, "hw-1", hosts[0].HardwareUUID)
+	assert.False(t, hosts[0].ReenrollApproved, "an approval is used once")
+	var detail HostDetail
+	require.Equal(t, http.StatusOK, adminGet(t, srv.URL+"/api/v1/hosts/"+id, &detail))
+	assert.Len(t, detail.Reports, 4)
+
+	// The replaced credentials no longer work: the old client enrolls
+	// again, and with a revoked token it is a new host.
+	_, err = c.Upload(context.Background(), testReport())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, adminGet(t, srv.URL+"/api/v1/hosts", &hosts))
+	assert.Len(t, hosts, 2, "a host with no identity can't be matched")
+}
+
+func TestServer_ReenrollNeedsProof(t *testing.T) {
+	// Agent and hardware UUIDs are in every report: the enroll token and
+	// a known UUID must not take over a host.
+	srv, _ := newTestServer(t, "")
+	rep := testReport()
+	rep.Identity = &report.Identity{AgentID: "5b0c2a6e-0000-4000-8000-000000000001", Hardware: &collector.HardwareInfo{UUID: "hw-1"}}
+	victim, _ := newTestClient(t, srv.URL, testEnroll)
+	_, err := victim.Upload(context.Background(), rep)
+	require.NoError(t, err)
+	id, token := victim.creds.AgentID, victim.creds.Token
+
+	for _, req := range []EnrollRequest{
+		{Hostname: "web-1", AgentUUID: rep.Identity.AgentID},
+		{Hostname: "web-1", HardwareUUID: "hw-1", PreviousToken: "guessed"},
+	} {
+		attacker, _ := newTestClient(t, srv.URL, testEnroll)
+		creds, err := attacker.Enroll(context.Background(), req)
+		require.NoError(t, err)
+		assert.NotEqual(t, id, creds.AgentID)
+		var detail HostDetail
+		require.Equal(t, http.StatusOK, adminGet(t, srv.URL+"/api/v1/hosts/"+creds.AgentID, &detail))
+		assert.NotEmpty(t, detail.PreviousID, "linked to the host it claimed")
+	}
+
+	// The real agent is untouched.
+	_, err = victim.Upload(context.Background(), rep)
+	require.NoError(t, err)
+	assert.Equal(t, id, victim.creds.AgentID)
+	assert.Equal(t, token, victim.creds.Token)
+}
+
+func TestServer_Compare(t *testing.T) {
+	srv, _ := newTestServer(t, "")
+	upload := func(hostname string, users ...string) {
+		rep := testReport()
+		rep.Hostname = hostname
+		rep.Violations = nil
+		for _, u := range users {
+			rep.Users = append(rep.Users, collector.User{Username: u, Shell: "/bin/sh"})
+			if u == "eve" {
+				rep.Violations = append(rep.Violations, analyzer.Violation{Category: "user", Severity: analyzer.SeverityCritical,
+					Message: "unexpected user present: eve"})
+			}
+		}
+		c, _ := newTestClient(t, srv.URL, testEnroll)
+		_, err := c.Upload(context.Background(), rep)
+		require.NoError(t, err)
+	}
+	upload("web-1", "root")
+	upload("web-2", "root")
+	upload("web-3", "root", "eve")
+
+	var hosts []storage.Agent
+	require.Equal(t, http.StatusOK, adminGet(t, srv.URL+"/api/v1/hosts", &hosts))
+	ids := map[string]string{}
+	for _, h := range hosts {
+		ids[h.Hostname] = h.ID
+	}
+
+	var cmp compare.Comparison
+	require.Equal(t, http.StatusOK, adminGet(t, srv.URL+"/api/v1/compare?host="+ids["web-1"]+"&host="+ids["web-3"], &cmp))
+	require.Len(t, cmp.Hosts, 2)
+	require.Len(t, cmp.Rules, 1)
+	assert.Equal(t, []string{"user/eve"}, cmp.Rules[0].Facts)
+
+	// One host against the norm of the others on its platform.
+	require.Equal(t, http.StatusOK, adminGet(t, srv.URL+"/api/v1/compare?host="+ids["web-3"], &cmp))
+	assert.Equal(t, 2, cmp.Hosts[1].Peers)
+	require.Len(t, cmp.Rules, 1)
+	assert.Equal(t, []string{compare.NormID}, cmp.Rules[0].Passing)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/compare?format=markdown&host="+ids["web-3"]+"&peer="+ids["web-1"], nil)
+	req.Header.Set("Authorization", "Bearer "+testAdmin)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "# web-3 against its peer")
+
+	assert.Equal(t, http.StatusNotFound, adminGet(t, srv.URL+"/api/v1/compare?host=nope", nil))
+	assert.Equal(t, http.StatusBadRequest, adminGet(t, srv.URL+"/api/v1/compare", nil))
+	assert.Equal(t, http.StatusBadRequest, adminGet(t, srv.URL+"/api/v1/compare?host="+ids["web-1"]+"&peer="+ids["web-1"], nil))
+}
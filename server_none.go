@@ -0,0 +1,10 @@
+//go:build no_server || slim
+
+package main
+
+import "errors"
+
+// cmdServer fails: the fleet server was left out of this build.
+func cmdServer([]string) {
+	fatal(errors.New("server: not compiled into this agent (built with no_server)"), "server")
+}
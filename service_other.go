@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"os/signal"
+	"syscall"
+)
+
+// signalContext is cancelled on SIGINT or SIGTERM, as systemd and
+// launchd stop the agent.
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+}
@@ -0,0 +1,52 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// serviceName is the service the MSI installs (packaging/windows).
+const serviceName = "compliance-agent"
+
+// signalContext is cancelled on Ctrl+C or, when the agent runs as a
+// Windows service, when the service control manager stops it. A service
+// that doesn't answer the SCM is killed after 30 seconds.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	if ok, err := svc.IsWindowsService(); err != nil || !ok {
+		return ctx, cancel
+	}
+	go func() {
+		if err := svc.Run(serviceName, serviceHandler(cancel)); err != nil {
+			slog.Error("service control manager", "err", err)
+		}
+		cancel()
+	}()
+	return ctx, cancel
+}
+
+// serviceHandler answers the SCM, cancelling the agent's context on a
+// stop or at shutdown.
+type serviceHandler context.CancelFunc
+
+// Execute implements svc.Handler.
+func (h serviceHandler) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for req := range requests {
+		switch req.Cmd {
+		case svc.Interrogate:
+			status <- req.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			status <- svc.Status{State: svc.StopPending}
+			h()
+			return false, 0
+		}
+	}
+	return false, 0
+}
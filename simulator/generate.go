@@ -0,0 +1,176 @@
+// Package simulator load-tests a report aggregation server with fake
+// agents. Each agent keeps a plausible host inventory that drifts a little
+// between scans, so the server sees the same mix of steady hosts, new
+// findings and resolved findings a real fleet produces.
+package simulator
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/collector"
+	"compliance-agent/report"
+)
+
+var platforms = []string{"linux", "linux", "linux", "darwin", "darwin", "windows"}
+
+var baseProcesses = map[string][]string{
+	"linux":   {"systemd", "sshd", "cron", "rsyslogd", "dbus-daemon", "containerd", "dockerd", "nginx", "postgres", "osqueryd"},
+	"darwin":  {"launchd", "WindowServer", "Finder", "Dock", "mds", "cfprefsd", "Safari", "Slack", "osqueryd"},
+	"windows": {"System", "svchost.exe", "lsass.exe", "explorer.exe", "winlogon.exe", "MsMpEng.exe", "OneDrive.exe", "osqueryd.exe"},
+}
+
+// suspectProcesses sometimes start on a host, producing process findings.
+var suspectProcesses = []string{"nc", "xmrig", "anydesk", "teamviewer", "mimikatz.exe"}
+
+var basePackages = map[string][]collector.Package{
+	"linux": {
+		{Name: "openssl", Version: "3.0.2-0ubuntu1.15", Source: "deb"},
+		{Name: "openssh-server", Version: "1:8.9p1-3ubuntu0.6", Source: "deb"},
+		{Name: "curl", Version: "7.81.0-1ubuntu1.15", Source: "deb"},
+		{Name: "sudo", Version: "1.9.9-1ubuntu2.4", Source: "deb"},
+		{Name: "bash", Version: "5.1-6ubuntu1", Source: "deb"},
+	},
+	"darwin": {
+		{Name: "Google Chrome", Version: "124.0.6367.91", Source: "apps"},
+		{Name: "Slack", Version: "4.37.101", Source: "apps"},
+		{Name: "zoom.us", Version: "5.17.11", Source: "apps"},
+		{Name: "openssl@3", Version: "3.3.0", Source: "homebrew"},
+	},
+	"windows": {
+		{Name: "Google Chrome", Version: "124.0.6367.91", Source: "programs"},
+		{Name: "7-Zip", Version: "23.01", Source: "programs"},
+		{Name: "Microsoft Edge", Version: "124.0.2478.67", Source: "programs"},
+		{Name: "Notepad++", Version: "8.6.5", Source: "programs"},
+	},
+}
+
+var basePorts = map[string][]int{
+	"linux":   {22, 80, 443, 5432, 9100},
+	"darwin":  {5000, 7000},
+	"windows": {135, 445, 3389},
+}
+
+// findingTemplates are the violations a simulated host can pick up, with
+// severities matching the analyzer defaults so severity-gated alerters
+// see a realistic spread.
+var findingTemplates = []analyzer.Violation{
+	{Category: "port", Severity: analyzer.SeverityMedium, Message: "Unauthorized open port detected: 8080"},
+	{Category: "port", Severity: analyzer.SeverityMedium, Message: "Unauthorized open port detected: 6379"},
+	{Category: "user", Severity: analyzer.SeverityHigh, Message: "Unauthorized user detected: contractor"},
+	{Category: "firewall", Severity: analyzer.SeverityHigh, Message: "Host firewall is disabled"},
+	{Category: "disk_encryption", Severity: analyzer.SeverityHigh, Message: "Volume / is not encrypted"},
+	{Category: "screen_lock", Severity: analyzer.SeverityMedium, User: "alice", Message: "Screen lock timeout exceeds 300s"},
+	{Category: "vulnerability", Severity: analyzer.SeverityMedium, Message: "curl 7.81.0 is affected by CVE-2023-38545"},
+	{Category: "vulnerability", Severity: analyzer.SeverityCritical, Message: "openssl 3.0.2 is affected by CVE-2022-3602"},
+	{Category: "ssh_protocol", Severity: analyzer.SeverityCritical, Message: "sshd accepts SSH protocol 1"},
+	{Category: "ssh_root_login", Severity: analyzer.SeverityHigh, Message: "sshd permits root login with a password"},
+	{Category: "certificate_expiry", Severity: analyzer.SeverityMedium, Message: "Certificate /etc/ssl/certs/app.pem expires in 9 days"},
+	{Category: "sharing", Severity: analyzer.SeverityHigh, Message: "File sharing (smb) is enabled"},
+	{Category: "kernel_version", Severity: analyzer.SeverityHigh, Message: "Kernel 5.4.0 is older than the required 5.15"},
+}
+
+// host is one simulated agent's persistent state.
+type host struct {
+	name     string
+	platform string
+	rng      *rand.Rand
+	users    []collector.User
+	procs    []collector.Process
+	ports    []int
+	packages []collector.Package
+	// findings are indexes into findingTemplates currently present.
+	findings map[int]bool
+}
+
+// newHost builds a host with a random starting inventory. Each host has
+// its own seeded source so a run is reproducible for a given seed.
+func newHost(id int, seed int64, violationRate float64) *host {
+	rng := rand.New(rand.NewSource(seed + int64(id)))
+	platform := platforms[rng.Intn(len(platforms))]
+	h := &host{
+		name:     fmt.Sprintf("sim-%s-%05d", platform, id),
+		platform: platform,
+		rng:      rng,
+		findings: map[int]bool{},
+	}
+	h.users = []collector.User{{Username: "root", UID: 0, GID: 0, Directory: "/root", Shell: "/bin/bash"}}
+	for i, n := 0, 1+rng.Intn(3); i < n; i++ {
+		name := fmt.Sprintf("user%d", rng.Intn(500))
+		h.users = append(h.users, collector.User{
+			Username: name, UID: 1000 + i, GID: 1000 + i,
+			Directory: "/home/" + name, Shell: "/bin/zsh",
+		})
+	}
+	for i, name := range baseProcesses[platform] {
+		h.procs = append(h.procs, collector.Process{PID: 100 + i*37 + rng.Intn(30), Name: name, UID: rng.Intn(2) * 1000})
+	}
+	h.ports = slices.Clone(basePorts[platform])
+	h.packages = slices.Clone(basePackages[platform])
+	for i := range findingTemplates {
+		if rng.Float64() < violationRate {
+			h.findings[i] = true
+		}
+	}
+	return h
+}
+
+// drift applies the small changes a real host shows between scans: a
+// process comes or goes, a finding appears or is remediated.
+func (h *host) drift(violationRate float64) {
+	if h.rng.Float64() < 0.2 {
+		name := suspectProcesses[h.rng.Intn(len(suspectProcesses))]
+		if i := slices.IndexFunc(h.procs, func(p collector.Process) bool { return p.Name == name }); i >= 0 {
+			h.procs = slices.Delete(h.procs, i, i+1)
+		} else {
+			h.procs = append(h.procs, collector.Process{PID: 2000 + h.rng.Intn(30000), Name: name, UID: 1000})
+		}
+	}
+	// Flip about one finding in ten scans, keeping the long-run rate
+	// near violationRate.
+	if h.rng.Float64() < 0.1 {
+		i := h.rng.Intn(len(findingTemplates))
+		if h.findings[i] {
+			delete(h.findings, i)
+		} else if h.rng.Float64() < violationRate*2 {
+			h.findings[i] = true
+		}
+	}
+}
+
+// report renders the host's current state as a compliance report.
+func (h *host) report(now time.Time) report.ComplianceReport {
+	rep := report.ComplianceReport{
+		SchemaVersion: report.SchemaVersion,
+		GeneratedAt:   now.UTC(),
+		Hostname:      h.name,
+		Platform:      h.platform,
+		Scope:         "system",
+		Users:         slices.Clone(h.users),
+		Processes:     slices.Clone(h.procs),
+		OpenPorts:     slices.Clone(h.ports),
+		Packages:      slices.Clone(h.packages),
+		Violations:    []analyzer.Violation{},
+		ExtraMetadata: map[string]interface{}{
+			"simulated": true,
+		},
+	}
+	for i, v := range findingTemplates {
+		if h.findings[i] {
+			rep.Violations = append(rep.Violations, v)
+		}
+	}
+	for _, p := range h.procs {
+		if slices.Contains(suspectProcesses, p.Name) {
+			rep.Violations = append(rep.Violations, analyzer.Violation{
+				Category: "process_blocked",
+				Severity: analyzer.SeverityHigh,
+				Message:  fmt.Sprintf("Blocked process running: %s (pid %d)", p.Name, p.PID),
+			})
+		}
+	}
+	return rep
+}
@@ -0,0 +1,181 @@
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Options configures a simulation run.
+type Options struct {
+	// URL receives each report as a JSON POST.
+	URL string
+	// Token, when set, is sent as a bearer token.
+	Token string
+	// Agents is the number of simulated hosts.
+	Agents int
+	// Interval is how often each agent reports. Agents start at random
+	// offsets within the first interval so they don't arrive in lockstep.
+	Interval time.Duration
+	// Duration bounds the run; zero runs until ctx is cancelled.
+	Duration time.Duration
+	// Seed makes the generated fleet reproducible.
+	Seed int64
+	// ViolationRate is the chance that each kind of finding is present
+	// on a host, 0..1.
+	ViolationRate float64
+	// Timeout bounds each POST.
+	Timeout time.Duration
+}
+
+// Stats summarizes a run.
+type Stats struct {
+	Sent      int
+	Failed    int
+	Bytes     int64
+	Elapsed   time.Duration
+	Latencies []time.Duration
+	// Statuses counts responses by HTTP status code; transport errors
+	// are counted under 0.
+	Statuses map[int]int
+}
+
+// Percentile returns the p-th (0..100) percentile POST latency.
+func (s Stats) Percentile(p float64) time.Duration {
+	if len(s.Latencies) == 0 {
+		return 0
+	}
+	l := slices.Clone(s.Latencies)
+	slices.Sort(l)
+	i := int(float64(len(l)-1) * p / 100)
+	return l[i]
+}
+
+// String is a one-paragraph summary for the terminal.
+func (s Stats) String() string {
+	rate := 0.0
+	if s.Elapsed > 0 {
+		rate = float64(s.Sent+s.Failed) / s.Elapsed.Seconds()
+	}
+	return fmt.Sprintf("%d sent, %d failed in %s (%.1f req/s, %.1f MiB)\nlatency p50 %s, p95 %s, p99 %s, max %s\nstatus %v",
+		s.Sent, s.Failed, s.Elapsed.Round(time.Millisecond), rate, float64(s.Bytes)/(1<<20),
+		s.Percentile(50), s.Percentile(95), s.Percentile(99), s.Percentile(100), s.Statuses)
+}
+
+// Run starts opts.Agents simulated agents posting to opts.URL and returns
+// once the duration has passed or ctx is cancelled. Delivery failures are
+// counted, not returned: the point is to see how the server copes.
+func Run(ctx context.Context, opts Options) (Stats, error) {
+	if opts.URL == "" {
+		return Stats{}, errors.New("no server URL")
+	}
+	if opts.Agents <= 0 {
+		return Stats{}, errors.New("agents must be positive")
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Minute
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	client := &http.Client{
+		Timeout: opts.Timeout,
+		// One connection per agent, as a real fleet would have.
+		Transport: &http.Transport{MaxIdleConnsPerHost: opts.Agents},
+	}
+	var mu sync.Mutex
+	stats := Stats{Statuses: map[int]int{}}
+	record := func(n int, status int, latency time.Duration, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		stats.Statuses[status]++
+		if err != nil {
+			stats.Failed++
+			return
+		}
+		stats.Sent++
+		stats.Bytes += int64(n)
+		stats.Latencies = append(stats.Latencies, latency)
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for id := 0; id < opts.Agents; id++ {
+		h := newHost(id, opts.Seed, opts.ViolationRate)
+		offset := time.Duration(h.rng.Int63n(int64(opts.Interval)))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runAgent(ctx, client, opts, h, offset, record)
+		}()
+	}
+	wg.Wait()
+	stats.Elapsed = time.Since(start)
+	return stats, nil
+}
+
+// runAgent reports for one host every interval until ctx ends.
+func runAgent(ctx context.Context, client *http.Client, opts Options, h *host, offset time.Duration,
+	record func(n, status int, latency time.Duration, err error)) {
+	timer := time.NewTimer(offset)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		h.drift(opts.ViolationRate)
+		body, err := json.Marshal(h.report(time.Now()))
+		if err != nil {
+			record(0, 0, 0, err)
+			continue
+		}
+		began := time.Now()
+		status, err := post(ctx, client, opts, body)
+		if ctx.Err() != nil {
+			// Cut off by the end of the run, not a server failure.
+			return
+		}
+		record(len(body), status, time.Since(began), err)
+		// Jitter each agent by up to a tenth of the interval, as real
+		// daemons drift.
+		jitter := time.Duration(rand.Int63n(int64(opts.Interval)/10 + 1))
+		timer.Reset(opts.Interval - opts.Interval/20 + jitter)
+	}
+}
+
+func post(ctx context.Context, client *http.Client, opts Options, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.Token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("server returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,84 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"compliance-agent/report"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHost_Reproducible(t *testing.T) {
+	now := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	a, b := newHost(7, 42, 0.3), newHost(7, 42, 0.3)
+	for i := 0; i < 20; i++ {
+		a.drift(0.3)
+		b.drift(0.3)
+	}
+	assert.Equal(t, a.report(now), b.report(now))
+	assert.NotEqual(t, a.name, newHost(8, 42, 0.3).name)
+}
+
+func TestHost_ViolationRate(t *testing.T) {
+	now := time.Now()
+	assert.Empty(t, newHost(1, 1, 0).report(now).Violations)
+	rep := newHost(1, 1, 1).report(now)
+	assert.Len(t, rep.Violations, len(findingTemplates))
+	for _, v := range rep.Violations {
+		assert.NotEmpty(t, v.Severity)
+	}
+}
+
+func TestRun_PostsReports(t *testing.T) {
+	var mu sync.Mutex
+	hosts := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		var rep report.ComplianceReport
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&rep)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		hosts[rep.Hostname]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	stats, err := Run(context.Background(), Options{
+		URL: srv.URL, Token: "secret", Agents: 5,
+		Interval: 20 * time.Millisecond, Duration: 150 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Zero(t, stats.Failed)
+	assert.Positive(t, stats.Sent)
+	assert.Equal(t, stats.Sent, stats.Statuses[http.StatusAccepted])
+	assert.Len(t, hosts, 5)
+	assert.LessOrEqual(t, stats.Percentile(50), stats.Percentile(100))
+}
+
+func TestRun_CountsFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	stats, err := Run(context.Background(), Options{
+		URL: srv.URL, Agents: 2, Interval: 20 * time.Millisecond, Duration: 100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Zero(t, stats.Sent)
+	assert.Positive(t, stats.Failed)
+	assert.Equal(t, stats.Failed, stats.Statuses[http.StatusServiceUnavailable])
+
+	_, err = Run(context.Background(), Options{URL: srv.URL})
+	assert.Error(t, err)
+}
@@ -0,0 +1,10 @@
+//go:build !no_sinks && !slim
+
+package sink
+
+import "compliance-agent/buildinfo"
+
+// backendsLinked reports whether the sink backends are in this build.
+const backendsLinked = true
+
+func init() { buildinfo.Register("sinks") }
@@ -0,0 +1,7 @@
+//go:build no_sinks || slim
+
+package sink
+
+// backendsLinked is false: Splunk, Elasticsearch, Datadog and the object
+// store sink, with the cloud clients behind them, are left out.
+const backendsLinked = false
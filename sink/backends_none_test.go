@@ -0,0 +1,15 @@
+//go:build no_sinks || slim
+
+package sink
+
+import (
+	"testing"
+
+	"compliance-agent/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuild_NotCompiledIn(t *testing.T) {
+	_, err := Build(config.SinkConfig{Enabled: []string{"splunk"}})
+	assert.ErrorContains(t, err, "no_sinks")
+}
@@ -0,0 +1,347 @@
+//go:build !no_sinks && !slim
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+	"compliance-agent/errcode"
+	"compliance-agent/report"
+)
+
+func init() {
+	Register("datadog", func(cfg config.SinkConfig) (Sink, error) {
+		return NewDatadogSink(cfg.Datadog)
+	})
+}
+
+// datadogLogBatch is the most entries the logs intake takes per request.
+const datadogLogBatch = 1000
+
+// DatadogSink sends violation counts to Datadog as metrics, so posture
+// graphs and monitors sit beside infrastructure ones, and each violation
+// as a log or an event.
+type DatadogSink struct {
+	apiURL     string
+	logsURL    string
+	apiKey     string
+	tags       []string
+	service    string
+	prefix     string
+	violations string
+	maxRetries int
+	backoff    time.Duration
+	client     *http.Client
+}
+
+// NewDatadogSink builds a sink from config, falling back to the DD_API_KEY
+// environment variable.
+func NewDatadogSink(cfg config.DatadogSinkConfig) (*DatadogSink, error) {
+	s := &DatadogSink{
+		apiURL:     cfg.APIURL,
+		logsURL:    cfg.LogsURL,
+		apiKey:     cfg.APIKey,
+		tags:       cfg.Tags,
+		service:    cfg.Service,
+		prefix:     cfg.MetricPrefix,
+		violations: cfg.Violations,
+		maxRetries: cfg.MaxRetries,
+		backoff:    cfg.RetryBackoff,
+	}
+	if s.apiKey == "" {
+		s.apiKey = os.Getenv("DD_API_KEY")
+	}
+	if s.apiKey == "" {
+		return nil, errors.New("api_key not configured (or DD_API_KEY)")
+	}
+	site := cfg.Site
+	if site == "" {
+		site = "datadoghq.com"
+	}
+	if s.apiURL == "" {
+		s.apiURL = "https://api." + site
+	}
+	if s.logsURL == "" {
+		s.logsURL = "https://http-intake.logs." + site
+	}
+	for _, u := range []*string{&s.apiURL, &s.logsURL} {
+		parsed, err := url.Parse(*u)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return nil, fmt.Errorf("%q is not an http(s) URL", *u)
+		}
+		*u = strings.TrimSuffix(*u, "/")
+	}
+	if s.service == "" {
+		s.service = "compliance-agent"
+	}
+	if s.prefix == "" {
+		s.prefix = "compliance"
+	}
+	switch s.violations {
+	case "":
+		s.violations = "logs"
+	case "logs", "events", "none":
+	default:
+		return nil, fmt.Errorf("violations: %q (want logs, events or none)", cfg.Violations)
+	}
+	if s.maxRetries < 0 {
+		return nil, errors.New("max_retries: must not be negative")
+	}
+	if s.maxRetries == 0 {
+		s.maxRetries = 3
+	}
+	if s.backoff <= 0 {
+		s.backoff = time.Second
+	}
+	var err error
+	if s.client, err = httpClient(cfg.Timeout, ""); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Name implements Sink.
+func (s *DatadogSink) Name() string { return "datadog" }
+
+func (s *DatadogSink) header() http.Header {
+	h := http.Header{}
+	h.Set("DD-API-KEY", s.apiKey)
+	h.Set("Content-Type", "application/json")
+	return h
+}
+
+// Test implements Sink with Datadog's key validation endpoint, which
+// sends nothing.
+func (s *DatadogSink) Test(ctx context.Context, _ string) error {
+	_, err := request(ctx, s.client, http.MethodGet, s.apiURL+"/api/v1/validate", s.header(), nil)
+	return err
+}
+
+// send posts body with retries.
+func (s *DatadogSink) send(ctx context.Context, url string, body any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	header := s.header()
+	return withRetry(ctx, s.maxRetries, s.backoff, func() error {
+		return post(ctx, s.client, url, header, b)
+	})
+}
+
+// Send implements Sink: the metrics first, then the violations.
+func (s *DatadogSink) Send(ctx context.Context, rep report.ComplianceReport) error {
+	var errs []error
+	if err := s.send(ctx, s.apiURL+"/api/v2/series", s.series(rep)); err != nil {
+		errs = append(errs, fmt.Errorf("metrics: %w", err))
+	}
+	events := violationEvents(rep)
+	switch s.violations {
+	case "logs":
+		for start := 0; start < len(events); start += datadogLogBatch {
+			batch := events[start:min(start+datadogLogBatch, len(events))]
+			logs := make([]datadogLog, 0, len(batch))
+			for _, v := range batch {
+				logs = append(logs, s.log(rep, v))
+			}
+			if err := s.send(ctx, s.logsURL+"/api/v2/logs", logs); err != nil {
+				errs = append(errs, fmt.Errorf("logs: %w", err))
+			}
+		}
+	case "events":
+		for _, v := range events {
+			if err := s.send(ctx, s.apiURL+"/api/v1/events", s.event(rep, v)); err != nil {
+				errs = append(errs, fmt.Errorf("event %s: %w", v.Fingerprint, err))
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// datadogSeries is a metrics API v2 request body.
+type datadogSeries struct {
+	Series []datadogMetric `json:"series"`
+}
+
+type datadogMetric struct {
+	Metric    string            `json:"metric"`
+	Type      int               `json:"type"` // 3: gauge
+	Points    []datadogPoint    `json:"points"`
+	Tags      []string          `json:"tags,omitempty"`
+	Resources []datadogResource `json:"resources"`
+}
+
+type datadogPoint struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+type datadogResource struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// series reports <prefix>.violations per category and severity, and
+// <prefix>.violations.total, which is 0 for a clean host so its graphs
+// don't read as missing data. A report with run errors also gets
+// <prefix>.errors per error code and stage.
+func (s *DatadogSink) series(rep report.ComplianceReport) datadogSeries {
+	type key struct {
+		category string
+		severity analyzer.Severity
+	}
+	counts := map[key]int{}
+	for _, v := range violationEvents(rep) {
+		counts[key{v.Category, v.Severity}]++
+	}
+	keys := make([]key, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].category != keys[j].category {
+			return keys[i].category < keys[j].category
+		}
+		return keys[i].severity < keys[j].severity
+	})
+
+	ts := rep.GeneratedAt.Unix()
+	host := []datadogResource{{Name: rep.Hostname, Type: "host"}}
+	base := append([]string{"platform:" + rep.Platform}, s.tags...)
+	out := datadogSeries{Series: []datadogMetric{{
+		Metric:    s.prefix + ".violations.total",
+		Type:      3,
+		Points:    []datadogPoint{{ts, float64(len(rep.Violations))}},
+		Tags:      base,
+		Resources: host,
+	}}}
+	for _, k := range keys {
+		out.Series = append(out.Series, datadogMetric{
+			Metric:    s.prefix + ".violations",
+			Type:      3,
+			Points:    []datadogPoint{{ts, float64(counts[k])}},
+			Tags:      append([]string{"category:" + k.category, "severity:" + string(k.severity)}, base...),
+			Resources: host,
+		})
+	}
+
+	errCounts := map[[2]string]int{}
+	for _, e := range rep.Errors {
+		code := e.Code
+		if code == "" {
+			code = string(errcode.Unknown)
+		}
+		errCounts[[2]string{code, e.Stage}]++
+	}
+	errKeys := make([][2]string, 0, len(errCounts))
+	for k := range errCounts {
+		errKeys = append(errKeys, k)
+	}
+	sort.Slice(errKeys, func(i, j int) bool {
+		if errKeys[i][0] != errKeys[j][0] {
+			return errKeys[i][0] < errKeys[j][0]
+		}
+		return errKeys[i][1] < errKeys[j][1]
+	})
+	for _, k := range errKeys {
+		out.Series = append(out.Series, datadogMetric{
+			Metric:    s.prefix + ".errors",
+			Type:      3,
+			Points:    []datadogPoint{{ts, float64(errCounts[k])}},
+			Tags:      append([]string{"code:" + k[0], "stage:" + k[1]}, base...),
+			Resources: host,
+		})
+	}
+	return out
+}
+
+// datadogLog is a logs intake entry. The violation lands under the
+// "compliance" attribute, for facets.
+type datadogLog struct {
+	DDSource   string         `json:"ddsource"`
+	DDTags     string         `json:"ddtags"`
+	Hostname   string         `json:"hostname"`
+	Service    string         `json:"service"`
+	Status     string         `json:"status"`
+	Message    string         `json:"message"`
+	Timestamp  int64          `json:"timestamp"` // ms
+	Compliance ViolationEvent `json:"compliance"`
+}
+
+func (s *DatadogSink) log(rep report.ComplianceReport, v ViolationEvent) datadogLog {
+	return datadogLog{
+		DDSource:   "compliance-agent",
+		DDTags:     strings.Join(s.violationTags(rep, v), ","),
+		Hostname:   rep.Hostname,
+		Service:    s.service,
+		Status:     datadogStatus(v.Severity),
+		Message:    v.Message,
+		Timestamp:  rep.GeneratedAt.UnixMilli(),
+		Compliance: v,
+	}
+}
+
+// datadogEvent is an events API v1 request body. Events with the same
+// aggregation key, the violation's fingerprint, roll up together.
+type datadogEvent struct {
+	Title          string   `json:"title"`
+	Text           string   `json:"text"`
+	Host           string   `json:"host"`
+	Tags           []string `json:"tags"`
+	AlertType      string   `json:"alert_type"`
+	AggregationKey string   `json:"aggregation_key"`
+	SourceTypeName string   `json:"source_type_name"`
+	DateHappened   int64    `json:"date_happened"`
+}
+
+func (s *DatadogSink) event(rep report.ComplianceReport, v ViolationEvent) datadogEvent {
+	alertType := datadogStatus(v.Severity)
+	if alertType == "critical" {
+		alertType = "error" // events have no critical
+	}
+	return datadogEvent{
+		Title:          fmt.Sprintf("Compliance violation on %s: %s", rep.Hostname, v.Category),
+		Text:           v.Message,
+		Host:           rep.Hostname,
+		Tags:           s.violationTags(rep, v),
+		AlertType:      alertType,
+		AggregationKey: v.Fingerprint,
+		SourceTypeName: "compliance-agent",
+		DateHappened:   rep.GeneratedAt.Unix(),
+	}
+}
+
+func (s *DatadogSink) violationTags(rep report.ComplianceReport, v ViolationEvent) []string {
+	tags := []string{"category:" + v.Category, "severity:" + string(v.Severity), "platform:" + rep.Platform}
+	if v.Control != "" {
+		tags = append(tags, "control:"+v.Control)
+	}
+	return append(tags, s.tags...)
+}
+
+// datadogStatus maps a severity onto Datadog's log statuses.
+func datadogStatus(sev analyzer.Severity) string {
+	switch sev {
+	case analyzer.SeverityCritical:
+		return "critical"
+	case analyzer.SeverityHigh:
+		return "error"
+	case analyzer.SeverityMedium:
+		return "warning"
+	}
+	return "info"
+}
@@ -0,0 +1,129 @@
+//go:build !no_sinks && !slim
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"compliance-agent/config"
+	"compliance-agent/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDatadog records request bodies by path.
+func fakeDatadog(t *testing.T) (map[string][]json.RawMessage, string) {
+	got := map[string][]json.RawMessage{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("DD-API-KEY") != "k3y" {
+			http.Error(w, `{"errors":["Forbidden"]}`, http.StatusForbidden)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		got[r.URL.Path] = append(got[r.URL.Path], b)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = io.WriteString(w, `{"valid":true}`)
+	}))
+	t.Cleanup(srv.Close)
+	return got, srv.URL
+}
+
+func TestDatadog_MetricsAndLogs(t *testing.T) {
+	got, url := fakeDatadog(t)
+	sinks, err := Build(config.SinkConfig{
+		Enabled: []string{"datadog"},
+		Datadog: config.DatadogSinkConfig{APIKey: "k3y", APIURL: url, LogsURL: url + "/", Tags: []string{"env:prod"}},
+	})
+	require.NoError(t, err)
+	rep := testReport()
+	rep.Platform = "linux"
+	require.NoError(t, sinks[0].Send(context.Background(), rep))
+
+	require.Len(t, got["/api/v2/series"], 1)
+	var series datadogSeries
+	require.NoError(t, json.Unmarshal(got["/api/v2/series"][0], &series))
+	require.Len(t, series.Series, 4)
+	total := series.Series[0]
+	assert.Equal(t, "compliance.violations.total", total.Metric)
+	assert.Equal(t, 3, total.Type)
+	assert.Equal(t, []datadogPoint{{1772366400, 3}}, total.Points)
+	assert.Equal(t, []string{"platform:linux", "env:prod"}, total.Tags)
+	assert.Equal(t, []datadogResource{{Name: "web-1", Type: "host"}}, total.Resources)
+	assert.Equal(t, "compliance.violations", series.Series[1].Metric)
+	assert.Equal(t, []string{"category:port", "severity:low", "platform:linux", "env:prod"}, series.Series[1].Tags)
+	assert.Equal(t, []string{"category:port", "severity:medium", "platform:linux", "env:prod"}, series.Series[2].Tags)
+	assert.Equal(t, []string{"category:user", "severity:critical", "platform:linux", "env:prod"}, series.Series[3].Tags)
+
+	rep.Errors = []report.RunError{
+		{Stage: "collect", Subsystem: "packages", Code: "timeout"},
+		{Stage: "collect", Subsystem: "users", Code: "timeout"},
+		{Stage: "collect", Subsystem: "sshd", Code: "permission-denied"},
+	}
+	series = (&DatadogSink{prefix: "compliance"}).series(rep)
+	require.Len(t, series.Series, 6)
+	assert.Equal(t, "compliance.errors", series.Series[4].Metric)
+	assert.Equal(t, []string{"code:permission-denied", "stage:collect", "platform:linux"}, series.Series[4].Tags)
+	assert.Equal(t, []datadogPoint{{1772366400, 2}}, series.Series[5].Points)
+	assert.Equal(t, []string{"code:timeout", "stage:collect", "platform:linux"}, series.Series[5].Tags)
+
+	require.Len(t, got["/api/v2/logs"], 1)
+	var logs []map[string]any
+	require.NoError(t, json.Unmarshal(got["/api/v2/logs"][0], &logs))
+	require.Len(t, logs, 3)
+	assert.Equal(t, "critical", logs[0]["status"])
+	assert.Equal(t, "web-1", logs[0]["hostname"])
+	assert.Equal(t, "compliance-agent", logs[0]["service"])
+	assert.Equal(t, "category:user,severity:critical,platform:linux,env:prod", logs[0]["ddtags"])
+	assert.Equal(t, "user", logs[0]["compliance"].(map[string]any)["category"])
+	assert.NotEmpty(t, logs[0]["compliance"].(map[string]any)["fingerprint"])
+	assert.Empty(t, got["/api/v1/events"])
+}
+
+func TestDatadog_Events(t *testing.T) {
+	got, url := fakeDatadog(t)
+	s, err := NewDatadogSink(config.DatadogSinkConfig{APIKey: "k3y", APIURL: url, LogsURL: url, Violations: "events"})
+	require.NoError(t, err)
+	require.NoError(t, s.Send(context.Background(), testReport()))
+
+	require.Len(t, got["/api/v1/events"], 3)
+	var ev datadogEvent
+	require.NoError(t, json.Unmarshal(got["/api/v1/events"][0], &ev))
+	assert.Equal(t, "Compliance violation on web-1: user", ev.Title)
+	assert.Equal(t, "error", ev.AlertType, "events have no critical")
+	assert.NotEmpty(t, ev.AggregationKey)
+	assert.Empty(t, got["/api/v2/logs"])
+	assert.Len(t, got["/api/v2/series"], 1)
+}
+
+func TestDatadog_Test(t *testing.T) {
+	got, url := fakeDatadog(t)
+	s, err := NewDatadogSink(config.DatadogSinkConfig{APIKey: "k3y", APIURL: url})
+	require.NoError(t, err)
+	require.NoError(t, s.Test(context.Background(), "web-1"))
+	assert.Contains(t, got, "/api/v1/validate")
+
+	s, err = NewDatadogSink(config.DatadogSinkConfig{APIKey: "wrong", APIURL: url})
+	require.NoError(t, err)
+	assert.ErrorContains(t, s.Test(context.Background(), "web-1"), "403")
+}
+
+func TestNewDatadogSink_Config(t *testing.T) {
+	t.Setenv("DD_API_KEY", "")
+	_, err := NewDatadogSink(config.DatadogSinkConfig{})
+	assert.ErrorContains(t, err, "DD_API_KEY")
+
+	t.Setenv("DD_API_KEY", "env")
+	s, err := NewDatadogSink(config.DatadogSinkConfig{Site: "datadoghq.eu"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.datadoghq.eu", s.apiURL)
+	assert.Equal(t, "https://http-intake.logs.datadoghq.eu", s.logsURL)
+	assert.Equal(t, "env", s.apiKey)
+
+	_, err = NewDatadogSink(config.DatadogSinkConfig{Violations: "traces"})
+	assert.ErrorContains(t, err, "violations")
+}
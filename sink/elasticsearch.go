@@ -0,0 +1,311 @@
+//go:build !no_sinks && !slim
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"compliance-agent/config"
+	"compliance-agent/report"
+)
+
+func init() {
+	Register("elasticsearch", func(cfg config.SinkConfig) (Sink, error) {
+		return NewElasticsearchSink(cfg.Elasticsearch)
+	})
+}
+
+// ElasticsearchSink indexes reports and violations into Elasticsearch or
+// OpenSearch through the bulk API. Indices are monthly, named
+// <prefix>-reports-YYYY.MM and <prefix>-violations-YYYY.MM after the
+// scan time, and the sink installs an index template for each pattern
+// so fields map the same way every month.
+type ElasticsearchSink struct {
+	baseURL      string
+	prefix       string
+	ilmPolicy    string
+	skipTemplate bool
+	header       http.Header
+	events       []string
+	batchSize    int
+	maxRetries   int
+	backoff      time.Duration
+	client       *http.Client
+
+	mu        sync.Mutex
+	templated bool // templates installed by this process
+}
+
+// NewElasticsearchSink builds a sink from config, falling back to the
+// ELASTICSEARCH_API_KEY and ELASTICSEARCH_PASSWORD environment variables.
+func NewElasticsearchSink(cfg config.ElasticsearchSinkConfig) (*ElasticsearchSink, error) {
+	s := &ElasticsearchSink{
+		prefix:       cfg.IndexPrefix,
+		ilmPolicy:    cfg.ILMPolicy,
+		skipTemplate: cfg.SkipTemplate,
+		header:       http.Header{},
+		events:       cfg.Events,
+		batchSize:    cfg.BatchSize,
+		maxRetries:   cfg.MaxRetries,
+		backoff:      cfg.RetryBackoff,
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("url: %q is not an http(s) URL", cfg.URL)
+	}
+	s.baseURL = strings.TrimSuffix(u.String(), "/")
+
+	apiKey := cfg.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("ELASTICSEARCH_API_KEY")
+	}
+	password := cfg.Password
+	if password == "" {
+		password = os.Getenv("ELASTICSEARCH_PASSWORD")
+	}
+	switch {
+	case apiKey != "":
+		s.header.Set("Authorization", "ApiKey "+apiKey)
+	case cfg.Username != "":
+		s.header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(cfg.Username+":"+password)))
+	}
+
+	if s.prefix == "" {
+		s.prefix = "compliance"
+	}
+	if s.prefix != strings.ToLower(s.prefix) || strings.ContainsAny(s.prefix, `\/*?"<>| ,#:`) {
+		return nil, fmt.Errorf("index_prefix: %q is not a valid index name (lowercase, no \\ / * ? \" < > | , # : or spaces)", s.prefix)
+	}
+	if len(s.events) == 0 {
+		s.events = []string{"report", "violations"}
+	}
+	for _, e := range s.events {
+		if e != "report" && e != "violations" {
+			return nil, fmt.Errorf("events: unknown event %q (want report or violations)", e)
+		}
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = 500
+	}
+	if s.maxRetries < 0 {
+		return nil, errors.New("max_retries: must not be negative")
+	}
+	if s.maxRetries == 0 {
+		s.maxRetries = 3
+	}
+	if s.backoff <= 0 {
+		s.backoff = time.Second
+	}
+	if s.client, err = httpClient(cfg.Timeout, cfg.CAFile); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Name implements Sink.
+func (s *ElasticsearchSink) Name() string { return "elasticsearch" }
+
+// Test implements Sink by installing the index templates, which needs
+// the same connection and credentials as indexing. With skip_template it
+// only fetches the cluster's info.
+func (s *ElasticsearchSink) Test(ctx context.Context, _ string) error {
+	if s.skipTemplate {
+		_, err := request(ctx, s.client, http.MethodGet, s.baseURL+"/", s.header, nil)
+		return err
+	}
+	return s.ensureTemplates(ctx)
+}
+
+// indexName is the monthly index for kind ("reports" or "violations").
+func (s *ElasticsearchSink) indexName(kind string, t time.Time) string {
+	return fmt.Sprintf("%s-%s-%s", s.prefix, kind, t.UTC().Format("2006.01"))
+}
+
+// bulkDoc is one document and its bulk action line.
+type bulkDoc struct {
+	index, id string
+	doc       any
+}
+
+// Send implements Sink. Documents carry IDs derived from the host, scan
+// time and fingerprint, so a retried batch overwrites rather than
+// duplicates what already made it in.
+func (s *ElasticsearchSink) Send(ctx context.Context, rep report.ComplianceReport) error {
+	if !s.skipTemplate {
+		if err := s.ensureTemplates(ctx); err != nil {
+			return fmt.Errorf("index template: %w", err)
+		}
+	}
+	scanID := rep.Hostname + "-" + rep.GeneratedAt.UTC().Format("20060102T150405.000Z")
+	var docs []bulkDoc
+	if slices.Contains(s.events, "report") {
+		docs = append(docs, bulkDoc{s.indexName("reports", rep.GeneratedAt), scanID, rep})
+	}
+	if slices.Contains(s.events, "violations") {
+		for _, v := range violationEvents(rep) {
+			docs = append(docs, bulkDoc{s.indexName("violations", rep.GeneratedAt), scanID + "-" + v.Fingerprint, v})
+		}
+	}
+	var errs []error
+	for start := 0; start < len(docs); start += s.batchSize {
+		batch := docs[start:min(start+s.batchSize, len(docs))]
+		var body bytes.Buffer
+		enc := json.NewEncoder(&body)
+		for _, d := range batch {
+			action := map[string]map[string]string{"index": {"_index": d.index, "_id": d.id}}
+			if err := enc.Encode(action); err != nil {
+				return err
+			}
+			if err := enc.Encode(d.doc); err != nil {
+				return err
+			}
+		}
+		err := withRetry(ctx, s.maxRetries, s.backoff, func() error {
+			return s.bulk(ctx, body.Bytes())
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("documents %d-%d: %w", start+1, start+len(batch), err))
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// bulkResponse is the part of a _bulk response that reports failures.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// bulk sends one _bulk request. The cluster answers 200 even when some
+// documents fail, so the items are checked: if any was rejected with
+// 429 the batch is worth retrying whole.
+func (s *ElasticsearchSink) bulk(ctx context.Context, body []byte) error {
+	header := s.header.Clone()
+	header.Set("Content-Type", "application/x-ndjson")
+	b, err := request(ctx, s.client, http.MethodPost, s.baseURL+"/_bulk", header, body)
+	if err != nil {
+		return err
+	}
+	var resp bulkResponse
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return fmt.Errorf("bulk response: %w", err)
+	}
+	if !resp.Errors {
+		return nil
+	}
+	failed, throttled := 0, false
+	var first string
+	for _, item := range resp.Items {
+		for _, r := range item {
+			if r.Error == nil {
+				continue
+			}
+			failed++
+			throttled = throttled || r.Status == http.StatusTooManyRequests
+			if first == "" {
+				first = fmt.Sprintf("%s: %s", r.Error.Type, r.Error.Reason)
+			}
+		}
+	}
+	err = fmt.Errorf("%d of %d documents rejected, first: %s", failed, len(resp.Items), first)
+	if throttled {
+		return temporary{err}
+	}
+	return err
+}
+
+// ensureTemplates installs the index templates once per process. A
+// failure is retried on the next call.
+func (s *ElasticsearchSink) ensureTemplates(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.templated {
+		return nil
+	}
+	for _, kind := range []string{"reports", "violations"} {
+		body, err := json.Marshal(s.indexTemplate(kind))
+		if err != nil {
+			return err
+		}
+		name := s.prefix + "-" + kind
+		header := s.header.Clone()
+		header.Set("Content-Type", "application/json")
+		err = withRetry(ctx, s.maxRetries, s.backoff, func() error {
+			_, err := request(ctx, s.client, http.MethodPut, s.baseURL+"/_index_template/"+name, header, body)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	s.templated = true
+	return nil
+}
+
+// indexTemplate is the composable index template for kind. Strings map
+// to keyword, so dashboards can aggregate on any of them; violation
+// messages are also full-text searchable. Reports carry a field per
+// collected attribute, hence the raised field limit.
+func (s *ElasticsearchSink) indexTemplate(kind string) map[string]any {
+	settings := map[string]any{"number_of_shards": 1}
+	if s.ilmPolicy != "" {
+		settings["index.lifecycle.name"] = s.ilmPolicy
+	}
+	keyword := map[string]any{"type": "keyword"}
+	properties := map[string]any{
+		"hostname":     keyword,
+		"generated_at": map[string]any{"type": "date"},
+	}
+	// Risk is explicit, or a first whole-number score would map it as long.
+	risk := map[string]any{"type": "float"}
+	if kind == "reports" {
+		settings["index.mapping.total_fields.limit"] = 5000
+		properties["violations"] = map[string]any{"properties": map[string]any{"risk": risk}}
+	} else {
+		for _, f := range []string{"fingerprint", "category", "severity", "control", "user"} {
+			properties[f] = keyword
+		}
+		properties["risk"] = risk
+		properties["message"] = map[string]any{
+			"type":   "text",
+			"fields": map[string]any{"keyword": map[string]any{"type": "keyword", "ignore_above": 1024}},
+		}
+	}
+	return map[string]any{
+		"index_patterns": []string{s.prefix + "-" + kind + "-*"},
+		"template": map[string]any{
+			"settings": settings,
+			"mappings": map[string]any{
+				"dynamic_templates": []any{map[string]any{
+					"strings": map[string]any{
+						"match_mapping_type": "string",
+						"mapping":            map[string]any{"type": "keyword", "ignore_above": 1024},
+					},
+				}},
+				"properties": properties,
+			},
+		},
+		"_meta": map[string]any{"managed_by": "compliance-agent"},
+	}
+}
@@ -0,0 +1,166 @@
+//go:build !no_sinks && !slim
+
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"compliance-agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeElasticsearch records templates and bulk documents. bulkReply, if
+// set, answers _bulk requests instead of success.
+type fakeElasticsearch struct {
+	mu        sync.Mutex
+	auth      []string
+	templates map[string]map[string]any
+	actions   []map[string]map[string]string
+	docs      []map[string]any
+	bulks     int
+	bulkReply func(n int) string
+}
+
+func (f *fakeElasticsearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	b, _ := io.ReadAll(r.Body)
+	switch {
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/_index_template/"):
+		var tmpl map[string]any
+		if err := json.Unmarshal(b, &tmpl); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.templates[strings.TrimPrefix(r.URL.Path, "/_index_template/")] = tmpl
+		_, _ = io.WriteString(w, `{"acknowledged":true}`)
+	case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+		if r.Header.Get("Content-Type") != "application/x-ndjson" {
+			http.Error(w, "bad content type", http.StatusNotAcceptable)
+			return
+		}
+		f.bulks++
+		if f.bulkReply != nil {
+			if reply := f.bulkReply(f.bulks); reply != "" {
+				_, _ = io.WriteString(w, reply)
+				return
+			}
+		}
+		sc := bufio.NewScanner(bytes.NewReader(b))
+		sc.Buffer(nil, 1<<20)
+		for sc.Scan() {
+			var action map[string]map[string]string
+			_ = json.Unmarshal(sc.Bytes(), &action)
+			sc.Scan()
+			var doc map[string]any
+			_ = json.Unmarshal(sc.Bytes(), &doc)
+			f.actions = append(f.actions, action)
+			f.docs = append(f.docs, doc)
+		}
+		_, _ = io.WriteString(w, `{"errors":false,"items":[]}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newFakeElasticsearch(t *testing.T) (*fakeElasticsearch, string) {
+	f := &fakeElasticsearch{templates: map[string]map[string]any{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv.URL
+}
+
+func TestElasticsearch_SendIndexesMonthly(t *testing.T) {
+	f, url := newFakeElasticsearch(t)
+	sinks, err := Build(config.SinkConfig{
+		Enabled:       []string{"elasticsearch"},
+		Elasticsearch: config.ElasticsearchSinkConfig{URL: url, APIKey: "k3y", BatchSize: 3, ILMPolicy: "compliance-90d"},
+	})
+	require.NoError(t, err)
+	s := sinks[0]
+	require.NoError(t, s.Send(context.Background(), testReport()))
+	require.NoError(t, s.Send(context.Background(), testReport()))
+
+	assert.Len(t, f.templates, 2, "installed once")
+	reports := f.templates["compliance-reports"]
+	assert.Equal(t, []any{"compliance-reports-*"}, reports["index_patterns"])
+	settings := reports["template"].(map[string]any)["settings"].(map[string]any)
+	assert.Equal(t, "compliance-90d", settings["index.lifecycle.name"])
+	props := f.templates["compliance-violations"]["template"].(map[string]any)["mappings"].(map[string]any)["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "keyword"}, props["severity"])
+	assert.Equal(t, map[string]any{"type": "date"}, props["generated_at"])
+
+	assert.Equal(t, 4, f.bulks, "four documents per scan in batches of three")
+	require.Len(t, f.docs, 8)
+	assert.Equal(t, map[string]string{"_index": "compliance-reports-2026.03", "_id": "web-1-20260301T120000.000Z"}, f.actions[0]["index"])
+	assert.Equal(t, "web-1", f.docs[0]["hostname"])
+	v := f.actions[2]["index"]
+	assert.Equal(t, "compliance-violations-2026.03", v["_index"])
+	assert.True(t, strings.HasPrefix(v["_id"], "web-1-20260301T120000.000Z-"))
+	assert.Equal(t, "medium", f.docs[2]["severity"])
+	assert.Equal(t, f.actions[2], f.actions[6], "a rescan of the same report overwrites")
+	for _, a := range f.auth {
+		assert.Equal(t, "ApiKey k3y", a)
+	}
+}
+
+func TestElasticsearch_BulkItemFailures(t *testing.T) {
+	f, url := newFakeElasticsearch(t)
+	f.bulkReply = func(n int) string {
+		if n == 1 {
+			return `{"errors":true,"items":[{"index":{"status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue full"}}}]}`
+		}
+		return ""
+	}
+	s, err := NewElasticsearchSink(config.ElasticsearchSinkConfig{URL: url, SkipTemplate: true, Events: []string{"report"}, RetryBackoff: time.Millisecond})
+	require.NoError(t, err)
+	require.NoError(t, s.Send(context.Background(), testReport()))
+	assert.Equal(t, 2, f.bulks, "429 items retry the batch")
+	assert.Empty(t, f.templates)
+
+	f.bulks = 0
+	f.bulkReply = func(int) string {
+		return `{"errors":true,"items":[{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [severity]"}}},{"index":{"status":201}}]}`
+	}
+	err = s.Send(context.Background(), testReport())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 of 2 documents rejected, first: mapper_parsing_exception: failed to parse field [severity]")
+	assert.Equal(t, 1, f.bulks, "mapping errors don't retry")
+}
+
+func TestElasticsearch_Test(t *testing.T) {
+	f, url := newFakeElasticsearch(t)
+	s, err := NewElasticsearchSink(config.ElasticsearchSinkConfig{URL: url, Username: "agent", Password: "pw", IndexPrefix: "sec"})
+	require.NoError(t, err)
+	require.NoError(t, s.Test(context.Background(), "web-1"))
+	assert.Contains(t, f.templates, "sec-reports")
+	assert.Contains(t, f.templates, "sec-violations")
+	assert.Equal(t, "Basic YWdlbnQ6cHc=", f.auth[0])
+}
+
+func TestNewElasticsearchSink_Validation(t *testing.T) {
+	_, err := NewElasticsearchSink(config.ElasticsearchSinkConfig{URL: "es:9200"})
+	assert.ErrorContains(t, err, "url")
+	_, err = NewElasticsearchSink(config.ElasticsearchSinkConfig{URL: "https://es:9200", IndexPrefix: "Compliance"})
+	assert.ErrorContains(t, err, "index_prefix")
+	_, err = NewElasticsearchSink(config.ElasticsearchSinkConfig{URL: "https://es:9200", Events: []string{"alerts"}})
+	assert.ErrorContains(t, err, "unknown event")
+
+	t.Setenv("ELASTICSEARCH_API_KEY", "env-key")
+	s, err := NewElasticsearchSink(config.ElasticsearchSinkConfig{URL: "https://es:9200/"})
+	require.NoError(t, err)
+	assert.Equal(t, "ApiKey env-key", s.header.Get("Authorization"))
+	assert.Equal(t, "https://es:9200", s.baseURL)
+}
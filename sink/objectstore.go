@@ -0,0 +1,168 @@
+//go:build !no_sinks && !slim
+
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"compliance-agent/config"
+	"compliance-agent/objectstore"
+	"compliance-agent/report"
+)
+
+func init() {
+	Register("object_store", func(cfg config.SinkConfig) (Sink, error) {
+		return NewObjectStoreSink(cfg.ObjectStore)
+	})
+}
+
+// ObjectStoreSink writes each report, and its violations as JSON lines,
+// to an S3, GCS or Azure Blob bucket, for archiving and for query
+// engines (Athena, BigQuery, Synapse) that read straight from buckets.
+type ObjectStoreSink struct {
+	store      objectstore.ObjectStore
+	events     []string
+	gzip       bool
+	maxRetries int
+	backoff    time.Duration
+}
+
+// NewObjectStoreSink builds a sink from config; cfg.URL's scheme picks
+// the backend.
+func NewObjectStoreSink(cfg config.ObjectStoreSinkConfig) (*ObjectStoreSink, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("url not configured")
+	}
+	s := &ObjectStoreSink{
+		events:     cfg.Events,
+		gzip:       cfg.Gzip,
+		maxRetries: cfg.MaxRetries,
+		backoff:    cfg.RetryBackoff,
+	}
+	if len(s.events) == 0 {
+		s.events = []string{"report", "violations"}
+	}
+	for _, e := range s.events {
+		if e != "report" && e != "violations" {
+			return nil, fmt.Errorf("events: unknown event %q (want report or violations)", e)
+		}
+	}
+	if s.maxRetries < 0 {
+		return nil, errors.New("max_retries: must not be negative")
+	}
+	if s.maxRetries == 0 {
+		s.maxRetries = 3
+	}
+	if s.backoff <= 0 {
+		s.backoff = time.Second
+	}
+	client, err := httpClient(cfg.Timeout, "")
+	if err != nil {
+		return nil, err
+	}
+	if s.store, err = objectstore.Open(cfg.URL, client); err != nil {
+		return nil, fmt.Errorf("url: %w", err)
+	}
+	return s, nil
+}
+
+// Name implements Sink.
+func (s *ObjectStoreSink) Name() string { return "object_store" }
+
+// Test implements Sink by writing test/<hostname>.json under the prefix,
+// away from the reports.
+func (s *ObjectStoreSink) Test(ctx context.Context, hostname string) error {
+	body, err := json.Marshal(map[string]string{
+		"hostname": hostname,
+		"message":  "compliance-agent test event",
+		"time":     time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	return s.put(ctx, "test/"+objectName(hostname)+".json", body, "application/json")
+}
+
+// Send implements Sink. Object names come from the host and scan time,
+// so a retried or repeated send overwrites rather than duplicates.
+func (s *ObjectStoreSink) Send(ctx context.Context, rep report.ComplianceReport) error {
+	host := objectName(rep.Hostname)
+	at := rep.GeneratedAt.UTC()
+	path := host + "/" + at.Format("2006/01/02") + "/" + host + "-" + at.Format("20060102T150405Z")
+
+	var errs []error
+	if slices.Contains(s.events, "report") {
+		body, err := json.Marshal(rep)
+		if err != nil {
+			return err
+		}
+		err = withRetry(ctx, s.maxRetries, s.backoff, func() error {
+			return s.put(ctx, "reports/"+path+".json", body, "application/json")
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("report: %w", err))
+		}
+	}
+	if events := violationEvents(rep); slices.Contains(s.events, "violations") && len(events) > 0 && ctx.Err() == nil {
+		var body bytes.Buffer
+		enc := json.NewEncoder(&body)
+		for _, ev := range events {
+			if err := enc.Encode(ev); err != nil {
+				return err
+			}
+		}
+		err := withRetry(ctx, s.maxRetries, s.backoff, func() error {
+			return s.put(ctx, "violations/"+path+".ndjson", body.Bytes(), "application/x-ndjson")
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("violations: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// put writes one object, gzipped if configured. Network errors, 429 and
+// 5xx responses are temporary.
+func (s *ObjectStoreSink) put(ctx context.Context, key string, body []byte, contentType string) error {
+	if s.gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		key, body, contentType = key+".gz", buf.Bytes(), "application/gzip"
+	}
+	err := s.store.Put(ctx, key, body, contentType)
+	var status *objectstore.StatusError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &status):
+		if status.Temporary() {
+			return temporary{err}
+		}
+		return err
+	case ctx.Err() != nil:
+		return err
+	}
+	return temporary{err}
+}
+
+// objectName makes a hostname safe as one segment of an object name.
+func objectName(host string) string {
+	if host == "" {
+		return "unknown"
+	}
+	return strings.NewReplacer("/", "_", "\\", "_").Replace(host)
+}
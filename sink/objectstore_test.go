@@ -0,0 +1,97 @@
+//go:build !no_sinks && !slim
+
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"compliance-agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectStore_Send(t *testing.T) {
+	objects := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		b, _ := io.ReadAll(r.Body)
+		objects[r.URL.Path] = b
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_ENDPOINT_URL_S3", srv.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+
+	sinks, err := Build(config.SinkConfig{
+		Enabled:     []string{"object_store"},
+		ObjectStore: config.ObjectStoreSinkConfig{URL: "s3://archive/compliance"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, sinks[0].Send(context.Background(), testReport()))
+
+	rep := objects["/archive/compliance/reports/web-1/2026/03/01/web-1-20260301T120000Z.json"]
+	assert.Contains(t, string(rep), `"hostname":"web-1"`)
+	viol := objects["/archive/compliance/violations/web-1/2026/03/01/web-1-20260301T120000Z.ndjson"]
+	assert.Len(t, bytes.Split(bytes.TrimSpace(viol), []byte("\n")), 3)
+	assert.Contains(t, string(viol), `"fingerprint":`)
+}
+
+func TestObjectStore_Gzip(t *testing.T) {
+	var path string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/gzip", r.Header.Get("Content-Type"))
+		path = r.URL.Path
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_ENDPOINT_URL_S3", srv.URL)
+
+	s, err := NewObjectStoreSink(config.ObjectStoreSinkConfig{URL: "s3://archive", Events: []string{"report"}, Gzip: true})
+	require.NoError(t, err)
+	require.NoError(t, s.Send(context.Background(), testReport()))
+	assert.Equal(t, "/archive/reports/web-1/2026/03/01/web-1-20260301T120000Z.json.gz", path)
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	require.NoError(t, err)
+	plain, _ := io.ReadAll(zr)
+	assert.Contains(t, string(plain), `"hostname":"web-1"`)
+}
+
+func TestObjectStore_Retries(t *testing.T) {
+	var calls atomic.Int32
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(status)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_ENDPOINT_URL_S3", srv.URL)
+
+	cfg := config.ObjectStoreSinkConfig{URL: "s3://archive", Events: []string{"report"}, RetryBackoff: time.Millisecond}
+	s, err := NewObjectStoreSink(cfg)
+	require.NoError(t, err)
+	require.NoError(t, s.Send(context.Background(), testReport()))
+	assert.Equal(t, int32(2), calls.Load())
+
+	calls.Store(0)
+	status = http.StatusForbidden
+	assert.ErrorContains(t, s.Send(context.Background(), testReport()), "403")
+	assert.Equal(t, int32(1), calls.Load(), "rejections are not retried")
+}
+
+func TestNewObjectStoreSink_Validation(t *testing.T) {
+	_, err := NewObjectStoreSink(config.ObjectStoreSinkConfig{})
+	assert.ErrorContains(t, err, "url")
+	_, err = NewObjectStoreSink(config.ObjectStoreSinkConfig{URL: "https://bucket/x"})
+	assert.ErrorContains(t, err, "want a URL starting")
+	_, err = NewObjectStoreSink(config.ObjectStoreSinkConfig{URL: "s3://archive", Events: []string{"alerts"}})
+	assert.ErrorContains(t, err, "unknown event")
+}
@@ -0,0 +1,103 @@
+//go:build !no_sinks && !slim
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// temporary wraps an error worth retrying: the request may succeed as
+// is later.
+type temporary struct{ error }
+
+func (t temporary) Unwrap() error { return t.error }
+
+// withRetry runs fn until it succeeds, fails permanently, has been
+// retried retries times, or ctx is done. The wait doubles from backoff
+// after each attempt.
+func withRetry(ctx context.Context, retries int, backoff time.Duration, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		var tmp temporary
+		if err == nil || !errors.As(err, &tmp) || attempt >= retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (gave up retrying: %v)", err, ctx.Err())
+		case <-time.After(backoff << attempt):
+		}
+	}
+}
+
+// post sends body to url. Network errors, 429 and 5xx responses are
+// temporary; other non-2xx responses are not.
+func post(ctx context.Context, client *http.Client, url string, header http.Header, body []byte) error {
+	_, err := request(ctx, client, http.MethodPost, url, header, body)
+	return err
+}
+
+// request is post for any method, returning the body of a 2xx response.
+func request(ctx context.Context, client *http.Client, method, url string, header http.Header, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header.Clone()
+	req.Header.Set("User-Agent", "compliance-agent")
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, temporary{err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, temporary{err}
+		}
+		return b, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	err = fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil, temporary{err}
+	}
+	return nil, err
+}
+
+// httpClient builds a client with timeout, trusting caFile instead of
+// the system roots when set.
+func httpClient(timeout time.Duration, caFile string) (*http.Client, error) {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	c := &http.Client{Timeout: timeout}
+	if caFile == "" {
+		return c, nil
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("ca_file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("ca_file: no certificates in %s", caFile)
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	c.Transport = t
+	return c, nil
+}
@@ -0,0 +1,122 @@
+// Package sink ships compliance reports to data platforms (SIEMs, search
+// clusters, object stores). Where an alerter notifies people of what a
+// scan found, a sink stores every report in full, so it can be searched
+// and charted. Backends register a factory from their init, as alerters
+// do; builds tagged no_sinks (or slim) leave them all out.
+package sink
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"compliance-agent/alerting"
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+	"compliance-agent/report"
+)
+
+// Sink is a report destination.
+type Sink interface {
+	// Name identifies the backend in logs and config ("splunk").
+	Name() string
+	// Send delivers the report and its violations, retrying as the
+	// backend sees fit until ctx is done.
+	Send(ctx context.Context, rep report.ComplianceReport) error
+	// Test delivers a harmless test event, without retrying, to check
+	// the destination accepts what Send would.
+	Test(ctx context.Context, hostname string) error
+}
+
+// Factory builds a sink from the sink config. Returning an error means
+// the backend was enabled but is misconfigured.
+type Factory func(cfg config.SinkConfig) (Sink, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a backend available under name. It is called from the
+// backend's init.
+func Register(name string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic("sink: duplicate sink " + name)
+	}
+	registry[name] = f
+}
+
+// Registered lists the known backend names, sorted.
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for n := range registry {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Build instantiates every sink listed in cfg.Enabled, in order.
+func Build(cfg config.SinkConfig) ([]Sink, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var out []Sink
+	for _, name := range cfg.Enabled {
+		f, ok := registry[name]
+		if !ok && !backendsLinked {
+			return nil, fmt.Errorf("sink %s: not compiled into this agent (built with no_sinks)", name)
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown sink %q (known: %v)", name, Registered())
+		}
+		s, err := f(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("sink %s: %w", name, err)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// ViolationEvent is a violation as sinks send it: the finding with the
+// host, scan time and the fingerprint alerts carry.
+type ViolationEvent struct {
+	Hostname    string            `json:"hostname"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	Fingerprint string            `json:"fingerprint"`
+	Category    string            `json:"category"`
+	Severity    analyzer.Severity `json:"severity"`
+	Control     string            `json:"control,omitempty"`
+	User        string            `json:"user,omitempty"`
+	Message     string            `json:"message"`
+	Risk        float64           `json:"risk,omitempty"`
+}
+
+// violationEvents flattens the report's violations.
+func violationEvents(rep report.ComplianceReport) []ViolationEvent {
+	out := make([]ViolationEvent, 0, len(rep.Violations))
+	for _, v := range rep.Violations {
+		sev := v.Severity
+		if sev == "" {
+			sev = analyzer.SeverityMedium
+		}
+		out = append(out, ViolationEvent{
+			Hostname:    rep.Hostname,
+			GeneratedAt: rep.GeneratedAt,
+			Fingerprint: alerting.Fingerprint(rep.Hostname, v),
+			Category:    v.Category,
+			Severity:    sev,
+			Control:     v.Control,
+			User:        v.User,
+			Message:     v.Message,
+			Risk:        v.Risk,
+		})
+	}
+	return out
+}
@@ -0,0 +1,179 @@
+//go:build !no_sinks && !slim
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"compliance-agent/config"
+	"compliance-agent/report"
+)
+
+func init() {
+	Register("splunk", func(cfg config.SinkConfig) (Sink, error) {
+		return NewSplunkSink(cfg.Splunk)
+	})
+}
+
+// SplunkSink sends reports to a Splunk HTTP Event Collector: the full
+// report as one event and each violation as its own, so searches can
+// count and chart findings without unpacking reports.
+type SplunkSink struct {
+	endpoint            string
+	token               string
+	index               string
+	source              string
+	sourcetype          string
+	violationSourcetype string
+	events              []string
+	batchSize           int
+	maxRetries          int
+	backoff             time.Duration
+	client              *http.Client
+}
+
+// NewSplunkSink builds a sink from config, falling back to the
+// SPLUNK_HEC_TOKEN environment variable.
+func NewSplunkSink(cfg config.SplunkSinkConfig) (*SplunkSink, error) {
+	s := &SplunkSink{
+		token:               cfg.Token,
+		index:               cfg.Index,
+		source:              cfg.Source,
+		sourcetype:          cfg.Sourcetype,
+		violationSourcetype: cfg.ViolationSourcetype,
+		events:              cfg.Events,
+		batchSize:           cfg.BatchSize,
+		maxRetries:          cfg.MaxRetries,
+		backoff:             cfg.RetryBackoff,
+	}
+	if s.token == "" {
+		s.token = os.Getenv("SPLUNK_HEC_TOKEN")
+	}
+	if s.token == "" {
+		return nil, errors.New("token not configured (or SPLUNK_HEC_TOKEN)")
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("url: %q is not an http(s) URL", cfg.URL)
+	}
+	s.endpoint = strings.TrimSuffix(u.String(), "/") + "/services/collector/event"
+	if s.source == "" {
+		s.source = "compliance-agent"
+	}
+	if s.sourcetype == "" {
+		s.sourcetype = "compliance:report"
+	}
+	if s.violationSourcetype == "" {
+		s.violationSourcetype = "compliance:violation"
+	}
+	if len(s.events) == 0 {
+		s.events = []string{"report", "violations"}
+	}
+	for _, e := range s.events {
+		if e != "report" && e != "violations" {
+			return nil, fmt.Errorf("events: unknown event %q (want report or violations)", e)
+		}
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = 100
+	}
+	if s.maxRetries < 0 {
+		return nil, errors.New("max_retries: must not be negative")
+	}
+	if s.maxRetries == 0 {
+		s.maxRetries = 3
+	}
+	if s.backoff <= 0 {
+		s.backoff = time.Second
+	}
+	if s.client, err = httpClient(cfg.Timeout, cfg.CAFile); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Name implements Sink.
+func (s *SplunkSink) Name() string { return "splunk" }
+
+// Test implements Sink with one event under the compliance:test
+// sourcetype, so it stays out of searches on the real ones.
+func (s *SplunkSink) Test(ctx context.Context, hostname string) error {
+	body, err := json.Marshal(hecEvent{
+		Time:       float64(time.Now().UnixMilli()) / 1000,
+		Host:       hostname,
+		Source:     s.source,
+		Sourcetype: "compliance:test",
+		Index:      s.index,
+		Event:      map[string]string{"message": "compliance-agent test event"},
+	})
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.client, s.endpoint, s.header(), body)
+}
+
+func (s *SplunkSink) header() http.Header {
+	header := http.Header{}
+	header.Set("Authorization", "Splunk "+s.token)
+	header.Set("Content-Type", "application/json")
+	return header
+}
+
+// hecEvent is one event in an HEC request body.
+type hecEvent struct {
+	Time       float64 `json:"time"`
+	Host       string  `json:"host"`
+	Source     string  `json:"source"`
+	Sourcetype string  `json:"sourcetype"`
+	Index      string  `json:"index,omitempty"`
+	Event      any     `json:"event"`
+}
+
+// Send implements Sink. Events go out in batches of batch_size; each
+// batch is retried on its own, and a batch that fails for good doesn't
+// stop the rest.
+func (s *SplunkSink) Send(ctx context.Context, rep report.ComplianceReport) error {
+	ts := float64(rep.GeneratedAt.UnixMilli()) / 1000
+	var events []hecEvent
+	if slices.Contains(s.events, "report") {
+		events = append(events, hecEvent{Time: ts, Host: rep.Hostname, Source: s.source, Sourcetype: s.sourcetype, Index: s.index, Event: rep})
+	}
+	if slices.Contains(s.events, "violations") {
+		for _, v := range violationEvents(rep) {
+			events = append(events, hecEvent{Time: ts, Host: rep.Hostname, Source: s.source, Sourcetype: s.violationSourcetype, Index: s.index, Event: v})
+		}
+	}
+	header := s.header()
+	var errs []error
+	for start := 0; start < len(events); start += s.batchSize {
+		batch := events[start:min(start+s.batchSize, len(events))]
+		// HEC takes events concatenated, not as an array.
+		var body bytes.Buffer
+		enc := json.NewEncoder(&body)
+		for _, ev := range batch {
+			if err := enc.Encode(ev); err != nil {
+				return err
+			}
+		}
+		err := withRetry(ctx, s.maxRetries, s.backoff, func() error {
+			return post(ctx, s.client, s.endpoint, header, body.Bytes())
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("events %d-%d: %w", start+1, start+len(batch), err))
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,166 @@
+//go:build !no_sinks && !slim
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+	"compliance-agent/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReport() report.ComplianceReport {
+	return report.ComplianceReport{
+		Hostname:    "web-1",
+		GeneratedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Violations: []analyzer.Violation{
+			{Category: "user", Severity: analyzer.SeverityCritical, Message: `user "eve" present`},
+			{Category: "port", Message: "port 23"},
+			{Category: "port", Severity: analyzer.SeverityLow, Message: "port 8080"},
+		},
+	}
+}
+
+// decodeEvents splits a concatenated HEC body.
+func decodeEvents(t *testing.T, body []byte) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	for dec.More() {
+		var ev map[string]any
+		require.NoError(t, dec.Decode(&ev))
+		out = append(out, ev)
+	}
+	return out
+}
+
+func TestSplunk_SendBatchesEvents(t *testing.T) {
+	var bodies [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/services/collector/event", r.URL.Path)
+		assert.Equal(t, "Splunk tok", r.Header.Get("Authorization"))
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, b)
+		_, _ = io.WriteString(w, `{"text":"Success","code":0}`)
+	}))
+	defer srv.Close()
+
+	sinks, err := Build(config.SinkConfig{
+		Enabled: []string{"splunk"},
+		Splunk:  config.SplunkSinkConfig{URL: srv.URL + "/", Token: "tok", Index: "compliance", BatchSize: 2},
+	})
+	require.NoError(t, err)
+	require.Len(t, sinks, 1)
+	require.NoError(t, sinks[0].Send(context.Background(), testReport()))
+
+	require.Len(t, bodies, 2, "four events in batches of two")
+	var events []map[string]any
+	for _, b := range bodies {
+		events = append(events, decodeEvents(t, b)...)
+	}
+	require.Len(t, events, 4)
+	for _, ev := range events {
+		assert.Equal(t, "web-1", ev["host"])
+		assert.Equal(t, "compliance", ev["index"])
+		assert.Equal(t, "compliance-agent", ev["source"])
+		assert.InDelta(t, 1772366400.0, ev["time"], 0.001)
+	}
+	assert.Equal(t, "compliance:report", events[0]["sourcetype"])
+	assert.Equal(t, "web-1", events[0]["event"].(map[string]any)["hostname"])
+
+	v := events[2]
+	assert.Equal(t, "compliance:violation", v["sourcetype"])
+	body := v["event"].(map[string]any)
+	assert.Equal(t, "port 23", body["message"])
+	assert.Equal(t, "medium", body["severity"], "unset severity defaults to medium")
+	assert.NotEmpty(t, body["fingerprint"])
+}
+
+func TestSplunk_RetriesTemporaryFailures(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	s, err := NewSplunkSink(config.SplunkSinkConfig{URL: srv.URL, Token: "tok", Events: []string{"report"}, RetryBackoff: time.Millisecond})
+	require.NoError(t, err)
+	require.NoError(t, s.Send(context.Background(), testReport()))
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestSplunk_DoesNotRetryRejections(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, `{"text":"Invalid token","code":4}`, http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	s, err := NewSplunkSink(config.SplunkSinkConfig{URL: srv.URL, Token: "bad", Events: []string{"violations"}, RetryBackoff: time.Millisecond})
+	require.NoError(t, err)
+	err = s.Send(context.Background(), testReport())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403")
+	assert.Contains(t, err.Error(), "Invalid token")
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestSplunk_Test(t *testing.T) {
+	var bodies [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Splunk tok", r.Header.Get("Authorization"))
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, b)
+	}))
+	defer srv.Close()
+
+	s, err := NewSplunkSink(config.SplunkSinkConfig{URL: srv.URL, Token: "tok", Index: "compliance"})
+	require.NoError(t, err)
+	require.NoError(t, s.Test(context.Background(), "web-1"))
+	require.Len(t, bodies, 1)
+	events := decodeEvents(t, bodies[0])
+	require.Len(t, events, 1)
+	assert.Equal(t, "compliance:test", events[0]["sourcetype"])
+	assert.Equal(t, "compliance", events[0]["index"])
+	assert.Equal(t, "web-1", events[0]["host"])
+}
+
+func TestNewSplunkSink_Validation(t *testing.T) {
+	t.Setenv("SPLUNK_HEC_TOKEN", "")
+	_, err := NewSplunkSink(config.SplunkSinkConfig{URL: "https://splunk:8088"})
+	assert.ErrorContains(t, err, "token")
+
+	t.Setenv("SPLUNK_HEC_TOKEN", "env-tok")
+	s, err := NewSplunkSink(config.SplunkSinkConfig{URL: "https://splunk:8088"})
+	require.NoError(t, err)
+	assert.Equal(t, "env-tok", s.token)
+	assert.Equal(t, "https://splunk:8088/services/collector/event", s.endpoint)
+
+	_, err = NewSplunkSink(config.SplunkSinkConfig{URL: "splunk:8088"})
+	assert.ErrorContains(t, err, "url")
+	_, err = NewSplunkSink(config.SplunkSinkConfig{URL: "https://splunk:8088", Events: []string{"alerts"}})
+	assert.ErrorContains(t, err, "unknown event")
+	_, err = NewSplunkSink(config.SplunkSinkConfig{URL: "https://splunk:8088", MaxRetries: -1})
+	assert.ErrorContains(t, err, "max_retries")
+}
+
+func TestBuild_UnknownSink(t *testing.T) {
+	_, err := Build(config.SinkConfig{Enabled: []string{"nope"}})
+	assert.ErrorContains(t, err, `unknown sink "nope"`)
+}
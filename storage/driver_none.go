@@ -0,0 +1,7 @@
+//go:build no_history || slim
+
+package storage
+
+// driverLinked is false: the SQLite driver, most of a slim agent's size
+// saving, is left out.
+const driverLinked = false
@@ -0,0 +1,15 @@
+//go:build no_history || slim
+
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpen_NotCompiledIn(t *testing.T) {
+	_, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	assert.ErrorContains(t, err, "no_history")
+}
@@ -0,0 +1,14 @@
+//go:build !no_history && !slim
+
+package storage
+
+import (
+	"compliance-agent/buildinfo"
+
+	_ "modernc.org/sqlite"
+)
+
+// driverLinked reports whether the SQLite driver is in this build.
+const driverLinked = true
+
+func init() { buildinfo.Register("history") }
@@ -0,0 +1,443 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"compliance-agent/report"
+)
+
+const fleetSchema = `
+CREATE TABLE IF NOT EXISTS agents (
+	id            TEXT PRIMARY KEY,
+	token_hash    TEXT NOT NULL UNIQUE, -- hex SHA-256 of the agent's token
+	hostname      TEXT NOT NULL,
+	platform      TEXT NOT NULL DEFAULT '',
+	agent_version TEXT NOT NULL DEFAULT '',
+	enrolled_at   INTEGER NOT NULL, -- unix nanoseconds, UTC
+	last_seen     INTEGER NOT NULL,
+	agent_uuid    TEXT NOT NULL DEFAULT '', -- the agent's persistent ID
+	hardware_uuid TEXT NOT NULL DEFAULT '',
+	previous_id   TEXT NOT NULL DEFAULT '', -- the agent this host enrolled as before
+	reenroll_approved INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS fleet_reports (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	agent_id        TEXT NOT NULL REFERENCES agents (id),
+	generated_at    INTEGER NOT NULL,
+	received_at     INTEGER NOT NULL,
+	hostname        TEXT NOT NULL,
+	scope           TEXT NOT NULL DEFAULT '',
+	violation_count INTEGER NOT NULL,
+	error_count     INTEGER NOT NULL,
+	by_severity     TEXT NOT NULL, -- JSON object
+	max_risk        REAL NOT NULL DEFAULT 0,
+	report_json     BLOB NOT NULL,
+	anonymized      INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS fleet_reports_agent ON fleet_reports (agent_id, generated_at);
+CREATE TABLE IF NOT EXISTS purges (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	purged_at   INTEGER NOT NULL,
+	record_json TEXT NOT NULL
+);
+`
+
+// FleetStore is the fleet server's database of enrolled agents and the
+// reports they upload.
+type FleetStore struct {
+	db *sql.DB
+}
+
+// Agent is an enrolled host.
+type Agent struct {
+	ID           string    `json:"id"`
+	Hostname     string    `json:"hostname"`
+	Platform     string    `json:"platform,omitempty"`
+	AgentVersion string    `json:"agent_version,omitempty"`
+	EnrolledAt   time.Time `json:"enrolled_at"`
+	LastSeen     time.Time `json:"last_seen"`
+	// AgentUUID is the persistent ID the agent keeps on disk and
+	// HardwareUUID the machine's; a host that enrolls again with either
+	// keeps its fleet ID and history.
+	AgentUUID    string `json:"agent_uuid,omitempty"`
+	HardwareUUID string `json:"hardware_uuid,omitempty"`
+	// PreviousID is the agent a host with the same identity enrolled as
+	// before, when it couldn't prove it was that agent and got a new ID.
+	PreviousID string `json:"previous_id,omitempty"`
+	// ReenrollApproved is an admin's approval for the next enrollment
+	// under this agent's identity to take over its ID.
+	ReenrollApproved bool `json:"reenroll_approved,omitempty"`
+	// Latest summarizes the newest report, nil before the first upload.
+	Latest *Run `json:"latest,omitempty"`
+}
+
+// OpenFleet opens (creating if needed) the fleet database at path.
+func OpenFleet(path string) (*FleetStore, error) {
+	db, err := openDB(path, fleetSchema, "fleet database")
+	if err != nil {
+		return nil, err
+	}
+	// Databases from before agents reported an identity.
+	if err := addColumns(db, "agents", map[string]string{
+		"agent_uuid":        "TEXT NOT NULL DEFAULT ''",
+		"hardware_uuid":     "TEXT NOT NULL DEFAULT ''",
+		"previous_id":       "TEXT NOT NULL DEFAULT ''",
+		"reenroll_approved": "INTEGER NOT NULL DEFAULT 0",
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate fleet database %s: %w", path, err)
+	}
+	if err := addColumns(db, "fleet_reports", map[string]string{
+		"anonymized": "INTEGER NOT NULL DEFAULT 0",
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate fleet database %s: %w", path, err)
+	}
+	return &FleetStore{db: db}, nil
+}
+
+// Close releases the database.
+func (s *FleetStore) Close() error {
+	return s.db.Close()
+}
+
+// Enroll records a new agent, identified from then on by tokenHash.
+func (s *FleetStore) Enroll(a Agent, tokenHash string) error {
+	now := a.EnrolledAt.UTC().UnixNano()
+	_, err := s.db.Exec(`INSERT INTO agents (id, token_hash, hostname, platform, agent_version, enrolled_at, last_seen, agent_uuid, hardware_uuid, previous_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, a.ID, tokenHash, a.Hostname, a.Platform, a.AgentVersion, now, now, a.AgentUUID, a.HardwareUUID, a.PreviousID)
+	if err != nil {
+		return fmt.Errorf("enroll %s: %w", a.Hostname, err)
+	}
+	return nil
+}
+
+// Reenroll gives the enrolled agent a.ID a new token hash, so its old
+// token stops working, and refreshes what it says about itself. An
+// approval to re-enroll is used up.
+func (s *FleetStore) Reenroll(a Agent, tokenHash string) error {
+	res, err := s.db.Exec(`UPDATE agents SET token_hash = ?, hostname = ?, platform = ?, agent_version = ?, last_seen = ?,
+		agent_uuid = ?, hardware_uuid = ?, reenroll_approved = 0 WHERE id = ?`,
+		tokenHash, a.Hostname, a.Platform, a.AgentVersion, a.EnrolledAt.UTC().UnixNano(), a.AgentUUID, a.HardwareUUID, a.ID)
+	if err != nil {
+		return fmt.Errorf("re-enroll %s: %w", a.Hostname, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("re-enroll %s: no agent %s", a.Hostname, a.ID)
+	}
+	return nil
+}
+
+// AgentByIdentity finds the agent a host enrolled as before: by its
+// persistent agent UUID, else by its hardware UUID, one approved to
+// re-enroll first, then the newest. Empty arguments match nothing.
+func (s *FleetStore) AgentByIdentity(agentUUID, hardwareUUID string) (a Agent, ok bool, err error) {
+	if agentUUID != "" {
+		if a, ok, err = s.agent(`WHERE agent_uuid = ? ORDER BY reenroll_approved DESC, last_seen DESC LIMIT 1`, agentUUID); ok || err != nil {
+			return a, ok, err
+		}
+	}
+	if hardwareUUID != "" {
+		return s.agent(`WHERE hardware_uuid = ? ORDER BY reenroll_approved DESC, last_seen DESC LIMIT 1`, hardwareUUID)
+	}
+	return a, false, nil
+}
+
+// ApproveReenroll lets the next enrollment under agent id's identity
+// take over its ID without proving it holds the agent's credentials. ok
+// is false for an unknown ID.
+func (s *FleetStore) ApproveReenroll(id string) (ok bool, err error) {
+	res, err := s.db.Exec(`UPDATE agents SET reenroll_approved = 1 WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("approve re-enrollment of %s: %w", id, err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// AgentByToken finds the agent a token hash belongs to. ok is false for
+// an unknown token.
+func (s *FleetStore) AgentByToken(tokenHash string) (a Agent, ok bool, err error) {
+	return s.agent(`WHERE token_hash = ?`, tokenHash)
+}
+
+// Agent loads an agent with a summary of its newest report. ok is false
+// for an unknown ID.
+func (s *FleetStore) Agent(id string) (a Agent, ok bool, err error) {
+	if a, ok, err = s.agent(`WHERE id = ?`, id); !ok || err != nil {
+		return a, ok, err
+	}
+	runs, err := s.AgentRuns(id, 1)
+	if len(runs) > 0 {
+		a.Latest = &runs[0]
+	}
+	return a, true, err
+}
+
+func (s *FleetStore) agent(where string, arg any) (Agent, bool, error) {
+	var a Agent
+	var enrolled, seen int64
+	err := s.db.QueryRow(`SELECT id, hostname, platform, agent_version, enrolled_at, last_seen, agent_uuid, hardware_uuid,
+			previous_id, reenroll_approved
+		FROM agents `+where, arg).
+		Scan(&a.ID, &a.Hostname, &a.Platform, &a.AgentVersion, &enrolled, &seen, &a.AgentUUID, &a.HardwareUUID,
+			&a.PreviousID, &a.ReenrollApproved)
+	if err == sql.ErrNoRows {
+		return a, false, nil
+	}
+	if err != nil {
+		return a, false, err
+	}
+	a.EnrolledAt = time.Unix(0, enrolled).UTC()
+	a.LastSeen = time.Unix(0, seen).UTC()
+	return a, true, nil
+}
+
+// Agents lists every enrolled agent with a summary of its newest report,
+// the riskiest host first, then by hostname.
+func (s *FleetStore) Agents() ([]Agent, error) {
+	rows, err := s.db.Query(`SELECT a.id, a.hostname, a.platform, a.agent_version, a.enrolled_at, a.last_seen,
+			a.agent_uuid, a.hardware_uuid, a.previous_id, a.reenroll_approved,
+			r.id, r.generated_at, r.hostname, r.scope, r.violation_count, r.error_count, r.by_severity, r.max_risk
+		FROM agents a LEFT JOIN fleet_reports r ON r.id = (
+			SELECT id FROM fleet_reports WHERE agent_id = a.id ORDER BY generated_at DESC, id DESC LIMIT 1)
+		ORDER BY COALESCE(r.max_risk, 0) DESC, a.hostname, a.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var agents []Agent
+	for rows.Next() {
+		var a Agent
+		var enrolled, seen int64
+		var runID, generated sql.NullInt64
+		var hostname, scope, bySeverity sql.NullString
+		var violations, errs sql.NullInt64
+		var maxRisk sql.NullFloat64
+		if err := rows.Scan(&a.ID, &a.Hostname, &a.Platform, &a.AgentVersion, &enrolled, &seen,
+			&a.AgentUUID, &a.HardwareUUID, &a.PreviousID, &a.ReenrollApproved, &runID, &generated, &hostname, &scope, &violations, &errs, &bySeverity, &maxRisk); err != nil {
+			return nil, err
+		}
+		a.EnrolledAt = time.Unix(0, enrolled).UTC()
+		a.LastSeen = time.Unix(0, seen).UTC()
+		if runID.Valid {
+			r := Run{
+				ID:          runID.Int64,
+				GeneratedAt: time.Unix(0, generated.Int64).UTC(),
+				Hostname:    hostname.String,
+				Scope:       scope.String,
+				Violations:  int(violations.Int64),
+				Errors:      int(errs.Int64),
+				MaxRisk:     maxRisk.Float64,
+			}
+			if err := json.Unmarshal([]byte(bySeverity.String), &r.BySeverity); err != nil {
+				return nil, fmt.Errorf("report %d: %w", r.ID, err)
+			}
+			a.Latest = &r
+		}
+		agents = append(agents, a)
+	}
+	return agents, rows.Err()
+}
+
+// SaveReport stores a report an agent uploaded, refreshes the agent's
+// hostname, platform, version, identity and last-seen time from it, and
+// returns the new report ID.
+func (s *FleetStore) SaveReport(agentID string, rep report.ComplianceReport) (int64, error) {
+	body, err := json.Marshal(rep)
+	if err != nil {
+		return 0, err
+	}
+	sev, maxRisk := violationSummary(rep)
+	var version string
+	if rep.Agent != nil {
+		version = rep.Agent.Version
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	now := time.Now().UTC().UnixNano()
+	res, err := tx.Exec(`INSERT INTO fleet_reports
+		(agent_id, generated_at, received_at, hostname, scope, violation_count, error_count, by_severity, max_risk, report_json)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		agentID, rep.GeneratedAt.UTC().UnixNano(), now, rep.Hostname, rep.Scope,
+		len(rep.Violations), len(rep.Errors), sev, maxRisk, body)
+	if err != nil {
+		return 0, fmt.Errorf("insert report: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE agents SET hostname = ?, platform = ?, agent_version = ?, last_seen = ? WHERE id = ?`,
+		rep.Hostname, rep.Platform, version, now, agentID); err != nil {
+		return 0, fmt.Errorf("update agent: %w", err)
+	}
+	if ident := rep.Identity; ident != nil {
+		var hw string
+		if ident.Hardware != nil {
+			hw = ident.Hardware.UUID
+		}
+		if _, err := tx.Exec(`UPDATE agents SET agent_uuid = ?, hardware_uuid = COALESCE(NULLIF(?, ''), hardware_uuid) WHERE id = ?`,
+			ident.AgentID, hw, agentID); err != nil {
+			return 0, fmt.Errorf("update agent: %w", err)
+		}
+	}
+	return id, tx.Commit()
+}
+
+// violationSummary is the by_severity and max_risk columns for rep.
+func violationSummary(rep report.ComplianceReport) (bySeverity string, maxRisk float64) {
+	counts := map[string]int{}
+	for _, v := range rep.Violations {
+		counts[string(v.Severity)]++
+		maxRisk = max(maxRisk, v.Risk)
+	}
+	b, _ := json.Marshal(counts)
+	return string(b), maxRisk
+}
+
+// AgentRuns returns an agent's newest limit reports (all when limit <=
+// 0), newest first.
+func (s *FleetStore) AgentRuns(agentID string, limit int) ([]Run, error) {
+	return s.AgentRunsUntil(agentID, time.Time{}, limit)
+}
+
+// AgentRunsUntil is AgentRuns restricted to reports generated at or
+// before until (any time when zero).
+func (s *FleetStore) AgentRunsUntil(agentID string, until time.Time, limit int) ([]Run, error) {
+	q := `SELECT id, generated_at, hostname, scope, violation_count, error_count, by_severity, max_risk
+		FROM fleet_reports WHERE agent_id = ?`
+	args := []any{agentID}
+	if !until.IsZero() {
+		q += " AND generated_at <= ?"
+		args = append(args, until.UTC().UnixNano())
+	}
+	q += " ORDER BY generated_at DESC, id DESC"
+	if limit > 0 {
+		q += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var runs []Run
+	for rows.Next() {
+		var r Run
+		var ts int64
+		var sev string
+		if err := rows.Scan(&r.ID, &ts, &r.Hostname, &r.Scope, &r.Violations, &r.Errors, &sev, &r.MaxRisk); err != nil {
+			return nil, err
+		}
+		r.GeneratedAt = time.Unix(0, ts).UTC()
+		if err := json.Unmarshal([]byte(sev), &r.BySeverity); err != nil {
+			return nil, fmt.Errorf("report %d: %w", r.ID, err)
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// Report loads a stored report and the agent that uploaded it. ok is
+// false for an unknown ID.
+func (s *FleetStore) Report(id int64) (rep report.ComplianceReport, agentID string, ok bool, err error) {
+	var body []byte
+	err = s.db.QueryRow(`SELECT agent_id, report_json FROM fleet_reports WHERE id = ?`, id).Scan(&agentID, &body)
+	if err == sql.ErrNoRows {
+		return rep, "", false, nil
+	}
+	if err != nil {
+		return rep, "", false, err
+	}
+	return rep, agentID, true, json.Unmarshal(body, &rep)
+}
+
+// Prune deletes reports older than maxAge and, beyond that, all but each
+// agent's newest maxPerAgent. Zero disables either limit. It returns the
+// number of reports removed.
+func (s *FleetStore) Prune(maxAge time.Duration, maxPerAgent int) (int64, error) {
+	var n int64
+	if maxAge > 0 {
+		res, err := s.db.Exec(`DELETE FROM fleet_reports WHERE generated_at < ?`, time.Now().Add(-maxAge).UTC().UnixNano())
+		if err != nil {
+			return 0, fmt.Errorf("prune reports: %w", err)
+		}
+		removed, _ := res.RowsAffected()
+		n += removed
+	}
+	if maxPerAgent > 0 {
+		res, err := s.db.Exec(`DELETE FROM fleet_reports WHERE id IN (
+			SELECT id FROM (SELECT id, ROW_NUMBER() OVER (
+				PARTITION BY agent_id ORDER BY generated_at DESC, id DESC) AS n FROM fleet_reports)
+			WHERE n > ?)`, maxPerAgent)
+		if err != nil {
+			return n, fmt.Errorf("prune reports: %w", err)
+		}
+		removed, _ := res.RowsAffected()
+		n += removed
+	}
+	return n, nil
+}
+
+// Anonymize replaces each report older than olderThan with its anonymized
+// form (see report.ComplianceReport.Anonymize). Reports anonymized before
+// are skipped; zero disables it. It returns the number anonymized.
+func (s *FleetStore) Anonymize(olderThan time.Duration) (int64, error) {
+	if olderThan <= 0 {
+		return 0, nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	reps, err := stale(tx, "fleet_reports", olderThan)
+	if err != nil {
+		return 0, err
+	}
+	for id, rep := range reps {
+		body, err := json.Marshal(rep.Anonymize())
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`UPDATE fleet_reports SET report_json = ?, anonymized = 1 WHERE id = ?`, body, id); err != nil {
+			return 0, fmt.Errorf("anonymize report %d: %w", id, err)
+		}
+	}
+	return int64(len(reps)), tx.Commit()
+}
+
+// ReportsBetween calls fn with each stored report generated in [from,
+// to], grouped by agent and oldest first within each. fn must not use
+// the store.
+func (s *FleetStore) ReportsBetween(from, to time.Time, fn func(agentID string, rep report.ComplianceReport) error) error {
+	rows, err := s.db.Query(`SELECT agent_id, report_json FROM fleet_reports WHERE generated_at >= ? AND generated_at <= ?
+		ORDER BY agent_id, generated_at, id`, from.UTC().UnixNano(), to.UTC().UnixNano())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var agentID string
+		var body []byte
+		if err := rows.Scan(&agentID, &body); err != nil {
+			return err
+		}
+		var rep report.ComplianceReport
+		if err := json.Unmarshal(body, &rep); err != nil {
+			return fmt.Errorf("report of %s: %w", agentID, err)
+		}
+		if err := fn(agentID, rep); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
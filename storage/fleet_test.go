@@ -0,0 +1,212 @@
+//go:build !no_history && !slim
+
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/collector"
+	"compliance-agent/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFleetStore(t *testing.T) {
+	s, err := OpenFleet(filepath.Join(t.TempDir(), "fleet.db"))
+	require.NoError(t, err)
+	defer s.Close()
+
+	enrolled := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, s.Enroll(Agent{ID: "a1", Hostname: "web-1", EnrolledAt: enrolled}, "hash1"))
+	require.NoError(t, s.Enroll(Agent{ID: "a2", Hostname: "db-1", EnrolledAt: enrolled}, "hash2"))
+	assert.Error(t, s.Enroll(Agent{ID: "a3", Hostname: "x", EnrolledAt: enrolled}, "hash1"), "tokens are unique")
+
+	a, ok, err := s.AgentByToken("hash1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "a1", a.ID)
+	assert.Equal(t, enrolled, a.EnrolledAt)
+	_, ok, err = s.AgentByToken("nope")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	now := time.Now().UTC()
+	old := report.ComplianceReport{GeneratedAt: now.Add(-48 * time.Hour), Hostname: "web-1"}
+	recent := report.ComplianceReport{
+		GeneratedAt: now,
+		Hostname:    "web-1.example.com",
+		Platform:    "linux",
+		Agent:       &report.AgentInfo{Version: "1.2.0"},
+		Violations: []analyzer.Violation{
+			{Category: "user", Severity: analyzer.SeverityHigh, Message: "unexpected user present: eve", Risk: 7},
+			{Category: "port", Severity: analyzer.SeverityMedium, Message: "unexpected open port: 8080", Risk: 6},
+		},
+	}
+	_, err = s.SaveReport("a1", old)
+	require.NoError(t, err)
+	id, err := s.SaveReport("a1", recent)
+	require.NoError(t, err)
+
+	agents, err := s.Agents()
+	require.NoError(t, err)
+	require.Len(t, agents, 2)
+	assert.Equal(t, "a1", agents[0].ID, "riskiest first")
+	assert.Nil(t, agents[1].Latest)
+	web := agents[0]
+	assert.Equal(t, "web-1.example.com", web.Hostname, "refreshed from the report")
+	assert.Equal(t, "linux", web.Platform)
+	assert.Equal(t, "1.2.0", web.AgentVersion)
+	assert.True(t, web.LastSeen.After(enrolled))
+	require.NotNil(t, web.Latest)
+	assert.Equal(t, id, web.Latest.ID)
+	assert.Equal(t, map[string]int{"high": 1, "medium": 1}, web.Latest.BySeverity)
+	assert.Equal(t, 7.0, web.Latest.MaxRisk)
+
+	a, ok, err = s.Agent("a1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NotNil(t, a.Latest)
+	assert.Equal(t, 2, a.Latest.Violations)
+
+	runs, err := s.AgentRuns("a1", 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, id, runs[0].ID, "newest first")
+	runs, err = s.AgentRunsUntil("a1", now.Add(-time.Hour), 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.NotEqual(t, id, runs[0].ID)
+	runs, err = s.AgentRunsUntil("a1", now.Add(-72*time.Hour), 0)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+
+	got, agentID, ok, err := s.Report(id)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "a1", agentID)
+	assert.Equal(t, recent.Violations, got.Violations)
+	_, _, ok, err = s.Report(999)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	var hosts []string
+	require.NoError(t, s.ReportsBetween(now.Add(-72*time.Hour), now, func(agentID string, rep report.ComplianceReport) error {
+		hosts = append(hosts, agentID+"/"+rep.Hostname)
+		return nil
+	}))
+	assert.Equal(t, []string{"a1/web-1", "a1/web-1.example.com"}, hosts, "oldest first")
+
+	n, err := s.Anonymize(time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	got, _, _, err = s.Report(id)
+	require.NoError(t, err)
+	assert.False(t, got.Anonymized, "recent reports are left alone")
+	runs, err = s.AgentRunsUntil("a1", now.Add(-time.Hour), 0)
+	require.NoError(t, err)
+	got, _, _, err = s.Report(runs[0].ID)
+	require.NoError(t, err)
+	assert.True(t, got.Anonymized)
+
+	n, err = s.Prune(24*time.Hour, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	for i := 0; i < 3; i++ {
+		_, err = s.SaveReport("a2", report.ComplianceReport{GeneratedAt: now.Add(time.Duration(i) * time.Minute), Hostname: "db-1"})
+		require.NoError(t, err)
+	}
+	n, err = s.Prune(0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	runs, err = s.AgentRuns("a2", 0)
+	require.NoError(t, err)
+	assert.Len(t, runs, 2)
+	runs, err = s.AgentRuns("a1", 0)
+	require.NoError(t, err)
+	assert.Len(t, runs, 1, "limit is per agent")
+}
+
+func TestFleetStore_Identity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fleet.db")
+	// A database from before agents reported an identity.
+	db, err := openDB(path, `CREATE TABLE agents (
+		id TEXT PRIMARY KEY, token_hash TEXT NOT NULL UNIQUE, hostname TEXT NOT NULL,
+		platform TEXT NOT NULL DEFAULT '', agent_version TEXT NOT NULL DEFAULT '',
+		enrolled_at INTEGER NOT NULL, last_seen INTEGER NOT NULL);
+		INSERT INTO agents VALUES ('old', 'hash0', 'legacy', '', '', 0, 0);`, "fleet database")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	s, err := OpenFleet(path)
+	require.NoError(t, err)
+	defer s.Close()
+	a, ok, err := s.Agent("old")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Empty(t, a.AgentUUID)
+
+	now := time.Now().UTC()
+	require.NoError(t, s.Enroll(Agent{ID: "a1", Hostname: "web-1", EnrolledAt: now, AgentUUID: "u1"}, "hash1"))
+	_, ok, err = s.AgentByIdentity("", "")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// The first report brings the hardware UUID; a later one without it
+	// keeps it.
+	_, err = s.SaveReport("a1", report.ComplianceReport{GeneratedAt: now, Hostname: "web-1",
+		Identity: &report.Identity{AgentID: "u1", Hardware: &collector.HardwareInfo{UUID: "hw1"}}})
+	require.NoError(t, err)
+	_, err = s.SaveReport("a1", report.ComplianceReport{GeneratedAt: now, Hostname: "web-1",
+		Identity: &report.Identity{AgentID: "u1"}})
+	require.NoError(t, err)
+
+	a, ok, err = s.AgentByIdentity("u1", "")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "a1", a.ID)
+	assert.Equal(t, "hw1", a.HardwareUUID)
+	// Re-imaged: a new agent UUID on the same hardware.
+	a, ok, err = s.AgentByIdentity("u2", "hw1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "a1", a.ID)
+
+	a.Hostname, a.AgentUUID, a.EnrolledAt = "web-1-new", "u2", now
+	require.NoError(t, s.Reenroll(a, "hash2"))
+	_, ok, err = s.AgentByToken("hash1")
+	require.NoError(t, err)
+	assert.False(t, ok, "the old token is revoked")
+	a, ok, err = s.AgentByToken("hash2")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "a1", a.ID)
+	assert.Equal(t, "u2", a.AgentUUID)
+	assert.Equal(t, "web-1-new", a.Hostname)
+	assert.Error(t, s.Reenroll(Agent{ID: "nope"}, "hash3"))
+
+	// A newer agent claiming the same identity, then an approval for the
+	// old one: the approved agent is found first until it re-enrolls.
+	require.NoError(t, s.Enroll(Agent{ID: "a2", Hostname: "web-1", EnrolledAt: now.Add(time.Minute), AgentUUID: "u2", PreviousID: "a1"}, "hash4"))
+	a, _, err = s.AgentByIdentity("u2", "")
+	require.NoError(t, err)
+	assert.Equal(t, "a2", a.ID)
+	assert.Equal(t, "a1", a.PreviousID)
+	ok, err = s.ApproveReenroll("a1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	a, _, err = s.AgentByIdentity("u2", "")
+	require.NoError(t, err)
+	assert.Equal(t, "a1", a.ID)
+	assert.True(t, a.ReenrollApproved)
+	require.NoError(t, s.Reenroll(a, "hash5"))
+	a, _, err = s.AgentByIdentity("u2", "")
+	require.NoError(t, err)
+	assert.False(t, a.ReenrollApproved, "used up")
+	ok, err = s.ApproveReenroll("nope")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,409 @@
+// Package storage keeps a local history of compliance reports in SQLite so
+// operators can see how a host's findings change between runs without
+// shipping every report to a central system.
+//
+// It uses a pure-Go SQLite driver, so the agent still builds without cgo.
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"compliance-agent/report"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS reports (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	generated_at    INTEGER NOT NULL, -- unix nanoseconds, UTC
+	hostname        TEXT NOT NULL,
+	scope           TEXT NOT NULL DEFAULT '',
+	violation_count INTEGER NOT NULL,
+	error_count     INTEGER NOT NULL,
+	report_json     BLOB NOT NULL,
+	anonymized      INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS reports_generated_at ON reports (generated_at);
+CREATE TABLE IF NOT EXISTS violations (
+	id        INTEGER PRIMARY KEY AUTOINCREMENT,
+	report_id INTEGER NOT NULL REFERENCES reports (id),
+	category  TEXT NOT NULL,
+	severity  TEXT NOT NULL,
+	user      TEXT NOT NULL DEFAULT '',
+	message   TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS violations_report_id ON violations (report_id);
+`
+
+// Store is a report history database.
+type Store struct {
+	db *sql.DB
+}
+
+// Run summarizes one stored report.
+type Run struct {
+	ID          int64          `json:"id"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Hostname    string         `json:"hostname"`
+	Scope       string         `json:"scope,omitempty"`
+	Violations  int            `json:"violations"`
+	Errors      int            `json:"errors"`
+	BySeverity  map[string]int `json:"by_severity"`
+	// MaxRisk is the riskiest violation's score; fleet reports only.
+	MaxRisk float64 `json:"max_risk,omitempty"`
+}
+
+// Open opens (creating if needed) the history database at path.
+func Open(path string) (*Store, error) {
+	db, err := openDB(path, schema, "report history")
+	if err != nil {
+		return nil, err
+	}
+	// Databases from before reports could be anonymized.
+	if err := addColumns(db, "reports", map[string]string{
+		"anonymized": "INTEGER NOT NULL DEFAULT 0",
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate report history %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// openDB opens the SQLite database at path and applies schema; what names
+// it in errors.
+func openDB(path, schema, what string) (*sql.DB, error) {
+	if !driverLinked {
+		return nil, fmt.Errorf("%s is not compiled into this agent (built with no_history)", what)
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("%s dir: %w", what, err)
+		}
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s %s: %w", what, path, err)
+	}
+	// SQLite allows one writer; a single connection avoids SQLITE_BUSY
+	// between the agent's own goroutines.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init %s %s: %w", what, path, err)
+	}
+	return db, nil
+}
+
+// addColumns adds the columns (name to definition) that table lacks,
+// for databases created by an older schema.
+func addColumns(db *sql.DB, table string, cols map[string]string) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	have := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		have[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(cols))
+	for name := range cols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if have[name] {
+			continue
+		}
+		if _, err := db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + name + ` ` + cols[name]); err != nil {
+			return fmt.Errorf("add %s.%s: %w", table, name, err)
+		}
+	}
+	return nil
+}
+
+// Close releases the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Save stores a report and its violations, returning the new run ID.
+func (s *Store) Save(rep report.ComplianceReport) (int64, error) {
+	body, err := json.Marshal(rep)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO reports (generated_at, hostname, scope, violation_count, error_count, report_json)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		rep.GeneratedAt.UTC().UnixNano(), rep.Hostname, rep.Scope, len(rep.Violations), len(rep.Errors), body)
+	if err != nil {
+		return 0, fmt.Errorf("insert report: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range rep.Violations {
+		if _, err := tx.Exec(`INSERT INTO violations (report_id, category, severity, user, message) VALUES (?, ?, ?, ?, ?)`,
+			id, v.Category, string(v.Severity), v.User, v.Message); err != nil {
+			return 0, fmt.Errorf("insert violation: %w", err)
+		}
+	}
+	return id, tx.Commit()
+}
+
+// Prune deletes runs older than maxAge and, beyond that, all but the
+// newest maxRuns. Zero disables either limit. It returns the number of
+// runs removed.
+func (s *Store) Prune(maxAge time.Duration, maxRuns int) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Collect the doomed IDs first so violations go with their report.
+	var where []string
+	var args []any
+	if maxAge > 0 {
+		where = append(where, "generated_at < ?")
+		args = append(args, time.Now().Add(-maxAge).UTC().UnixNano())
+	}
+	if maxRuns > 0 {
+		where = append(where, "id NOT IN (SELECT id FROM reports ORDER BY generated_at DESC, id DESC LIMIT ?)")
+		args = append(args, maxRuns)
+	}
+	if len(where) == 0 {
+		return 0, nil
+	}
+	cond := where[0]
+	if len(where) == 2 {
+		cond = where[0] + " OR " + where[1]
+	}
+	if _, err := tx.Exec("DELETE FROM violations WHERE report_id IN (SELECT id FROM reports WHERE "+cond+")", args...); err != nil {
+		return 0, fmt.Errorf("prune violations: %w", err)
+	}
+	res, err := tx.Exec("DELETE FROM reports WHERE "+cond, args...)
+	if err != nil {
+		return 0, fmt.Errorf("prune reports: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, tx.Commit()
+}
+
+// Anonymize replaces each run older than olderThan with its anonymized
+// report (see report.ComplianceReport.Anonymize), and its violation rows
+// with their fingerprints. Runs anonymized before are skipped; zero
+// disables it. It returns the number of runs anonymized.
+func (s *Store) Anonymize(olderThan time.Duration) (int64, error) {
+	if olderThan <= 0 {
+		return 0, nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	reps, err := stale(tx, "reports", olderThan)
+	if err != nil {
+		return 0, err
+	}
+	for id, rep := range reps {
+		anon := rep.Anonymize()
+		body, err := json.Marshal(anon)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`UPDATE reports SET report_json = ?, anonymized = 1 WHERE id = ?`, body, id); err != nil {
+			return 0, fmt.Errorf("anonymize report %d: %w", id, err)
+		}
+		if _, err := tx.Exec(`DELETE FROM violations WHERE report_id = ?`, id); err != nil {
+			return 0, fmt.Errorf("anonymize report %d: %w", id, err)
+		}
+		for _, v := range anon.Violations {
+			if _, err := tx.Exec(`INSERT INTO violations (report_id, category, severity, message) VALUES (?, ?, ?, ?)`,
+				id, v.Category, string(v.Severity), v.Fingerprint); err != nil {
+				return 0, fmt.Errorf("anonymize report %d: %w", id, err)
+			}
+		}
+	}
+	return int64(len(reps)), tx.Commit()
+}
+
+// stale loads table's reports generated before olderThan ago that aren't
+// anonymized yet, by ID.
+func stale(tx *sql.Tx, table string, olderThan time.Duration) (map[int64]report.ComplianceReport, error) {
+	rows, err := tx.Query(`SELECT id, report_json FROM `+table+` WHERE anonymized = 0 AND generated_at < ?`,
+		time.Now().Add(-olderThan).UTC().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reps := map[int64]report.ComplianceReport{}
+	for rows.Next() {
+		var id int64
+		var body []byte
+		if err := rows.Scan(&id, &body); err != nil {
+			return nil, err
+		}
+		var rep report.ComplianceReport
+		if err := json.Unmarshal(body, &rep); err != nil {
+			return nil, fmt.Errorf("report %d: %w", id, err)
+		}
+		reps[id] = rep
+	}
+	return reps, rows.Err()
+}
+
+// Runs returns the newest limit runs (all when limit <= 0), newest first,
+// with violation counts broken down by severity.
+func (s *Store) Runs(limit int) ([]Run, error) {
+	return s.RunsUntil("", time.Time{}, limit)
+}
+
+// RunsUntil is Runs restricted to hostname's reports (every host's when
+// empty) generated at or before until (any time when zero). The first is
+// the report that shows what the host looked like at until.
+func (s *Store) RunsUntil(hostname string, until time.Time, limit int) ([]Run, error) {
+	q := `SELECT id, generated_at, hostname, scope, violation_count, error_count FROM reports`
+	var where []string
+	var args []any
+	if hostname != "" {
+		where = append(where, "hostname = ?")
+		args = append(args, hostname)
+	}
+	if !until.IsZero() {
+		where = append(where, "generated_at <= ?")
+		args = append(args, until.UTC().UnixNano())
+	}
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	q += " ORDER BY generated_at DESC, id DESC"
+	if limit > 0 {
+		q += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	var runs []Run
+	index := map[int64]int{}
+	var minID int64
+	for rows.Next() {
+		var r Run
+		var ts int64
+		if err := rows.Scan(&r.ID, &ts, &r.Hostname, &r.Scope, &r.Violations, &r.Errors); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		r.GeneratedAt = time.Unix(0, ts).UTC()
+		r.BySeverity = map[string]int{}
+		index[r.ID] = len(runs)
+		if minID == 0 || r.ID < minID {
+			minID = r.ID
+		}
+		runs = append(runs, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, nil
+	}
+
+	sev, err := s.db.Query(`SELECT report_id, severity, COUNT(*) FROM violations
+		WHERE report_id >= ? GROUP BY report_id, severity`, minID)
+	if err != nil {
+		return nil, err
+	}
+	defer sev.Close()
+	for sev.Next() {
+		var id int64
+		var name string
+		var n int
+		if err := sev.Scan(&id, &name, &n); err != nil {
+			return nil, err
+		}
+		if i, ok := index[id]; ok {
+			runs[i].BySeverity[name] = n
+		}
+	}
+	return runs, sev.Err()
+}
+
+// Latest loads the newest stored report for hostname. ok is false when
+// the host has none.
+func (s *Store) Latest(hostname string) (rep report.ComplianceReport, ok bool, err error) {
+	var body []byte
+	err = s.db.QueryRow(`SELECT report_json FROM reports WHERE hostname = ?
+		ORDER BY generated_at DESC, id DESC LIMIT 1`, hostname).Scan(&body)
+	if err == sql.ErrNoRows {
+		return rep, false, nil
+	}
+	if err != nil {
+		return rep, false, err
+	}
+	return rep, true, json.Unmarshal(body, &rep)
+}
+
+// Report loads the full stored report for a run.
+func (s *Store) Report(id int64) (report.ComplianceReport, error) {
+	var rep report.ComplianceReport
+	var body []byte
+	err := s.db.QueryRow(`SELECT report_json FROM reports WHERE id = ?`, id).Scan(&body)
+	if err == sql.ErrNoRows {
+		return rep, fmt.Errorf("no run with id %d", id)
+	}
+	if err != nil {
+		return rep, err
+	}
+	return rep, json.Unmarshal(body, &rep)
+}
+
+// ReportsBetween calls fn with each stored report generated in [from,
+// to], oldest first. fn must not use the store.
+func (s *Store) ReportsBetween(from, to time.Time, fn func(report.ComplianceReport) error) error {
+	rows, err := s.db.Query(`SELECT report_json FROM reports WHERE generated_at >= ? AND generated_at <= ?
+		ORDER BY generated_at, id`, from.UTC().UnixNano(), to.UTC().UnixNano())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var body []byte
+		if err := rows.Scan(&body); err != nil {
+			return err
+		}
+		var rep report.ComplianceReport
+		if err := json.Unmarshal(body, &rep); err != nil {
+			return err
+		}
+		if err := fn(rep); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,168 @@
+//go:build !no_history && !slim
+
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/collector"
+	"compliance-agent/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_SaveRunsAndPrune(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "sub", "history.db"))
+	require.NoError(t, err)
+	defer s.Close()
+
+	now := time.Now().UTC()
+	old := report.ComplianceReport{GeneratedAt: now.Add(-48 * time.Hour), Hostname: "web-1"}
+	recent := report.ComplianceReport{
+		GeneratedAt: now,
+		Hostname:    "web-1",
+		Violations: []analyzer.Violation{
+			{Category: "user", Severity: analyzer.SeverityHigh, Message: "unexpected user present: eve"},
+			{Category: "port", Severity: analyzer.SeverityMedium, Message: "unexpected open port: 8080"},
+			{Category: "port", Severity: analyzer.SeverityMedium, Message: "unexpected open port: 9090"},
+		},
+		Errors: []report.RunError{{Stage: "collect", Subsystem: "packages", Message: "boom"}},
+	}
+	_, err = s.Save(old)
+	require.NoError(t, err)
+	id, err := s.Save(recent)
+	require.NoError(t, err)
+
+	runs, err := s.Runs(0)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, id, runs[0].ID, "newest first")
+	assert.Equal(t, 3, runs[0].Violations)
+	assert.Equal(t, 1, runs[0].Errors)
+	assert.Equal(t, map[string]int{"high": 1, "medium": 2}, runs[0].BySeverity)
+	assert.Empty(t, runs[1].BySeverity)
+
+	got, err := s.Report(id)
+	require.NoError(t, err)
+	assert.Equal(t, recent.Violations, got.Violations)
+
+	got, ok, err := s.Latest("web-1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, recent.Violations, got.Violations)
+	_, ok, err = s.Latest("web-2")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	var between []time.Time
+	require.NoError(t, s.ReportsBetween(now.Add(-72*time.Hour), now, func(rep report.ComplianceReport) error {
+		between = append(between, rep.GeneratedAt)
+		return nil
+	}))
+	require.Len(t, between, 2)
+	assert.True(t, between[0].Before(between[1]), "oldest first")
+	between = nil
+	require.NoError(t, s.ReportsBetween(now.Add(-time.Hour), now, func(rep report.ComplianceReport) error {
+		between = append(between, rep.GeneratedAt)
+		return nil
+	}))
+	assert.Len(t, between, 1)
+
+	n, err := s.Prune(24*time.Hour, 0)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+
+	_, err = s.Save(recent)
+	require.NoError(t, err)
+	n, err = s.Prune(0, 1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	runs, err = s.Runs(0)
+	require.NoError(t, err)
+	assert.Len(t, runs, 1)
+}
+
+func TestStore_RunsUntil(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	require.NoError(t, err)
+	defer s.Close()
+
+	now := time.Now().UTC()
+	for _, rep := range []report.ComplianceReport{
+		{GeneratedAt: now.Add(-48 * time.Hour), Hostname: "web-1"},
+		{GeneratedAt: now.Add(-24 * time.Hour), Hostname: "db-1"},
+		{GeneratedAt: now, Hostname: "web-1"},
+	} {
+		_, err = s.Save(rep)
+		require.NoError(t, err)
+	}
+
+	runs, err := s.RunsUntil("web-1", now.Add(-time.Hour), 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1, "db-1's newer run is another host's")
+	assert.Equal(t, now.Add(-48*time.Hour).UnixNano(), runs[0].GeneratedAt.UnixNano())
+	runs, err = s.RunsUntil("", now.Add(-time.Hour), 1)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, "db-1", runs[0].Hostname)
+	runs, err = s.RunsUntil("web-1", now, 0)
+	require.NoError(t, err)
+	assert.Len(t, runs, 2, "until is inclusive")
+	runs, err = s.RunsUntil("web-1", now.Add(-72*time.Hour), 0)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+}
+
+func TestStore_Anonymize(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	require.NoError(t, err)
+	defer s.Close()
+
+	now := time.Now().UTC()
+	eve := analyzer.Violation{Category: "user", Severity: analyzer.SeverityHigh, User: "eve", Message: "unexpected user present: eve"}
+	old := report.ComplianceReport{
+		GeneratedAt: now.Add(-48 * time.Hour), Hostname: "web-1",
+		Users: []collector.User{{Username: "eve"}}, Violations: []analyzer.Violation{eve},
+	}
+	recent := old
+	recent.GeneratedAt = now
+	oldID, err := s.Save(old)
+	require.NoError(t, err)
+	recentID, err := s.Save(recent)
+	require.NoError(t, err)
+
+	n, err := s.Anonymize(0)
+	require.NoError(t, err)
+	assert.Zero(t, n, "disabled")
+	n, err = s.Anonymize(24 * time.Hour)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	n, err = s.Anonymize(24 * time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, n, "already anonymized")
+
+	got, err := s.Report(oldID)
+	require.NoError(t, err)
+	assert.True(t, got.Anonymized)
+	assert.Empty(t, got.Users)
+	require.Len(t, got.Violations, 1)
+	assert.Empty(t, got.Violations[0].Message)
+	assert.Equal(t, analyzer.Fingerprint("web-1", eve), got.Violations[0].Fingerprint)
+	got, err = s.Report(recentID)
+	require.NoError(t, err)
+	assert.False(t, got.Anonymized)
+	assert.Equal(t, recent.Violations, got.Violations)
+
+	var user, message string
+	require.NoError(t, s.db.QueryRow(`SELECT user, message FROM violations WHERE report_id = ?`, oldID).Scan(&user, &message))
+	assert.Empty(t, user)
+	assert.Equal(t, analyzer.Fingerprint("web-1", eve), message)
+
+	runs, err := s.Runs(0)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, map[string]int{"high": 1}, runs[1].BySeverity, "counts survive")
+}
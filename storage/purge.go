@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"compliance-agent/report"
+)
+
+// Purge is the record of a data-subject erasure, kept in the fleet
+// database for the privacy team: who asked for what to be erased, why,
+// and how much was.
+type Purge struct {
+	ID       int64     `json:"id"`
+	Time     time.Time `json:"time"`
+	Operator string    `json:"operator"`
+	Reason   string    `json:"reason,omitempty"`
+	// Agent and Hostname are the host whose data was erased, or User
+	// the user whose data was removed from every report.
+	Agent    string `json:"agent,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	User     string `json:"user,omitempty"`
+	// ReportsDeleted counts the reports removed outright and
+	// ReportsRedacted those rewritten without the user's data, with
+	// Redactions the objects and strings removed from them.
+	ReportsDeleted  int64 `json:"reports_deleted"`
+	ReportsRedacted int64 `json:"reports_redacted"`
+	Redactions      int   `json:"redactions,omitempty"`
+}
+
+// EachReport calls fn with every stored report, as stored, by ID. fn
+// must not use the store.
+func (s *FleetStore) EachReport(fn func(id int64, agentID string, body []byte) error) error {
+	rows, err := s.db.Query(`SELECT id, agent_id, report_json FROM fleet_reports ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var agentID string
+		var body []byte
+		if err := rows.Scan(&id, &agentID, &body); err != nil {
+			return err
+		}
+		if err := fn(id, agentID, body); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// PurgeAgent deletes an agent and every report it uploaded, and records
+// rec, filled in with what was deleted, in the same transaction. The
+// agent must enroll again to upload.
+func (s *FleetStore) PurgeAgent(agentID string, rec Purge) (Purge, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return rec, err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`DELETE FROM fleet_reports WHERE agent_id = ?`, agentID)
+	if err != nil {
+		return rec, fmt.Errorf("purge reports: %w", err)
+	}
+	rec.ReportsDeleted, _ = res.RowsAffected()
+	res, err = tx.Exec(`DELETE FROM agents WHERE id = ?`, agentID)
+	if err != nil {
+		return rec, fmt.Errorf("purge agent: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return rec, fmt.Errorf("purge agent: no agent %s", agentID)
+	}
+	rec.Agent = agentID
+	if rec, err = recordPurge(tx, rec); err != nil {
+		return rec, err
+	}
+	return rec, tx.Commit()
+}
+
+// RedactReports rewrites every stored report with redact, which returns
+// the new report and how much it removed (zero to leave the report
+// alone), and records rec, filled in with the counts, in the same
+// transaction. Violation counts and risk are recomputed from the new
+// report.
+func (s *FleetStore) RedactReports(redact func(body []byte) ([]byte, int, error), rec Purge) (Purge, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return rec, err
+	}
+	defer tx.Rollback()
+	// Read everything first: the one connection can't update while a
+	// query is open on it.
+	rows, err := tx.Query(`SELECT id, report_json FROM fleet_reports ORDER BY id`)
+	if err != nil {
+		return rec, err
+	}
+	bodies := map[int64][]byte{}
+	for rows.Next() {
+		var id int64
+		var body []byte
+		if err := rows.Scan(&id, &body); err != nil {
+			rows.Close()
+			return rec, err
+		}
+		bodies[id] = body
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return rec, err
+	}
+	for id, body := range bodies {
+		out, n, err := redact(body)
+		if err != nil {
+			return rec, fmt.Errorf("report %d: %w", id, err)
+		}
+		if n == 0 {
+			continue
+		}
+		var rep report.ComplianceReport
+		if err := json.Unmarshal(out, &rep); err != nil {
+			return rec, fmt.Errorf("report %d: %w", id, err)
+		}
+		sev, maxRisk := violationSummary(rep)
+		if _, err := tx.Exec(`UPDATE fleet_reports SET report_json = ?, violation_count = ?, error_count = ?,
+			by_severity = ?, max_risk = ? WHERE id = ?`,
+			out, len(rep.Violations), len(rep.Errors), sev, maxRisk, id); err != nil {
+			return rec, fmt.Errorf("redact report %d: %w", id, err)
+		}
+		rec.ReportsRedacted++
+		rec.Redactions += n
+	}
+	if rec, err = recordPurge(tx, rec); err != nil {
+		return rec, err
+	}
+	return rec, tx.Commit()
+}
+
+// recordPurge stores rec, stamped with the current time, and returns it
+// with its ID.
+func recordPurge(tx *sql.Tx, rec Purge) (Purge, error) {
+	rec.Time = time.Now().UTC()
+	body, err := json.Marshal(rec)
+	if err != nil {
+		return rec, err
+	}
+	res, err := tx.Exec(`INSERT INTO purges (purged_at, record_json) VALUES (?, ?)`, rec.Time.UnixNano(), string(body))
+	if err != nil {
+		return rec, fmt.Errorf("record purge: %w", err)
+	}
+	rec.ID, err = res.LastInsertId()
+	return rec, err
+}
+
+// Purges lists the recorded purges, oldest first.
+func (s *FleetStore) Purges() ([]Purge, error) {
+	rows, err := s.db.Query(`SELECT id, record_json FROM purges ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var purges []Purge
+	for rows.Next() {
+		var id int64
+		var body string
+		if err := rows.Scan(&id, &body); err != nil {
+			return nil, err
+		}
+		var p Purge
+		if err := json.Unmarshal([]byte(body), &p); err != nil {
+			return nil, fmt.Errorf("purge %d: %w", id, err)
+		}
+		p.ID = id
+		purges = append(purges, p)
+	}
+	return purges, rows.Err()
+}
@@ -0,0 +1,85 @@
+//go:build !no_history && !slim
+
+package storage
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFleetStore_Purge(t *testing.T) {
+	s, err := OpenFleet(filepath.Join(t.TempDir(), "fleet.db"))
+	require.NoError(t, err)
+	defer s.Close()
+
+	now := time.Now().UTC()
+	require.NoError(t, s.Enroll(Agent{ID: "a1", Hostname: "web-1", EnrolledAt: now}, "hash1"))
+	require.NoError(t, s.Enroll(Agent{ID: "a2", Hostname: "laptop-7", EnrolledAt: now}, "hash2"))
+	for i := 0; i < 2; i++ {
+		_, err = s.SaveReport("a1", report.ComplianceReport{GeneratedAt: now, Hostname: "web-1"})
+		require.NoError(t, err)
+	}
+	eve := report.ComplianceReport{
+		GeneratedAt: now, Hostname: "laptop-7",
+		Violations: []analyzer.Violation{
+			{Category: "user", Severity: analyzer.SeverityHigh, User: "eve", Message: "unexpected user present: eve", Risk: 9},
+			{Category: "port", Severity: analyzer.SeverityMedium, Message: "unexpected open port: 8080", Risk: 4},
+		},
+	}
+	id, err := s.SaveReport("a2", eve)
+	require.NoError(t, err)
+
+	var seen []int64
+	require.NoError(t, s.EachReport(func(id int64, agentID string, body []byte) error {
+		seen = append(seen, id)
+		return nil
+	}))
+	assert.Len(t, seen, 3)
+
+	rec, err := s.PurgeAgent("a1", Purge{Operator: "dpo", Reason: "DSR-12", Hostname: "web-1"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, rec.ReportsDeleted)
+	assert.Equal(t, "a1", rec.Agent)
+	assert.NotZero(t, rec.ID)
+	_, ok, err := s.Agent("a1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, err = s.PurgeAgent("a1", Purge{})
+	assert.Error(t, err, "already gone")
+
+	// Drop the first violation, as the privacy package would for eve.
+	rec, err = s.RedactReports(func(body []byte) ([]byte, int, error) {
+		if !bytes.Contains(body, []byte(`"eve"`)) {
+			return body, 0, nil
+		}
+		rep := eve
+		rep.Violations = rep.Violations[1:]
+		b, err := rep.ToJSON()
+		return b, 1, err
+	}, Purge{Operator: "dpo", Reason: "DSR-13", User: "eve"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, rec.ReportsRedacted)
+	assert.Equal(t, 1, rec.Redactions)
+
+	runs, err := s.AgentRuns("a2", 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, id, runs[0].ID)
+	assert.Equal(t, 1, runs[0].Violations, "counts follow the new report")
+	assert.Equal(t, map[string]int{"medium": 1}, runs[0].BySeverity)
+	assert.Equal(t, 4.0, runs[0].MaxRisk)
+
+	purges, err := s.Purges()
+	require.NoError(t, err)
+	require.Len(t, purges, 2)
+	assert.Equal(t, "DSR-12", purges[0].Reason)
+	assert.Equal(t, "eve", purges[1].User)
+	assert.WithinDuration(t, time.Now(), purges[1].Time, time.Minute)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+	"time"
+
+	"compliance-agent/errcode"
+	"compliance-agent/storage"
+	"compliance-agent/summary"
+)
+
+// cmdSummary implements `compliance-agent summary`: the executive summary
+// of the last period, for this host from the report history or, with
+// -fleet, for every host on the fleet server.
+func cmdSummary(args []string) {
+	fs := flag.NewFlagSet("summary", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to YAML config (optional)")
+	period := fs.Duration("period", 0, "How far back to look (overrides config summary.period)")
+	until := fs.String("to", "", "End of the period, RFC 3339 or YYYY-MM-DD (default now)")
+	fleet := fs.Bool("fleet", false, "Summarize the fleet server's database (server.db_path) instead of the local history")
+	dbPath := fs.String("db", "", "Database to read (overrides history.path, or server.db_path with -fleet)")
+	format := fs.String("format", "markdown", "Output format: markdown, html, email or json")
+	outPath := fs.String("o", "", "Write to this file instead of stdout")
+	send := fs.Bool("send", false, "Mail the summary as configured in summary.email")
+	parseFlags(fs, args)
+
+	cfg := loadConfig(*configPath)
+	if *period > 0 {
+		cfg.Summary.Period = *period
+	}
+	to := time.Now().UTC()
+	if *until != "" {
+		var err error
+		if to, err = time.Parse(time.RFC3339, *until); err != nil {
+			if to, err = time.Parse(time.DateOnly, *until); err != nil {
+				usageError("-to: want RFC 3339 or YYYY-MM-DD, got %q", *until)
+			}
+		}
+	}
+	opts, err := summary.OptionsFor(cfg.Summary, to)
+	if err != nil {
+		fatal(err, "config")
+	}
+
+	var sum summary.Summary
+	if *fleet {
+		path := cfg.Server.DBPath
+		if *dbPath != "" {
+			path = *dbPath
+		}
+		if _, err := os.Stat(path); err != nil {
+			fatal(errcode.Errorf(errcode.CollectorUnavailable, "no fleet database at %s: %w", path, err), "summary")
+		}
+		store, err := storage.OpenFleet(path)
+		if err != nil {
+			fatal(err, "summary")
+		}
+		defer store.Close()
+		sum, err = summary.ForFleet(store, opts)
+		if err != nil {
+			fatal(err, "summary")
+		}
+	} else {
+		path := cfg.History.Path
+		if *dbPath != "" {
+			path = *dbPath
+		}
+		if path == "" {
+			usageError("report history is disabled (history.path is empty)")
+		}
+		if _, err := os.Stat(path); err != nil {
+			fatal(errcode.Errorf(errcode.CollectorUnavailable, "no report history at %s: %w", path, err), "summary")
+		}
+		store, err := storage.Open(path)
+		if err != nil {
+			fatal(err, "summary")
+		}
+		defer store.Close()
+		sum, err = summary.ForHost(store, opts)
+		if err != nil {
+			fatal(err, "summary")
+		}
+	}
+
+	if *send {
+		if err := summary.Send(cfg.Summary.Email, sum); err != nil {
+			fatal(errcode.New(errcode.NotifierFailure, err), "summary email")
+		}
+		slog.Info("summary mailed", "recipients", len(cfg.Summary.Email.To))
+		if *outPath == "" {
+			return
+		}
+	}
+
+	var out []byte
+	switch *format {
+	case "markdown", "md":
+		out, err = sum.Markdown()
+	case "html":
+		out, err = sum.HTML()
+	case "email":
+		out, err = sum.Email(cfg.Summary.Email.From, cfg.Summary.Email.To)
+	case "json":
+		out, err = json.MarshalIndent(sum, "", "  ")
+		out = append(out, '\n')
+	default:
+		usageError("unknown -format %q (want markdown, html, email or json)", *format)
+	}
+	if err != nil {
+		fatal(err, "render summary")
+	}
+	if *outPath == "" {
+		os.Stdout.Write(out)
+		return
+	}
+	if err := os.WriteFile(*outPath, out, 0o644); err != nil {
+		fatal(err, "write summary")
+	}
+	slog.Info("summary written", "path", *outPath)
+}
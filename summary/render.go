@@ -0,0 +1,278 @@
+package summary
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	htmltemplate "html/template"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"compliance-agent/config"
+)
+
+// Headline is the summary's opening sentence: the score and how it moved,
+// then what needs attention.
+func (s Summary) Headline() string {
+	var b strings.Builder
+	if s.Hosts == 0 {
+		fmt.Fprintf(&b, "No scans were reported between %s and %s.", day(s.From), day(s.To))
+		return b.String()
+	}
+	subject := "This host"
+	if s.Scope == "fleet" {
+		subject = "The fleet"
+	}
+	switch change := s.Change(); {
+	case s.PreviousScore == nil:
+		fmt.Fprintf(&b, "%s scores %.1f out of 100.", subject, s.Score)
+	case change > 0:
+		fmt.Fprintf(&b, "%s's compliance score rose %.1f points to %.1f.", subject, change, s.Score)
+	case change < 0:
+		fmt.Fprintf(&b, "%s's compliance score fell %.1f points to %.1f.", subject, -change, s.Score)
+	default:
+		fmt.Fprintf(&b, "%s's compliance score held at %.1f.", subject, s.Score)
+	}
+	if s.Scope == "fleet" {
+		fmt.Fprintf(&b, " %s reported, with %s open.", plural(s.Hosts, "host"), plural(s.Violations, "violation"))
+	} else {
+		fmt.Fprintf(&b, " It has %s open.", plural(s.Violations, "violation"))
+	}
+	if n := len(s.NewlyFailing); n > 0 && s.Scope == "fleet" {
+		fmt.Fprintf(&b, " %s started failing.", plural(n, "host"))
+	} else if n > 0 {
+		b.WriteString(" It started failing this period.")
+	}
+	if n := len(s.SLABreaches); n > 0 {
+		fmt.Fprintf(&b, " %s past SLA.", plural(n, "violation"))
+	} else {
+		b.WriteString(" Nothing is past its SLA.")
+	}
+	return b.String()
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func day(t time.Time) string { return t.UTC().Format("2006-01-02") }
+
+// days renders a duration in whole days, or hours under two days.
+func days(d time.Duration) string {
+	if d < 48*time.Hour {
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+var funcs = map[string]any{
+	"day":   day,
+	"days":  days,
+	"sign":  func(f float64) string { return fmt.Sprintf("%+.1f", f) },
+	"title": title,
+}
+
+func title(scope string) string {
+	if scope == "fleet" {
+		return "Fleet compliance summary"
+	}
+	return "Host compliance summary"
+}
+
+var markdownTemplate = template.Must(template.New("summary").Funcs(funcs).Parse(`# {{title .Scope}}, {{day .From}} to {{day .To}}
+
+{{.Headline}}
+
+## Compliance score
+
+| | Score |
+|---|---|
+| Start of period | {{with .PreviousScore}}{{printf "%.1f" .}}{{else}}—{{end}} |
+| End of period | {{printf "%.1f" .Score}} |
+| Change | {{if .PreviousScore}}{{sign .Change}}{{else}}—{{end}} |
+
+## Top recurring rules
+{{if .TopRules}}
+| Rule | Worst severity | Hosts | Scans |
+|---|---|---|---|
+{{range .TopRules}}| {{.Rule}} | {{.Severity}} | {{.Hosts}} | {{.Scans}} |
+{{end}}{{else}}
+No rule failed this period.
+{{end}}
+## Newly failing hosts
+{{if .NewlyFailing}}
+| Host | Score | Violations | Worst |
+|---|---|---|---|
+{{range .NewlyFailing}}| {{.Hostname}} | {{printf "%.1f" .Score}} | {{.Violations}} | {{.Worst}} |
+{{end}}{{else}}
+No host started failing.
+{{end}}
+## SLA breaches
+{{if .SLABreaches}}
+| Host | Severity | Rule | Violation | Open | SLA |
+|---|---|---|---|---|---|
+{{range .SLABreaches}}| {{.Hostname}} | {{.Severity}} | {{.Category}} | {{.Message}} | {{days .Open}} | {{days .SLA}} |
+{{end}}{{else}}
+No open violation is past its SLA.
+{{end}}`))
+
+// Markdown renders the summary as Markdown.
+func (s Summary) Markdown() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := markdownTemplate.Execute(&buf, s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// htmlTemplate is self-contained, like the report's, so it survives being
+// emailed.
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("summary").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{title .Scope}}</title>
+<style>
+body { font-family: -apple-system, Segoe UI, Helvetica, Arial, sans-serif; margin: 2rem; color: #222; max-width: 60rem; }
+.lead { font-size: 1.1rem; }
+.cards { display: flex; gap: 1rem; flex-wrap: wrap; margin: 1rem 0 2rem; }
+.card { border: 1px solid #ddd; border-radius: 6px; padding: .6rem 1rem; min-width: 8rem; }
+.card b { display: block; font-size: 1.4rem; }
+.ok { color: #2e7d32; } .bad { color: #c62828; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5rem; }
+th, td { border: 1px solid #ddd; padding: .35rem .6rem; text-align: left; font-size: .9rem; vertical-align: top; }
+th { background: #f5f5f5; }
+</style>
+</head>
+<body>
+<h1>{{title .Scope}}</h1>
+<div>{{day .From}} to {{day .To}}</div>
+<p class="lead">{{.Headline}}</p>
+
+<div class="cards">
+  <div class="card"><b>{{printf "%.1f" .Score}}</b>score</div>
+  <div class="card"><b class="{{if lt .Change 0.0}}bad{{else}}ok{{end}}">{{if .PreviousScore}}{{sign .Change}}{{else}}—{{end}}</b>change</div>
+  {{if eq .Scope "fleet"}}<div class="card"><b>{{.Hosts}}</b>hosts</div>{{end}}
+  <div class="card"><b class="{{if .NewlyFailing}}bad{{else}}ok{{end}}">{{len .NewlyFailing}}</b>newly failing</div>
+  <div class="card"><b class="{{if .SLABreaches}}bad{{else}}ok{{end}}">{{len .SLABreaches}}</b>past SLA</div>
+</div>
+
+<h2>Top recurring rules</h2>
+{{if .TopRules}}<table>
+<tr><th>Rule</th><th>Worst severity</th><th>Hosts</th><th>Scans</th></tr>
+{{range .TopRules}}<tr><td>{{.Rule}}</td><td>{{.Severity}}</td><td>{{.Hosts}}</td><td>{{.Scans}}</td></tr>
+{{end}}</table>{{else}}<p class="ok">No rule failed this period.</p>{{end}}
+
+<h2>Newly failing hosts</h2>
+{{if .NewlyFailing}}<table>
+<tr><th>Host</th><th>Score</th><th>Violations</th><th>Worst</th></tr>
+{{range .NewlyFailing}}<tr><td>{{.Hostname}}</td><td>{{printf "%.1f" .Score}}</td><td>{{.Violations}}</td><td>{{.Worst}}</td></tr>
+{{end}}</table>{{else}}<p class="ok">No host started failing.</p>{{end}}
+
+<h2>SLA breaches</h2>
+{{if .SLABreaches}}<table>
+<tr><th>Host</th><th>Severity</th><th>Rule</th><th>Violation</th><th>Open</th><th>SLA</th></tr>
+{{range .SLABreaches}}<tr><td>{{.Hostname}}</td><td>{{.Severity}}</td><td>{{.Category}}</td><td>{{.Message}}</td><td>{{days .Open}}</td><td>{{days .SLA}}</td></tr>
+{{end}}</table>{{else}}<p class="ok">No open violation is past its SLA.</p>{{end}}
+</body>
+</html>
+`))
+
+// HTML renders the summary as a self-contained HTML page.
+func (s Summary) HTML() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Subject is the summary email's subject line.
+func (s Summary) Subject() string {
+	subject := fmt.Sprintf("%s, %s to %s: score %.1f", title(s.Scope), day(s.From), day(s.To), s.Score)
+	if s.PreviousScore != nil {
+		subject += fmt.Sprintf(" (%+.1f)", s.Change())
+	}
+	return subject
+}
+
+// Email renders the summary as a MIME message from from to to, with the
+// Markdown as its plain-text part and the HTML as its rich one.
+func (s Summary) Email(from string, to []string) ([]byte, error) {
+	text, err := s.Markdown()
+	if err != nil {
+		return nil, err
+	}
+	html, err := s.HTML()
+	if err != nil {
+		return nil, err
+	}
+	var nonce [12]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	boundary := "summary-" + hex.EncodeToString(nonce[:])
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", s.Subject())
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	for _, part := range []struct {
+		contentType string
+		body        []byte
+	}{{"text/plain", text}, {"text/html", html}} {
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		fmt.Fprintf(&buf, "Content-Type: %s; charset=utf-8\r\n", part.contentType)
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		qp := quotedprintable.NewWriter(&buf)
+		if _, err := qp.Write(part.body); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+	return buf.Bytes(), nil
+}
+
+// Send mails the summary as configured. It authenticates only when a
+// username is set; the password falls back to SMTP_PASSWORD.
+func Send(cfg config.EmailConfig, s Summary) error {
+	if cfg.SMTPAddr == "" || cfg.From == "" || len(cfg.To) == 0 {
+		return fmt.Errorf("summary.email needs smtp_addr, from and to")
+	}
+	msg, err := s.Email(cfg.From, cfg.To)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		password := cfg.Password
+		if password == "" {
+			password = os.Getenv("SMTP_PASSWORD")
+		}
+		host, _, err := net.SplitHostPort(cfg.SMTPAddr)
+		if err != nil {
+			return fmt.Errorf("summary.email.smtp_addr: %w", err)
+		}
+		auth = smtp.PlainAuth("", cfg.Username, password, host)
+	}
+	if err := smtp.SendMail(cfg.SMTPAddr, auth, cfg.From, cfg.To, msg); err != nil {
+		return fmt.Errorf("send summary email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,382 @@
+// Package summary builds the short executive summary of a period's scans
+// — for one host from its report history, or for the fleet from the
+// fleet server's database: how the compliance score moved, which rules
+// keep failing, which hosts started failing and which violations are
+// past their SLA. Render turns it into Markdown, HTML or an email.
+package summary
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+	"compliance-agent/report"
+	"compliance-agent/storage"
+)
+
+// penalties are the score points each violation costs, by severity.
+var penalties = map[analyzer.Severity]float64{
+	analyzer.SeverityCritical: 10,
+	analyzer.SeverityHigh:     5,
+	analyzer.SeverityMedium:   2,
+	analyzer.SeverityLow:      1,
+}
+
+// Score rates a report from 100 (no violations) down towards 0 as
+// 100 × 50 / (50 + penalty points), so 50 points halve it. A critical
+// violation costs 10 points, high 5, medium 2, low 1 and info none.
+func Score(rep report.ComplianceReport) float64 {
+	var points float64
+	for _, v := range rep.Violations {
+		points += penalties[severityOf(v)]
+	}
+	return round(100 * 50 / (50 + points))
+}
+
+func round(f float64) float64 { return math.Round(f*10) / 10 }
+
+// Options are what a summary covers.
+type Options struct {
+	From, To time.Time
+	// Top is how many recurring rules to list.
+	Top int
+	// FailSeverity is the least severe violation that fails a host.
+	FailSeverity analyzer.Severity
+	// SLA is how long a violation of each severity may stay open.
+	SLA map[analyzer.Severity]time.Duration
+}
+
+// OptionsFor builds options for the period ending at to from config.
+func OptionsFor(cfg config.SummaryConfig, to time.Time) (Options, error) {
+	o := Options{To: to, Top: cfg.Top, SLA: map[analyzer.Severity]time.Duration{}}
+	period := cfg.Period
+	if period <= 0 {
+		period = 7 * 24 * time.Hour
+	}
+	o.From = to.Add(-period)
+	if o.Top <= 0 {
+		o.Top = 5
+	}
+	o.FailSeverity = analyzer.SeverityHigh
+	if cfg.FailSeverity != "" {
+		sev, err := analyzer.ParseSeverity(cfg.FailSeverity)
+		if err != nil {
+			return o, fmt.Errorf("summary.fail_severity: %w", err)
+		}
+		o.FailSeverity = sev
+	}
+	for name, d := range cfg.SLA {
+		sev, err := analyzer.ParseSeverity(name)
+		if err != nil {
+			return o, fmt.Errorf("summary.sla: %w", err)
+		}
+		if d > 0 {
+			o.SLA[sev] = d
+		}
+	}
+	return o, nil
+}
+
+// lookback is how far before From reports are read: far enough to date
+// every violation that could be past its SLA.
+func (o Options) lookback() time.Duration {
+	var d time.Duration
+	for _, sla := range o.SLA {
+		d = max(d, sla)
+	}
+	return d
+}
+
+// Summary is one period's executive summary.
+type Summary struct {
+	// Scope is "host" or "fleet".
+	Scope string    `json:"scope"`
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+	// Hosts is how many hosts reported during the period.
+	Hosts int `json:"hosts"`
+	// Score is the average host score at the end of the period and
+	// PreviousScore at its start, over hosts that had reported by then.
+	Score         float64  `json:"score"`
+	PreviousScore *float64 `json:"previous_score,omitempty"`
+	Violations    int      `json:"violations"`
+	// TopRules are the rules failing on the most hosts and scans.
+	TopRules     []Rule   `json:"top_rules"`
+	NewlyFailing []Host   `json:"newly_failing"`
+	SLABreaches  []Breach `json:"sla_breaches"`
+}
+
+// Change is the score change over the period, zero without a previous
+// score.
+func (s Summary) Change() float64 {
+	if s.PreviousScore == nil {
+		return 0
+	}
+	return round(s.Score - *s.PreviousScore)
+}
+
+// Rule is a violation category and how widely it failed in the period.
+type Rule struct {
+	Rule     string            `json:"rule"`
+	Severity analyzer.Severity `json:"severity"` // the worst seen
+	Hosts    int               `json:"hosts"`
+	Scans    int               `json:"scans"`
+}
+
+// Host is a host that passed at the start of the period and fails at its
+// end.
+type Host struct {
+	Hostname   string            `json:"hostname"`
+	Score      float64           `json:"score"`
+	Violations int               `json:"violations"`
+	Worst      analyzer.Severity `json:"worst"`
+}
+
+// Breach is a violation open for longer than its severity's SLA.
+type Breach struct {
+	Hostname  string            `json:"hostname"`
+	Category  string            `json:"category"`
+	Severity  analyzer.Severity `json:"severity"`
+	Message   string            `json:"message"`
+	FirstSeen time.Time         `json:"first_seen"`
+	Open      time.Duration     `json:"open"`
+	SLA       time.Duration     `json:"sla"`
+}
+
+// hostState is one host's reports as they are added.
+type hostState struct {
+	// baseline is the last report at or before From; first and latest
+	// are the period's first and last.
+	baseline, first, latest *report.ComplianceReport
+	// firstSeen dates each open violation by fingerprint (see
+	// analyzer.Fingerprint), which anonymized reports keep; one that
+	// disappears is forgotten, so it is dated afresh if it returns.
+	firstSeen map[string]time.Time
+	// categories counts the period's scans each category failed in.
+	categories map[string]int
+	worst      map[string]analyzer.Severity
+}
+
+// Builder accumulates reports into a summary.
+type Builder struct {
+	opts  Options
+	scope string
+	hosts map[string]*hostState
+	order []string
+}
+
+// NewBuilder starts a summary; scope is "host" or "fleet".
+func NewBuilder(scope string, opts Options) *Builder {
+	return &Builder{opts: opts, scope: scope, hosts: map[string]*hostState{}}
+}
+
+// Add records a report of host (any stable ID). Each host's reports must
+// be added oldest first; those after To are ignored.
+func (b *Builder) Add(host string, rep report.ComplianceReport) {
+	if rep.GeneratedAt.After(b.opts.To) {
+		return
+	}
+	h := b.hosts[host]
+	if h == nil {
+		h = &hostState{firstSeen: map[string]time.Time{}, categories: map[string]int{}, worst: map[string]analyzer.Severity{}}
+		b.hosts[host] = h
+		b.order = append(b.order, host)
+	}
+
+	open := make(map[string]time.Time, len(rep.Violations))
+	for _, v := range rep.Violations {
+		key := analyzer.Fingerprint(rep.Hostname, v)
+		if t, ok := h.firstSeen[key]; ok {
+			open[key] = t
+		} else {
+			open[key] = rep.GeneratedAt
+		}
+	}
+	h.firstSeen = open
+
+	if !rep.GeneratedAt.After(b.opts.From) {
+		h.baseline = &rep
+		return
+	}
+	if h.first == nil {
+		h.first = &rep
+	}
+	h.latest = &rep
+	seen := map[string]bool{}
+	for _, v := range rep.Violations {
+		cat := v.Category
+		if cat == "" {
+			cat = "unknown"
+		}
+		if sev := severityOf(v); sev.Rank() > h.worst[cat].Rank() || h.worst[cat] == "" {
+			h.worst[cat] = sev
+		}
+		if !seen[cat] {
+			seen[cat] = true
+			h.categories[cat]++
+		}
+	}
+}
+
+// Summary computes the summary of what was added.
+func (b *Builder) Summary() Summary {
+	s := Summary{
+		Scope:        b.scope,
+		From:         b.opts.From,
+		To:           b.opts.To,
+		TopRules:     []Rule{},
+		NewlyFailing: []Host{},
+		SLABreaches:  []Breach{},
+	}
+	var scoreSum, prevSum float64
+	var prevHosts int
+	rules := map[string]*Rule{}
+	for _, id := range b.order {
+		h := b.hosts[id]
+		if h.latest == nil {
+			continue // nothing in the period
+		}
+		s.Hosts++
+		score := Score(*h.latest)
+		scoreSum += score
+		s.Violations += len(h.latest.Violations)
+		if h.baseline != nil {
+			prevHosts++
+			prevSum += Score(*h.baseline)
+		}
+
+		for cat, scans := range h.categories {
+			r := rules[cat]
+			if r == nil {
+				r = &Rule{Rule: cat}
+				rules[cat] = r
+			}
+			r.Hosts++
+			r.Scans += scans
+			if sev := h.worst[cat]; sev.Rank() > r.Severity.Rank() || r.Severity == "" {
+				r.Severity = sev
+			}
+		}
+
+		// A host that reported for the first time this period is
+		// compared with its first report.
+		start := h.baseline
+		if start == nil {
+			start = h.first
+		}
+		if start != nil && !b.fails(*start) && b.fails(*h.latest) {
+			s.NewlyFailing = append(s.NewlyFailing, Host{
+				Hostname:   h.latest.Hostname,
+				Score:      score,
+				Violations: len(h.latest.Violations),
+				Worst:      worst(h.latest.Violations),
+			})
+		}
+
+		for _, v := range h.latest.Violations {
+			sev := severityOf(v)
+			sla, ok := b.opts.SLA[sev]
+			if !ok {
+				continue
+			}
+			first := h.firstSeen[analyzer.Fingerprint(h.latest.Hostname, v)]
+			if open := b.opts.To.Sub(first); open > sla {
+				s.SLABreaches = append(s.SLABreaches, Breach{
+					Hostname:  h.latest.Hostname,
+					Category:  v.Category,
+					Severity:  sev,
+					Message:   cmp.Or(v.Message, v.Fingerprint),
+					FirstSeen: first,
+					Open:      open.Truncate(time.Hour),
+					SLA:       sla,
+				})
+			}
+		}
+	}
+	if s.Hosts > 0 {
+		s.Score = round(scoreSum / float64(s.Hosts))
+	}
+	if prevHosts > 0 {
+		prev := round(prevSum / float64(prevHosts))
+		s.PreviousScore = &prev
+	}
+
+	for _, r := range rules {
+		s.TopRules = append(s.TopRules, *r)
+	}
+	sort.Slice(s.TopRules, func(i, j int) bool {
+		a, b := s.TopRules[i], s.TopRules[j]
+		if a.Hosts != b.Hosts {
+			return a.Hosts > b.Hosts
+		}
+		if a.Scans != b.Scans {
+			return a.Scans > b.Scans
+		}
+		return a.Rule < b.Rule
+	})
+	if len(s.TopRules) > b.opts.Top {
+		s.TopRules = s.TopRules[:b.opts.Top]
+	}
+	sort.Slice(s.NewlyFailing, func(i, j int) bool { return s.NewlyFailing[i].Score < s.NewlyFailing[j].Score })
+	sort.Slice(s.SLABreaches, func(i, j int) bool {
+		a, b := s.SLABreaches[i], s.SLABreaches[j]
+		if a.Severity != b.Severity {
+			return a.Severity.Rank() > b.Severity.Rank()
+		}
+		if a.Open != b.Open {
+			return a.Open > b.Open
+		}
+		return a.Hostname < b.Hostname
+	})
+	return s
+}
+
+func (b *Builder) fails(rep report.ComplianceReport) bool {
+	for _, v := range rep.Violations {
+		if severityOf(v).Rank() >= b.opts.FailSeverity.Rank() {
+			return true
+		}
+	}
+	return false
+}
+
+func severityOf(v analyzer.Violation) analyzer.Severity {
+	if v.Severity == "" {
+		return analyzer.SeverityMedium
+	}
+	return v.Severity
+}
+
+func worst(violations []analyzer.Violation) analyzer.Severity {
+	var w analyzer.Severity
+	for _, v := range violations {
+		if sev := severityOf(v); w == "" || sev.Rank() > w.Rank() {
+			w = sev
+		}
+	}
+	return w
+}
+
+// ForHost summarizes the local report history.
+func ForHost(store *storage.Store, opts Options) (Summary, error) {
+	b := NewBuilder("host", opts)
+	err := store.ReportsBetween(opts.From.Add(-opts.lookback()), opts.To, func(rep report.ComplianceReport) error {
+		b.Add(rep.Hostname, rep)
+		return nil
+	})
+	return b.Summary(), err
+}
+
+// ForFleet summarizes every host on the fleet server.
+func ForFleet(store *storage.FleetStore, opts Options) (Summary, error) {
+	b := NewBuilder("fleet", opts)
+	err := store.ReportsBetween(opts.From.Add(-opts.lookback()), opts.To, func(agentID string, rep report.ComplianceReport) error {
+		b.Add(agentID, rep)
+		return nil
+	})
+	return b.Summary(), err
+}
@@ -0,0 +1,143 @@
+package summary
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/config"
+	"compliance-agent/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func violation(cat string, sev analyzer.Severity, msg string) analyzer.Violation {
+	return analyzer.Violation{Category: cat, Severity: sev, Message: msg}
+}
+
+func TestScore(t *testing.T) {
+	assert.Equal(t, 100.0, Score(report.ComplianceReport{}))
+	rep := report.ComplianceReport{Violations: []analyzer.Violation{
+		violation("user", analyzer.SeverityCritical, "a"),
+		violation("port", analyzer.SeverityHigh, "b"),
+		violation("port", analyzer.SeverityInfo, "c"),
+		{Category: "x"}, // medium
+	}}
+	assert.Equal(t, 74.6, Score(rep), "100 × 50 / 67")
+}
+
+func TestOptionsFor(t *testing.T) {
+	to := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	o, err := OptionsFor(config.Default().Summary, to)
+	require.NoError(t, err)
+	assert.Equal(t, to.Add(-7*24*time.Hour), o.From)
+	assert.Equal(t, 5, o.Top)
+	assert.Equal(t, analyzer.SeverityHigh, o.FailSeverity)
+	assert.Equal(t, 72*time.Hour, o.SLA[analyzer.SeverityCritical])
+	assert.Equal(t, 30*24*time.Hour, o.lookback())
+
+	_, err = OptionsFor(config.SummaryConfig{SLA: map[string]time.Duration{"urgent": time.Hour}}, to)
+	assert.ErrorContains(t, err, "summary.sla")
+}
+
+func TestBuilder(t *testing.T) {
+	to := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	from := to.Add(-7 * 24 * time.Hour)
+	b := NewBuilder("fleet", Options{
+		From: from, To: to, Top: 2, FailSeverity: analyzer.SeverityHigh,
+		SLA: map[analyzer.Severity]time.Duration{analyzer.SeverityHigh: 7 * 24 * time.Hour},
+	})
+
+	eve := violation("user", analyzer.SeverityHigh, "unexpected user present: eve")
+	port := violation("port", analyzer.SeverityMedium, "unexpected open port: 8080")
+
+	// web-1 passed at the start of the period and fails at its end.
+	b.Add("a1", report.ComplianceReport{Hostname: "web-1", GeneratedAt: to.Add(-10 * 24 * time.Hour), Violations: []analyzer.Violation{port}})
+	b.Add("a1", report.ComplianceReport{Hostname: "web-1", GeneratedAt: to.Add(-9 * 24 * time.Hour), Violations: []analyzer.Violation{port, eve}})
+	b.Add("a1", report.ComplianceReport{Hostname: "web-1", GeneratedAt: to.Add(-8 * 24 * time.Hour), Violations: []analyzer.Violation{port}})
+	b.Add("a1", report.ComplianceReport{Hostname: "web-1", GeneratedAt: to.Add(-time.Hour), Violations: []analyzer.Violation{port, eve}})
+	// db-1 is new this period and was failing from its first scan.
+	b.Add("a2", report.ComplianceReport{Hostname: "db-1", GeneratedAt: to.Add(-2 * time.Hour), Violations: []analyzer.Violation{eve}})
+	b.Add("a2", report.ComplianceReport{Hostname: "db-1", GeneratedAt: to.Add(-time.Hour), Violations: []analyzer.Violation{eve}})
+	// Reports after the period are ignored.
+	b.Add("a2", report.ComplianceReport{Hostname: "db-1", GeneratedAt: to.Add(time.Hour)})
+	// gone-1 stopped reporting before the period.
+	b.Add("a3", report.ComplianceReport{Hostname: "gone-1", GeneratedAt: from.Add(-time.Hour)})
+
+	s := b.Summary()
+	assert.Equal(t, 2, s.Hosts)
+	assert.Equal(t, 3, s.Violations)
+	assert.Equal(t, 89.3, s.Score, "(87.7 + 90.9) / 2")
+	require.NotNil(t, s.PreviousScore)
+	assert.Equal(t, 96.2, *s.PreviousScore, "web-1 before the period")
+	assert.Equal(t, -6.9, s.Change())
+
+	require.Len(t, s.TopRules, 2)
+	assert.Equal(t, Rule{Rule: "user", Severity: analyzer.SeverityHigh, Hosts: 2, Scans: 3}, s.TopRules[0])
+	assert.Equal(t, Rule{Rule: "port", Severity: analyzer.SeverityMedium, Hosts: 1, Scans: 1}, s.TopRules[1])
+
+	require.Len(t, s.NewlyFailing, 1)
+	assert.Equal(t, "web-1", s.NewlyFailing[0].Hostname)
+	assert.Equal(t, analyzer.SeverityHigh, s.NewlyFailing[0].Worst)
+
+	require.Len(t, s.SLABreaches, 0, "eve went away on day 8, so web-1's clock restarted")
+}
+
+func TestBuilder_SLABreach(t *testing.T) {
+	to := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	b := NewBuilder("host", Options{
+		From: to.Add(-24 * time.Hour), To: to, Top: 5, FailSeverity: analyzer.SeverityHigh,
+		SLA: map[analyzer.Severity]time.Duration{analyzer.SeverityCritical: 72 * time.Hour},
+	})
+	crit := violation("disk_encryption", analyzer.SeverityCritical, "/ is not encrypted")
+	b.Add("h", report.ComplianceReport{Hostname: "lab", GeneratedAt: to.Add(-100 * time.Hour), Violations: []analyzer.Violation{crit}})
+	b.Add("h", report.ComplianceReport{Hostname: "lab", GeneratedAt: to.Add(-time.Hour), Violations: []analyzer.Violation{crit}})
+
+	s := b.Summary()
+	require.Len(t, s.SLABreaches, 1)
+	br := s.SLABreaches[0]
+	assert.Equal(t, "lab", br.Hostname)
+	assert.Equal(t, 100*time.Hour, br.Open)
+	assert.Equal(t, 72*time.Hour, br.SLA)
+	assert.Empty(t, s.NewlyFailing, "already failing at the start")
+	assert.Equal(t, s.Score, *s.PreviousScore)
+}
+
+func TestRender(t *testing.T) {
+	prev := 90.0
+	s := Summary{
+		Scope: "fleet",
+		From:  time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC),
+		To:    time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC),
+		Hosts: 3, Score: 85.5, PreviousScore: &prev, Violations: 4,
+		TopRules:     []Rule{{Rule: "user", Severity: analyzer.SeverityHigh, Hosts: 2, Scans: 5}},
+		NewlyFailing: []Host{{Hostname: "web-1", Score: 80, Violations: 2, Worst: analyzer.SeverityHigh}},
+		SLABreaches: []Breach{{Hostname: "db-1", Category: "user", Severity: analyzer.SeverityHigh,
+			Message: "unexpected user present: <eve>", Open: 10 * 24 * time.Hour, SLA: 7 * 24 * time.Hour}},
+	}
+	assert.Equal(t, "The fleet's compliance score fell 4.5 points to 85.5. 3 hosts reported, with 4 violations open. 1 host started failing. 1 violation past SLA.", s.Headline())
+
+	md, err := s.Markdown()
+	require.NoError(t, err)
+	assert.Contains(t, string(md), "# Fleet compliance summary, 2026-10-10 to 2026-10-17")
+	assert.Contains(t, string(md), "| Change | -4.5 |")
+	assert.Contains(t, string(md), "| user | high | 2 | 5 |")
+	assert.Contains(t, string(md), "| db-1 | high | user | unexpected user present: <eve> | 10d | 7d |")
+
+	html, err := s.HTML()
+	require.NoError(t, err)
+	assert.Contains(t, string(html), "unexpected user present: &lt;eve&gt;")
+	assert.Contains(t, string(html), `<b class="bad">-4.5</b>change`)
+
+	msg, err := s.Email("compliance@example.com", []string{"ciso@example.com", "it@example.com"})
+	require.NoError(t, err)
+	assert.Contains(t, string(msg), "To: ciso@example.com, it@example.com\r\n")
+	assert.Contains(t, string(msg), "Subject: Fleet compliance summary, 2026-10-10 to 2026-10-17: score 85.5 (-4.5)\r\n")
+	assert.Contains(t, string(msg), "multipart/alternative")
+	assert.Equal(t, 2, strings.Count(string(msg), "Content-Transfer-Encoding: quoted-printable"))
+
+	empty := Summary{Scope: "host", From: s.From, To: s.To}
+	assert.Equal(t, "No scans were reported between 2026-10-10 and 2026-10-17.", empty.Headline())
+	assert.Error(t, Send(config.EmailConfig{}, empty))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"compliance-agent/analyzer"
+	"compliance-agent/errcode"
+	"compliance-agent/golden"
+	"compliance-agent/report"
+)
+
+// cmdValidateImage implements `compliance-agent validate-image`: a full
+// scan inside a machine image build, checked against the golden image's
+// report. It exits 1 on a regression so Packer and friends fail the
+// build.
+func cmdValidateImage(args []string) {
+	fs := flag.NewFlagSet("validate-image", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	baselinePath := fs.String("baseline", "", "Golden image report to check against (required)")
+	update := fs.Bool("update", false, "Write this scan as the new golden report instead of checking")
+	failOn := fs.String("fail-on", "low", "Least severe new violation that fails the image")
+	guard := fs.String("guard", golden.DefaultGuard, "Fact sections where an added or changed fact fails the image, comma-separated")
+	ignore := fs.String("ignore", "", "Fact keys or patterns to leave out, comma-separated (e.g. user/packer*,port/68)")
+	out := fs.String("o", "", "Also write the result as JSON to this file")
+	reportPath := fs.String("report", "", "Also write the image's full report to this file")
+	parseFlags(fs, args)
+
+	if *baselinePath == "" {
+		usageError("validate-image: -baseline is required")
+	}
+	sev, err := analyzer.ParseSeverity(*failOn)
+	if err != nil {
+		fatal(errcode.New(errcode.Usage, err), "-fail-on")
+	}
+	cfg, policies := common.load()
+	var want report.ComplianceReport
+	if !*update {
+		want = readReport(*baselinePath)
+	}
+	// Nothing the scan leaves behind may end up in the image: no history,
+	// evidence log, agent ID or fleet enrollment, and the baseline goes
+	// to a scratch directory.
+	cfg.History.Path = ""
+	cfg.Evidence.Log = ""
+	cfg.Identity.Path = ""
+	cfg.Central.URL = ""
+	scratch, err := os.MkdirTemp("", "validate-image-")
+	if err != nil {
+		fatal(err, "validate-image")
+	}
+	defer os.RemoveAll(scratch)
+	cfg.Baseline.Path = filepath.Join(scratch, "baseline.json")
+
+	ctx, cancel := signalContext()
+	defer cancel()
+	s, closeScanner := startScanner(cfg, policies)
+	// Every collector runs, so a golden report from -update covers
+	// whatever a later policy asks about.
+	rep, err := s.collect(ctx, allOptions(policies))
+	closeScanner()
+	if err != nil {
+		os.RemoveAll(scratch)
+		fatal(err, "validate-image")
+	}
+	analyze(&rep, policies, s.risk, nil)
+	if *reportPath != "" {
+		if err := writeReport(&rep, "json", *reportPath); err != nil {
+			os.RemoveAll(scratch)
+			fatal(err, "write report")
+		}
+	}
+
+	if *update {
+		if err := writeReport(&rep, "json", *baselinePath); err != nil {
+			os.RemoveAll(scratch)
+			fatal(err, "write golden report")
+		}
+		fmt.Fprintf(os.Stderr, "Saved golden report to %s (%d violation(s))\n", *baselinePath, len(rep.Violations))
+		return
+	}
+
+	res := golden.Check(want, rep, golden.Options{
+		FailOn: sev,
+		Guard:  golden.ParseList(*guard),
+		Ignore: golden.ParseList(*ignore),
+	})
+	fmt.Print(res.Text())
+	if *out != "" {
+		b, _ := json.MarshalIndent(res, "", "  ")
+		if err := os.WriteFile(*out, append(b, '\n'), 0o644); err != nil {
+			os.RemoveAll(scratch)
+			fatal(err, "write result")
+		}
+	}
+	if res.Failed() {
+		os.RemoveAll(scratch)
+		os.Exit(errcode.CheckFailed.ExitCode())
+	}
+}